@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/KonstantinGalanin/itemStore/pkg/client"
+)
+
+// admin implements the commands that need the admin role. The server does
+// the role check; these only shape the requests.
+func (a *app) admin(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: admin needs grant, clawback, set-balance or price", errUsage)
+	}
+
+	switch args[0] {
+	case "grant":
+		return a.grant(ctx, args[1:])
+	case "clawback":
+		return a.clawback(ctx, args[1:])
+	case "set-balance":
+		return a.setBalance(ctx, args[1:])
+	case "price":
+		return a.price(ctx, args[1:])
+	}
+
+	return fmt.Errorf("%w: unknown admin command %q", errUsage, args[0])
+}
+
+func (a *app) grant(ctx context.Context, args []string) error {
+	fs := a.flagSet("admin grant")
+	reason := fs.String("reason", "", "why the coins are granted")
+	rest, err := parseArgs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	if len(rest) < 2 {
+		return fmt.Errorf("%w: admin grant needs an amount and at least one user", errUsage)
+	}
+
+	amount, err := parseInt("amount", rest[0])
+	if err != nil {
+		return err
+	}
+
+	adjustments, err := a.client().GrantCoins(ctx, rest[1:], amount, *reason)
+	if err != nil {
+		return err
+	}
+
+	return a.printAdjustments(adjustments)
+}
+
+func (a *app) clawback(ctx context.Context, args []string) error {
+	fs := a.flagSet("admin clawback")
+	reason := fs.String("reason", "", "why the coins are taken back")
+	rest, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	amount, err := parseInt("amount", rest[1])
+	if err != nil {
+		return err
+	}
+
+	adjustment, err := a.client().ClawbackCoins(ctx, rest[0], amount, *reason)
+	if err != nil {
+		return err
+	}
+
+	return a.printAdjustments([]*client.BalanceAdjustment{adjustment})
+}
+
+func (a *app) setBalance(ctx context.Context, args []string) error {
+	fs := a.flagSet("admin set-balance")
+	reason := fs.String("reason", "", "why the balance is corrected")
+	rest, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	balance, err := parseInt("balance", rest[1])
+	if err != nil {
+		return err
+	}
+
+	adjustment, err := a.client().SetBalance(ctx, rest[0], balance, *reason)
+	if err != nil {
+		return err
+	}
+
+	return a.printAdjustments([]*client.BalanceAdjustment{adjustment})
+}
+
+func (a *app) printAdjustments(adjustments []*client.BalanceAdjustment) error {
+	return a.print(adjustments, func(t *table) {
+		t.row("ID", "USER", "KIND", "AMOUNT", "BALANCE", "REASON")
+		for _, adj := range adjustments {
+			t.row(adj.ID, adj.Username, adj.Kind, adj.Amount, adj.Balance, orDash(adj.Reason))
+		}
+	})
+}
+
+// price sets an item's price. Items cannot be taken off sale: users'
+// inventories and order history refer to them.
+func (a *app) price(ctx context.Context, args []string) error {
+	rest, err := parseArgs(a.flagSet("admin price"), args, 2)
+	if err != nil {
+		return err
+	}
+
+	price, err := parseInt("price", rest[1])
+	if err != nil {
+		return err
+	}
+
+	item, err := a.client().SetItemPrice(ctx, rest[0], price)
+	if err != nil {
+		return err
+	}
+
+	return a.print(item, func(t *table) {
+		t.row("ITEM", "PRICE")
+		t.row(item.Name, item.Price)
+	})
+}
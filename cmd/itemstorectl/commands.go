@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/KonstantinGalanin/itemStore/pkg/client"
+)
+
+// login authenticates against the profile's server and caches the token.
+func (a *app) login(ctx context.Context, args []string) error {
+	fs := a.flagSet("login")
+	fromStdin := fs.Bool("password-stdin", false, "read the password from stdin even if ITEMSTORE_PASSWORD is set")
+	rest, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	password := a.getenv("ITEMSTORE_PASSWORD")
+	if *fromStdin || password == "" {
+		if password, err = a.readPassword(); err != nil {
+			return err
+		}
+	}
+
+	token, err := a.client().Auth(ctx, rest[0], password)
+	if err != nil {
+		return err
+	}
+
+	a.profile.Username, a.profile.Token = rest[0], token
+	if err := a.saveProfile(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.stderr, "logged in to %s as %s\n", a.profile.Server, rest[0])
+	return nil
+}
+
+// readPassword reads one line from stdin. There is no terminal handling, so
+// pipe the password in or set ITEMSTORE_PASSWORD to keep it off the screen.
+func (a *app) readPassword() (string, error) {
+	fmt.Fprint(a.stderr, "Password: ")
+
+	line, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password given")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (a *app) logout(args []string) error {
+	if _, err := parseArgs(a.flagSet("logout"), args, 0); err != nil {
+		return err
+	}
+
+	a.profile.Username, a.profile.Token = "", ""
+
+	return a.saveProfile()
+}
+
+func (a *app) balance(ctx context.Context, args []string) error {
+	if _, err := parseArgs(a.flagSet("balance"), args, 0); err != nil {
+		return err
+	}
+
+	info, err := a.client().GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.print(struct {
+		Coins int `json:"coins"`
+	}{info.Coins}, func(t *table) {
+		t.row("COINS")
+		t.row(info.Coins)
+	})
+}
+
+func (a *app) inventory(ctx context.Context, args []string) error {
+	if _, err := parseArgs(a.flagSet("inventory"), args, 0); err != nil {
+		return err
+	}
+
+	info, err := a.client().GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.print(info.Inventory, func(t *table) {
+		t.row("ITEM", "QUANTITY")
+		for _, item := range info.Inventory {
+			t.row(item.ItemType, item.Quantity)
+		}
+	})
+}
+
+func (a *app) history(ctx context.Context, args []string) error {
+	fs := a.flagSet("history")
+	var filter client.TransferFilter
+	fs.StringVar(&filter.Direction, "direction", "", "sent or received")
+	fs.StringVar(&filter.Category, "category", "", "only transfers in this category")
+	fs.StringVar(&filter.Query, "query", "", "match memos and usernames")
+	limit := fs.Int("limit", 20, "transfers per page")
+	offset := fs.Int("offset", 0, "transfers to skip")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	page, err := a.client().SearchHistory(ctx, filter, *limit, *offset)
+	if err != nil {
+		return err
+	}
+
+	return a.print(page, func(t *table) {
+		t.row("ID", "DATE", "FROM", "TO", "AMOUNT", "CATEGORY", "MEMO")
+		for _, tr := range page.Transfers {
+			t.row(tr.ID, formatTime(tr.CreatedAt), tr.FromUser, tr.ToUser, tr.Amount, orDash(tr.Category), orDash(tr.Memo))
+		}
+		if page.Total > len(page.Transfers) {
+			fmt.Fprintf(a.stderr, "showing %d of %d; use -offset %d for more\n", len(page.Transfers), page.Total, page.Offset+len(page.Transfers))
+		}
+	})
+}
+
+func (a *app) items(ctx context.Context, args []string) error {
+	if _, err := parseArgs(a.flagSet("items"), args, 0); err != nil {
+		return err
+	}
+
+	catalog, err := a.client().GetCatalog(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.print(catalog, func(t *table) {
+		t.row("ITEM", "PRICE")
+		for _, item := range catalog {
+			t.row(item.Name, item.Price)
+		}
+	})
+}
+
+func (a *app) send(ctx context.Context, args []string) error {
+	fs := a.flagSet("send")
+	memo := fs.String("memo", "", "note shown to the receiver")
+	category := fs.String("category", "", "thanks, bet, reimbursement, gift or other")
+	rest, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	amount, err := parseInt("amount", rest[1])
+	if err != nil {
+		return err
+	}
+
+	if err := a.client().SendCoin(ctx, rest[0], amount, *memo, *category); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.stderr, "sent %d coins to %s\n", amount, rest[0])
+	return nil
+}
+
+func (a *app) buy(ctx context.Context, args []string) error {
+	fs := a.flagSet("buy")
+	giftTo := fs.String("gift-to", "", "put the item into this user's inventory")
+	message := fs.String("message", "", "gift message")
+	rest, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if *giftTo == "" {
+		if *message != "" {
+			return fmt.Errorf("%w: -message needs -gift-to", errUsage)
+		}
+		if err := a.client().BuyItem(ctx, rest[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stderr, "bought %s\n", rest[0])
+		return nil
+	}
+
+	if err := a.client().GiftItem(ctx, *giftTo, rest[0], *message); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.stderr, "bought %s for %s\n", rest[0], *giftTo)
+	return nil
+}
+
+func parseInt(name, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be a whole number, got %q", errUsage, name, s)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	commands      = []string{"login", "logout", "balance", "inventory", "history", "items", "send", "buy", "admin", "profile", "completion", "help"}
+	adminCommands = []string{"grant", "clawback", "set-balance", "price"}
+	profileVerbs  = []string{"list", "use", "add", "remove"}
+	shells        = []string{"bash", "zsh", "fish"}
+)
+
+// Profile names are completed by asking the tool itself, so new profiles
+// show up without regenerating the script.
+const bashCompletion = `# itemstorectl bash completion
+_itemstorectl() {
+    local cur prev cmd i
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+        -profile) COMPREPLY=($(compgen -W "$(itemstorectl profile list -q 2>/dev/null)" -- "$cur")); return ;;
+        -output) COMPREPLY=($(compgen -W "table json" -- "$cur")); return ;;
+    esac
+
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -profile|-server|-output) ((i++)) ;;
+            -*) ;;
+            *) cmd="${COMP_WORDS[i]}"; break ;;
+        esac
+    done
+
+    if [[ -z "$cmd" ]]; then
+        COMPREPLY=($(compgen -W "%[1]s -profile -server -output" -- "$cur"))
+        return
+    fi
+    if ((i + 1 != COMP_CWORD)); then
+        [[ "$cmd" == profile && ( "$prev" == use || "$prev" == remove ) ]] &&
+            COMPREPLY=($(compgen -W "$(itemstorectl profile list -q 2>/dev/null)" -- "$cur"))
+        return
+    fi
+    case "$cmd" in
+        admin) COMPREPLY=($(compgen -W "%[2]s" -- "$cur")) ;;
+        profile) COMPREPLY=($(compgen -W "%[3]s" -- "$cur")) ;;
+        completion) COMPREPLY=($(compgen -W "%[4]s" -- "$cur")) ;;
+    esac
+}
+complete -F _itemstorectl itemstorectl
+`
+
+const zshCompletion = `#compdef itemstorectl
+# itemstorectl zsh completion
+_itemstorectl() {
+    local -a commands
+    commands=(%[1]s)
+    _arguments -C \
+        '-profile[configuration profile]:profile:($(itemstorectl profile list -q 2>/dev/null))' \
+        '-server[server URL]:url:' \
+        '-output[output format]:format:(table json)' \
+        '1:command:($commands)' \
+        '*::arg:->args'
+    case "$state" in
+        args)
+            case "$words[1]" in
+                admin) _values 'admin command' %[2]s ;;
+                profile)
+                    if ((CURRENT == 2)); then
+                        _values 'profile command' %[3]s
+                    elif [[ "$words[2]" == (use|remove) ]]; then
+                        _values 'profile' $(itemstorectl profile list -q 2>/dev/null)
+                    fi ;;
+                completion) _values 'shell' %[4]s ;;
+            esac ;;
+    esac
+}
+compdef _itemstorectl itemstorectl
+`
+
+const fishCompletion = `# itemstorectl fish completion
+complete -c itemstorectl -f
+complete -c itemstorectl -o profile -x -a '(itemstorectl profile list -q 2>/dev/null)'
+complete -c itemstorectl -o server -x
+complete -c itemstorectl -o output -x -a 'table json'
+complete -c itemstorectl -n 'not __fish_seen_subcommand_from %[1]s' -a '%[1]s'
+complete -c itemstorectl -n '__fish_seen_subcommand_from admin; and not __fish_seen_subcommand_from %[2]s' -a '%[2]s'
+complete -c itemstorectl -n '__fish_seen_subcommand_from profile; and not __fish_seen_subcommand_from %[3]s' -a '%[3]s'
+complete -c itemstorectl -n '__fish_seen_subcommand_from use remove' -a '(itemstorectl profile list -q 2>/dev/null)'
+complete -c itemstorectl -n '__fish_seen_subcommand_from completion' -a '%[4]s'
+`
+
+// completion prints a completion script for the given shell. Load it with
+// e.g. "source <(itemstorectl completion bash)".
+func (a *app) completion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: completion needs one of %s", errUsage, strings.Join(shells, ", "))
+	}
+
+	var script string
+	switch args[0] {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("%w: no completion for shell %q", errUsage, args[0])
+	}
+
+	_, err := fmt.Fprintf(a.stdout, script,
+		strings.Join(commands, " "),
+		strings.Join(adminCommands, " "),
+		strings.Join(profileVerbs, " "),
+		strings.Join(shells, " "))
+	return err
+}
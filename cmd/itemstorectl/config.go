@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// defaultServer is where the default profile points until it is changed.
+const defaultServer = "http://localhost:8080"
+
+const defaultProfile = "default"
+
+// config is the file kept under the user's config directory. It holds
+// cached tokens, so it is written readable by the owner only.
+type config struct {
+	Current  string              `json:"current"`
+	Profiles map[string]*profile `json:"profiles"`
+}
+
+// profile is one environment the tool can talk to.
+type profile struct {
+	Server   string `json:"server"`
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// defaultConfigPath returns ITEMSTORE_CONFIG, or config.json in the user's
+// config directory.
+func (a *app) defaultConfigPath() (string, error) {
+	if path := a.getenv("ITEMSTORE_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "itemstorectl", "config.json"), nil
+}
+
+func (a *app) loadConfig() error {
+	if a.configPath == "" {
+		path, err := a.defaultConfigPath()
+		if err != nil {
+			return err
+		}
+		a.configPath = path
+	}
+
+	a.config = &config{
+		Current:  defaultProfile,
+		Profiles: map[string]*profile{defaultProfile: {Server: defaultServer}},
+	}
+
+	data, err := os.ReadFile(a.configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, a.config); err != nil {
+		return fmt.Errorf("read %s: %w", a.configPath, err)
+	}
+	if a.config.Profiles == nil {
+		a.config.Profiles = map[string]*profile{}
+	}
+
+	return nil
+}
+
+func (a *app) saveConfig() error {
+	data, err := json.MarshalIndent(a.config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.configPath), 0o700); err != nil {
+		return err
+	}
+
+	// Write a sibling file and rename it so a failed write cannot leave a
+	// truncated config behind.
+	tmp := a.configPath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, a.configPath)
+}
+
+// selectProfile picks the named profile, or the current one when name is
+// empty. server overrides the profile's server for this run only.
+func (a *app) selectProfile(name, server string) error {
+	if name == "" {
+		name = a.config.Current
+	}
+
+	p, ok := a.config.Profiles[name]
+	if !ok {
+		return fmt.Errorf("no profile %q; add it with \"itemstorectl profile add\"", name)
+	}
+
+	a.profileName, a.profile = name, p
+	if server != "" {
+		// Copy so the override is never written back to the file.
+		override := *p
+		override.Server = server
+		a.profile = &override
+	}
+
+	return nil
+}
+
+// saveProfile stores the selected profile's login state. Copying the fields
+// rather than the profile keeps a -server override out of the file.
+func (a *app) saveProfile() error {
+	stored := a.config.Profiles[a.profileName]
+	stored.Username, stored.Token = a.profile.Username, a.profile.Token
+
+	return a.saveConfig()
+}
+
+func (a *app) profileNames() []string {
+	names := make([]string, 0, len(a.config.Profiles))
+	for name := range a.config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// profileCommand implements "profile list", "use", "add" and "remove".
+func (a *app) profileCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: profile needs list, use, add or remove", errUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		flags := a.flagSet("profile list")
+		quiet := flags.Bool("q", false, "print only the profile names")
+		if _, err := parseArgs(flags, args[1:], 0); err != nil {
+			return err
+		}
+		return a.listProfiles(*quiet)
+
+	case "use":
+		flags := a.flagSet("profile use")
+		rest, err := parseArgs(flags, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		if _, ok := a.config.Profiles[rest[0]]; !ok {
+			return fmt.Errorf("no profile %q", rest[0])
+		}
+		a.config.Current = rest[0]
+		return a.saveConfig()
+
+	case "add":
+		flags := a.flagSet("profile add")
+		server := flags.String("server", defaultServer, "server URL")
+		rest, err := parseArgs(flags, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		if p, ok := a.config.Profiles[rest[0]]; ok {
+			// Changing the server invalidates the cached login.
+			if p.Server != *server {
+				*p = profile{Server: *server}
+			}
+		} else {
+			a.config.Profiles[rest[0]] = &profile{Server: *server}
+		}
+		return a.saveConfig()
+
+	case "remove":
+		flags := a.flagSet("profile remove")
+		rest, err := parseArgs(flags, args[1:], 1)
+		if err != nil {
+			return err
+		}
+		if _, ok := a.config.Profiles[rest[0]]; !ok {
+			return fmt.Errorf("no profile %q", rest[0])
+		}
+		if rest[0] == a.config.Current {
+			return fmt.Errorf("profile %q is in use; switch with \"itemstorectl profile use\" first", rest[0])
+		}
+		delete(a.config.Profiles, rest[0])
+		return a.saveConfig()
+	}
+
+	return fmt.Errorf("%w: unknown profile command %q", errUsage, args[0])
+}
+
+func (a *app) listProfiles(quiet bool) error {
+	names := a.profileNames()
+	if quiet {
+		for _, name := range names {
+			fmt.Fprintln(a.stdout, name)
+		}
+		return nil
+	}
+
+	type row struct {
+		Name     string `json:"name"`
+		Server   string `json:"server"`
+		Username string `json:"username,omitempty"`
+		Current  bool   `json:"current"`
+		LoggedIn bool   `json:"loggedIn"`
+	}
+	rows := make([]row, 0, len(names))
+	for _, name := range names {
+		p := a.config.Profiles[name]
+		rows = append(rows, row{name, p.Server, p.Username, name == a.config.Current, p.Token != ""})
+	}
+
+	return a.print(rows, func(t *table) {
+		t.row("", "NAME", "SERVER", "USER")
+		for _, r := range rows {
+			current := ""
+			if r.Current {
+				current = "*"
+			}
+			t.row(current, r.Name, r.Server, orDash(r.Username))
+		}
+	})
+}
@@ -0,0 +1,203 @@
+// Command itemstorectl is a command-line client for the item store API.
+//
+// It logs in once and caches the token per profile, so each profile can
+// point at a different environment:
+//
+//	itemstorectl profile add -server https://staging.example.com staging
+//	itemstorectl -profile staging login alice
+//	itemstorectl -profile staging balance
+//
+// Output is a table by default; -output json prints the API's JSON instead.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/KonstantinGalanin/itemStore/pkg/client"
+)
+
+const usage = `usage: itemstorectl [-profile name] [-server url] [-output table|json] command [args]
+
+Commands:
+  login [-password-stdin] username   log in and cache the token
+  logout                             forget the cached token
+  balance                            show your coins
+  inventory                          show the items you own
+  history [flags]                    search your coin transfers
+  items                              list the items on sale
+  send [-memo m] [-category c] user amount
+                                     send coins to a user
+  buy [-gift-to user] [-message m] item
+                                     buy an item, or buy it for someone else
+  admin grant [-reason r] amount user...
+  admin clawback [-reason r] user amount
+  admin set-balance [-reason r] user balance
+  admin price item price             set an item's price, adding it if new
+  profile list [-q] | use name | add [-server url] name | remove name
+  completion bash|zsh|fish           print a shell completion script
+
+The password is read from ITEMSTORE_PASSWORD, or from stdin.
+`
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var errUsage = errors.New("invalid usage")
+
+// app holds what a command run needs, so tests can swap out the process
+// environment.
+type app struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+
+	configPath string
+	config     *config
+
+	profileName string
+	profile     *profile
+	output      string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a := &app{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	}
+	os.Exit(a.main(ctx, os.Args[1:]))
+}
+
+// main runs one command and returns the process exit code.
+func (a *app) main(ctx context.Context, args []string) int {
+	err := a.run(ctx, args)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(a.stderr, "itemstorectl: %v\n\n%s", err, usage)
+		return 2
+	case errors.Is(err, client.ErrUnauthorized):
+		fmt.Fprintf(a.stderr, "itemstorectl: %v\nrun \"itemstorectl -profile %s login\" to log in again\n", err, a.profileName)
+		return 1
+	}
+
+	fmt.Fprintf(a.stderr, "itemstorectl: %v\n", err)
+	return 1
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	fs := a.flagSet("itemstorectl")
+	fs.Usage = func() { fmt.Fprint(a.stderr, usage) }
+	profileName := fs.String("profile", a.getenv("ITEMSTORE_PROFILE"), "configuration profile to use")
+	server := fs.String("server", "", "server URL, overriding the profile's")
+	fs.StringVar(&a.output, "output", outputTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if a.output != outputTable && a.output != outputJSON {
+		return fmt.Errorf("%w: unknown output format %q", errUsage, a.output)
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no command given", errUsage)
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "completion":
+		return a.completion(args)
+	case "help":
+		fmt.Fprint(a.stdout, usage)
+		return nil
+	}
+
+	if err := a.loadConfig(); err != nil {
+		return err
+	}
+	if command == "profile" {
+		return a.profileCommand(args)
+	}
+	if err := a.selectProfile(*profileName, *server); err != nil {
+		return err
+	}
+
+	switch command {
+	case "login":
+		return a.login(ctx, args)
+	case "logout":
+		return a.logout(args)
+	case "balance":
+		return a.balance(ctx, args)
+	case "inventory":
+		return a.inventory(ctx, args)
+	case "history":
+		return a.history(ctx, args)
+	case "items":
+		return a.items(ctx, args)
+	case "send":
+		return a.send(ctx, args)
+	case "buy":
+		return a.buy(ctx, args)
+	case "admin":
+		return a.admin(ctx, args)
+	}
+
+	return fmt.Errorf("%w: unknown command %q", errUsage, command)
+}
+
+// client returns an API client for the current profile, using its cached
+// token.
+func (a *app) client() *client.Client {
+	c := client.New(a.profile.Server)
+	if a.profile.Token != "" {
+		c.SetToken(a.profile.Token)
+	}
+
+	return c
+}
+
+func (a *app) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	return fs
+}
+
+// parseArgs parses fs from args and returns the positional arguments,
+// allowing flags to come after them as in "send bob 10 -memo lunch".
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if want >= 0 && len(positional) != want {
+		return nil, fmt.Errorf("%w: %s takes %d argument(s), got %d", errUsage, fs.Name(), want, len(positional))
+	}
+
+	return positional, nil
+}
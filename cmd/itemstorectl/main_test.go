@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/handlers"
+	"github.com/KonstantinGalanin/itemStore/internal/router"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/pkg/jwt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnv struct {
+	t            *testing.T
+	server       string
+	configPath   string
+	env          map[string]string
+	userService  *service.MockUserService
+	adminService *service.MockAdminService
+}
+
+// newTestEnv starts the real router over mock services and points the
+// default profile at it.
+func newTestEnv(t *testing.T) *testEnv {
+	ctrl := gomock.NewController(t)
+	e := &testEnv{
+		t:            t,
+		configPath:   filepath.Join(t.TempDir(), "itemstorectl", "config.json"),
+		env:          map[string]string{},
+		userService:  service.NewMockUserService(ctrl),
+		adminService: service.NewMockAdminService(ctrl),
+	}
+
+	server := httptest.NewServer(router.NewRouter(
+		handlers.NewUserHandler(e.userService, jwt.NewJwtService()),
+		handlers.NewAdminHandler(e.adminService),
+		handlers.NewAuditHandler(service.NewMockAuditService(ctrl)),
+	))
+	t.Cleanup(server.Close)
+	e.server = server.URL
+
+	_, _, code := e.run("", "profile", "add", "-server", e.server, "default")
+	require.Equal(t, 0, code)
+
+	return e
+}
+
+// run executes the tool with stdin and returns its output and exit code.
+func (e *testEnv) run(stdin string, args ...string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	a := &app{
+		stdin:      strings.NewReader(stdin),
+		stdout:     &stdout,
+		stderr:     &stderr,
+		getenv:     func(key string) string { return e.env[key] },
+		configPath: e.configPath,
+	}
+
+	code := a.main(context.Background(), args)
+	return stdout.String(), stderr.String(), code
+}
+
+func (e *testEnv) login(username, role string) {
+	e.userService.EXPECT().Auth(gomock.Any(), username, "password1").
+		Return(&entities.User{Username: username, Role: role}, nil)
+
+	_, stderr, code := e.run("password1\n", "login", username)
+	require.Equal(e.t, 0, code, stderr)
+}
+
+func (e *testEnv) config() config {
+	data, err := os.ReadFile(e.configPath)
+	require.NoError(e.t, err)
+
+	var cfg config
+	require.NoError(e.t, json.Unmarshal(data, &cfg))
+	return cfg
+}
+
+func TestLogin(t *testing.T) {
+	e := newTestEnv(t)
+	e.login("alice", entities.RoleUser)
+
+	cfg := e.config()
+	assert.Equal(t, "alice", cfg.Profiles["default"].Username)
+	assert.NotEmpty(t, cfg.Profiles["default"].Token)
+
+	info, err := os.Stat(e.configPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Later runs use the cached token instead of logging in.
+	e.userService.EXPECT().GetInfo(gomock.Any(), "alice").Return(&entities.InfoResponse{Coins: 990}, nil)
+	stdout, _, code := e.run("", "balance")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "COINS\n990\n", stdout)
+
+	_, _, code = e.run("", "logout")
+	assert.Equal(t, 0, code)
+	assert.Empty(t, e.config().Profiles["default"].Token)
+
+	_, stderr, code := e.run("", "balance")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `run "itemstorectl -profile default login"`)
+
+	t.Run("password from environment", func(t *testing.T) {
+		e.env["ITEMSTORE_PASSWORD"] = "password1"
+		defer delete(e.env, "ITEMSTORE_PASSWORD")
+		e.userService.EXPECT().Auth(gomock.Any(), "bob", "password1").
+			Return(&entities.User{Username: "bob", Role: entities.RoleUser}, nil)
+
+		_, stderr, code := e.run("", "login", "bob")
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "bob", e.config().Profiles["default"].Username)
+	})
+
+	t.Run("no password", func(t *testing.T) {
+		_, stderr, code := e.run("", "login", "bob")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "no password given")
+	})
+}
+
+func TestUserCommands(t *testing.T) {
+	e := newTestEnv(t)
+	e.login("alice", entities.RoleUser)
+
+	t.Run("inventory", func(t *testing.T) {
+		e.userService.EXPECT().GetInfo(gomock.Any(), "alice").
+			Return(&entities.InfoResponse{Inventory: []*entities.Item{{ItemType: "cup", Quantity: 2}, {ItemType: "pink-hoody", Quantity: 1}}}, nil)
+
+		stdout, _, code := e.run("", "inventory")
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "ITEM        QUANTITY\ncup         2\npink-hoody  1\n", stdout)
+	})
+
+	t.Run("inventory as json", func(t *testing.T) {
+		e.userService.EXPECT().GetInfo(gomock.Any(), "alice").
+			Return(&entities.InfoResponse{Inventory: []*entities.Item{{ItemType: "cup", Quantity: 2}}}, nil)
+
+		stdout, _, code := e.run("", "-output", "json", "inventory")
+		assert.Equal(t, 0, code)
+		var items []entities.Item
+		require.NoError(t, json.Unmarshal([]byte(stdout), &items))
+		assert.Equal(t, []entities.Item{{ItemType: "cup", Quantity: 2}}, items)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		e.userService.EXPECT().SearchHistory(gomock.Any(), "alice", entities.TransferFilter{Direction: "sent", Query: "lunch"}, 1, 0).
+			Return(&entities.TransfersPage{
+				Transfers: []*entities.CoinTransfer{{ID: 3, FromUser: "alice", ToUser: "bob", Amount: 10, Memo: "lunch", Category: "other"}},
+				Total:     4,
+				Limit:     1,
+			}, nil)
+
+		stdout, stderr, code := e.run("", "history", "-direction", "sent", "-query", "lunch", "-limit", "1")
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "ID  DATE  FROM   TO   AMOUNT  CATEGORY  MEMO\n3   -     alice  bob  10      other     lunch\n", stdout)
+		assert.Equal(t, "showing 1 of 4; use -offset 1 for more\n", stderr)
+	})
+
+	t.Run("items", func(t *testing.T) {
+		e.userService.EXPECT().GetCatalog(gomock.Any()).
+			Return([]*entities.CatalogItem{{Name: "cup", Price: 20}, {Name: "pen", Price: 10}}, nil)
+
+		stdout, _, code := e.run("", "items")
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "ITEM  PRICE\ncup   20\npen   10\n", stdout)
+	})
+
+	t.Run("send", func(t *testing.T) {
+		e.userService.EXPECT().SendCoin(gomock.Any(), "alice", "bob", 10, "lunch", "thanks").Return(nil)
+
+		_, stderr, code := e.run("", "send", "bob", "10", "-memo", "lunch", "-category", "thanks")
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "sent 10 coins to bob\n", stderr)
+	})
+
+	t.Run("send bad amount", func(t *testing.T) {
+		_, stderr, code := e.run("", "send", "bob", "ten")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, `amount must be a whole number, got "ten"`)
+	})
+
+	t.Run("buy", func(t *testing.T) {
+		e.userService.EXPECT().BuyItem(gomock.Any(), "alice", "cup").Return(nil)
+
+		_, stderr, code := e.run("", "buy", "cup")
+		assert.Equal(t, 0, code, stderr)
+	})
+
+	t.Run("buy as gift", func(t *testing.T) {
+		e.userService.EXPECT().GiftItem(gomock.Any(), "alice", "bob", "cup", "enjoy").Return(nil)
+
+		_, stderr, code := e.run("", "buy", "-gift-to", "bob", "-message", "enjoy", "cup")
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "bought cup for bob\n", stderr)
+	})
+
+	t.Run("admin only", func(t *testing.T) {
+		_, stderr, code := e.run("", "admin", "price", "cup", "25")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "(403)")
+	})
+}
+
+func TestAdminCommands(t *testing.T) {
+	e := newTestEnv(t)
+	e.login("root", entities.RoleAdmin)
+
+	t.Run("grant", func(t *testing.T) {
+		e.adminService.EXPECT().GrantCoins(gomock.Any(), "root", []string{"alice", "bob"}, 100, "bonus").
+			Return([]*entities.BalanceAdjustment{
+				{ID: 1, Username: "alice", Kind: "grant", Amount: 100, Balance: 1100, Reason: "bonus"},
+				{ID: 2, Username: "bob", Kind: "grant", Amount: 100, Balance: 1100, Reason: "bonus"},
+			}, nil)
+
+		stdout, stderr, code := e.run("", "admin", "grant", "-reason", "bonus", "100", "alice", "bob")
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, "ID  USER   KIND   AMOUNT  BALANCE  REASON\n1   alice  grant  100     1100     bonus\n2   bob    grant  100     1100     bonus\n", stdout)
+	})
+
+	t.Run("grant without users", func(t *testing.T) {
+		_, _, code := e.run("", "admin", "grant", "100")
+		assert.Equal(t, 2, code)
+	})
+
+	t.Run("price", func(t *testing.T) {
+		e.adminService.EXPECT().SetItemPrice(gomock.Any(), "root", "sticker", 15).
+			Return(&entities.CatalogItem{Name: "sticker", Price: 15}, nil)
+
+		stdout, stderr, code := e.run("", "-output", "json", "admin", "price", "sticker", "15")
+		assert.Equal(t, 0, code, stderr)
+		assert.JSONEq(t, `{"name":"sticker","price":15}`, stdout)
+	})
+}
+
+func TestProfiles(t *testing.T) {
+	e := newTestEnv(t)
+	e.login("alice", entities.RoleUser)
+
+	_, _, code := e.run("", "profile", "add", "-server", "https://staging.example.com", "staging")
+	require.Equal(t, 0, code)
+
+	stdout, _, code := e.run("", "profile", "list")
+	assert.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"NAME", "SERVER", "USER"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"*", "default", e.server, "alice"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"staging", "https://staging.example.com", "-"}, strings.Fields(lines[2]))
+
+	stdout, _, _ = e.run("", "profile", "list", "-q")
+	assert.Equal(t, "default\nstaging\n", stdout)
+
+	_, _, code = e.run("", "profile", "use", "staging")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "staging", e.config().Current)
+
+	_, stderr, code := e.run("", "profile", "remove", "staging")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "is in use")
+
+	// -profile and ITEMSTORE_PROFILE pick a profile for one run.
+	e.userService.EXPECT().GetInfo(gomock.Any(), "alice").Return(&entities.InfoResponse{Coins: 5}, nil).Times(2)
+	stdout, _, code = e.run("", "-profile", "default", "balance")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "COINS\n5\n", stdout)
+
+	e.env["ITEMSTORE_PROFILE"] = "default"
+	stdout, _, code = e.run("", "balance")
+	delete(e.env, "ITEMSTORE_PROFILE")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "COINS\n5\n", stdout)
+
+	_, stderr, code = e.run("", "-profile", "prod", "balance")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `no profile "prod"`)
+
+	// A -server override is used for the run but never saved.
+	e.userService.EXPECT().Auth(gomock.Any(), "bob", "password1").
+		Return(&entities.User{Username: "bob", Role: entities.RoleUser}, nil)
+	_, stderr, code = e.run("password1\n", "-server", e.server, "login", "bob")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "https://staging.example.com", e.config().Profiles["staging"].Server)
+	assert.Equal(t, "bob", e.config().Profiles["staging"].Username)
+
+	// Pointing a profile elsewhere drops its cached login.
+	_, _, code = e.run("", "profile", "add", "-server", "https://prod.example.com", "staging")
+	assert.Equal(t, 0, code)
+	assert.Empty(t, e.config().Profiles["staging"].Token)
+}
+
+func TestUsage(t *testing.T) {
+	e := newTestEnv(t)
+
+	for _, args := range [][]string{
+		{},
+		{"frobnicate"},
+		{"-output", "yaml", "balance"},
+		{"send", "bob"},
+		{"admin"},
+		{"completion", "powershell"},
+	} {
+		_, stderr, code := e.run("", args...)
+		assert.Equal(t, 2, code, "%q", args)
+		assert.Contains(t, stderr, "usage: itemstorectl", "%q", args)
+	}
+
+	for _, shell := range shells {
+		stdout, _, code := e.run("", "completion", shell)
+		assert.Equal(t, 0, code)
+		assert.Contains(t, stdout, "itemstorectl profile list -q")
+		assert.NotContains(t, stdout, "%!", shell)
+	}
+}
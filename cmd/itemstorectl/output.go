@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// table lines up rows in columns.
+type table struct {
+	w *tabwriter.Writer
+}
+
+func (t *table) row(cells ...any) {
+	parts := make([]string, len(cells))
+	for i, cell := range cells {
+		parts[i] = fmt.Sprint(cell)
+	}
+	fmt.Fprintln(t.w, strings.Join(parts, "\t"))
+}
+
+// print writes v as indented JSON with -output json, and otherwise lets
+// writeTable lay it out.
+func (a *app) print(v any, writeTable func(t *table)) error {
+	if a.output == outputJSON {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	t := &table{w: tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)}
+	writeTable(t)
+
+	return t.w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// orDash keeps empty cells visible in a table.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -1,51 +1,355 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/KonstantinGalanin/itemStore/internal/config"
+	"github.com/KonstantinGalanin/itemStore/internal/email"
+	"github.com/KonstantinGalanin/itemStore/internal/events"
 	"github.com/KonstantinGalanin/itemStore/internal/handlers"
+	"github.com/KonstantinGalanin/itemStore/internal/notify"
+	"github.com/KonstantinGalanin/itemStore/internal/outbox"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/memory"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqlite"
 	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
 	"github.com/KonstantinGalanin/itemStore/internal/router"
 	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/webhook"
 	"github.com/KonstantinGalanin/itemStore/pkg/jwt"
 
 	_ "github.com/lib/pq"
 )
 
-var (
-	dbHost = os.Getenv("DATABASE_HOST")
-	dbPort = os.Getenv("DATABASE_PORT")
-	dbUser = os.Getenv("DATABASE_USER")
-	dbPass = os.Getenv("DATABASE_PASSWORD")
-	dbName = os.Getenv("DATABASE_NAME")
-
-	serverPort = os.Getenv("SERVER_PORT")
-)
-
 func main() {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPass, dbName)
-	db, err := sql.Open("postgres", dsn)
+	cfg, err := config.Load()
 	if err != nil {
 		panic(err)
 	}
-	defer db.Close()
 
-	if err := db.Ping(); err != nil {
-		panic(err)
+	ctx := context.Background()
+	migrate := len(os.Args) > 1 && os.Args[1] == "migrate"
+	setRole := len(os.Args) > 1 && os.Args[1] == "role"
+
+	var userRepo service.UserRepo
+	var txManager service.TxManager
+	var eventHub service.EventHub
+	if cfg.Storage.Driver == config.DriverMemory {
+		if migrate {
+			fmt.Fprintln(os.Stderr, "the memory storage driver has no schema to migrate")
+			os.Exit(1)
+		}
+		if setRole {
+			fmt.Fprintln(os.Stderr, "the memory storage driver keeps no users between runs")
+			os.Exit(1)
+		}
+		memoryRepo := memory.NewUserMemoryRepo()
+		userRepo, txManager = memoryRepo, memoryRepo
+		eventHub = events.NewHub()
+	} else {
+		db, err := openDB(cfg)
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+
+		migrator, err := newMigrator(cfg.Storage.Driver, db)
+		if err != nil {
+			panic(err)
+		}
+
+		if migrate {
+			if err := runMigrate(ctx, migrator, os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if cfg.AutoMigrate {
+			if _, err := migrator.Up(ctx); err != nil {
+				panic(err)
+			}
+		}
+
+		if cfg.Storage.Driver == config.DriverSQLite {
+			userRepo = sqlite.NewUserSQLiteRepo(db)
+			txManager = sqlite.NewTxManager(db)
+			eventHub = events.NewHub()
+		} else {
+			userRepo = repository.NewUserPostgresRepo(db)
+			txManager = repository.NewTxManager(db)
+
+			pgHub := events.NewPGHub(db, cfg.Database.DSN())
+			go func() {
+				if err := pgHub.Listen(ctx); err != nil {
+					log.Printf("listen for user events: %v", err)
+				}
+			}()
+			eventHub = pgHub
+		}
+	}
+
+	userService := service.NewUserService(userRepo, txManager)
+	userService.Lockout = cfg.Lockout
+	userService.RefundWindow = cfg.RefundWindow
+	userService.Market = cfg.Market
+	userService.PaymentRequestTTL = cfg.PaymentRequestTTL
+	userService.TransferLimits = cfg.TransferLimits
+	userService.Roles = cfg.Roles
+	userService.Notifier = service.NewInbox(userRepo)
+	userService.Auditor = service.NewAuditLog(userRepo, txManager)
+	userService.Webhooks = service.NewWebhooks(userRepo)
+	userService.WebhookSender = webhook.NewSender()
+	userService.LowBalanceThreshold = cfg.LowBalanceThreshold
+	userService.Events = service.NewUserEvents(userRepo, eventHub)
+	userService.EventRetention = cfg.EventRetention
+
+	if setRole {
+		if err := runRole(ctx, userService, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	userRepo := repository.NewUserPostgresRepo(db)
-	userService := service.NewUserService(userRepo)
+	if cfg.Email.SMTPAddr != "" {
+		templates, err := email.New()
+		if err != nil {
+			panic(err)
+		}
+		userService.Notifier = notify.NewMulti(service.NewInbox(userRepo), service.NewMailer(userRepo, templates))
+		userService.EmailTemplates = templates
+		userService.EmailSender = email.NewSMTPSender(cfg.Email.SMTPAddr, cfg.Email.From, cfg.Email.SMTPUsername, cfg.Email.SMTPPassword)
+		userService.EmailDigestInterval = cfg.Email.DigestInterval
+
+		go sendEmails(ctx, userService, cfg.Email.Interval)
+		go sendEmailDigests(ctx, userService, min(emailDigestCheckInterval, cfg.Email.DigestInterval))
+	}
+
+	if len(cfg.Outbox.Sinks) > 0 {
+		sinks, err := eventSinks(cfg.Outbox)
+		if err != nil {
+			panic(err)
+		}
+		userService.Publisher = service.NewOutbox(userRepo)
+		userService.EventSinks = sinks
+
+		hostname, _ := os.Hostname()
+		go relayOutbox(ctx, userService, fmt.Sprintf("%s-%d", hostname, os.Getpid()), cfg.Outbox.Interval)
+	}
+
+	if err := userService.RecordConfig(ctx, cfg.AuditSettings()); err != nil {
+		log.Printf("record config in audit log: %v", err)
+	}
+
+	go expireListings(ctx, userService, cfg.ListingExpiryInterval)
+	go runSchedules(ctx, userService, cfg.ScheduleInterval)
+	go expirePaymentRequests(ctx, userService, cfg.PaymentRequestExpiryInterval)
+	go deliverWebhooks(ctx, userService, cfg.WebhookInterval)
+	go pruneUserEvents(ctx, userService, eventPruneInterval)
 
 	jwtService := jwt.NewJwtService()
 	userHandler := handlers.NewUserHandler(userService, jwtService)
+	adminHandler := handlers.NewAdminHandler(userService)
+	auditHandler := handlers.NewAuditHandler(userService)
 
-	r := router.NewRouter(userHandler)
-	err = http.ListenAndServe(":" + serverPort, r)
+	r := router.NewRouter(userHandler, adminHandler, auditHandler)
+	err = http.ListenAndServe(":" + cfg.Server.Port, r)
 	if err != nil {
 		panic(err)
 	}
 }
+
+// expireListings periodically closes market listings that ran out of time
+// so their items go back to the sellers.
+func expireListings(ctx context.Context, userService *service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.ExpireListings(ctx); err != nil {
+				log.Printf("expire listings: %v", err)
+			}
+		}
+	}
+}
+
+// runSchedules periodically executes scheduled coin transfers that are
+// due. It is safe to run on every replica.
+func runSchedules(ctx context.Context, userService *service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.RunDueSchedules(ctx); err != nil {
+				log.Printf("run scheduled transfers: %v", err)
+			}
+		}
+	}
+}
+
+// expirePaymentRequests periodically closes payment requests nobody
+// answered in time.
+func expirePaymentRequests(ctx context.Context, userService *service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.ExpirePaymentRequests(ctx); err != nil {
+				log.Printf("expire payment requests: %v", err)
+			}
+		}
+	}
+}
+
+// deliverWebhooks periodically sends the webhook deliveries that are due.
+// It is safe to run on every replica.
+func deliverWebhooks(ctx context.Context, userService *service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.DeliverWebhooks(ctx); err != nil {
+				log.Printf("deliver webhooks: %v", err)
+			}
+		}
+	}
+}
+
+// sendEmails periodically sends the queued emails that are due. It is safe
+// to run on every replica.
+func sendEmails(ctx context.Context, userService *service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.SendEmails(ctx); err != nil {
+				log.Printf("send emails: %v", err)
+			}
+		}
+	}
+}
+
+// emailDigestCheckInterval is how often users are checked for a due digest.
+const emailDigestCheckInterval = time.Hour
+
+// sendEmailDigests periodically queues the digests that are due.
+func sendEmailDigests(ctx context.Context, userService *service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.SendEmailDigests(ctx); err != nil {
+				log.Printf("send email digests: %v", err)
+			}
+		}
+	}
+}
+
+// eventPruneInterval is how often events past their retention are deleted.
+const eventPruneInterval = time.Hour
+
+// pruneUserEvents periodically deletes the events streams can no longer be
+// resumed from.
+func pruneUserEvents(ctx context.Context, userService *service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.PruneUserEvents(ctx); err != nil {
+				log.Printf("prune user events: %v", err)
+			}
+		}
+	}
+}
+
+// eventSinks builds the sinks the outbox relay delivers events to.
+func eventSinks(cfg config.OutboxConfig) ([]service.EventSink, error) {
+	var sinks []service.EventSink
+	for _, name := range cfg.Sinks {
+		switch name {
+		case outbox.SinkStdout:
+			sinks = append(sinks, outbox.NewWriterSink(os.Stdout))
+		case outbox.SinkFile:
+			sink, err := outbox.NewFileSink(cfg.File)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case outbox.SinkWebhook:
+			sinks = append(sinks, outbox.NewWebhookSink(cfg.WebhookURL))
+		}
+	}
+
+	return sinks, nil
+}
+
+// relayOutbox periodically delivers domain events from the outbox. Every
+// replica may run it; the outbox lease lets only one deliver at a time.
+func relayOutbox(ctx context.Context, userService *service.UserService, relayID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := userService.RelayOutbox(ctx, relayID); err != nil {
+				log.Printf("relay outbox: %v", err)
+			}
+		}
+	}
+}
+
+func openDB(cfg *config.Config) (*sql.DB, error) {
+	if cfg.Storage.Driver == config.DriverSQLite {
+		return sqlite.Open(cfg.Storage.SQLitePath)
+	}
+
+	db, err := sql.Open("postgres", cfg.Database.DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/KonstantinGalanin/itemStore/internal/config"
+	"github.com/KonstantinGalanin/itemStore/internal/migrations"
+)
+
+var errMigrateUsage = errors.New("usage: item_store migrate up | down [steps] | status | version")
+
+func newMigrator(driver string, db *sql.DB) (*migrations.Migrator, error) {
+	if driver == config.DriverSQLite {
+		list, err := migrations.SQLite()
+		if err != nil {
+			return nil, err
+		}
+		return migrations.NewMigrator(db, migrations.SQLiteDialect, list), nil
+	}
+
+	list, err := migrations.Postgres()
+	if err != nil {
+		return nil, err
+	}
+
+	return migrations.NewMigrator(db, migrations.PostgresDialect, list), nil
+}
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(ctx context.Context, migrator *migrations.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errMigrateUsage
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+	default:
+		return errMigrateUsage
+	}
+
+	return nil
+}
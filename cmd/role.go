@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+)
+
+var errRoleUsage = errors.New("usage: item_store role <username> user | admin | auditor")
+
+// roleActor is recorded in the audit log for roles set from the command
+// line.
+const roleActor = "cli"
+
+// runRole implements the "role" subcommand. The user must have logged in
+// once so the account exists.
+func runRole(ctx context.Context, userService *service.UserService, args []string) error {
+	if len(args) != 2 {
+		return errRoleUsage
+	}
+
+	if err := userService.SetUserRole(ctx, roleActor, args[0], args[1]); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now %s; it takes effect at their next login\n", args[0], args[1])
+	return nil
+}
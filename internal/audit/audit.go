@@ -0,0 +1,71 @@
+// Package audit holds the request metadata recorded with audit events and
+// the hash chain that makes the audit log tamper-evident.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+// Meta describes the request an audited action came from.
+type Meta struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type metaKey struct{}
+
+// WithMeta returns a copy of ctx carrying meta.
+func WithMeta(ctx context.Context, meta Meta) context.Context {
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+// MetaFrom returns the request metadata stored in ctx. Actions that do not
+// come from a request, such as scheduled transfers, have none.
+func MetaFrom(ctx context.Context) Meta {
+	meta, _ := ctx.Value(metaKey{}).(Meta)
+	return meta
+}
+
+// Hash returns the chain hash of event: SHA-256 over event.PrevHash and
+// every recorded field except the id, which is only known after the insert.
+// CreatedAt is hashed at microsecond precision, the finest every storage
+// driver keeps.
+func Hash(event *entities.AuditEvent) string {
+	// Encoding the fields as a JSON array keeps the input unambiguous
+	// whatever characters the fields contain.
+	fields, _ := json.Marshal([]string{
+		event.PrevHash,
+		event.Action,
+		event.Actor,
+		event.Target,
+		event.IP,
+		event.UserAgent,
+		event.RequestID,
+		event.Details,
+		event.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(fields)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that events, in log order, continue the chain ending in
+// prevHash. It returns the index of the first event that does not, or -1
+// if the chain is intact.
+func Verify(prevHash string, events []*entities.AuditEvent) int {
+	for i, event := range events {
+		if event.PrevHash != prevHash || Hash(event) != event.Hash {
+			return i
+		}
+		prevHash = event.Hash
+	}
+
+	return -1
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeta(t *testing.T) {
+	assert.Equal(t, Meta{}, MetaFrom(context.Background()))
+
+	meta := Meta{IP: "10.0.0.1", UserAgent: "curl/8.0", RequestID: "req-1"}
+	assert.Equal(t, meta, MetaFrom(WithMeta(context.Background(), meta)))
+}
+
+func TestHash(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	event := &entities.AuditEvent{Action: entities.AuditCoinsSent, Actor: "alice", Target: "bob", CreatedAt: now}
+
+	hash := Hash(event)
+	assert.Len(t, hash, 64)
+
+	// The id is not hashed and times are compared at microsecond
+	// precision in UTC, as the stores return them.
+	stored := *event
+	stored.ID = 5
+	stored.CreatedAt = now.Truncate(time.Microsecond).In(time.FixedZone("UTC+3", 3*60*60))
+	assert.Equal(t, hash, Hash(&stored))
+
+	// Moving text between fields changes the hash.
+	moved := *event
+	moved.Actor, moved.Target = "alicebob", ""
+	assert.NotEqual(t, hash, Hash(&moved))
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var events []*entities.AuditEvent
+	prevHash := "start"
+	for _, actor := range []string{"alice", "bob", "carol"} {
+		event := &entities.AuditEvent{Action: entities.AuditLoginSucceeded, Actor: actor, CreatedAt: now, PrevHash: prevHash}
+		event.Hash = Hash(event)
+		events = append(events, event)
+		prevHash = event.Hash
+	}
+
+	assert.Equal(t, -1, Verify("start", events))
+	assert.Equal(t, 0, Verify("", events))
+
+	edited := *events[1]
+	edited.Actor = "mallory"
+	assert.Equal(t, 1, Verify("start", []*entities.AuditEvent{events[0], &edited, events[2]}))
+
+	// Rehashing the edited event does not help: the next one still points
+	// at the original.
+	edited.Hash = Hash(&edited)
+	assert.Equal(t, 2, Verify("start", []*entities.AuditEvent{events[0], &edited, events[2]}))
+
+	assert.Equal(t, 1, Verify("start", []*entities.AuditEvent{events[0], events[2]}))
+}
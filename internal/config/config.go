@@ -0,0 +1,406 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/outbox"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+	DriverSQLite   = "sqlite"
+)
+
+type Config struct {
+	Database DatabaseConfig
+	Server   ServerConfig
+	Storage  StorageConfig
+	Lockout  service.LockoutPolicy
+	// RefundWindow is how long users can return a purchase themselves.
+	RefundWindow time.Duration
+	Market       service.MarketPolicy
+	// ListingExpiryInterval is how often expired market listings are
+	// closed and their items returned to the sellers.
+	ListingExpiryInterval time.Duration
+	// ScheduleInterval is how often due scheduled transfers are executed.
+	ScheduleInterval time.Duration
+	// PaymentRequestTTL is how long a payment request waits for an answer
+	// and PaymentRequestExpiryInterval how often expired ones are closed.
+	PaymentRequestTTL            time.Duration
+	PaymentRequestExpiryInterval time.Duration
+	// TransferLimits cap outgoing coin transfers for users without an
+	// admin override. Zero disables a limit.
+	TransferLimits entities.TransferLimits
+	// AutoMigrate applies pending schema migrations on startup.
+	AutoMigrate bool
+	// Roles maps the users named in ADMIN_USERS and AUDITOR_USERS to the
+	// role they get when they log in to an existing account.
+	Roles  map[string]string
+	Outbox OutboxConfig
+	// WebhookInterval is how often due webhook deliveries are sent.
+	WebhookInterval time.Duration
+	// LowBalanceThreshold is the balance below which a spend sends a
+	// balance.low webhook.
+	LowBalanceThreshold int
+	// EventRetention is how long events stay available for resuming an
+	// event stream.
+	EventRetention time.Duration
+	Email          EmailConfig
+}
+
+// EmailConfig configures email to users. Email is off unless SMTPAddr is
+// set.
+type EmailConfig struct {
+	// SMTPAddr is the host:port of the SMTP server emails are sent through.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	// From is the sender address, e.g. "itemStore <noreply@example.com>".
+	From string
+	// Interval is how often queued emails are sent and DigestInterval how
+	// often users in digest mode get a digest.
+	Interval       time.Duration
+	DigestInterval time.Duration
+}
+
+type OutboxConfig struct {
+	// Sinks lists where domain events are relayed: "stdout", "file" and
+	// "webhook". Without sinks no events are written to the outbox.
+	Sinks []string
+	// File is the file the file sink appends to.
+	File string
+	// WebhookURL is the URL the webhook sink posts to.
+	WebhookURL string
+	// Interval is how often the relay looks for undelivered events.
+	Interval time.Duration
+}
+
+type DatabaseConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+}
+
+type ServerConfig struct {
+	Port string
+}
+
+type StorageConfig struct {
+	// Driver selects the service.UserRepo backend: "postgres" (default),
+	// "sqlite" for single node deployments, or "memory", which keeps
+	// everything in process and loses it on exit.
+	Driver string
+	// SQLitePath is the database file used by the sqlite driver.
+	SQLitePath string
+}
+
+// AuditSettings describes the settings that change the service's behaviour,
+// for the audit log. Credentials are left out.
+func (c *Config) AuditSettings() string {
+	settings := []string{
+		"storage.driver=" + c.Storage.Driver,
+		fmt.Sprintf("lockout.maxAttempts=%d", c.Lockout.MaxAttempts),
+		"lockout.baseDelay=" + c.Lockout.BaseDelay.String(),
+		"lockout.maxDelay=" + c.Lockout.MaxDelay.String(),
+		"lockout.duration=" + c.Lockout.LockoutDuration.String(),
+		"lockout.failureWindow=" + c.Lockout.FailureWindow.String(),
+		"refundWindow=" + c.RefundWindow.String(),
+		fmt.Sprintf("market.feePercent=%d", c.Market.FeePercent),
+		"market.listingTTL=" + c.Market.ListingTTL.String(),
+		"paymentRequestTTL=" + c.PaymentRequestTTL.String(),
+		fmt.Sprintf("limits.maxTransfer=%d", c.TransferLimits.MaxTransfer),
+		fmt.Sprintf("limits.dailyAmount=%d", c.TransferLimits.DailyAmount),
+		fmt.Sprintf("limits.weeklyAmount=%d", c.TransferLimits.WeeklyAmount),
+		fmt.Sprintf("limits.dailyRecipients=%d", c.TransferLimits.DailyRecipients),
+		fmt.Sprintf("webhooks.lowBalanceThreshold=%d", c.LowBalanceThreshold),
+		"events.retention=" + c.EventRetention.String(),
+		"email.smtpAddr=" + c.Email.SMTPAddr,
+		"email.from=" + c.Email.From,
+		"email.digestInterval=" + c.Email.DigestInterval.String(),
+		"roles=" + c.rolesSetting(),
+	}
+
+	return strings.Join(settings, " ")
+}
+
+// rolesSetting lists the configured roles as sorted "user:role" pairs.
+func (c *Config) rolesSetting() string {
+	pairs := make([]string, 0, len(c.Roles))
+	for name, role := range c.Roles {
+		pairs = append(pairs, name+":"+role)
+	}
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", d.Host, d.Port, d.User, d.Password, d.Name)
+}
+
+// Load reads the configuration from the environment. Unset optional
+// variables fall back to their defaults.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Database: DatabaseConfig{
+			Host:     os.Getenv("DATABASE_HOST"),
+			Port:     os.Getenv("DATABASE_PORT"),
+			User:     os.Getenv("DATABASE_USER"),
+			Password: os.Getenv("DATABASE_PASSWORD"),
+			Name:     os.Getenv("DATABASE_NAME"),
+		},
+		Server: ServerConfig{
+			Port: os.Getenv("SERVER_PORT"),
+		},
+		Storage: StorageConfig{
+			Driver:     DriverPostgres,
+			SQLitePath: "itemstore.db",
+		},
+		Lockout:                      service.DefaultLockoutPolicy,
+		RefundWindow:                 service.DefaultRefundWindow,
+		Market:                       service.DefaultMarketPolicy,
+		ListingExpiryInterval:        time.Minute,
+		ScheduleInterval:             30 * time.Second,
+		PaymentRequestTTL:            service.DefaultPaymentRequestTTL,
+		PaymentRequestExpiryInterval: time.Minute,
+		TransferLimits:               service.DefaultTransferLimits,
+		WebhookInterval:              10 * time.Second,
+		LowBalanceThreshold:          service.DefaultLowBalanceThreshold,
+		EventRetention:               service.DefaultEventRetention,
+		Outbox: OutboxConfig{
+			File:     "events.jsonl",
+			Interval: 5 * time.Second,
+		},
+		Email: EmailConfig{
+			Interval:       30 * time.Second,
+			DigestInterval: service.DefaultEmailDigestInterval,
+		},
+	}
+
+	if driver := os.Getenv("STORAGE_DRIVER"); driver != "" {
+		cfg.Storage.Driver = driver
+	}
+	if path := os.Getenv("SQLITE_PATH"); path != "" {
+		cfg.Storage.SQLitePath = path
+	}
+	switch cfg.Storage.Driver {
+	case DriverPostgres, DriverMemory, DriverSQLite:
+	default:
+		return nil, fmt.Errorf("config STORAGE_DRIVER: unknown driver %q", cfg.Storage.Driver)
+	}
+
+	var err error
+	if cfg.AutoMigrate, err = getBool("AUTO_MIGRATE", false); err != nil {
+		return nil, err
+	}
+	if cfg.Roles, err = getRoles(); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.MaxAttempts, err = getInt("LOGIN_MAX_ATTEMPTS", cfg.Lockout.MaxAttempts); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.BaseDelay, err = getDuration("LOGIN_BACKOFF_BASE", cfg.Lockout.BaseDelay); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.MaxDelay, err = getDuration("LOGIN_BACKOFF_MAX", cfg.Lockout.MaxDelay); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.LockoutDuration, err = getDuration("LOGIN_LOCKOUT_DURATION", cfg.Lockout.LockoutDuration); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.FailureWindow, err = getDuration("LOGIN_FAILURE_WINDOW", cfg.Lockout.FailureWindow); err != nil {
+		return nil, err
+	}
+
+	if cfg.RefundWindow, err = getDuration("REFUND_WINDOW", cfg.RefundWindow); err != nil {
+		return nil, err
+	}
+
+	if cfg.Market.FeePercent, err = getInt("MARKET_FEE_PERCENT", cfg.Market.FeePercent); err != nil {
+		return nil, err
+	}
+	if cfg.Market.FeePercent < 0 || cfg.Market.FeePercent > 100 {
+		return nil, fmt.Errorf("config MARKET_FEE_PERCENT: must be between 0 and 100")
+	}
+	if cfg.Market.ListingTTL, err = getDuration("LISTING_TTL", cfg.Market.ListingTTL); err != nil {
+		return nil, err
+	}
+	if cfg.ListingExpiryInterval, err = getDuration("LISTING_EXPIRY_INTERVAL", cfg.ListingExpiryInterval); err != nil {
+		return nil, err
+	}
+	if cfg.ListingExpiryInterval <= 0 {
+		return nil, fmt.Errorf("config LISTING_EXPIRY_INTERVAL: must be positive")
+	}
+	if cfg.ScheduleInterval, err = getDuration("SCHEDULE_INTERVAL", cfg.ScheduleInterval); err != nil {
+		return nil, err
+	}
+	if cfg.ScheduleInterval <= 0 {
+		return nil, fmt.Errorf("config SCHEDULE_INTERVAL: must be positive")
+	}
+	if cfg.PaymentRequestTTL, err = getDuration("PAYMENT_REQUEST_TTL", cfg.PaymentRequestTTL); err != nil {
+		return nil, err
+	}
+	if cfg.PaymentRequestTTL <= 0 {
+		return nil, fmt.Errorf("config PAYMENT_REQUEST_TTL: must be positive")
+	}
+	if cfg.PaymentRequestExpiryInterval, err = getDuration("PAYMENT_REQUEST_EXPIRY_INTERVAL", cfg.PaymentRequestExpiryInterval); err != nil {
+		return nil, err
+	}
+	if cfg.PaymentRequestExpiryInterval <= 0 {
+		return nil, fmt.Errorf("config PAYMENT_REQUEST_EXPIRY_INTERVAL: must be positive")
+	}
+
+	limits := []struct {
+		key   string
+		value *int
+	}{
+		{"TRANSFER_MAX_AMOUNT", &cfg.TransferLimits.MaxTransfer},
+		{"TRANSFER_DAILY_LIMIT", &cfg.TransferLimits.DailyAmount},
+		{"TRANSFER_WEEKLY_LIMIT", &cfg.TransferLimits.WeeklyAmount},
+		{"TRANSFER_DAILY_RECIPIENTS", &cfg.TransferLimits.DailyRecipients},
+	}
+	for _, limit := range limits {
+		if *limit.value, err = getInt(limit.key, *limit.value); err != nil {
+			return nil, err
+		}
+		if *limit.value < 0 {
+			return nil, fmt.Errorf("config %s: must not be negative", limit.key)
+		}
+	}
+
+	if cfg.WebhookInterval, err = getDuration("WEBHOOK_INTERVAL", cfg.WebhookInterval); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookInterval <= 0 {
+		return nil, fmt.Errorf("config WEBHOOK_INTERVAL: must be positive")
+	}
+	if cfg.LowBalanceThreshold, err = getInt("LOW_BALANCE_THRESHOLD", cfg.LowBalanceThreshold); err != nil {
+		return nil, err
+	}
+	if cfg.EventRetention, err = getDuration("EVENT_RETENTION", cfg.EventRetention); err != nil {
+		return nil, err
+	}
+	if cfg.EventRetention <= 0 {
+		return nil, fmt.Errorf("config EVENT_RETENTION: must be positive")
+	}
+
+	for _, sink := range strings.Split(os.Getenv("OUTBOX_SINKS"), ",") {
+		switch sink = strings.TrimSpace(sink); sink {
+		case "":
+			continue
+		case outbox.SinkStdout, outbox.SinkFile, outbox.SinkWebhook:
+		default:
+			return nil, fmt.Errorf("config OUTBOX_SINKS: unknown sink %q", sink)
+		}
+		cfg.Outbox.Sinks = append(cfg.Outbox.Sinks, sink)
+	}
+	if path := os.Getenv("OUTBOX_FILE"); path != "" {
+		cfg.Outbox.File = path
+	}
+	cfg.Outbox.WebhookURL = os.Getenv("OUTBOX_WEBHOOK_URL")
+	if slices.Contains(cfg.Outbox.Sinks, outbox.SinkWebhook) && cfg.Outbox.WebhookURL == "" {
+		return nil, fmt.Errorf("config OUTBOX_WEBHOOK_URL: required by the webhook sink")
+	}
+	if cfg.Outbox.Interval, err = getDuration("OUTBOX_INTERVAL", cfg.Outbox.Interval); err != nil {
+		return nil, err
+	}
+	if cfg.Outbox.Interval <= 0 {
+		return nil, fmt.Errorf("config OUTBOX_INTERVAL: must be positive")
+	}
+
+	cfg.Email.SMTPAddr = os.Getenv("SMTP_ADDR")
+	cfg.Email.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.Email.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	cfg.Email.From = os.Getenv("EMAIL_FROM")
+	if cfg.Email.SMTPAddr != "" && cfg.Email.From == "" {
+		return nil, fmt.Errorf("config EMAIL_FROM: required by SMTP_ADDR")
+	}
+	if cfg.Email.Interval, err = getDuration("EMAIL_INTERVAL", cfg.Email.Interval); err != nil {
+		return nil, err
+	}
+	if cfg.Email.Interval <= 0 {
+		return nil, fmt.Errorf("config EMAIL_INTERVAL: must be positive")
+	}
+	if cfg.Email.DigestInterval, err = getDuration("EMAIL_DIGEST_INTERVAL", cfg.Email.DigestInterval); err != nil {
+		return nil, err
+	}
+	if cfg.Email.DigestInterval <= 0 {
+		return nil, fmt.Errorf("config EMAIL_DIGEST_INTERVAL: must be positive")
+	}
+
+	return cfg, nil
+}
+
+func getInt(key string, def int) (int, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("config %s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+func getDuration(key string, def time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("config %s: %w", key, err)
+	}
+
+	return d, nil
+}
+
+func getBool(key string, def bool) (bool, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("config %s: %w", key, err)
+	}
+
+	return b, nil
+}
+
+// getRoles reads the comma separated usernames in ADMIN_USERS and
+// AUDITOR_USERS. A user may be listed in only one of them.
+func getRoles() (map[string]string, error) {
+	roles := map[string]string{}
+	for _, list := range []struct{ key, role string }{
+		{"ADMIN_USERS", entities.RoleAdmin},
+		{"AUDITOR_USERS", entities.RoleAuditor},
+	} {
+		for _, name := range strings.Split(os.Getenv(list.key), ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if role, ok := roles[name]; ok && role != list.role {
+				return nil, fmt.Errorf("config %s: %s is already listed as %s", list.key, name, role)
+			}
+			roles[name] = list.role
+		}
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,187 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+const DefaultTimeout = 30 * time.Second
+
+var ErrBadHeader = errors.New("email header contains a line break")
+
+// SMTPSender sends emails through an SMTP server. It upgrades to TLS when
+// the server offers STARTTLS and logs in with PLAIN auth when Username is
+// set; net/smtp refuses to send a password over an unencrypted connection
+// to anything but localhost.
+type SMTPSender struct {
+	// Addr is the server's host:port.
+	Addr     string
+	From     string
+	Username string
+	Password string
+	// Timeout bounds a whole send, from dialing to QUIT.
+	Timeout time.Duration
+	// TLSConfig is used for STARTTLS; nil verifies the server's host name.
+	TLSConfig *tls.Config
+	Now       func() time.Time
+}
+
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	return &SMTPSender{
+		Addr:     addr,
+		From:     from,
+		Username: username,
+		Password: password,
+		Timeout:  DefaultTimeout,
+		Now:      time.Now,
+	}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, email *entities.Email) error {
+	message, err := Message(s.From, email, s.Now())
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp address: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := s.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(s.From)); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := client.Rcpt(email.To); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// envelopeAddress returns the bare address of from, which may carry a
+// display name.
+func envelopeAddress(from string) string {
+	if address, err := mail.ParseAddress(from); err == nil {
+		return address.Address
+	}
+
+	return from
+}
+
+// Message builds the multipart/alternative message for email, with a
+// quoted-printable plain text part and, if email has one, an HTML part.
+func Message(from string, email *entities.Email, date time.Time) ([]byte, error) {
+	for _, value := range []string{from, email.To, email.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, ErrBadHeader
+		}
+	}
+
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", email.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID(from))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", body.Boundary())
+
+	parts := []struct{ contentType, content string }{{"text/plain", email.Text}}
+	if email.HTML != "" {
+		parts = append(parts, struct{ contentType, content string }{"text/html", email.HTML})
+	}
+	for _, part := range parts {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if _, host, ok := strings.Cut(envelopeAddress(from), "@"); ok {
+		domain = host
+	}
+	random := make([]byte, 16)
+	rand.Read(random)
+
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
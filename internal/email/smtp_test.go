@@ -0,0 +1,202 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP is an SMTP server that accepts every message and keeps it.
+type fakeSMTP struct {
+	listener net.Listener
+	// auth makes the server advertise AUTH PLAIN; rejectRcpt makes it
+	// refuse every recipient.
+	auth       bool
+	rejectRcpt bool
+
+	mu       sync.Mutex
+	from     string
+	rcpt     []string
+	login    string
+	messages [][]byte
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTP{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	return s
+}
+
+func (s *fakeSMTP) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 fake ESMTP")
+
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		s.mu.Lock()
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.auth {
+				c.PrintfLine("250-fake")
+				c.PrintfLine("250 AUTH PLAIN")
+			} else {
+				c.PrintfLine("250 fake")
+			}
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			s.login = string(decoded)
+			c.PrintfLine("235 ok")
+		case "MAIL":
+			s.from = arg
+			c.PrintfLine("250 ok")
+		case "RCPT":
+			if s.rejectRcpt {
+				c.PrintfLine("550 no such user")
+				break
+			}
+			s.rcpt = append(s.rcpt, arg)
+			c.PrintfLine("250 ok")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			s.mu.Unlock()
+			message, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	email := &entities.Email{
+		ID:      3,
+		To:      "bob@example.com",
+		Subject: "Grüße from itemStore",
+		Text:    "alice sent you 50 coins.\n",
+		HTML:    "<p>alice sent you 50 coins.</p>",
+	}
+
+	t.Run("sent", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		sender := NewSMTPSender(server.Addr(), "itemStore <noreply@example.com>", "", "")
+
+		require.NoError(t, sender.Send(context.Background(), email))
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		assert.Equal(t, "FROM:<noreply@example.com>", server.from)
+		assert.Equal(t, []string{"TO:<bob@example.com>"}, server.rcpt)
+		require.Len(t, server.messages, 1)
+
+		message, err := mail.ReadMessage(bytes.NewReader(server.messages[0]))
+		require.NoError(t, err)
+		subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+		require.NoError(t, err)
+		assert.Equal(t, email.Subject, subject)
+		assert.Equal(t, "bob@example.com", message.Header.Get("To"))
+		assert.Contains(t, message.Header.Get("Message-ID"), "@example.com>")
+
+		mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		assert.Equal(t, "multipart/alternative", mediaType)
+		parts := multipart.NewReader(message.Body, params["boundary"])
+		for _, want := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", email.Text},
+			{"text/html; charset=utf-8", email.HTML},
+		} {
+			part, err := parts.NextRawPart()
+			require.NoError(t, err)
+			assert.Equal(t, want.contentType, part.Header.Get("Content-Type"))
+			body, err := io.ReadAll(quotedprintable.NewReader(part))
+			require.NoError(t, err)
+			assert.Equal(t, want.body, string(body))
+		}
+	})
+
+	t.Run("auth", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		server.auth = true
+		sender := NewSMTPSender(server.Addr(), "noreply@example.com", "mailer", "secret")
+
+		require.NoError(t, sender.Send(context.Background(), email))
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		assert.Equal(t, "\x00mailer\x00secret", server.login)
+		assert.Len(t, server.messages, 1)
+	})
+
+	t.Run("recipient rejected", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		server.rejectRcpt = true
+		sender := NewSMTPSender(server.Addr(), "noreply@example.com", "", "")
+
+		err := sender.Send(context.Background(), email)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "550")
+	})
+
+	t.Run("server down", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		addr := server.Addr()
+		server.listener.Close()
+		sender := NewSMTPSender(addr, "noreply@example.com", "", "")
+		sender.Timeout = time.Second
+
+		assert.Error(t, sender.Send(context.Background(), email))
+	})
+
+	t.Run("header injection", func(t *testing.T) {
+		_, err := Message("noreply@example.com", &entities.Email{To: "bob@example.com\r\nBcc: eve@example.com"}, time.Now())
+		assert.ErrorIs(t, err, ErrBadHeader)
+	})
+}
@@ -0,0 +1,77 @@
+// Package email renders and sends the emails users get about their coins
+// and purchases.
+//
+// Every email has a plain text and an HTML body. The template named after
+// an email is the pair templates/<name>.txt, which defines "subject" and
+// "body", and templates/<name>.html, which defines the "content" of the
+// shared HTML layout.
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+// Names lists the templates New parses: one per notification kind that is
+// emailed, and the digest.
+var Names = append(append([]string(nil), entities.EmailKinds...), "digest")
+
+var ErrNoTemplate = errors.New("no such email template")
+
+//go:embed templates
+var files embed.FS
+
+// Templates renders emails from the embedded templates.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func New() (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template, len(Names)),
+		html: make(map[string]*htmltemplate.Template, len(Names)),
+	}
+	for _, name := range Names {
+		text, err := texttemplate.ParseFS(files, "templates/"+name+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("parse %s text template: %w", name, err)
+		}
+		html, err := htmltemplate.ParseFS(files, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("parse %s html template: %w", name, err)
+		}
+		t.text[name] = text
+		t.html[name] = html
+	}
+
+	return t, nil
+}
+
+func (t *Templates) Render(name string, data *entities.EmailData) (string, string, string, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return "", "", "", fmt.Errorf("%s: %w", name, ErrNoTemplate)
+	}
+
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	if err := text.ExecuteTemplate(&body, "body", data); err != nil {
+		return "", "", "", err
+	}
+	if err := t.html[name].ExecuteTemplate(&html, "layout", data); err != nil {
+		return "", "", "", err
+	}
+
+	// A subject is a single header line.
+	return strings.Join(strings.Fields(subject.String()), " "), body.String(), html.String(), nil
+}
@@ -0,0 +1,61 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := New()
+	require.NoError(t, err)
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("every kind", func(t *testing.T) {
+		for _, kind := range entities.EmailKinds {
+			subject, text, html, err := templates.Render(kind, &entities.EmailData{
+				Username:     "bob",
+				Notification: &entities.Notification{Kind: kind, Text: "something happened", CreatedAt: now},
+			})
+			require.NoError(t, err, kind)
+			assert.NotEmpty(t, subject, kind)
+			assert.Contains(t, text, "Hi bob,", kind)
+			assert.Contains(t, text, "something happened", kind)
+			assert.Contains(t, html, "<p>Hi bob,</p>", kind)
+		}
+	})
+
+	t.Run("html is escaped", func(t *testing.T) {
+		_, text, html, err := templates.Render(entities.NotificationCoinsReceived, &entities.EmailData{
+			Username:     "bob",
+			Notification: &entities.Notification{Text: "alice sent you 5 coins: <script>x</script>", CreatedAt: now},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, text, "<script>x</script>")
+		assert.NotContains(t, html, "<script>")
+		assert.Contains(t, html, "&lt;script&gt;")
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		subject, text, html, err := templates.Render("digest", &entities.EmailData{
+			Username: "bob",
+			Notifications: []*entities.Notification{
+				{Text: "alice sent you 5 coins", CreatedAt: now},
+				{Text: "alice sent you a cup", CreatedAt: now.Add(time.Hour)},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Your itemStore digest: 2 new", subject)
+		assert.Contains(t, text, "- 2025-03-01 12:00 alice sent you 5 coins\n- 2025-03-01 13:00 alice sent you a cup\n")
+		assert.Contains(t, html, "<li>2025-03-01 13:00 alice sent you a cup</li>")
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, _, _, err := templates.Render("spam", &entities.EmailData{})
+		assert.ErrorIs(t, err, ErrNoTemplate)
+	})
+}
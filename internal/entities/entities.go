@@ -1,12 +1,28 @@
 package entities
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleAuditor may read the audit log and nothing else beyond what
+	// users can do.
+	RoleAuditor = "auditor"
+)
+
 type User struct {
 	ID          int    `json:"id"`
 	Username    string `json:"username"`
 	Password    string
+	Role        string `json:"role"`
 	Coins       int
 	Inventory   []*Item
 	CoinHistory CoinHistory
+	// Registered is set by Auth when the call created the user.
+	Registered bool `json:"-"`
 }
 
 type Item struct {
@@ -14,10 +30,18 @@ type Item struct {
 	Quantity int    `json:"quantity"`
 }
 
+// CatalogItem is an item the shop sells and its price in coins.
+type CatalogItem struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
 type InfoResponse struct {
 	Coins       int         `json:"coins"`
 	Inventory   []*Item     `json:"inventory"`
 	CoinHistory CoinHistory `json:"coinHistory"`
+	GiftHistory GiftHistory `json:"giftHistory"`
+	ItemHistory ItemHistory `json:"itemHistory"`
 }
 
 type ErrorResponse struct {
@@ -27,14 +51,745 @@ type ErrorResponse struct {
 type CoinHistory struct {
 	Received []*ReceiveOperation `json:"received"`
 	Sent     []*SentOperation    `json:"sent"`
+	Refunds  []*RefundOperation  `json:"refunds"`
+}
+
+type GiftHistory struct {
+	Received []*ReceivedGift `json:"received"`
+	Sent     []*SentGift     `json:"sent"`
+}
+
+type ItemHistory struct {
+	Received []*ReceivedItemOperation `json:"received"`
+	Sent     []*SentItemOperation     `json:"sent"`
+}
+
+type SentItemOperation struct {
+	ToUser   string `json:"toUser"`
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+type ReceivedItemOperation struct {
+	FromUser string `json:"fromUser"`
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+type SentGift struct {
+	ToUser  string `json:"toUser"`
+	Item    string `json:"item"`
+	Message string `json:"message,omitempty"`
+}
+
+type ReceivedGift struct {
+	FromUser string `json:"fromUser"`
+	Item     string `json:"item"`
+	Message  string `json:"message,omitempty"`
 }
 
 type SentOperation struct {
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+type ReceiveOperation struct {
+	FromUser string `json:"fromUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+const (
+	TransferCategoryThanks        = "thanks"
+	TransferCategoryBet           = "bet"
+	TransferCategoryReimbursement = "reimbursement"
+	TransferCategoryGift          = "gift"
+	TransferCategoryOther         = "other"
+)
+
+// TransferCategories lists the reasons a coin transfer can be tagged with.
+var TransferCategories = []string{
+	TransferCategoryThanks,
+	TransferCategoryBet,
+	TransferCategoryReimbursement,
+	TransferCategoryGift,
+	TransferCategoryOther,
+}
+
+const (
+	TransferDirectionSent     = "sent"
+	TransferDirectionReceived = "received"
+)
+
+// CoinTransfer is one coin transfer as listed by the history search.
+// CreatedAt is nil for transfers recorded before times were kept.
+type CoinTransfer struct {
+	ID        int        `json:"id"`
+	FromUser  string     `json:"fromUser"`
+	ToUser    string     `json:"toUser"`
+	Amount    int        `json:"amount"`
+	Memo      string     `json:"memo,omitempty"`
+	Category  string     `json:"category"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// TransferFilter narrows the history search. Direction is one of the
+// TransferDirection* constants or empty for both; Query matches memos and
+// the other party's username.
+type TransferFilter struct {
+	Direction string
+	Category  string
+	Query     string
+}
+
+type TransfersPage struct {
+	Transfers []*CoinTransfer `json:"transfers"`
+	Total     int             `json:"total"`
+	Limit     int             `json:"limit"`
+	Offset    int             `json:"offset"`
+}
+
+// RefundOperation is a refund as shown in the coin history. RefundedBy is
+// set when an admin forced the refund.
+type RefundOperation struct {
+	Item       string `json:"item"`
+	Quantity   int    `json:"quantity"`
+	Amount     int    `json:"amount"`
+	RefundedBy string `json:"refundedBy,omitempty"`
+}
+
+// Order is a single purchase together with how many of its units have
+// already been refunded. Total is what was paid for all units. GiftTo and
+// Message are set when the order was bought as a gift.
+type Order struct {
+	ID        int       `json:"id"`
+	ItemType  string    `json:"item"`
+	Quantity  int       `json:"quantity"`
+	UnitPrice int       `json:"unitPrice"`
+	Total     int       `json:"total"`
+	Refunded  int       `json:"refunded"`
+	GiftTo    string    `json:"giftTo,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type OrdersPage struct {
+	Orders []*Order `json:"orders"`
+	Total  int      `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
+
+// Refund returns Quantity units of one order. Amount is credited back to
+// the user and Actor is the admin who forced it, empty for the user.
+type Refund struct {
+	OrderID  int
+	UserID   int
+	ItemID   int
+	Quantity int
+	Amount   int
+	Actor    string
+}
+
+const (
+	ListingOpen      = "open"
+	ListingSold      = "sold"
+	ListingCancelled = "cancelled"
+	ListingExpired   = "expired"
+)
+
+// Listing offers Quantity units of an item for Price coins in total. The
+// units stay in escrow until the listing is sold, cancelled or expires.
+type Listing struct {
+	ID        int       `json:"id"`
+	SellerID  int       `json:"-"`
+	Seller    string    `json:"seller"`
+	ItemType  string    `json:"item"`
+	Quantity  int       `json:"quantity"`
+	Price     int       `json:"price"`
+	Status    string    `json:"status"`
+	Buyer     string    `json:"buyer,omitempty"`
+	Fee       int       `json:"fee,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type ListingsPage struct {
+	Listings []*Listing `json:"listings"`
+	Total    int        `json:"total"`
+	Limit    int        `json:"limit"`
+	Offset   int        `json:"offset"`
+}
+
+// BatchTransfer is one recipient of a batch coin transfer.
+type BatchTransfer struct {
 	ToUser string `json:"toUser"`
 	Amount int    `json:"amount"`
 }
 
-type ReceiveOperation struct {
+// BatchSendResult reports a completed batch coin transfer: what each
+// recipient got, the total sent and the sender's balance afterwards.
+type BatchSendResult struct {
+	Transfers []BatchTransfer `json:"transfers"`
+	Total     int             `json:"total"`
+	Balance   int             `json:"balance"`
+}
+
+const (
+	RecurrenceOnce    = "once"
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+)
+
+var Recurrences = []string{RecurrenceOnce, RecurrenceWeekly, RecurrenceMonthly}
+
+const (
+	ScheduleActive    = "active"
+	ScheduleCompleted = "completed"
+	ScheduleCancelled = "cancelled"
+)
+
+const (
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+)
+
+// ScheduleRequest describes a scheduled transfer to create or the new
+// settings of an existing one.
+type ScheduleRequest struct {
+	ToUser     string    `json:"toUser"`
+	Amount     int       `json:"amount"`
+	Memo       string    `json:"memo"`
+	Category   string    `json:"category"`
+	Recurrence string    `json:"recurrence"`
+	RunAt      time.Time `json:"runAt"`
+}
+
+// ScheduledTransfer sends Amount coins from FromUser to ToUser at NextRunAt,
+// and again every week or month after that unless Recurrence is once.
+type ScheduledTransfer struct {
+	ID         int                     `json:"id"`
+	FromUserID int                     `json:"-"`
+	FromUser   string                  `json:"fromUser"`
+	ToUserID   int                     `json:"-"`
+	ToUser     string                  `json:"toUser"`
+	Amount     int                     `json:"amount"`
+	Memo       string                  `json:"memo,omitempty"`
+	Category   string                  `json:"category"`
+	Recurrence string                  `json:"recurrence"`
+	NextRunAt  time.Time               `json:"nextRunAt"`
+	Status     string                  `json:"status"`
+	CreatedAt  time.Time               `json:"createdAt"`
+	Runs       []*ScheduledTransferRun `json:"runs,omitempty"`
+
+	// AnchorDay is the day of the month, in UTC, that monthly runs fall on
+	// when the month has it. Zero means NextRunAt's day.
+	AnchorDay int `json:"-"`
+}
+
+// ScheduledTransferRun records one execution of a scheduled transfer. Error
+// says why a failed run did not send the coins.
+type ScheduledTransferRun struct {
+	ID     int       `json:"id"`
+	RunAt  time.Time `json:"runAt"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+const (
+	PaymentRequestPending   = "pending"
+	PaymentRequestAccepted  = "accepted"
+	PaymentRequestDeclined  = "declined"
+	PaymentRequestCancelled = "cancelled"
+	PaymentRequestExpired   = "expired"
+)
+
+var PaymentRequestStatuses = []string{
+	PaymentRequestPending,
+	PaymentRequestAccepted,
+	PaymentRequestDeclined,
+	PaymentRequestCancelled,
+	PaymentRequestExpired,
+}
+
+const (
+	// PaymentRequestIncoming selects requests the user was asked to pay.
+	PaymentRequestIncoming = "incoming"
+	// PaymentRequestOutgoing selects requests the user made.
+	PaymentRequestOutgoing = "outgoing"
+)
+
+// PaymentRequest asks Payer to send Amount coins to Requester.
+type PaymentRequest struct {
+	ID          int        `json:"id"`
+	RequesterID int        `json:"-"`
+	Requester   string     `json:"requester"`
+	PayerID     int        `json:"-"`
+	Payer       string     `json:"payer"`
+	Amount      int        `json:"amount"`
+	Memo        string     `json:"memo,omitempty"`
+	Category    string     `json:"category"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	ClosedAt    *time.Time `json:"closedAt,omitempty"`
+}
+
+// PaymentRequestFilter narrows a user's payment requests. Empty fields match
+// everything.
+type PaymentRequestFilter struct {
+	Direction string
+	Status    string
+}
+
+type PaymentRequestsPage struct {
+	Requests []*PaymentRequest `json:"requests"`
+	Total    int               `json:"total"`
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
+}
+
+// Transfer limit names, reported by utils.TransferLimitError.
+const (
+	LimitMaxTransfer     = "max_transfer"
+	LimitDailyAmount     = "daily_amount"
+	LimitWeeklyAmount    = "weekly_amount"
+	LimitDailyRecipients = "daily_recipients"
+)
+
+// TransferLimits cap a user's outgoing coin transfers: the size of a single
+// transfer, the coins sent in the last 24 hours and 7 days, and the number
+// of distinct recipients in the last 24 hours. Zero disables a limit.
+type TransferLimits struct {
+	MaxTransfer     int `json:"maxTransfer"`
+	DailyAmount     int `json:"dailyAmount"`
+	WeeklyAmount    int `json:"weeklyAmount"`
+	DailyRecipients int `json:"dailyRecipients"`
+}
+
+// UserTransferLimits are the limits applied to a user: an admin override
+// if Override is set, the configured defaults otherwise.
+type UserTransferLimits struct {
+	Username string `json:"username"`
+	TransferLimits
+	Override  bool       `json:"override"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// TransferUsage sums a user's outgoing transfers since some time.
+// Recipients counts distinct recipients other than the one a pending
+// transfer goes to.
+type TransferUsage struct {
+	Amount     int
+	Recipients int
+}
+
+const (
+	AdjustmentGrant    = "grant"
+	AdjustmentClawback = "clawback"
+	AdjustmentSet      = "set"
+)
+
+// BalanceAdjustment records an admin changing a user's balance. Amount is
+// the signed change and Balance the user's balance afterwards.
+type BalanceAdjustment struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Username  string    `json:"username"`
+	Actor     string    `json:"actor"`
+	Kind      string    `json:"kind"`
+	Amount    int       `json:"amount"`
+	Balance   int       `json:"balance"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type BalanceAdjustmentsPage struct {
+	Adjustments []*BalanceAdjustment `json:"adjustments"`
+	Total       int                  `json:"total"`
+	Limit       int                  `json:"limit"`
+	Offset      int                  `json:"offset"`
+}
+
+type LoginAttempts struct {
+	Username     string
+	FailedCount  int
+	LastFailedAt time.Time
+	BlockedUntil time.Time
+}
+
+const (
+	LockoutActionLocked   = "locked"
+	LockoutActionUnlocked = "unlocked"
+)
+
+type LockoutEvent struct {
+	Username    string
+	Action      string
+	Actor       string
+	FailedCount int
+	LockedUntil time.Time
+}
+
+const (
+	NotificationGiftReceived      = "gift_received"
+	NotificationListingSold       = "listing_sold"
+	NotificationScheduleFailed    = "schedule_failed"
+	NotificationPaymentRequest    = "payment_request"
+	NotificationBalanceAdjusted   = "balance_adjusted"
+	NotificationCoinsReceived     = "coins_received"
+	NotificationPurchaseCompleted = "purchase_completed"
+	NotificationItemRefunded      = "item_refunded"
+	NotificationItemReceived      = "item_received"
+)
+
+var NotificationKinds = []string{
+	NotificationGiftReceived,
+	NotificationListingSold,
+	NotificationScheduleFailed,
+	NotificationPaymentRequest,
+	NotificationBalanceAdjusted,
+	NotificationCoinsReceived,
+	NotificationPurchaseCompleted,
+	NotificationItemRefunded,
+	NotificationItemReceived,
+}
+
+// Notification tells Recipient that something happened to their account.
+// Kind is one of the Notification* constants. ID, CreatedAt and ReadAt are
+// set once the notification is in the recipient's inbox.
+type Notification struct {
+	ID        int        `json:"id"`
+	Recipient string     `json:"-"`
+	Kind      string     `json:"kind"`
+	Text      string     `json:"text"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+type NotificationsPage struct {
+	Notifications []*Notification `json:"notifications"`
+	// Unread counts all of the user's unread notifications, not only
+	// those on the page.
+	Unread int `json:"unread"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// NotificationPreferences says, per notification kind, whether the user
+// wants notifications of that kind in their inbox.
+type NotificationPreferences map[string]bool
+
+// MarkReadResponse reports how many notifications were marked read.
+type MarkReadResponse struct {
+	Marked int `json:"marked"`
+}
+
+// Email modes: EmailModeOff sends no email, EmailModeInstant sends each
+// receipt and coin-received alert as it happens and EmailModeDigest sends
+// all notifications in one daily email.
+const (
+	EmailModeOff     = "off"
+	EmailModeInstant = "instant"
+	EmailModeDigest  = "digest"
+)
+
+var EmailModes = []string{EmailModeOff, EmailModeInstant, EmailModeDigest}
+
+// EmailKinds are the notification kinds emailed in EmailModeInstant.
+var EmailKinds = []string{
+	NotificationCoinsReceived,
+	NotificationPurchaseCompleted,
+	NotificationItemRefunded,
+}
+
+// EmailSettings says where and how a user wants to be emailed.
+// DigestSentAt is when the user's last digest went out, or when they
+// switched to EmailModeDigest.
+type EmailSettings struct {
+	Address      string    `json:"address"`
+	Mode         string    `json:"mode"`
+	DigestSentAt time.Time `json:"-"`
+}
+
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailDead    = "dead"
+)
+
+// Email is a message in the outgoing email queue.
+type Email struct {
+	ID            int
+	UserID        int
+	To            string
+	Subject       string
+	Text          string
+	HTML          string
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
+
+// EmailDigest is a user due a digest of the notifications they got since
+// Since.
+type EmailDigest struct {
+	UserID   int
+	Username string
+	Address  string
+	Since    time.Time
+}
+
+// EmailData is what email templates are executed with: Notification for a
+// single notification, Notifications for a digest.
+type EmailData struct {
+	Username      string
+	Notification  *Notification
+	Notifications []*Notification
+}
+
+const (
+	AuditLoginSucceeded  = "auth.login_succeeded"
+	AuditLoginFailed     = "auth.login_failed"
+	AuditRegistered      = "auth.registered"
+	AuditCoinsSent       = "coins.sent"
+	AuditCoinsBatchSent  = "coins.batch_sent"
+	AuditItemBought      = "item.bought"
+	AuditItemGifted      = "item.gifted"
+	AuditItemTransferred = "item.transferred"
+	AuditItemRefunded    = "item.refunded"
+	AuditListingBought   = "listing.bought"
+	AuditUserUnlocked    = "admin.user_unlocked"
+	AuditLimitsSet       = "admin.limits_set"
+	AuditLimitsCleared   = "admin.limits_cleared"
+	AuditCoinsGranted    = "admin.coins_granted"
+	AuditCoinsClawedBack = "admin.coins_clawed_back"
+	AuditBalanceSet      = "admin.balance_set"
+	AuditItemPriced      = "admin.item_priced"
+	AuditRoleChanged     = "admin.role_changed"
+	AuditConfigChanged   = "config.changed"
+	AuditLogExported     = "audit.exported"
+)
+
+// AuditEvent is one entry of the append-only audit log. Hash covers the
+// event and PrevHash, the Hash of the entry before it, so editing or
+// removing an entry breaks the chain from that point on.
+type AuditEvent struct {
+	ID        int       `json:"id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Target    string    `json:"target,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	PrevHash  string    `json:"prevHash"`
+	Hash      string    `json:"hash"`
+}
+
+// AuditFilter narrows down audit log queries. Empty fields and zero times
+// match everything; Since is inclusive and Until exclusive.
+type AuditFilter struct {
+	Action string
+	Actor  string
+	Target string
+	Since  time.Time
+	Until  time.Time
+}
+
+// AuditEventsPage is a page of the audit log in log order. After is the id
+// the page starts after; pass the last event's id to get the next page.
+type AuditEventsPage struct {
+	Events []*AuditEvent `json:"events"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	After  int           `json:"after"`
+}
+
+// AuditVerification is the result of checking the audit log's hash chain.
+// BrokenAt is the id of the first event that does not fit the chain.
+type AuditVerification struct {
+	Valid    bool `json:"valid"`
+	Events   int  `json:"events"`
+	BrokenAt int  `json:"brokenAt,omitempty"`
+}
+
+const (
+	EventCoinsSent       = "CoinsSent"
+	EventItemPurchased   = "ItemPurchased"
+	EventItemTransferred = "ItemTransferred"
+	EventUserRegistered  = "UserRegistered"
+)
+
+// OutboxEvent is a domain event stored in the outbox in the transaction
+// that made the change it describes, then relayed to the event sinks.
+// Key is the user the event belongs to; events with the same key are
+// delivered in order. Only the exported JSON fields are sent to sinks.
+type OutboxEvent struct {
+	ID            int             `json:"id"`
+	Type          string          `json:"type"`
+	Key           string          `json:"key"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Attempts      int             `json:"-"`
+	LastError     string          `json:"-"`
+	NextAttemptAt time.Time       `json:"-"`
+}
+
+// CoinsSentEvent is the payload of EventCoinsSent.
+type CoinsSentEvent struct {
 	FromUser string `json:"fromUser"`
+	ToUser   string `json:"toUser"`
 	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Category string `json:"category"`
+}
+
+// ItemPurchasedEvent is the payload of EventItemPurchased. Recipient is set
+// for gifts and ListingID for purchases on the market.
+type ItemPurchasedEvent struct {
+	User      string `json:"user"`
+	Item      string `json:"item"`
+	Quantity  int    `json:"quantity"`
+	Recipient string `json:"recipient,omitempty"`
+	ListingID int    `json:"listingId,omitempty"`
+}
+
+// ItemTransferredEvent is the payload of EventItemTransferred.
+type ItemTransferredEvent struct {
+	FromUser string `json:"fromUser"`
+	ToUser   string `json:"toUser"`
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+// UserRegisteredEvent is the payload of EventUserRegistered.
+type UserRegisteredEvent struct {
+	Username string `json:"username"`
+}
+
+// Webhook event types an endpoint can subscribe to.
+const (
+	WebhookCoinReceived  = "coin.received"
+	WebhookItemPurchased = "item.purchased"
+	WebhookItemReceived  = "item.received"
+	WebhookLowBalance    = "balance.low"
+	// WebhookTest is only sent when the user asks for a test event.
+	WebhookTest = "webhook.test"
+)
+
+var WebhookEventTypes = []string{
+	WebhookCoinReceived,
+	WebhookItemPurchased,
+	WebhookItemReceived,
+	WebhookLowBalance,
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead marks a delivery that ran out of retries; the
+	// dead deliveries form the endpoint's dead-letter list.
+	WebhookDeliveryDead = "dead"
+)
+
+var WebhookDeliveryStatuses = []string{
+	WebhookDeliveryPending,
+	WebhookDeliveryDelivered,
+	WebhookDeliveryDead,
+}
+
+// WebhookEndpoint is a URL a user registered to receive the events in
+// EventTypes. Secret signs every delivery; it is only shown when the
+// endpoint is created.
+type WebhookEndpoint struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type WebhookEndpointRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+// WebhookDelivery is one event queued for one endpoint. Log lists its
+// attempts, oldest first, when the delivery is read on its own.
+type WebhookDelivery struct {
+	ID            int               `json:"id"`
+	EndpointID    int               `json:"endpointId"`
+	EventType     string            `json:"eventType"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	Attempts      int               `json:"attempts"`
+	LastError     string            `json:"lastError,omitempty"`
+	NextAttemptAt time.Time         `json:"nextAttemptAt"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	DeliveredAt   *time.Time        `json:"deliveredAt,omitempty"`
+	Log           []*WebhookAttempt `json:"log,omitempty"`
+}
+
+// WebhookAttempt records one request made for a delivery. StatusCode is
+// zero when no response was received.
+type WebhookAttempt struct {
+	ID          int       `json:"id"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int       `json:"durationMs"`
+}
+
+type WebhookDeliveriesPage struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	Total      int                `json:"total"`
+	Limit      int                `json:"limit"`
+	Offset     int                `json:"offset"`
+}
+
+// LowBalanceEvent is the payload of WebhookLowBalance, sent when a spend
+// takes the user's balance below Threshold.
+type LowBalanceEvent struct {
+	User      string `json:"user"`
+	Balance   int    `json:"balance"`
+	Threshold int    `json:"threshold"`
+}
+
+// WebhookTestEvent is the payload of WebhookTest.
+type WebhookTestEvent struct {
+	Message string `json:"message"`
+}
+
+// Events pushed to connected users over GET /api/events.
+const (
+	StreamCoinReceived      = "coin.received"
+	StreamItemReceived      = "item.received"
+	StreamPurchaseCompleted = "purchase.completed"
+	StreamBalanceChanged    = "balance.changed"
+)
+
+// UserEvent is an event in a user's event stream. Ids only grow, so a
+// client that reconnects resumes after the last id it saw.
+type UserEvent struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"-"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// BalanceChangedEvent is the payload of StreamBalanceChanged.
+type BalanceChangedEvent struct {
+	Balance int `json:"balance"`
 }
@@ -0,0 +1,89 @@
+// Package events wakes the event streams of users connected to
+// GET /api/events when events are added for them. Hub does it within one
+// process; PGHub fans the wake-ups out to every replica through Postgres
+// LISTEN/NOTIFY.
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Hub implements service.EventHub for a single process.
+//
+// Notify wakes subscribers right away, before the transaction that added
+// the event commits. That is safe with the memory and SQLite storage: the
+// memory repository holds its lock and the SQLite pool its only connection
+// until the commit, so a subscriber's read waits for it.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[int]map[chan struct{}]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[int]map[chan struct{}]struct{}),
+	}
+}
+
+func (h *Hub) Notify(ctx context.Context, userID int) error {
+	h.Wake(userID)
+	return nil
+}
+
+// Subscribe returns a channel woken by Wake(userID). Wake-ups that arrive
+// while one is pending are merged, so a subscriber reads everything new
+// each time it is woken.
+func (h *Hub) Subscribe(userID int) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan struct{}]struct{})
+	}
+	h.subs[userID][wake] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return wake, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subs[userID], wake)
+			if len(h.subs[userID]) == 0 {
+				delete(h.subs, userID)
+			}
+		})
+	}
+}
+
+// Wake wakes every subscriber of userID.
+func (h *Hub) Wake(userID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for wake := range h.subs[userID] {
+		signal(wake)
+	}
+}
+
+// WakeAll wakes every subscriber, for when notifications may have been
+// lost.
+func (h *Hub) WakeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.subs {
+		for wake := range subs {
+			signal(wake)
+		}
+	}
+}
+
+func signal(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
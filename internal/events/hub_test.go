@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqltx"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func woken(wake <-chan struct{}) bool {
+	select {
+	case <-wake:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	alice, stopAlice := hub.Subscribe(1)
+	aliceTab, stopAliceTab := hub.Subscribe(1)
+	bob, stopBob := hub.Subscribe(2)
+	defer stopBob()
+
+	assert.NoError(t, hub.Notify(context.Background(), 1))
+	assert.NoError(t, hub.Notify(context.Background(), 1))
+	assert.True(t, woken(alice))
+	assert.False(t, woken(alice), "wake-ups are merged")
+	assert.True(t, woken(aliceTab))
+	assert.False(t, woken(bob))
+
+	stopAliceTab()
+	stopAliceTab()
+	hub.Wake(1)
+	assert.True(t, woken(alice))
+	assert.False(t, woken(aliceTab), "unsubscribed")
+
+	hub.WakeAll()
+	assert.True(t, woken(alice))
+	assert.True(t, woken(bob))
+
+	stopAlice()
+	assert.NotContains(t, hub.subs, 1)
+}
+
+func TestPGHubNotify(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	hub := NewPGHub(db, "")
+
+	// The notification is sent in the caller's transaction.
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_notify").WithArgs(Channel, "7").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, hub.Notify(sqltx.WithTx(context.Background(), tx), 7))
+	assert.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqltx"
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel; the payload is the user id.
+const Channel = "itemstore_user_events"
+
+const (
+	minReconnect = 100 * time.Millisecond
+	maxReconnect = time.Minute
+	// pingInterval is how often an idle listener checks its connection.
+	pingInterval = 90 * time.Second
+)
+
+// PGHub implements service.EventHub across replicas. Notify sends
+// pg_notify in the caller's transaction, so Postgres delivers the
+// notification only once the event is committed, and Listen wakes the
+// local subscribers of every replica that receives it.
+type PGHub struct {
+	*Hub
+	DB *sql.DB
+	// DSN is used for the listener's own connection.
+	DSN string
+}
+
+func NewPGHub(db *sql.DB, dsn string) *PGHub {
+	return &PGHub{
+		Hub: NewHub(),
+		DB:  db,
+		DSN: dsn,
+	}
+}
+
+func (h *PGHub) Notify(ctx context.Context, userID int) error {
+	_, err := sqltx.From(ctx, h.DB).ExecContext(ctx, "SELECT pg_notify($1, $2);", Channel, strconv.Itoa(userID))
+	if err != nil {
+		return fmt.Errorf("postgres notify user events: %w", err)
+	}
+
+	return nil
+}
+
+// Listen receives notifications until ctx is done. After the listener
+// reconnects every subscriber is woken, since notifications sent while it
+// was away are lost.
+func (h *PGHub) Listen(ctx context.Context) error {
+	listener := pq.NewListener(h.DSN, minReconnect, maxReconnect, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("user events listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		return fmt.Errorf("listen %s: %w", Channel, err)
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				h.WakeAll()
+				continue
+			}
+			userID, err := strconv.Atoi(n.Extra)
+			if err != nil {
+				log.Printf("user events listener: bad payload %q", n.Extra)
+				continue
+			}
+			h.Wake(userID)
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// GrantCoins gives coins to one or more users. The body lists the users,
+// the amount each receives and the reason; the grant succeeds or fails as a
+// whole.
+func (a *AdminHandler) GrantCoins(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		Users  []string `json:"users"`
+		Amount int      `json:"amount"`
+		Reason string   `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	adjustments, err := a.AdminService.GrantCoins(r.Context(), adminName, data.Users, data.Amount, data.Reason)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, adjustmentErrorStatus(err))
+		return
+	}
+
+	writeAdjustments(w, adjustments)
+}
+
+// ClawbackCoins takes coins back from a user. The body carries the amount
+// and the reason.
+func (a *AdminHandler) ClawbackCoins(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	userName, exists := mux.Vars(r)["username"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Username not specified"), http.StatusBadRequest)
+		return
+	}
+
+	var data struct {
+		Amount int    `json:"amount"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	adjustment, err := a.AdminService.ClawbackCoins(r.Context(), adminName, userName, data.Amount, data.Reason)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, adjustmentErrorStatus(err))
+		return
+	}
+
+	writeAdjustments(w, adjustment)
+}
+
+// SetBalance sets a user's balance outright. The body carries the new
+// balance and the reason.
+func (a *AdminHandler) SetBalance(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	userName, exists := mux.Vars(r)["username"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Username not specified"), http.StatusBadRequest)
+		return
+	}
+
+	var data struct {
+		Balance *int   `json:"balance"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	if data.Balance == nil {
+		utils.WriteErrorResponse(w, fmt.Errorf("Balance not specified"), http.StatusBadRequest)
+		return
+	}
+
+	adjustment, err := a.AdminService.SetBalance(r.Context(), adminName, userName, *data.Balance, data.Reason)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, adjustmentErrorStatus(err))
+		return
+	}
+
+	writeAdjustments(w, adjustment)
+}
+
+// GetBalanceAdjustments lists admin balance adjustments, newest first.
+// Query parameters: user to show a single user's, limit and offset.
+func (a *AdminHandler) GetBalanceAdjustments(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	page, err := a.AdminService.GetBalanceAdjustments(r.Context(), adminName, query.Get("user"), limit, offset)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, adjustmentErrorStatus(err))
+		return
+	}
+
+	writeAdjustments(w, page)
+}
+
+func writeAdjustments(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func adjustmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrNoUsers),
+		errors.Is(err, utils.ErrTooManyUsers),
+		errors.Is(err, utils.ErrDuplicateRecipient),
+		errors.Is(err, utils.ErrBadAmount),
+		errors.Is(err, utils.ErrBadBalance),
+		errors.Is(err, utils.ErrReasonRequired),
+		errors.Is(err, utils.ErrReasonTooLong),
+		errors.Is(err, utils.ErrBadPage),
+		errors.Is(err, utils.ErrNotEnoughBalance):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrNoUser):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
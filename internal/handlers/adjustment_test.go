@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantCoins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAdminService := service.NewMockAdminService(ctrl)
+	adminHandler := NewAdminHandler(mockAdminService)
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/admin/coins/grant", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		adjustments := []*entities.BalanceAdjustment{
+			{ID: 1, Username: "alice", Actor: "admin", Kind: entities.AdjustmentGrant, Amount: 100, Balance: 1100, Reason: "prize"},
+			{ID: 2, Username: "bob", Actor: "admin", Kind: entities.AdjustmentGrant, Amount: 100, Balance: 300, Reason: "prize"},
+		}
+
+		mockAdminService.EXPECT().GrantCoins(gomock.Any(), "admin", []string{"alice", "bob"}, 100, "prize").Return(adjustments, nil)
+
+		adminHandler.GrantCoins(w, newRequest(`{"users":["alice","bob"],"amount":100,"reason":"prize"}`))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body []*entities.BalanceAdjustment
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, adjustments, body)
+	})
+
+	t.Run("missing reason", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().GrantCoins(gomock.Any(), "admin", []string{"alice"}, 100, "").Return(nil, utils.ErrReasonRequired)
+
+		adminHandler.GrantCoins(w, newRequest(`{"users":["alice"],"amount":100}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().GrantCoins(gomock.Any(), "admin", []string{"ghost"}, 100, "prize").Return(nil, utils.ErrNoUser)
+
+		adminHandler.GrantCoins(w, newRequest(`{"users":["ghost"],"amount":100,"reason":"prize"}`))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestAdjustBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAdminService := service.NewMockAdminService(ctrl)
+	adminHandler := NewAdminHandler(mockAdminService)
+
+	newRequest := func(method, body string) *http.Request {
+		req := httptest.NewRequest(method, "/admin/users/bob/balance", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		return mux.SetURLVars(req, map[string]string{"username": "bob"})
+	}
+
+	t.Run("clawback", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().ClawbackCoins(gomock.Any(), "admin", "bob", 40, "mistake").
+			Return(&entities.BalanceAdjustment{Username: "bob", Kind: entities.AdjustmentClawback, Amount: -40}, nil)
+
+		adminHandler.ClawbackCoins(w, newRequest(http.MethodPost, `{"amount":40,"reason":"mistake"}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("clawback more than the balance", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().ClawbackCoins(gomock.Any(), "admin", "bob", 4000, "mistake").Return(nil, utils.ErrNotEnoughBalance)
+
+		adminHandler.ClawbackCoins(w, newRequest(http.MethodPost, `{"amount":4000,"reason":"mistake"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("set balance to zero", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().SetBalance(gomock.Any(), "admin", "bob", 0, "fraud").
+			Return(&entities.BalanceAdjustment{Username: "bob", Kind: entities.AdjustmentSet, Amount: -300}, nil)
+
+		adminHandler.SetBalance(w, newRequest(http.MethodPut, `{"balance":0,"reason":"fraud"}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("set balance without balance", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		adminHandler.SetBalance(w, newRequest(http.MethodPut, `{"reason":"fraud"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestGetBalanceAdjustments(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAdminService := service.NewMockAdminService(ctrl)
+	adminHandler := NewAdminHandler(mockAdminService)
+
+	newRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		return req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		page := &entities.BalanceAdjustmentsPage{Adjustments: []*entities.BalanceAdjustment{{ID: 7, Username: "bob"}}, Total: 1, Limit: 10}
+
+		mockAdminService.EXPECT().GetBalanceAdjustments(gomock.Any(), "admin", "bob", 10, 0).Return(page, nil)
+
+		adminHandler.GetBalanceAdjustments(w, newRequest("/admin/adjustments?user=bob&limit=10"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.BalanceAdjustmentsPage
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *page, body)
+	})
+
+	t.Run("bad page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		adminHandler.GetBalanceAdjustments(w, newRequest("/admin/adjustments?offset=x"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+//go:generate mockgen -source=admin.go -destination=../service/admin_service_mock.go -package=service
+type AdminService interface {
+	UnlockUser(ctx context.Context, adminName, userName string) error
+	ForceRefund(ctx context.Context, adminName, userName, itemName string, quantity int) (*entities.RefundOperation, error)
+	GetTransferLimits(ctx context.Context, userName string) (*entities.UserTransferLimits, error)
+	SetTransferLimits(ctx context.Context, adminName, userName string, limits entities.TransferLimits) (*entities.UserTransferLimits, error)
+	ClearTransferLimits(ctx context.Context, adminName, userName string) error
+	GrantCoins(ctx context.Context, adminName string, userNames []string, amount int, reason string) ([]*entities.BalanceAdjustment, error)
+	ClawbackCoins(ctx context.Context, adminName, userName string, amount int, reason string) (*entities.BalanceAdjustment, error)
+	SetBalance(ctx context.Context, adminName, userName string, balance int, reason string) (*entities.BalanceAdjustment, error)
+	GetBalanceAdjustments(ctx context.Context, adminName, userName string, limit, offset int) (*entities.BalanceAdjustmentsPage, error)
+	SetItemPrice(ctx context.Context, adminName, itemName string, price int) (*entities.CatalogItem, error)
+}
+
+type AdminHandler struct {
+	AdminService AdminService
+}
+
+func NewAdminHandler(adminService AdminService) *AdminHandler {
+	return &AdminHandler{
+		AdminService: adminService,
+	}
+}
+
+func (a *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	userName, exists := mux.Vars(r)["username"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Username not specified"), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.AdminService.UnlockUser(r.Context(), adminName, userName); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ForceRefund refunds a user's item regardless of the refund window.
+func (a *AdminHandler) ForceRefund(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userName, exists := vars["username"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Username not specified"), http.StatusBadRequest)
+		return
+	}
+	itemName, exists := vars["item"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Item not exists"), http.StatusBadRequest)
+		return
+	}
+
+	quantity, err := decodeRefundQuantity(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	refund, err := a.AdminService.ForceRefund(r.Context(), adminName, userName, itemName, quantity)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, refundErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(refund); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetTransferLimits returns the transfer limits applied to a user.
+func (a *AdminHandler) GetTransferLimits(w http.ResponseWriter, r *http.Request) {
+	userName, exists := mux.Vars(r)["username"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Username not specified"), http.StatusBadRequest)
+		return
+	}
+
+	limits, err := a.AdminService.GetTransferLimits(r.Context(), userName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, transferLimitsErrorStatus(err))
+		return
+	}
+
+	writeTransferLimits(w, limits)
+}
+
+// SetTransferLimits overrides the configured transfer limits for a user.
+// The body carries every limit; zero lifts a limit for the user.
+func (a *AdminHandler) SetTransferLimits(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	userName, exists := mux.Vars(r)["username"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Username not specified"), http.StatusBadRequest)
+		return
+	}
+
+	var data entities.TransferLimits
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	limits, err := a.AdminService.SetTransferLimits(r.Context(), adminName, userName, data)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, transferLimitsErrorStatus(err))
+		return
+	}
+
+	writeTransferLimits(w, limits)
+}
+
+// ClearTransferLimits removes a user's override so the configured transfer
+// limits apply again.
+func (a *AdminHandler) ClearTransferLimits(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	userName, exists := mux.Vars(r)["username"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Username not specified"), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.AdminService.ClearTransferLimits(r.Context(), adminName, userName); err != nil {
+		utils.WriteErrorResponse(w, err, transferLimitsErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeTransferLimits(w http.ResponseWriter, limits *entities.UserTransferLimits) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(limits); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func transferLimitsErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadLimit):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrNoUser),
+		errors.Is(err, utils.ErrNoTransferLimits):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnlockUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAdminService := service.NewMockAdminService(ctrl)
+	adminHandler := NewAdminHandler(mockAdminService)
+
+	t.Run("user context error", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/bob/unlock", nil)
+		w := httptest.NewRecorder()
+
+		adminHandler.UnlockUser(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+
+	t.Run("no username", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users//unlock", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		req = mux.SetURLVars(req, map[string]string{})
+		w := httptest.NewRecorder()
+
+		adminHandler.UnlockUser(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/bob/unlock", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		req = mux.SetURLVars(req, map[string]string{"username": "bob"})
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().UnlockUser(gomock.Any(), "admin", "bob").Return(errors.New("db error"))
+
+		adminHandler.UnlockUser(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/bob/unlock", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		req = mux.SetURLVars(req, map[string]string{"username": "bob"})
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().UnlockUser(gomock.Any(), "admin", "bob").Return(nil)
+
+		adminHandler.UnlockUser(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+}
+
+func TestForceRefund(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAdminService := service.NewMockAdminService(ctrl)
+	adminHandler := NewAdminHandler(mockAdminService)
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/bob/refund/cup", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		return mux.SetURLVars(req, map[string]string{"username": "bob", "item": "cup"})
+	}
+
+	t.Run("no item", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/bob/refund/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		req = mux.SetURLVars(req, map[string]string{"username": "bob"})
+		w := httptest.NewRecorder()
+
+		adminHandler.ForceRefund(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().ForceRefund(gomock.Any(), "admin", "bob", "cup", 1).Return(nil, utils.ErrNoUser)
+
+		adminHandler.ForceRefund(w, newRequest(""))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().ForceRefund(gomock.Any(), "admin", "bob", "cup", 2).
+			Return(&entities.RefundOperation{Item: "cup", Quantity: 2, Amount: 40, RefundedBy: "admin"}, nil)
+
+		adminHandler.ForceRefund(w, newRequest(`{"quantity": 2}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+}
+
+func TestTransferLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAdminService := service.NewMockAdminService(ctrl)
+	adminHandler := NewAdminHandler(mockAdminService)
+
+	newRequest := func(method, body string) *http.Request {
+		req := httptest.NewRequest(method, "/admin/users/bob/limits", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		return mux.SetURLVars(req, map[string]string{"username": "bob"})
+	}
+
+	t.Run("get", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		limits := &entities.UserTransferLimits{Username: "bob", TransferLimits: entities.TransferLimits{DailyAmount: 200}}
+
+		mockAdminService.EXPECT().GetTransferLimits(gomock.Any(), "bob").Return(limits, nil)
+
+		adminHandler.GetTransferLimits(w, newRequest(http.MethodGet, ""))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.UserTransferLimits
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *limits, body)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		limits := entities.TransferLimits{MaxTransfer: 50, DailyRecipients: 5}
+
+		mockAdminService.EXPECT().SetTransferLimits(gomock.Any(), "admin", "bob", limits).
+			Return(&entities.UserTransferLimits{Username: "bob", TransferLimits: limits, Override: true, UpdatedBy: "admin"}, nil)
+
+		adminHandler.SetTransferLimits(w, newRequest(http.MethodPut, `{"maxTransfer":50,"dailyRecipients":5}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().SetTransferLimits(gomock.Any(), "admin", "bob", gomock.Any()).Return(nil, utils.ErrBadLimit)
+
+		adminHandler.SetTransferLimits(w, newRequest(http.MethodPut, `{"dailyAmount":-1}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("clear without override", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().ClearTransferLimits(gomock.Any(), "admin", "bob").Return(utils.ErrNoTransferLimits)
+
+		adminHandler.ClearTransferLimits(w, newRequest(http.MethodDelete, ""))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+//go:generate mockgen -source=audit.go -destination=../service/audit_service_mock.go -package=service
+type AuditService interface {
+	GetAuditEvents(ctx context.Context, auditorName string, filter entities.AuditFilter, after, limit int) (*entities.AuditEventsPage, error)
+	ExportAuditEvents(ctx context.Context, auditorName string, filter entities.AuditFilter, fn func(event *entities.AuditEvent) error) error
+	VerifyAuditLog(ctx context.Context, auditorName string) (*entities.AuditVerification, error)
+}
+
+type AuditHandler struct {
+	AuditService AuditService
+}
+
+func NewAuditHandler(auditService AuditService) *AuditHandler {
+	return &AuditHandler{
+		AuditService: auditService,
+	}
+}
+
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+)
+
+var auditCSVHeader = []string{"id", "action", "actor", "target", "ip", "userAgent", "requestId", "details", "createdAt", "prevHash", "hash"}
+
+// GetAuditEvents lists audit events in log order. Query parameters: action,
+// actor, target, since and until (RFC 3339) to filter, after (an event id)
+// and limit to page.
+func (a *AuditHandler) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	auditorName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := auditFilter(query)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	after, err := queryInt(query.Get("after"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	page, err := a.AuditService.GetAuditEvents(r.Context(), auditorName, filter, after, limit)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, auditErrorStatus(err))
+		return
+	}
+
+	writeAudit(w, page)
+}
+
+// ExportAuditEvents streams every audit event matching the filters of
+// GetAuditEvents, in log order, as JSON lines or, with format=csv, as CSV.
+// The hashes are included so the export can be verified offline.
+func (a *AuditHandler) ExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	auditorName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := auditFilter(query)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = ExportFormatJSONL
+	}
+	if format != ExportFormatJSONL && format != ExportFormatCSV {
+		utils.WriteErrorResponse(w, utils.ErrBadExportFormat, http.StatusBadRequest)
+		return
+	}
+
+	// The response starts with the first event, so errors found before
+	// it, such as a bad filter, still get a proper status.
+	started := false
+	csvWriter := csv.NewWriter(w)
+	encoder := json.NewEncoder(w)
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+
+		if format == ExportFormatCSV {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit-log.csv"`)
+			w.WriteHeader(http.StatusOK)
+			return csvWriter.Write(auditCSVHeader)
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit-log.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	err = a.AuditService.ExportAuditEvents(r.Context(), auditorName, filter, func(event *entities.AuditEvent) error {
+		if err := start(); err != nil {
+			return err
+		}
+
+		if format == ExportFormatCSV {
+			return csvWriter.Write([]string{
+				strconv.Itoa(event.ID),
+				event.Action,
+				event.Actor,
+				event.Target,
+				event.IP,
+				event.UserAgent,
+				event.RequestID,
+				event.Details,
+				event.CreatedAt.Format(time.RFC3339Nano),
+				event.PrevHash,
+				event.Hash,
+			})
+		}
+
+		return encoder.Encode(event)
+	})
+	if err == nil {
+		err = start()
+	}
+	if err != nil {
+		if !started {
+			utils.WriteErrorResponse(w, err, auditErrorStatus(err))
+			return
+		}
+		log.Printf("export audit log for %s: %v", auditorName, err)
+	}
+
+	csvWriter.Flush()
+}
+
+// VerifyAuditLog checks the audit log's hash chain.
+func (a *AuditHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	auditorName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	result, err := a.AuditService.VerifyAuditLog(r.Context(), auditorName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, auditErrorStatus(err))
+		return
+	}
+
+	writeAudit(w, result)
+}
+
+// auditFilter reads the audit log filters from the query string.
+func auditFilter(query url.Values) (entities.AuditFilter, error) {
+	filter := entities.AuditFilter{
+		Action: query.Get("action"),
+		Actor:  query.Get("actor"),
+		Target: query.Get("target"),
+	}
+
+	for _, bound := range []struct {
+		key   string
+		value *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		value := query.Get(bound.key)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return entities.AuditFilter{}, fmt.Errorf("%s must be an RFC 3339 time", bound.key)
+		}
+		*bound.value = t
+	}
+
+	return filter, nil
+}
+
+func writeAudit(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func auditErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadPage),
+		errors.Is(err, utils.ErrBadTimeRange),
+		errors.Is(err, utils.ErrBadExportFormat):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuditRequest(target string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	return req.WithContext(context.WithValue(req.Context(), "user", "auditor"))
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuditService := service.NewMockAuditService(ctrl)
+
+	auditHandler := AuditHandler{
+		AuditService: mockAuditService,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+		filter := entities.AuditFilter{Action: entities.AuditCoinsSent, Actor: "alice", Since: since}
+		page := &entities.AuditEventsPage{
+			Events: []*entities.AuditEvent{{ID: 11, Action: entities.AuditCoinsSent, Actor: "alice", Target: "bob", CreatedAt: since, Hash: "bb"}},
+			Total:  1,
+			Limit:  5,
+			After:  10,
+		}
+
+		mockAuditService.EXPECT().
+			GetAuditEvents(gomock.Any(), "auditor", filter, 10, 5).
+			Return(page, nil)
+
+		auditHandler.GetAuditEvents(w, newAuditRequest("/audit/events?action=coins.sent&actor=alice&since=2025-03-01T00:00:00Z&after=10&limit=5"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.AuditEventsPage
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *page, body)
+	})
+
+	t.Run("bad time", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		auditHandler.GetAuditEvents(w, newAuditRequest("/audit/events?until=yesterday"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad time range", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAuditService.EXPECT().
+			GetAuditEvents(gomock.Any(), "auditor", gomock.Any(), 0, 0).
+			Return(nil, utils.ErrBadTimeRange)
+
+		auditHandler.GetAuditEvents(w, newAuditRequest("/audit/events?since=2025-03-02T00:00:00Z&until=2025-03-01T00:00:00Z"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		auditHandler.GetAuditEvents(w, httptest.NewRequest(http.MethodGet, "/audit/events", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuditService := service.NewMockAuditService(ctrl)
+
+	auditHandler := AuditHandler{
+		AuditService: mockAuditService,
+	}
+
+	createdAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []*entities.AuditEvent{
+		{ID: 1, Action: entities.AuditRegistered, Actor: "alice", Target: "alice", CreatedAt: createdAt, Hash: "aa"},
+		{ID: 2, Action: entities.AuditCoinsSent, Actor: "alice", Target: "bob", Details: "amount=5, category=other", CreatedAt: createdAt, PrevHash: "aa", Hash: "bb"},
+	}
+	export := func(ctx context.Context, auditorName string, filter entities.AuditFilter, fn func(event *entities.AuditEvent) error) error {
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	t.Run("jsonl", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAuditService.EXPECT().
+			ExportAuditEvents(gomock.Any(), "auditor", entities.AuditFilter{Actor: "alice"}, gomock.Any()).
+			DoAndReturn(export)
+
+		auditHandler.ExportAuditEvents(w, newAuditRequest("/audit/export?actor=alice"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+		decoder := json.NewDecoder(resp.Body)
+		for _, want := range events {
+			var event entities.AuditEvent
+			assert.NoError(t, decoder.Decode(&event))
+			assert.Equal(t, *want, event)
+		}
+		assert.False(t, decoder.More())
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAuditService.EXPECT().
+			ExportAuditEvents(gomock.Any(), "auditor", entities.AuditFilter{}, gomock.Any()).
+			DoAndReturn(export)
+
+		auditHandler.ExportAuditEvents(w, newAuditRequest("/audit/export?format=csv"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{
+			auditCSVHeader,
+			{"1", entities.AuditRegistered, "alice", "alice", "", "", "", "", "2025-03-01T12:00:00Z", "", "aa"},
+			{"2", entities.AuditCoinsSent, "alice", "bob", "", "", "", "amount=5, category=other", "2025-03-01T12:00:00Z", "aa", "bb"},
+		}, records)
+	})
+
+	t.Run("empty csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAuditService.EXPECT().
+			ExportAuditEvents(gomock.Any(), "auditor", entities.AuditFilter{Action: "nothing"}, gomock.Any()).
+			Return(nil)
+
+		auditHandler.ExportAuditEvents(w, newAuditRequest("/audit/export?format=csv&action=nothing"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, strings.Join(auditCSVHeader, ",")+"\n", w.Body.String())
+	})
+
+	t.Run("bad format", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		auditHandler.ExportAuditEvents(w, newAuditRequest("/audit/export?format=xml"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("error before the first event", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAuditService.EXPECT().
+			ExportAuditEvents(gomock.Any(), "auditor", gomock.Any(), gomock.Any()).
+			Return(utils.ErrBadTimeRange)
+
+		auditHandler.ExportAuditEvents(w, newAuditRequest("/audit/export?since=2025-03-02T00:00:00Z&until=2025-03-01T00:00:00Z"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuditService := service.NewMockAuditService(ctrl)
+
+	auditHandler := AuditHandler{
+		AuditService: mockAuditService,
+	}
+
+	w := httptest.NewRecorder()
+	result := &entities.AuditVerification{Events: 41, BrokenAt: 42}
+
+	mockAuditService.EXPECT().VerifyAuditLog(gomock.Any(), "auditor").Return(result, nil)
+
+	auditHandler.VerifyAuditLog(w, newAuditRequest("/audit/verify"))
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body entities.AuditVerification
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, *result, body)
+}
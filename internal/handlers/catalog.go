@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetCatalog lists the items on sale and their prices.
+func (u *UserHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
+	catalog, err := u.UserService.GetCatalog(r.Context())
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeCatalog(w, catalog)
+}
+
+// SetItemPrice sets an item's price, adding it to the catalog if it is new.
+// Body: {"price": coins}.
+func (a *AdminHandler) SetItemPrice(w http.ResponseWriter, r *http.Request) {
+	adminName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	itemName, exists := mux.Vars(r)["item"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Item not exists"), http.StatusBadRequest)
+		return
+	}
+
+	var data struct {
+		Price int `json:"price"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	item, err := a.AdminService.SetItemPrice(r.Context(), adminName, itemName, data.Price)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, catalogErrorStatus(err))
+		return
+	}
+
+	writeCatalog(w, item)
+}
+
+func writeCatalog(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func catalogErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadItemName),
+		errors.Is(err, utils.ErrBadPrice):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCatalog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+	userHandler := NewUserHandler(mockUserService, nil)
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		catalog := []*entities.CatalogItem{{Name: "cup", Price: 20}, {Name: "pen", Price: 10}}
+
+		mockUserService.EXPECT().GetCatalog(gomock.Any()).Return(catalog, nil)
+
+		userHandler.GetCatalog(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body []*entities.CatalogItem
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, catalog, body)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().GetCatalog(gomock.Any()).Return(nil, errors.New("db down"))
+
+		userHandler.GetCatalog(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
+
+func TestSetItemPrice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAdminService := service.NewMockAdminService(ctrl)
+	adminHandler := NewAdminHandler(mockAdminService)
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, "/admin/items/sticker", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", "admin"))
+		return mux.SetURLVars(req, map[string]string{"item": "sticker"})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().SetItemPrice(gomock.Any(), "admin", "sticker", 15).
+			Return(&entities.CatalogItem{Name: "sticker", Price: 15}, nil)
+
+		adminHandler.SetItemPrice(w, newRequest(`{"price":15}`))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.CatalogItem
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, entities.CatalogItem{Name: "sticker", Price: 15}, body)
+	})
+
+	t.Run("bad price", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockAdminService.EXPECT().SetItemPrice(gomock.Any(), "admin", "sticker", 0).Return(nil, utils.ErrBadPrice)
+
+		adminHandler.SetItemPrice(w, newRequest(`{}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		adminHandler.SetItemPrice(w, newRequest(`{"price":`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
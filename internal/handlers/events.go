@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/KonstantinGalanin/itemStore/internal/websocket"
+)
+
+const (
+	// LastEventIDHeader is sent by EventSource when it reconnects.
+	LastEventIDHeader = "Last-Event-ID"
+
+	// eventsRetry is how long an EventSource waits before reconnecting.
+	eventsRetry = 3 * time.Second
+)
+
+// eventsHeartbeat is how often an idle stream is pinged. Each ping also
+// looks for events, in case a wake-up was lost.
+var eventsHeartbeat = 15 * time.Second
+
+// CreateStreamToken returns a short-lived token that opens the event stream
+// when passed as the access_token query parameter, for clients that cannot
+// send the Authorization header there.
+func (u *UserHandler) CreateStreamToken(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+	role, _ := r.Context().Value("role").(string)
+
+	resp, err := u.JwtService.CreateStreamToken(&entities.User{Username: userName, Role: role})
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(resp); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetEvents streams the user's coin.received, item.received,
+// purchase.completed and balance.changed events as Server-Sent Events, or
+// over a WebSocket when the request asks to upgrade. A client resumes after
+// the last event it saw with the Last-Event-ID header, which EventSource
+// sends when it reconnects, or the lastEventId query parameter; without
+// either the stream starts with the next event.
+func (u *UserHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	lastID := r.Header.Get(LastEventIDHeader)
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	afterID, err := queryInt(lastID)
+	if err != nil || afterID < 0 {
+		utils.WriteErrorResponse(w, utils.ErrBadEventID, http.StatusBadRequest)
+		return
+	}
+
+	// Subscribing before looking for events means none can slip in
+	// between unnoticed.
+	wake, unsubscribe, err := u.UserService.SubscribeEvents(r.Context(), userName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, eventsErrorStatus(err))
+		return
+	}
+	defer unsubscribe()
+
+	if lastID == "" {
+		if afterID, err = u.UserService.LastUserEventID(r.Context(), userName); err != nil {
+			utils.WriteErrorResponse(w, err, eventsErrorStatus(err))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	var stream eventStream
+	if websocket.IsUpgrade(r) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close(websocket.CloseNormal, "")
+
+		// A hijacked connection no longer cancels the request context, so
+		// the read loop reports the client going away.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			conn.ReadLoop()
+			cancel()
+		}()
+
+		stream = &wsStream{conn: conn}
+	} else {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			utils.WriteErrorResponse(w, errors.New("streaming not supported"), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+		flusher.Flush()
+
+		stream = &sseStream{w: w, flusher: flusher}
+	}
+
+	ticker := time.NewTicker(eventsHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		if afterID, err = u.sendEvents(ctx, stream, userName, afterID); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("stream events to %s: %v", userName, err)
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+			if err := stream.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sendEvents writes the user's events after afterID and returns the id of
+// the last one written.
+func (u *UserHandler) sendEvents(ctx context.Context, stream eventStream, userName string, afterID int) (int, error) {
+	for {
+		events, err := u.UserService.GetUserEvents(ctx, userName, afterID)
+		if err != nil {
+			return afterID, err
+		}
+		if len(events) == 0 {
+			return afterID, stream.Flush()
+		}
+
+		for _, event := range events {
+			if err := stream.WriteEvent(event); err != nil {
+				return afterID, err
+			}
+			afterID = event.ID
+		}
+	}
+}
+
+// eventStream is the transport GetEvents writes events to.
+type eventStream interface {
+	WriteEvent(event *entities.UserEvent) error
+	Flush() error
+	Ping() error
+}
+
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *sseStream) WriteEvent(event *entities.UserEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+func (s *sseStream) Flush() error {
+	s.flusher.Flush()
+	return nil
+}
+
+// Ping writes a comment line, which EventSource ignores.
+func (s *sseStream) Ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+
+	return s.Flush()
+}
+
+type wsStream struct {
+	conn *websocket.Conn
+}
+
+func (s *wsStream) WriteEvent(event *entities.UserEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.conn.WriteText(data)
+}
+
+func (s *wsStream) Flush() error {
+	return nil
+}
+
+func (s *wsStream) Ping() error {
+	return s.conn.Ping()
+}
+
+func eventsErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadEventID):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrNoUser):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
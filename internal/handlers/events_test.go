@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	createdAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := func(id int, eventType, data string) *entities.UserEvent {
+		return &entities.UserEvent{ID: id, Type: eventType, Data: json.RawMessage(data), CreatedAt: createdAt}
+	}
+
+	// newRequest returns a request whose context GetUserEvents cancels
+	// once it has nothing more to return, which ends the stream.
+	newRequest := func(target string) (*http.Request, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "user", "alice"))
+		return httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx), cancel
+	}
+
+	t.Run("resume after last event id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, cancel := newRequest("/events")
+		req.Header.Set(LastEventIDHeader, "4")
+
+		gomock.InOrder(
+			mockUserService.EXPECT().SubscribeEvents(gomock.Any(), "alice").Return(make(chan struct{}), func() {}, nil),
+			mockUserService.EXPECT().GetUserEvents(gomock.Any(), "alice", 4).Return([]*entities.UserEvent{
+				event(5, entities.StreamCoinReceived, `{"fromUser":"bob","amount":15}`),
+				event(6, entities.StreamBalanceChanged, `{"balance":1015}`),
+			}, nil),
+			mockUserService.EXPECT().GetUserEvents(gomock.Any(), "alice", 6).DoAndReturn(func(ctx context.Context, userName string, afterID int) ([]*entities.UserEvent, error) {
+				cancel()
+				return nil, nil
+			}),
+		)
+
+		userHandler.GetEvents(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "retry: 3000\n\n"+
+			"id: 5\nevent: coin.received\ndata: {\"id\":5,\"type\":\"coin.received\",\"data\":{\"fromUser\":\"bob\",\"amount\":15},\"createdAt\":\"2025-03-01T12:00:00Z\"}\n\n"+
+			"id: 6\nevent: balance.changed\ndata: {\"id\":6,\"type\":\"balance.changed\",\"data\":{\"balance\":1015},\"createdAt\":\"2025-03-01T12:00:00Z\"}\n\n",
+			w.Body.String())
+	})
+
+	t.Run("new stream waits for the next event", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, cancel := newRequest("/events")
+		wake := make(chan struct{}, 1)
+		wake <- struct{}{}
+		unsubscribed := false
+
+		gomock.InOrder(
+			mockUserService.EXPECT().SubscribeEvents(gomock.Any(), "alice").Return(wake, func() { unsubscribed = true }, nil),
+			mockUserService.EXPECT().LastUserEventID(gomock.Any(), "alice").Return(9, nil),
+			mockUserService.EXPECT().GetUserEvents(gomock.Any(), "alice", 9).Return(nil, nil),
+			mockUserService.EXPECT().GetUserEvents(gomock.Any(), "alice", 9).Return([]*entities.UserEvent{
+				event(10, entities.StreamPurchaseCompleted, `{"item":"cup"}`),
+			}, nil),
+			mockUserService.EXPECT().GetUserEvents(gomock.Any(), "alice", 10).DoAndReturn(func(ctx context.Context, userName string, afterID int) ([]*entities.UserEvent, error) {
+				cancel()
+				return nil, nil
+			}),
+		)
+
+		userHandler.GetEvents(w, req)
+
+		assert.Contains(t, w.Body.String(), "id: 10\nevent: purchase.completed\n")
+		assert.Equal(t, 1, strings.Count(w.Body.String(), "id: "))
+		assert.True(t, unsubscribed)
+	})
+
+	t.Run("last event id in the query", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, cancel := newRequest("/events?lastEventId=7")
+
+		mockUserService.EXPECT().SubscribeEvents(gomock.Any(), "alice").Return(make(chan struct{}), func() {}, nil)
+		mockUserService.EXPECT().GetUserEvents(gomock.Any(), "alice", 7).DoAndReturn(func(ctx context.Context, userName string, afterID int) ([]*entities.UserEvent, error) {
+			cancel()
+			return nil, nil
+		})
+
+		userHandler.GetEvents(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("bad last event id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, cancel := newRequest("/events")
+		defer cancel()
+		req.Header.Set(LastEventIDHeader, "abc")
+
+		userHandler.GetEvents(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, cancel := newRequest("/events")
+		defer cancel()
+
+		mockUserService.EXPECT().SubscribeEvents(gomock.Any(), "alice").Return(nil, nil, utils.ErrNoUser)
+
+		userHandler.GetEvents(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetEvents(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+// GetHistory searches the caller's coin transfers. Query parameters:
+// direction (sent or received), category, q to match memos and the other
+// party's username, limit and offset.
+func (u *UserHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	filter := entities.TransferFilter{
+		Direction: query.Get("direction"),
+		Category:  query.Get("category"),
+		Query:     query.Get("q"),
+	}
+	page, err := u.UserService.SearchHistory(r.Context(), userName, filter, limit, offset)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, utils.ErrBadPage) || errors.Is(err, utils.ErrBadDirection) || errors.Is(err, utils.ErrBadCategory) {
+			status = http.StatusBadRequest
+		}
+		utils.WriteErrorResponse(w, err, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		filter := entities.TransferFilter{Direction: entities.TransferDirectionReceived, Category: entities.TransferCategoryThanks, Query: "pizza"}
+		page := &entities.TransfersPage{
+			Transfers: []*entities.CoinTransfer{{ID: 1, FromUser: "bob", ToUser: "alice", Amount: 5, Memo: "pizza", Category: entities.TransferCategoryThanks}},
+			Total:     1,
+			Limit:     10,
+		}
+
+		mockUserService.EXPECT().
+			SearchHistory(gomock.Any(), "alice", filter, 10, 0).
+			Return(page, nil)
+
+		userHandler.GetHistory(w, newRequest("/history?direction=received&category=thanks&q=pizza&limit=10"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.TransfersPage
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *page, body)
+	})
+
+	t.Run("bad page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetHistory(w, newRequest("/history?limit=abc"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad direction", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			SearchHistory(gomock.Any(), "alice", entities.TransferFilter{Direction: "both"}, 0, 0).
+			Return(nil, utils.ErrBadDirection)
+
+		userHandler.GetHistory(w, newRequest("/history?direction=both"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetHistory(w, httptest.NewRequest(http.MethodGet, "/history", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// CreateListing puts owned items up for sale. Body:
+// {"item": "...", "quantity": n, "price": coins}; quantity defaults to 1.
+func (u *UserHandler) CreateListing(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	data := struct {
+		Item     string `json:"item"`
+		Quantity int    `json:"quantity"`
+		Price    int    `json:"price"`
+	}{
+		Quantity: 1,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	listing, err := u.UserService.CreateListing(r.Context(), userName, data.Item, data.Quantity, data.Price)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, marketErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(listing); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetListings browses open listings. Query parameters: limit, offset and
+// item to only show listings of one item.
+func (u *UserHandler) GetListings(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	page, err := u.UserService.GetListings(r.Context(), query.Get("item"), limit, offset)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, marketErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func (u *UserHandler) BuyListing(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	listingID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoListing, http.StatusNotFound)
+		return
+	}
+
+	listing, err := u.UserService.BuyListing(r.Context(), userName, listingID)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, marketErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(listing); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func (u *UserHandler) CancelListing(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	listingID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoListing, http.StatusNotFound)
+		return
+	}
+
+	if err := u.UserService.CancelListing(r.Context(), userName, listingID); err != nil {
+		utils.WriteErrorResponse(w, err, marketErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func marketErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadQuantity),
+		errors.Is(err, utils.ErrBadPrice),
+		errors.Is(err, utils.ErrBadPage),
+		errors.Is(err, utils.ErrNotEnoughItems),
+		errors.Is(err, utils.ErrNotEnoughBalance),
+		errors.Is(err, utils.ErrNoItem),
+		errors.Is(err, utils.ErrListingClosed),
+		errors.Is(err, utils.ErrOwnListing):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, utils.ErrNoListing):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/market/listings", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		listing := &entities.Listing{ID: 7, Seller: "alice", ItemType: "cup", Quantity: 1, Price: 50, Status: entities.ListingOpen}
+
+		mockUserService.EXPECT().
+			CreateListing(gomock.Any(), "alice", "cup", 1, 50).
+			Return(listing, nil)
+
+		userHandler.CreateListing(w, newRequest(`{"item":"cup","price":50}`))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		var body entities.Listing
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *listing, body)
+	})
+
+	t.Run("not enough items", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			CreateListing(gomock.Any(), "alice", "cup", 5, 50).
+			Return(nil, utils.ErrNotEnoughItems)
+
+		userHandler.CreateListing(w, newRequest(`{"item":"cup","quantity":5,"price":50}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.CreateListing(w, newRequest(`{"item":`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestGetListings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/market/listings?item=cup&limit=5&offset=10", nil)
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			GetListings(gomock.Any(), "cup", 5, 10).
+			Return(&entities.ListingsPage{Listings: []*entities.Listing{}, Limit: 5, Offset: 10}, nil)
+
+		userHandler.GetListings(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("bad limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/market/listings?limit=many", nil)
+		w := httptest.NewRecorder()
+
+		userHandler.GetListings(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestBuyListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(id string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/market/listings/"+id+"/buy", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", "bob"))
+		return mux.SetURLVars(req, map[string]string{"id": id})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			BuyListing(gomock.Any(), "bob", 7).
+			Return(&entities.Listing{ID: 7, Status: entities.ListingSold, Buyer: "bob"}, nil)
+
+		userHandler.BuyListing(w, newRequest("7"))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			BuyListing(gomock.Any(), "bob", 7).
+			Return(nil, utils.ErrListingClosed)
+
+		userHandler.BuyListing(w, newRequest("7"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			BuyListing(gomock.Any(), "bob", 8).
+			Return(nil, utils.ErrNoListing)
+
+		userHandler.BuyListing(w, newRequest("8"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("bad id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.BuyListing(w, newRequest("abc"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestCancelListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, "/market/listings/7", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", "bob"))
+		return mux.SetURLVars(req, map[string]string{"id": "7"})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().CancelListing(gomock.Any(), "bob", 7).Return(nil)
+
+		userHandler.CancelListing(w, newRequest())
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("not the seller", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().CancelListing(gomock.Any(), "bob", 7).Return(utils.ErrForbidden)
+
+		userHandler.CancelListing(w, newRequest())
+
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+}
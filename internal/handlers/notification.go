@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetNotifications lists the user's notifications, newest first, with the
+// number of unread ones. Query parameters: unread=true to list only unread
+// notifications, limit and offset.
+func (u *UserHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	unreadOnly := false
+	if value := query.Get("unread"); value != "" {
+		var err error
+		if unreadOnly, err = strconv.ParseBool(value); err != nil {
+			utils.WriteErrorResponse(w, fmt.Errorf("Bad unread value"), http.StatusBadRequest)
+			return
+		}
+	}
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	page, err := u.UserService.GetNotifications(r.Context(), userName, unreadOnly, limit, offset)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, notificationErrorStatus(err))
+		return
+	}
+
+	writeNotifications(w, page)
+}
+
+func (u *UserHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	notificationID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoNotification, http.StatusNotFound)
+		return
+	}
+
+	if err := u.UserService.MarkNotificationRead(r.Context(), userName, notificationID); err != nil {
+		utils.WriteErrorResponse(w, err, notificationErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// MarkAllNotificationsRead marks all of the user's notifications read and
+// responds with how many were unread.
+func (u *UserHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	marked, err := u.UserService.MarkAllNotificationsRead(r.Context(), userName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, notificationErrorStatus(err))
+		return
+	}
+
+	writeNotifications(w, entities.MarkReadResponse{Marked: marked})
+}
+
+// GetNotificationPreferences responds with every notification kind and
+// whether the user gets notifications of that kind.
+func (u *UserHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	preferences, err := u.UserService.GetNotificationPreferences(r.Context(), userName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, notificationErrorStatus(err))
+		return
+	}
+
+	writeNotifications(w, preferences)
+}
+
+// SetNotificationPreferences turns notification kinds on or off. Body:
+// {"coins_received": false, "item_refunded": true}; kinds left out keep
+// their setting.
+func (u *UserHandler) SetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	var preferences entities.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	preferences, err := u.UserService.SetNotificationPreferences(r.Context(), userName, preferences)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, notificationErrorStatus(err))
+		return
+	}
+
+	writeNotifications(w, preferences)
+}
+
+// GetEmailSettings responds with where and how the user is emailed.
+func (u *UserHandler) GetEmailSettings(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := u.UserService.GetEmailSettings(r.Context(), userName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, notificationErrorStatus(err))
+		return
+	}
+
+	writeNotifications(w, settings)
+}
+
+// SetEmailSettings sets where and how the user is emailed. Body:
+// {"address": "alice@example.com", "mode": "instant"}, where mode is off,
+// instant for receipts and coin alerts as they happen, or digest for a
+// daily summary of all notifications.
+func (u *UserHandler) SetEmailSettings(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	var settings entities.EmailSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	result, err := u.UserService.SetEmailSettings(r.Context(), userName, settings)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, notificationErrorStatus(err))
+		return
+	}
+
+	writeNotifications(w, result)
+}
+
+func writeNotifications(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func notificationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadNotificationKind),
+		errors.Is(err, utils.ErrBadEmail),
+		errors.Is(err, utils.ErrBadEmailMode),
+		errors.Is(err, utils.ErrBadPage):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrNoNotification),
+		errors.Is(err, utils.ErrNoUser):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
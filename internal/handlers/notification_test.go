@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNotifications(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("unread page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		page := &entities.NotificationsPage{
+			Notifications: []*entities.Notification{{
+				ID:        7,
+				Kind:      entities.NotificationCoinsReceived,
+				Text:      "bob sent you 50 coins",
+				CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+			}},
+			Unread: 1,
+			Total:  1,
+			Limit:  5,
+			Offset: 10,
+		}
+
+		mockUserService.EXPECT().GetNotifications(gomock.Any(), "alice", true, 5, 10).Return(page, nil)
+
+		userHandler.GetNotifications(w, newRequest("/notifications?unread=true&limit=5&offset=10"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.NotificationsPage
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *page, body)
+	})
+
+	t.Run("bad unread", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetNotifications(w, newRequest("/notifications?unread=maybe"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().GetNotifications(gomock.Any(), "alice", false, -1, 0).Return(nil, utils.ErrBadPage)
+
+		userHandler.GetNotifications(w, newRequest("/notifications?limit=-1"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetNotifications(w, httptest.NewRequest(http.MethodGet, "/notifications", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(id string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/notifications/"+id+"/read", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("one", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().MarkNotificationRead(gomock.Any(), "alice", 7).Return(nil)
+
+		userHandler.MarkNotificationRead(w, newRequest("7"))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("someone else's", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().MarkNotificationRead(gomock.Any(), "alice", 8).
+			Return(fmt.Errorf("postgres mark notification read: %w", utils.ErrNoNotification))
+
+		userHandler.MarkNotificationRead(w, newRequest("8"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("bad id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.MarkNotificationRead(w, newRequest("abc"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/notifications/read", nil)
+
+		mockUserService.EXPECT().MarkAllNotificationsRead(gomock.Any(), "alice").Return(3, nil)
+
+		userHandler.MarkAllNotificationsRead(w, req.WithContext(context.WithValue(req.Context(), "user", "alice")))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.MarkReadResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, 3, body.Marked)
+	})
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(method, body string) *http.Request {
+		req := httptest.NewRequest(method, "/notifications/preferences", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	preferences := entities.NotificationPreferences{
+		entities.NotificationCoinsReceived: false,
+		entities.NotificationItemRefunded:  true,
+	}
+
+	t.Run("get", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().GetNotificationPreferences(gomock.Any(), "alice").Return(preferences, nil)
+
+		userHandler.GetNotificationPreferences(w, newRequest(http.MethodGet, ""))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.NotificationPreferences
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, preferences, body)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			SetNotificationPreferences(gomock.Any(), "alice", entities.NotificationPreferences{entities.NotificationCoinsReceived: false}).
+			Return(preferences, nil)
+
+		userHandler.SetNotificationPreferences(w, newRequest(http.MethodPut, `{"coins_received":false}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("unknown kind", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			SetNotificationPreferences(gomock.Any(), "alice", gomock.Any()).
+			Return(nil, fmt.Errorf("spam: %w", utils.ErrBadNotificationKind))
+
+		userHandler.SetNotificationPreferences(w, newRequest(http.MethodPut, `{"spam":false}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.SetNotificationPreferences(w, newRequest(http.MethodPut, `{"coins_received":"no"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestEmailSettings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(method, body string) *http.Request {
+		req := httptest.NewRequest(method, "/notifications/email", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	settings := &entities.EmailSettings{Address: "alice@example.com", Mode: entities.EmailModeDigest}
+
+	t.Run("get", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().GetEmailSettings(gomock.Any(), "alice").Return(settings, nil)
+
+		userHandler.GetEmailSettings(w, newRequest(http.MethodGet, ""))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.EmailSettings
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *settings, body)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().SetEmailSettings(gomock.Any(), "alice", *settings).Return(settings, nil)
+
+		userHandler.SetEmailSettings(w, newRequest(http.MethodPut, `{"address":"alice@example.com","mode":"digest"}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("bad mode", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().SetEmailSettings(gomock.Any(), "alice", gomock.Any()).Return(nil, utils.ErrBadEmailMode)
+
+		userHandler.SetEmailSettings(w, newRequest(http.MethodPut, `{"address":"alice@example.com","mode":"weekly"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad address", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().SetEmailSettings(gomock.Any(), "alice", gomock.Any()).Return(nil, utils.ErrBadEmail)
+
+		userHandler.SetEmailSettings(w, newRequest(http.MethodPut, `{"address":"alice","mode":"instant"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetEmailSettings(w, httptest.NewRequest(http.MethodGet, "/notifications/email", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
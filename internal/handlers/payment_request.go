@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// RequestPayment asks another user for coins. Body: {"fromUser": "...",
+// "amount": n, "memo": "...", "category": "..."}.
+func (u *UserHandler) RequestPayment(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		FromUser string `json:"fromUser"`
+		Amount   int    `json:"amount"`
+		Memo     string `json:"memo"`
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	request, err := u.UserService.RequestPayment(r.Context(), userName, data.FromUser, data.Amount, data.Memo, data.Category)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, paymentRequestErrorStatus(err))
+		return
+	}
+
+	writePaymentRequest(w, http.StatusCreated, request)
+}
+
+// GetPaymentRequests lists the caller's payment requests. Query parameters:
+// direction (incoming for requests to pay, outgoing for requests made),
+// status, limit and offset.
+func (u *UserHandler) GetPaymentRequests(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	filter := entities.PaymentRequestFilter{
+		Direction: query.Get("direction"),
+		Status:    query.Get("status"),
+	}
+	page, err := u.UserService.GetPaymentRequests(r.Context(), userName, filter, limit, offset)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, paymentRequestErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func (u *UserHandler) AcceptPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	u.answerPaymentRequest(w, r, u.UserService.AcceptPaymentRequest)
+}
+
+func (u *UserHandler) DeclinePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	u.answerPaymentRequest(w, r, u.UserService.DeclinePaymentRequest)
+}
+
+func (u *UserHandler) answerPaymentRequest(w http.ResponseWriter, r *http.Request, answer func(ctx context.Context, userName string, requestID int) (*entities.PaymentRequest, error)) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoPaymentRequest, http.StatusNotFound)
+		return
+	}
+
+	request, err := answer(r.Context(), userName, requestID)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, paymentRequestErrorStatus(err))
+		return
+	}
+
+	writePaymentRequest(w, http.StatusOK, request)
+}
+
+func (u *UserHandler) CancelPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoPaymentRequest, http.StatusNotFound)
+		return
+	}
+
+	if err := u.UserService.CancelPaymentRequest(r.Context(), userName, requestID); err != nil {
+		utils.WriteErrorResponse(w, err, paymentRequestErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func writePaymentRequest(w http.ResponseWriter, status int, request *entities.PaymentRequest) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(request); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func paymentRequestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadAmount),
+		errors.Is(err, utils.ErrMemoTooLong),
+		errors.Is(err, utils.ErrBadCategory),
+		errors.Is(err, utils.ErrSelfRequest),
+		errors.Is(err, utils.ErrBadRequestDirection),
+		errors.Is(err, utils.ErrBadStatus),
+		errors.Is(err, utils.ErrBadPage),
+		errors.Is(err, utils.ErrNoUser),
+		errors.Is(err, utils.ErrNotEnoughBalance),
+		errors.Is(err, utils.ErrPaymentRequestClosed):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrTransferLimit):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, utils.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, utils.ErrNoPaymentRequest):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestPayment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/paymentRequests", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		request := &entities.PaymentRequest{ID: 3, Requester: "alice", Payer: "bob", Amount: 15, Memo: "lunch", Status: entities.PaymentRequestPending}
+
+		mockUserService.EXPECT().
+			RequestPayment(gomock.Any(), "alice", "bob", 15, "lunch", "").
+			Return(request, nil)
+
+		userHandler.RequestPayment(w, newRequest(`{"fromUser":"bob","amount":15,"memo":"lunch"}`))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		var body entities.PaymentRequest
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *request, body)
+	})
+
+	t.Run("self request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			RequestPayment(gomock.Any(), "alice", "alice", 15, "", "").
+			Return(nil, utils.ErrSelfRequest)
+
+		userHandler.RequestPayment(w, newRequest(`{"fromUser":"alice","amount":15}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.RequestPayment(w, httptest.NewRequest(http.MethodPost, "/paymentRequests", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestGetPaymentRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		filter := entities.PaymentRequestFilter{Direction: entities.PaymentRequestIncoming, Status: entities.PaymentRequestPending}
+		page := &entities.PaymentRequestsPage{Requests: []*entities.PaymentRequest{{ID: 3, Requester: "bob", Payer: "alice"}}, Total: 1, Limit: 5}
+
+		mockUserService.EXPECT().
+			GetPaymentRequests(gomock.Any(), "alice", filter, 5, 0).
+			Return(page, nil)
+
+		userHandler.GetPaymentRequests(w, newRequest("/paymentRequests?direction=incoming&status=pending&limit=5"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.PaymentRequestsPage
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *page, body)
+	})
+
+	t.Run("bad status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			GetPaymentRequests(gomock.Any(), "alice", entities.PaymentRequestFilter{Status: "paid"}, 0, 0).
+			Return(nil, utils.ErrBadStatus)
+
+		userHandler.GetPaymentRequests(w, newRequest("/paymentRequests?status=paid"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestAnswerPaymentRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(method, id string) *http.Request {
+		req := httptest.NewRequest(method, "/paymentRequests/"+id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		return req.WithContext(context.WithValue(req.Context(), "user", "bob"))
+	}
+
+	t.Run("accept", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		request := &entities.PaymentRequest{ID: 3, Requester: "alice", Payer: "bob", Amount: 15, Status: entities.PaymentRequestAccepted}
+
+		mockUserService.EXPECT().AcceptPaymentRequest(gomock.Any(), "bob", 3).Return(request, nil)
+
+		userHandler.AcceptPaymentRequest(w, newRequest(http.MethodPost, "3"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.PaymentRequest
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *request, body)
+	})
+
+	t.Run("accept without enough balance", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().AcceptPaymentRequest(gomock.Any(), "bob", 3).Return(nil, utils.ErrNotEnoughBalance)
+
+		userHandler.AcceptPaymentRequest(w, newRequest(http.MethodPost, "3"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("decline someone else's request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().DeclinePaymentRequest(gomock.Any(), "bob", 4).Return(nil, utils.ErrForbidden)
+
+		userHandler.DeclinePaymentRequest(w, newRequest(http.MethodPost, "4"))
+
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+
+	t.Run("bad id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.DeclinePaymentRequest(w, newRequest(http.MethodPost, "abc"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().CancelPaymentRequest(gomock.Any(), "bob", 5).Return(nil)
+
+		userHandler.CancelPaymentRequest(w, newRequest(http.MethodDelete, "5"))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("cancel closed request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().CancelPaymentRequest(gomock.Any(), "bob", 5).Return(utils.ErrPaymentRequestClosed)
+
+		userHandler.CancelPaymentRequest(w, newRequest(http.MethodDelete, "5"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
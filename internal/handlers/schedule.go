@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// CreateSchedule schedules a coin transfer. Body: {"toUser": "...",
+// "amount": n, "memo": "...", "category": "...", "recurrence": "once",
+// "runAt": RFC 3339 time}; recurrence may also be weekly or monthly.
+func (u *UserHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	var req entities.ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := u.UserService.CreateSchedule(r.Context(), userName, req)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, scheduleErrorStatus(err))
+		return
+	}
+
+	writeSchedule(w, http.StatusCreated, schedule)
+}
+
+func (u *UserHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	schedules, err := u.UserService.GetSchedules(r.Context(), userName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, scheduleErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(schedules); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+// GetSchedule returns a scheduled transfer with its latest runs.
+func (u *UserHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoSchedule, http.StatusNotFound)
+		return
+	}
+
+	schedule, err := u.UserService.GetSchedule(r.Context(), userName, scheduleID)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, scheduleErrorStatus(err))
+		return
+	}
+
+	writeSchedule(w, http.StatusOK, schedule)
+}
+
+// UpdateSchedule replaces the settings of an active scheduled transfer. The
+// body is the same as for CreateSchedule.
+func (u *UserHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoSchedule, http.StatusNotFound)
+		return
+	}
+
+	var req entities.ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := u.UserService.UpdateSchedule(r.Context(), userName, scheduleID, req)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, scheduleErrorStatus(err))
+		return
+	}
+
+	writeSchedule(w, http.StatusOK, schedule)
+}
+
+func (u *UserHandler) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoSchedule, http.StatusNotFound)
+		return
+	}
+
+	if err := u.UserService.CancelSchedule(r.Context(), userName, scheduleID); err != nil {
+		utils.WriteErrorResponse(w, err, scheduleErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeSchedule(w http.ResponseWriter, status int, schedule *entities.ScheduledTransfer) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(schedule); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func scheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadAmount),
+		errors.Is(err, utils.ErrMemoTooLong),
+		errors.Is(err, utils.ErrBadCategory),
+		errors.Is(err, utils.ErrBadRecurrence),
+		errors.Is(err, utils.ErrBadRunTime),
+		errors.Is(err, utils.ErrSelfSend),
+		errors.Is(err, utils.ErrNoUser),
+		errors.Is(err, utils.ErrScheduleClosed):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, utils.ErrNoSchedule):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/schedules", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	runAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		schedule := &entities.ScheduledTransfer{
+			ID:         4,
+			FromUser:   "alice",
+			ToUser:     "bob",
+			Amount:     25,
+			Category:   entities.TransferCategoryGift,
+			Recurrence: entities.RecurrenceWeekly,
+			NextRunAt:  runAt,
+			Status:     entities.ScheduleActive,
+			CreatedAt:  runAt.Add(-time.Hour),
+		}
+
+		mockUserService.EXPECT().
+			CreateSchedule(gomock.Any(), "alice", entities.ScheduleRequest{ToUser: "bob", Amount: 25, Category: "gift", Recurrence: "weekly", RunAt: runAt}).
+			Return(schedule, nil)
+
+		userHandler.CreateSchedule(w, newRequest(`{"toUser":"bob","amount":25,"category":"gift","recurrence":"weekly","runAt":"2025-03-01T12:00:00Z"}`))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		var body entities.ScheduledTransfer
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *schedule, body)
+	})
+
+	t.Run("run time in the past", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			CreateSchedule(gomock.Any(), "alice", gomock.Any()).
+			Return(nil, utils.ErrBadRunTime)
+
+		userHandler.CreateSchedule(w, newRequest(`{"toUser":"bob","amount":25,"runAt":"2000-01-01T00:00:00Z"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad run time", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.CreateSchedule(w, newRequest(`{"toUser":"bob","amount":25,"runAt":"tomorrow"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.CreateSchedule(w, httptest.NewRequest(http.MethodPost, "/schedules", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestGetSchedules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/schedules", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	w := httptest.NewRecorder()
+
+	mockUserService.EXPECT().
+		GetSchedules(gomock.Any(), "alice").
+		Return([]*entities.ScheduledTransfer{{ID: 4, ToUser: "bob"}}, nil)
+
+	userHandler.GetSchedules(w, req)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body []*entities.ScheduledTransfer
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body, 1)
+}
+
+func TestGetSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(id string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/schedules/"+id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		schedule := &entities.ScheduledTransfer{
+			ID:   4,
+			Runs: []*entities.ScheduledTransferRun{{ID: 1, Status: entities.ScheduleRunFailed, Error: "Not enough balance"}},
+		}
+
+		mockUserService.EXPECT().GetSchedule(gomock.Any(), "alice", 4).Return(schedule, nil)
+
+		userHandler.GetSchedule(w, newRequest("4"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.ScheduledTransfer
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, schedule.Runs, body.Runs)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().GetSchedule(gomock.Any(), "alice", 5).Return(nil, utils.ErrForbidden)
+
+		userHandler.GetSchedule(w, newRequest("5"))
+
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+
+	t.Run("bad id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetSchedule(w, newRequest("abc"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestUpdateSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, "/schedules/4", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "4"})
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			UpdateSchedule(gomock.Any(), "alice", 4, entities.ScheduleRequest{ToUser: "carol", Amount: 40, RunAt: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)}).
+			Return(&entities.ScheduledTransfer{ID: 4, ToUser: "carol", Amount: 40}, nil)
+
+		userHandler.UpdateSchedule(w, newRequest(`{"toUser":"carol","amount":40,"runAt":"2025-04-01T00:00:00Z"}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			UpdateSchedule(gomock.Any(), "alice", 4, gomock.Any()).
+			Return(nil, utils.ErrScheduleClosed)
+
+		userHandler.UpdateSchedule(w, newRequest(`{"toUser":"carol","amount":40,"runAt":"2025-04-01T00:00:00Z"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+func TestCancelSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(id string) *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, "/schedules/"+id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().CancelSchedule(gomock.Any(), "alice", 4).Return(nil)
+
+		userHandler.CancelSchedule(w, newRequest("4"))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().CancelSchedule(gomock.Any(), "alice", 9).Return(utils.ErrNoSchedule)
+
+		userHandler.CancelSchedule(w, newRequest("9"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
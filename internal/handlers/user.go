@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"regexp"
+	"strconv"
 
 	"github.com/KonstantinGalanin/itemStore/internal/entities"
 	"github.com/KonstantinGalanin/itemStore/internal/utils"
@@ -31,14 +36,53 @@ func Validate(username, password string) error {
 
 type JwtService interface {
 	CreateToken(userItem *entities.User) ([]byte, error)
+	CreateStreamToken(userItem *entities.User) ([]byte, error)
 }
 
 //go:generate mockgen -source=user.go -destination=../service/user_service_mock.go -package=service
 type UserService interface {
-	BuyItem(userName string, itemName string) error
-	SendCoin(fromUser, toUser string, amount int) error
-	GetInfo(userName string) (*entities.InfoResponse, error)
-	Auth(userName, password string) (*entities.User, error)
+	BuyItem(ctx context.Context, userName string, itemName string) error
+	SendCoin(ctx context.Context, fromUser, toUser string, amount int, memo, category string) error
+	BatchSendCoin(ctx context.Context, fromUser string, transfers []entities.BatchTransfer, memo, category string) (*entities.BatchSendResult, error)
+	GetInfo(ctx context.Context, userName string) (*entities.InfoResponse, error)
+	GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error)
+	Auth(ctx context.Context, userName, password string) (*entities.User, error)
+	RefundItem(ctx context.Context, userName, itemName string, quantity int) (*entities.RefundOperation, error)
+	GetOrders(ctx context.Context, userName, itemName string, limit, offset int) (*entities.OrdersPage, error)
+	GiftItem(ctx context.Context, fromUser, toUser, itemName, message string) error
+	TransferItem(ctx context.Context, fromUser, toUser, itemName string, quantity int) error
+	CreateListing(ctx context.Context, userName, itemName string, quantity, price int) (*entities.Listing, error)
+	GetListings(ctx context.Context, itemName string, limit, offset int) (*entities.ListingsPage, error)
+	BuyListing(ctx context.Context, userName string, listingID int) (*entities.Listing, error)
+	CancelListing(ctx context.Context, userName string, listingID int) error
+	SearchHistory(ctx context.Context, userName string, filter entities.TransferFilter, limit, offset int) (*entities.TransfersPage, error)
+	CreateSchedule(ctx context.Context, userName string, req entities.ScheduleRequest) (*entities.ScheduledTransfer, error)
+	GetSchedules(ctx context.Context, userName string) ([]*entities.ScheduledTransfer, error)
+	GetSchedule(ctx context.Context, userName string, scheduleID int) (*entities.ScheduledTransfer, error)
+	UpdateSchedule(ctx context.Context, userName string, scheduleID int, req entities.ScheduleRequest) (*entities.ScheduledTransfer, error)
+	CancelSchedule(ctx context.Context, userName string, scheduleID int) error
+	RequestPayment(ctx context.Context, requester, payer string, amount int, memo, category string) (*entities.PaymentRequest, error)
+	GetPaymentRequests(ctx context.Context, userName string, filter entities.PaymentRequestFilter, limit, offset int) (*entities.PaymentRequestsPage, error)
+	AcceptPaymentRequest(ctx context.Context, userName string, requestID int) (*entities.PaymentRequest, error)
+	DeclinePaymentRequest(ctx context.Context, userName string, requestID int) (*entities.PaymentRequest, error)
+	CancelPaymentRequest(ctx context.Context, userName string, requestID int) error
+	CreateWebhook(ctx context.Context, userName string, req entities.WebhookEndpointRequest) (*entities.WebhookEndpoint, error)
+	GetWebhooks(ctx context.Context, userName string) ([]*entities.WebhookEndpoint, error)
+	DeleteWebhook(ctx context.Context, userName string, endpointID int) error
+	GetWebhookDeliveries(ctx context.Context, userName string, endpointID int, status string, limit, offset int) (*entities.WebhookDeliveriesPage, error)
+	GetWebhookDelivery(ctx context.Context, userName string, endpointID, deliveryID int) (*entities.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, userName string, endpointID, deliveryID int) (*entities.WebhookDelivery, error)
+	SendTestWebhook(ctx context.Context, userName string, endpointID int) (*entities.WebhookDelivery, error)
+	SubscribeEvents(ctx context.Context, userName string) (<-chan struct{}, func(), error)
+	GetUserEvents(ctx context.Context, userName string, afterID int) ([]*entities.UserEvent, error)
+	LastUserEventID(ctx context.Context, userName string) (int, error)
+	GetNotifications(ctx context.Context, userName string, unreadOnly bool, limit, offset int) (*entities.NotificationsPage, error)
+	MarkNotificationRead(ctx context.Context, userName string, notificationID int) error
+	MarkAllNotificationsRead(ctx context.Context, userName string) (int, error)
+	GetNotificationPreferences(ctx context.Context, userName string) (entities.NotificationPreferences, error)
+	SetNotificationPreferences(ctx context.Context, userName string, preferences entities.NotificationPreferences) (entities.NotificationPreferences, error)
+	GetEmailSettings(ctx context.Context, userName string) (*entities.EmailSettings, error)
+	SetEmailSettings(ctx context.Context, userName string, settings entities.EmailSettings) (*entities.EmailSettings, error)
 }
 
 type UserHandler struct {
@@ -68,8 +112,14 @@ func (u *UserHandler) Auth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := u.UserService.Auth(data.Username, data.Password)
+	user, err := u.UserService.Auth(r.Context(), data.Username, data.Password)
 	if err != nil {
+		var blocked *utils.LoginBlockedError
+		if errors.As(err, &blocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			utils.WriteErrorResponse(w, blocked, http.StatusTooManyRequests)
+			return
+		}
 		utils.WriteErrorResponse(w, err, http.StatusUnauthorized)
 		return 
 	}
@@ -96,8 +146,10 @@ func (u *UserHandler) SendCoin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var data struct {
-		ToUser string `json:"toUser"`
-		Amount int    `json:"amount"`
+		ToUser   string `json:"toUser"`
+		Amount   int    `json:"amount"`
+		Memo     string `json:"memo"`
+		Category string `json:"category"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&data)
@@ -106,14 +158,76 @@ func (u *UserHandler) SendCoin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := u.UserService.SendCoin(userName, data.ToUser, data.Amount); err != nil {
-		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	if err := u.UserService.SendCoin(r.Context(), userName, data.ToUser, data.Amount, data.Memo, data.Category); err != nil {
+		utils.WriteErrorResponse(w, err, sendCoinErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func sendCoinErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadAmount),
+		errors.Is(err, utils.ErrMemoTooLong),
+		errors.Is(err, utils.ErrBadCategory):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrTransferLimit):
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusInternalServerError
+}
+
+// BatchSendCoin sends coins to several users at once. The body lists the
+// transfers as {toUser, amount} pairs with an optional memo and category for
+// all of them; the batch succeeds or fails as a whole.
+func (u *UserHandler) BatchSendCoin(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		Transfers []entities.BatchTransfer `json:"transfers"`
+		Memo      string                   `json:"memo"`
+		Category  string                   `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
 		return
 	}
 
+	result, err := u.UserService.BatchSendCoin(r.Context(), userName, data.Transfers, data.Memo, data.Category)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, batchSendErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func batchSendErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrEmptyBatch),
+		errors.Is(err, utils.ErrBatchTooLarge),
+		errors.Is(err, utils.ErrSelfSend),
+		errors.Is(err, utils.ErrDuplicateRecipient),
+		errors.Is(err, utils.ErrNoUser),
+		errors.Is(err, utils.ErrNotEnoughBalance):
+		return http.StatusBadRequest
+	}
+
+	return sendCoinErrorStatus(err)
 }
 
+// BuyItem buys an item for the caller. A body with toUser buys it as a gift
+// for that user instead, with an optional message.
 func (u *UserHandler) BuyItem(w http.ResponseWriter, r *http.Request) {
 	userName, ok := r.Context().Value("user").(string)
 	if !ok {
@@ -127,7 +241,26 @@ func (u *UserHandler) BuyItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := u.UserService.BuyItem(userName, itemName); err != nil {
+	var gift struct {
+		ToUser  string `json:"toUser"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&gift); err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if gift.ToUser != "" {
+		if err := u.UserService.GiftItem(r.Context(), userName, gift.ToUser, itemName, gift.Message); err != nil {
+			utils.WriteErrorResponse(w, err, giftErrorStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := u.UserService.BuyItem(r.Context(), userName, itemName); err != nil {
 		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -135,6 +268,18 @@ func (u *UserHandler) BuyItem(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func giftErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrSelfGift),
+		errors.Is(err, utils.ErrMessageTooLong),
+		errors.Is(err, utils.ErrNoUser),
+		errors.Is(err, utils.ErrNoItem):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
 func (u *UserHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
 	userName, ok := r.Context().Value("user").(string)
 	if !ok {
@@ -142,7 +287,7 @@ func (u *UserHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	info, err := u.UserService.GetInfo(userName)
+	info, err := u.UserService.GetInfo(r.Context(), userName)
 	if err != nil {
 		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
 		return
@@ -154,3 +299,158 @@ func (u *UserHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
 		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
 	}
 }
+
+// TransferItem hands units of an owned item to another user. Body:
+// {"toUser": "...", "quantity": n}; quantity defaults to 1.
+func (u *UserHandler) TransferItem(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	itemName, exists := mux.Vars(r)["item"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Item not exists"), http.StatusBadRequest)
+		return
+	}
+
+	data := struct {
+		ToUser   string `json:"toUser"`
+		Quantity int    `json:"quantity"`
+	}{
+		Quantity: 1,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := u.UserService.TransferItem(r.Context(), userName, data.ToUser, itemName, data.Quantity); err != nil {
+		utils.WriteErrorResponse(w, err, transferErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func transferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadQuantity),
+		errors.Is(err, utils.ErrSelfTransfer),
+		errors.Is(err, utils.ErrNotEnoughItems),
+		errors.Is(err, utils.ErrNoUser),
+		errors.Is(err, utils.ErrNoItem):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Refund returns units of an item bought within the refund window. The body
+// is optional and defaults to a single unit.
+func (u *UserHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	itemName, exists := mux.Vars(r)["item"]
+	if !exists {
+		utils.WriteErrorResponse(w, fmt.Errorf("Item not exists"), http.StatusBadRequest)
+		return
+	}
+
+	quantity, err := decodeRefundQuantity(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	refund, err := u.UserService.RefundItem(r.Context(), userName, itemName, quantity)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, refundErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(refund); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func decodeRefundQuantity(r *http.Request) (int, error) {
+	data := struct {
+		Quantity int `json:"quantity"`
+	}{
+		Quantity: 1,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+
+	return data.Quantity, nil
+}
+
+func refundErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadQuantity),
+		errors.Is(err, utils.ErrNothingToRefund),
+		errors.Is(err, utils.ErrNotEnoughItems),
+		errors.Is(err, utils.ErrNoItem):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrNoUser):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// GetOrders lists the caller's purchases. Query parameters: limit, offset
+// and item to only show orders of one item.
+func (u *UserHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	page, err := u.UserService.GetOrders(r.Context(), userName, query.Get("item"), limit, offset)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, utils.ErrBadPage) {
+			status = http.StatusBadRequest
+		}
+		utils.WriteErrorResponse(w, err, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+// queryInt parses an optional integer query parameter, 0 when absent.
+func queryInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(value)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/KonstantinGalanin/itemStore/internal/entities"
 	"github.com/KonstantinGalanin/itemStore/internal/service"
@@ -111,7 +112,7 @@ func TestAuth(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		mockUserService.EXPECT().
-			Auth("testuser", "wrongpass").
+			Auth(gomock.Any(), "testuser", "wrongpass").
 			Return(nil, errors.New("invalid credentials"))
 
 		userHandler.Auth(w, req)
@@ -120,6 +121,24 @@ func TestAuth(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
 
+	t.Run("login blocked", func(t *testing.T) {
+		data := map[string]string{"username": "testuser", "password": "wrongpass"}
+		body, _ := json.Marshal(data)
+
+		req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			Auth(gomock.Any(), "testuser", "wrongpass").
+			Return(nil, &utils.LoginBlockedError{RetryAfter: 1500 * time.Millisecond})
+
+		userHandler.Auth(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+	})
+
 	t.Run("success", func(t *testing.T) {
 		data := map[string]string{"username": "testuser", "password": "correctpass"}
 		body, _ := json.Marshal(data)
@@ -131,7 +150,7 @@ func TestAuth(t *testing.T) {
 		mockToken := []byte(`{"token":"mocked_jwt"}`)
 
 		mockUserService.EXPECT().
-			Auth("testuser", "correctpass").
+			Auth(gomock.Any(), "testuser", "correctpass").
 			Return(mockUser, nil)
 
 		mockJwtService.EXPECT().
@@ -158,7 +177,7 @@ func TestAuth(t *testing.T) {
 		mockUser := &entities.User{ID: 1, Username: "testuser"}
 
 		mockUserService.EXPECT().
-			Auth("testuser", "correctpass").
+			Auth(gomock.Any(), "testuser", "correctpass").
 			Return(mockUser, nil)
 
 		mockJwtService.EXPECT().
@@ -217,7 +236,7 @@ func TestSendCoin(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		mockUserService.EXPECT().
-			SendCoin("alice", "bob", 100).
+			SendCoin(gomock.Any(), "alice", "bob", 100, "", "").
 			Return(errors.New("transaction failed"))
 
 		userHandler.SendCoin(w, req)
@@ -239,7 +258,7 @@ func TestSendCoin(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		mockUserService.EXPECT().
-			SendCoin("alice", "bob", 50).
+			SendCoin(gomock.Any(), "alice", "bob", 50, "", "").
 			Return(nil)
 
 		userHandler.SendCoin(w, req)
@@ -247,6 +266,117 @@ func TestSendCoin(t *testing.T) {
 		resp := w.Result()
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 	})
+
+	t.Run("memo too long", func(t *testing.T) {
+		body := `{"toUser":"bob","amount":5,"memo":"lunch","category":"reimbursement"}`
+
+		req := httptest.NewRequest(http.MethodPost, "/sendCoin", bytes.NewBufferString(body))
+		ctx := context.WithValue(req.Context(), "user", "alice")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			SendCoin(gomock.Any(), "alice", "bob", 5, "lunch", "reimbursement").
+			Return(utils.ErrMemoTooLong)
+
+		userHandler.SendCoin(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("transfer limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/sendCoin", bytes.NewBufferString(`{"toUser":"bob","amount":500}`))
+		ctx := context.WithValue(req.Context(), "user", "alice")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			SendCoin(gomock.Any(), "alice", "bob", 500, "", "").
+			Return(&utils.TransferLimitError{Limit: entities.LimitDailyAmount, Max: 300})
+
+		userHandler.SendCoin(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		var body entities.ErrorResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "daily transfer limit of 300 coins exceeded", body.Errors)
+	})
+}
+
+func TestBatchSendCoin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/sendCoin/batch", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		transfers := []entities.BatchTransfer{{ToUser: "bob", Amount: 30}, {ToUser: "carol", Amount: 20}}
+		result := &entities.BatchSendResult{Transfers: transfers, Total: 50, Balance: 950}
+
+		mockUserService.EXPECT().
+			BatchSendCoin(gomock.Any(), "alice", transfers, "bonus", "thanks").
+			Return(result, nil)
+
+		userHandler.BatchSendCoin(w, newRequest(`{"transfers":[{"toUser":"bob","amount":30},{"toUser":"carol","amount":20}],"memo":"bonus","category":"thanks"}`))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.BatchSendResult
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *result, body)
+	})
+
+	t.Run("invalid batch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			BatchSendCoin(gomock.Any(), "alice", gomock.Any(), "", "").
+			Return(nil, utils.ErrSelfSend)
+
+		userHandler.BatchSendCoin(w, newRequest(`{"transfers":[{"toUser":"alice","amount":30}]}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			BatchSendCoin(gomock.Any(), "alice", gomock.Any(), "", "").
+			Return(nil, errors.New("db down"))
+
+		userHandler.BatchSendCoin(w, newRequest(`{"transfers":[{"toUser":"bob","amount":30}]}`))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("json decode error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.BatchSendCoin(w, newRequest(`{invalid json}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.BatchSendCoin(w, httptest.NewRequest(http.MethodPost, "/sendCoin/batch", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
 }
 
 func TestUserHandler_BuyItem(t *testing.T) {
@@ -292,7 +422,7 @@ func TestUserHandler_BuyItem(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"item": "cup"})
 
 		mockUserService.EXPECT().
-			BuyItem("alice", "cup").
+			BuyItem(gomock.Any(), "alice", "cup").
 			Return(errors.New("not enough coins"))
 
 		userHandler.BuyItem(w, req)
@@ -310,7 +440,26 @@ func TestUserHandler_BuyItem(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"item": "cup"})
 
 		mockUserService.EXPECT().
-			BuyItem("alice", "cup").
+			BuyItem(gomock.Any(), "alice", "cup").
+			Return(nil)
+
+		userHandler.BuyItem(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("gift", func(t *testing.T) {
+		body := bytes.NewBufferString(`{"toUser":"bob","message":"happy birthday"}`)
+		req := httptest.NewRequest(http.MethodPost, "/buy/cup", body)
+		ctx := context.WithValue(req.Context(), "user", "alice")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		req = mux.SetURLVars(req, map[string]string{"item": "cup"})
+
+		mockUserService.EXPECT().
+			GiftItem(gomock.Any(), "alice", "bob", "cup", "happy birthday").
 			Return(nil)
 
 		userHandler.BuyItem(w, req)
@@ -318,6 +467,112 @@ func TestUserHandler_BuyItem(t *testing.T) {
 		resp := w.Result()
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 	})
+
+	t.Run("gift to yourself", func(t *testing.T) {
+		body := bytes.NewBufferString(`{"toUser":"alice"}`)
+		req := httptest.NewRequest(http.MethodPost, "/buy/cup", body)
+		ctx := context.WithValue(req.Context(), "user", "alice")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		req = mux.SetURLVars(req, map[string]string{"item": "cup"})
+
+		mockUserService.EXPECT().
+			GiftItem(gomock.Any(), "alice", "alice", "cup", "").
+			Return(utils.ErrSelfGift)
+
+		userHandler.BuyItem(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("bad body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/buy/cup", bytes.NewBufferString(`{"toUser":`))
+		ctx := context.WithValue(req.Context(), "user", "alice")
+		req = req.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		req = mux.SetURLVars(req, map[string]string{"item": "cup"})
+
+		userHandler.BuyItem(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestTransferItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/transfer/cup", bytes.NewBufferString(body))
+		ctx := context.WithValue(req.Context(), "user", "alice")
+		return mux.SetURLVars(req.WithContext(ctx), map[string]string{"item": "cup"})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			TransferItem(gomock.Any(), "alice", "bob", "cup", 2).
+			Return(nil)
+
+		userHandler.TransferItem(w, newRequest(`{"toUser":"bob","quantity":2}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("default quantity", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			TransferItem(gomock.Any(), "alice", "bob", "cup", 1).
+			Return(nil)
+
+		userHandler.TransferItem(w, newRequest(`{"toUser":"bob"}`))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("not enough items", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			TransferItem(gomock.Any(), "alice", "bob", "cup", 3).
+			Return(utils.ErrNotEnoughItems)
+
+		userHandler.TransferItem(w, newRequest(`{"toUser":"bob","quantity":3}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			TransferItem(gomock.Any(), "alice", "bob", "cup", 1).
+			Return(errors.New("db is down"))
+
+		userHandler.TransferItem(w, newRequest(`{"toUser":"bob"}`))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("missing body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.TransferItem(w, newRequest(``))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
 }
 
 func TestGetInfo(t *testing.T) {
@@ -340,7 +595,7 @@ func TestGetInfo(t *testing.T) {
 				Sent:     make([]*entities.SentOperation, 0),
 			},
 		}
-		mockUserService.EXPECT().GetInfo(userName).Return(userInfo, nil)
+		mockUserService.EXPECT().GetInfo(gomock.Any(), userName).Return(userInfo, nil)
 
 		req := httptest.NewRequest(http.MethodGet, "/info", nil)
 		req = req.WithContext(context.WithValue(req.Context(), "user", userName))
@@ -355,3 +610,136 @@ func TestGetInfo(t *testing.T) {
 	})
 
 }
+
+func TestRefund(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+	userHandler := NewUserHandler(mockUserService, service.NewMockJwtService(ctrl))
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/refund/cup", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+		return mux.SetURLVars(req, map[string]string{"item": "cup"})
+	}
+
+	t.Run("user context error", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/refund/cup", nil)
+		w := httptest.NewRecorder()
+
+		userHandler.Refund(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.Refund(w, newRequest("{"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("defaults to one unit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		refund := &entities.RefundOperation{Item: "cup", Quantity: 1, Amount: 20}
+
+		mockUserService.EXPECT().RefundItem(gomock.Any(), "alice", "cup", 1).Return(refund, nil)
+
+		userHandler.Refund(w, newRequest(""))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		var got entities.RefundOperation
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, *refund, got)
+	})
+
+	t.Run("nothing to refund", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().RefundItem(gomock.Any(), "alice", "cup", 3).Return(nil, utils.ErrNothingToRefund)
+
+		userHandler.Refund(w, newRequest(`{"quantity": 3}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().RefundItem(gomock.Any(), "alice", "cup", 1).Return(nil, errors.New("db error"))
+
+		userHandler.Refund(w, newRequest(`{"quantity": 1}`))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
+
+func TestGetOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+	userHandler := NewUserHandler(mockUserService, service.NewMockJwtService(ctrl))
+
+	newRequest := func(target string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("user context error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetOrders(w, httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetOrders(w, newRequest("/api/orders?limit=ten"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().GetOrders(gomock.Any(), "alice", "", 0, -5).Return(nil, utils.ErrBadPage)
+
+		userHandler.GetOrders(w, newRequest("/api/orders?offset=-5"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		page := &entities.OrdersPage{
+			Orders: []*entities.Order{{ID: 3, ItemType: "hoody", Quantity: 1, UnitPrice: 300, Total: 300, CreatedAt: time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)}},
+			Total:  4,
+			Limit:  1,
+			Offset: 2,
+		}
+
+		mockUserService.EXPECT().GetOrders(gomock.Any(), "alice", "hoody", 1, 2).Return(page, nil)
+
+		userHandler.GetOrders(w, newRequest("/api/orders?item=hoody&limit=1&offset=2"))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		var got entities.OrdersPage
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, *page, got)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().GetOrders(gomock.Any(), "alice", "", 0, 0).Return(nil, errors.New("db error"))
+
+		userHandler.GetOrders(w, newRequest("/api/orders"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// CreateWebhook registers a webhook endpoint. Body: {"url": "...",
+// "eventTypes": ["coin.received", "item.purchased", "balance.low"]}. The
+// response is the only one to include the endpoint's signing secret.
+func (u *UserHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	var req entities.WebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := u.UserService.CreateWebhook(r.Context(), userName, req)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhook(w, http.StatusCreated, endpoint)
+}
+
+func (u *UserHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	endpoints, err := u.UserService.GetWebhooks(r.Context(), userName)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhook(w, http.StatusOK, endpoints)
+}
+
+func (u *UserHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	endpointID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoWebhook, http.StatusNotFound)
+		return
+	}
+
+	if err := u.UserService.DeleteWebhook(r.Context(), userName, endpointID); err != nil {
+		utils.WriteErrorResponse(w, err, webhookErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetWebhookDeliveries lists an endpoint's deliveries, newest first. Query
+// parameters: status (pending, delivered, or dead for the dead-letter
+// list), limit and offset.
+func (u *UserHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	endpointID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoWebhook, http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrBadPage, http.StatusBadRequest)
+		return
+	}
+
+	page, err := u.UserService.GetWebhookDeliveries(r.Context(), userName, endpointID, query.Get("status"), limit, offset)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhook(w, http.StatusOK, page)
+}
+
+// GetWebhookDelivery returns a delivery with its delivery log.
+func (u *UserHandler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	endpointID, deliveryID, err := deliveryVars(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusNotFound)
+		return
+	}
+
+	delivery, err := u.UserService.GetWebhookDelivery(r.Context(), userName, endpointID, deliveryID)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhook(w, http.StatusOK, delivery)
+}
+
+// RedeliverWebhook moves a dead delivery back into the queue.
+func (u *UserHandler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	endpointID, deliveryID, err := deliveryVars(r)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusNotFound)
+		return
+	}
+
+	delivery, err := u.UserService.RedeliverWebhook(r.Context(), userName, endpointID, deliveryID)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhook(w, http.StatusOK, delivery)
+}
+
+// SendTestWebhook sends a webhook.test event to the endpoint right away and
+// returns the delivery, with the outcome of the attempt.
+func (u *UserHandler) SendTestWebhook(w http.ResponseWriter, r *http.Request) {
+	userName, ok := r.Context().Value("user").(string)
+	if !ok {
+		utils.WriteErrorResponse(w, fmt.Errorf("User not found"), http.StatusUnauthorized)
+		return
+	}
+
+	endpointID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteErrorResponse(w, utils.ErrNoWebhook, http.StatusNotFound)
+		return
+	}
+
+	delivery, err := u.UserService.SendTestWebhook(r.Context(), userName, endpointID)
+	if err != nil {
+		utils.WriteErrorResponse(w, err, webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhook(w, http.StatusOK, delivery)
+}
+
+// deliveryVars reads the endpoint and delivery ids from the path.
+func deliveryVars(r *http.Request) (int, int, error) {
+	vars := mux.Vars(r)
+	endpointID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, utils.ErrNoWebhook
+	}
+	deliveryID, err := strconv.Atoi(vars["deliveryId"])
+	if err != nil {
+		return 0, 0, utils.ErrNoWebhookDelivery
+	}
+
+	return endpointID, deliveryID, nil
+}
+
+func writeWebhook(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+	}
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrBadWebhookURL),
+		errors.Is(err, utils.ErrPrivateWebhookURL),
+		errors.Is(err, utils.ErrBadEventType),
+		errors.Is(err, utils.ErrNoEventTypes),
+		errors.Is(err, utils.ErrTooManyWebhooks),
+		errors.Is(err, utils.ErrBadStatus),
+		errors.Is(err, utils.ErrBadPage):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrNotDeadLetter):
+		return http.StatusConflict
+	case errors.Is(err, utils.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, utils.ErrNoWebhook),
+		errors.Is(err, utils.ErrNoWebhookDelivery),
+		errors.Is(err, utils.ErrNoUser):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(body))
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		endpoint := &entities.WebhookEndpoint{
+			ID:         4,
+			URL:        "https://example.com/hooks",
+			EventTypes: []string{entities.WebhookCoinReceived},
+			Secret:     "whsec_abc",
+			CreatedAt:  time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		}
+
+		mockUserService.EXPECT().
+			CreateWebhook(gomock.Any(), "alice", entities.WebhookEndpointRequest{URL: "https://example.com/hooks", EventTypes: []string{"coin.received"}}).
+			Return(endpoint, nil)
+
+		userHandler.CreateWebhook(w, newRequest(`{"url":"https://example.com/hooks","eventTypes":["coin.received"]}`))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		var body entities.WebhookEndpoint
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *endpoint, body)
+	})
+
+	t.Run("bad event type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			CreateWebhook(gomock.Any(), "alice", gomock.Any()).
+			Return(nil, utils.ErrBadEventType)
+
+		userHandler.CreateWebhook(w, newRequest(`{"url":"https://example.com/hooks","eventTypes":["coins.lost"]}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.CreateWebhook(w, newRequest(`{"url":`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.CreateWebhook(w, httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(`{}`)))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestGetWebhookDeliveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(id, query string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/webhooks/"+id+"/deliveries"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("dead letters", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		page := &entities.WebhookDeliveriesPage{
+			Deliveries: []*entities.WebhookDelivery{{
+				ID:            7,
+				EndpointID:    2,
+				EventType:     entities.WebhookCoinReceived,
+				Payload:       json.RawMessage(`{"amount":5}`),
+				Status:        entities.WebhookDeliveryDead,
+				Attempts:      8,
+				LastError:     "unexpected status 500",
+				NextAttemptAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+				CreatedAt:     time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+			}},
+			Total: 1,
+			Limit: 10,
+		}
+
+		mockUserService.EXPECT().
+			GetWebhookDeliveries(gomock.Any(), "alice", 2, "dead", 10, 0).
+			Return(page, nil)
+
+		userHandler.GetWebhookDeliveries(w, newRequest("2", "?status=dead&limit=10"))
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body entities.WebhookDeliveriesPage
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, *page, body)
+	})
+
+	t.Run("someone else's endpoint", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			GetWebhookDeliveries(gomock.Any(), "alice", 3, "", 0, 0).
+			Return(nil, utils.ErrForbidden)
+
+		userHandler.GetWebhookDeliveries(w, newRequest("3", ""))
+
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+
+	t.Run("bad limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetWebhookDeliveries(w, newRequest("2", "?limit=ten"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("bad id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.GetWebhookDeliveries(w, newRequest("two", ""))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	newRequest := func(id, deliveryID string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/"+id+"/deliveries/"+deliveryID+"/redeliver", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id, "deliveryId": deliveryID})
+		return req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			RedeliverWebhook(gomock.Any(), "alice", 2, 7).
+			Return(&entities.WebhookDelivery{ID: 7, EndpointID: 2, Status: entities.WebhookDeliveryPending}, nil)
+
+		userHandler.RedeliverWebhook(w, newRequest("2", "7"))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("not a dead letter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		mockUserService.EXPECT().
+			RedeliverWebhook(gomock.Any(), "alice", 2, 7).
+			Return(nil, utils.ErrNotDeadLetter)
+
+		userHandler.RedeliverWebhook(w, newRequest("2", "7"))
+
+		assert.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	})
+
+	t.Run("bad delivery id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		userHandler.RedeliverWebhook(w, newRequest("2", "seven"))
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func TestSendTestWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserService := service.NewMockUserService(ctrl)
+
+	userHandler := UserHandler{
+		UserService: mockUserService,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/2/test", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "2"})
+	req = req.WithContext(context.WithValue(req.Context(), "user", "alice"))
+	w := httptest.NewRecorder()
+
+	mockUserService.EXPECT().
+		SendTestWebhook(gomock.Any(), "alice", 2).
+		Return(&entities.WebhookDelivery{ID: 9, EndpointID: 2, EventType: entities.WebhookTest, Status: entities.WebhookDeliveryDelivered, Attempts: 1}, nil)
+
+	userHandler.SendTestWebhook(w, req)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body entities.WebhookDelivery
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, entities.WebhookDeliveryDelivered, body.Status)
+}
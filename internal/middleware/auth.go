@@ -10,14 +10,76 @@ import (
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, err := jwt.GetToken(r.Header.Get("Authorization"))
+		claims, err := jwt.ParseToken(r.Header.Get("Authorization"))
 		if err != nil {
 			utils.WriteErrorResponse(w, err, http.StatusUnauthorized)
 			return
 		}
 
 		ctx := r.Context()
-		ctx = context.WithValue(ctx, "user", user)
+		ctx = context.WithValue(ctx, "user", claims.Username)
+		ctx = context.WithValue(ctx, "role", claims.Role)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// StreamAuth authenticates requests for the event stream. Browsers cannot
+// set headers on EventSource and WebSocket requests, so besides the
+// Authorization header it takes a stream token (see
+// jwt.JwtService.CreateStreamToken) from the access_token query parameter.
+// URLs end up in proxy logs and browser history, which is why only that
+// short-lived token is accepted there. The parameter is removed from the
+// request before it is passed on.
+func StreamAuth(next http.Handler) http.Handler {
+	headerAuth := AuthMiddleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !query.Has("access_token") {
+			headerAuth.ServeHTTP(w, r)
+			return
+		}
+
+		token := query.Get("access_token")
+		query.Del("access_token")
+		url := *r.URL
+		url.RawQuery = query.Encode()
+		r = r.Clone(r.Context())
+		r.URL = &url
+		r.RequestURI = url.RequestURI()
+
+		if r.Header.Get("Authorization") != "" {
+			headerAuth.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := jwt.ParseStreamToken(token)
+		if err != nil {
+			utils.WriteErrorResponse(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, "user", claims.Username)
+		ctx = context.WithValue(ctx, "role", claims.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireRole only lets through requests whose token carries one of roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value("role").(string)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			utils.WriteErrorResponse(w, utils.ErrForbidden, http.StatusForbidden)
+		})
+	}
+}
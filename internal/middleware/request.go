@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/KonstantinGalanin/itemStore/internal/audit"
+)
+
+const (
+	// RequestIDHeader carries the request id in both directions: a valid
+	// id sent by the client or a proxy is kept, otherwise one is generated.
+	RequestIDHeader = "X-Request-ID"
+
+	maxUserAgentLength = 512
+)
+
+var requestIDValid = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestInfo stores the client address, user agent and request id in the
+// request context for the audit log, and echoes the request id back.
+func RequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDValid.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		// RemoteAddr is used rather than X-Forwarded-For, which any
+		// client can set.
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		userAgent := r.UserAgent()
+		if len(userAgent) > maxUserAgentLength {
+			userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+		}
+
+		ctx := audit.WithMeta(r.Context(), audit.Meta{
+			IP:        ip,
+			UserAgent: userAgent,
+			RequestID: requestID,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,118 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	//go:embed postgres/*.sql
+	postgresFS embed.FS
+
+	//go:embed sqlite/*.sql
+	sqliteFS embed.FS
+)
+
+var (
+	ErrBadFileName   = errors.New("migration file name must look like 0001_name.up.sql or 0001_name.down.sql")
+	ErrDuplicate     = errors.New("duplicate migration version")
+	ErrNoUp          = errors.New("migration has no up script")
+	ErrNoDown        = errors.New("migration has no down script")
+	ErrUnknownSchema = errors.New("database has migrations unknown to this binary")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Postgres returns the migrations shipped with the binary for PostgreSQL.
+func Postgres() ([]*Migration, error) {
+	return Load(postgresFS, "postgres")
+}
+
+// SQLite returns the migrations shipped with the binary for SQLite.
+func SQLite() ([]*Migration, error) {
+	return Load(sqliteFS, "sqlite")
+}
+
+// Load reads every NNNN_name.up.sql / NNNN_name.down.sql pair from dir and
+// returns them ordered by version.
+func Load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("load migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("load migrations %s: %w", entry.Name(), ErrBadFileName)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("load migrations %s: %w", entry.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("load migrations %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("load migrations %s: %w", entry.Name(), ErrDuplicate)
+		}
+
+		script := &migration.Up
+		if match[3] == "down" {
+			script = &migration.Down
+		}
+		if *script != "" {
+			return nil, fmt.Errorf("load migrations %s: %w", entry.Name(), ErrDuplicate)
+		}
+		*script = string(body)
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("load migrations %d_%s: %w", migration.Version, migration.Name, ErrNoUp)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
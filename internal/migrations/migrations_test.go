@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/0002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+			"sql/0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+			"sql/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+			"sql/0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		}
+
+		list, err := Load(fsys, "sql")
+		assert.NoError(t, err)
+		assert.Equal(t, []*Migration{
+			{Version: 1, Name: "first", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;"},
+			{Version: 2, Name: "second", Up: "CREATE TABLE b ();", Down: "DROP TABLE b;"},
+		}, list)
+	})
+
+	t.Run("bad file name", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/first.sql": {Data: []byte("CREATE TABLE a ();")},
+		}
+
+		_, err := Load(fsys, "sql")
+		assert.True(t, errors.Is(err, ErrBadFileName))
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/0001_first.up.sql": {Data: []byte("CREATE TABLE a ();")},
+			"sql/0001_other.up.sql": {Data: []byte("CREATE TABLE b ();")},
+		}
+
+		_, err := Load(fsys, "sql")
+		assert.True(t, errors.Is(err, ErrDuplicate))
+	})
+
+	t.Run("no up script", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+		}
+
+		_, err := Load(fsys, "sql")
+		assert.True(t, errors.Is(err, ErrNoUp))
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	list, err := Postgres()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, list)
+
+	for i, migration := range list {
+		assert.Equal(t, int64(i+1), migration.Version, "versions must be consecutive")
+		assert.NotEmpty(t, migration.Down, "migration %d has no down script", migration.Version)
+	}
+}
@@ -0,0 +1,247 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Dialect holds the database specific parts of the migrator: the
+// schema_version statements and the lock that keeps concurrent runners
+// (e.g. several replicas starting with auto-migrate) from racing.
+type Dialect struct {
+	CreateVersionTable string
+	GetVersions        string
+	InsertVersion      string
+	DeleteVersion      string
+	Lock               func(ctx context.Context, conn *sql.Conn) error
+	Unlock             func(ctx context.Context, conn *sql.Conn) error
+}
+
+// advisoryLockKey is an arbitrary application wide key for pg_advisory_lock.
+const advisoryLockKey = 7413100231
+
+var PostgresDialect = Dialect{
+	CreateVersionTable: "CREATE TABLE IF NOT EXISTS schema_version (version BIGINT PRIMARY KEY, name VARCHAR(200) NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW());",
+	GetVersions:        "SELECT version, applied_at FROM schema_version ORDER BY version;",
+	InsertVersion:      "INSERT INTO schema_version (version, name) VALUES ($1, $2);",
+	DeleteVersion:      "DELETE FROM schema_version WHERE version = $1;",
+	Lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1);", advisoryLockKey)
+		return err
+	},
+	Unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1);", advisoryLockKey)
+		return err
+	},
+}
+
+// SQLiteDialect has no cross-process lock: SQLite deployments are single
+// node, the database is opened with immediate transactions and a second
+// runner applying the same migration fails on the schema_version key.
+var SQLiteDialect = Dialect{
+	CreateVersionTable: "CREATE TABLE IF NOT EXISTS schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);",
+	GetVersions:        "SELECT version, applied_at FROM schema_version ORDER BY version;",
+	InsertVersion:      "INSERT INTO schema_version (version, name) VALUES (?, ?);",
+	DeleteVersion:      "DELETE FROM schema_version WHERE version = ?;",
+	Lock: func(ctx context.Context, conn *sql.Conn) error {
+		return nil
+	},
+	Unlock: func(ctx context.Context, conn *sql.Conn) error {
+		return nil
+	},
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    Dialect
+	Migrations []*Migration
+}
+
+func NewMigrator(db *sql.DB, dialect Dialect, migrations []*Migration) *Migrator {
+	return &Migrator{
+		DB:         db,
+		Dialect:    dialect,
+		Migrations: migrations,
+	}
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied. Each migration runs in its own transaction together with its
+// schema_version row.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var applied []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration.Up, m.Dialect.InsertVersion, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var reverted []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.Migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, ErrNoDown)
+			}
+
+			if err := m.apply(ctx, conn, migration.Down, m.Dialect.DeleteVersion, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	var statuses []*Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			appliedAt, ok := versions[migration.Version]
+			statuses = append(statuses, &Status{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Version returns the newest applied migration version, 0 for an empty
+// database.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for v := range versions {
+			if v > version {
+				version = v
+			}
+		}
+
+		return nil
+	})
+
+	return version, err
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.Dialect.Lock(ctx, conn); err != nil {
+		return fmt.Errorf("migrate lock: %w", err)
+	}
+	defer func() {
+		if unlockErr := m.Dialect.Unlock(ctx, conn); unlockErr != nil && err == nil {
+			err = fmt.Errorf("migrate unlock: %w", unlockErr)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, m.Dialect.CreateVersionTable); err != nil {
+		return fmt.Errorf("migrate create schema_version: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, m.Dialect.GetVersions)
+	if err != nil {
+		return nil, fmt.Errorf("migrate get versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("migrate get versions: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrate get versions: %w", err)
+	}
+
+	known := make(map[int64]bool, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		known[migration.Version] = true
+	}
+	for version := range versions {
+		if !known[version] {
+			return nil, fmt.Errorf("migrate version %d: %w", version, ErrUnknownSchema)
+		}
+	}
+
+	return versions, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script, versionQuery string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, versionQuery, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
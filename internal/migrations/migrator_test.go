@@ -0,0 +1,148 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var (
+	InternalTestError = errors.New("internal error")
+
+	testMigrations = []*Migration{
+		{Version: 1, Name: "first", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "second", Up: "CREATE TABLE b ();", Down: "DROP TABLE b;"},
+	}
+)
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(advisoryLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_version`).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(advisoryLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestUp(t *testing.T) {
+	t.Run("applies pending", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectLock(mock)
+		mock.ExpectQuery(`SELECT version, applied_at FROM schema_version`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_version \(version, name\)`).WithArgs(2, "second").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectUnlock(mock)
+
+		applied, err := NewMigrator(db, PostgresDialect, testMigrations).Up(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, testMigrations[1:], applied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("script error rolls back", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectLock(mock)
+		mock.ExpectQuery(`SELECT version, applied_at FROM schema_version`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE TABLE a`).WillReturnError(InternalTestError)
+		mock.ExpectRollback()
+		expectUnlock(mock)
+
+		applied, err := NewMigrator(db, PostgresDialect, testMigrations).Up(context.Background())
+		assert.True(t, errors.Is(err, InternalTestError))
+		assert.Empty(t, applied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectLock(mock)
+		mock.ExpectQuery(`SELECT version, applied_at FROM schema_version`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(3, time.Now()))
+		expectUnlock(mock)
+
+		_, err = NewMigrator(db, PostgresDialect, testMigrations).Up(context.Background())
+		assert.True(t, errors.Is(err, ErrUnknownSchema))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnError(InternalTestError)
+
+		_, err = NewMigrator(db, PostgresDialect, testMigrations).Up(context.Background())
+		assert.True(t, errors.Is(err, InternalTestError))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_version`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_version WHERE version = (.+);`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	reverted, err := NewMigrator(db, PostgresDialect, testMigrations).Down(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Migration{testMigrations[1]}, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusAndVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	appliedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	migrator := NewMigrator(db, PostgresDialect, testMigrations)
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_version`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+	expectUnlock(mock)
+
+	statuses, err := migrator.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []*Status{
+		{Version: 1, Name: "first", Applied: true, AppliedAt: appliedAt},
+		{Version: 2, Name: "second"},
+	}, statuses)
+
+	expectLock(mock)
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_version`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+	expectUnlock(mock)
+
+	version, err := migrator.Version(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,13 +1,11 @@
-DROP TABLE IF EXISTS users;
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     username VARCHAR(200) NOT NULL UNIQUE,
     password VARCHAR(200) NOT NULL,
     balance INT DEFAULT 1000
 );
 
-DROP TABLE IF EXISTS items;
-CREATE TABLE items (
+CREATE TABLE IF NOT EXISTS items (
     id SERIAL PRIMARY KEY,
     name VARCHAR(200) NOT NULL UNIQUE,
     price INT NOT NULL
@@ -22,21 +20,20 @@ INSERT INTO items (name, price) VALUES
     ('hoody', 300),
     ('umbrella', 200),
     ('socks', 10),
-    ('wallet', 50);
+    ('wallet', 50)
+ON CONFLICT (name) DO NOTHING;
 
-DROP TABLE IF EXISTS exchanges;
-CREATE TABLE exchanges(
+CREATE TABLE IF NOT EXISTS exchanges(
     id SERIAL PRIMARY KEY,
     from_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     to_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     amount INT NOT NULL
 );
 
-DROP TABLE IF EXISTS purchases;
-CREATE TABLE purchases (
+CREATE TABLE IF NOT EXISTS purchases (
     id SERIAL PRIMARY KEY,
     user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
     quantity INT NOT NULL DEFAULT 1,
     UNIQUE (user_id, item_id)
-);
\ No newline at end of file
+);
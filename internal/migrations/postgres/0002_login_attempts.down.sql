@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS lockout_events;
+DROP TABLE IF EXISTS login_attempts;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
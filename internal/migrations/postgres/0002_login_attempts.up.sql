@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    username VARCHAR(200) PRIMARY KEY,
+    failed_count INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ,
+    blocked_until TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS lockout_events (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(200) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    actor VARCHAR(200) NOT NULL DEFAULT '',
+    failed_count INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,21 @@
+-- Inventory is no longer a counter of its own: it is whatever a user ordered
+-- minus what was refunded.
+CREATE VIEW inventory AS
+SELECT orders.user_id, orders.item_id, SUM(orders.quantity - COALESCE(refunded.quantity, 0)) AS quantity
+FROM orders
+LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+GROUP BY orders.user_id, orders.item_id
+HAVING SUM(orders.quantity - COALESCE(refunded.quantity, 0)) > 0;
+
+-- Units bought before orders were recorded become one order per user and
+-- item at the current catalog price.
+INSERT INTO orders (user_id, item_id, quantity, unit_price)
+SELECT purchases.user_id, purchases.item_id, purchases.quantity - COALESCE(inventory.quantity, 0), items.price
+FROM purchases
+JOIN items ON items.id = purchases.item_id
+LEFT JOIN inventory ON inventory.user_id = purchases.user_id AND inventory.item_id = purchases.item_id
+WHERE purchases.quantity > COALESCE(inventory.quantity, 0);
+
+DROP TABLE purchases;
+
+CREATE INDEX IF NOT EXISTS orders_user_idx ON orders (user_id, id);
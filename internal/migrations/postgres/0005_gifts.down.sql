@@ -0,0 +1,12 @@
+DROP VIEW inventory;
+
+CREATE VIEW inventory AS
+SELECT orders.user_id, orders.item_id, SUM(orders.quantity - COALESCE(refunded.quantity, 0)) AS quantity
+FROM orders
+LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+GROUP BY orders.user_id, orders.item_id
+HAVING SUM(orders.quantity - COALESCE(refunded.quantity, 0)) > 0;
+
+DROP INDEX IF EXISTS orders_recipient_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS message;
+ALTER TABLE orders DROP COLUMN IF EXISTS recipient_id;
@@ -0,0 +1,14 @@
+-- A gift is an order paid by user_id whose units belong to recipient_id.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS recipient_id INT REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS message VARCHAR(200) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS orders_recipient_idx ON orders (recipient_id) WHERE recipient_id IS NOT NULL;
+
+DROP VIEW inventory;
+
+CREATE VIEW inventory AS
+SELECT COALESCE(orders.recipient_id, orders.user_id) AS user_id, orders.item_id, SUM(orders.quantity - COALESCE(refunded.quantity, 0)) AS quantity
+FROM orders
+LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+GROUP BY COALESCE(orders.recipient_id, orders.user_id), orders.item_id
+HAVING SUM(orders.quantity - COALESCE(refunded.quantity, 0)) > 0;
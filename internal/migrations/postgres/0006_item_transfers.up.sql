@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS item_transfers (
+    id SERIAL PRIMARY KEY,
+    from_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS item_transfers_from_idx ON item_transfers (from_id);
+CREATE INDEX IF NOT EXISTS item_transfers_to_idx ON item_transfers (to_id);
+
+DROP VIEW inventory;
+
+-- Holdings are a ledger: orders net of refunds, plus units received and
+-- minus units given away through transfers.
+CREATE VIEW inventory AS
+SELECT ledger.user_id, ledger.item_id, SUM(ledger.quantity) AS quantity
+FROM (
+    SELECT COALESCE(orders.recipient_id, orders.user_id) AS user_id, orders.item_id, orders.quantity - COALESCE(refunded.quantity, 0) AS quantity
+    FROM orders
+    LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+    UNION ALL
+    SELECT to_id, item_id, quantity FROM item_transfers
+    UNION ALL
+    SELECT from_id, item_id, -quantity FROM item_transfers
+) AS ledger
+GROUP BY ledger.user_id, ledger.item_id
+HAVING SUM(ledger.quantity) > 0;
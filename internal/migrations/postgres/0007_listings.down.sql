@@ -0,0 +1,17 @@
+DROP VIEW inventory;
+
+CREATE VIEW inventory AS
+SELECT ledger.user_id, ledger.item_id, SUM(ledger.quantity) AS quantity
+FROM (
+    SELECT COALESCE(orders.recipient_id, orders.user_id) AS user_id, orders.item_id, orders.quantity - COALESCE(refunded.quantity, 0) AS quantity
+    FROM orders
+    LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+    UNION ALL
+    SELECT to_id, item_id, quantity FROM item_transfers
+    UNION ALL
+    SELECT from_id, item_id, -quantity FROM item_transfers
+) AS ledger
+GROUP BY ledger.user_id, ledger.item_id
+HAVING SUM(ledger.quantity) > 0;
+
+DROP TABLE IF EXISTS listings;
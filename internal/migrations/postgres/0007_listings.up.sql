@@ -0,0 +1,40 @@
+-- A listing holds its units in escrow: while it is open or once it is sold
+-- they are out of the seller's inventory, and a sale puts them into the
+-- buyer's. Cancelled and expired listings drop out of the ledger, which
+-- returns the units to the seller.
+CREATE TABLE IF NOT EXISTS listings (
+    id SERIAL PRIMARY KEY,
+    seller_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id INT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    price INT NOT NULL CHECK (price > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    buyer_id INT REFERENCES users(id) ON DELETE CASCADE,
+    fee INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS listings_open_idx ON listings (expires_at) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS listings_seller_idx ON listings (seller_id);
+
+DROP VIEW inventory;
+
+CREATE VIEW inventory AS
+SELECT ledger.user_id, ledger.item_id, SUM(ledger.quantity) AS quantity
+FROM (
+    SELECT COALESCE(orders.recipient_id, orders.user_id) AS user_id, orders.item_id, orders.quantity - COALESCE(refunded.quantity, 0) AS quantity
+    FROM orders
+    LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+    UNION ALL
+    SELECT to_id, item_id, quantity FROM item_transfers
+    UNION ALL
+    SELECT from_id, item_id, -quantity FROM item_transfers
+    UNION ALL
+    SELECT seller_id, item_id, -quantity FROM listings WHERE status IN ('open', 'sold')
+    UNION ALL
+    SELECT buyer_id, item_id, quantity FROM listings WHERE status = 'sold'
+) AS ledger
+GROUP BY ledger.user_id, ledger.item_id
+HAVING SUM(ledger.quantity) > 0;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS exchanges_to_idx;
+DROP INDEX IF EXISTS exchanges_from_idx;
+ALTER TABLE exchanges DROP COLUMN IF EXISTS created_at;
+ALTER TABLE exchanges DROP COLUMN IF EXISTS category;
+ALTER TABLE exchanges DROP COLUMN IF EXISTS memo;
@@ -0,0 +1,8 @@
+ALTER TABLE exchanges ADD COLUMN IF NOT EXISTS memo VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE exchanges ADD COLUMN IF NOT EXISTS category VARCHAR(32) NOT NULL DEFAULT 'other';
+-- Transfers made before this migration have no recorded time.
+ALTER TABLE exchanges ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE exchanges ALTER COLUMN created_at SET DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS exchanges_from_idx ON exchanges (from_id);
+CREATE INDEX IF NOT EXISTS exchanges_to_idx ON exchanges (to_id);
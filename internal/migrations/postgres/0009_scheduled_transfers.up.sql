@@ -0,0 +1,28 @@
+-- A scheduled transfer sends coins once at next_run_at, or again every week
+-- or month after that. Every execution, successful or not, is recorded as a
+-- run.
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id SERIAL PRIMARY KEY,
+    from_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INT NOT NULL CHECK (amount > 0),
+    memo VARCHAR(200) NOT NULL DEFAULT '',
+    category VARCHAR(32) NOT NULL DEFAULT 'other',
+    recurrence VARCHAR(20) NOT NULL DEFAULT 'once',
+    next_run_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_due_idx ON scheduled_transfers (next_run_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS scheduled_transfers_from_idx ON scheduled_transfers (from_id);
+
+CREATE TABLE IF NOT EXISTS scheduled_transfer_runs (
+    id SERIAL PRIMARY KEY,
+    schedule_id INT NOT NULL REFERENCES scheduled_transfers(id) ON DELETE CASCADE,
+    run_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfer_runs_schedule_idx ON scheduled_transfer_runs (schedule_id);
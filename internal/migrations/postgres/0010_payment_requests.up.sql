@@ -0,0 +1,19 @@
+-- A payment request asks payer_id to send amount coins to requester_id. It
+-- stays pending until the payer accepts or declines it, the requester
+-- cancels it or it expires.
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id SERIAL PRIMARY KEY,
+    requester_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payer_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INT NOT NULL CHECK (amount > 0),
+    memo VARCHAR(200) NOT NULL DEFAULT '',
+    category VARCHAR(32) NOT NULL DEFAULT 'other',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS payment_requests_pending_idx ON payment_requests (expires_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS payment_requests_requester_idx ON payment_requests (requester_id);
+CREATE INDEX IF NOT EXISTS payment_requests_payer_idx ON payment_requests (payer_id);
@@ -0,0 +1,14 @@
+-- transfer_limits holds per-user overrides of the configured transfer
+-- limits, set by admins. A zero limit is not enforced.
+CREATE TABLE IF NOT EXISTS transfer_limits (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    max_transfer INT NOT NULL DEFAULT 0 CHECK (max_transfer >= 0),
+    daily_amount INT NOT NULL DEFAULT 0 CHECK (daily_amount >= 0),
+    weekly_amount INT NOT NULL DEFAULT 0 CHECK (weekly_amount >= 0),
+    daily_recipients INT NOT NULL DEFAULT 0 CHECK (daily_recipients >= 0),
+    updated_by VARCHAR(200) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Limit checks sum a sender's recent transfers.
+CREATE INDEX IF NOT EXISTS exchanges_from_created_idx ON exchanges (from_id, created_at);
@@ -0,0 +1,15 @@
+-- balance_adjustments is the audit log of admin changes to balances:
+-- grants, clawbacks and balances set outright. amount is the signed change
+-- and balance the user's balance afterwards.
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor VARCHAR(200) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    amount INT NOT NULL,
+    balance INT NOT NULL,
+    reason VARCHAR(200) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS balance_adjustments_user_idx ON balance_adjustments (user_id);
@@ -0,0 +1,37 @@
+-- audit_log is the append-only record of security and financial events.
+-- Every row carries the hash of the one before it (see internal/audit), so
+-- prev_hash is unique: two events can never continue the same entry. The
+-- triggers reject updates, deletes and truncation, so rewriting history
+-- takes someone able to drop them, and the broken chain still shows it.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(50) NOT NULL,
+    actor VARCHAR(200) NOT NULL,
+    target VARCHAR(200) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    prev_hash VARCHAR(64) NOT NULL UNIQUE,
+    hash VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log (action, id);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, id);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target, id);
+CREATE INDEX IF NOT EXISTS audit_log_created_idx ON audit_log (created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,27 @@
+-- outbox holds domain events written in the same transaction as the change
+-- they describe, until the relay has delivered them to every sink.
+-- event_key is the user the event belongs to: an event is only due once no
+-- earlier event with the same key is waiting for a retry, which keeps each
+-- user's events in order.
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    event_key VARCHAR(200) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at, id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_key_pending_idx ON outbox (event_key, id) WHERE delivered_at IS NULL;
+
+-- outbox_relay_lease names the replica allowed to relay the outbox until
+-- expires_at, so only one relay delivers events at a time.
+CREATE TABLE IF NOT EXISTS outbox_relay_lease (
+    id INT PRIMARY KEY CHECK (id = 1),
+    owner VARCHAR(200) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,13 @@
+-- user_events holds the events pushed to connected users over
+-- GET /api/events, kept for a while so a client that reconnects can resume
+-- after the last id it saw.
+CREATE TABLE IF NOT EXISTS user_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_events_user_idx ON user_events (user_id, id);
+CREATE INDEX IF NOT EXISTS user_events_created_idx ON user_events (created_at);
@@ -0,0 +1,22 @@
+-- notifications is the users' inbox. read_at is NULL until the user marks
+-- the notification read.
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    text TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    read_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (user_id, id);
+CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+-- notification_preferences stores the kinds a user turned on or off; kinds
+-- without a row are on.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, kind)
+);
@@ -0,0 +1,29 @@
+-- email_settings holds the address a user wants email at and the mode:
+-- off, instant or digest. digest_sent_at is when the last digest went out.
+CREATE TABLE IF NOT EXISTS email_settings (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    address VARCHAR(254) NOT NULL,
+    mode VARCHAR(20) NOT NULL,
+    digest_sent_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_settings_digest_idx ON email_settings (digest_sent_at) WHERE mode = 'digest';
+
+-- emails is the outgoing email queue. Pending emails are sent once
+-- next_attempt_at has passed; an email out of retries is marked dead.
+CREATE TABLE IF NOT EXISTS emails (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    address VARCHAR(254) NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS emails_pending_idx ON emails (next_attempt_at, id) WHERE status = 'pending';
@@ -0,0 +1 @@
+ALTER TABLE scheduled_transfers DROP COLUMN IF EXISTS anchor_day;
@@ -0,0 +1,4 @@
+-- anchor_day is the day of the month monthly schedules return to after a
+-- shorter month. Schedules created before it have 0 and keep following
+-- next_run_at.
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS anchor_day INT NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS lockout_events;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS purchases;
+DROP TABLE IF EXISTS exchanges;
+DROP TABLE IF EXISTS items;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS orders_user_idx;
+
+CREATE TABLE purchases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id INTEGER NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (user_id, item_id)
+);
+
+INSERT INTO purchases (user_id, item_id, quantity)
+SELECT user_id, item_id, quantity FROM inventory;
+
+DROP VIEW inventory;
@@ -0,0 +1,16 @@
+-- A gift is an order paid by user_id whose units belong to recipient_id.
+-- SQLite cannot drop a column that is part of a foreign key, so unlike the
+-- Postgres schema recipient_id has no REFERENCES clause.
+ALTER TABLE orders ADD COLUMN recipient_id INTEGER;
+ALTER TABLE orders ADD COLUMN message TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX orders_recipient_idx ON orders (recipient_id) WHERE recipient_id IS NOT NULL;
+
+DROP VIEW inventory;
+
+CREATE VIEW inventory AS
+SELECT COALESCE(orders.recipient_id, orders.user_id) AS user_id, orders.item_id, SUM(orders.quantity - COALESCE(refunded.quantity, 0)) AS quantity
+FROM orders
+LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+GROUP BY COALESCE(orders.recipient_id, orders.user_id), orders.item_id
+HAVING SUM(orders.quantity - COALESCE(refunded.quantity, 0)) > 0;
@@ -0,0 +1,10 @@
+DROP VIEW inventory;
+
+CREATE VIEW inventory AS
+SELECT COALESCE(orders.recipient_id, orders.user_id) AS user_id, orders.item_id, SUM(orders.quantity - COALESCE(refunded.quantity, 0)) AS quantity
+FROM orders
+LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+GROUP BY COALESCE(orders.recipient_id, orders.user_id), orders.item_id
+HAVING SUM(orders.quantity - COALESCE(refunded.quantity, 0)) > 0;
+
+DROP TABLE IF EXISTS item_transfers;
@@ -0,0 +1,40 @@
+-- A listing holds its units in escrow: while it is open or once it is sold
+-- they are out of the seller's inventory, and a sale puts them into the
+-- buyer's. Cancelled and expired listings drop out of the ledger, which
+-- returns the units to the seller.
+CREATE TABLE listings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    seller_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id INTEGER NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    price INTEGER NOT NULL CHECK (price > 0),
+    status TEXT NOT NULL DEFAULT 'open',
+    buyer_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    fee INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX listings_open_idx ON listings (expires_at) WHERE status = 'open';
+CREATE INDEX listings_seller_idx ON listings (seller_id);
+
+DROP VIEW inventory;
+
+CREATE VIEW inventory AS
+SELECT ledger.user_id, ledger.item_id, SUM(ledger.quantity) AS quantity
+FROM (
+    SELECT COALESCE(orders.recipient_id, orders.user_id) AS user_id, orders.item_id, orders.quantity - COALESCE(refunded.quantity, 0) AS quantity
+    FROM orders
+    LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id
+    UNION ALL
+    SELECT to_id, item_id, quantity FROM item_transfers
+    UNION ALL
+    SELECT from_id, item_id, -quantity FROM item_transfers
+    UNION ALL
+    SELECT seller_id, item_id, -quantity FROM listings WHERE status IN ('open', 'sold')
+    UNION ALL
+    SELECT buyer_id, item_id, quantity FROM listings WHERE status = 'sold'
+) AS ledger
+GROUP BY ledger.user_id, ledger.item_id
+HAVING SUM(ledger.quantity) > 0;
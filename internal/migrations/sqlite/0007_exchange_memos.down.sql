@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS exchanges_to_idx;
+DROP INDEX IF EXISTS exchanges_from_idx;
+ALTER TABLE exchanges DROP COLUMN created_at;
+ALTER TABLE exchanges DROP COLUMN category;
+ALTER TABLE exchanges DROP COLUMN memo;
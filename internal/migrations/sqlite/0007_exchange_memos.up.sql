@@ -0,0 +1,9 @@
+ALTER TABLE exchanges ADD COLUMN memo TEXT NOT NULL DEFAULT '';
+ALTER TABLE exchanges ADD COLUMN category TEXT NOT NULL DEFAULT 'other';
+-- Transfers made before this migration have no recorded time. SQLite
+-- cannot add a column defaulting to CURRENT_TIMESTAMP, so new rows set it
+-- on insert.
+ALTER TABLE exchanges ADD COLUMN created_at TIMESTAMP;
+
+CREATE INDEX exchanges_from_idx ON exchanges (from_id);
+CREATE INDEX exchanges_to_idx ON exchanges (to_id);
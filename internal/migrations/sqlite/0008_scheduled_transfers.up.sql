@@ -0,0 +1,28 @@
+-- A scheduled transfer sends coins once at next_run_at, or again every week
+-- or month after that. Every execution, successful or not, is recorded as a
+-- run.
+CREATE TABLE scheduled_transfers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    memo TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT 'other',
+    recurrence TEXT NOT NULL DEFAULT 'once',
+    next_run_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX scheduled_transfers_due_idx ON scheduled_transfers (next_run_at) WHERE status = 'active';
+CREATE INDEX scheduled_transfers_from_idx ON scheduled_transfers (from_id);
+
+CREATE TABLE scheduled_transfer_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id INTEGER NOT NULL REFERENCES scheduled_transfers(id) ON DELETE CASCADE,
+    run_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX scheduled_transfer_runs_schedule_idx ON scheduled_transfer_runs (schedule_id);
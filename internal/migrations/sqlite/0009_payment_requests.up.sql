@@ -0,0 +1,19 @@
+-- A payment request asks payer_id to send amount coins to requester_id. It
+-- stays pending until the payer accepts or declines it, the requester
+-- cancels it or it expires.
+CREATE TABLE payment_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    requester_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    memo TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT 'other',
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX payment_requests_pending_idx ON payment_requests (expires_at) WHERE status = 'pending';
+CREATE INDEX payment_requests_requester_idx ON payment_requests (requester_id);
+CREATE INDEX payment_requests_payer_idx ON payment_requests (payer_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS exchanges_from_created_idx;
+DROP TABLE IF EXISTS transfer_limits;
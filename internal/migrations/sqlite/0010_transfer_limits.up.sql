@@ -0,0 +1,14 @@
+-- transfer_limits holds per-user overrides of the configured transfer
+-- limits, set by admins. A zero limit is not enforced.
+CREATE TABLE transfer_limits (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    max_transfer INTEGER NOT NULL DEFAULT 0 CHECK (max_transfer >= 0),
+    daily_amount INTEGER NOT NULL DEFAULT 0 CHECK (daily_amount >= 0),
+    weekly_amount INTEGER NOT NULL DEFAULT 0 CHECK (weekly_amount >= 0),
+    daily_recipients INTEGER NOT NULL DEFAULT 0 CHECK (daily_recipients >= 0),
+    updated_by TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Limit checks sum a sender's recent transfers.
+CREATE INDEX exchanges_from_created_idx ON exchanges (from_id, created_at);
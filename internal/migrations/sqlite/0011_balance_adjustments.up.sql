@@ -0,0 +1,15 @@
+-- balance_adjustments is the audit log of admin changes to balances:
+-- grants, clawbacks and balances set outright. amount is the signed change
+-- and balance the user's balance afterwards.
+CREATE TABLE balance_adjustments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    balance INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX balance_adjustments_user_idx ON balance_adjustments (user_id);
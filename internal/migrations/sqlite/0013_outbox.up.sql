@@ -0,0 +1,27 @@
+-- outbox holds domain events written in the same transaction as the change
+-- they describe, until the relay has delivered them to every sink.
+-- event_key is the user the event belongs to: an event is only due once no
+-- earlier event with the same key is waiting for a retry, which keeps each
+-- user's events in order.
+CREATE TABLE outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL,
+    event_key TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at, id) WHERE delivered_at IS NULL;
+CREATE INDEX outbox_key_pending_idx ON outbox (event_key, id) WHERE delivered_at IS NULL;
+
+-- outbox_relay_lease names the process allowed to relay the outbox until
+-- expires_at, so only one relay delivers events at a time.
+CREATE TABLE outbox_relay_lease (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    owner TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
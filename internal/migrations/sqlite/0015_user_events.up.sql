@@ -0,0 +1,13 @@
+-- user_events holds the events pushed to connected users over
+-- GET /api/events, kept for a while so a client that reconnects can resume
+-- after the last id it saw.
+CREATE TABLE user_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX user_events_user_idx ON user_events (user_id, id);
+CREATE INDEX user_events_created_idx ON user_events (created_at);
@@ -0,0 +1,29 @@
+-- email_settings holds the address a user wants email at and the mode:
+-- off, instant or digest. digest_sent_at is when the last digest went out.
+CREATE TABLE email_settings (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    address TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    digest_sent_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX email_settings_digest_idx ON email_settings (digest_sent_at) WHERE mode = 'digest';
+
+-- emails is the outgoing email queue. Pending emails are sent once
+-- next_attempt_at has passed; an email out of retries is marked dead.
+CREATE TABLE emails (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    address TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX emails_pending_idx ON emails (next_attempt_at, id) WHERE status = 'pending';
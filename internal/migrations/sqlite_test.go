@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteUpDown(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	list, err := SQLite()
+	require.NoError(t, err)
+	migrator := NewMigrator(db, SQLiteDialect, list)
+	ctx := context.Background()
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, list, applied)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, list[len(list)-1].Version, version)
+
+	reverted, err := migrator.Down(ctx, len(list))
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(list))
+
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, version)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, list, applied)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied)
+		assert.False(t, status.AppliedAt.IsZero())
+	}
+}
+
+func TestSQLiteOrderHistoryBackfill(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	list, err := SQLite()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	var beforeHistory []*Migration
+	for _, migration := range list {
+		if migration.Name == "order_history" {
+			break
+		}
+		beforeHistory = append(beforeHistory, migration)
+	}
+	_, err = NewMigrator(db, SQLiteDialect, beforeHistory).Up(ctx)
+	require.NoError(t, err)
+
+	// Three cups held: two bought before orders existed, one recorded as an
+	// order at an old price.
+	_, err = db.Exec("INSERT INTO users (id, username, password) VALUES (1, 'alice', 'pass1234');")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO purchases (user_id, item_id, quantity) VALUES (1, (SELECT id FROM items WHERE name = 'cup'), 3);")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO orders (user_id, item_id, quantity, unit_price) VALUES (1, (SELECT id FROM items WHERE name = 'cup'), 1, 15);")
+	require.NoError(t, err)
+
+	_, err = NewMigrator(db, SQLiteDialect, list).Up(ctx)
+	require.NoError(t, err)
+
+	rows, err := db.Query("SELECT quantity, unit_price FROM orders ORDER BY id;")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var orders [][2]int
+	for rows.Next() {
+		var quantity, price int
+		require.NoError(t, rows.Scan(&quantity, &price))
+		orders = append(orders, [2]int{quantity, price})
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][2]int{{1, 15}, {2, 20}}, orders)
+
+	var held int
+	require.NoError(t, db.QueryRow("SELECT quantity FROM inventory WHERE user_id = 1;").Scan(&held))
+	assert.Equal(t, 3, held)
+}
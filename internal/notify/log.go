@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+	"log"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+// LogNotifier writes notifications to the standard logger. It stands in for
+// a real delivery channel.
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{
+		Logger: log.Default(),
+	}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification *entities.Notification) error {
+	n.Logger.Printf("notify %s [%s]: %s", notification.Recipient, notification.Kind, notification.Text)
+	return nil
+}
@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+type Notifier interface {
+	Notify(ctx context.Context, notification *entities.Notification) error
+}
+
+// Multi delivers every notification to each of its notifiers in order. One
+// failing does not keep the rest from getting it; their errors are joined.
+type Multi []Notifier
+
+func NewMulti(notifiers ...Notifier) Multi {
+	return Multi(notifiers)
+}
+
+func (m Multi) Notify(ctx context.Context, notification *entities.Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+// Package openapi holds the OpenAPI 3 description of the HTTP API, serves
+// it with a reference page, and checks requests against it.
+//
+// openapi.json is written by hand. Tests keep it in sync with the router
+// and with the entities the handlers encode.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docs []byte
+
+// Document is the part of an OpenAPI 3 document the validator reads.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema the document uses.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *Schema            `json:"items"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Nullable             bool               `json:"nullable"`
+}
+
+// Spec returns the document as served.
+func Spec() []byte {
+	return bytes.Clone(spec)
+}
+
+// Load parses the embedded document.
+func Load() (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse openapi.json: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// MustLoad is Load for callers that cannot go on without the document.
+func MustLoad() *Document {
+	doc, err := Load()
+	if err != nil {
+		panic(err)
+	}
+
+	return doc
+}
+
+// Operation returns the operation for method on the path template, or nil
+// if the document does not describe it.
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// resolve follows schema's $ref, if it has one.
+func (d *Document) resolve(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+
+	name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+	resolved := d.Components.Schemas[name]
+	if !ok || resolved == nil {
+		return nil, fmt.Errorf("unknown schema %s", schema.Ref)
+	}
+
+	return resolved, nil
+}
+
+// ServeSpec responds with the document.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(spec)
+}
+
+// ServeDocs responds with a page rendering the document.
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(docs)
+}
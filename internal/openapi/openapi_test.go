@@ -0,0 +1,225 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchemasMatchEntities keeps the response schemas in step with the
+// types the handlers encode.
+func TestSchemasMatchEntities(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+
+	for name, value := range map[string]any{
+		"Item":                   entities.Item{},
+		"CatalogItem":            entities.CatalogItem{},
+		"InfoResponse":           entities.InfoResponse{},
+		"Error":                  entities.ErrorResponse{},
+		"CoinHistory":            entities.CoinHistory{},
+		"GiftHistory":            entities.GiftHistory{},
+		"ItemHistory":            entities.ItemHistory{},
+		"SentItemOperation":      entities.SentItemOperation{},
+		"ReceivedItemOperation":  entities.ReceivedItemOperation{},
+		"SentGift":               entities.SentGift{},
+		"ReceivedGift":           entities.ReceivedGift{},
+		"SentOperation":          entities.SentOperation{},
+		"ReceiveOperation":       entities.ReceiveOperation{},
+		"RefundOperation":        entities.RefundOperation{},
+		"BatchTransfer":          entities.BatchTransfer{},
+		"BatchSendResult":        entities.BatchSendResult{},
+		"CoinTransfer":           entities.CoinTransfer{},
+		"TransfersPage":          entities.TransfersPage{},
+		"Order":                  entities.Order{},
+		"OrdersPage":             entities.OrdersPage{},
+		"Listing":                entities.Listing{},
+		"ListingsPage":           entities.ListingsPage{},
+		"ScheduleRequest":        entities.ScheduleRequest{},
+		"ScheduledTransfer":      entities.ScheduledTransfer{},
+		"ScheduledTransferRun":   entities.ScheduledTransferRun{},
+		"PaymentRequest":         entities.PaymentRequest{},
+		"PaymentRequestsPage":    entities.PaymentRequestsPage{},
+		"WebhookEndpointRequest": entities.WebhookEndpointRequest{},
+		"WebhookEndpoint":        entities.WebhookEndpoint{},
+		"WebhookDelivery":        entities.WebhookDelivery{},
+		"WebhookAttempt":         entities.WebhookAttempt{},
+		"WebhookDeliveriesPage":  entities.WebhookDeliveriesPage{},
+		"Notification":           entities.Notification{},
+		"NotificationsPage":      entities.NotificationsPage{},
+		"MarkReadResponse":       entities.MarkReadResponse{},
+		"EmailSettings":          entities.EmailSettings{},
+		"TransferLimits":         entities.TransferLimits{},
+		"UserTransferLimits":     entities.UserTransferLimits{},
+		"BalanceAdjustment":      entities.BalanceAdjustment{},
+		"BalanceAdjustmentsPage": entities.BalanceAdjustmentsPage{},
+		"AuditEvent":             entities.AuditEvent{},
+		"AuditEventsPage":        entities.AuditEventsPage{},
+		"AuditVerification":      entities.AuditVerification{},
+	} {
+		schema := doc.Components.Schemas[name]
+		if !assert.NotNil(t, schema, name) {
+			continue
+		}
+
+		var properties []string
+		for property := range schema.Properties {
+			properties = append(properties, property)
+		}
+		slices.Sort(properties)
+		assert.Equal(t, jsonFields(reflect.TypeOf(value)), properties, name)
+	}
+}
+
+// jsonFields lists the JSON keys a struct encodes to.
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	slices.Sort(fields)
+
+	return fields
+}
+
+func TestRefsResolve(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+
+	var check func(schema *Schema, where string)
+	check = func(schema *Schema, where string) {
+		if schema == nil {
+			return
+		}
+		_, err := doc.resolve(schema)
+		assert.NoError(t, err, where)
+		for name, property := range schema.Properties {
+			check(property, where+"."+name)
+		}
+		check(schema.Items, where+"[]")
+		check(schema.AdditionalProperties, where+".*")
+	}
+
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			where := method + " " + path
+			assert.NotEmpty(t, operation.OperationID, where)
+			for _, param := range operation.Parameters {
+				check(param.Schema, where+" "+param.Name)
+			}
+			if operation.RequestBody != nil {
+				for _, media := range operation.RequestBody.Content {
+					check(media.Schema, where+" body")
+				}
+			}
+		}
+	}
+	for name, schema := range doc.Components.Schemas {
+		check(schema, name)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	validator := NewValidator(MustLoad())
+
+	// serve runs a request through the validator on a router with the
+	// documented route.
+	serve := func(method, path, route, body string) (*httptest.ResponseRecorder, []byte) {
+		var got []byte
+		r := mux.NewRouter()
+		r.Use(validator.Middleware)
+		r.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			got, _ = io.ReadAll(r.Body)
+		}).Methods(method)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w, got
+	}
+
+	for _, tc := range []struct {
+		name, method, path, route, body string
+		err                             string
+	}{
+		{"valid body", http.MethodPost, "/api/sendCoin", "/api/sendCoin", `{"toUser":"bob","amount":10,"memo":"lunch"}`, ""},
+		{"unknown fields pass", http.MethodPost, "/api/sendCoin", "/api/sendCoin", `{"toUser":"bob","amount":10,"extra":true}`, ""},
+		{"missing field", http.MethodPost, "/api/sendCoin", "/api/sendCoin", `{"toUser":"bob"}`, "body.amount is required"},
+		{"wrong type", http.MethodPost, "/api/sendCoin", "/api/sendCoin", `{"toUser":"bob","amount":"10"}`, "body.amount must be an integer"},
+		{"fraction", http.MethodPost, "/api/sendCoin", "/api/sendCoin", `{"toUser":"bob","amount":1.5}`, "body.amount must be an integer"},
+		{"null", http.MethodPost, "/api/sendCoin", "/api/sendCoin", `{"toUser":null,"amount":1}`, "body.toUser must not be null"},
+		{"not json", http.MethodPost, "/api/sendCoin", "/api/sendCoin", `{"toUser":`, "body must be valid JSON"},
+		{"body required", http.MethodPost, "/api/sendCoin", "/api/sendCoin", ``, "body is required"},
+		{"optional body", http.MethodPost, "/api/buy/cup", "/api/buy/{item}", ``, ""},
+		{"array items", http.MethodPost, "/api/sendCoin/batch", "/api/sendCoin/batch", `{"transfers":[{"toUser":"bob","amount":1},{"toUser":"eve"}]}`, "body.transfers[1].amount is required"},
+		{"map values", http.MethodPut, "/api/notifications/preferences", "/api/notifications/preferences", `{"coins_received":"no"}`, "body.coins_received must be true or false"},
+		{"enum", http.MethodPut, "/api/notifications/email", "/api/notifications/email", `{"mode":"weekly"}`, "body.mode must be one of [off instant digest]"},
+		{"date-time", http.MethodPost, "/api/schedules", "/api/schedules", `{"toUser":"bob","amount":1,"runAt":"tomorrow"}`, "body.runAt must be an RFC 3339 time"},
+		{"query integer", http.MethodGet, "/api/orders?limit=ten", "/api/orders", ``, "query parameter limit must be an integer"},
+		{"query minimum", http.MethodGet, "/api/orders?offset=-1", "/api/orders", ``, "query parameter offset must be at least 0"},
+		{"query enum", http.MethodGet, "/api/history?direction=sideways", "/api/history", ``, "query parameter direction must be one of [sent received]"},
+		{"empty query", http.MethodGet, "/api/history?direction=", "/api/history", ``, ""},
+		{"query boolean", http.MethodGet, "/api/notifications?unread=maybe", "/api/notifications", ``, "query parameter unread must be true or false"},
+		{"path integer", http.MethodGet, "/api/schedules/abc", "/api/schedules/{id}", ``, "path parameter id must be an integer"},
+		{"undocumented route", http.MethodGet, "/api/nowhere?limit=x", "/api/nowhere", ``, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w, got := serve(tc.method, tc.path, tc.route, tc.body)
+			if tc.err == "" {
+				assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+				assert.Equal(t, tc.body, string(got), "the handler gets the body")
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.JSONEq(t, `{"errors":"`+strings.ReplaceAll(tc.err, `"`, `\"`)+`"}`, w.Body.String())
+		})
+	}
+
+	t.Run("body too large", func(t *testing.T) {
+		body := `{"toUser":"` + strings.Repeat("a", MaxBodySize) + `","amount":1}`
+		w, _ := serve(http.MethodPost, "/api/sendCoin", "/api/sendCoin", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("error type", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewBufferString(`{}`))
+		err := validator.ValidateRequest(req, validator.doc.Operation(http.MethodPost, "/api/sendCoin"))
+		var validationErr *ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "body.toUser", validationErr.Location)
+	})
+}
+
+func TestServe(t *testing.T) {
+	w := httptest.NewRecorder()
+	ServeSpec(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, Spec(), w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	ServeDocs(w, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `spec-url="openapi.json"`)
+}
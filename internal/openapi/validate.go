@@ -0,0 +1,287 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+// MaxBodySize is the largest request body the validator reads.
+const MaxBodySize = 1 << 20
+
+// ValidationError says which part of a request does not match the
+// document, such as "body.amount" or "query parameter limit".
+type ValidationError struct {
+	Location string
+	Reason   string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Location + " " + e.Reason
+}
+
+// Validator checks requests against a Document.
+type Validator struct {
+	doc *Document
+}
+
+func NewValidator(doc *Document) *Validator {
+	return &Validator{doc: doc}
+}
+
+// Middleware rejects requests whose parameters or body do not match their
+// operation with 400 Bad Request. It must be installed with mux's Use, so
+// the matched route is known; routes the document does not describe are
+// let through.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		operation := v.doc.Operation(r.Method, template)
+		if operation == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := v.ValidateRequest(r, operation); err != nil {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				utils.WriteErrorResponse(w, err, http.StatusBadRequest)
+				return
+			}
+			utils.WriteErrorResponse(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ValidateRequest checks r's parameters and body against operation. The
+// body is read and replaced, so handlers can still decode it.
+func (v *Validator) ValidateRequest(r *http.Request, operation *Operation) error {
+	for _, param := range operation.Parameters {
+		var value, location string
+		switch param.In {
+		case "path":
+			value, location = mux.Vars(r)[param.Name], "path parameter "+param.Name
+		case "query":
+			value, location = r.URL.Query().Get(param.Name), "query parameter "+param.Name
+		case "header":
+			value, location = r.Header.Get(param.Name), "header "+param.Name
+		default:
+			continue
+		}
+
+		if value == "" {
+			if param.Required {
+				return &ValidationError{location, "is required"}
+			}
+			continue
+		}
+		if err := v.validateParameter(param.Schema, value, location); err != nil {
+			return err
+		}
+	}
+
+	if operation.RequestBody == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &ValidationError{"body", fmt.Sprintf("must not be larger than %d bytes", MaxBodySize)}
+		}
+		return &ValidationError{"body", "could not be read"}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			return &ValidationError{"body", "is required"}
+		}
+		return nil
+	}
+
+	media := operation.RequestBody.Content["application/json"]
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{"body", "must be valid JSON"}
+	}
+
+	return v.validateValue(media.Schema, value, "body")
+}
+
+// validateParameter checks a parameter's string value against schema.
+func (v *Validator) validateParameter(schema *Schema, value, location string) error {
+	if schema == nil {
+		return nil
+	}
+	schema, err := v.doc.resolve(schema)
+	if err != nil {
+		return err
+	}
+
+	switch schema.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return &ValidationError{location, "must be an integer"}
+		}
+		return checkRange(schema, float64(n), location)
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return &ValidationError{location, "must be a number"}
+		}
+		return checkRange(schema, n, location)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return &ValidationError{location, "must be true or false"}
+		}
+		return nil
+	}
+
+	return checkString(schema, value, location)
+}
+
+// validateValue checks a decoded JSON value against schema.
+func (v *Validator) validateValue(schema *Schema, value any, location string) error {
+	schema, err := v.doc.resolve(schema)
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return &ValidationError{location, "must not be null"}
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return &ValidationError{location, "must be an object"}
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{location + "." + name, "is required"}
+			}
+		}
+		// Keys are checked in order so the same request always gets the
+		// same error.
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			property := schema.Properties[name]
+			if property == nil {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				continue
+			}
+			if err := v.validateValue(property, object[name], location+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return &ValidationError{location, "must be an array"}
+		}
+		if schema.Items == nil {
+			return nil
+		}
+		for i, item := range array {
+			if err := v.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", location, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return &ValidationError{location, "must be a string"}
+		}
+		return checkString(schema, s, location)
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return &ValidationError{location, "must be an integer"}
+		}
+		n, err := strconv.ParseInt(number.String(), 10, 64)
+		if err != nil {
+			return &ValidationError{location, "must be an integer"}
+		}
+		return checkRange(schema, float64(n), location)
+	case "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return &ValidationError{location, "must be a number"}
+		}
+		n, err := number.Float64()
+		if err != nil {
+			return &ValidationError{location, "must be a number"}
+		}
+		return checkRange(schema, n, location)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return &ValidationError{location, "must be true or false"}
+		}
+	}
+
+	return nil
+}
+
+func checkString(schema *Schema, value, location string) error {
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, any(value)) {
+		return &ValidationError{location, fmt.Sprintf("must be one of %v", schema.Enum)}
+	}
+	if schema.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return &ValidationError{location, "must be an RFC 3339 time"}
+		}
+	}
+
+	return nil
+}
+
+func checkRange(schema *Schema, n float64, location string) error {
+	if schema.Minimum != nil && n < *schema.Minimum {
+		return &ValidationError{location, fmt.Sprintf("must be at least %v", *schema.Minimum)}
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		return &ValidationError{location, fmt.Sprintf("must be at most %v", *schema.Maximum)}
+	}
+
+	return nil
+}
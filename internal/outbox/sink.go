@@ -0,0 +1,123 @@
+// Package outbox holds the sinks the outbox relay delivers domain events
+// to.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+const (
+	SinkStdout  = "stdout"
+	SinkFile    = "file"
+	SinkWebhook = "webhook"
+)
+
+// WriterSink writes every event to W as one line of JSON.
+type WriterSink struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{
+		W: w,
+	}
+}
+
+func (s *WriterSink) Deliver(ctx context.Context, event *entities.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event %d: %w", event.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.W.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends every event to a file as one line of JSON and syncs it
+// before reporting the event delivered.
+type FileSink struct {
+	file *os.File
+	sink *WriterSink
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open event file: %w", err)
+	}
+
+	return &FileSink{
+		file: file,
+		sink: NewWriterSink(file),
+	}, nil
+}
+
+func (s *FileSink) Deliver(ctx context.Context, event *entities.OutboxEvent) error {
+	if err := s.sink.Deliver(ctx, event); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// WebhookSink POSTs every event as JSON to URL. Any response other than 2xx
+// is a failed delivery. The event id is sent in the X-Event-ID header so the
+// receiver can drop the duplicates at-least-once delivery may produce.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, event *entities.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event %d: %w", event.ID, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.Itoa(event.ID))
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
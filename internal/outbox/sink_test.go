@@ -0,0 +1,86 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvent() *entities.OutboxEvent {
+	return &entities.OutboxEvent{
+		ID:        7,
+		Type:      entities.EventCoinsSent,
+		Key:       "alice",
+		Payload:   json.RawMessage(`{"fromUser":"alice","toUser":"bob","amount":15,"category":"other"}`),
+		CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	assert.NoError(t, sink.Deliver(context.Background(), testEvent()))
+	assert.NoError(t, sink.Deliver(context.Background(), testEvent()))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if assert.Len(t, lines, 2) {
+		var got entities.OutboxEvent
+		assert.NoError(t, json.Unmarshal(lines[0], &got))
+		assert.Equal(t, 7, got.ID)
+		assert.Equal(t, entities.EventCoinsSent, got.Type)
+		assert.JSONEq(t, string(testEvent().Payload), string(got.Payload))
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewFileSink(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sink.Close()
+
+	assert.NoError(t, sink.Deliver(context.Background(), testEvent()))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"CoinsSent"`)
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Run("delivered", func(t *testing.T) {
+		var got *http.Request
+		var body entities.OutboxEvent
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			json.NewDecoder(r.Body).Decode(&body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		assert.NoError(t, NewWebhookSink(server.URL).Deliver(context.Background(), testEvent()))
+		assert.Equal(t, http.MethodPost, got.Method)
+		assert.Equal(t, "7", got.Header.Get("X-Event-ID"))
+		assert.Equal(t, entities.EventCoinsSent, got.Header.Get("X-Event-Type"))
+		assert.Equal(t, "alice", body.Key)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		assert.Error(t, NewWebhookSink(server.URL).Deliver(context.Background(), testEvent()))
+	})
+}
@@ -0,0 +1,2229 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	InitBalance = 1000
+)
+
+// DefaultItems is the catalog a new repository starts with. It mirrors the
+// items seeded by the Postgres migrations.
+var DefaultItems = []struct {
+	Name  string
+	Price int
+}{
+	{"t-shirt", 80},
+	{"cup", 20},
+	{"book", 50},
+	{"pen", 10},
+	{"powerbank", 200},
+	{"hoody", 300},
+	{"umbrella", 200},
+	{"socks", 10},
+	{"wallet", 50},
+}
+
+type user struct {
+	id       int
+	username string
+	password string
+	role     string
+	balance  int
+}
+
+type item struct {
+	id    int
+	name  string
+	price int
+}
+
+type exchange struct {
+	id        int
+	fromID    int
+	toID      int
+	amount    int
+	memo      string
+	category  string
+	createdAt time.Time
+}
+
+type order struct {
+	id     int
+	userID int
+	// recipientID owns the units of a gift; zero for a regular purchase.
+	recipientID int
+	message     string
+	itemID      int
+	quantity    int
+	unitPrice   int
+	refunded    int
+	createdAt   time.Time
+}
+
+// holder returns the id of the user whose inventory holds the order.
+func (o *order) holder() int {
+	if o.recipientID != 0 {
+		return o.recipientID
+	}
+
+	return o.userID
+}
+
+type itemTransfer struct {
+	fromID   int
+	toID     int
+	itemID   int
+	quantity int
+}
+
+type listing struct {
+	id        int
+	sellerID  int
+	itemID    int
+	quantity  int
+	price     int
+	status    string
+	buyerID   int
+	fee       int
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+type schedule struct {
+	id         int
+	fromID     int
+	toID       int
+	amount     int
+	memo       string
+	category   string
+	recurrence string
+	nextRunAt  time.Time
+	anchorDay  int
+	status     string
+	createdAt  time.Time
+}
+
+type scheduleRun struct {
+	id         int
+	scheduleID int
+	runAt      time.Time
+	status     string
+	err        string
+}
+
+type paymentRequest struct {
+	id          int
+	requesterID int
+	payerID     int
+	amount      int
+	memo        string
+	category    string
+	status      string
+	createdAt   time.Time
+	expiresAt   time.Time
+	closedAt    time.Time
+}
+
+type transferLimits struct {
+	limits    entities.TransferLimits
+	updatedBy string
+	updatedAt time.Time
+}
+
+type refund struct {
+	orderID  int
+	userID   int
+	quantity int
+	amount   int
+	actor    string
+}
+
+type outboxEvent struct {
+	entities.OutboxEvent
+	delivered bool
+}
+
+type outboxLease struct {
+	owner     string
+	expiresAt time.Time
+}
+
+type webhookAttempt struct {
+	entities.WebhookAttempt
+	deliveryID int
+}
+
+type notification struct {
+	entities.Notification
+	userID int
+}
+
+func copyNotification(n *notification) *notification {
+	copied := *n
+	if n.ReadAt != nil {
+		readAt := *n.ReadAt
+		copied.ReadAt = &readAt
+	}
+
+	return &copied
+}
+
+func copyEmail(e *entities.Email) *entities.Email {
+	copied := *e
+	if e.SentAt != nil {
+		sentAt := *e.SentAt
+		copied.SentAt = &sentAt
+	}
+
+	return &copied
+}
+
+type state struct {
+	users           map[int]*user
+	usersByName     map[string]*user
+	items           map[int]*item
+	itemsByName     map[string]*item
+	exchanges       []*exchange
+	loginAttempts   map[string]*entities.LoginAttempts
+	lockoutEvents   []*entities.LockoutEvent
+	orders          []*order
+	refunds         []*refund
+	itemTransfers   []*itemTransfer
+	listings        []*listing
+	schedules       []*schedule
+	scheduleRuns    []*scheduleRun
+	paymentRequests []*paymentRequest
+	transferLimits  map[int]*transferLimits
+	adjustments     []*entities.BalanceAdjustment
+	auditLog        []*entities.AuditEvent
+	outbox          []*outboxEvent
+	outboxLease     outboxLease
+	webhooks        []*entities.WebhookEndpoint
+	deliveries      []*entities.WebhookDelivery
+	attempts        []*webhookAttempt
+	userEvents      []*entities.UserEvent
+	notifications   []*notification
+	// notificationPrefs maps user id to the kinds they turned on or off.
+	notificationPrefs map[int]map[string]bool
+	emailSettings     map[int]*entities.EmailSettings
+	emails            []*entities.Email
+
+	nextUserID int
+	// Webhook rows and user events can be deleted, so their ids come
+	// from counters.
+	nextWebhookID   int
+	nextDeliveryID  int
+	nextAttemptID   int
+	nextUserEventID int
+}
+
+func newState() *state {
+	return &state{
+		users:             make(map[int]*user),
+		usersByName:       make(map[string]*user),
+		items:             make(map[int]*item),
+		itemsByName:       make(map[string]*item),
+		loginAttempts:     make(map[string]*entities.LoginAttempts),
+		transferLimits:    make(map[int]*transferLimits),
+		notificationPrefs: make(map[int]map[string]bool),
+		emailSettings:     make(map[int]*entities.EmailSettings),
+		nextUserID:        1,
+		nextWebhookID:     1,
+		nextDeliveryID:    1,
+		nextAttemptID:     1,
+		nextUserEventID:   1,
+	}
+}
+
+// clone returns a copy used to roll a failed transaction back. Logs that
+// are only ever appended to, with entries that never change, share their
+// entries with s; their capacity is capped so appends made after the copy
+// cannot show through it.
+func (s *state) clone() *state {
+	c := newState()
+	c.nextUserID = s.nextUserID
+	c.outboxLease = s.outboxLease
+	c.nextWebhookID = s.nextWebhookID
+	c.nextDeliveryID = s.nextDeliveryID
+	c.nextAttemptID = s.nextAttemptID
+	c.nextUserEventID = s.nextUserEventID
+	c.exchanges = s.exchanges[:len(s.exchanges):len(s.exchanges)]
+	c.lockoutEvents = s.lockoutEvents[:len(s.lockoutEvents):len(s.lockoutEvents)]
+	c.refunds = s.refunds[:len(s.refunds):len(s.refunds)]
+	c.itemTransfers = s.itemTransfers[:len(s.itemTransfers):len(s.itemTransfers)]
+	c.scheduleRuns = s.scheduleRuns[:len(s.scheduleRuns):len(s.scheduleRuns)]
+	c.adjustments = s.adjustments[:len(s.adjustments):len(s.adjustments)]
+	c.auditLog = s.auditLog[:len(s.auditLog):len(s.auditLog)]
+
+	for id, u := range s.users {
+		copied := *u
+		c.users[id] = &copied
+		c.usersByName[copied.username] = &copied
+	}
+	for id, it := range s.items {
+		copied := *it
+		c.items[id] = &copied
+		c.itemsByName[copied.name] = &copied
+	}
+	for name, attempts := range s.loginAttempts {
+		copied := *attempts
+		c.loginAttempts[name] = &copied
+	}
+	for _, o := range s.orders {
+		copied := *o
+		c.orders = append(c.orders, &copied)
+	}
+	for _, l := range s.listings {
+		copied := *l
+		c.listings = append(c.listings, &copied)
+	}
+	for _, sc := range s.schedules {
+		copied := *sc
+		c.schedules = append(c.schedules, &copied)
+	}
+	for _, r := range s.paymentRequests {
+		copied := *r
+		c.paymentRequests = append(c.paymentRequests, &copied)
+	}
+	for userID, l := range s.transferLimits {
+		copied := *l
+		c.transferLimits[userID] = &copied
+	}
+	for _, e := range s.outbox {
+		copied := *e
+		c.outbox = append(c.outbox, &copied)
+	}
+	for _, w := range s.webhooks {
+		c.webhooks = append(c.webhooks, copyWebhook(w))
+	}
+	for _, d := range s.deliveries {
+		c.deliveries = append(c.deliveries, copyDelivery(d))
+	}
+	for _, a := range s.attempts {
+		copied := *a
+		c.attempts = append(c.attempts, &copied)
+	}
+	for _, e := range s.userEvents {
+		copied := *e
+		c.userEvents = append(c.userEvents, &copied)
+	}
+	for _, n := range s.notifications {
+		c.notifications = append(c.notifications, copyNotification(n))
+	}
+	for userID, prefs := range s.notificationPrefs {
+		c.notificationPrefs[userID] = maps.Clone(prefs)
+	}
+	for userID, settings := range s.emailSettings {
+		copied := *settings
+		c.emailSettings[userID] = &copied
+	}
+	for _, e := range s.emails {
+		c.emails = append(c.emails, copyEmail(e))
+	}
+
+	return c
+}
+
+// inventory maps item id to the quantity the user holds: what they ordered
+// minus what was refunded, plus units transferred in and minus units
+// transferred out, with units on open or sold listings moved from the
+// seller to the buyer.
+func (s *state) inventory(userID int) map[int]int {
+	held := make(map[int]int)
+	for _, o := range s.orders {
+		if o.holder() == userID {
+			held[o.itemID] += o.quantity - o.refunded
+		}
+	}
+	for _, t := range s.itemTransfers {
+		if t.toID == userID {
+			held[t.itemID] += t.quantity
+		}
+		if t.fromID == userID {
+			held[t.itemID] -= t.quantity
+		}
+	}
+	for _, l := range s.listings {
+		if l.status != entities.ListingOpen && l.status != entities.ListingSold {
+			continue
+		}
+		if l.sellerID == userID {
+			held[l.itemID] -= l.quantity
+		}
+		if l.status == entities.ListingSold && l.buyerID == userID {
+			held[l.itemID] += l.quantity
+		}
+	}
+	for itemID, quantity := range held {
+		if quantity <= 0 {
+			delete(held, itemID)
+		}
+	}
+
+	return held
+}
+
+func (s *state) listing(l *listing) *entities.Listing {
+	result := &entities.Listing{
+		ID:        l.id,
+		SellerID:  l.sellerID,
+		Seller:    s.users[l.sellerID].username,
+		ItemType:  s.items[l.itemID].name,
+		Quantity:  l.quantity,
+		Price:     l.price,
+		Status:    l.status,
+		Fee:       l.fee,
+		CreatedAt: l.createdAt,
+		ExpiresAt: l.expiresAt,
+	}
+	if l.buyerID != 0 {
+		result.Buyer = s.users[l.buyerID].username
+	}
+
+	return result
+}
+
+func (s *state) schedule(sc *schedule) *entities.ScheduledTransfer {
+	return &entities.ScheduledTransfer{
+		ID:         sc.id,
+		FromUserID: sc.fromID,
+		FromUser:   s.users[sc.fromID].username,
+		ToUserID:   sc.toID,
+		ToUser:     s.users[sc.toID].username,
+		Amount:     sc.amount,
+		Memo:       sc.memo,
+		Category:   sc.category,
+		Recurrence: sc.recurrence,
+		NextRunAt:  sc.nextRunAt,
+		AnchorDay:  sc.anchorDay,
+		Status:     sc.status,
+		CreatedAt:  sc.createdAt,
+	}
+}
+
+func (s *state) paymentRequest(r *paymentRequest) *entities.PaymentRequest {
+	result := &entities.PaymentRequest{
+		ID:          r.id,
+		RequesterID: r.requesterID,
+		Requester:   s.users[r.requesterID].username,
+		PayerID:     r.payerID,
+		Payer:       s.users[r.payerID].username,
+		Amount:      r.amount,
+		Memo:        r.memo,
+		Category:    r.category,
+		Status:      r.status,
+		CreatedAt:   r.createdAt,
+		ExpiresAt:   r.expiresAt,
+	}
+	if !r.closedAt.IsZero() {
+		closedAt := r.closedAt
+		result.ClosedAt = &closedAt
+	}
+
+	return result
+}
+
+func (s *state) order(o *order) *entities.Order {
+	result := &entities.Order{
+		ID:        o.id,
+		ItemType:  s.items[o.itemID].name,
+		Quantity:  o.quantity,
+		UnitPrice: o.unitPrice,
+		Total:     o.quantity * o.unitPrice,
+		Refunded:  o.refunded,
+		Message:   o.message,
+		CreatedAt: o.createdAt,
+	}
+	if o.recipientID != 0 {
+		result.GiftTo = s.users[o.recipientID].username
+	}
+
+	return result
+}
+
+// UserMemoryRepo keeps all state in process memory. It implements
+// service.UserRepo with the same semantics as UserPostgresRepo and is meant
+// for tests and local development. It is safe for concurrent use and is
+// its own service.TxManager.
+type UserMemoryRepo struct {
+	mu sync.RWMutex
+	s  *state
+}
+
+func NewUserMemoryRepo() *UserMemoryRepo {
+	repo := &UserMemoryRepo{
+		s: newState(),
+	}
+
+	for i, it := range DefaultItems {
+		item := &item{id: i + 1, name: it.Name, price: it.Price}
+		repo.s.items[item.id] = item
+		repo.s.itemsByName[item.name] = item
+	}
+
+	return repo
+}
+
+type txKey struct{}
+
+// tx is stored in the ctx passed to WithinTx's fn.
+type tx struct {
+	repo     *UserMemoryRepo
+	readOnly bool
+}
+
+// WithinTx runs fn holding the repository's write lock. Repository calls
+// made with the ctx passed to fn skip locking, and if fn fails every change
+// it made is discarded. Read-only transactions hold the read lock instead,
+// so they run alongside each other and need nothing to roll back; writing
+// in one panics. The isolation level is always serializable, so the rest
+// of opts is ignored.
+func (u *UserMemoryRepo) WithinTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	if u.inTx(ctx) {
+		return fn(ctx)
+	}
+
+	if opts != nil && opts.ReadOnly {
+		u.mu.RLock()
+		defer u.mu.RUnlock()
+
+		return fn(context.WithValue(ctx, txKey{}, tx{repo: u, readOnly: true}))
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	snapshot := u.s.clone()
+	if err := fn(context.WithValue(ctx, txKey{}, tx{repo: u})); err != nil {
+		u.s = snapshot
+		return err
+	}
+
+	return nil
+}
+
+func (u *UserMemoryRepo) inTx(ctx context.Context) bool {
+	t, _ := ctx.Value(txKey{}).(tx)
+	return t.repo == u
+}
+
+func (u *UserMemoryRepo) lock(ctx context.Context) func() {
+	if t, _ := ctx.Value(txKey{}).(tx); t.repo == u {
+		if t.readOnly {
+			panic("memory: write in a read-only transaction")
+		}
+		return func() {}
+	}
+
+	u.mu.Lock()
+	return u.mu.Unlock
+}
+
+func (u *UserMemoryRepo) rlock(ctx context.Context) func() {
+	if u.inTx(ctx) {
+		return func() {}
+	}
+
+	u.mu.RLock()
+	return u.mu.RUnlock
+}
+
+func (u *UserMemoryRepo) GetItemID(ctx context.Context, itemName string) (int, error) {
+	defer u.rlock(ctx)()
+
+	item, ok := u.s.itemsByName[itemName]
+	if !ok {
+		return 0, fmt.Errorf("memory get item id error: %w", utils.ErrNoItem)
+	}
+
+	return item.id, nil
+}
+
+// GetCatalog lists every item the shop sells, by name.
+func (u *UserMemoryRepo) GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error) {
+	defer u.rlock(ctx)()
+
+	catalog := make([]*entities.CatalogItem, 0, len(u.s.items))
+	for _, item := range u.s.items {
+		catalog = append(catalog, &entities.CatalogItem{Name: item.name, Price: item.price})
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+
+	return catalog, nil
+}
+
+// SetItemPrice sets an item's price, adding it to the catalog if it is new.
+func (u *UserMemoryRepo) SetItemPrice(ctx context.Context, itemName string, price int) error {
+	defer u.lock(ctx)()
+
+	if item, ok := u.s.itemsByName[itemName]; ok {
+		item.price = price
+		return nil
+	}
+
+	item := &item{id: len(u.s.items) + 1, name: itemName, price: price}
+	u.s.items[item.id] = item
+	u.s.itemsByName[item.name] = item
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetUserID(ctx context.Context, username string) (int, error) {
+	defer u.rlock(ctx)()
+
+	user, ok := u.s.usersByName[username]
+	if !ok {
+		return 0, fmt.Errorf("memory get user id error: %w", utils.ErrNoUser)
+	}
+
+	return user.id, nil
+}
+
+func (u *UserMemoryRepo) BuyItem(ctx context.Context, userID, itemID int) error {
+	defer u.lock(ctx)()
+
+	return u.buy(userID, 0, itemID, "")
+}
+
+func (u *UserMemoryRepo) GiftItem(ctx context.Context, userID, recipientID, itemID int, message string) error {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[recipientID]; !ok {
+		return fmt.Errorf("memory gift item: %w", utils.ErrNoUser)
+	}
+
+	return u.buy(userID, recipientID, itemID, message)
+}
+
+// buy must be called with the write lock held.
+func (u *UserMemoryRepo) buy(userID, recipientID, itemID int, message string) error {
+	user, ok := u.s.users[userID]
+	if !ok {
+		return fmt.Errorf("get balance error: %w", utils.ErrNoUser)
+	}
+
+	item, ok := u.s.items[itemID]
+	if !ok {
+		return fmt.Errorf("get price error: %w", utils.ErrNoItem)
+	}
+
+	if user.balance < item.price {
+		return fmt.Errorf("buy item error: %w", utils.ErrNotEnoughBalance)
+	}
+
+	user.balance -= item.price
+	u.s.orders = append(u.s.orders, &order{
+		id:          len(u.s.orders) + 1,
+		userID:      userID,
+		recipientID: recipientID,
+		message:     message,
+		itemID:      itemID,
+		quantity:    1,
+		unitPrice:   item.price,
+		createdAt:   time.Now(),
+	})
+
+	return nil
+}
+
+func (u *UserMemoryRepo) SendCoin(ctx context.Context, fromUserID, toUserID int, amount int, memo, category string) error {
+	defer u.lock(ctx)()
+
+	fromUser, ok := u.s.users[fromUserID]
+	if !ok {
+		return fmt.Errorf("send coin error: %w", utils.ErrNoUser)
+	}
+	toUser, ok := u.s.users[toUserID]
+	if !ok {
+		return fmt.Errorf("send coin error: %w", utils.ErrNoUser)
+	}
+
+	if fromUser.balance < amount {
+		return fmt.Errorf("send coin error: %w", utils.ErrNotEnoughBalance)
+	}
+
+	fromUser.balance -= amount
+	toUser.balance += amount
+	u.s.exchanges = append(u.s.exchanges, &exchange{
+		id:        len(u.s.exchanges) + 1,
+		fromID:    fromUserID,
+		toID:      toUserID,
+		amount:    amount,
+		memo:      memo,
+		category:  category,
+		createdAt: time.Now(),
+	})
+
+	return nil
+}
+
+func (u *UserMemoryRepo) Auth(ctx context.Context, username, password string) (*entities.User, error) {
+	defer u.lock(ctx)()
+
+	existing, ok := u.s.usersByName[username]
+	if !ok {
+		user := &user{
+			id:       u.s.nextUserID,
+			username: username,
+			password: password,
+			role:     entities.RoleUser,
+			balance:  InitBalance,
+		}
+		u.s.nextUserID++
+		u.s.users[user.id] = user
+		u.s.usersByName[username] = user
+
+		return &entities.User{
+			Username:   username,
+			Role:       user.role,
+			Registered: true,
+		}, nil
+	}
+
+	if existing.password != password {
+		return nil, fmt.Errorf("memory auth: %w", utils.ErrWrongPass)
+	}
+
+	return &entities.User{
+		Username: username,
+		Role:     existing.role,
+	}, nil
+}
+
+func (u *UserMemoryRepo) GetCoinsInfo(ctx context.Context, userID int) (int, error) {
+	defer u.rlock(ctx)()
+
+	user, ok := u.s.users[userID]
+	if !ok {
+		return 0, fmt.Errorf("get coin info error: %w", utils.ErrNoUser)
+	}
+
+	return user.balance, nil
+}
+
+func (u *UserMemoryRepo) GetInventoryInfo(ctx context.Context, userID int) ([]*entities.Item, error) {
+	defer u.rlock(ctx)()
+
+	held := u.s.inventory(userID)
+	inventory := make([]*entities.Item, 0, len(held))
+	for itemID, quantity := range held {
+		inventory = append(inventory, &entities.Item{
+			ItemType: u.s.items[itemID].name,
+			Quantity: quantity,
+		})
+	}
+
+	sort.Slice(inventory, func(i, j int) bool {
+		return inventory[i].ItemType < inventory[j].ItemType
+	})
+
+	return inventory, nil
+}
+
+func (u *UserMemoryRepo) GetReceiveInfo(ctx context.Context, userID int) ([]*entities.ReceiveOperation, error) {
+	defer u.rlock(ctx)()
+
+	receives := make([]*entities.ReceiveOperation, 0)
+	for _, e := range u.s.exchanges {
+		if e.toID != userID {
+			continue
+		}
+		receives = append(receives, &entities.ReceiveOperation{
+			FromUser: u.s.users[e.fromID].username,
+			Amount:   e.amount,
+			Memo:     e.memo,
+			Category: e.category,
+		})
+	}
+
+	return receives, nil
+}
+
+func (u *UserMemoryRepo) GetSentInfo(ctx context.Context, userID int) ([]*entities.SentOperation, error) {
+	defer u.rlock(ctx)()
+
+	sents := make([]*entities.SentOperation, 0)
+	for _, e := range u.s.exchanges {
+		if e.fromID != userID {
+			continue
+		}
+		sents = append(sents, &entities.SentOperation{
+			ToUser:   u.s.users[e.toID].username,
+			Amount:   e.amount,
+			Memo:     e.memo,
+			Category: e.category,
+		})
+	}
+
+	return sents, nil
+}
+
+// LockLoginAttempts takes the write lock, which a transaction already holds
+// until it ends, so logins for the same username run one at a time.
+func (u *UserMemoryRepo) LockLoginAttempts(ctx context.Context, username string) (*entities.LoginAttempts, error) {
+	defer u.lock(ctx)()
+
+	attempts, ok := u.s.loginAttempts[username]
+	if !ok {
+		return &entities.LoginAttempts{Username: username}, nil
+	}
+
+	copied := *attempts
+	return &copied, nil
+}
+
+func (u *UserMemoryRepo) RegisterFailedLogin(ctx context.Context, username string, at, since time.Time) (int, error) {
+	defer u.lock(ctx)()
+
+	attempts, ok := u.s.loginAttempts[username]
+	if !ok {
+		attempts = &entities.LoginAttempts{Username: username}
+		u.s.loginAttempts[username] = attempts
+	}
+	if attempts.LastFailedAt.Before(since) {
+		attempts.FailedCount = 0
+	}
+	attempts.FailedCount++
+	attempts.LastFailedAt = at
+
+	return attempts.FailedCount, nil
+}
+
+func (u *UserMemoryRepo) BlockLogin(ctx context.Context, username string, until time.Time) error {
+	defer u.lock(ctx)()
+
+	if attempts, ok := u.s.loginAttempts[username]; ok {
+		attempts.BlockedUntil = until
+	}
+
+	return nil
+}
+
+func (u *UserMemoryRepo) ResetLoginAttempts(ctx context.Context, username string) error {
+	defer u.lock(ctx)()
+
+	delete(u.s.loginAttempts, username)
+
+	return nil
+}
+
+func (u *UserMemoryRepo) SetUserRole(ctx context.Context, username, role string) error {
+	defer u.lock(ctx)()
+
+	user, ok := u.s.usersByName[username]
+	if !ok {
+		return fmt.Errorf("memory set user role: %w", utils.ErrNoUser)
+	}
+	user.role = role
+
+	return nil
+}
+
+func (u *UserMemoryRepo) AddLockoutEvent(ctx context.Context, event *entities.LockoutEvent) error {
+	defer u.lock(ctx)()
+
+	copied := *event
+	u.s.lockoutEvents = append(u.s.lockoutEvents, &copied)
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetRefundableOrders(ctx context.Context, userID, itemID int) ([]*entities.Order, error) {
+	defer u.rlock(ctx)()
+
+	orders := make([]*entities.Order, 0)
+	for i := len(u.s.orders) - 1; i >= 0; i-- {
+		o := u.s.orders[i]
+		if o.userID != userID || o.recipientID != 0 || o.itemID != itemID || o.refunded >= o.quantity {
+			continue
+		}
+		orders = append(orders, u.s.order(o))
+	}
+
+	return orders, nil
+}
+
+func (u *UserMemoryRepo) AddRefund(ctx context.Context, r *entities.Refund) error {
+	defer u.lock(ctx)()
+
+	user, ok := u.s.users[r.UserID]
+	if !ok {
+		return fmt.Errorf("memory add refund: %w", utils.ErrNoUser)
+	}
+	if r.OrderID < 1 || r.OrderID > len(u.s.orders) {
+		return fmt.Errorf("memory add refund: order %d not found", r.OrderID)
+	}
+	if u.s.inventory(r.UserID)[r.ItemID] < r.Quantity {
+		return fmt.Errorf("memory add refund: %w", utils.ErrNotEnoughItems)
+	}
+
+	user.balance += r.Amount
+	u.s.orders[r.OrderID-1].refunded += r.Quantity
+	u.s.refunds = append(u.s.refunds, &refund{
+		orderID:  r.OrderID,
+		userID:   r.UserID,
+		quantity: r.Quantity,
+		amount:   r.Amount,
+		actor:    r.Actor,
+	})
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetRefundInfo(ctx context.Context, userID int) ([]*entities.RefundOperation, error) {
+	defer u.rlock(ctx)()
+
+	refunds := make([]*entities.RefundOperation, 0)
+	for _, r := range u.s.refunds {
+		if r.userID != userID {
+			continue
+		}
+		o := u.s.orders[r.orderID-1]
+		refunds = append(refunds, &entities.RefundOperation{
+			Item:       u.s.items[o.itemID].name,
+			Quantity:   r.quantity,
+			Amount:     r.amount,
+			RefundedBy: r.actor,
+		})
+	}
+
+	return refunds, nil
+}
+
+func (u *UserMemoryRepo) GetOrders(ctx context.Context, userID int, itemName string, limit, offset int) ([]*entities.Order, error) {
+	defer u.rlock(ctx)()
+
+	orders := make([]*entities.Order, 0)
+	skipped := 0
+	for i := len(u.s.orders) - 1; i >= 0 && len(orders) < limit; i-- {
+		o := u.s.orders[i]
+		if o.userID != userID || (itemName != "" && u.s.items[o.itemID].name != itemName) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		orders = append(orders, u.s.order(o))
+	}
+
+	return orders, nil
+}
+
+func (u *UserMemoryRepo) CountOrders(ctx context.Context, userID int, itemName string) (int, error) {
+	defer u.rlock(ctx)()
+
+	count := 0
+	for _, o := range u.s.orders {
+		if o.userID == userID && (itemName == "" || u.s.items[o.itemID].name == itemName) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (u *UserMemoryRepo) GetSentGifts(ctx context.Context, userID int) ([]*entities.SentGift, error) {
+	defer u.rlock(ctx)()
+
+	gifts := make([]*entities.SentGift, 0)
+	for _, o := range u.s.orders {
+		if o.userID != userID || o.recipientID == 0 {
+			continue
+		}
+		gifts = append(gifts, &entities.SentGift{
+			ToUser:  u.s.users[o.recipientID].username,
+			Item:    u.s.items[o.itemID].name,
+			Message: o.message,
+		})
+	}
+
+	return gifts, nil
+}
+
+func (u *UserMemoryRepo) GetReceivedGifts(ctx context.Context, userID int) ([]*entities.ReceivedGift, error) {
+	defer u.rlock(ctx)()
+
+	gifts := make([]*entities.ReceivedGift, 0)
+	for _, o := range u.s.orders {
+		if o.recipientID == 0 || o.recipientID != userID {
+			continue
+		}
+		gifts = append(gifts, &entities.ReceivedGift{
+			FromUser: u.s.users[o.userID].username,
+			Item:     u.s.items[o.itemID].name,
+			Message:  o.message,
+		})
+	}
+
+	return gifts, nil
+}
+
+func (u *UserMemoryRepo) TransferItem(ctx context.Context, fromUserID, toUserID, itemID, quantity int) error {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[fromUserID]; !ok {
+		return fmt.Errorf("memory transfer item: %w", utils.ErrNoUser)
+	}
+	if _, ok := u.s.users[toUserID]; !ok {
+		return fmt.Errorf("memory transfer item: %w", utils.ErrNoUser)
+	}
+
+	if u.s.inventory(fromUserID)[itemID] < quantity {
+		return fmt.Errorf("memory transfer item: %w", utils.ErrNotEnoughItems)
+	}
+
+	u.s.itemTransfers = append(u.s.itemTransfers, &itemTransfer{
+		fromID:   fromUserID,
+		toID:     toUserID,
+		itemID:   itemID,
+		quantity: quantity,
+	})
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetSentItems(ctx context.Context, userID int) ([]*entities.SentItemOperation, error) {
+	defer u.rlock(ctx)()
+
+	operations := make([]*entities.SentItemOperation, 0)
+	for _, t := range u.s.itemTransfers {
+		if t.fromID != userID {
+			continue
+		}
+		operations = append(operations, &entities.SentItemOperation{
+			ToUser:   u.s.users[t.toID].username,
+			Item:     u.s.items[t.itemID].name,
+			Quantity: t.quantity,
+		})
+	}
+
+	return operations, nil
+}
+
+func (u *UserMemoryRepo) GetReceivedItems(ctx context.Context, userID int) ([]*entities.ReceivedItemOperation, error) {
+	defer u.rlock(ctx)()
+
+	operations := make([]*entities.ReceivedItemOperation, 0)
+	for _, t := range u.s.itemTransfers {
+		if t.toID != userID {
+			continue
+		}
+		operations = append(operations, &entities.ReceivedItemOperation{
+			FromUser: u.s.users[t.fromID].username,
+			Item:     u.s.items[t.itemID].name,
+			Quantity: t.quantity,
+		})
+	}
+
+	return operations, nil
+}
+
+func (u *UserMemoryRepo) CreateListing(ctx context.Context, sellerID, itemID, quantity, price int, expiresAt time.Time) (int, error) {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[sellerID]; !ok {
+		return 0, fmt.Errorf("memory create listing: %w", utils.ErrNoUser)
+	}
+	if u.s.inventory(sellerID)[itemID] < quantity {
+		return 0, fmt.Errorf("memory create listing: %w", utils.ErrNotEnoughItems)
+	}
+
+	l := &listing{
+		id:        len(u.s.listings) + 1,
+		sellerID:  sellerID,
+		itemID:    itemID,
+		quantity:  quantity,
+		price:     price,
+		status:    entities.ListingOpen,
+		createdAt: time.Now(),
+		expiresAt: expiresAt,
+	}
+	u.s.listings = append(u.s.listings, l)
+
+	return l.id, nil
+}
+
+// findListing must be called with a lock held.
+func (u *UserMemoryRepo) findListing(listingID int) (*listing, bool) {
+	if listingID < 1 || listingID > len(u.s.listings) {
+		return nil, false
+	}
+
+	return u.s.listings[listingID-1], true
+}
+
+func (u *UserMemoryRepo) GetListing(ctx context.Context, listingID int) (*entities.Listing, error) {
+	defer u.rlock(ctx)()
+
+	l, ok := u.findListing(listingID)
+	if !ok {
+		return nil, fmt.Errorf("memory get listing: %w", utils.ErrNoListing)
+	}
+
+	return u.s.listing(l), nil
+}
+
+// openListings returns the listings still for sale at now, newest first.
+func (u *UserMemoryRepo) openListings(itemName string, now time.Time) []*listing {
+	var open []*listing
+	for i := len(u.s.listings) - 1; i >= 0; i-- {
+		l := u.s.listings[i]
+		if l.status != entities.ListingOpen || !l.expiresAt.After(now) {
+			continue
+		}
+		if itemName != "" && u.s.items[l.itemID].name != itemName {
+			continue
+		}
+		open = append(open, l)
+	}
+
+	return open
+}
+
+func (u *UserMemoryRepo) GetListings(ctx context.Context, itemName string, now time.Time, limit, offset int) ([]*entities.Listing, error) {
+	defer u.rlock(ctx)()
+
+	open := u.openListings(itemName, now)
+	listings := make([]*entities.Listing, 0)
+	for i := offset; i < len(open) && len(listings) < limit; i++ {
+		listings = append(listings, u.s.listing(open[i]))
+	}
+
+	return listings, nil
+}
+
+func (u *UserMemoryRepo) CountListings(ctx context.Context, itemName string, now time.Time) (int, error) {
+	defer u.rlock(ctx)()
+
+	return len(u.openListings(itemName, now)), nil
+}
+
+func (u *UserMemoryRepo) SellListing(ctx context.Context, listingID, buyerID, fee int) error {
+	defer u.lock(ctx)()
+
+	l, ok := u.findListing(listingID)
+	if !ok || l.status != entities.ListingOpen {
+		return fmt.Errorf("memory sell listing: %w", utils.ErrListingClosed)
+	}
+
+	buyer, ok := u.s.users[buyerID]
+	if !ok {
+		return fmt.Errorf("memory sell listing: %w", utils.ErrNoUser)
+	}
+	if buyer.balance < l.price {
+		return fmt.Errorf("memory sell listing: %w", utils.ErrNotEnoughBalance)
+	}
+
+	buyer.balance -= l.price
+	u.s.users[l.sellerID].balance += l.price - fee
+	l.status = entities.ListingSold
+	l.buyerID = buyerID
+	l.fee = fee
+
+	return nil
+}
+
+func (u *UserMemoryRepo) CloseListing(ctx context.Context, listingID int, status string) error {
+	defer u.lock(ctx)()
+
+	l, ok := u.findListing(listingID)
+	if !ok || l.status != entities.ListingOpen {
+		return fmt.Errorf("memory close listing: %w", utils.ErrListingClosed)
+	}
+	l.status = status
+
+	return nil
+}
+
+func (u *UserMemoryRepo) ExpireListings(ctx context.Context, now time.Time) (int, error) {
+	defer u.lock(ctx)()
+
+	expired := 0
+	for _, l := range u.s.listings {
+		if l.status == entities.ListingOpen && !l.expiresAt.After(now) {
+			l.status = entities.ListingExpired
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// matchTransfers returns the user's exchanges selected by filter, newest
+// first.
+func (u *UserMemoryRepo) matchTransfers(userID int, filter entities.TransferFilter) []*exchange {
+	query := strings.ToLower(filter.Query)
+
+	var matched []*exchange
+	for i := len(u.s.exchanges) - 1; i >= 0; i-- {
+		e := u.s.exchanges[i]
+		sent := e.fromID == userID && filter.Direction != entities.TransferDirectionReceived
+		received := e.toID == userID && filter.Direction != entities.TransferDirectionSent
+		if !sent && !received {
+			continue
+		}
+		if filter.Category != "" && e.category != filter.Category {
+			continue
+		}
+		if query != "" {
+			found := strings.Contains(strings.ToLower(e.memo), query) ||
+				e.fromID == userID && strings.Contains(strings.ToLower(u.s.users[e.toID].username), query) ||
+				e.toID == userID && strings.Contains(strings.ToLower(u.s.users[e.fromID].username), query)
+			if !found {
+				continue
+			}
+		}
+		matched = append(matched, e)
+	}
+
+	return matched
+}
+
+func (u *UserMemoryRepo) SearchTransfers(ctx context.Context, userID int, filter entities.TransferFilter, limit, offset int) ([]*entities.CoinTransfer, error) {
+	defer u.rlock(ctx)()
+
+	matched := u.matchTransfers(userID, filter)
+	transfers := make([]*entities.CoinTransfer, 0)
+	for i := offset; i < len(matched) && len(transfers) < limit; i++ {
+		e := matched[i]
+		createdAt := e.createdAt
+		transfers = append(transfers, &entities.CoinTransfer{
+			ID:        e.id,
+			FromUser:  u.s.users[e.fromID].username,
+			ToUser:    u.s.users[e.toID].username,
+			Amount:    e.amount,
+			Memo:      e.memo,
+			Category:  e.category,
+			CreatedAt: &createdAt,
+		})
+	}
+
+	return transfers, nil
+}
+
+func (u *UserMemoryRepo) CountTransfers(ctx context.Context, userID int, filter entities.TransferFilter) (int, error) {
+	defer u.rlock(ctx)()
+
+	return len(u.matchTransfers(userID, filter)), nil
+}
+
+func (u *UserMemoryRepo) CreateSchedule(ctx context.Context, sc *entities.ScheduledTransfer) (int, error) {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[sc.FromUserID]; !ok {
+		return 0, fmt.Errorf("memory create schedule: %w", utils.ErrNoUser)
+	}
+	if _, ok := u.s.users[sc.ToUserID]; !ok {
+		return 0, fmt.Errorf("memory create schedule: %w", utils.ErrNoUser)
+	}
+
+	created := &schedule{
+		id:         len(u.s.schedules) + 1,
+		fromID:     sc.FromUserID,
+		toID:       sc.ToUserID,
+		amount:     sc.Amount,
+		memo:       sc.Memo,
+		category:   sc.Category,
+		recurrence: sc.Recurrence,
+		nextRunAt:  sc.NextRunAt,
+		anchorDay:  sc.AnchorDay,
+		status:     entities.ScheduleActive,
+		createdAt:  time.Now(),
+	}
+	u.s.schedules = append(u.s.schedules, created)
+
+	return created.id, nil
+}
+
+// findSchedule must be called with a lock held.
+func (u *UserMemoryRepo) findSchedule(scheduleID int) (*schedule, bool) {
+	if scheduleID < 1 || scheduleID > len(u.s.schedules) {
+		return nil, false
+	}
+
+	return u.s.schedules[scheduleID-1], true
+}
+
+func (u *UserMemoryRepo) GetSchedule(ctx context.Context, scheduleID int) (*entities.ScheduledTransfer, error) {
+	defer u.rlock(ctx)()
+
+	sc, ok := u.findSchedule(scheduleID)
+	if !ok {
+		return nil, fmt.Errorf("memory get schedule: %w", utils.ErrNoSchedule)
+	}
+
+	return u.s.schedule(sc), nil
+}
+
+func (u *UserMemoryRepo) GetSchedules(ctx context.Context, userID int) ([]*entities.ScheduledTransfer, error) {
+	defer u.rlock(ctx)()
+
+	schedules := make([]*entities.ScheduledTransfer, 0)
+	for i := len(u.s.schedules) - 1; i >= 0; i-- {
+		if sc := u.s.schedules[i]; sc.fromID == userID {
+			schedules = append(schedules, u.s.schedule(sc))
+		}
+	}
+
+	return schedules, nil
+}
+
+func (u *UserMemoryRepo) UpdateSchedule(ctx context.Context, updated *entities.ScheduledTransfer) error {
+	defer u.lock(ctx)()
+
+	sc, ok := u.findSchedule(updated.ID)
+	if !ok || sc.status != entities.ScheduleActive {
+		return fmt.Errorf("memory update schedule: %w", utils.ErrScheduleClosed)
+	}
+	if _, ok := u.s.users[updated.ToUserID]; !ok {
+		return fmt.Errorf("memory update schedule: %w", utils.ErrNoUser)
+	}
+
+	sc.toID = updated.ToUserID
+	sc.amount = updated.Amount
+	sc.memo = updated.Memo
+	sc.category = updated.Category
+	sc.recurrence = updated.Recurrence
+	sc.nextRunAt = updated.NextRunAt
+	sc.anchorDay = updated.AnchorDay
+
+	return nil
+}
+
+func (u *UserMemoryRepo) CloseSchedule(ctx context.Context, scheduleID int, status string) error {
+	defer u.lock(ctx)()
+
+	sc, ok := u.findSchedule(scheduleID)
+	if !ok || sc.status != entities.ScheduleActive {
+		return fmt.Errorf("memory close schedule: %w", utils.ErrScheduleClosed)
+	}
+	sc.status = status
+
+	return nil
+}
+
+// ClaimDueSchedule returns the earliest schedule due at now. Inside a
+// transaction the whole repository is locked, so no one else can claim it
+// before the transaction ends.
+func (u *UserMemoryRepo) ClaimDueSchedule(ctx context.Context, now time.Time) (*entities.ScheduledTransfer, error) {
+	defer u.rlock(ctx)()
+
+	var due *schedule
+	for _, sc := range u.s.schedules {
+		if sc.status != entities.ScheduleActive || sc.nextRunAt.After(now) {
+			continue
+		}
+		if due == nil || sc.nextRunAt.Before(due.nextRunAt) {
+			due = sc
+		}
+	}
+	if due == nil {
+		return nil, fmt.Errorf("memory claim due schedule: %w", utils.ErrNoSchedule)
+	}
+
+	return u.s.schedule(due), nil
+}
+
+func (u *UserMemoryRepo) AddScheduleRun(ctx context.Context, scheduleID int, run *entities.ScheduledTransferRun) error {
+	defer u.lock(ctx)()
+
+	if _, ok := u.findSchedule(scheduleID); !ok {
+		return fmt.Errorf("memory add schedule run: %w", utils.ErrNoSchedule)
+	}
+
+	run.ID = len(u.s.scheduleRuns) + 1
+	u.s.scheduleRuns = append(u.s.scheduleRuns, &scheduleRun{
+		id:         run.ID,
+		scheduleID: scheduleID,
+		runAt:      run.RunAt,
+		status:     run.Status,
+		err:        run.Error,
+	})
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetScheduleRuns(ctx context.Context, scheduleID, limit int) ([]*entities.ScheduledTransferRun, error) {
+	defer u.rlock(ctx)()
+
+	runs := make([]*entities.ScheduledTransferRun, 0)
+	for i := len(u.s.scheduleRuns) - 1; i >= 0 && len(runs) < limit; i-- {
+		if r := u.s.scheduleRuns[i]; r.scheduleID == scheduleID {
+			runs = append(runs, &entities.ScheduledTransferRun{
+				ID:     r.id,
+				RunAt:  r.runAt,
+				Status: r.status,
+				Error:  r.err,
+			})
+		}
+	}
+
+	return runs, nil
+}
+
+func (u *UserMemoryRepo) CreatePaymentRequest(ctx context.Context, request *entities.PaymentRequest) (int, error) {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[request.RequesterID]; !ok {
+		return 0, fmt.Errorf("memory create payment request: %w", utils.ErrNoUser)
+	}
+	if _, ok := u.s.users[request.PayerID]; !ok {
+		return 0, fmt.Errorf("memory create payment request: %w", utils.ErrNoUser)
+	}
+
+	created := &paymentRequest{
+		id:          len(u.s.paymentRequests) + 1,
+		requesterID: request.RequesterID,
+		payerID:     request.PayerID,
+		amount:      request.Amount,
+		memo:        request.Memo,
+		category:    request.Category,
+		status:      entities.PaymentRequestPending,
+		createdAt:   time.Now(),
+		expiresAt:   request.ExpiresAt,
+	}
+	u.s.paymentRequests = append(u.s.paymentRequests, created)
+
+	return created.id, nil
+}
+
+// findPaymentRequest must be called with a lock held.
+func (u *UserMemoryRepo) findPaymentRequest(requestID int) (*paymentRequest, bool) {
+	if requestID < 1 || requestID > len(u.s.paymentRequests) {
+		return nil, false
+	}
+
+	return u.s.paymentRequests[requestID-1], true
+}
+
+func (u *UserMemoryRepo) GetPaymentRequest(ctx context.Context, requestID int) (*entities.PaymentRequest, error) {
+	defer u.rlock(ctx)()
+
+	r, ok := u.findPaymentRequest(requestID)
+	if !ok {
+		return nil, fmt.Errorf("memory get payment request: %w", utils.ErrNoPaymentRequest)
+	}
+
+	return u.s.paymentRequest(r), nil
+}
+
+// matchPaymentRequests returns the user's payment requests selected by
+// filter, newest first.
+func (u *UserMemoryRepo) matchPaymentRequests(userID int, filter entities.PaymentRequestFilter) []*paymentRequest {
+	var matched []*paymentRequest
+	for i := len(u.s.paymentRequests) - 1; i >= 0; i-- {
+		r := u.s.paymentRequests[i]
+		outgoing := r.requesterID == userID && filter.Direction != entities.PaymentRequestIncoming
+		incoming := r.payerID == userID && filter.Direction != entities.PaymentRequestOutgoing
+		if !outgoing && !incoming {
+			continue
+		}
+		if filter.Status != "" && r.status != filter.Status {
+			continue
+		}
+		matched = append(matched, r)
+	}
+
+	return matched
+}
+
+func (u *UserMemoryRepo) GetPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter, limit, offset int) ([]*entities.PaymentRequest, error) {
+	defer u.rlock(ctx)()
+
+	matched := u.matchPaymentRequests(userID, filter)
+	requests := make([]*entities.PaymentRequest, 0)
+	for i := offset; i < len(matched) && len(requests) < limit; i++ {
+		requests = append(requests, u.s.paymentRequest(matched[i]))
+	}
+
+	return requests, nil
+}
+
+func (u *UserMemoryRepo) CountPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter) (int, error) {
+	defer u.rlock(ctx)()
+
+	return len(u.matchPaymentRequests(userID, filter)), nil
+}
+
+func (u *UserMemoryRepo) ClosePaymentRequest(ctx context.Context, requestID int, status string) error {
+	defer u.lock(ctx)()
+
+	r, ok := u.findPaymentRequest(requestID)
+	if !ok || r.status != entities.PaymentRequestPending {
+		return fmt.Errorf("memory close payment request: %w", utils.ErrPaymentRequestClosed)
+	}
+	r.status = status
+	r.closedAt = time.Now()
+
+	return nil
+}
+
+func (u *UserMemoryRepo) ExpirePaymentRequests(ctx context.Context, now time.Time) ([]*entities.PaymentRequest, error) {
+	defer u.lock(ctx)()
+
+	expired := make([]*entities.PaymentRequest, 0)
+	for _, r := range u.s.paymentRequests {
+		if r.status == entities.PaymentRequestPending && !r.expiresAt.After(now) {
+			r.status = entities.PaymentRequestExpired
+			r.closedAt = now
+			expired = append(expired, u.s.paymentRequest(r))
+		}
+	}
+
+	return expired, nil
+}
+
+func (u *UserMemoryRepo) GetTransferLimits(ctx context.Context, userID int) (*entities.UserTransferLimits, error) {
+	defer u.rlock(ctx)()
+
+	l, ok := u.s.transferLimits[userID]
+	if !ok {
+		return nil, fmt.Errorf("memory get transfer limits: %w", utils.ErrNoTransferLimits)
+	}
+	updatedAt := l.updatedAt
+
+	return &entities.UserTransferLimits{
+		Username:       u.s.users[userID].username,
+		TransferLimits: l.limits,
+		Override:       true,
+		UpdatedBy:      l.updatedBy,
+		UpdatedAt:      &updatedAt,
+	}, nil
+}
+
+func (u *UserMemoryRepo) SetTransferLimits(ctx context.Context, userID int, limits entities.TransferLimits, actor string) error {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[userID]; !ok {
+		return fmt.Errorf("memory set transfer limits: %w", utils.ErrNoUser)
+	}
+	u.s.transferLimits[userID] = &transferLimits{
+		limits:    limits,
+		updatedBy: actor,
+		updatedAt: time.Now(),
+	}
+
+	return nil
+}
+
+func (u *UserMemoryRepo) DeleteTransferLimits(ctx context.Context, userID int) error {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.transferLimits[userID]; !ok {
+		return fmt.Errorf("memory delete transfer limits: %w", utils.ErrNoTransferLimits)
+	}
+	delete(u.s.transferLimits, userID)
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetTransferUsage(ctx context.Context, fromUserID, toUserID int, since time.Time) (*entities.TransferUsage, error) {
+	defer u.rlock(ctx)()
+
+	usage := &entities.TransferUsage{}
+	recipients := make(map[int]bool)
+	for _, e := range u.s.exchanges {
+		if e.fromID != fromUserID || e.createdAt.Before(since) {
+			continue
+		}
+		usage.Amount += e.amount
+		if e.toID != toUserID {
+			recipients[e.toID] = true
+		}
+	}
+	usage.Recipients = len(recipients)
+
+	return usage, nil
+}
+
+func (u *UserMemoryRepo) AdjustBalance(ctx context.Context, userID, delta int) (int, error) {
+	defer u.lock(ctx)()
+
+	user, ok := u.s.users[userID]
+	if !ok {
+		return 0, fmt.Errorf("memory adjust balance: %w", utils.ErrNoUser)
+	}
+	if user.balance+delta < 0 {
+		return 0, fmt.Errorf("memory adjust balance: %w", utils.ErrNotEnoughBalance)
+	}
+	user.balance += delta
+
+	return user.balance, nil
+}
+
+func (u *UserMemoryRepo) AddBalanceAdjustment(ctx context.Context, adjustment *entities.BalanceAdjustment) error {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[adjustment.UserID]; !ok {
+		return fmt.Errorf("memory add balance adjustment: %w", utils.ErrNoUser)
+	}
+	adjustment.ID = len(u.s.adjustments) + 1
+	copied := *adjustment
+	u.s.adjustments = append(u.s.adjustments, &copied)
+
+	return nil
+}
+
+// matchAdjustments returns userID's balance adjustments, or everyone's if
+// userID is 0, newest first.
+func (u *UserMemoryRepo) matchAdjustments(userID int) []*entities.BalanceAdjustment {
+	var matched []*entities.BalanceAdjustment
+	for i := len(u.s.adjustments) - 1; i >= 0; i-- {
+		if a := u.s.adjustments[i]; userID == 0 || a.UserID == userID {
+			matched = append(matched, a)
+		}
+	}
+
+	return matched
+}
+
+func (u *UserMemoryRepo) GetBalanceAdjustments(ctx context.Context, userID, limit, offset int) ([]*entities.BalanceAdjustment, error) {
+	defer u.rlock(ctx)()
+
+	matched := u.matchAdjustments(userID)
+	adjustments := make([]*entities.BalanceAdjustment, 0)
+	for i := offset; i < len(matched) && len(adjustments) < limit; i++ {
+		copied := *matched[i]
+		copied.Username = u.s.users[copied.UserID].username
+		adjustments = append(adjustments, &copied)
+	}
+
+	return adjustments, nil
+}
+
+func (u *UserMemoryRepo) CountBalanceAdjustments(ctx context.Context, userID int) (int, error) {
+	defer u.rlock(ctx)()
+
+	return len(u.matchAdjustments(userID)), nil
+}
+
+func (u *UserMemoryRepo) LockAuditLog(ctx context.Context) error {
+	defer u.lock(ctx)()
+
+	return nil
+}
+
+func (u *UserMemoryRepo) AddAuditEvent(ctx context.Context, event *entities.AuditEvent) error {
+	defer u.lock(ctx)()
+
+	// Mirrors the unique prev_hash constraint of the SQL stores.
+	for _, e := range u.s.auditLog {
+		if e.PrevHash == event.PrevHash {
+			return fmt.Errorf("memory add audit event: prev hash %q already continued by event %d", event.PrevHash, e.ID)
+		}
+	}
+	event.ID = len(u.s.auditLog) + 1
+	copied := *event
+	copied.CreatedAt = copied.CreatedAt.UTC()
+	u.s.auditLog = append(u.s.auditLog, &copied)
+
+	return nil
+}
+
+func (u *UserMemoryRepo) LastAuditEvent(ctx context.Context, action string) (*entities.AuditEvent, error) {
+	defer u.rlock(ctx)()
+
+	for i := len(u.s.auditLog) - 1; i >= 0; i-- {
+		if e := u.s.auditLog[i]; action == "" || e.Action == action {
+			copied := *e
+			return &copied, nil
+		}
+	}
+
+	return nil, fmt.Errorf("memory last audit event: %w", utils.ErrNoAuditEvent)
+}
+
+// matchAuditEvents returns the audit events matching filter, in log order.
+func (u *UserMemoryRepo) matchAuditEvents(filter entities.AuditFilter) []*entities.AuditEvent {
+	var matched []*entities.AuditEvent
+	for _, e := range u.s.auditLog {
+		if filter.Action != "" && e.Action != filter.Action ||
+			filter.Actor != "" && e.Actor != filter.Actor ||
+			filter.Target != "" && e.Target != filter.Target ||
+			!filter.Since.IsZero() && e.CreatedAt.Before(filter.Since) ||
+			!filter.Until.IsZero() && !e.CreatedAt.Before(filter.Until) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	return matched
+}
+
+func (u *UserMemoryRepo) GetAuditEvents(ctx context.Context, filter entities.AuditFilter, afterID, limit int) ([]*entities.AuditEvent, error) {
+	defer u.rlock(ctx)()
+
+	events := make([]*entities.AuditEvent, 0)
+	for _, e := range u.matchAuditEvents(filter) {
+		if len(events) == limit {
+			break
+		}
+		if e.ID > afterID {
+			copied := *e
+			events = append(events, &copied)
+		}
+	}
+
+	return events, nil
+}
+
+func (u *UserMemoryRepo) CountAuditEvents(ctx context.Context, filter entities.AuditFilter) (int, error) {
+	defer u.rlock(ctx)()
+
+	return len(u.matchAuditEvents(filter)), nil
+}
+
+func (u *UserMemoryRepo) AddOutboxEvent(ctx context.Context, event *entities.OutboxEvent) error {
+	defer u.lock(ctx)()
+
+	event.ID = len(u.s.outbox) + 1
+	copied := outboxEvent{OutboxEvent: *event}
+	copied.Payload = append([]byte(nil), event.Payload...)
+	copied.CreatedAt = copied.CreatedAt.UTC()
+	copied.NextAttemptAt = copied.CreatedAt
+	u.s.outbox = append(u.s.outbox, &copied)
+
+	return nil
+}
+
+func (u *UserMemoryRepo) AcquireOutboxLease(ctx context.Context, owner string, now, until time.Time) (bool, error) {
+	defer u.lock(ctx)()
+
+	lease := &u.s.outboxLease
+	if lease.owner != "" && lease.owner != owner && lease.expiresAt.After(now) {
+		return false, nil
+	}
+	lease.owner = owner
+	lease.expiresAt = until.UTC()
+
+	return true, nil
+}
+
+func (u *UserMemoryRepo) GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEvent, error) {
+	defer u.rlock(ctx)()
+
+	// Keys with an earlier event waiting for a retry are held back.
+	held := make(map[string]bool)
+	events := make([]*entities.OutboxEvent, 0)
+	for _, e := range u.s.outbox {
+		if len(events) == limit {
+			break
+		}
+		if e.delivered {
+			continue
+		}
+		if e.NextAttemptAt.After(now) {
+			held[e.Key] = true
+			continue
+		}
+		if held[e.Key] {
+			continue
+		}
+		copied := e.OutboxEvent
+		copied.Payload = append([]byte(nil), e.Payload...)
+		events = append(events, &copied)
+	}
+
+	return events, nil
+}
+
+func (u *UserMemoryRepo) MarkOutboxDelivered(ctx context.Context, eventID int, at time.Time) error {
+	defer u.lock(ctx)()
+
+	if eventID >= 1 && eventID <= len(u.s.outbox) {
+		u.s.outbox[eventID-1].delivered = true
+	}
+
+	return nil
+}
+
+func (u *UserMemoryRepo) DeferOutboxEvent(ctx context.Context, eventID int, lastError string, until time.Time) error {
+	defer u.lock(ctx)()
+
+	if eventID < 1 || eventID > len(u.s.outbox) {
+		return nil
+	}
+	e := u.s.outbox[eventID-1]
+	if e.delivered {
+		return nil
+	}
+	e.Attempts++
+	e.LastError = lastError
+	e.NextAttemptAt = until.UTC()
+
+	return nil
+}
+
+func copyWebhook(w *entities.WebhookEndpoint) *entities.WebhookEndpoint {
+	copied := *w
+	copied.EventTypes = slices.Clone(w.EventTypes)
+	return &copied
+}
+
+func copyDelivery(d *entities.WebhookDelivery) *entities.WebhookDelivery {
+	copied := *d
+	copied.Payload = slices.Clone(d.Payload)
+	if d.DeliveredAt != nil {
+		deliveredAt := *d.DeliveredAt
+		copied.DeliveredAt = &deliveredAt
+	}
+	copied.Log = nil
+	return &copied
+}
+
+func (u *UserMemoryRepo) AddWebhookEndpoint(ctx context.Context, endpoint *entities.WebhookEndpoint) error {
+	defer u.lock(ctx)()
+
+	if _, ok := u.s.users[endpoint.UserID]; !ok {
+		return fmt.Errorf("memory add webhook endpoint: %w", utils.ErrNoUser)
+	}
+	endpoint.ID = u.s.nextWebhookID
+	u.s.nextWebhookID++
+	u.s.webhooks = append(u.s.webhooks, copyWebhook(endpoint))
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetWebhookEndpoint(ctx context.Context, endpointID int) (*entities.WebhookEndpoint, error) {
+	defer u.rlock(ctx)()
+
+	for _, w := range u.s.webhooks {
+		if w.ID == endpointID {
+			return copyWebhook(w), nil
+		}
+	}
+
+	return nil, fmt.Errorf("memory get webhook endpoint: %w", utils.ErrNoWebhook)
+}
+
+func (u *UserMemoryRepo) GetWebhookEndpoints(ctx context.Context, userID int) ([]*entities.WebhookEndpoint, error) {
+	defer u.rlock(ctx)()
+
+	endpoints := make([]*entities.WebhookEndpoint, 0)
+	for _, w := range u.s.webhooks {
+		if w.UserID == userID {
+			endpoints = append(endpoints, copyWebhook(w))
+		}
+	}
+
+	return endpoints, nil
+}
+
+func (u *UserMemoryRepo) DeleteWebhookEndpoint(ctx context.Context, endpointID int) error {
+	defer u.lock(ctx)()
+
+	deleted := make(map[int]bool)
+	u.s.deliveries = slices.DeleteFunc(u.s.deliveries, func(d *entities.WebhookDelivery) bool {
+		deleted[d.ID] = d.EndpointID == endpointID
+		return deleted[d.ID]
+	})
+	u.s.attempts = slices.DeleteFunc(u.s.attempts, func(a *webhookAttempt) bool {
+		return deleted[a.deliveryID]
+	})
+	u.s.webhooks = slices.DeleteFunc(u.s.webhooks, func(w *entities.WebhookEndpoint) bool {
+		return w.ID == endpointID
+	})
+
+	return nil
+}
+
+func (u *UserMemoryRepo) AddWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	defer u.lock(ctx)()
+
+	delivery.ID = u.s.nextDeliveryID
+	u.s.nextDeliveryID++
+	u.s.deliveries = append(u.s.deliveries, copyDelivery(delivery))
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetWebhookDelivery(ctx context.Context, deliveryID int) (*entities.WebhookDelivery, error) {
+	defer u.rlock(ctx)()
+
+	for _, d := range u.s.deliveries {
+		if d.ID == deliveryID {
+			return copyDelivery(d), nil
+		}
+	}
+
+	return nil, fmt.Errorf("memory get webhook delivery: %w", utils.ErrNoWebhookDelivery)
+}
+
+// matchDeliveries returns the endpoint's deliveries with status, or all of
+// them for an empty status, newest first.
+func (u *UserMemoryRepo) matchDeliveries(endpointID int, status string) []*entities.WebhookDelivery {
+	var matched []*entities.WebhookDelivery
+	for i := len(u.s.deliveries) - 1; i >= 0; i-- {
+		d := u.s.deliveries[i]
+		if d.EndpointID == endpointID && (status == "" || d.Status == status) {
+			matched = append(matched, d)
+		}
+	}
+
+	return matched
+}
+
+func (u *UserMemoryRepo) GetWebhookDeliveries(ctx context.Context, endpointID int, status string, limit, offset int) ([]*entities.WebhookDelivery, error) {
+	defer u.rlock(ctx)()
+
+	deliveries := make([]*entities.WebhookDelivery, 0)
+	for i, d := range u.matchDeliveries(endpointID, status) {
+		if len(deliveries) == limit {
+			break
+		}
+		if i >= offset {
+			deliveries = append(deliveries, copyDelivery(d))
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (u *UserMemoryRepo) CountWebhookDeliveries(ctx context.Context, endpointID int, status string) (int, error) {
+	defer u.rlock(ctx)()
+
+	return len(u.matchDeliveries(endpointID, status)), nil
+}
+
+func (u *UserMemoryRepo) ClaimDueWebhookDelivery(ctx context.Context, now, until time.Time) (*entities.WebhookDelivery, error) {
+	defer u.lock(ctx)()
+
+	var due *entities.WebhookDelivery
+	for _, d := range u.s.deliveries {
+		if d.Status != entities.WebhookDeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		if due == nil || d.NextAttemptAt.Before(due.NextAttemptAt) {
+			due = d
+		}
+	}
+	if due == nil {
+		return nil, fmt.Errorf("memory claim due webhook delivery: %w", utils.ErrNoWebhookDelivery)
+	}
+	due.NextAttemptAt = until.UTC()
+
+	return copyDelivery(due), nil
+}
+
+func (u *UserMemoryRepo) UpdateWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	defer u.lock(ctx)()
+
+	for _, d := range u.s.deliveries {
+		if d.ID == delivery.ID {
+			updated := copyDelivery(delivery)
+			d.Status = updated.Status
+			d.Attempts = updated.Attempts
+			d.LastError = updated.LastError
+			d.NextAttemptAt = updated.NextAttemptAt
+			d.DeliveredAt = updated.DeliveredAt
+		}
+	}
+
+	return nil
+}
+
+func (u *UserMemoryRepo) AddWebhookAttempt(ctx context.Context, deliveryID int, attempt *entities.WebhookAttempt) error {
+	defer u.lock(ctx)()
+
+	attempt.ID = u.s.nextAttemptID
+	u.s.nextAttemptID++
+	u.s.attempts = append(u.s.attempts, &webhookAttempt{WebhookAttempt: *attempt, deliveryID: deliveryID})
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetWebhookAttempts(ctx context.Context, deliveryID int) ([]*entities.WebhookAttempt, error) {
+	defer u.rlock(ctx)()
+
+	attempts := make([]*entities.WebhookAttempt, 0)
+	for _, a := range u.s.attempts {
+		if a.deliveryID == deliveryID {
+			copied := a.WebhookAttempt
+			attempts = append(attempts, &copied)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (u *UserMemoryRepo) AddUserEvent(ctx context.Context, event *entities.UserEvent) error {
+	defer u.lock(ctx)()
+
+	event.ID = u.s.nextUserEventID
+	u.s.nextUserEventID++
+	copied := *event
+	copied.Data = slices.Clone(event.Data)
+	u.s.userEvents = append(u.s.userEvents, &copied)
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetUserEvents(ctx context.Context, userID, afterID, limit int) ([]*entities.UserEvent, error) {
+	defer u.rlock(ctx)()
+
+	events := make([]*entities.UserEvent, 0)
+	for _, e := range u.s.userEvents {
+		if len(events) == limit {
+			break
+		}
+		if e.UserID != userID || e.ID <= afterID {
+			continue
+		}
+		copied := *e
+		events = append(events, &copied)
+	}
+
+	return events, nil
+}
+
+func (u *UserMemoryRepo) LastUserEventID(ctx context.Context, userID int) (int, error) {
+	defer u.rlock(ctx)()
+
+	for i := len(u.s.userEvents) - 1; i >= 0; i-- {
+		if u.s.userEvents[i].UserID == userID {
+			return u.s.userEvents[i].ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (u *UserMemoryRepo) DeleteUserEvents(ctx context.Context, before time.Time) (int, error) {
+	defer u.lock(ctx)()
+
+	kept := len(u.s.userEvents)
+	u.s.userEvents = slices.DeleteFunc(u.s.userEvents, func(e *entities.UserEvent) bool {
+		return e.CreatedAt.Before(before)
+	})
+
+	return kept - len(u.s.userEvents), nil
+}
+
+func (u *UserMemoryRepo) AddNotification(ctx context.Context, userID int, n *entities.Notification) error {
+	defer u.lock(ctx)()
+
+	n.ID = len(u.s.notifications) + 1
+	u.s.notifications = append(u.s.notifications, copyNotification(&notification{Notification: *n, userID: userID}))
+
+	return nil
+}
+
+// userNotifications returns the user's notifications, newest first.
+func (u *UserMemoryRepo) userNotifications(userID int, unreadOnly bool) []*notification {
+	var notifications []*notification
+	for i := len(u.s.notifications) - 1; i >= 0; i-- {
+		n := u.s.notifications[i]
+		if n.userID == userID && (!unreadOnly || n.ReadAt == nil) {
+			notifications = append(notifications, n)
+		}
+	}
+
+	return notifications
+}
+
+func (u *UserMemoryRepo) GetNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*entities.Notification, error) {
+	defer u.rlock(ctx)()
+
+	matched := u.userNotifications(userID, unreadOnly)
+	notifications := make([]*entities.Notification, 0)
+	for i := offset; i < len(matched) && len(notifications) < limit; i++ {
+		copied := copyNotification(matched[i]).Notification
+		notifications = append(notifications, &copied)
+	}
+
+	return notifications, nil
+}
+
+func (u *UserMemoryRepo) CountNotifications(ctx context.Context, userID int, unreadOnly bool) (int, error) {
+	defer u.rlock(ctx)()
+
+	return len(u.userNotifications(userID, unreadOnly)), nil
+}
+
+func (u *UserMemoryRepo) MarkNotificationRead(ctx context.Context, userID, notificationID int, at time.Time) error {
+	defer u.lock(ctx)()
+
+	for _, n := range u.s.notifications {
+		if n.ID != notificationID || n.userID != userID {
+			continue
+		}
+		if n.ReadAt == nil {
+			n.ReadAt = &at
+		}
+		return nil
+	}
+
+	return fmt.Errorf("memory mark notification read: %w", utils.ErrNoNotification)
+}
+
+func (u *UserMemoryRepo) MarkAllNotificationsRead(ctx context.Context, userID int, at time.Time) (int, error) {
+	defer u.lock(ctx)()
+
+	marked := 0
+	for _, n := range u.s.notifications {
+		if n.userID == userID && n.ReadAt == nil {
+			n.ReadAt = &at
+			marked++
+		}
+	}
+
+	return marked, nil
+}
+
+func (u *UserMemoryRepo) GetNotificationPreferences(ctx context.Context, userID int) (entities.NotificationPreferences, error) {
+	defer u.rlock(ctx)()
+
+	preferences := make(entities.NotificationPreferences)
+	for kind, enabled := range u.s.notificationPrefs[userID] {
+		preferences[kind] = enabled
+	}
+
+	return preferences, nil
+}
+
+func (u *UserMemoryRepo) SetNotificationPreference(ctx context.Context, userID int, kind string, enabled bool) error {
+	defer u.lock(ctx)()
+
+	if u.s.notificationPrefs[userID] == nil {
+		u.s.notificationPrefs[userID] = make(map[string]bool)
+	}
+	u.s.notificationPrefs[userID][kind] = enabled
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetNotificationsSince(ctx context.Context, userID int, since time.Time, limit int) ([]*entities.Notification, error) {
+	defer u.rlock(ctx)()
+
+	notifications := make([]*entities.Notification, 0)
+	for _, n := range u.s.notifications {
+		if len(notifications) == limit {
+			break
+		}
+		if n.userID != userID || !n.CreatedAt.After(since) {
+			continue
+		}
+		copied := copyNotification(n).Notification
+		notifications = append(notifications, &copied)
+	}
+
+	return notifications, nil
+}
+
+func (u *UserMemoryRepo) GetEmailSettings(ctx context.Context, userID int) (*entities.EmailSettings, error) {
+	defer u.rlock(ctx)()
+
+	settings, ok := u.s.emailSettings[userID]
+	if !ok {
+		return nil, fmt.Errorf("memory get email settings: %w", utils.ErrNoEmailSettings)
+	}
+	copied := *settings
+
+	return &copied, nil
+}
+
+func (u *UserMemoryRepo) SetEmailSettings(ctx context.Context, userID int, settings *entities.EmailSettings) error {
+	defer u.lock(ctx)()
+
+	copied := *settings
+	u.s.emailSettings[userID] = &copied
+
+	return nil
+}
+
+func (u *UserMemoryRepo) GetDueEmailDigests(ctx context.Context, before time.Time, limit int) ([]*entities.EmailDigest, error) {
+	defer u.rlock(ctx)()
+
+	digests := make([]*entities.EmailDigest, 0)
+	for userID, settings := range u.s.emailSettings {
+		if settings.Mode != entities.EmailModeDigest || !settings.DigestSentAt.Before(before) {
+			continue
+		}
+		digests = append(digests, &entities.EmailDigest{
+			UserID:   userID,
+			Username: u.s.users[userID].username,
+			Address:  settings.Address,
+			Since:    settings.DigestSentAt,
+		})
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		if !digests[i].Since.Equal(digests[j].Since) {
+			return digests[i].Since.Before(digests[j].Since)
+		}
+		return digests[i].UserID < digests[j].UserID
+	})
+
+	return digests[:min(limit, len(digests))], nil
+}
+
+func (u *UserMemoryRepo) MarkEmailDigestSent(ctx context.Context, userID int, at time.Time) error {
+	defer u.lock(ctx)()
+
+	if settings, ok := u.s.emailSettings[userID]; ok {
+		settings.DigestSentAt = at
+	}
+
+	return nil
+}
+
+func (u *UserMemoryRepo) AddEmail(ctx context.Context, email *entities.Email) error {
+	defer u.lock(ctx)()
+
+	email.ID = len(u.s.emails) + 1
+	u.s.emails = append(u.s.emails, copyEmail(email))
+
+	return nil
+}
+
+func (u *UserMemoryRepo) ClaimDueEmail(ctx context.Context, now, until time.Time) (*entities.Email, error) {
+	defer u.lock(ctx)()
+
+	var due *entities.Email
+	for _, e := range u.s.emails {
+		if e.Status != entities.EmailPending || e.NextAttemptAt.After(now) {
+			continue
+		}
+		if due == nil || e.NextAttemptAt.Before(due.NextAttemptAt) {
+			due = e
+		}
+	}
+	if due == nil {
+		return nil, fmt.Errorf("memory claim due email: %w", utils.ErrNoEmail)
+	}
+	due.NextAttemptAt = until
+
+	return copyEmail(due), nil
+}
+
+func (u *UserMemoryRepo) UpdateEmail(ctx context.Context, email *entities.Email) error {
+	defer u.lock(ctx)()
+
+	for i, e := range u.s.emails {
+		if e.ID == email.ID {
+			updated := copyEmail(e)
+			updated.Status = email.Status
+			updated.Attempts = email.Attempts
+			updated.LastError = email.LastError
+			updated.NextAttemptAt = email.NextAttemptAt
+			updated.SentAt = copyEmail(email).SentAt
+			u.s.emails[i] = updated
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/repotest"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserMemoryRepoContract(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) service.UserRepo {
+		return NewUserMemoryRepo()
+	})
+}
+
+func TestUserMemoryRepoTxManager(t *testing.T) {
+	repotest.RunTxManager(t, func(t *testing.T) (service.UserRepo, service.TxManager) {
+		repo := NewUserMemoryRepo()
+		return repo, repo
+	})
+}
+
+func TestReadOnlyTx(t *testing.T) {
+	repo := NewUserMemoryRepo()
+	_, userID := repotest.CreateUser(t, repo, "reader")
+	readOnly := &sql.TxOptions{ReadOnly: true}
+
+	t.Run("runs alongside other readers", func(t *testing.T) {
+		entered := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- repo.WithinTx(context.Background(), readOnly, func(ctx context.Context) error {
+				<-entered
+				_, err := repo.GetCoinsInfo(ctx, userID)
+				return err
+			})
+		}()
+
+		err := repo.WithinTx(context.Background(), readOnly, func(ctx context.Context) error {
+			close(entered)
+			select {
+			case err := <-done:
+				return err
+			case <-time.After(5 * time.Second):
+				return errors.New("second reader blocked")
+			}
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("write panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = repo.WithinTx(context.Background(), readOnly, func(ctx context.Context) error {
+				return repo.SendCoin(ctx, userID, userID, 1, "", entities.TransferCategoryOther)
+			})
+		})
+	})
+}
+
+func TestRollbackKeepsLaterAppends(t *testing.T) {
+	repo := NewUserMemoryRepo()
+	_, senderID := repotest.CreateUser(t, repo, "sender")
+	_, receiverID := repotest.CreateUser(t, repo, "receiver")
+	errRollback := errors.New("rollback")
+	ctx := context.Background()
+
+	err := repo.WithinTx(ctx, nil, func(ctx context.Context) error {
+		if err := repo.SendCoin(ctx, senderID, receiverID, 100, "rolled back", entities.TransferCategoryOther); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	require.True(t, errors.Is(err, errRollback))
+	require.NoError(t, repo.SendCoin(ctx, senderID, receiverID, 5, "kept", entities.TransferCategoryOther))
+
+	sent, err := repo.GetSentInfo(ctx, senderID)
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	assert.Equal(t, "kept", sent[0].Memo)
+	assert.Equal(t, 5, sent[0].Amount)
+}
+
+func TestConcurrentBuyItem(t *testing.T) {
+	repo := NewUserMemoryRepo()
+	_, userID := repotest.CreateUser(t, repo, "buyer")
+
+	penID, err := repo.GetItemID(context.Background(), "pen")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, rejected := 0, 0
+	for i := 0; i < 150; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.BuyItem(context.Background(), userID, penID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			} else if errors.Is(err, utils.ErrNotEnoughBalance) {
+				rejected++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, succeeded)
+	assert.Equal(t, 50, rejected)
+
+	coins, err := repo.GetCoinsInfo(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Zero(t, coins)
+}
+
+func TestConcurrentSendCoin(t *testing.T) {
+	repo := NewUserMemoryRepo()
+	_, aliceID := repotest.CreateUser(t, repo, "alice")
+	_, bobID := repotest.CreateUser(t, repo, "bob")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, repo.SendCoin(context.Background(), aliceID, bobID, 1, "", ""))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, repo.SendCoin(context.Background(), bobID, aliceID, 2, "", ""))
+		}()
+	}
+	wg.Wait()
+
+	aliceCoins, err := repo.GetCoinsInfo(context.Background(), aliceID)
+	require.NoError(t, err)
+	bobCoins, err := repo.GetCoinsInfo(context.Background(), bobID)
+	require.NoError(t, err)
+
+	assert.Equal(t, repotest.InitBalance+100, aliceCoins)
+	assert.Equal(t, repotest.InitBalance-100, bobCoins)
+}
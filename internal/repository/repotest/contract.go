@@ -0,0 +1,1557 @@
+// Package repotest holds the behaviour every service.UserRepo backend must
+// share. Backends run it from their own tests.
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/audit"
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	InitBalance = 1000
+	password    = "pass1234"
+)
+
+var userSeq atomic.Int64
+
+// NewUsername returns a name no other test has used, so the suite can run
+// against a shared, non-empty database.
+func NewUsername(prefix string) string {
+	return fmt.Sprintf("%s%d_%d", prefix, time.Now().UnixNano(), userSeq.Add(1))
+}
+
+// CreateUser registers a user through Auth and returns its id.
+func CreateUser(t *testing.T, repo service.UserRepo, prefix string) (string, int) {
+	t.Helper()
+
+	ctx := context.Background()
+	username := NewUsername(prefix)
+	_, err := repo.Auth(ctx, username, password)
+	require.NoError(t, err)
+
+	userID, err := repo.GetUserID(ctx, username)
+	require.NoError(t, err)
+
+	return username, userID
+}
+
+// RunUserRepo runs the contract against repositories built by newRepo.
+// newRepo is called once per subtest.
+func RunUserRepo(t *testing.T, newRepo func(t *testing.T) service.UserRepo) {
+	ctx := context.Background()
+
+	t.Run("auth registers new user", func(t *testing.T) {
+		repo := newRepo(t)
+		username := NewUsername("auth")
+
+		user, err := repo.Auth(ctx, username, password)
+		require.NoError(t, err)
+		assert.Equal(t, username, user.Username)
+		assert.Equal(t, entities.RoleUser, user.Role)
+		assert.True(t, user.Registered)
+
+		userID, err := repo.GetUserID(ctx, username)
+		require.NoError(t, err)
+
+		coins, err := repo.GetCoinsInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance, coins)
+
+		user, err = repo.Auth(ctx, username, password)
+		require.NoError(t, err)
+		assert.Equal(t, username, user.Username)
+		assert.False(t, user.Registered)
+
+		_, err = repo.Auth(ctx, username, "wrongpass")
+		assert.True(t, errors.Is(err, utils.ErrWrongPass))
+	})
+
+	t.Run("set user role", func(t *testing.T) {
+		repo := newRepo(t)
+		username := NewUsername("role")
+
+		_, err := repo.Auth(ctx, username, password)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.SetUserRole(ctx, username, entities.RoleAdmin))
+		user, err := repo.Auth(ctx, username, password)
+		require.NoError(t, err)
+		assert.Equal(t, entities.RoleAdmin, user.Role)
+
+		require.NoError(t, repo.SetUserRole(ctx, username, entities.RoleUser))
+		user, err = repo.Auth(ctx, username, password)
+		require.NoError(t, err)
+		assert.Equal(t, entities.RoleUser, user.Role)
+
+		err = repo.SetUserRole(ctx, NewUsername("nobody"), entities.RoleAdmin)
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+	})
+
+	t.Run("unknown user and item", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetUserID(ctx, NewUsername("ghost"))
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+
+		_, err = repo.GetItemID(ctx, "no-such-item")
+		assert.True(t, errors.Is(err, utils.ErrNoItem))
+
+		itemID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+		assert.NotZero(t, itemID)
+	})
+
+	t.Run("buy item", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "buyer")
+
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+		penID, err := repo.GetItemID(ctx, "pen")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.BuyItem(ctx, userID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, userID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, userID, penID))
+
+		coins, err := repo.GetCoinsInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance-20-20-10, coins)
+
+		inventory, err := repo.GetInventoryInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []*entities.Item{
+			{ItemType: "cup", Quantity: 2},
+			{ItemType: "pen", Quantity: 1},
+		}, inventory)
+	})
+
+	t.Run("catalog", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "catalog")
+
+		catalog, err := repo.GetCatalog(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, catalog, &entities.CatalogItem{Name: "cup", Price: 20})
+		assert.True(t, slices.IsSortedFunc(catalog, func(a, b *entities.CatalogItem) int {
+			return strings.Compare(a.Name, b.Name)
+		}))
+
+		// Names are unique so the suite can share a database; the seeded
+		// items keep their prices for the other subtests.
+		itemName := NewUsername("sticker")
+		require.NoError(t, repo.SetItemPrice(ctx, itemName, 15))
+		itemID, err := repo.GetItemID(ctx, itemName)
+		require.NoError(t, err)
+		require.NoError(t, repo.BuyItem(ctx, userID, itemID))
+
+		require.NoError(t, repo.SetItemPrice(ctx, itemName, 25))
+		repricedID, err := repo.GetItemID(ctx, itemName)
+		require.NoError(t, err)
+		assert.Equal(t, itemID, repricedID)
+		require.NoError(t, repo.BuyItem(ctx, userID, itemID))
+
+		coins, err := repo.GetCoinsInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance-15-25, coins)
+
+		catalog, err = repo.GetCatalog(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, catalog, &entities.CatalogItem{Name: itemName, Price: 25})
+	})
+
+	t.Run("buy item with insufficient balance", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "poor")
+
+		hoodyID, err := repo.GetItemID(ctx, "hoody")
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.BuyItem(ctx, userID, hoodyID))
+		}
+		err = repo.BuyItem(ctx, userID, hoodyID)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+
+		coins, err := repo.GetCoinsInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance-3*300, coins)
+
+		inventory, err := repo.GetInventoryInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "hoody", Quantity: 3}}, inventory)
+	})
+
+	t.Run("send coin", func(t *testing.T) {
+		repo := newRepo(t)
+		sender, senderID := CreateUser(t, repo, "sender")
+		receiver, receiverID := CreateUser(t, repo, "receiver")
+
+		require.NoError(t, repo.SendCoin(ctx, senderID, receiverID, 100, "lunch", entities.TransferCategoryReimbursement))
+		require.NoError(t, repo.SendCoin(ctx, senderID, receiverID, 50, "", entities.TransferCategoryOther))
+
+		senderCoins, err := repo.GetCoinsInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance-150, senderCoins)
+
+		receiverCoins, err := repo.GetCoinsInfo(ctx, receiverID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance+150, receiverCoins)
+
+		sent, err := repo.GetSentInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.SentOperation{
+			{ToUser: receiver, Amount: 100, Memo: "lunch", Category: entities.TransferCategoryReimbursement},
+			{ToUser: receiver, Amount: 50, Category: entities.TransferCategoryOther},
+		}, sent)
+
+		received, err := repo.GetReceiveInfo(ctx, receiverID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.ReceiveOperation{
+			{FromUser: sender, Amount: 100, Memo: "lunch", Category: entities.TransferCategoryReimbursement},
+			{FromUser: sender, Amount: 50, Category: entities.TransferCategoryOther},
+		}, received)
+
+		received, err = repo.GetReceiveInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Empty(t, received)
+	})
+
+	t.Run("send coin with insufficient balance", func(t *testing.T) {
+		repo := newRepo(t)
+		_, senderID := CreateUser(t, repo, "sender")
+		_, receiverID := CreateUser(t, repo, "receiver")
+
+		err := repo.SendCoin(ctx, senderID, receiverID, InitBalance+1, "", entities.TransferCategoryOther)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+
+		senderCoins, err := repo.GetCoinsInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance, senderCoins)
+
+		receiverCoins, err := repo.GetCoinsInfo(ctx, receiverID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance, receiverCoins)
+
+		sent, err := repo.GetSentInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Empty(t, sent)
+	})
+
+	t.Run("refund", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "refunder")
+
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+		penID, err := repo.GetItemID(ctx, "pen")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.BuyItem(ctx, userID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, userID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, userID, penID))
+
+		orders, err := repo.GetRefundableOrders(ctx, userID, cupID)
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		assert.Greater(t, orders[0].ID, orders[1].ID, "newest order first")
+		for _, order := range orders {
+			assert.Equal(t, "cup", order.ItemType)
+			assert.Equal(t, 1, order.Quantity)
+			assert.Equal(t, 20, order.UnitPrice)
+			assert.Zero(t, order.Refunded)
+			assert.False(t, order.CreatedAt.IsZero())
+		}
+
+		require.NoError(t, repo.AddRefund(ctx, &entities.Refund{
+			OrderID:  orders[0].ID,
+			UserID:   userID,
+			ItemID:   cupID,
+			Quantity: 1,
+			Amount:   20,
+		}))
+		require.NoError(t, repo.AddRefund(ctx, &entities.Refund{
+			OrderID:  orders[1].ID,
+			UserID:   userID,
+			ItemID:   cupID,
+			Quantity: 1,
+			Amount:   20,
+			Actor:    "admin",
+		}))
+
+		orders, err = repo.GetRefundableOrders(ctx, userID, cupID)
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+
+		err = repo.AddRefund(ctx, &entities.Refund{
+			OrderID:  1,
+			UserID:   userID,
+			ItemID:   cupID,
+			Quantity: 1,
+			Amount:   20,
+		})
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+
+		coins, err := repo.GetCoinsInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance-10, coins)
+
+		inventory, err := repo.GetInventoryInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "pen", Quantity: 1}}, inventory)
+
+		refunds, err := repo.GetRefundInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.RefundOperation{
+			{Item: "cup", Quantity: 1, Amount: 20},
+			{Item: "cup", Quantity: 1, Amount: 20, RefundedBy: "admin"},
+		}, refunds)
+	})
+
+	t.Run("orders", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "orders")
+
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+		hoodyID, err := repo.GetItemID(ctx, "hoody")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.BuyItem(ctx, userID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, userID, hoodyID))
+		require.NoError(t, repo.BuyItem(ctx, userID, cupID))
+
+		count, err := repo.CountOrders(ctx, userID, "")
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+		count, err = repo.CountOrders(ctx, userID, "cup")
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		orders, err := repo.GetOrders(ctx, userID, "", 2, 0)
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		assert.Equal(t, "cup", orders[0].ItemType)
+		assert.Equal(t, "hoody", orders[1].ItemType)
+		assert.Equal(t, 1, orders[1].Quantity)
+		assert.Equal(t, 300, orders[1].UnitPrice)
+		assert.Equal(t, 300, orders[1].Total)
+		assert.False(t, orders[1].CreatedAt.IsZero())
+
+		orders, err = repo.GetOrders(ctx, userID, "", 2, 2)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, "cup", orders[0].ItemType)
+
+		orders, err = repo.GetOrders(ctx, userID, "cup", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		assert.Greater(t, orders[0].ID, orders[1].ID)
+
+		require.NoError(t, repo.AddRefund(ctx, &entities.Refund{
+			OrderID:  orders[0].ID,
+			UserID:   userID,
+			ItemID:   cupID,
+			Quantity: 1,
+			Amount:   20,
+		}))
+
+		orders, err = repo.GetOrders(ctx, userID, "cup", 1, 0)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, 1, orders[0].Refunded)
+
+		inventory, err := repo.GetInventoryInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []*entities.Item{
+			{ItemType: "cup", Quantity: 1},
+			{ItemType: "hoody", Quantity: 1},
+		}, inventory)
+	})
+
+	t.Run("gift", func(t *testing.T) {
+		repo := newRepo(t)
+		sender, senderID := CreateUser(t, repo, "giver")
+		recipient, recipientID := CreateUser(t, repo, "receiver")
+
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.GiftItem(ctx, senderID, recipientID, cupID, "happy birthday"))
+
+		coins, err := repo.GetCoinsInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, 980, coins)
+		coins, err = repo.GetCoinsInfo(ctx, recipientID)
+		require.NoError(t, err)
+		assert.Equal(t, 1000, coins)
+
+		inventory, err := repo.GetInventoryInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Empty(t, inventory)
+		inventory, err = repo.GetInventoryInfo(ctx, recipientID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "cup", Quantity: 1}}, inventory)
+
+		sent, err := repo.GetSentGifts(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.SentGift{{ToUser: recipient, Item: "cup", Message: "happy birthday"}}, sent)
+		received, err := repo.GetReceivedGifts(ctx, recipientID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.ReceivedGift{{FromUser: sender, Item: "cup", Message: "happy birthday"}}, received)
+
+		orders, err := repo.GetOrders(ctx, senderID, "", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, recipient, orders[0].GiftTo)
+		assert.Equal(t, "happy birthday", orders[0].Message)
+
+		refundable, err := repo.GetRefundableOrders(ctx, senderID, cupID)
+		require.NoError(t, err)
+		assert.Empty(t, refundable)
+
+		err = repo.GiftItem(ctx, senderID, 0, cupID, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("transfer item", func(t *testing.T) {
+		repo := newRepo(t)
+		sender, senderID := CreateUser(t, repo, "holder")
+		recipient, recipientID := CreateUser(t, repo, "taker")
+
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.BuyItem(ctx, senderID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, senderID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, senderID, cupID))
+
+		require.NoError(t, repo.TransferItem(ctx, senderID, recipientID, cupID, 2))
+
+		inventory, err := repo.GetInventoryInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "cup", Quantity: 1}}, inventory)
+		inventory, err = repo.GetInventoryInfo(ctx, recipientID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "cup", Quantity: 2}}, inventory)
+
+		err = repo.TransferItem(ctx, senderID, recipientID, cupID, 2)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+
+		// Transferred units are no longer the sender's to refund.
+		orders, err := repo.GetRefundableOrders(ctx, senderID, cupID)
+		require.NoError(t, err)
+		require.NotEmpty(t, orders)
+		err = repo.AddRefund(ctx, &entities.Refund{
+			OrderID:  orders[0].ID,
+			UserID:   senderID,
+			ItemID:   cupID,
+			Quantity: 2,
+			Amount:   40,
+		})
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+
+		// The recipient can pass units on and give them back.
+		require.NoError(t, repo.TransferItem(ctx, recipientID, senderID, cupID, 2))
+		inventory, err = repo.GetInventoryInfo(ctx, recipientID)
+		require.NoError(t, err)
+		assert.Empty(t, inventory)
+
+		sent, err := repo.GetSentItems(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.SentItemOperation{{ToUser: recipient, Item: "cup", Quantity: 2}}, sent)
+		received, err := repo.GetReceivedItems(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.ReceivedItemOperation{{FromUser: recipient, Item: "cup", Quantity: 2}}, received)
+		received, err = repo.GetReceivedItems(ctx, recipientID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.ReceivedItemOperation{{FromUser: sender, Item: "cup", Quantity: 2}}, received)
+
+		err = repo.TransferItem(ctx, senderID, 0, cupID, 1)
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+	})
+
+	t.Run("marketplace", func(t *testing.T) {
+		repo := newRepo(t)
+		seller, sellerID := CreateUser(t, repo, "seller")
+		buyer, buyerID := CreateUser(t, repo, "bidder")
+
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+		penID, err := repo.GetItemID(ctx, "pen")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.BuyItem(ctx, sellerID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, sellerID, cupID))
+		require.NoError(t, repo.BuyItem(ctx, sellerID, penID))
+
+		now := time.Now()
+		cupListing, err := repo.CreateListing(ctx, sellerID, cupID, 2, 100, now.Add(time.Hour))
+		require.NoError(t, err)
+		penListing, err := repo.CreateListing(ctx, sellerID, penID, 1, 30, now.Add(time.Hour))
+		require.NoError(t, err)
+
+		// Listed units are in escrow and cannot be listed or moved twice.
+		_, err = repo.CreateListing(ctx, sellerID, cupID, 1, 50, now.Add(time.Hour))
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+		err = repo.TransferItem(ctx, sellerID, buyerID, penID, 1)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+		inventory, err := repo.GetInventoryInfo(ctx, sellerID)
+		require.NoError(t, err)
+		assert.Empty(t, inventory)
+
+		listing, err := repo.GetListing(ctx, cupListing)
+		require.NoError(t, err)
+		assert.Equal(t, sellerID, listing.SellerID)
+		assert.Equal(t, seller, listing.Seller)
+		assert.Equal(t, "cup", listing.ItemType)
+		assert.Equal(t, 2, listing.Quantity)
+		assert.Equal(t, 100, listing.Price)
+		assert.Equal(t, entities.ListingOpen, listing.Status)
+		assert.WithinDuration(t, now.Add(time.Hour), listing.ExpiresAt, time.Second)
+
+		_, err = repo.GetListing(ctx, penListing+100)
+		assert.True(t, errors.Is(err, utils.ErrNoListing))
+
+		count, err := repo.CountListings(ctx, "", now)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		listings, err := repo.GetListings(ctx, "", now, 1, 0)
+		require.NoError(t, err)
+		require.Len(t, listings, 1)
+		assert.Equal(t, penListing, listings[0].ID)
+		listings, err = repo.GetListings(ctx, "cup", now, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, listings, 1)
+		assert.Equal(t, cupListing, listings[0].ID)
+		count, err = repo.CountListings(ctx, "", now.Add(2*time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		// Sale: coins to the seller less the burned fee, units to the buyer.
+		require.NoError(t, repo.SellListing(ctx, cupListing, buyerID, 10))
+		err = repo.SellListing(ctx, cupListing, buyerID, 10)
+		assert.True(t, errors.Is(err, utils.ErrListingClosed))
+
+		coins, err := repo.GetCoinsInfo(ctx, buyerID)
+		require.NoError(t, err)
+		assert.Equal(t, 900, coins)
+		coins, err = repo.GetCoinsInfo(ctx, sellerID)
+		require.NoError(t, err)
+		assert.Equal(t, 1000-20-20-10+90, coins)
+		inventory, err = repo.GetInventoryInfo(ctx, buyerID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "cup", Quantity: 2}}, inventory)
+
+		listing, err = repo.GetListing(ctx, cupListing)
+		require.NoError(t, err)
+		assert.Equal(t, entities.ListingSold, listing.Status)
+		assert.Equal(t, buyer, listing.Buyer)
+		assert.Equal(t, 10, listing.Fee)
+
+		// Expiry returns the units to the seller.
+		expired, err := repo.ExpireListings(ctx, now.Add(2*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		listing, err = repo.GetListing(ctx, penListing)
+		require.NoError(t, err)
+		assert.Equal(t, entities.ListingExpired, listing.Status)
+		inventory, err = repo.GetInventoryInfo(ctx, sellerID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "pen", Quantity: 1}}, inventory)
+		err = repo.CloseListing(ctx, penListing, entities.ListingCancelled)
+		assert.True(t, errors.Is(err, utils.ErrListingClosed))
+
+		// Cancelling does the same, and a poor buyer cannot buy.
+		penListing, err = repo.CreateListing(ctx, sellerID, penID, 1, 5000, now.Add(time.Hour))
+		require.NoError(t, err)
+		err = repo.SellListing(ctx, penListing, buyerID, 0)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+		listing, err = repo.GetListing(ctx, penListing)
+		require.NoError(t, err)
+		assert.Equal(t, entities.ListingOpen, listing.Status)
+
+		require.NoError(t, repo.CloseListing(ctx, penListing, entities.ListingCancelled))
+		inventory, err = repo.GetInventoryInfo(ctx, sellerID)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.Item{{ItemType: "pen", Quantity: 1}}, inventory)
+	})
+
+	t.Run("search transfers", func(t *testing.T) {
+		repo := newRepo(t)
+		alice, aliceID := CreateUser(t, repo, "alice")
+		bob, bobID := CreateUser(t, repo, "bob")
+		_, carolID := CreateUser(t, repo, "carol")
+
+		require.NoError(t, repo.SendCoin(ctx, aliceID, bobID, 10, "Pizza on Friday", entities.TransferCategoryReimbursement))
+		require.NoError(t, repo.SendCoin(ctx, bobID, aliceID, 20, "lost the bet", entities.TransferCategoryBet))
+		require.NoError(t, repo.SendCoin(ctx, aliceID, carolID, 30, "100%_sure", entities.TransferCategoryThanks))
+
+		search := func(filter entities.TransferFilter) []int {
+			t.Helper()
+			transfers, err := repo.SearchTransfers(ctx, aliceID, filter, 10, 0)
+			require.NoError(t, err)
+			count, err := repo.CountTransfers(ctx, aliceID, filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(transfers), count)
+
+			amounts := make([]int, 0, len(transfers))
+			for _, transfer := range transfers {
+				amounts = append(amounts, transfer.Amount)
+			}
+			return amounts
+		}
+
+		assert.Equal(t, []int{30, 20, 10}, search(entities.TransferFilter{}))
+		assert.Equal(t, []int{30, 10}, search(entities.TransferFilter{Direction: entities.TransferDirectionSent}))
+		assert.Equal(t, []int{20}, search(entities.TransferFilter{Direction: entities.TransferDirectionReceived}))
+		assert.Equal(t, []int{20}, search(entities.TransferFilter{Category: entities.TransferCategoryBet}))
+		assert.Equal(t, []int{10}, search(entities.TransferFilter{Query: "pizza"}))
+		assert.Equal(t, []int{20, 10}, search(entities.TransferFilter{Query: bob}))
+		assert.Equal(t, []int{30}, search(entities.TransferFilter{Query: "%_"}))
+		assert.Empty(t, search(entities.TransferFilter{Query: alice}))
+		assert.Empty(t, search(entities.TransferFilter{Direction: entities.TransferDirectionSent, Category: entities.TransferCategoryBet}))
+
+		transfers, err := repo.SearchTransfers(ctx, aliceID, entities.TransferFilter{}, 1, 1)
+		require.NoError(t, err)
+		require.Len(t, transfers, 1)
+		assert.Equal(t, bob, transfers[0].FromUser)
+		assert.Equal(t, alice, transfers[0].ToUser)
+		assert.Equal(t, "lost the bet", transfers[0].Memo)
+		assert.Equal(t, entities.TransferCategoryBet, transfers[0].Category)
+		require.NotNil(t, transfers[0].CreatedAt)
+		assert.False(t, transfers[0].CreatedAt.IsZero())
+	})
+
+	t.Run("scheduled transfers", func(t *testing.T) {
+		repo := newRepo(t)
+		sender, senderID := CreateUser(t, repo, "scheduler")
+		recipient, recipientID := CreateUser(t, repo, "mentee")
+		_, otherID := CreateUser(t, repo, "mentor")
+
+		// Far in the past so schedules left by other tests are not due.
+		runAt := time.Date(2001, 1, 31, 9, 0, 0, 0, time.UTC)
+		scheduleID, err := repo.CreateSchedule(ctx, &entities.ScheduledTransfer{
+			FromUserID: senderID,
+			ToUserID:   recipientID,
+			Amount:     25,
+			Memo:       "allowance",
+			Category:   entities.TransferCategoryGift,
+			Recurrence: entities.RecurrenceMonthly,
+			NextRunAt:  runAt,
+			AnchorDay:  31,
+		})
+		require.NoError(t, err)
+
+		schedule, err := repo.GetSchedule(ctx, scheduleID)
+		require.NoError(t, err)
+		assert.Equal(t, senderID, schedule.FromUserID)
+		assert.Equal(t, sender, schedule.FromUser)
+		assert.Equal(t, recipientID, schedule.ToUserID)
+		assert.Equal(t, recipient, schedule.ToUser)
+		assert.Equal(t, 25, schedule.Amount)
+		assert.Equal(t, "allowance", schedule.Memo)
+		assert.Equal(t, entities.TransferCategoryGift, schedule.Category)
+		assert.Equal(t, entities.RecurrenceMonthly, schedule.Recurrence)
+		assert.Equal(t, entities.ScheduleActive, schedule.Status)
+		assert.Equal(t, 31, schedule.AnchorDay)
+		assert.True(t, schedule.NextRunAt.Equal(runAt), "next run at %v, want %v", schedule.NextRunAt, runAt)
+
+		_, err = repo.GetSchedule(ctx, scheduleID+100)
+		assert.True(t, errors.Is(err, utils.ErrNoSchedule))
+
+		_, err = repo.ClaimDueSchedule(ctx, runAt.Add(-time.Second))
+		assert.True(t, errors.Is(err, utils.ErrNoSchedule))
+		claimed, err := repo.ClaimDueSchedule(ctx, runAt)
+		require.NoError(t, err)
+		assert.Equal(t, scheduleID, claimed.ID)
+
+		run := &entities.ScheduledTransferRun{RunAt: runAt, Status: entities.ScheduleRunFailed, Error: "Not enough balance"}
+		require.NoError(t, repo.AddScheduleRun(ctx, scheduleID, run))
+		assert.NotZero(t, run.ID)
+		require.NoError(t, repo.AddScheduleRun(ctx, scheduleID, &entities.ScheduledTransferRun{RunAt: runAt.Add(time.Hour), Status: entities.ScheduleRunSucceeded}))
+
+		runs, err := repo.GetScheduleRuns(ctx, scheduleID, 1)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, entities.ScheduleRunSucceeded, runs[0].Status)
+		runs, err = repo.GetScheduleRuns(ctx, scheduleID, 10)
+		require.NoError(t, err)
+		require.Len(t, runs, 2)
+		assert.Equal(t, run.ID, runs[1].ID)
+		assert.Equal(t, "Not enough balance", runs[1].Error)
+		assert.True(t, runs[1].RunAt.Equal(runAt), "run at %v, want %v", runs[1].RunAt, runAt)
+
+		claimed.ToUserID = otherID
+		claimed.Amount = 40
+		claimed.NextRunAt = runAt.AddDate(0, 1, 0)
+		claimed.AnchorDay = 30
+		require.NoError(t, repo.UpdateSchedule(ctx, claimed))
+		_, err = repo.ClaimDueSchedule(ctx, runAt)
+		assert.True(t, errors.Is(err, utils.ErrNoSchedule))
+
+		schedules, err := repo.GetSchedules(ctx, senderID)
+		require.NoError(t, err)
+		require.Len(t, schedules, 1)
+		assert.Equal(t, otherID, schedules[0].ToUserID)
+		assert.Equal(t, 40, schedules[0].Amount)
+		assert.Equal(t, 30, schedules[0].AnchorDay)
+		schedules, err = repo.GetSchedules(ctx, recipientID)
+		require.NoError(t, err)
+		assert.Empty(t, schedules)
+
+		require.NoError(t, repo.CloseSchedule(ctx, scheduleID, entities.ScheduleCancelled))
+		err = repo.CloseSchedule(ctx, scheduleID, entities.ScheduleCancelled)
+		assert.True(t, errors.Is(err, utils.ErrScheduleClosed))
+		err = repo.UpdateSchedule(ctx, claimed)
+		assert.True(t, errors.Is(err, utils.ErrScheduleClosed))
+		_, err = repo.ClaimDueSchedule(ctx, runAt.AddDate(0, 2, 0))
+		assert.True(t, errors.Is(err, utils.ErrNoSchedule))
+	})
+
+	t.Run("payment requests", func(t *testing.T) {
+		repo := newRepo(t)
+		requester, requesterID := CreateUser(t, repo, "requester")
+		payer, payerID := CreateUser(t, repo, "payer")
+
+		now := time.Now()
+		lunch, err := repo.CreatePaymentRequest(ctx, &entities.PaymentRequest{
+			RequesterID: requesterID,
+			PayerID:     payerID,
+			Amount:      15,
+			Memo:        "lunch",
+			Category:    entities.TransferCategoryReimbursement,
+			ExpiresAt:   now.Add(time.Hour),
+		})
+		require.NoError(t, err)
+		taxi, err := repo.CreatePaymentRequest(ctx, &entities.PaymentRequest{
+			RequesterID: payerID,
+			PayerID:     requesterID,
+			Amount:      40,
+			Category:    entities.TransferCategoryOther,
+			ExpiresAt:   now.Add(-time.Minute),
+		})
+		require.NoError(t, err)
+
+		request, err := repo.GetPaymentRequest(ctx, lunch)
+		require.NoError(t, err)
+		assert.Equal(t, requesterID, request.RequesterID)
+		assert.Equal(t, requester, request.Requester)
+		assert.Equal(t, payerID, request.PayerID)
+		assert.Equal(t, payer, request.Payer)
+		assert.Equal(t, 15, request.Amount)
+		assert.Equal(t, "lunch", request.Memo)
+		assert.Equal(t, entities.TransferCategoryReimbursement, request.Category)
+		assert.Equal(t, entities.PaymentRequestPending, request.Status)
+		assert.WithinDuration(t, now.Add(time.Hour), request.ExpiresAt, time.Second)
+		assert.Nil(t, request.ClosedAt)
+
+		_, err = repo.GetPaymentRequest(ctx, taxi+100)
+		assert.True(t, errors.Is(err, utils.ErrNoPaymentRequest))
+
+		count, err := repo.CountPaymentRequests(ctx, requesterID, entities.PaymentRequestFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		requests, err := repo.GetPaymentRequests(ctx, requesterID, entities.PaymentRequestFilter{}, 1, 0)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		assert.Equal(t, taxi, requests[0].ID)
+		requests, err = repo.GetPaymentRequests(ctx, requesterID, entities.PaymentRequestFilter{Direction: entities.PaymentRequestOutgoing}, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		assert.Equal(t, lunch, requests[0].ID)
+		requests, err = repo.GetPaymentRequests(ctx, payerID, entities.PaymentRequestFilter{Direction: entities.PaymentRequestIncoming}, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		assert.Equal(t, lunch, requests[0].ID)
+
+		expired, err := repo.ExpirePaymentRequests(ctx, now)
+		require.NoError(t, err)
+		// Requests left by other tests may expire as well.
+		i := slices.IndexFunc(expired, func(r *entities.PaymentRequest) bool { return r.ID == taxi })
+		require.GreaterOrEqual(t, i, 0, "taxi request not expired")
+		assert.Equal(t, payer, expired[i].Requester)
+		assert.Equal(t, requester, expired[i].Payer)
+		assert.Equal(t, 40, expired[i].Amount)
+		assert.Equal(t, entities.PaymentRequestExpired, expired[i].Status)
+		assert.NotNil(t, expired[i].ClosedAt)
+		assert.False(t, slices.ContainsFunc(expired, func(r *entities.PaymentRequest) bool { return r.ID == lunch }))
+		request, err = repo.GetPaymentRequest(ctx, taxi)
+		require.NoError(t, err)
+		assert.Equal(t, entities.PaymentRequestExpired, request.Status)
+		assert.NotNil(t, request.ClosedAt)
+
+		require.NoError(t, repo.ClosePaymentRequest(ctx, lunch, entities.PaymentRequestAccepted))
+		err = repo.ClosePaymentRequest(ctx, lunch, entities.PaymentRequestDeclined)
+		assert.True(t, errors.Is(err, utils.ErrPaymentRequestClosed))
+		err = repo.ClosePaymentRequest(ctx, taxi, entities.PaymentRequestAccepted)
+		assert.True(t, errors.Is(err, utils.ErrPaymentRequestClosed))
+
+		count, err = repo.CountPaymentRequests(ctx, payerID, entities.PaymentRequestFilter{Status: entities.PaymentRequestAccepted})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		count, err = repo.CountPaymentRequests(ctx, payerID, entities.PaymentRequestFilter{Status: entities.PaymentRequestPending})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("transfer limits", func(t *testing.T) {
+		repo := newRepo(t)
+		sender, senderID := CreateUser(t, repo, "limited")
+		_, firstID := CreateUser(t, repo, "first")
+		_, secondID := CreateUser(t, repo, "second")
+
+		_, err := repo.GetTransferLimits(ctx, senderID)
+		assert.True(t, errors.Is(err, utils.ErrNoTransferLimits))
+		err = repo.DeleteTransferLimits(ctx, senderID)
+		assert.True(t, errors.Is(err, utils.ErrNoTransferLimits))
+
+		limits := entities.TransferLimits{MaxTransfer: 50, DailyAmount: 100, DailyRecipients: 3}
+		require.NoError(t, repo.SetTransferLimits(ctx, senderID, limits, "admin"))
+		limits.WeeklyAmount = 400
+		require.NoError(t, repo.SetTransferLimits(ctx, senderID, limits, "root"))
+
+		override, err := repo.GetTransferLimits(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, sender, override.Username)
+		assert.Equal(t, limits, override.TransferLimits)
+		assert.True(t, override.Override)
+		assert.Equal(t, "root", override.UpdatedBy)
+		assert.NotNil(t, override.UpdatedAt)
+
+		require.NoError(t, repo.DeleteTransferLimits(ctx, senderID))
+		_, err = repo.GetTransferLimits(ctx, senderID)
+		assert.True(t, errors.Is(err, utils.ErrNoTransferLimits))
+
+		since := time.Now().Add(-time.Hour)
+		require.NoError(t, repo.SendCoin(ctx, senderID, firstID, 10, "", entities.TransferCategoryOther))
+		require.NoError(t, repo.SendCoin(ctx, senderID, firstID, 15, "", entities.TransferCategoryOther))
+		require.NoError(t, repo.SendCoin(ctx, senderID, secondID, 20, "", entities.TransferCategoryOther))
+		require.NoError(t, repo.SendCoin(ctx, firstID, senderID, 5, "", entities.TransferCategoryOther))
+
+		usage, err := repo.GetTransferUsage(ctx, senderID, firstID, since)
+		require.NoError(t, err)
+		assert.Equal(t, &entities.TransferUsage{Amount: 45, Recipients: 1}, usage)
+		usage, err = repo.GetTransferUsage(ctx, senderID, senderID, since)
+		require.NoError(t, err)
+		assert.Equal(t, &entities.TransferUsage{Amount: 45, Recipients: 2}, usage)
+		usage, err = repo.GetTransferUsage(ctx, senderID, firstID, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, &entities.TransferUsage{}, usage)
+	})
+
+	t.Run("balance adjustments", func(t *testing.T) {
+		repo := newRepo(t)
+		user, userID := CreateUser(t, repo, "adjusted")
+		_, otherID := CreateUser(t, repo, "other")
+
+		balance, err := repo.AdjustBalance(ctx, userID, 250)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance+250, balance)
+		balance, err = repo.AdjustBalance(ctx, userID, -InitBalance)
+		require.NoError(t, err)
+		assert.Equal(t, 250, balance)
+
+		_, err = repo.AdjustBalance(ctx, userID, -251)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+		_, err = repo.AdjustBalance(ctx, userID+100, 10)
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+		coins, err := repo.GetCoinsInfo(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, 250, coins)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		grant := &entities.BalanceAdjustment{UserID: userID, Actor: "admin", Kind: entities.AdjustmentGrant, Amount: 250, Balance: 1250, Reason: "bonus", CreatedAt: now}
+		require.NoError(t, repo.AddBalanceAdjustment(ctx, grant))
+		assert.NotZero(t, grant.ID)
+		require.NoError(t, repo.AddBalanceAdjustment(ctx, &entities.BalanceAdjustment{UserID: otherID, Actor: "admin", Kind: entities.AdjustmentSet, Amount: -1000, Balance: 0, Reason: "fraud", CreatedAt: now}))
+		clawback := &entities.BalanceAdjustment{UserID: userID, Actor: "root", Kind: entities.AdjustmentClawback, Amount: -1000, Balance: 250, Reason: "mistake", CreatedAt: now}
+		require.NoError(t, repo.AddBalanceAdjustment(ctx, clawback))
+
+		count, err := repo.CountBalanceAdjustments(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		count, err = repo.CountBalanceAdjustments(ctx, 0)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, count, 3)
+
+		adjustments, err := repo.GetBalanceAdjustments(ctx, userID, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, adjustments, 2)
+		assert.Equal(t, clawback.ID, adjustments[0].ID)
+		assert.Equal(t, user, adjustments[1].Username)
+		assert.Equal(t, "admin", adjustments[1].Actor)
+		assert.Equal(t, entities.AdjustmentGrant, adjustments[1].Kind)
+		assert.Equal(t, 250, adjustments[1].Amount)
+		assert.Equal(t, 1250, adjustments[1].Balance)
+		assert.Equal(t, "bonus", adjustments[1].Reason)
+		assert.WithinDuration(t, now, adjustments[1].CreatedAt, time.Second)
+
+		adjustments, err = repo.GetBalanceAdjustments(ctx, userID, 1, 1)
+		require.NoError(t, err)
+		require.Len(t, adjustments, 1)
+		assert.Equal(t, grant.ID, adjustments[0].ID)
+	})
+
+	t.Run("audit log", func(t *testing.T) {
+		repo := newRepo(t)
+		actor := NewUsername("auditor")
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		prevHash := ""
+		last, err := repo.LastAuditEvent(ctx, "")
+		if err == nil {
+			prevHash = last.Hash
+		} else {
+			require.True(t, errors.Is(err, utils.ErrNoAuditEvent))
+		}
+
+		failed := &entities.AuditEvent{Action: entities.AuditLoginFailed, Actor: actor, Target: actor, IP: "10.0.0.1", UserAgent: "curl/8.0",
+			RequestID: "req-1", Details: "wrong password", CreatedAt: now, PrevHash: prevHash}
+		failed.Hash = audit.Hash(failed)
+		require.NoError(t, repo.AddAuditEvent(ctx, failed))
+		assert.NotZero(t, failed.ID)
+
+		sent := &entities.AuditEvent{Action: entities.AuditCoinsSent, Actor: actor, Target: "bob", Details: "amount=10 category=other",
+			CreatedAt: now.Add(time.Second), PrevHash: failed.Hash}
+		sent.Hash = audit.Hash(sent)
+		require.NoError(t, repo.AddAuditEvent(ctx, sent))
+
+		fork := &entities.AuditEvent{Action: entities.AuditCoinsSent, Actor: actor, CreatedAt: now, PrevHash: failed.Hash}
+		fork.Hash = audit.Hash(fork)
+		assert.Error(t, repo.AddAuditEvent(ctx, fork), "two events must not continue the same one")
+
+		last, err = repo.LastAuditEvent(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, sent, last)
+		last, err = repo.LastAuditEvent(ctx, entities.AuditLoginFailed)
+		require.NoError(t, err)
+		assert.Equal(t, failed.ID, last.ID)
+
+		events, err := repo.GetAuditEvents(ctx, entities.AuditFilter{Actor: actor}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []*entities.AuditEvent{failed, sent}, events)
+		assert.Equal(t, -1, audit.Verify(prevHash, events))
+
+		filters := []struct {
+			filter entities.AuditFilter
+			want   *entities.AuditEvent
+		}{
+			{entities.AuditFilter{Actor: actor, Action: entities.AuditCoinsSent}, sent},
+			{entities.AuditFilter{Actor: actor, Target: actor}, failed},
+			{entities.AuditFilter{Actor: actor, Since: now.Add(time.Second)}, sent},
+			{entities.AuditFilter{Actor: actor, Until: now.Add(time.Second)}, failed},
+		}
+		for _, f := range filters {
+			events, err := repo.GetAuditEvents(ctx, f.filter, 0, 10)
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, f.want.ID, events[0].ID)
+
+			count, err := repo.CountAuditEvents(ctx, f.filter)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+		}
+
+		events, err = repo.GetAuditEvents(ctx, entities.AuditFilter{Actor: actor}, failed.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, sent.ID, events[0].ID)
+		events, err = repo.GetAuditEvents(ctx, entities.AuditFilter{Actor: actor}, 0, 1)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, failed.ID, events[0].ID)
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		repo := newRepo(t)
+		key, other := NewUsername("outbox"), NewUsername("outbox")
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		first := &entities.OutboxEvent{Type: entities.EventCoinsSent, Key: key, Payload: json.RawMessage(`{"amount":1}`), CreatedAt: now}
+		second := &entities.OutboxEvent{Type: entities.EventCoinsSent, Key: key, Payload: json.RawMessage(`{"amount":2}`), CreatedAt: now}
+		third := &entities.OutboxEvent{Type: entities.EventUserRegistered, Key: other, Payload: json.RawMessage(`{"username":"x"}`), CreatedAt: now}
+		for _, event := range []*entities.OutboxEvent{first, second, third} {
+			require.NoError(t, repo.AddOutboxEvent(ctx, event))
+		}
+		assert.Less(t, first.ID, second.ID)
+		assert.Less(t, second.ID, third.ID)
+
+		// due returns the ids of this test's events due at at.
+		due := func(at time.Time) []int {
+			events, err := repo.GetDueOutboxEvents(ctx, at, 100)
+			require.NoError(t, err)
+
+			var ids []int
+			for _, event := range events {
+				if event.Key == key || event.Key == other {
+					ids = append(ids, event.ID)
+				}
+			}
+			return ids
+		}
+
+		events, err := repo.GetDueOutboxEvents(ctx, now, 100)
+		require.NoError(t, err)
+		for _, event := range events {
+			if event.ID == first.ID {
+				assert.Equal(t, entities.EventCoinsSent, event.Type)
+				assert.Equal(t, key, event.Key)
+				assert.JSONEq(t, `{"amount":1}`, string(event.Payload))
+				assert.True(t, now.Equal(event.CreatedAt))
+				assert.True(t, now.Equal(event.NextAttemptAt))
+				assert.Zero(t, event.Attempts)
+			}
+		}
+		assert.Equal(t, []int{first.ID, second.ID, third.ID}, due(now))
+		assert.Empty(t, due(now.Add(-time.Second)))
+
+		// A deferred event holds back the later events with its key.
+		require.NoError(t, repo.DeferOutboxEvent(ctx, first.ID, "sink down", now.Add(time.Minute)))
+		assert.Equal(t, []int{third.ID}, due(now))
+		assert.Equal(t, []int{first.ID, second.ID, third.ID}, due(now.Add(time.Minute)))
+
+		events, err = repo.GetDueOutboxEvents(ctx, now.Add(time.Minute), 100)
+		require.NoError(t, err)
+		for _, event := range events {
+			if event.ID == first.ID {
+				assert.Equal(t, 1, event.Attempts)
+				assert.Equal(t, "sink down", event.LastError)
+			}
+		}
+
+		for _, event := range []*entities.OutboxEvent{first, second, third} {
+			require.NoError(t, repo.MarkOutboxDelivered(ctx, event.ID, now))
+		}
+		assert.Empty(t, due(now.Add(time.Minute)))
+	})
+
+	t.Run("outbox lease", func(t *testing.T) {
+		repo := newRepo(t)
+		relay, otherRelay := NewUsername("relay"), NewUsername("relay")
+		// Far enough ahead that leases left by earlier runs have expired.
+		now := time.Now().UTC().Add(1000 * time.Hour).Truncate(time.Microsecond)
+
+		acquired, err := repo.AcquireOutboxLease(ctx, relay, now, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.True(t, acquired)
+
+		acquired, err = repo.AcquireOutboxLease(ctx, otherRelay, now.Add(time.Second), now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, acquired, "the lease is held by another relay")
+
+		acquired, err = repo.AcquireOutboxLease(ctx, relay, now.Add(time.Second), now.Add(2*time.Minute))
+		require.NoError(t, err)
+		assert.True(t, acquired, "the holder renews its lease")
+
+		acquired, err = repo.AcquireOutboxLease(ctx, otherRelay, now.Add(2*time.Minute), now.Add(3*time.Minute))
+		require.NoError(t, err)
+		assert.True(t, acquired, "an expired lease is taken over")
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "hooks")
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		endpoint := &entities.WebhookEndpoint{
+			UserID:     userID,
+			URL:        "https://example.com/hooks",
+			EventTypes: []string{entities.WebhookCoinReceived, entities.WebhookLowBalance},
+			Secret:     "whsec_test",
+			CreatedAt:  now,
+		}
+		require.NoError(t, repo.AddWebhookEndpoint(ctx, endpoint))
+		assert.NotZero(t, endpoint.ID)
+
+		got, err := repo.GetWebhookEndpoint(ctx, endpoint.ID)
+		require.NoError(t, err)
+		assert.Equal(t, endpoint.EventTypes, got.EventTypes)
+		assert.Equal(t, "whsec_test", got.Secret)
+		assert.True(t, now.Equal(got.CreatedAt))
+
+		endpoints, err := repo.GetWebhookEndpoints(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		assert.Equal(t, endpoint.ID, endpoints[0].ID)
+
+		// Long overdue, so the claim below picks it before any delivery
+		// left by other tests.
+		due := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		delivery := &entities.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventType:     entities.WebhookCoinReceived,
+			Payload:       json.RawMessage(`{"amount":5}`),
+			Status:        entities.WebhookDeliveryPending,
+			NextAttemptAt: due,
+			CreatedAt:     now,
+		}
+		delivered := &entities.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventType:     entities.WebhookLowBalance,
+			Payload:       json.RawMessage(`{"balance":10}`),
+			Status:        entities.WebhookDeliveryDelivered,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		require.NoError(t, repo.AddWebhookDelivery(ctx, delivery))
+		require.NoError(t, repo.AddWebhookDelivery(ctx, delivered))
+
+		claimed, err := repo.ClaimDueWebhookDelivery(ctx, due.Add(time.Second), due.Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, delivery.ID, claimed.ID)
+		assert.JSONEq(t, `{"amount":5}`, string(claimed.Payload))
+		assert.True(t, due.Add(time.Minute).Equal(claimed.NextAttemptAt))
+
+		_, err = repo.ClaimDueWebhookDelivery(ctx, due.Add(time.Second), due.Add(time.Minute))
+		assert.True(t, errors.Is(err, utils.ErrNoWebhookDelivery), "a claimed delivery is not due")
+
+		require.NoError(t, repo.AddWebhookAttempt(ctx, delivery.ID, &entities.WebhookAttempt{AttemptedAt: now, StatusCode: 500, Error: "unexpected status 500", DurationMS: 12}))
+		claimed.Status = entities.WebhookDeliveryDead
+		claimed.Attempts = 1
+		claimed.LastError = "unexpected status 500"
+		require.NoError(t, repo.UpdateWebhookDelivery(ctx, claimed))
+
+		attempts, err := repo.GetWebhookAttempts(ctx, delivery.ID)
+		require.NoError(t, err)
+		require.Len(t, attempts, 1)
+		assert.Equal(t, 500, attempts[0].StatusCode)
+		assert.Equal(t, 12, attempts[0].DurationMS)
+
+		dead, err := repo.GetWebhookDeliveries(ctx, endpoint.ID, entities.WebhookDeliveryDead, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, delivery.ID, dead[0].ID)
+		assert.Equal(t, 1, dead[0].Attempts)
+		assert.Equal(t, "unexpected status 500", dead[0].LastError)
+
+		all, err := repo.GetWebhookDeliveries(ctx, endpoint.ID, "", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, delivered.ID, all[0].ID, "newest first")
+
+		total, err := repo.CountWebhookDeliveries(ctx, endpoint.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+
+		// Deleting the endpoint deletes its deliveries and their attempts.
+		require.NoError(t, repo.DeleteWebhookEndpoint(ctx, endpoint.ID))
+		_, err = repo.GetWebhookEndpoint(ctx, endpoint.ID)
+		assert.True(t, errors.Is(err, utils.ErrNoWebhook))
+		_, err = repo.GetWebhookDelivery(ctx, delivery.ID)
+		assert.True(t, errors.Is(err, utils.ErrNoWebhookDelivery))
+	})
+
+	t.Run("user events", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "stream")
+		_, otherID := CreateUser(t, repo, "stream")
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		last, err := repo.LastUserEventID(ctx, userID)
+		require.NoError(t, err)
+		assert.Zero(t, last)
+
+		first := &entities.UserEvent{UserID: userID, Type: entities.StreamCoinReceived, Data: json.RawMessage(`{"amount":5}`), CreatedAt: now.Add(-time.Hour)}
+		other := &entities.UserEvent{UserID: otherID, Type: entities.StreamBalanceChanged, Data: json.RawMessage(`{"balance":10}`), CreatedAt: now}
+		second := &entities.UserEvent{UserID: userID, Type: entities.StreamBalanceChanged, Data: json.RawMessage(`{"balance":1005}`), CreatedAt: now}
+		for _, event := range []*entities.UserEvent{first, other, second} {
+			require.NoError(t, repo.AddUserEvent(ctx, event))
+		}
+		assert.Less(t, first.ID, other.ID)
+		assert.Less(t, other.ID, second.ID)
+
+		events, err := repo.GetUserEvents(ctx, userID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, first.ID, events[0].ID)
+		assert.Equal(t, userID, events[0].UserID)
+		assert.Equal(t, entities.StreamCoinReceived, events[0].Type)
+		assert.JSONEq(t, `{"amount":5}`, string(events[0].Data))
+		assert.True(t, first.CreatedAt.Equal(events[0].CreatedAt))
+		assert.Equal(t, second.ID, events[1].ID)
+
+		events, err = repo.GetUserEvents(ctx, userID, first.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, second.ID, events[0].ID)
+
+		events, err = repo.GetUserEvents(ctx, userID, 0, 1)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, first.ID, events[0].ID)
+
+		last, err = repo.LastUserEventID(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, last)
+
+		// Other tests' events may be pruned too, so only ours are checked.
+		_, err = repo.DeleteUserEvents(ctx, now.Add(-time.Minute))
+		require.NoError(t, err)
+		events, err = repo.GetUserEvents(ctx, userID, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, second.ID, events[0].ID)
+	})
+
+	t.Run("notifications", func(t *testing.T) {
+		repo := newRepo(t)
+		_, userID := CreateUser(t, repo, "inbox")
+		_, otherID := CreateUser(t, repo, "inbox")
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		first := &entities.Notification{Kind: entities.NotificationCoinsReceived, Text: "bob sent you 50 coins", CreatedAt: now.Add(-time.Hour)}
+		other := &entities.Notification{Kind: entities.NotificationGiftReceived, Text: "bob sent you a cup", CreatedAt: now}
+		second := &entities.Notification{Kind: entities.NotificationItemRefunded, Text: "your order of 1 hoodie was refunded: 300 coins returned", CreatedAt: now}
+		require.NoError(t, repo.AddNotification(ctx, userID, first))
+		require.NoError(t, repo.AddNotification(ctx, otherID, other))
+		require.NoError(t, repo.AddNotification(ctx, userID, second))
+		assert.Less(t, first.ID, second.ID)
+
+		notifications, err := repo.GetNotifications(ctx, userID, false, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+		assert.Equal(t, second.ID, notifications[0].ID, "newest first")
+		assert.Equal(t, first.ID, notifications[1].ID)
+		assert.Equal(t, entities.NotificationCoinsReceived, notifications[1].Kind)
+		assert.Equal(t, first.Text, notifications[1].Text)
+		assert.True(t, first.CreatedAt.Equal(notifications[1].CreatedAt))
+		assert.Nil(t, notifications[1].ReadAt)
+
+		notifications, err = repo.GetNotifications(ctx, userID, false, 1, 1)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+		assert.Equal(t, first.ID, notifications[0].ID)
+
+		readAt := now.Add(time.Minute)
+		require.NoError(t, repo.MarkNotificationRead(ctx, userID, first.ID, readAt))
+		require.NoError(t, repo.MarkNotificationRead(ctx, userID, first.ID, readAt.Add(time.Minute)), "marking again is not an error")
+		assert.ErrorIs(t, repo.MarkNotificationRead(ctx, userID, other.ID, readAt), utils.ErrNoNotification)
+		assert.ErrorIs(t, repo.MarkNotificationRead(ctx, userID, -1, readAt), utils.ErrNoNotification)
+
+		unread, err := repo.CountNotifications(ctx, userID, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, unread)
+		total, err := repo.CountNotifications(ctx, userID, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, total)
+
+		notifications, err = repo.GetNotifications(ctx, userID, false, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, notifications, 2)
+		require.NotNil(t, notifications[1].ReadAt)
+		assert.True(t, readAt.Equal(*notifications[1].ReadAt), "the first read is kept")
+
+		notifications, err = repo.GetNotifications(ctx, userID, true, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, notifications, 1)
+		assert.Equal(t, second.ID, notifications[0].ID)
+
+		marked, err := repo.MarkAllNotificationsRead(ctx, userID, readAt)
+		require.NoError(t, err)
+		assert.Equal(t, 1, marked)
+		unread, err = repo.CountNotifications(ctx, userID, true)
+		require.NoError(t, err)
+		assert.Zero(t, unread)
+		unread, err = repo.CountNotifications(ctx, otherID, true)
+		require.NoError(t, err)
+		assert.Equal(t, 1, unread)
+
+		preferences, err := repo.GetNotificationPreferences(ctx, userID)
+		require.NoError(t, err)
+		assert.Empty(t, preferences)
+		require.NoError(t, repo.SetNotificationPreference(ctx, userID, entities.NotificationCoinsReceived, false))
+		require.NoError(t, repo.SetNotificationPreference(ctx, userID, entities.NotificationItemRefunded, false))
+		require.NoError(t, repo.SetNotificationPreference(ctx, userID, entities.NotificationItemRefunded, true))
+		preferences, err = repo.GetNotificationPreferences(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, entities.NotificationPreferences{
+			entities.NotificationCoinsReceived: false,
+			entities.NotificationItemRefunded:  true,
+		}, preferences)
+		preferences, err = repo.GetNotificationPreferences(ctx, otherID)
+		require.NoError(t, err)
+		assert.Empty(t, preferences)
+	})
+
+	t.Run("emails", func(t *testing.T) {
+		repo := newRepo(t)
+		username, userID := CreateUser(t, repo, "mail")
+		_, otherID := CreateUser(t, repo, "mail")
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		older := &entities.Notification{Kind: entities.NotificationCoinsReceived, Text: "bob sent you 5 coins", CreatedAt: now.Add(-2 * time.Hour)}
+		newer := &entities.Notification{Kind: entities.NotificationItemRefunded, Text: "your order was refunded", CreatedAt: now}
+		require.NoError(t, repo.AddNotification(ctx, userID, older))
+		require.NoError(t, repo.AddNotification(ctx, userID, newer))
+		require.NoError(t, repo.AddNotification(ctx, otherID, &entities.Notification{Kind: entities.NotificationCoinsReceived, Text: "x", CreatedAt: now}))
+
+		since, err := repo.GetNotificationsSince(ctx, userID, now.Add(-3*time.Hour), 10)
+		require.NoError(t, err)
+		require.Len(t, since, 2)
+		assert.Equal(t, older.ID, since[0].ID, "oldest first")
+		assert.Equal(t, newer.Text, since[1].Text)
+		since, err = repo.GetNotificationsSince(ctx, userID, older.CreatedAt, 10)
+		require.NoError(t, err)
+		require.Len(t, since, 1)
+		assert.Equal(t, newer.ID, since[0].ID)
+
+		_, err = repo.GetEmailSettings(ctx, userID)
+		assert.True(t, errors.Is(err, utils.ErrNoEmailSettings))
+
+		// Long overdue, so the lookups below find these before anything
+		// left by other tests.
+		due := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, repo.SetEmailSettings(ctx, userID, &entities.EmailSettings{Address: "mail@example.com", Mode: entities.EmailModeInstant, DigestSentAt: due}))
+		require.NoError(t, repo.SetEmailSettings(ctx, userID, &entities.EmailSettings{Address: "mail@example.org", Mode: entities.EmailModeDigest, DigestSentAt: due}))
+		require.NoError(t, repo.SetEmailSettings(ctx, otherID, &entities.EmailSettings{Address: "other@example.com", Mode: entities.EmailModeInstant, DigestSentAt: due}))
+		settings, err := repo.GetEmailSettings(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, "mail@example.org", settings.Address)
+		assert.Equal(t, entities.EmailModeDigest, settings.Mode)
+		assert.True(t, due.Equal(settings.DigestSentAt))
+
+		digests, err := repo.GetDueEmailDigests(ctx, due.Add(time.Hour), 100)
+		require.NoError(t, err)
+		var digest *entities.EmailDigest
+		for _, d := range digests {
+			assert.NotEqual(t, otherID, d.UserID, "only digest mode users")
+			if d.UserID == userID {
+				digest = d
+			}
+		}
+		require.NotNil(t, digest)
+		assert.Equal(t, username, digest.Username)
+		assert.Equal(t, "mail@example.org", digest.Address)
+		assert.True(t, due.Equal(digest.Since))
+
+		require.NoError(t, repo.MarkEmailDigestSent(ctx, userID, now))
+		digests, err = repo.GetDueEmailDigests(ctx, due.Add(time.Hour), 100)
+		require.NoError(t, err)
+		for _, d := range digests {
+			assert.NotEqual(t, userID, d.UserID, "a sent digest is not due")
+		}
+
+		email := &entities.Email{
+			UserID:        userID,
+			To:            "mail@example.org",
+			Subject:       "Your receipt",
+			Text:          "Thanks",
+			HTML:          "<p>Thanks</p>",
+			Status:        entities.EmailPending,
+			NextAttemptAt: due,
+			CreatedAt:     now,
+		}
+		require.NoError(t, repo.AddEmail(ctx, email))
+		assert.NotZero(t, email.ID)
+
+		claimed, err := repo.ClaimDueEmail(ctx, due.Add(time.Second), due.Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, email.ID, claimed.ID)
+		assert.Equal(t, userID, claimed.UserID)
+		assert.Equal(t, "mail@example.org", claimed.To)
+		assert.Equal(t, "Your receipt", claimed.Subject)
+		assert.Equal(t, "Thanks", claimed.Text)
+		assert.Equal(t, "<p>Thanks</p>", claimed.HTML)
+		assert.True(t, due.Add(time.Minute).Equal(claimed.NextAttemptAt))
+		assert.True(t, now.Equal(claimed.CreatedAt))
+		assert.Nil(t, claimed.SentAt)
+
+		_, err = repo.ClaimDueEmail(ctx, due.Add(time.Second), due.Add(time.Minute))
+		assert.True(t, errors.Is(err, utils.ErrNoEmail), "a claimed email is not due")
+
+		claimed.Attempts = 1
+		claimed.LastError = "421 try again later"
+		claimed.NextAttemptAt = due.Add(2 * time.Minute)
+		require.NoError(t, repo.UpdateEmail(ctx, claimed))
+		claimed, err = repo.ClaimDueEmail(ctx, due.Add(3*time.Minute), due.Add(4*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, email.ID, claimed.ID)
+		assert.Equal(t, 1, claimed.Attempts)
+		assert.Equal(t, "421 try again later", claimed.LastError)
+
+		claimed.Status = entities.EmailSent
+		claimed.Attempts = 2
+		claimed.SentAt = &now
+		require.NoError(t, repo.UpdateEmail(ctx, claimed))
+		_, err = repo.ClaimDueEmail(ctx, due.Add(time.Hour), due.Add(2*time.Hour))
+		assert.True(t, errors.Is(err, utils.ErrNoEmail), "a sent email is not claimed again")
+	})
+
+	t.Run("login attempts", func(t *testing.T) {
+		repo := newRepo(t)
+		username := NewUsername("locked")
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		attempts, err := repo.LockLoginAttempts(ctx, username)
+		require.NoError(t, err)
+		assert.Zero(t, attempts.FailedCount)
+		assert.True(t, attempts.BlockedUntil.IsZero())
+
+		failed, err := repo.RegisterFailedLogin(ctx, username, now.Add(-time.Hour), time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, failed)
+		failed, err = repo.RegisterFailedLogin(ctx, username, now, now.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 1, failed, "a failure before since is forgotten")
+		failed, err = repo.RegisterFailedLogin(ctx, username, now, now.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 2, failed)
+
+		until := now.Add(time.Minute)
+		require.NoError(t, repo.BlockLogin(ctx, username, until))
+
+		attempts, err = repo.LockLoginAttempts(ctx, username)
+		require.NoError(t, err)
+		assert.Equal(t, 2, attempts.FailedCount)
+		assert.True(t, attempts.LastFailedAt.Equal(now), "last failed at %v, want %v", attempts.LastFailedAt, now)
+		assert.True(t, attempts.BlockedUntil.Equal(until), "blocked until %v, want %v", attempts.BlockedUntil, until)
+
+		require.NoError(t, repo.AddLockoutEvent(ctx, &entities.LockoutEvent{
+			Username:    username,
+			Action:      entities.LockoutActionLocked,
+			FailedCount: 2,
+			LockedUntil: until,
+		}))
+
+		require.NoError(t, repo.ResetLoginAttempts(ctx, username))
+		attempts, err = repo.LockLoginAttempts(ctx, username)
+		require.NoError(t, err)
+		assert.Zero(t, attempts.FailedCount)
+		assert.True(t, attempts.BlockedUntil.IsZero())
+	})
+}
+
+var errRollback = errors.New("rollback")
+
+// RunTxManager checks that a TxManager makes repository calls made with its
+// ctx atomic. newRepo returns a repository and the TxManager for the same
+// storage.
+func RunTxManager(t *testing.T, newRepo func(t *testing.T) (service.UserRepo, service.TxManager)) {
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		repo, tx := newRepo(t)
+		_, senderID := CreateUser(t, repo, "sender")
+		_, receiverID := CreateUser(t, repo, "receiver")
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+
+		err = tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+			if err := repo.SendCoin(ctx, senderID, receiverID, 100, "", entities.TransferCategoryOther); err != nil {
+				return err
+			}
+			return repo.BuyItem(ctx, senderID, cupID)
+		})
+		require.NoError(t, err)
+
+		coins, err := repo.GetCoinsInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance-100-20, coins)
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		repo, tx := newRepo(t)
+		_, senderID := CreateUser(t, repo, "sender")
+		_, receiverID := CreateUser(t, repo, "receiver")
+		cupID, err := repo.GetItemID(ctx, "cup")
+		require.NoError(t, err)
+
+		err = tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+			if err := repo.SendCoin(ctx, senderID, receiverID, 100, "", entities.TransferCategoryOther); err != nil {
+				return err
+			}
+			if err := repo.BuyItem(ctx, senderID, cupID); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		assert.True(t, errors.Is(err, errRollback))
+
+		senderCoins, err := repo.GetCoinsInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance, senderCoins)
+
+		receiverCoins, err := repo.GetCoinsInfo(ctx, receiverID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance, receiverCoins)
+
+		inventory, err := repo.GetInventoryInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Empty(t, inventory)
+
+		sent, err := repo.GetSentInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Empty(t, sent)
+	})
+
+	t.Run("nested call joins outer transaction", func(t *testing.T) {
+		repo, tx := newRepo(t)
+		_, senderID := CreateUser(t, repo, "sender")
+		_, receiverID := CreateUser(t, repo, "receiver")
+
+		err := tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+			err := tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+				return repo.SendCoin(ctx, senderID, receiverID, 100, "", entities.TransferCategoryOther)
+			})
+			if err != nil {
+				return err
+			}
+			return errRollback
+		})
+		assert.True(t, errors.Is(err, errRollback))
+
+		coins, err := repo.GetCoinsInfo(ctx, senderID)
+		require.NoError(t, err)
+		assert.Equal(t, InitBalance, coins)
+	})
+
+	t.Run("concurrent audit appends", func(t *testing.T) {
+		repo, tx := newRepo(t)
+		auditLog := service.NewAuditLog(repo, tx)
+		actor := NewUsername("auditor")
+
+		prevHash, afterID := "", 0
+		last, err := repo.LastAuditEvent(ctx, "")
+		if err == nil {
+			prevHash, afterID = last.Hash, last.ID
+		} else {
+			require.True(t, errors.Is(err, utils.ErrNoAuditEvent))
+		}
+
+		const appends = 10
+		var wg sync.WaitGroup
+		for i := 0; i < appends; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, auditLog.Record(ctx, &entities.AuditEvent{
+					Action:    entities.AuditLoginSucceeded,
+					Actor:     actor,
+					CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+				}))
+			}()
+		}
+		wg.Wait()
+
+		events, err := repo.GetAuditEvents(ctx, entities.AuditFilter{}, afterID, 2*appends)
+		require.NoError(t, err)
+		assert.Len(t, events, appends)
+		assert.Equal(t, -1, audit.Verify(prevHash, events), "the appends form one chain")
+	})
+}
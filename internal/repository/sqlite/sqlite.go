@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// Open opens the SQLite database file at path. Transactions are started
+// with BEGIN IMMEDIATE so a read-check-write sequence inside one (e.g. the
+// balance check in BuyItem) cannot be interleaved with another writer, and
+// a single connection is used since SQLite serialises writers anyway.
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite open: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
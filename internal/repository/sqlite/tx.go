@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqltx"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// IsRetryable reports errors caused by another connection holding the
+// database lock past busy_timeout.
+func IsRetryable(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+
+	return false
+}
+
+func NewTxManager(db *sql.DB) *sqltx.Manager {
+	return sqltx.NewManager(db, IsRetryable)
+}
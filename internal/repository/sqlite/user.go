@@ -0,0 +1,1860 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqltx"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	InitBalance = 1000
+)
+
+// UserSQLiteRepo implements service.UserRepo on top of SQLite for single
+// node deployments. The database must be opened with Open.
+type UserSQLiteRepo struct {
+	DB *sql.DB
+}
+
+func NewUserSQLiteRepo(db *sql.DB) *UserSQLiteRepo {
+	return &UserSQLiteRepo{
+		DB: db,
+	}
+}
+
+// querier returns the transaction carried by ctx, or the pool.
+func (u *UserSQLiteRepo) querier(ctx context.Context) sqltx.Querier {
+	return sqltx.From(ctx, u.DB)
+}
+
+func (u *UserSQLiteRepo) GetItemID(ctx context.Context, itemName string) (int, error) {
+	var itemID int
+	err := u.querier(ctx).QueryRowContext(ctx, GetItemID, itemName).Scan(&itemID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("sqlite get item id: %w", utils.ErrNoItem)
+		}
+		return 0, fmt.Errorf("sqlite get item id: %w", err)
+	}
+
+	return itemID, nil
+}
+
+// GetCatalog lists every item the shop sells, by name.
+func (u *UserSQLiteRepo) GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetCatalog)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get catalog: %w", err)
+	}
+	defer rows.Close()
+
+	catalog := make([]*entities.CatalogItem, 0)
+	for rows.Next() {
+		item := &entities.CatalogItem{}
+		if err := rows.Scan(&item.Name, &item.Price); err != nil {
+			return nil, fmt.Errorf("sqlite get catalog: %w", err)
+		}
+		catalog = append(catalog, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get catalog: %w", err)
+	}
+
+	return catalog, nil
+}
+
+// SetItemPrice sets an item's price, adding it to the catalog if it is new.
+func (u *UserSQLiteRepo) SetItemPrice(ctx context.Context, itemName string, price int) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, SetItemPrice, itemName, price); err != nil {
+		return fmt.Errorf("sqlite set item price: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) GetUserID(ctx context.Context, username string) (int, error) {
+	var userID int
+	err := u.querier(ctx).QueryRowContext(ctx, GetUserID, username).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("sqlite get user id: %w", utils.ErrNoUser)
+		}
+		return 0, fmt.Errorf("sqlite get user id: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (u *UserSQLiteRepo) BuyItem(ctx context.Context, userID, itemID int) error {
+	return u.buy(ctx, userID, itemID, sql.NullInt64{}, "")
+}
+
+// GiftItem is BuyItem where the unit goes to recipientID's inventory.
+func (u *UserSQLiteRepo) GiftItem(ctx context.Context, userID, recipientID, itemID int, message string) error {
+	return u.buy(ctx, userID, itemID, sql.NullInt64{Int64: int64(recipientID), Valid: true}, message)
+}
+
+func (u *UserSQLiteRepo) buy(ctx context.Context, userID, itemID int, recipientID sql.NullInt64, message string) error {
+	return sqltx.Run(ctx, u.DB, func(q sqltx.Querier) error {
+		var balance int
+		if err := q.QueryRowContext(ctx, GetBalance, userID).Scan(&balance); err != nil {
+			return fmt.Errorf("sqlite get balance: %w", err)
+		}
+
+		var price int
+		if err := q.QueryRowContext(ctx, GetPrice, itemID).Scan(&price); err != nil {
+			return fmt.Errorf("sqlite get price: %w", err)
+		}
+
+		if balance < price {
+			return fmt.Errorf("sqlite buy item: %w", utils.ErrNotEnoughBalance)
+		}
+
+		// orders.recipient_id has no foreign key in SQLite, so check it here.
+		if recipientID.Valid {
+			var recipientBalance int
+			if err := q.QueryRowContext(ctx, GetBalance, recipientID.Int64).Scan(&recipientBalance); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("sqlite gift item: %w", utils.ErrNoUser)
+				}
+				return fmt.Errorf("sqlite gift item: %w", err)
+			}
+		}
+
+		if _, err := q.ExecContext(ctx, ReduceCoins, price, userID); err != nil {
+			return fmt.Errorf("sqlite buy item: %w", err)
+		}
+
+		if _, err := q.ExecContext(ctx, AddOrder, userID, itemID, price, recipientID, message); err != nil {
+			return fmt.Errorf("sqlite add order: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserSQLiteRepo) SendCoin(ctx context.Context, fromUserID, toUserID int, amount int, memo, category string) error {
+	return sqltx.Run(ctx, u.DB, func(q sqltx.Querier) error {
+		var balance int
+		if err := q.QueryRowContext(ctx, GetBalance, fromUserID).Scan(&balance); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("sqlite send coin: %w", utils.ErrNoUser)
+			}
+			return fmt.Errorf("sqlite send coin: %w", err)
+		}
+		if balance < amount {
+			return fmt.Errorf("sqlite send coin: %w", utils.ErrNotEnoughBalance)
+		}
+
+		if _, err := q.ExecContext(ctx, ReduceCoins, amount, fromUserID); err != nil {
+			return fmt.Errorf("sqlite send coin: %w", err)
+		}
+
+		res, err := q.ExecContext(ctx, AddCoins, amount, toUserID)
+		if err != nil {
+			return fmt.Errorf("sqlite send coin: %w", err)
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("sqlite send coin: %w", err)
+		} else if affected == 0 {
+			return fmt.Errorf("sqlite send coin: %w", utils.ErrNoUser)
+		}
+
+		if _, err := q.ExecContext(ctx, AddExchangeRecord, fromUserID, toUserID, amount, memo, category); err != nil {
+			return fmt.Errorf("sqlite send coin: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserSQLiteRepo) Auth(ctx context.Context, username, password string) (*entities.User, error) {
+	user := &entities.User{}
+	err := u.querier(ctx).QueryRowContext(ctx, GetUser, username).Scan(&user.ID, &user.Username, &user.Password, &user.Role)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite auth: %w", err)
+		}
+
+		if _, err := u.querier(ctx).ExecContext(ctx, CreateUser, username, password, InitBalance); err != nil {
+			return nil, fmt.Errorf("sqlite auth: %w", err)
+		}
+
+		return &entities.User{
+			Username:   username,
+			Role:       entities.RoleUser,
+			Registered: true,
+		}, nil
+	}
+
+	if user.Password != password {
+		return nil, fmt.Errorf("sqlite auth: %w", utils.ErrWrongPass)
+	}
+
+	return &entities.User{
+		Username: username,
+		Role:     user.Role,
+	}, nil
+}
+
+func (u *UserSQLiteRepo) GetCoinsInfo(ctx context.Context, userID int) (int, error) {
+	var coins int
+	if err := u.querier(ctx).QueryRowContext(ctx, GetCoins, userID).Scan(&coins); err != nil {
+		return 0, fmt.Errorf("sqlite get coin info: %w", err)
+	}
+
+	return coins, nil
+}
+
+func (u *UserSQLiteRepo) GetInventoryInfo(ctx context.Context, userID int) ([]*entities.Item, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetInventory, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get inventory: %w", err)
+	}
+	defer rows.Close()
+
+	inventory := make([]*entities.Item, 0)
+	for rows.Next() {
+		item := &entities.Item{}
+		if err := rows.Scan(&item.ItemType, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("sqlite get inventory: %w", err)
+		}
+		inventory = append(inventory, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get inventory: %w", err)
+	}
+
+	return inventory, nil
+}
+
+func (u *UserSQLiteRepo) GetReceiveInfo(ctx context.Context, userID int) ([]*entities.ReceiveOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetReceiveInfo, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get receive info: %w", err)
+	}
+	defer rows.Close()
+
+	receives := make([]*entities.ReceiveOperation, 0)
+	for rows.Next() {
+		op := &entities.ReceiveOperation{}
+		if err := rows.Scan(&op.FromUser, &op.Amount, &op.Memo, &op.Category); err != nil {
+			return nil, fmt.Errorf("sqlite get receive info: %w", err)
+		}
+		receives = append(receives, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get receive info: %w", err)
+	}
+
+	return receives, nil
+}
+
+func (u *UserSQLiteRepo) GetSentInfo(ctx context.Context, userID int) ([]*entities.SentOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSentInfo, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get sent info: %w", err)
+	}
+	defer rows.Close()
+
+	sents := make([]*entities.SentOperation, 0)
+	for rows.Next() {
+		op := &entities.SentOperation{}
+		if err := rows.Scan(&op.ToUser, &op.Amount, &op.Memo, &op.Category); err != nil {
+			return nil, fmt.Errorf("sqlite get sent info: %w", err)
+		}
+		sents = append(sents, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get sent info: %w", err)
+	}
+
+	return sents, nil
+}
+
+// LockLoginAttempts creates the username's row if it is missing, so there
+// is always a row to lock, and holds it until the transaction ends.
+func (u *UserSQLiteRepo) LockLoginAttempts(ctx context.Context, username string) (*entities.LoginAttempts, error) {
+	attempts := &entities.LoginAttempts{
+		Username: username,
+	}
+
+	var lastFailedAt, blockedUntil sql.NullTime
+	err := u.querier(ctx).QueryRowContext(ctx, LockLoginAttempts, username).Scan(&attempts.FailedCount, &lastFailedAt, &blockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite lock login attempts: %w", err)
+	}
+	attempts.LastFailedAt = lastFailedAt.Time
+	attempts.BlockedUntil = blockedUntil.Time
+
+	return attempts, nil
+}
+
+func (u *UserSQLiteRepo) RegisterFailedLogin(ctx context.Context, username string, at, since time.Time) (int, error) {
+	var failed int
+	if err := u.querier(ctx).QueryRowContext(ctx, RegisterFailedLogin, username, at.UTC(), since.UTC()).Scan(&failed); err != nil {
+		return 0, fmt.Errorf("sqlite register failed login: %w", err)
+	}
+
+	return failed, nil
+}
+
+func (u *UserSQLiteRepo) BlockLogin(ctx context.Context, username string, until time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, BlockLogin, until, username); err != nil {
+		return fmt.Errorf("sqlite block login: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) ResetLoginAttempts(ctx context.Context, username string) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, ResetLoginAttempts, username); err != nil {
+		return fmt.Errorf("sqlite reset login attempts: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) SetUserRole(ctx context.Context, username, role string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, SetUserRole, role, username)
+	if err != nil {
+		return fmt.Errorf("sqlite set user role: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite set user role: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("sqlite set user role: %w", utils.ErrNoUser)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) AddLockoutEvent(ctx context.Context, event *entities.LockoutEvent) error {
+	var lockedUntil sql.NullTime
+	if !event.LockedUntil.IsZero() {
+		lockedUntil = sql.NullTime{Time: event.LockedUntil, Valid: true}
+	}
+
+	_, err := u.querier(ctx).ExecContext(ctx, AddLockoutEvent, event.Username, event.Action, event.Actor, event.FailedCount, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("sqlite add lockout event: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) GetRefundableOrders(ctx context.Context, userID, itemID int) ([]*entities.Order, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetRefundableOrders, userID, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get refundable orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]*entities.Order, 0)
+	for rows.Next() {
+		order := &entities.Order{}
+		if err := rows.Scan(&order.ID, &order.ItemType, &order.Quantity, &order.UnitPrice, &order.Refunded, &order.CreatedAt); err != nil {
+			return nil, fmt.Errorf("sqlite get refundable orders: %w", err)
+		}
+		order.Total = order.Quantity * order.UnitPrice
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get refundable orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// AddRefund records the refund, which takes the units out of the inventory,
+// and credits its amount. It fails with utils.ErrNotEnoughItems if the user no
+// longer holds that many units.
+func (u *UserSQLiteRepo) AddRefund(ctx context.Context, refund *entities.Refund) error {
+	return sqltx.Run(ctx, u.DB, func(q sqltx.Querier) error {
+		var held int
+		if err := q.QueryRowContext(ctx, GetItemQuantity, refund.UserID, refund.ItemID).Scan(&held); err != nil {
+			return fmt.Errorf("sqlite add refund: %w", err)
+		}
+		if held < refund.Quantity {
+			return fmt.Errorf("sqlite add refund: %w", utils.ErrNotEnoughItems)
+		}
+
+		if _, err := q.ExecContext(ctx, AddCoins, refund.Amount, refund.UserID); err != nil {
+			return fmt.Errorf("sqlite add refund: %w", err)
+		}
+
+		_, err := q.ExecContext(ctx, AddRefund, refund.OrderID, refund.UserID, refund.Quantity, refund.Amount, refund.Actor)
+		if err != nil {
+			return fmt.Errorf("sqlite add refund: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserSQLiteRepo) GetRefundInfo(ctx context.Context, userID int) ([]*entities.RefundOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetRefundInfo, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get refund info: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := make([]*entities.RefundOperation, 0)
+	for rows.Next() {
+		op := &entities.RefundOperation{}
+		if err := rows.Scan(&op.Item, &op.Quantity, &op.Amount, &op.RefundedBy); err != nil {
+			return nil, fmt.Errorf("sqlite get refund info: %w", err)
+		}
+		refunds = append(refunds, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get refund info: %w", err)
+	}
+
+	return refunds, nil
+}
+
+// GetOrders returns a page of the user's orders, newest first. An empty
+// itemName matches every item.
+func (u *UserSQLiteRepo) GetOrders(ctx context.Context, userID int, itemName string, limit, offset int) ([]*entities.Order, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetOrders, userID, itemName, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]*entities.Order, 0)
+	for rows.Next() {
+		order := &entities.Order{}
+		if err := rows.Scan(&order.ID, &order.ItemType, &order.Quantity, &order.UnitPrice, &order.Refunded, &order.GiftTo, &order.Message, &order.CreatedAt); err != nil {
+			return nil, fmt.Errorf("sqlite get orders: %w", err)
+		}
+		order.Total = order.Quantity * order.UnitPrice
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+func (u *UserSQLiteRepo) CountOrders(ctx context.Context, userID int, itemName string) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountOrders, userID, itemName).Scan(&count); err != nil {
+		return 0, fmt.Errorf("sqlite count orders: %w", err)
+	}
+
+	return count, nil
+}
+
+func (u *UserSQLiteRepo) GetSentGifts(ctx context.Context, userID int) ([]*entities.SentGift, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSentGifts, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get sent gifts: %w", err)
+	}
+	defer rows.Close()
+
+	gifts := make([]*entities.SentGift, 0)
+	for rows.Next() {
+		gift := &entities.SentGift{}
+		if err := rows.Scan(&gift.ToUser, &gift.Item, &gift.Message); err != nil {
+			return nil, fmt.Errorf("sqlite get sent gifts: %w", err)
+		}
+		gifts = append(gifts, gift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get sent gifts: %w", err)
+	}
+
+	return gifts, nil
+}
+
+func (u *UserSQLiteRepo) GetReceivedGifts(ctx context.Context, userID int) ([]*entities.ReceivedGift, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetReceivedGifts, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get received gifts: %w", err)
+	}
+	defer rows.Close()
+
+	gifts := make([]*entities.ReceivedGift, 0)
+	for rows.Next() {
+		gift := &entities.ReceivedGift{}
+		if err := rows.Scan(&gift.FromUser, &gift.Item, &gift.Message); err != nil {
+			return nil, fmt.Errorf("sqlite get received gifts: %w", err)
+		}
+		gifts = append(gifts, gift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get received gifts: %w", err)
+	}
+
+	return gifts, nil
+}
+
+func (u *UserSQLiteRepo) TransferItem(ctx context.Context, fromUserID, toUserID, itemID, quantity int) error {
+	return sqltx.Run(ctx, u.DB, func(q sqltx.Querier) error {
+		for _, userID := range []int{fromUserID, toUserID} {
+			var balance int
+			if err := q.QueryRowContext(ctx, GetBalance, userID).Scan(&balance); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("sqlite transfer item: %w", utils.ErrNoUser)
+				}
+				return fmt.Errorf("sqlite transfer item: %w", err)
+			}
+		}
+
+		var held int
+		if err := q.QueryRowContext(ctx, GetItemQuantity, fromUserID, itemID).Scan(&held); err != nil {
+			return fmt.Errorf("sqlite transfer item: %w", err)
+		}
+		if held < quantity {
+			return fmt.Errorf("sqlite transfer item: %w", utils.ErrNotEnoughItems)
+		}
+
+		if _, err := q.ExecContext(ctx, AddItemTransfer, fromUserID, toUserID, itemID, quantity); err != nil {
+			return fmt.Errorf("sqlite transfer item: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserSQLiteRepo) GetSentItems(ctx context.Context, userID int) ([]*entities.SentItemOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSentItems, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get sent items: %w", err)
+	}
+	defer rows.Close()
+
+	operations := make([]*entities.SentItemOperation, 0)
+	for rows.Next() {
+		op := &entities.SentItemOperation{}
+		if err := rows.Scan(&op.ToUser, &op.Item, &op.Quantity); err != nil {
+			return nil, fmt.Errorf("sqlite get sent items: %w", err)
+		}
+		operations = append(operations, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get sent items: %w", err)
+	}
+
+	return operations, nil
+}
+
+func (u *UserSQLiteRepo) GetReceivedItems(ctx context.Context, userID int) ([]*entities.ReceivedItemOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetReceivedItems, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get received items: %w", err)
+	}
+	defer rows.Close()
+
+	operations := make([]*entities.ReceivedItemOperation, 0)
+	for rows.Next() {
+		op := &entities.ReceivedItemOperation{}
+		if err := rows.Scan(&op.FromUser, &op.Item, &op.Quantity); err != nil {
+			return nil, fmt.Errorf("sqlite get received items: %w", err)
+		}
+		operations = append(operations, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get received items: %w", err)
+	}
+
+	return operations, nil
+}
+
+func (u *UserSQLiteRepo) CreateListing(ctx context.Context, sellerID, itemID, quantity, price int, expiresAt time.Time) (int, error) {
+	var listingID int
+	err := sqltx.Run(ctx, u.DB, func(q sqltx.Querier) error {
+		var balance int
+		if err := q.QueryRowContext(ctx, GetBalance, sellerID).Scan(&balance); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("sqlite create listing: %w", utils.ErrNoUser)
+			}
+			return fmt.Errorf("sqlite create listing: %w", err)
+		}
+
+		var held int
+		if err := q.QueryRowContext(ctx, GetItemQuantity, sellerID, itemID).Scan(&held); err != nil {
+			return fmt.Errorf("sqlite create listing: %w", err)
+		}
+		if held < quantity {
+			return fmt.Errorf("sqlite create listing: %w", utils.ErrNotEnoughItems)
+		}
+
+		if err := q.QueryRowContext(ctx, AddListing, sellerID, itemID, quantity, price, expiresAt.UTC()).Scan(&listingID); err != nil {
+			return fmt.Errorf("sqlite create listing: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return listingID, nil
+}
+
+// scanListing reads a row of GetListing or GetListings. Listing times are
+// written in UTC so that comparing their text form in SQL orders them
+// correctly.
+func scanListing(scanner interface{ Scan(dest ...any) error }) (*entities.Listing, error) {
+	listing := &entities.Listing{}
+	err := scanner.Scan(&listing.ID, &listing.SellerID, &listing.Seller, &listing.ItemType, &listing.Quantity,
+		&listing.Price, &listing.Status, &listing.Buyer, &listing.Fee, &listing.CreatedAt, &listing.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+func (u *UserSQLiteRepo) GetListing(ctx context.Context, listingID int) (*entities.Listing, error) {
+	listing, err := scanListing(u.querier(ctx).QueryRowContext(ctx, GetListing, listingID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite get listing: %w", utils.ErrNoListing)
+		}
+		return nil, fmt.Errorf("sqlite get listing: %w", err)
+	}
+
+	return listing, nil
+}
+
+func (u *UserSQLiteRepo) GetListings(ctx context.Context, itemName string, now time.Time, limit, offset int) ([]*entities.Listing, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetListings, itemName, now.UTC(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get listings: %w", err)
+	}
+	defer rows.Close()
+
+	listings := make([]*entities.Listing, 0)
+	for rows.Next() {
+		listing, err := scanListing(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get listings: %w", err)
+		}
+		listings = append(listings, listing)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get listings: %w", err)
+	}
+
+	return listings, nil
+}
+
+func (u *UserSQLiteRepo) CountListings(ctx context.Context, itemName string, now time.Time) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountListings, itemName, now.UTC()).Scan(&count); err != nil {
+		return 0, fmt.Errorf("sqlite count listings: %w", err)
+	}
+
+	return count, nil
+}
+
+func (u *UserSQLiteRepo) SellListing(ctx context.Context, listingID, buyerID, fee int) error {
+	return sqltx.Run(ctx, u.DB, func(q sqltx.Querier) error {
+		// Closing the listing first makes a concurrent buyer, cancel or
+		// expiry find it no longer open.
+		var sellerID, price int
+		if err := q.QueryRowContext(ctx, SellListing, listingID, buyerID, fee).Scan(&sellerID, &price); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("sqlite sell listing: %w", utils.ErrListingClosed)
+			}
+			return fmt.Errorf("sqlite sell listing: %w", err)
+		}
+
+		var balance int
+		if err := q.QueryRowContext(ctx, GetBalance, buyerID).Scan(&balance); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("sqlite sell listing: %w", utils.ErrNoUser)
+			}
+			return fmt.Errorf("sqlite sell listing: %w", err)
+		}
+		if balance < price {
+			return fmt.Errorf("sqlite sell listing: %w", utils.ErrNotEnoughBalance)
+		}
+
+		if _, err := q.ExecContext(ctx, ReduceCoins, price, buyerID); err != nil {
+			return fmt.Errorf("sqlite sell listing: %w", err)
+		}
+
+		// The fee is burned: the seller is credited the price without it.
+		if _, err := q.ExecContext(ctx, AddCoins, price-fee, sellerID); err != nil {
+			return fmt.Errorf("sqlite sell listing: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserSQLiteRepo) CloseListing(ctx context.Context, listingID int, status string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, CloseListing, listingID, status)
+	if err != nil {
+		return fmt.Errorf("sqlite close listing: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite close listing: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("sqlite close listing: %w", utils.ErrListingClosed)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) ExpireListings(ctx context.Context, now time.Time) (int, error) {
+	result, err := u.querier(ctx).ExecContext(ctx, ExpireListings, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("sqlite expire listings: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("sqlite expire listings: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// likePattern turns a search term into a LIKE pattern matching any value
+// that contains it, or "" for no term.
+func likePattern(term string) string {
+	if term == "" {
+		return ""
+	}
+
+	term = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+	return "%" + term + "%"
+}
+
+func (u *UserSQLiteRepo) SearchTransfers(ctx context.Context, userID int, filter entities.TransferFilter, limit, offset int) ([]*entities.CoinTransfer, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, SearchTransfers, userID, filter.Direction, filter.Category, likePattern(filter.Query), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite search transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := make([]*entities.CoinTransfer, 0)
+	for rows.Next() {
+		transfer := &entities.CoinTransfer{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&transfer.ID, &transfer.FromUser, &transfer.ToUser, &transfer.Amount, &transfer.Memo, &transfer.Category, &createdAt); err != nil {
+			return nil, fmt.Errorf("sqlite search transfers: %w", err)
+		}
+		if createdAt.Valid {
+			transfer.CreatedAt = &createdAt.Time
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite search transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (u *UserSQLiteRepo) CountTransfers(ctx context.Context, userID int, filter entities.TransferFilter) (int, error) {
+	var count int
+	err := u.querier(ctx).QueryRowContext(ctx, CountTransfers, userID, filter.Direction, filter.Category, likePattern(filter.Query)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite count transfers: %w", err)
+	}
+
+	return count, nil
+}
+
+// scanSchedule reads a row selected with scheduleSelect. Schedule times are
+// written in UTC so that comparing their text form in SQL orders them
+// correctly.
+func scanSchedule(scanner interface{ Scan(dest ...any) error }) (*entities.ScheduledTransfer, error) {
+	schedule := &entities.ScheduledTransfer{}
+	err := scanner.Scan(&schedule.ID, &schedule.FromUserID, &schedule.FromUser, &schedule.ToUserID, &schedule.ToUser, &schedule.Amount,
+		&schedule.Memo, &schedule.Category, &schedule.Recurrence, &schedule.NextRunAt, &schedule.AnchorDay, &schedule.Status, &schedule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (u *UserSQLiteRepo) CreateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) (int, error) {
+	var scheduleID int
+	err := u.querier(ctx).QueryRowContext(ctx, AddSchedule, schedule.FromUserID, schedule.ToUserID, schedule.Amount,
+		schedule.Memo, schedule.Category, schedule.Recurrence, schedule.NextRunAt.UTC(), schedule.AnchorDay).Scan(&scheduleID)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite create schedule: %w", err)
+	}
+
+	return scheduleID, nil
+}
+
+func (u *UserSQLiteRepo) GetSchedule(ctx context.Context, scheduleID int) (*entities.ScheduledTransfer, error) {
+	schedule, err := scanSchedule(u.querier(ctx).QueryRowContext(ctx, GetSchedule, scheduleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite get schedule: %w", utils.ErrNoSchedule)
+		}
+		return nil, fmt.Errorf("sqlite get schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (u *UserSQLiteRepo) GetSchedules(ctx context.Context, userID int) ([]*entities.ScheduledTransfer, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSchedules, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make([]*entities.ScheduledTransfer, 0)
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get schedules: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (u *UserSQLiteRepo) UpdateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) error {
+	result, err := u.querier(ctx).ExecContext(ctx, UpdateSchedule, schedule.ID, schedule.ToUserID, schedule.Amount,
+		schedule.Memo, schedule.Category, schedule.Recurrence, schedule.NextRunAt.UTC(), schedule.AnchorDay)
+	if err != nil {
+		return fmt.Errorf("sqlite update schedule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite update schedule: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("sqlite update schedule: %w", utils.ErrScheduleClosed)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) CloseSchedule(ctx context.Context, scheduleID int, status string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, CloseSchedule, scheduleID, status)
+	if err != nil {
+		return fmt.Errorf("sqlite close schedule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite close schedule: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("sqlite close schedule: %w", utils.ErrScheduleClosed)
+	}
+
+	return nil
+}
+
+// ClaimDueSchedule returns the earliest schedule due at now. Transactions
+// begin immediately, so no other writer can claim it before this one ends.
+func (u *UserSQLiteRepo) ClaimDueSchedule(ctx context.Context, now time.Time) (*entities.ScheduledTransfer, error) {
+	schedule, err := scanSchedule(u.querier(ctx).QueryRowContext(ctx, ClaimDueSchedule, now.UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite claim due schedule: %w", utils.ErrNoSchedule)
+		}
+		return nil, fmt.Errorf("sqlite claim due schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (u *UserSQLiteRepo) AddScheduleRun(ctx context.Context, scheduleID int, run *entities.ScheduledTransferRun) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddScheduleRun, scheduleID, run.RunAt.UTC(), run.Status, run.Error).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add schedule run: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) GetScheduleRuns(ctx context.Context, scheduleID, limit int) ([]*entities.ScheduledTransferRun, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetScheduleRuns, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*entities.ScheduledTransferRun, 0)
+	for rows.Next() {
+		run := &entities.ScheduledTransferRun{}
+		if err := rows.Scan(&run.ID, &run.RunAt, &run.Status, &run.Error); err != nil {
+			return nil, fmt.Errorf("sqlite get schedule runs: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get schedule runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// scanPaymentRequest reads a row selected with paymentRequestSelect.
+func scanPaymentRequest(scanner interface{ Scan(dest ...any) error }) (*entities.PaymentRequest, error) {
+	request := &entities.PaymentRequest{}
+	var closedAt sql.NullTime
+	err := scanner.Scan(&request.ID, &request.RequesterID, &request.Requester, &request.PayerID, &request.Payer, &request.Amount,
+		&request.Memo, &request.Category, &request.Status, &request.CreatedAt, &request.ExpiresAt, &closedAt)
+	if err != nil {
+		return nil, err
+	}
+	if closedAt.Valid {
+		request.ClosedAt = &closedAt.Time
+	}
+
+	return request, nil
+}
+
+func (u *UserSQLiteRepo) CreatePaymentRequest(ctx context.Context, request *entities.PaymentRequest) (int, error) {
+	var requestID int
+	err := u.querier(ctx).QueryRowContext(ctx, AddPaymentRequest, request.RequesterID, request.PayerID, request.Amount,
+		request.Memo, request.Category, request.ExpiresAt.UTC()).Scan(&requestID)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite create payment request: %w", err)
+	}
+
+	return requestID, nil
+}
+
+func (u *UserSQLiteRepo) GetPaymentRequest(ctx context.Context, requestID int) (*entities.PaymentRequest, error) {
+	request, err := scanPaymentRequest(u.querier(ctx).QueryRowContext(ctx, GetPaymentRequest, requestID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite get payment request: %w", utils.ErrNoPaymentRequest)
+		}
+		return nil, fmt.Errorf("sqlite get payment request: %w", err)
+	}
+
+	return request, nil
+}
+
+func (u *UserSQLiteRepo) GetPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter, limit, offset int) ([]*entities.PaymentRequest, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetPaymentRequests, userID, filter.Direction, filter.Status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get payment requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]*entities.PaymentRequest, 0)
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get payment requests: %w", err)
+		}
+		requests = append(requests, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get payment requests: %w", err)
+	}
+
+	return requests, nil
+}
+
+func (u *UserSQLiteRepo) CountPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter) (int, error) {
+	var count int
+	err := u.querier(ctx).QueryRowContext(ctx, CountPaymentRequests, userID, filter.Direction, filter.Status).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite count payment requests: %w", err)
+	}
+
+	return count, nil
+}
+
+func (u *UserSQLiteRepo) ClosePaymentRequest(ctx context.Context, requestID int, status string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, ClosePaymentRequest, requestID, status)
+	if err != nil {
+		return fmt.Errorf("sqlite close payment request: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite close payment request: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("sqlite close payment request: %w", utils.ErrPaymentRequestClosed)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) ExpirePaymentRequests(ctx context.Context, now time.Time) ([]*entities.PaymentRequest, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, ExpirePaymentRequests, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite expire payment requests: %w", err)
+	}
+	defer rows.Close()
+
+	expired := make([]*entities.PaymentRequest, 0)
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite expire payment requests: %w", err)
+		}
+		expired = append(expired, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite expire payment requests: %w", err)
+	}
+
+	return expired, nil
+}
+
+func (u *UserSQLiteRepo) GetTransferLimits(ctx context.Context, userID int) (*entities.UserTransferLimits, error) {
+	limits := &entities.UserTransferLimits{Override: true}
+	var updatedAt time.Time
+	err := u.querier(ctx).QueryRowContext(ctx, GetTransferLimits, userID).Scan(&limits.Username, &limits.MaxTransfer,
+		&limits.DailyAmount, &limits.WeeklyAmount, &limits.DailyRecipients, &limits.UpdatedBy, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite get transfer limits: %w", utils.ErrNoTransferLimits)
+		}
+		return nil, fmt.Errorf("sqlite get transfer limits: %w", err)
+	}
+	limits.UpdatedAt = &updatedAt
+
+	return limits, nil
+}
+
+func (u *UserSQLiteRepo) SetTransferLimits(ctx context.Context, userID int, limits entities.TransferLimits, actor string) error {
+	_, err := u.querier(ctx).ExecContext(ctx, SetTransferLimits, userID, limits.MaxTransfer, limits.DailyAmount,
+		limits.WeeklyAmount, limits.DailyRecipients, actor)
+	if err != nil {
+		return fmt.Errorf("sqlite set transfer limits: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) DeleteTransferLimits(ctx context.Context, userID int) error {
+	result, err := u.querier(ctx).ExecContext(ctx, DeleteTransferLimits, userID)
+	if err != nil {
+		return fmt.Errorf("sqlite delete transfer limits: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite delete transfer limits: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("sqlite delete transfer limits: %w", utils.ErrNoTransferLimits)
+	}
+
+	return nil
+}
+
+// sqliteTimestamp is the layout of CURRENT_TIMESTAMP, used to compare
+// against columns it fills.
+const sqliteTimestamp = "2006-01-02 15:04:05"
+
+func (u *UserSQLiteRepo) GetTransferUsage(ctx context.Context, fromUserID, toUserID int, since time.Time) (*entities.TransferUsage, error) {
+	usage := &entities.TransferUsage{}
+	err := u.querier(ctx).QueryRowContext(ctx, GetTransferUsage, fromUserID, toUserID, since.UTC().Format(sqliteTimestamp)).Scan(&usage.Amount, &usage.Recipients)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get transfer usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+func (u *UserSQLiteRepo) AdjustBalance(ctx context.Context, userID, delta int) (int, error) {
+	var balance int
+	err := u.querier(ctx).QueryRowContext(ctx, AdjustBalance, userID, delta).Scan(&balance)
+	if err == nil {
+		return balance, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("sqlite adjust balance: %w", err)
+	}
+
+	// Nothing was updated: either the user does not exist or the change
+	// would leave a negative balance.
+	err = u.querier(ctx).QueryRowContext(ctx, GetCoins, userID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("sqlite adjust balance: %w", utils.ErrNoUser)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("sqlite adjust balance: %w", err)
+	}
+
+	return 0, fmt.Errorf("sqlite adjust balance: %w", utils.ErrNotEnoughBalance)
+}
+
+func (u *UserSQLiteRepo) AddBalanceAdjustment(ctx context.Context, adjustment *entities.BalanceAdjustment) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddBalanceAdjustment, adjustment.UserID, adjustment.Actor, adjustment.Kind,
+		adjustment.Amount, adjustment.Balance, adjustment.Reason, adjustment.CreatedAt.UTC()).Scan(&adjustment.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add balance adjustment: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) GetBalanceAdjustments(ctx context.Context, userID, limit, offset int) ([]*entities.BalanceAdjustment, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetBalanceAdjustments, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get balance adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	adjustments := make([]*entities.BalanceAdjustment, 0)
+	for rows.Next() {
+		adjustment := &entities.BalanceAdjustment{}
+		err := rows.Scan(&adjustment.ID, &adjustment.UserID, &adjustment.Username, &adjustment.Actor, &adjustment.Kind,
+			&adjustment.Amount, &adjustment.Balance, &adjustment.Reason, &adjustment.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get balance adjustments: %w", err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get balance adjustments: %w", err)
+	}
+
+	return adjustments, nil
+}
+
+func (u *UserSQLiteRepo) CountBalanceAdjustments(ctx context.Context, userID int) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountBalanceAdjustments, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("sqlite count balance adjustments: %w", err)
+	}
+
+	return count, nil
+}
+
+// LockAuditLog does nothing: the pool has a single connection, so
+// transactions already run one at a time.
+func (u *UserSQLiteRepo) LockAuditLog(ctx context.Context) error {
+	return nil
+}
+
+func (u *UserSQLiteRepo) AddAuditEvent(ctx context.Context, event *entities.AuditEvent) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddAuditEvent, event.Action, event.Actor, event.Target, event.IP, event.UserAgent,
+		event.RequestID, event.Details, event.CreatedAt.UTC(), event.PrevHash, event.Hash).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add audit event: %w", err)
+	}
+
+	return nil
+}
+
+// LastAuditEvent returns the newest audit event, or the newest with action
+// if it is not empty.
+func (u *UserSQLiteRepo) LastAuditEvent(ctx context.Context, action string) (*entities.AuditEvent, error) {
+	event, err := scanAuditEvent(u.querier(ctx).QueryRowContext(ctx, LastAuditEvent, action))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite last audit event: %w", utils.ErrNoAuditEvent)
+		}
+		return nil, fmt.Errorf("sqlite last audit event: %w", err)
+	}
+
+	return event, nil
+}
+
+// GetAuditEvents returns up to limit events matching filter with ids above
+// afterID, in log order.
+func (u *UserSQLiteRepo) GetAuditEvents(ctx context.Context, filter entities.AuditFilter, afterID, limit int) ([]*entities.AuditEvent, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetAuditEvents, filter.Action, filter.Actor, filter.Target,
+		auditTime(filter.Since), auditTime(filter.Until), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*entities.AuditEvent, 0)
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get audit events: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (u *UserSQLiteRepo) CountAuditEvents(ctx context.Context, filter entities.AuditFilter) (int, error) {
+	var count int
+	err := u.querier(ctx).QueryRowContext(ctx, CountAuditEvents, filter.Action, filter.Actor, filter.Target,
+		auditTime(filter.Since), auditTime(filter.Until)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite count audit events: %w", err)
+	}
+
+	return count, nil
+}
+
+// scanAuditEvent reads a row selected with auditEventSelect.
+func scanAuditEvent(scanner interface{ Scan(dest ...any) error }) (*entities.AuditEvent, error) {
+	event := &entities.AuditEvent{}
+	err := scanner.Scan(&event.ID, &event.Action, &event.Actor, &event.Target, &event.IP, &event.UserAgent,
+		&event.RequestID, &event.Details, &event.CreatedAt, &event.PrevHash, &event.Hash)
+	if err != nil {
+		return nil, err
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+
+	return event, nil
+}
+
+// auditTime turns an AuditFilter bound into a query argument; the zero
+// time, meaning no bound, becomes NULL.
+func auditTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func (u *UserSQLiteRepo) AddOutboxEvent(ctx context.Context, event *entities.OutboxEvent) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddOutboxEvent, event.Type, event.Key, string(event.Payload), event.CreatedAt.UTC()).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add outbox event: %w", err)
+	}
+
+	return nil
+}
+
+// AcquireOutboxLease makes owner the only relay until until, unless another
+// relay's lease is still valid at now. It reports whether owner holds the
+// lease.
+func (u *UserSQLiteRepo) AcquireOutboxLease(ctx context.Context, owner string, now, until time.Time) (bool, error) {
+	var holder string
+	err := u.querier(ctx).QueryRowContext(ctx, AcquireOutboxLease, owner, now.UTC(), until.UTC()).Scan(&holder)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("sqlite acquire outbox lease: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetDueOutboxEvents returns up to limit undelivered events due at now, in
+// the order they were written.
+func (u *UserSQLiteRepo) GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEvent, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetDueOutboxEvents, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get due outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*entities.OutboxEvent, 0)
+	for rows.Next() {
+		event := &entities.OutboxEvent{}
+		var payload string
+		err := rows.Scan(&event.ID, &event.Type, &event.Key, &payload, &event.CreatedAt, &event.Attempts, &event.LastError, &event.NextAttemptAt)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get due outbox events: %w", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		event.CreatedAt = event.CreatedAt.UTC()
+		event.NextAttemptAt = event.NextAttemptAt.UTC()
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get due outbox events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (u *UserSQLiteRepo) MarkOutboxDelivered(ctx context.Context, eventID int, at time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, MarkOutboxDelivered, eventID, at.UTC()); err != nil {
+		return fmt.Errorf("sqlite mark outbox delivered: %w", err)
+	}
+
+	return nil
+}
+
+// DeferOutboxEvent records a failed delivery and holds the event, and every
+// later event with its key, back until until.
+func (u *UserSQLiteRepo) DeferOutboxEvent(ctx context.Context, eventID int, lastError string, until time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, DeferOutboxEvent, eventID, lastError, until.UTC()); err != nil {
+		return fmt.Errorf("sqlite defer outbox event: %w", err)
+	}
+
+	return nil
+}
+
+func scanWebhookEndpoint(scanner interface{ Scan(dest ...any) error }) (*entities.WebhookEndpoint, error) {
+	endpoint := &entities.WebhookEndpoint{}
+	var eventTypes string
+	err := scanner.Scan(&endpoint.ID, &endpoint.UserID, &endpoint.URL, &eventTypes, &endpoint.Secret, &endpoint.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.EventTypes = strings.Split(eventTypes, ",")
+
+	return endpoint, nil
+}
+
+func (u *UserSQLiteRepo) AddWebhookEndpoint(ctx context.Context, endpoint *entities.WebhookEndpoint) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddWebhookEndpoint, endpoint.UserID, endpoint.URL,
+		strings.Join(endpoint.EventTypes, ","), endpoint.Secret, endpoint.CreatedAt.UTC()).Scan(&endpoint.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add webhook endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) GetWebhookEndpoint(ctx context.Context, endpointID int) (*entities.WebhookEndpoint, error) {
+	endpoint, err := scanWebhookEndpoint(u.querier(ctx).QueryRowContext(ctx, GetWebhookEndpoint, endpointID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite get webhook endpoint: %w", utils.ErrNoWebhook)
+		}
+		return nil, fmt.Errorf("sqlite get webhook endpoint: %w", err)
+	}
+
+	return endpoint, nil
+}
+
+func (u *UserSQLiteRepo) GetWebhookEndpoints(ctx context.Context, userID int) ([]*entities.WebhookEndpoint, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetWebhookEndpoints, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]*entities.WebhookEndpoint, 0)
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get webhook endpoints: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get webhook endpoints: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+// DeleteWebhookEndpoint removes the endpoint with its deliveries and their
+// log.
+func (u *UserSQLiteRepo) DeleteWebhookEndpoint(ctx context.Context, endpointID int) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, DeleteWebhookEndpoint, endpointID); err != nil {
+		return fmt.Errorf("sqlite delete webhook endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func scanWebhookDelivery(scanner interface{ Scan(dest ...any) error }) (*entities.WebhookDelivery, error) {
+	delivery := &entities.WebhookDelivery{}
+	var payload string
+	var deliveredAt sql.NullTime
+	err := scanner.Scan(&delivery.ID, &delivery.EndpointID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts,
+		&delivery.LastError, &delivery.NextAttemptAt, &delivery.CreatedAt, &deliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return delivery, nil
+}
+
+func (u *UserSQLiteRepo) AddWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddWebhookDelivery, delivery.EndpointID, delivery.EventType, string(delivery.Payload),
+		delivery.Status, delivery.NextAttemptAt.UTC(), delivery.CreatedAt.UTC()).Scan(&delivery.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) GetWebhookDelivery(ctx context.Context, deliveryID int) (*entities.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(u.querier(ctx).QueryRowContext(ctx, GetWebhookDelivery, deliveryID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite get webhook delivery: %w", utils.ErrNoWebhookDelivery)
+		}
+		return nil, fmt.Errorf("sqlite get webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// GetWebhookDeliveries returns a page of the endpoint's deliveries with the
+// given status, or all of them for an empty status, newest first.
+func (u *UserSQLiteRepo) GetWebhookDeliveries(ctx context.Context, endpointID int, status string, limit, offset int) ([]*entities.WebhookDelivery, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetWebhookDeliveries, endpointID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*entities.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite get webhook deliveries: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (u *UserSQLiteRepo) CountWebhookDeliveries(ctx context.Context, endpointID int, status string) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountWebhookDeliveries, endpointID, status).Scan(&count); err != nil {
+		return 0, fmt.Errorf("sqlite count webhook deliveries: %w", err)
+	}
+
+	return count, nil
+}
+
+// ClaimDueWebhookDelivery returns the earliest pending delivery due at now
+// and holds it back until until, so no other worker sends it meanwhile.
+func (u *UserSQLiteRepo) ClaimDueWebhookDelivery(ctx context.Context, now, until time.Time) (*entities.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(u.querier(ctx).QueryRowContext(ctx, ClaimDueWebhookDelivery, now.UTC(), until.UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite claim due webhook delivery: %w", utils.ErrNoWebhookDelivery)
+		}
+		return nil, fmt.Errorf("sqlite claim due webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// UpdateWebhookDelivery stores the delivery's status, attempts, last error,
+// next attempt and delivery time.
+func (u *UserSQLiteRepo) UpdateWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	var deliveredAt any
+	if delivery.DeliveredAt != nil {
+		deliveredAt = delivery.DeliveredAt.UTC()
+	}
+
+	_, err := u.querier(ctx).ExecContext(ctx, UpdateWebhookDelivery, delivery.ID, delivery.Status, delivery.Attempts,
+		delivery.LastError, delivery.NextAttemptAt.UTC(), deliveredAt)
+	if err != nil {
+		return fmt.Errorf("sqlite update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserSQLiteRepo) AddWebhookAttempt(ctx context.Context, deliveryID int, attempt *entities.WebhookAttempt) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddWebhookAttempt, deliveryID, attempt.AttemptedAt.UTC(), attempt.StatusCode,
+		attempt.Error, attempt.DurationMS).Scan(&attempt.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add webhook attempt: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhookAttempts returns the delivery log of a delivery, oldest first.
+func (u *UserSQLiteRepo) GetWebhookAttempts(ctx context.Context, deliveryID int) ([]*entities.WebhookAttempt, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetWebhookAttempts, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make([]*entities.WebhookAttempt, 0)
+	for rows.Next() {
+		attempt := &entities.WebhookAttempt{}
+		if err := rows.Scan(&attempt.ID, &attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMS); err != nil {
+			return nil, fmt.Errorf("sqlite get webhook attempts: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get webhook attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+func (u *UserSQLiteRepo) AddUserEvent(ctx context.Context, event *entities.UserEvent) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddUserEvent, event.UserID, event.Type, string(event.Data), event.CreatedAt.UTC()).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add user event: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserEvents returns up to limit of the user's events after afterID,
+// oldest first.
+func (u *UserSQLiteRepo) GetUserEvents(ctx context.Context, userID, afterID, limit int) ([]*entities.UserEvent, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetUserEvents, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get user events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*entities.UserEvent, 0)
+	for rows.Next() {
+		event := &entities.UserEvent{}
+		var data string
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Type, &data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("sqlite get user events: %w", err)
+		}
+		event.Data = json.RawMessage(data)
+		event.CreatedAt = event.CreatedAt.UTC()
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get user events: %w", err)
+	}
+
+	return events, nil
+}
+
+// LastUserEventID returns the id of the user's latest event, or zero if
+// there is none.
+func (u *UserSQLiteRepo) LastUserEventID(ctx context.Context, userID int) (int, error) {
+	var id int
+	if err := u.querier(ctx).QueryRowContext(ctx, LastUserEventID, userID).Scan(&id); err != nil {
+		return 0, fmt.Errorf("sqlite last user event id: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeleteUserEvents deletes the events created before before and reports
+// how many there were.
+func (u *UserSQLiteRepo) DeleteUserEvents(ctx context.Context, before time.Time) (int, error) {
+	result, err := u.querier(ctx).ExecContext(ctx, DeleteUserEvents, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("sqlite delete user events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("sqlite delete user events: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+func (u *UserSQLiteRepo) AddNotification(ctx context.Context, userID int, notification *entities.Notification) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddNotification, userID, notification.Kind, notification.Text, notification.CreatedAt.UTC()).Scan(&notification.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add notification: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotifications returns a page of the user's notifications, newest
+// first, optionally only the unread ones.
+func (u *UserSQLiteRepo) GetNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*entities.Notification, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetNotifications, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*entities.Notification, 0)
+	for rows.Next() {
+		notification := &entities.Notification{}
+		var readAt sql.NullTime
+		if err := rows.Scan(&notification.ID, &notification.Kind, &notification.Text, &notification.CreatedAt, &readAt); err != nil {
+			return nil, fmt.Errorf("sqlite get notifications: %w", err)
+		}
+		notification.CreatedAt = notification.CreatedAt.UTC()
+		if readAt.Valid {
+			at := readAt.Time.UTC()
+			notification.ReadAt = &at
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (u *UserSQLiteRepo) CountNotifications(ctx context.Context, userID int, unreadOnly bool) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountNotifications, userID, unreadOnly).Scan(&count); err != nil {
+		return 0, fmt.Errorf("sqlite count notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+// MarkNotificationRead marks one of the user's notifications read at at,
+// unless it already was.
+func (u *UserSQLiteRepo) MarkNotificationRead(ctx context.Context, userID, notificationID int, at time.Time) error {
+	result, err := u.querier(ctx).ExecContext(ctx, MarkNotificationRead, userID, notificationID, at.UTC())
+	if err != nil {
+		return fmt.Errorf("sqlite mark notification read: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite mark notification read: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("sqlite mark notification read: %w", utils.ErrNoNotification)
+	}
+
+	return nil
+}
+
+// MarkAllNotificationsRead marks the user's unread notifications read and
+// reports how many there were.
+func (u *UserSQLiteRepo) MarkAllNotificationsRead(ctx context.Context, userID int, at time.Time) (int, error) {
+	result, err := u.querier(ctx).ExecContext(ctx, MarkAllNotificationsRead, userID, at.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("sqlite mark all notifications read: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("sqlite mark all notifications read: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// GetNotificationPreferences returns the kinds the user turned on or off.
+func (u *UserSQLiteRepo) GetNotificationPreferences(ctx context.Context, userID int) (entities.NotificationPreferences, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetNotificationPreferences, userID)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	preferences := make(entities.NotificationPreferences)
+	for rows.Next() {
+		var kind string
+		var enabled bool
+		if err := rows.Scan(&kind, &enabled); err != nil {
+			return nil, fmt.Errorf("sqlite get notification preferences: %w", err)
+		}
+		preferences[kind] = enabled
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get notification preferences: %w", err)
+	}
+
+	return preferences, nil
+}
+
+func (u *UserSQLiteRepo) SetNotificationPreference(ctx context.Context, userID int, kind string, enabled bool) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, SetNotificationPreference, userID, kind, enabled); err != nil {
+		return fmt.Errorf("sqlite set notification preference: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotificationsSince returns up to limit of the user's notifications
+// created after since, oldest first.
+func (u *UserSQLiteRepo) GetNotificationsSince(ctx context.Context, userID int, since time.Time, limit int) ([]*entities.Notification, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetNotificationsSince, userID, since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get notifications since: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*entities.Notification, 0)
+	for rows.Next() {
+		notification := &entities.Notification{}
+		var readAt sql.NullTime
+		if err := rows.Scan(&notification.ID, &notification.Kind, &notification.Text, &notification.CreatedAt, &readAt); err != nil {
+			return nil, fmt.Errorf("sqlite get notifications since: %w", err)
+		}
+		notification.CreatedAt = notification.CreatedAt.UTC()
+		if readAt.Valid {
+			at := readAt.Time.UTC()
+			notification.ReadAt = &at
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get notifications since: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (u *UserSQLiteRepo) GetEmailSettings(ctx context.Context, userID int) (*entities.EmailSettings, error) {
+	settings := &entities.EmailSettings{}
+	err := u.querier(ctx).QueryRowContext(ctx, GetEmailSettings, userID).Scan(&settings.Address, &settings.Mode, &settings.DigestSentAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite get email settings: %w", utils.ErrNoEmailSettings)
+		}
+		return nil, fmt.Errorf("sqlite get email settings: %w", err)
+	}
+	settings.DigestSentAt = settings.DigestSentAt.UTC()
+
+	return settings, nil
+}
+
+func (u *UserSQLiteRepo) SetEmailSettings(ctx context.Context, userID int, settings *entities.EmailSettings) error {
+	_, err := u.querier(ctx).ExecContext(ctx, SetEmailSettings, userID, settings.Address, settings.Mode, settings.DigestSentAt.UTC())
+	if err != nil {
+		return fmt.Errorf("sqlite set email settings: %w", err)
+	}
+
+	return nil
+}
+
+// GetDueEmailDigests returns up to limit digest mode users whose last
+// digest went out before before.
+func (u *UserSQLiteRepo) GetDueEmailDigests(ctx context.Context, before time.Time, limit int) ([]*entities.EmailDigest, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetDueEmailDigests, before.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite get due email digests: %w", err)
+	}
+	defer rows.Close()
+
+	digests := make([]*entities.EmailDigest, 0)
+	for rows.Next() {
+		digest := &entities.EmailDigest{}
+		if err := rows.Scan(&digest.UserID, &digest.Username, &digest.Address, &digest.Since); err != nil {
+			return nil, fmt.Errorf("sqlite get due email digests: %w", err)
+		}
+		digest.Since = digest.Since.UTC()
+		digests = append(digests, digest)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite get due email digests: %w", err)
+	}
+
+	return digests, nil
+}
+
+func (u *UserSQLiteRepo) MarkEmailDigestSent(ctx context.Context, userID int, at time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, MarkEmailDigestSent, userID, at.UTC()); err != nil {
+		return fmt.Errorf("sqlite mark email digest sent: %w", err)
+	}
+
+	return nil
+}
+
+func scanEmail(scanner interface{ Scan(dest ...any) error }) (*entities.Email, error) {
+	email := &entities.Email{}
+	var sentAt sql.NullTime
+	err := scanner.Scan(&email.ID, &email.UserID, &email.To, &email.Subject, &email.Text, &email.HTML, &email.Status,
+		&email.Attempts, &email.LastError, &email.NextAttemptAt, &email.CreatedAt, &sentAt)
+	if err != nil {
+		return nil, err
+	}
+	email.NextAttemptAt = email.NextAttemptAt.UTC()
+	email.CreatedAt = email.CreatedAt.UTC()
+	if sentAt.Valid {
+		at := sentAt.Time.UTC()
+		email.SentAt = &at
+	}
+
+	return email, nil
+}
+
+func (u *UserSQLiteRepo) AddEmail(ctx context.Context, email *entities.Email) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddEmail, email.UserID, email.To, email.Subject, email.Text, email.HTML,
+		email.Status, email.NextAttemptAt.UTC(), email.CreatedAt.UTC()).Scan(&email.ID)
+	if err != nil {
+		return fmt.Errorf("sqlite add email: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDueEmail returns the earliest pending email that is due and holds it
+// back from other workers until until.
+func (u *UserSQLiteRepo) ClaimDueEmail(ctx context.Context, now, until time.Time) (*entities.Email, error) {
+	email, err := scanEmail(u.querier(ctx).QueryRowContext(ctx, ClaimDueEmail, now.UTC(), until.UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("sqlite claim due email: %w", utils.ErrNoEmail)
+		}
+		return nil, fmt.Errorf("sqlite claim due email: %w", err)
+	}
+
+	return email, nil
+}
+
+// UpdateEmail stores the email's status, attempts, last error, next attempt
+// and send time.
+func (u *UserSQLiteRepo) UpdateEmail(ctx context.Context, email *entities.Email) error {
+	var sentAt any
+	if email.SentAt != nil {
+		sentAt = email.SentAt.UTC()
+	}
+
+	_, err := u.querier(ctx).ExecContext(ctx, UpdateEmail, email.ID, email.Status, email.Attempts,
+		email.LastError, email.NextAttemptAt.UTC(), sentAt)
+	if err != nil {
+		return fmt.Errorf("sqlite update email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/audit"
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/migrations"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/repotest"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRepo(t *testing.T) *UserSQLiteRepo {
+	db, err := Open(filepath.Join(t.TempDir(), "itemstore.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	list, err := migrations.SQLite()
+	require.NoError(t, err)
+	_, err = migrations.NewMigrator(db, migrations.SQLiteDialect, list).Up(context.Background())
+	require.NoError(t, err)
+
+	return NewUserSQLiteRepo(db)
+}
+
+func TestUserSQLiteRepoContract(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) service.UserRepo {
+		return newTestRepo(t)
+	})
+}
+
+func TestSQLiteTxManager(t *testing.T) {
+	repotest.RunTxManager(t, func(t *testing.T) (service.UserRepo, service.TxManager) {
+		repo := newTestRepo(t)
+		return repo, NewTxManager(repo.DB)
+	})
+}
+
+func TestConcurrentBuyItem(t *testing.T) {
+	repo := newTestRepo(t)
+	_, userID := repotest.CreateUser(t, repo, "buyer")
+
+	penID, err := repo.GetItemID(context.Background(), "pen")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, rejected := 0, 0
+	for i := 0; i < 120; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.BuyItem(context.Background(), userID, penID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			} else if errors.Is(err, utils.ErrNotEnoughBalance) {
+				rejected++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, succeeded)
+	assert.Equal(t, 20, rejected)
+
+	coins, err := repo.GetCoinsInfo(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Zero(t, coins)
+}
+
+func TestSendCoinToUnknownUser(t *testing.T) {
+	repo := newTestRepo(t)
+	_, userID := repotest.CreateUser(t, repo, "sender")
+
+	err := repo.SendCoin(context.Background(), userID, userID+1000, 10, "", "")
+	assert.True(t, errors.Is(err, utils.ErrNoUser))
+
+	coins, err := repo.GetCoinsInfo(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, repotest.InitBalance, coins)
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	event := &entities.AuditEvent{Action: entities.AuditLoginSucceeded, Actor: "alice", CreatedAt: time.Now()}
+	event.Hash = audit.Hash(event)
+	require.NoError(t, repo.AddAuditEvent(ctx, event))
+
+	_, err := repo.DB.ExecContext(ctx, "UPDATE audit_log SET actor = 'mallory' WHERE id = ?;", event.ID)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = repo.DB.ExecContext(ctx, "DELETE FROM audit_log WHERE id = ?;", event.ID)
+	assert.ErrorContains(t, err, "append-only")
+
+	last, err := repo.LastAuditEvent(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", last.Actor)
+}
@@ -0,0 +1,121 @@
+// Package sqltx runs database/sql transactions on behalf of the service
+// layer. The open transaction travels in the context, so repository methods
+// called with that context join it instead of using the pool.
+package sqltx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Querier is the part of *sql.DB and *sql.Tx the repositories use.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// From returns the transaction carried by ctx, or db when there is none.
+func From(ctx context.Context, db *sql.DB) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// Run calls fn with the transaction carried by ctx. Without one it begins a
+// new transaction on db and commits it when fn succeeds, so a repository
+// method stays atomic whether or not the caller opened a transaction.
+func Run(ctx context.Context, db *sql.DB, fn func(q Querier) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = 20 * time.Millisecond
+)
+
+// Manager implements service.TxManager for a database/sql database.
+type Manager struct {
+	DB *sql.DB
+	// IsRetryable reports whether a transaction that failed with err can be
+	// run again from the start, e.g. on a serialization failure or a
+	// deadlock. Nil means nothing is retried.
+	IsRetryable func(err error) bool
+	MaxAttempts int
+	// Backoff is the pause before the first retry; it grows linearly.
+	Backoff time.Duration
+}
+
+func NewManager(db *sql.DB, isRetryable func(err error) bool) *Manager {
+	return &Manager{
+		DB:          db,
+		IsRetryable: isRetryable,
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+	}
+}
+
+// WithinTx runs fn in a transaction opened with opts and commits it when fn
+// returns nil. If ctx already carries a transaction fn simply joins it: the
+// outermost WithinTx owns commit, rollback and retries.
+func (m *Manager) WithinTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.run(ctx, opts, fn)
+		if err == nil || m.IsRetryable == nil || !m.IsRetryable(err) || attempt >= m.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("retry transaction: %w", ctx.Err())
+		case <-time.After(time.Duration(attempt) * m.Backoff):
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := m.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(WithTx(ctx, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,153 @@
+package sqltx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var (
+	InternalTestError  = errors.New("internal error")
+	RetryableTestError = errors.New("serialization failure")
+)
+
+func isRetryable(err error) bool {
+	return errors.Is(err, RetryableTestError)
+}
+
+func TestWithinTx(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	manager := NewManager(db, isRetryable)
+	manager.Backoff = 0
+	ctx := context.Background()
+
+	t.Run("commit", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := manager.WithinTx(ctx, nil, func(ctx context.Context) error {
+			_, ok := TxFromContext(ctx)
+			assert.True(t, ok)
+
+			_, err := From(ctx, db).ExecContext(ctx, "UPDATE users SET balance = 0;")
+			return err
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := manager.WithinTx(ctx, nil, func(ctx context.Context) error {
+			return InternalTestError
+		})
+		assert.Equal(t, InternalTestError, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		calls := 0
+		err := manager.WithinTx(ctx, nil, func(ctx context.Context) error {
+			calls++
+			if calls == 1 {
+				return RetryableTestError
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retry gives up", func(t *testing.T) {
+		for i := 0; i < DefaultMaxAttempts; i++ {
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+		}
+
+		calls := 0
+		err := manager.WithinTx(ctx, nil, func(ctx context.Context) error {
+			calls++
+			return RetryableTestError
+		})
+		assert.Equal(t, RetryableTestError, err)
+		assert.Equal(t, DefaultMaxAttempts, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nested joins outer", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+
+		err := manager.WithinTx(ctx, nil, func(outer context.Context) error {
+			outerTx, _ := TxFromContext(outer)
+			return manager.WithinTx(outer, nil, func(inner context.Context) error {
+				innerTx, _ := TxFromContext(inner)
+				assert.Same(t, outerTx, innerTx)
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("begin error", func(t *testing.T) {
+		mock.ExpectBegin().WillReturnError(InternalTestError)
+
+		err := manager.WithinTx(ctx, &sql.TxOptions{}, func(ctx context.Context) error {
+			return nil
+		})
+		assert.Equal(t, InternalTestError, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	t.Run("own transaction", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := Run(context.Background(), db, func(q Querier) error {
+			_, err := q.ExecContext(context.Background(), "UPDATE users SET balance = 0;")
+			return err
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("joins transaction from context", func(t *testing.T) {
+		mock.ExpectBegin()
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+
+		err = Run(WithTx(context.Background(), tx), db, func(q Querier) error {
+			assert.Same(t, tx, q)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		mock.ExpectRollback()
+		assert.NoError(t, tx.Rollback())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
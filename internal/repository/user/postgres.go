@@ -1,11 +1,16 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqltx"
 	"github.com/KonstantinGalanin/itemStore/internal/utils"
 )
 
@@ -23,20 +28,60 @@ func NewUserPostgresRepo(db *sql.DB) *UserPostgresRepo {
 	}
 }
 
-func (u *UserPostgresRepo) GetItemID(itemName string) (int, error) {
+// querier returns the transaction carried by ctx, or the pool.
+func (u *UserPostgresRepo) querier(ctx context.Context) sqltx.Querier {
+	return sqltx.From(ctx, u.DB)
+}
+
+func (u *UserPostgresRepo) GetItemID(ctx context.Context, itemName string) (int, error) {
 	var itemID int
-	row := u.DB.QueryRow(GetItemID, itemName)
+	row := u.querier(ctx).QueryRowContext(ctx, GetItemID, itemName)
 	err := row.Scan(&itemID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("get item id error: %w: %w", utils.ErrNoItem, err)
+		}
 		return 0, fmt.Errorf("get item id error: %w", err)
 	}
 
 	return itemID, nil
 }
 
-func (u *UserPostgresRepo) GetUserID(username string) (int, error) {
+// GetCatalog lists every item the shop sells, by name.
+func (u *UserPostgresRepo) GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetCatalog)
+	if err != nil {
+		return nil, fmt.Errorf("get catalog error: %w", err)
+	}
+	defer rows.Close()
+
+	catalog := make([]*entities.CatalogItem, 0)
+	for rows.Next() {
+		item := &entities.CatalogItem{}
+		if err := rows.Scan(&item.Name, &item.Price); err != nil {
+			return nil, fmt.Errorf("get catalog error: %w", err)
+		}
+		catalog = append(catalog, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get catalog error: %w", err)
+	}
+
+	return catalog, nil
+}
+
+// SetItemPrice sets an item's price, adding it to the catalog if it is new.
+func (u *UserPostgresRepo) SetItemPrice(ctx context.Context, itemName string, price int) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, SetItemPrice, itemName, price); err != nil {
+		return fmt.Errorf("set item price error: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) GetUserID(ctx context.Context, username string) (int, error) {
 	var userID int
-	row := u.DB.QueryRow(GetUserID, username)
+	row := u.querier(ctx).QueryRowContext(ctx, GetUserID, username)
 	err := row.Scan(&userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -47,42 +92,47 @@ func (u *UserPostgresRepo) GetUserID(username string) (int, error) {
 	return userID, nil
 }
 
-func (u *UserPostgresRepo) BuyItem(userID, itemID int) error {
-	tx, err := u.DB.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+func (u *UserPostgresRepo) BuyItem(ctx context.Context, userID, itemID int) error {
+	return u.buy(ctx, userID, itemID, sql.NullInt64{}, "")
+}
 
-	var balance int
-	err = tx.QueryRow(GetBalance, userID).Scan(&balance)
-	if err != nil {
-		return fmt.Errorf("get balance error: %w", err)
-	}
+// GiftItem is BuyItem where the unit goes to recipientID's inventory.
+func (u *UserPostgresRepo) GiftItem(ctx context.Context, userID, recipientID, itemID int, message string) error {
+	return u.buy(ctx, userID, itemID, sql.NullInt64{Int64: int64(recipientID), Valid: true}, message)
+}
 
-	var price int
-	err = tx.QueryRow(GetPrice, itemID).Scan(&price)
-	if err != nil {
-		return fmt.Errorf("get price error: %w", err)
-	}
+func (u *UserPostgresRepo) buy(ctx context.Context, userID, itemID int, recipientID sql.NullInt64, message string) error {
+	return sqltx.Run(ctx, u.DB, func(tx sqltx.Querier) error {
+		var balance int
+		err := tx.QueryRowContext(ctx, GetBalance, userID).Scan(&balance)
+		if err != nil {
+			return fmt.Errorf("get balance error: %w", err)
+		}
 
-	if balance < price {
-		return fmt.Errorf("buy item error: %w", utils.ErrNotEnoughBalance)
-	}
+		var price int
+		err = tx.QueryRowContext(ctx, GetPrice, itemID).Scan(&price)
+		if err != nil {
+			return fmt.Errorf("get price error: %w", err)
+		}
 
-	if _, err = tx.Exec(ReduceCoins, price, userID); err != nil {
-		return fmt.Errorf("buy item error: %w", err)
-	}
+		if balance < price {
+			return fmt.Errorf("buy item error: %w", utils.ErrNotEnoughBalance)
+		}
 
-	if _, err := tx.Exec(AddToInventory, userID, itemID); err != nil {
-		return fmt.Errorf("add to inventory error: %w", err)
-	}
+		if _, err = tx.ExecContext(ctx, ReduceCoins, price, userID); err != nil {
+			return fmt.Errorf("buy item error: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, AddOrder, userID, itemID, price, recipientID, message); err != nil {
+			return fmt.Errorf("add order error: %w", err)
+		}
 
-	return tx.Commit()
+		return nil
+	})
 }
 
-func (u *UserPostgresRepo) GetInventoryInfo(userID int) ([]*entities.Item, error) {
-	rows, err := u.DB.Query(GetInventory, userID)
+func (u *UserPostgresRepo) GetInventoryInfo(ctx context.Context, userID int) ([]*entities.Item, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetInventory, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -110,9 +160,9 @@ func (u *UserPostgresRepo) GetInventoryInfo(userID int) ([]*entities.Item, error
 	return inventory, nil
 }
 
-func (u *UserPostgresRepo) GetCoinsInfo(userID int) (int, error) {
+func (u *UserPostgresRepo) GetCoinsInfo(ctx context.Context, userID int) (int, error) {
 	var coins int
-	row := u.DB.QueryRow(GetCoins, userID)
+	row := u.querier(ctx).QueryRowContext(ctx, GetCoins, userID)
 	if err := row.Scan(&coins); err != nil {
 		return 0, fmt.Errorf("get coin info error: %w", err)
 	}
@@ -120,23 +170,25 @@ func (u *UserPostgresRepo) GetCoinsInfo(userID int) (int, error) {
 	return coins, nil
 }
 
-func (u *UserPostgresRepo) GetReceiveInfo(userID int) ([]*entities.ReceiveOperation, error) {
-	rows, err := u.DB.Query(GetReceiveInfo, userID)
+func (u *UserPostgresRepo) GetReceiveInfo(ctx context.Context, userID int) ([]*entities.ReceiveOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetReceiveInfo, userID)
 	if err != nil {
 		return nil, fmt.Errorf("get receive info: %w", err)
 	}
 
 	receives := make([]*entities.ReceiveOperation, 0)
 	for rows.Next() {
-		var fromUser string
+		var fromUser, memo, category string
 		var amount int
-		if err := rows.Scan(&fromUser, &amount); err != nil {
+		if err := rows.Scan(&fromUser, &amount, &memo, &category); err != nil {
 			return nil, fmt.Errorf("get receive info: %w", err) 
 		}
 
 		receives = append(receives, &entities.ReceiveOperation{
 			FromUser: fromUser,
 			Amount: amount,
+			Memo: memo,
+			Category: category,
 		})
 	}
 
@@ -148,23 +200,25 @@ func (u *UserPostgresRepo) GetReceiveInfo(userID int) ([]*entities.ReceiveOperat
 }
 
 
-func (u *UserPostgresRepo) GetSentInfo(userID int) ([]*entities.SentOperation, error) {
-	rows, err := u.DB.Query(GetSentInfo, userID)
+func (u *UserPostgresRepo) GetSentInfo(ctx context.Context, userID int) ([]*entities.SentOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSentInfo, userID)
 	if err != nil {
 		return nil, fmt.Errorf("get sent info: %w", err)
 	}
 
 	receives := make([]*entities.SentOperation, 0)
 	for rows.Next() {
-		var toUser string
+		var toUser, memo, category string
 		var amount int
-		if err := rows.Scan(&toUser, &amount); err != nil {
+		if err := rows.Scan(&toUser, &amount, &memo, &category); err != nil {
 			return nil, fmt.Errorf("get sent info: %w", err) 
 		}
 
 		receives = append(receives, &entities.SentOperation{
 			ToUser: toUser,
 			Amount: amount,
+			Memo: memo,
+			Category: category,
 		})
 	}
 
@@ -175,11 +229,11 @@ func (u *UserPostgresRepo) GetSentInfo(userID int) ([]*entities.SentOperation, e
 	return receives, nil
 }
 
-func (u *UserPostgresRepo) GetUserByUsername(username string) (*entities.User, error) {
+func (u *UserPostgresRepo) GetUserByUsername(ctx context.Context, username string) (*entities.User, error) {
 	user := &entities.User{}
 
-	row := u.DB.QueryRow(GetUser, username)
-	err := row.Scan(&user.ID, &user.Username, &user.Password)
+	row := u.querier(ctx).QueryRowContext(ctx, GetUser, username)
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Role)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("postgres get user: %w", utils.ErrNoUser)
@@ -190,16 +244,18 @@ func (u *UserPostgresRepo) GetUserByUsername(username string) (*entities.User, e
 	return user, nil
 }
 
-func (u *UserPostgresRepo) Auth(username, password string) (*entities.User, error) {
-	user, err := u.GetUserByUsername(username)
+func (u *UserPostgresRepo) Auth(ctx context.Context, username, password string) (*entities.User, error) {
+	user, err := u.GetUserByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, utils.ErrNoUser) {
-			_, err := u.DB.Exec(CreateUser, username, password, InitBalance)
+			_, err := u.querier(ctx).ExecContext(ctx, CreateUser, username, password, InitBalance)
 			if err != nil {
 				return nil, fmt.Errorf("postgres auth: %w", err)
 			}
 			user = &entities.User{
-				Username: username,
+				Username:   username,
+				Role:       entities.RoleUser,
+				Registered: true,
 			}
 
 			return user, nil
@@ -214,13 +270,14 @@ func (u *UserPostgresRepo) Auth(username, password string) (*entities.User, erro
 
 	return &entities.User{
 		Username: username,
+		Role:     user.Role,
 	}, nil
 }
 
-func (u *UserPostgresRepo) GetUserByID(userID int) (*entities.User, error) {
+func (u *UserPostgresRepo) GetUserByID(ctx context.Context, userID int) (*entities.User, error) {
 	user := &entities.User{}
 
-	row := u.DB.QueryRow(GetUserByID, userID)
+	row := u.querier(ctx).QueryRowContext(ctx, GetUserByID, userID)
 	err := row.Scan(&user.ID, &user.Username, &user.Coins)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -232,37 +289,1631 @@ func (u *UserPostgresRepo) GetUserByID(userID int) (*entities.User, error) {
 	return user, nil
 }
 
-func (u *UserPostgresRepo) SendCoin(fromUserID, toUserID int, amount int) error {
-	tx, err := u.DB.Begin()
+func (u *UserPostgresRepo) SendCoin(ctx context.Context, fromUserID, toUserID int, amount int, memo, category string) error {
+	return sqltx.Run(ctx, u.DB, func(tx sqltx.Querier) error {
+		// Both rows are locked in id order so that two opposite transfers
+		// cannot deadlock each other.
+		rows, err := tx.QueryContext(ctx, LockUsers, fromUserID, toUserID)
+		if err != nil {
+			return fmt.Errorf("send coin error: %w", err)
+		}
+		defer rows.Close()
+
+		balances := make(map[int]int, 2)
+		for rows.Next() {
+			var id, balance int
+			if err := rows.Scan(&id, &balance); err != nil {
+				return fmt.Errorf("send coin error: %w", err)
+			}
+			balances[id] = balance
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("send coin error: %w", err)
+		}
+
+		fromBalance, ok := balances[fromUserID]
+		if !ok {
+			return fmt.Errorf("send coin error: %w", utils.ErrNoUser)
+		}
+		if _, ok := balances[toUserID]; !ok {
+			return fmt.Errorf("send coin error: %w", utils.ErrNoUser)
+		}
+		if fromBalance < amount {
+			return fmt.Errorf("send coin error: %w", utils.ErrNotEnoughBalance)
+		}
+
+		_, err = tx.ExecContext(ctx, ReduceCoins, amount, fromUserID)
+		if err != nil {
+			return fmt.Errorf("send coin error: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, AddCoins, amount, toUserID)
+		if err != nil {
+			return fmt.Errorf("send coin error: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, AddExchangeRecord, fromUserID, toUserID, amount, memo, category)
+		if err != nil {
+			return fmt.Errorf("send coin error: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// LockLoginAttempts creates the username's row if it is missing, so there
+// is always a row to lock, and holds it until the transaction ends.
+func (u *UserPostgresRepo) LockLoginAttempts(ctx context.Context, username string) (*entities.LoginAttempts, error) {
+	attempts := &entities.LoginAttempts{
+		Username: username,
+	}
+
+	var lastFailedAt, blockedUntil sql.NullTime
+	err := u.querier(ctx).QueryRowContext(ctx, LockLoginAttempts, username).Scan(&attempts.FailedCount, &lastFailedAt, &blockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("postgres lock login attempts: %w", err)
+	}
+	attempts.LastFailedAt = lastFailedAt.Time
+	attempts.BlockedUntil = blockedUntil.Time
+
+	return attempts, nil
+}
+
+func (u *UserPostgresRepo) RegisterFailedLogin(ctx context.Context, username string, at, since time.Time) (int, error) {
+	var failed int
+	if err := u.querier(ctx).QueryRowContext(ctx, RegisterFailedLogin, username, at, since).Scan(&failed); err != nil {
+		return 0, fmt.Errorf("postgres register failed login: %w", err)
+	}
+
+	return failed, nil
+}
+
+func (u *UserPostgresRepo) BlockLogin(ctx context.Context, username string, until time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, BlockLogin, username, until); err != nil {
+		return fmt.Errorf("postgres block login: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) ResetLoginAttempts(ctx context.Context, username string) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, ResetLoginAttempts, username); err != nil {
+		return fmt.Errorf("postgres reset login attempts: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) SetUserRole(ctx context.Context, username, role string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, SetUserRole, username, role)
+	if err != nil {
+		return fmt.Errorf("postgres set user role: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("postgres set user role: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("postgres set user role: %w", utils.ErrNoUser)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) AddLockoutEvent(ctx context.Context, event *entities.LockoutEvent) error {
+	var lockedUntil sql.NullTime
+	if !event.LockedUntil.IsZero() {
+		lockedUntil = sql.NullTime{Time: event.LockedUntil, Valid: true}
+	}
+
+	_, err := u.querier(ctx).ExecContext(ctx, AddLockoutEvent, event.Username, event.Action, event.Actor, event.FailedCount, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("postgres add lockout event: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) GetRefundableOrders(ctx context.Context, userID, itemID int) ([]*entities.Order, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetRefundableOrders, userID, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get refundable orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]*entities.Order, 0)
+	for rows.Next() {
+		order := &entities.Order{}
+		if err := rows.Scan(&order.ID, &order.ItemType, &order.Quantity, &order.UnitPrice, &order.Refunded, &order.CreatedAt); err != nil {
+			return nil, fmt.Errorf("postgres get refundable orders: %w", err)
+		}
+		order.Total = order.Quantity * order.UnitPrice
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get refundable orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// AddRefund records the refund, which takes the units out of the inventory,
+// and credits its amount. It fails with utils.ErrNotEnoughItems if the user no
+// longer holds that many units.
+func (u *UserPostgresRepo) AddRefund(ctx context.Context, refund *entities.Refund) error {
+	return sqltx.Run(ctx, u.DB, func(tx sqltx.Querier) error {
+		var held int
+		if err := tx.QueryRowContext(ctx, GetItemQuantity, refund.UserID, refund.ItemID).Scan(&held); err != nil {
+			return fmt.Errorf("postgres add refund: %w", err)
+		}
+		if held < refund.Quantity {
+			return fmt.Errorf("postgres add refund: %w", utils.ErrNotEnoughItems)
+		}
+
+		if _, err := tx.ExecContext(ctx, AddCoins, refund.Amount, refund.UserID); err != nil {
+			return fmt.Errorf("postgres add refund: %w", err)
+		}
+
+		_, err := tx.ExecContext(ctx, AddRefund, refund.OrderID, refund.UserID, refund.Quantity, refund.Amount, refund.Actor)
+		if err != nil {
+			return fmt.Errorf("postgres add refund: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserPostgresRepo) GetRefundInfo(ctx context.Context, userID int) ([]*entities.RefundOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetRefundInfo, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get refund info: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := make([]*entities.RefundOperation, 0)
+	for rows.Next() {
+		op := &entities.RefundOperation{}
+		if err := rows.Scan(&op.Item, &op.Quantity, &op.Amount, &op.RefundedBy); err != nil {
+			return nil, fmt.Errorf("postgres get refund info: %w", err)
+		}
+		refunds = append(refunds, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get refund info: %w", err)
+	}
+
+	return refunds, nil
+}
+
+// GetOrders returns a page of the user's orders, newest first. An empty
+// itemName matches every item.
+func (u *UserPostgresRepo) GetOrders(ctx context.Context, userID int, itemName string, limit, offset int) ([]*entities.Order, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetOrders, userID, itemName, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]*entities.Order, 0)
+	for rows.Next() {
+		order := &entities.Order{}
+		if err := rows.Scan(&order.ID, &order.ItemType, &order.Quantity, &order.UnitPrice, &order.Refunded, &order.GiftTo, &order.Message, &order.CreatedAt); err != nil {
+			return nil, fmt.Errorf("postgres get orders: %w", err)
+		}
+		order.Total = order.Quantity * order.UnitPrice
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+func (u *UserPostgresRepo) CountOrders(ctx context.Context, userID int, itemName string) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountOrders, userID, itemName).Scan(&count); err != nil {
+		return 0, fmt.Errorf("postgres count orders: %w", err)
+	}
+
+	return count, nil
+}
+
+func (u *UserPostgresRepo) GetSentGifts(ctx context.Context, userID int) ([]*entities.SentGift, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSentGifts, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get sent gifts: %w", err)
+	}
+	defer rows.Close()
+
+	gifts := make([]*entities.SentGift, 0)
+	for rows.Next() {
+		gift := &entities.SentGift{}
+		if err := rows.Scan(&gift.ToUser, &gift.Item, &gift.Message); err != nil {
+			return nil, fmt.Errorf("postgres get sent gifts: %w", err)
+		}
+		gifts = append(gifts, gift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get sent gifts: %w", err)
+	}
+
+	return gifts, nil
+}
+
+func (u *UserPostgresRepo) GetReceivedGifts(ctx context.Context, userID int) ([]*entities.ReceivedGift, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetReceivedGifts, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get received gifts: %w", err)
+	}
+	defer rows.Close()
+
+	gifts := make([]*entities.ReceivedGift, 0)
+	for rows.Next() {
+		gift := &entities.ReceivedGift{}
+		if err := rows.Scan(&gift.FromUser, &gift.Item, &gift.Message); err != nil {
+			return nil, fmt.Errorf("postgres get received gifts: %w", err)
+		}
+		gifts = append(gifts, gift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get received gifts: %w", err)
+	}
+
+	return gifts, nil
+}
+
+func (u *UserPostgresRepo) TransferItem(ctx context.Context, fromUserID, toUserID, itemID, quantity int) error {
+	return sqltx.Run(ctx, u.DB, func(tx sqltx.Querier) error {
+		// Locking the sender serializes concurrent transfers of the same
+		// units; the recipient row is locked only to check it exists.
+		rows, err := tx.QueryContext(ctx, LockUsers, fromUserID, toUserID)
+		if err != nil {
+			return fmt.Errorf("postgres transfer item: %w", err)
+		}
+		defer rows.Close()
+
+		found := 0
+		for rows.Next() {
+			var id, balance int
+			if err := rows.Scan(&id, &balance); err != nil {
+				return fmt.Errorf("postgres transfer item: %w", err)
+			}
+			found++
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("postgres transfer item: %w", err)
+		}
+		if found != 2 {
+			return fmt.Errorf("postgres transfer item: %w", utils.ErrNoUser)
+		}
+
+		var held int
+		if err := tx.QueryRowContext(ctx, GetItemQuantity, fromUserID, itemID).Scan(&held); err != nil {
+			return fmt.Errorf("postgres transfer item: %w", err)
+		}
+		if held < quantity {
+			return fmt.Errorf("postgres transfer item: %w", utils.ErrNotEnoughItems)
+		}
+
+		if _, err := tx.ExecContext(ctx, AddItemTransfer, fromUserID, toUserID, itemID, quantity); err != nil {
+			return fmt.Errorf("postgres transfer item: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserPostgresRepo) GetSentItems(ctx context.Context, userID int) ([]*entities.SentItemOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSentItems, userID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("postgres get sent items: %w", err)
+	}
+	defer rows.Close()
+
+	operations := make([]*entities.SentItemOperation, 0)
+	for rows.Next() {
+		op := &entities.SentItemOperation{}
+		if err := rows.Scan(&op.ToUser, &op.Item, &op.Quantity); err != nil {
+			return nil, fmt.Errorf("postgres get sent items: %w", err)
+		}
+		operations = append(operations, op)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get sent items: %w", err)
 	}
-	defer tx.Rollback()
 
-	fromUser, err := u.GetUserByID(fromUserID)
+	return operations, nil
+}
+
+func (u *UserPostgresRepo) GetReceivedItems(ctx context.Context, userID int) ([]*entities.ReceivedItemOperation, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetReceivedItems, userID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("postgres get received items: %w", err)
+	}
+	defer rows.Close()
+
+	operations := make([]*entities.ReceivedItemOperation, 0)
+	for rows.Next() {
+		op := &entities.ReceivedItemOperation{}
+		if err := rows.Scan(&op.FromUser, &op.Item, &op.Quantity); err != nil {
+			return nil, fmt.Errorf("postgres get received items: %w", err)
+		}
+		operations = append(operations, op)
 	}
-	if fromUser.Coins < amount {
-		return fmt.Errorf("send coin error: %w", utils.ErrNotEnoughBalance)
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get received items: %w", err)
+	}
+
+	return operations, nil
+}
+
+func (u *UserPostgresRepo) CreateListing(ctx context.Context, sellerID, itemID, quantity, price int, expiresAt time.Time) (int, error) {
+	var listingID int
+	err := sqltx.Run(ctx, u.DB, func(tx sqltx.Querier) error {
+		// The seller row lock keeps two listings, or a listing and a
+		// transfer, from escrowing the same units.
+		var balance int
+		if err := tx.QueryRowContext(ctx, GetBalance, sellerID).Scan(&balance); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("postgres create listing: %w", utils.ErrNoUser)
+			}
+			return fmt.Errorf("postgres create listing: %w", err)
+		}
+
+		var held int
+		if err := tx.QueryRowContext(ctx, GetItemQuantity, sellerID, itemID).Scan(&held); err != nil {
+			return fmt.Errorf("postgres create listing: %w", err)
+		}
+		if held < quantity {
+			return fmt.Errorf("postgres create listing: %w", utils.ErrNotEnoughItems)
+		}
+
+		if err := tx.QueryRowContext(ctx, AddListing, sellerID, itemID, quantity, price, expiresAt).Scan(&listingID); err != nil {
+			return fmt.Errorf("postgres create listing: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	_, err = tx.Exec(ReduceCoins, amount, fromUserID)
+	return listingID, nil
+}
+
+func scanListing(scanner interface{ Scan(dest ...any) error }) (*entities.Listing, error) {
+	listing := &entities.Listing{}
+	err := scanner.Scan(&listing.ID, &listing.SellerID, &listing.Seller, &listing.ItemType, &listing.Quantity,
+		&listing.Price, &listing.Status, &listing.Buyer, &listing.Fee, &listing.CreatedAt, &listing.ExpiresAt)
 	if err != nil {
-		return fmt.Errorf("send coin error: %w", err)
+		return nil, err
 	}
 
-	_, err = tx.Exec(AddCoins, amount, toUserID)
+	return listing, nil
+}
+
+func (u *UserPostgresRepo) GetListing(ctx context.Context, listingID int) (*entities.Listing, error) {
+	listing, err := scanListing(u.querier(ctx).QueryRowContext(ctx, GetListing, listingID))
 	if err != nil {
-		return fmt.Errorf("send coin error: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres get listing: %w", utils.ErrNoListing)
+		}
+		return nil, fmt.Errorf("postgres get listing: %w", err)
 	}
 
-	_, err = tx.Exec(AddExchangeRecord, fromUserID, toUserID, amount)
+	return listing, nil
+}
+
+func (u *UserPostgresRepo) GetListings(ctx context.Context, itemName string, now time.Time, limit, offset int) ([]*entities.Listing, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetListings, itemName, now, limit, offset)
 	if err != nil {
-		return fmt.Errorf("send coin error: %w", err)
+		return nil, fmt.Errorf("postgres get listings: %w", err)
+	}
+	defer rows.Close()
+
+	listings := make([]*entities.Listing, 0)
+	for rows.Next() {
+		listing, err := scanListing(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get listings: %w", err)
+		}
+		listings = append(listings, listing)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get listings: %w", err)
+	}
+
+	return listings, nil
+}
+
+func (u *UserPostgresRepo) CountListings(ctx context.Context, itemName string, now time.Time) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountListings, itemName, now).Scan(&count); err != nil {
+		return 0, fmt.Errorf("postgres count listings: %w", err)
 	}
 
-	tx.Commit()
+	return count, nil
+}
+
+func (u *UserPostgresRepo) SellListing(ctx context.Context, listingID, buyerID, fee int) error {
+	return sqltx.Run(ctx, u.DB, func(tx sqltx.Querier) error {
+		// Closing the listing first makes a concurrent buyer, cancel or
+		// expiry find it no longer open.
+		var sellerID, price int
+		if err := tx.QueryRowContext(ctx, SellListing, listingID, buyerID, fee).Scan(&sellerID, &price); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("postgres sell listing: %w", utils.ErrListingClosed)
+			}
+			return fmt.Errorf("postgres sell listing: %w", err)
+		}
+
+		var balance int
+		if err := tx.QueryRowContext(ctx, GetBalance, buyerID).Scan(&balance); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("postgres sell listing: %w", utils.ErrNoUser)
+			}
+			return fmt.Errorf("postgres sell listing: %w", err)
+		}
+		if balance < price {
+			return fmt.Errorf("postgres sell listing: %w", utils.ErrNotEnoughBalance)
+		}
+
+		if _, err := tx.ExecContext(ctx, ReduceCoins, price, buyerID); err != nil {
+			return fmt.Errorf("postgres sell listing: %w", err)
+		}
+
+		// The fee is burned: the seller is credited the price without it.
+		if _, err := tx.ExecContext(ctx, AddCoins, price-fee, sellerID); err != nil {
+			return fmt.Errorf("postgres sell listing: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (u *UserPostgresRepo) CloseListing(ctx context.Context, listingID int, status string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, CloseListing, listingID, status)
+	if err != nil {
+		return fmt.Errorf("postgres close listing: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("postgres close listing: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("postgres close listing: %w", utils.ErrListingClosed)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) ExpireListings(ctx context.Context, now time.Time) (int, error) {
+	result, err := u.querier(ctx).ExecContext(ctx, ExpireListings, now)
+	if err != nil {
+		return 0, fmt.Errorf("postgres expire listings: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("postgres expire listings: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// likePattern turns a search term into a LIKE pattern matching any value
+// that contains it, or "" for no term.
+func likePattern(term string) string {
+	if term == "" {
+		return ""
+	}
+
+	term = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+	return "%" + term + "%"
+}
+
+func (u *UserPostgresRepo) SearchTransfers(ctx context.Context, userID int, filter entities.TransferFilter, limit, offset int) ([]*entities.CoinTransfer, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, SearchTransfers, userID, filter.Direction, filter.Category, likePattern(filter.Query), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("postgres search transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := make([]*entities.CoinTransfer, 0)
+	for rows.Next() {
+		transfer := &entities.CoinTransfer{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&transfer.ID, &transfer.FromUser, &transfer.ToUser, &transfer.Amount, &transfer.Memo, &transfer.Category, &createdAt); err != nil {
+			return nil, fmt.Errorf("postgres search transfers: %w", err)
+		}
+		if createdAt.Valid {
+			transfer.CreatedAt = &createdAt.Time
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres search transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (u *UserPostgresRepo) CountTransfers(ctx context.Context, userID int, filter entities.TransferFilter) (int, error) {
+	var count int
+	err := u.querier(ctx).QueryRowContext(ctx, CountTransfers, userID, filter.Direction, filter.Category, likePattern(filter.Query)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("postgres count transfers: %w", err)
+	}
+
+	return count, nil
+}
+
+// scanSchedule reads a row selected with scheduleSelect.
+func scanSchedule(scanner interface{ Scan(dest ...any) error }) (*entities.ScheduledTransfer, error) {
+	schedule := &entities.ScheduledTransfer{}
+	err := scanner.Scan(&schedule.ID, &schedule.FromUserID, &schedule.FromUser, &schedule.ToUserID, &schedule.ToUser, &schedule.Amount,
+		&schedule.Memo, &schedule.Category, &schedule.Recurrence, &schedule.NextRunAt, &schedule.AnchorDay, &schedule.Status, &schedule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (u *UserPostgresRepo) CreateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) (int, error) {
+	var scheduleID int
+	err := u.querier(ctx).QueryRowContext(ctx, AddSchedule, schedule.FromUserID, schedule.ToUserID, schedule.Amount,
+		schedule.Memo, schedule.Category, schedule.Recurrence, schedule.NextRunAt, schedule.AnchorDay).Scan(&scheduleID)
+	if err != nil {
+		return 0, fmt.Errorf("postgres create schedule: %w", err)
+	}
+
+	return scheduleID, nil
+}
+
+func (u *UserPostgresRepo) GetSchedule(ctx context.Context, scheduleID int) (*entities.ScheduledTransfer, error) {
+	schedule, err := scanSchedule(u.querier(ctx).QueryRowContext(ctx, GetSchedule, scheduleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres get schedule: %w", utils.ErrNoSchedule)
+		}
+		return nil, fmt.Errorf("postgres get schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (u *UserPostgresRepo) GetSchedules(ctx context.Context, userID int) ([]*entities.ScheduledTransfer, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetSchedules, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make([]*entities.ScheduledTransfer, 0)
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get schedules: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (u *UserPostgresRepo) UpdateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) error {
+	result, err := u.querier(ctx).ExecContext(ctx, UpdateSchedule, schedule.ID, schedule.ToUserID, schedule.Amount,
+		schedule.Memo, schedule.Category, schedule.Recurrence, schedule.NextRunAt, schedule.AnchorDay)
+	if err != nil {
+		return fmt.Errorf("postgres update schedule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("postgres update schedule: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("postgres update schedule: %w", utils.ErrScheduleClosed)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) CloseSchedule(ctx context.Context, scheduleID int, status string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, CloseSchedule, scheduleID, status)
+	if err != nil {
+		return fmt.Errorf("postgres close schedule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("postgres close schedule: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("postgres close schedule: %w", utils.ErrScheduleClosed)
+	}
+
+	return nil
+}
+
+// ClaimDueSchedule locks the earliest schedule due at now for the rest of
+// the transaction. Schedules locked by another transaction are skipped, so
+// several workers can run side by side without executing a run twice.
+func (u *UserPostgresRepo) ClaimDueSchedule(ctx context.Context, now time.Time) (*entities.ScheduledTransfer, error) {
+	schedule, err := scanSchedule(u.querier(ctx).QueryRowContext(ctx, ClaimDueSchedule, now))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres claim due schedule: %w", utils.ErrNoSchedule)
+		}
+		return nil, fmt.Errorf("postgres claim due schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (u *UserPostgresRepo) AddScheduleRun(ctx context.Context, scheduleID int, run *entities.ScheduledTransferRun) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddScheduleRun, scheduleID, run.RunAt, run.Status, run.Error).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add schedule run: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) GetScheduleRuns(ctx context.Context, scheduleID, limit int) ([]*entities.ScheduledTransferRun, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetScheduleRuns, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*entities.ScheduledTransferRun, 0)
+	for rows.Next() {
+		run := &entities.ScheduledTransferRun{}
+		if err := rows.Scan(&run.ID, &run.RunAt, &run.Status, &run.Error); err != nil {
+			return nil, fmt.Errorf("postgres get schedule runs: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get schedule runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// scanPaymentRequest reads a row selected with paymentRequestSelect.
+func scanPaymentRequest(scanner interface{ Scan(dest ...any) error }) (*entities.PaymentRequest, error) {
+	request := &entities.PaymentRequest{}
+	var closedAt sql.NullTime
+	err := scanner.Scan(&request.ID, &request.RequesterID, &request.Requester, &request.PayerID, &request.Payer, &request.Amount,
+		&request.Memo, &request.Category, &request.Status, &request.CreatedAt, &request.ExpiresAt, &closedAt)
+	if err != nil {
+		return nil, err
+	}
+	if closedAt.Valid {
+		request.ClosedAt = &closedAt.Time
+	}
+
+	return request, nil
+}
+
+func (u *UserPostgresRepo) CreatePaymentRequest(ctx context.Context, request *entities.PaymentRequest) (int, error) {
+	var requestID int
+	err := u.querier(ctx).QueryRowContext(ctx, AddPaymentRequest, request.RequesterID, request.PayerID, request.Amount,
+		request.Memo, request.Category, request.ExpiresAt).Scan(&requestID)
+	if err != nil {
+		return 0, fmt.Errorf("postgres create payment request: %w", err)
+	}
+
+	return requestID, nil
+}
+
+func (u *UserPostgresRepo) GetPaymentRequest(ctx context.Context, requestID int) (*entities.PaymentRequest, error) {
+	request, err := scanPaymentRequest(u.querier(ctx).QueryRowContext(ctx, GetPaymentRequest, requestID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres get payment request: %w", utils.ErrNoPaymentRequest)
+		}
+		return nil, fmt.Errorf("postgres get payment request: %w", err)
+	}
+
+	return request, nil
+}
+
+func (u *UserPostgresRepo) GetPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter, limit, offset int) ([]*entities.PaymentRequest, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetPaymentRequests, userID, filter.Direction, filter.Status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get payment requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]*entities.PaymentRequest, 0)
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get payment requests: %w", err)
+		}
+		requests = append(requests, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get payment requests: %w", err)
+	}
+
+	return requests, nil
+}
+
+func (u *UserPostgresRepo) CountPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter) (int, error) {
+	var count int
+	err := u.querier(ctx).QueryRowContext(ctx, CountPaymentRequests, userID, filter.Direction, filter.Status).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("postgres count payment requests: %w", err)
+	}
+
+	return count, nil
+}
+
+func (u *UserPostgresRepo) ClosePaymentRequest(ctx context.Context, requestID int, status string) error {
+	result, err := u.querier(ctx).ExecContext(ctx, ClosePaymentRequest, requestID, status)
+	if err != nil {
+		return fmt.Errorf("postgres close payment request: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("postgres close payment request: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("postgres close payment request: %w", utils.ErrPaymentRequestClosed)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) ExpirePaymentRequests(ctx context.Context, now time.Time) ([]*entities.PaymentRequest, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, ExpirePaymentRequests, now)
+	if err != nil {
+		return nil, fmt.Errorf("postgres expire payment requests: %w", err)
+	}
+	defer rows.Close()
+
+	expired := make([]*entities.PaymentRequest, 0)
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres expire payment requests: %w", err)
+		}
+		expired = append(expired, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres expire payment requests: %w", err)
+	}
+
+	return expired, nil
+}
+
+func (u *UserPostgresRepo) GetTransferLimits(ctx context.Context, userID int) (*entities.UserTransferLimits, error) {
+	limits := &entities.UserTransferLimits{Override: true}
+	var updatedAt time.Time
+	err := u.querier(ctx).QueryRowContext(ctx, GetTransferLimits, userID).Scan(&limits.Username, &limits.MaxTransfer,
+		&limits.DailyAmount, &limits.WeeklyAmount, &limits.DailyRecipients, &limits.UpdatedBy, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres get transfer limits: %w", utils.ErrNoTransferLimits)
+		}
+		return nil, fmt.Errorf("postgres get transfer limits: %w", err)
+	}
+	limits.UpdatedAt = &updatedAt
+
+	return limits, nil
+}
+
+func (u *UserPostgresRepo) SetTransferLimits(ctx context.Context, userID int, limits entities.TransferLimits, actor string) error {
+	_, err := u.querier(ctx).ExecContext(ctx, SetTransferLimits, userID, limits.MaxTransfer, limits.DailyAmount,
+		limits.WeeklyAmount, limits.DailyRecipients, actor)
+	if err != nil {
+		return fmt.Errorf("postgres set transfer limits: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) DeleteTransferLimits(ctx context.Context, userID int) error {
+	result, err := u.querier(ctx).ExecContext(ctx, DeleteTransferLimits, userID)
+	if err != nil {
+		return fmt.Errorf("postgres delete transfer limits: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("postgres delete transfer limits: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("postgres delete transfer limits: %w", utils.ErrNoTransferLimits)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) GetTransferUsage(ctx context.Context, fromUserID, toUserID int, since time.Time) (*entities.TransferUsage, error) {
+	usage := &entities.TransferUsage{}
+	err := u.querier(ctx).QueryRowContext(ctx, GetTransferUsage, fromUserID, toUserID, since).Scan(&usage.Amount, &usage.Recipients)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get transfer usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+func (u *UserPostgresRepo) AdjustBalance(ctx context.Context, userID, delta int) (int, error) {
+	var balance int
+	err := u.querier(ctx).QueryRowContext(ctx, AdjustBalance, userID, delta).Scan(&balance)
+	if err == nil {
+		return balance, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("postgres adjust balance: %w", err)
+	}
+
+	// Nothing was updated: either the user does not exist or the change
+	// would leave a negative balance.
+	err = u.querier(ctx).QueryRowContext(ctx, GetCoins, userID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("postgres adjust balance: %w", utils.ErrNoUser)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("postgres adjust balance: %w", err)
+	}
+
+	return 0, fmt.Errorf("postgres adjust balance: %w", utils.ErrNotEnoughBalance)
+}
+
+func (u *UserPostgresRepo) AddBalanceAdjustment(ctx context.Context, adjustment *entities.BalanceAdjustment) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddBalanceAdjustment, adjustment.UserID, adjustment.Actor, adjustment.Kind,
+		adjustment.Amount, adjustment.Balance, adjustment.Reason, adjustment.CreatedAt).Scan(&adjustment.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add balance adjustment: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) GetBalanceAdjustments(ctx context.Context, userID, limit, offset int) ([]*entities.BalanceAdjustment, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetBalanceAdjustments, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get balance adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	adjustments := make([]*entities.BalanceAdjustment, 0)
+	for rows.Next() {
+		adjustment := &entities.BalanceAdjustment{}
+		err := rows.Scan(&adjustment.ID, &adjustment.UserID, &adjustment.Username, &adjustment.Actor, &adjustment.Kind,
+			&adjustment.Amount, &adjustment.Balance, &adjustment.Reason, &adjustment.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get balance adjustments: %w", err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get balance adjustments: %w", err)
+	}
+
+	return adjustments, nil
+}
+
+func (u *UserPostgresRepo) CountBalanceAdjustments(ctx context.Context, userID int) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountBalanceAdjustments, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("postgres count balance adjustments: %w", err)
+	}
+
+	return count, nil
+}
+
+func (u *UserPostgresRepo) LockAuditLog(ctx context.Context) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, LockAuditLog); err != nil {
+		return fmt.Errorf("postgres lock audit log: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) AddAuditEvent(ctx context.Context, event *entities.AuditEvent) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddAuditEvent, event.Action, event.Actor, event.Target, event.IP, event.UserAgent,
+		event.RequestID, event.Details, event.CreatedAt.UTC(), event.PrevHash, event.Hash).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add audit event: %w", err)
+	}
+
+	return nil
+}
+
+// LastAuditEvent returns the newest audit event, or the newest with action
+// if it is not empty.
+func (u *UserPostgresRepo) LastAuditEvent(ctx context.Context, action string) (*entities.AuditEvent, error) {
+	event, err := scanAuditEvent(u.querier(ctx).QueryRowContext(ctx, LastAuditEvent, action))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres last audit event: %w", utils.ErrNoAuditEvent)
+		}
+		return nil, fmt.Errorf("postgres last audit event: %w", err)
+	}
+
+	return event, nil
+}
+
+// GetAuditEvents returns up to limit events matching filter with ids above
+// afterID, in log order.
+func (u *UserPostgresRepo) GetAuditEvents(ctx context.Context, filter entities.AuditFilter, afterID, limit int) ([]*entities.AuditEvent, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetAuditEvents, filter.Action, filter.Actor, filter.Target,
+		auditTime(filter.Since), auditTime(filter.Until), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*entities.AuditEvent, 0)
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get audit events: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (u *UserPostgresRepo) CountAuditEvents(ctx context.Context, filter entities.AuditFilter) (int, error) {
+	var count int
+	err := u.querier(ctx).QueryRowContext(ctx, CountAuditEvents, filter.Action, filter.Actor, filter.Target,
+		auditTime(filter.Since), auditTime(filter.Until)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("postgres count audit events: %w", err)
+	}
+
+	return count, nil
+}
+
+// scanAuditEvent reads a row selected with auditEventSelect.
+func scanAuditEvent(scanner interface{ Scan(dest ...any) error }) (*entities.AuditEvent, error) {
+	event := &entities.AuditEvent{}
+	err := scanner.Scan(&event.ID, &event.Action, &event.Actor, &event.Target, &event.IP, &event.UserAgent,
+		&event.RequestID, &event.Details, &event.CreatedAt, &event.PrevHash, &event.Hash)
+	if err != nil {
+		return nil, err
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+
+	return event, nil
+}
+
+// auditTime turns an AuditFilter bound into a query argument; the zero
+// time, meaning no bound, becomes NULL.
+func auditTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func (u *UserPostgresRepo) AddOutboxEvent(ctx context.Context, event *entities.OutboxEvent) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddOutboxEvent, event.Type, event.Key, string(event.Payload), event.CreatedAt.UTC()).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add outbox event: %w", err)
+	}
+
+	return nil
+}
+
+// AcquireOutboxLease makes owner the only relay until until, unless another
+// relay's lease is still valid at now. It reports whether owner holds the
+// lease.
+func (u *UserPostgresRepo) AcquireOutboxLease(ctx context.Context, owner string, now, until time.Time) (bool, error) {
+	var holder string
+	err := u.querier(ctx).QueryRowContext(ctx, AcquireOutboxLease, owner, now.UTC(), until.UTC()).Scan(&holder)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("postgres acquire outbox lease: %w", err)
+	}
+
+	return true, nil
+}
+
+// GetDueOutboxEvents returns up to limit undelivered events due at now, in
+// the order they were written.
+func (u *UserPostgresRepo) GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEvent, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetDueOutboxEvents, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get due outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*entities.OutboxEvent, 0)
+	for rows.Next() {
+		event := &entities.OutboxEvent{}
+		var payload string
+		err := rows.Scan(&event.ID, &event.Type, &event.Key, &payload, &event.CreatedAt, &event.Attempts, &event.LastError, &event.NextAttemptAt)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get due outbox events: %w", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		event.CreatedAt = event.CreatedAt.UTC()
+		event.NextAttemptAt = event.NextAttemptAt.UTC()
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get due outbox events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (u *UserPostgresRepo) MarkOutboxDelivered(ctx context.Context, eventID int, at time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, MarkOutboxDelivered, eventID, at.UTC()); err != nil {
+		return fmt.Errorf("postgres mark outbox delivered: %w", err)
+	}
+
+	return nil
+}
+
+// DeferOutboxEvent records a failed delivery and holds the event, and every
+// later event with its key, back until until.
+func (u *UserPostgresRepo) DeferOutboxEvent(ctx context.Context, eventID int, lastError string, until time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, DeferOutboxEvent, eventID, lastError, until.UTC()); err != nil {
+		return fmt.Errorf("postgres defer outbox event: %w", err)
+	}
+
+	return nil
+}
+
+func scanWebhookEndpoint(scanner interface{ Scan(dest ...any) error }) (*entities.WebhookEndpoint, error) {
+	endpoint := &entities.WebhookEndpoint{}
+	var eventTypes string
+	err := scanner.Scan(&endpoint.ID, &endpoint.UserID, &endpoint.URL, &eventTypes, &endpoint.Secret, &endpoint.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.EventTypes = strings.Split(eventTypes, ",")
+
+	return endpoint, nil
+}
+
+func (u *UserPostgresRepo) AddWebhookEndpoint(ctx context.Context, endpoint *entities.WebhookEndpoint) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddWebhookEndpoint, endpoint.UserID, endpoint.URL,
+		strings.Join(endpoint.EventTypes, ","), endpoint.Secret, endpoint.CreatedAt.UTC()).Scan(&endpoint.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add webhook endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) GetWebhookEndpoint(ctx context.Context, endpointID int) (*entities.WebhookEndpoint, error) {
+	endpoint, err := scanWebhookEndpoint(u.querier(ctx).QueryRowContext(ctx, GetWebhookEndpoint, endpointID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres get webhook endpoint: %w", utils.ErrNoWebhook)
+		}
+		return nil, fmt.Errorf("postgres get webhook endpoint: %w", err)
+	}
+
+	return endpoint, nil
+}
+
+func (u *UserPostgresRepo) GetWebhookEndpoints(ctx context.Context, userID int) ([]*entities.WebhookEndpoint, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetWebhookEndpoints, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]*entities.WebhookEndpoint, 0)
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get webhook endpoints: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get webhook endpoints: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+// DeleteWebhookEndpoint removes the endpoint with its deliveries and their
+// log.
+func (u *UserPostgresRepo) DeleteWebhookEndpoint(ctx context.Context, endpointID int) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, DeleteWebhookEndpoint, endpointID); err != nil {
+		return fmt.Errorf("postgres delete webhook endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func scanWebhookDelivery(scanner interface{ Scan(dest ...any) error }) (*entities.WebhookDelivery, error) {
+	delivery := &entities.WebhookDelivery{}
+	var payload string
+	var deliveredAt sql.NullTime
+	err := scanner.Scan(&delivery.ID, &delivery.EndpointID, &delivery.EventType, &payload, &delivery.Status, &delivery.Attempts,
+		&delivery.LastError, &delivery.NextAttemptAt, &delivery.CreatedAt, &deliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return delivery, nil
+}
+
+func (u *UserPostgresRepo) AddWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddWebhookDelivery, delivery.EndpointID, delivery.EventType, string(delivery.Payload),
+		delivery.Status, delivery.NextAttemptAt.UTC(), delivery.CreatedAt.UTC()).Scan(&delivery.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) GetWebhookDelivery(ctx context.Context, deliveryID int) (*entities.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(u.querier(ctx).QueryRowContext(ctx, GetWebhookDelivery, deliveryID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres get webhook delivery: %w", utils.ErrNoWebhookDelivery)
+		}
+		return nil, fmt.Errorf("postgres get webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// GetWebhookDeliveries returns a page of the endpoint's deliveries with the
+// given status, or all of them for an empty status, newest first.
+func (u *UserPostgresRepo) GetWebhookDeliveries(ctx context.Context, endpointID int, status string, limit, offset int) ([]*entities.WebhookDelivery, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetWebhookDeliveries, endpointID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*entities.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres get webhook deliveries: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (u *UserPostgresRepo) CountWebhookDeliveries(ctx context.Context, endpointID int, status string) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountWebhookDeliveries, endpointID, status).Scan(&count); err != nil {
+		return 0, fmt.Errorf("postgres count webhook deliveries: %w", err)
+	}
+
+	return count, nil
+}
+
+// ClaimDueWebhookDelivery returns the earliest pending delivery due at now
+// and holds it back until until, so no other worker sends it meanwhile.
+func (u *UserPostgresRepo) ClaimDueWebhookDelivery(ctx context.Context, now, until time.Time) (*entities.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(u.querier(ctx).QueryRowContext(ctx, ClaimDueWebhookDelivery, now.UTC(), until.UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres claim due webhook delivery: %w", utils.ErrNoWebhookDelivery)
+		}
+		return nil, fmt.Errorf("postgres claim due webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// UpdateWebhookDelivery stores the delivery's status, attempts, last error,
+// next attempt and delivery time.
+func (u *UserPostgresRepo) UpdateWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	var deliveredAt any
+	if delivery.DeliveredAt != nil {
+		deliveredAt = delivery.DeliveredAt.UTC()
+	}
+
+	_, err := u.querier(ctx).ExecContext(ctx, UpdateWebhookDelivery, delivery.ID, delivery.Status, delivery.Attempts,
+		delivery.LastError, delivery.NextAttemptAt.UTC(), deliveredAt)
+	if err != nil {
+		return fmt.Errorf("postgres update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (u *UserPostgresRepo) AddWebhookAttempt(ctx context.Context, deliveryID int, attempt *entities.WebhookAttempt) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddWebhookAttempt, deliveryID, attempt.AttemptedAt.UTC(), attempt.StatusCode,
+		attempt.Error, attempt.DurationMS).Scan(&attempt.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add webhook attempt: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhookAttempts returns the delivery log of a delivery, oldest first.
+func (u *UserPostgresRepo) GetWebhookAttempts(ctx context.Context, deliveryID int) ([]*entities.WebhookAttempt, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetWebhookAttempts, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := make([]*entities.WebhookAttempt, 0)
+	for rows.Next() {
+		attempt := &entities.WebhookAttempt{}
+		if err := rows.Scan(&attempt.ID, &attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMS); err != nil {
+			return nil, fmt.Errorf("postgres get webhook attempts: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get webhook attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+func (u *UserPostgresRepo) AddUserEvent(ctx context.Context, event *entities.UserEvent) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddUserEvent, event.UserID, event.Type, string(event.Data), event.CreatedAt.UTC()).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add user event: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserEvents returns up to limit of the user's events after afterID,
+// oldest first.
+func (u *UserPostgresRepo) GetUserEvents(ctx context.Context, userID, afterID, limit int) ([]*entities.UserEvent, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetUserEvents, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get user events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*entities.UserEvent, 0)
+	for rows.Next() {
+		event := &entities.UserEvent{}
+		var data string
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Type, &data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("postgres get user events: %w", err)
+		}
+		event.Data = json.RawMessage(data)
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get user events: %w", err)
+	}
+
+	return events, nil
+}
+
+// LastUserEventID returns the id of the user's latest event, or zero if
+// there is none.
+func (u *UserPostgresRepo) LastUserEventID(ctx context.Context, userID int) (int, error) {
+	var id int
+	if err := u.querier(ctx).QueryRowContext(ctx, LastUserEventID, userID).Scan(&id); err != nil {
+		return 0, fmt.Errorf("postgres last user event id: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeleteUserEvents deletes the events created before before and reports
+// how many there were.
+func (u *UserPostgresRepo) DeleteUserEvents(ctx context.Context, before time.Time) (int, error) {
+	result, err := u.querier(ctx).ExecContext(ctx, DeleteUserEvents, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("postgres delete user events: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("postgres delete user events: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+func (u *UserPostgresRepo) AddNotification(ctx context.Context, userID int, notification *entities.Notification) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddNotification, userID, notification.Kind, notification.Text, notification.CreatedAt.UTC()).Scan(&notification.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add notification: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotifications returns a page of the user's notifications, newest
+// first, optionally only the unread ones.
+func (u *UserPostgresRepo) GetNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*entities.Notification, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetNotifications, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*entities.Notification, 0)
+	for rows.Next() {
+		notification := &entities.Notification{}
+		var readAt sql.NullTime
+		if err := rows.Scan(&notification.ID, &notification.Kind, &notification.Text, &notification.CreatedAt, &readAt); err != nil {
+			return nil, fmt.Errorf("postgres get notifications: %w", err)
+		}
+		if readAt.Valid {
+			at := readAt.Time
+			notification.ReadAt = &at
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (u *UserPostgresRepo) CountNotifications(ctx context.Context, userID int, unreadOnly bool) (int, error) {
+	var count int
+	if err := u.querier(ctx).QueryRowContext(ctx, CountNotifications, userID, unreadOnly).Scan(&count); err != nil {
+		return 0, fmt.Errorf("postgres count notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+// MarkNotificationRead marks one of the user's notifications read at at,
+// unless it already was.
+func (u *UserPostgresRepo) MarkNotificationRead(ctx context.Context, userID, notificationID int, at time.Time) error {
+	result, err := u.querier(ctx).ExecContext(ctx, MarkNotificationRead, userID, notificationID, at.UTC())
+	if err != nil {
+		return fmt.Errorf("postgres mark notification read: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("postgres mark notification read: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("postgres mark notification read: %w", utils.ErrNoNotification)
+	}
+
+	return nil
+}
+
+// MarkAllNotificationsRead marks the user's unread notifications read and
+// reports how many there were.
+func (u *UserPostgresRepo) MarkAllNotificationsRead(ctx context.Context, userID int, at time.Time) (int, error) {
+	result, err := u.querier(ctx).ExecContext(ctx, MarkAllNotificationsRead, userID, at.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("postgres mark all notifications read: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("postgres mark all notifications read: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// GetNotificationPreferences returns the kinds the user turned on or off.
+func (u *UserPostgresRepo) GetNotificationPreferences(ctx context.Context, userID int) (entities.NotificationPreferences, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetNotificationPreferences, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	preferences := make(entities.NotificationPreferences)
+	for rows.Next() {
+		var kind string
+		var enabled bool
+		if err := rows.Scan(&kind, &enabled); err != nil {
+			return nil, fmt.Errorf("postgres get notification preferences: %w", err)
+		}
+		preferences[kind] = enabled
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get notification preferences: %w", err)
+	}
+
+	return preferences, nil
+}
+
+func (u *UserPostgresRepo) SetNotificationPreference(ctx context.Context, userID int, kind string, enabled bool) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, SetNotificationPreference, userID, kind, enabled); err != nil {
+		return fmt.Errorf("postgres set notification preference: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotificationsSince returns up to limit of the user's notifications
+// created after since, oldest first.
+func (u *UserPostgresRepo) GetNotificationsSince(ctx context.Context, userID int, since time.Time, limit int) ([]*entities.Notification, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetNotificationsSince, userID, since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get notifications since: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := make([]*entities.Notification, 0)
+	for rows.Next() {
+		notification := &entities.Notification{}
+		var readAt sql.NullTime
+		if err := rows.Scan(&notification.ID, &notification.Kind, &notification.Text, &notification.CreatedAt, &readAt); err != nil {
+			return nil, fmt.Errorf("postgres get notifications since: %w", err)
+		}
+		if readAt.Valid {
+			at := readAt.Time
+			notification.ReadAt = &at
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get notifications since: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (u *UserPostgresRepo) GetEmailSettings(ctx context.Context, userID int) (*entities.EmailSettings, error) {
+	settings := &entities.EmailSettings{}
+	err := u.querier(ctx).QueryRowContext(ctx, GetEmailSettings, userID).Scan(&settings.Address, &settings.Mode, &settings.DigestSentAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres get email settings: %w", utils.ErrNoEmailSettings)
+		}
+		return nil, fmt.Errorf("postgres get email settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func (u *UserPostgresRepo) SetEmailSettings(ctx context.Context, userID int, settings *entities.EmailSettings) error {
+	_, err := u.querier(ctx).ExecContext(ctx, SetEmailSettings, userID, settings.Address, settings.Mode, settings.DigestSentAt.UTC())
+	if err != nil {
+		return fmt.Errorf("postgres set email settings: %w", err)
+	}
+
+	return nil
+}
+
+// GetDueEmailDigests returns up to limit digest mode users whose last
+// digest went out before before.
+func (u *UserPostgresRepo) GetDueEmailDigests(ctx context.Context, before time.Time, limit int) ([]*entities.EmailDigest, error) {
+	rows, err := u.querier(ctx).QueryContext(ctx, GetDueEmailDigests, before.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres get due email digests: %w", err)
+	}
+	defer rows.Close()
+
+	digests := make([]*entities.EmailDigest, 0)
+	for rows.Next() {
+		digest := &entities.EmailDigest{}
+		if err := rows.Scan(&digest.UserID, &digest.Username, &digest.Address, &digest.Since); err != nil {
+			return nil, fmt.Errorf("postgres get due email digests: %w", err)
+		}
+		digests = append(digests, digest)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres get due email digests: %w", err)
+	}
+
+	return digests, nil
+}
+
+func (u *UserPostgresRepo) MarkEmailDigestSent(ctx context.Context, userID int, at time.Time) error {
+	if _, err := u.querier(ctx).ExecContext(ctx, MarkEmailDigestSent, userID, at.UTC()); err != nil {
+		return fmt.Errorf("postgres mark email digest sent: %w", err)
+	}
+
+	return nil
+}
+
+func scanEmail(scanner interface{ Scan(dest ...any) error }) (*entities.Email, error) {
+	email := &entities.Email{}
+	var sentAt sql.NullTime
+	err := scanner.Scan(&email.ID, &email.UserID, &email.To, &email.Subject, &email.Text, &email.HTML, &email.Status,
+		&email.Attempts, &email.LastError, &email.NextAttemptAt, &email.CreatedAt, &sentAt)
+	if err != nil {
+		return nil, err
+	}
+	if sentAt.Valid {
+		email.SentAt = &sentAt.Time
+	}
+
+	return email, nil
+}
+
+func (u *UserPostgresRepo) AddEmail(ctx context.Context, email *entities.Email) error {
+	err := u.querier(ctx).QueryRowContext(ctx, AddEmail, email.UserID, email.To, email.Subject, email.Text, email.HTML,
+		email.Status, email.NextAttemptAt.UTC(), email.CreatedAt.UTC()).Scan(&email.ID)
+	if err != nil {
+		return fmt.Errorf("postgres add email: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDueEmail returns the earliest pending email that is due and holds it
+// back from other workers until until.
+func (u *UserPostgresRepo) ClaimDueEmail(ctx context.Context, now, until time.Time) (*entities.Email, error) {
+	email, err := scanEmail(u.querier(ctx).QueryRowContext(ctx, ClaimDueEmail, now.UTC(), until.UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("postgres claim due email: %w", utils.ErrNoEmail)
+		}
+		return nil, fmt.Errorf("postgres claim due email: %w", err)
+	}
+
+	return email, nil
+}
+
+// UpdateEmail stores the email's status, attempts, last error, next attempt
+// and send time.
+func (u *UserPostgresRepo) UpdateEmail(ctx context.Context, email *entities.Email) error {
+	var sentAt any
+	if email.SentAt != nil {
+		sentAt = email.SentAt.UTC()
+	}
+
+	_, err := u.querier(ctx).ExecContext(ctx, UpdateEmail, email.ID, email.Status, email.Attempts,
+		email.LastError, email.NextAttemptAt.UTC(), sentAt)
+	if err != nil {
+		return fmt.Errorf("postgres update email: %w", err)
+	}
 
 	return nil
 }
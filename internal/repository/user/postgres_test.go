@@ -1,13 +1,18 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqltx"
 	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
@@ -44,7 +49,7 @@ func TestGetItemID(t *testing.T) {
 			WithArgs(itemName).
 			WillReturnRows(rows)
 
-		itemID, err := repo.GetItemID(itemName)
+		itemID, err := repo.GetItemID(context.Background(), itemName)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedID, itemID)
 	})
@@ -56,7 +61,7 @@ func TestGetItemID(t *testing.T) {
 			WithArgs(itemName).
 			WillReturnError(sql.ErrNoRows)
 
-		itemID, err := repo.GetItemID(itemName)
+		itemID, err := repo.GetItemID(context.Background(), itemName)
 		assert.Error(t, err)
 		assert.Equal(t, 0, itemID)
 		assert.True(t, errors.Is(err, sql.ErrNoRows))
@@ -82,7 +87,7 @@ func TestGetUserID(t *testing.T) {
 			WithArgs(username).
 			WillReturnRows(rows)
 
-		userID, err := repo.GetUserID(username)
+		userID, err := repo.GetUserID(context.Background(), username)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedID, userID)
 	})
@@ -94,7 +99,7 @@ func TestGetUserID(t *testing.T) {
 			WithArgs(username).
 			WillReturnError(sql.ErrNoRows)
 
-		_, err := repo.GetUserID(username)
+		_, err := repo.GetUserID(context.Background(), username)
 		assert.Error(t, err)
 		assert.True(t, errors.Is(err, utils.ErrNoUser))
 	})
@@ -106,7 +111,7 @@ func TestGetUserID(t *testing.T) {
 			WithArgs(username).
 			WillReturnError(InternalTestError)
 
-		_, err := repo.GetUserID(username)
+		_, err := repo.GetUserID(context.Background(), username)
 		assert.Error(t, err)
 		assert.True(t, errors.Is(err, InternalTestError))
 	})
@@ -133,11 +138,11 @@ func TestBuyItem(t *testing.T) {
 			WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(price))
 		mock.ExpectExec(`UPDATE users SET balance = balance - (.+) WHERE id = (.+);`).WithArgs(price, userID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
-		mock.ExpectExec(`INSERT INTO purchases \(user_id, item_id, quantity\) VALUES \((.+), (.+), 1\) ON CONFLICT \(user_id, item_id\) DO UPDATE SET quantity = purchases\.quantity \+ 1;`).WithArgs(userID, itemID).
+		mock.ExpectExec(`INSERT INTO orders \(user_id, item_id, quantity, unit_price, recipient_id, message\) VALUES \((.+), (.+), 1, (.+), (.+), (.+)\);`).WithArgs(userID, itemID, price, nil, "").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
-		err = repo.BuyItem(userID, itemID)
+		err = repo.BuyItem(context.Background(), userID, itemID)
 		assert.NoError(t, err)
 
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -149,7 +154,7 @@ func TestBuyItem(t *testing.T) {
 
 		userID := 1
 		itemID := 1
-		err := repo.BuyItem(userID, itemID)
+		err := repo.BuyItem(context.Background(), userID, itemID)
 		assert.Error(t, err)
 		assert.Equal(t, err, BeginTxError)
 	})
@@ -164,7 +169,7 @@ func TestBuyItem(t *testing.T) {
 			WillReturnError(InternalTestError)
 		mock.ExpectCommit()
 
-		err := repo.BuyItem(userID, itemID)
+		err := repo.BuyItem(context.Background(), userID, itemID)
 		assert.Error(t, err)
 	})
 
@@ -180,7 +185,7 @@ func TestBuyItem(t *testing.T) {
 			WillReturnError(InternalTestError)
 		mock.ExpectCommit()
 
-		err := repo.BuyItem(userID, itemID)
+		err := repo.BuyItem(context.Background(), userID, itemID)
 		assert.Error(t, err)
 		// assert.True(t, errors.Is(err, InternalTestError))
 	})
@@ -203,7 +208,7 @@ func TestGetCoinsInfo(t *testing.T) {
 			WithArgs(userID).
 			WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(expectedCoins))
 
-		coins, err := repo.GetCoinsInfo(userID)
+		coins, err := repo.GetCoinsInfo(context.Background(), userID)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedCoins, coins)
 	})
@@ -213,7 +218,7 @@ func TestGetCoinsInfo(t *testing.T) {
 			WithArgs(userID).
 			WillReturnError(sql.ErrNoRows)
 
-		coins, err := repo.GetCoinsInfo(userID)
+		coins, err := repo.GetCoinsInfo(context.Background(), userID)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "get coin info error")
 		assert.Equal(t, 0, coins)
@@ -240,7 +245,7 @@ func TestGetUserByID(t *testing.T) {
 			WithArgs(userID).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "balance"}).AddRow(expectedUser.ID, expectedUser.Username, expectedUser.Coins))
 
-		user, err := repo.GetUserByID(userID)
+		user, err := repo.GetUserByID(context.Background(), userID)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedUser, user)
 	})
@@ -250,7 +255,7 @@ func TestGetUserByID(t *testing.T) {
 			WithArgs(userID).
 			WillReturnError(sql.ErrNoRows)
 
-		user, err := repo.GetUserByID(userID)
+		user, err := repo.GetUserByID(context.Background(), userID)
 		assert.Error(t, err)
 		assert.Nil(t, user)
 		assert.True(t, errors.Is(err, utils.ErrNoUser))
@@ -261,7 +266,7 @@ func TestGetUserByID(t *testing.T) {
 			WithArgs(userID).
 			WillReturnError(InternalTestError)
 
-		user, err := repo.GetUserByID(userID)
+		user, err := repo.GetUserByID(context.Background(), userID)
 		assert.Error(t, err)
 		assert.Nil(t, user)
 		assert.Contains(t, err.Error(), InternalTestError.Error())
@@ -282,35 +287,36 @@ func TestGetUserByUsername(t *testing.T) {
 		ID:       1,
 		Username: username,
 		Password: "hashed_password",
+		Role:     entities.RoleUser,
 	}
 
 	t.Run("success", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, username, password FROM users WHERE username = (.+)").
+		mock.ExpectQuery("SELECT id, username, password, role FROM users WHERE username = (.+)").
 			WithArgs(username).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password"}).AddRow(expectedUser.ID, expectedUser.Username, expectedUser.Password))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "role"}).AddRow(expectedUser.ID, expectedUser.Username, expectedUser.Password, expectedUser.Role))
 
-		user, err := repo.GetUserByUsername(username)
+		user, err := repo.GetUserByUsername(context.Background(), username)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedUser, user)
 	})
 
 	t.Run("error no user", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, username, password FROM users WHERE username = (.+)").
+		mock.ExpectQuery("SELECT id, username, password, role FROM users WHERE username = (.+)").
 			WithArgs(username).
 			WillReturnError(sql.ErrNoRows)
 
-		user, err := repo.GetUserByUsername(username)
+		user, err := repo.GetUserByUsername(context.Background(), username)
 		assert.Error(t, err)
 		assert.Nil(t, user)
 		assert.True(t, errors.Is(err, utils.ErrNoUser))
 	})
 
 	t.Run("internal error", func(t *testing.T) {
-		mock.ExpectQuery("SELECT id, username, password FROM users WHERE username = (.+)").
+		mock.ExpectQuery("SELECT id, username, password, role FROM users WHERE username = (.+)").
 			WithArgs(username).
 			WillReturnError(fmt.Errorf("database error"))
 
-		user, err := repo.GetUserByUsername(username)
+		user, err := repo.GetUserByUsername(context.Background(), username)
 		assert.Error(t, err)
 		assert.Nil(t, user)
 		assert.Contains(t, err.Error(), "database error")
@@ -326,22 +332,18 @@ func TestSendCoin(t *testing.T) {
 		DB: db,
 	}
 
-	t.Run("success", func(t *testing.T) {
-		fromUserID := 1
-		toUserID := 2
-		amount := 50
-
-		fromUser := entities.User{
-			ID:    fromUserID,
-			Coins: 100,
-		}
+	fromUserID := 1
+	toUserID := 2
+	amount := 50
 
+	t.Run("success", func(t *testing.T) {
 		mock.ExpectBegin()
 
-		mock.ExpectQuery("SELECT id, username, balance FROM users WHERE id = (.+);").
-			WithArgs(fromUserID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "balance"}).
-				AddRow(fromUser.ID, "user1", fromUser.Coins))
+		mock.ExpectQuery(`SELECT id, balance FROM users WHERE id IN \((.+), (.+)\) ORDER BY id FOR UPDATE;`).
+			WithArgs(fromUserID, toUserID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).
+				AddRow(fromUserID, 100).
+				AddRow(toUserID, 10))
 
 		mock.ExpectExec(`UPDATE users SET balance = balance \- (.+) WHERE id = (.+);`).
 			WithArgs(amount, fromUserID).
@@ -351,14 +353,958 @@ func TestSendCoin(t *testing.T) {
 			WithArgs(amount, toUserID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		mock.ExpectExec(`INSERT INTO exchanges \(from_id, to_id, amount\) VALUES \((.+), (.+), (.+)\);`).
-			WithArgs(fromUserID, toUserID, amount).
+		mock.ExpectExec(`INSERT INTO exchanges \(from_id, to_id, amount, memo, category\) VALUES \((.+), (.+), (.+), (.+), (.+)\);`).
+			WithArgs(fromUserID, toUserID, amount, "lunch", entities.TransferCategoryReimbursement).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		mock.ExpectCommit()
+
+		err = repo.SendCoin(context.Background(), fromUserID, toUserID, amount, "lunch", entities.TransferCategoryReimbursement)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown receiver", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, balance FROM users WHERE id IN (.+) FOR UPDATE;`).
+			WithArgs(fromUserID, toUserID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(fromUserID, 100))
+		mock.ExpectRollback()
+
+		err := repo.SendCoin(context.Background(), fromUserID, toUserID, amount, "", "")
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not enough balance", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, balance FROM users WHERE id IN (.+) FOR UPDATE;`).
+			WithArgs(fromUserID, toUserID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).
+				AddRow(fromUserID, 10).
+				AddRow(toUserID, 10))
+		mock.ExpectRollback()
+
+		err := repo.SendCoin(context.Background(), fromUserID, toUserID, amount, "", "")
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("joins transaction from context", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, balance FROM users WHERE id IN (.+) FOR UPDATE;`).
+			WithArgs(fromUserID, toUserID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).
+				AddRow(fromUserID, 100).
+				AddRow(toUserID, 10))
+		mock.ExpectExec(`UPDATE users SET balance = balance \- (.+) WHERE id = (.+);`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`UPDATE users SET balance = balance \+ (.+) WHERE id = (.+);`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO exchanges (.+)`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		// The repository must not commit a transaction it did not open.
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		err = repo.SendCoin(sqltx.WithTx(context.Background(), tx), fromUserID, toUserID, amount, "", "")
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Rollback())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLockLoginAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &UserPostgresRepo{
+		DB: db,
+	}
+	username := "test_user"
+	query := `INSERT INTO login_attempts \(username\) VALUES \(\$1\) ON CONFLICT \(username\) DO UPDATE SET username = EXCLUDED\.username RETURNING failed_count, last_failed_at, blocked_until;`
+
+	t.Run("success", func(t *testing.T) {
+		lastFailedAt := time.Date(2025, 1, 1, 11, 59, 0, 0, time.UTC)
+		blockedUntil := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(query).
+			WithArgs(username).
+			WillReturnRows(sqlmock.NewRows([]string{"failed_count", "last_failed_at", "blocked_until"}).AddRow(3, lastFailedAt, blockedUntil))
+
+		attempts, err := repo.LockLoginAttempts(context.Background(), username)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.LoginAttempts{Username: username, FailedCount: 3, LastFailedAt: lastFailedAt, BlockedUntil: blockedUntil}, attempts)
+	})
+
+	t.Run("no attempts", func(t *testing.T) {
+		mock.ExpectQuery(query).
+			WithArgs(username).
+			WillReturnRows(sqlmock.NewRows([]string{"failed_count", "last_failed_at", "blocked_until"}).AddRow(0, nil, nil))
+
+		attempts, err := repo.LockLoginAttempts(context.Background(), username)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.LoginAttempts{Username: username}, attempts)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		mock.ExpectQuery(query).
+			WithArgs(username).
+			WillReturnError(InternalTestError)
+
+		attempts, err := repo.LockLoginAttempts(context.Background(), username)
+		assert.Nil(t, attempts)
+		assert.True(t, errors.Is(err, InternalTestError))
+	})
+}
+
+func TestRegisterFailedLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &UserPostgresRepo{
+		DB: db,
+	}
+	username := "test_user"
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	since := at.Add(-15 * time.Minute)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO login_attempts (.+) ON CONFLICT \(username\) DO UPDATE SET failed_count = CASE WHEN login_attempts\.last_failed_at >= \$3 THEN login_attempts\.failed_count \+ 1 ELSE 1 END`).
+			WithArgs(username, at, since).
+			WillReturnRows(sqlmock.NewRows([]string{"failed_count"}).AddRow(2))
+
+		failed, err := repo.RegisterFailedLogin(context.Background(), username, at, since)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, failed)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO login_attempts`).
+			WithArgs(username, at, since).
+			WillReturnError(InternalTestError)
+
+		_, err := repo.RegisterFailedLogin(context.Background(), username, at, since)
+		assert.True(t, errors.Is(err, InternalTestError))
+	})
+}
+
+func TestBlockAndResetLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &UserPostgresRepo{
+		DB: db,
+	}
+	username := "test_user"
+	until := time.Date(2025, 1, 1, 12, 15, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE login_attempts SET blocked_until = (.+) WHERE username = (.+);`).
+		WithArgs(username, until).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.BlockLogin(context.Background(), username, until))
+
+	mock.ExpectExec(`DELETE FROM login_attempts WHERE username = (.+);`).
+		WithArgs(username).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.ResetLoginAttempts(context.Background(), username))
+
+	mock.ExpectExec(`DELETE FROM login_attempts WHERE username = (.+);`).
+		WithArgs(username).
+		WillReturnError(InternalTestError)
+	assert.True(t, errors.Is(repo.ResetLoginAttempts(context.Background(), username), InternalTestError))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddLockoutEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := &UserPostgresRepo{
+		DB: db,
+	}
+
+	t.Run("locked", func(t *testing.T) {
+		until := time.Date(2025, 1, 1, 12, 15, 0, 0, time.UTC)
+		mock.ExpectExec(`INSERT INTO lockout_events \(username, action, actor, failed_count, locked_until\)`).
+			WithArgs("test_user", entities.LockoutActionLocked, "", 5, until).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := repo.AddLockoutEvent(context.Background(), &entities.LockoutEvent{
+			Username:    "test_user",
+			Action:      entities.LockoutActionLocked,
+			FailedCount: 5,
+			LockedUntil: until,
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("unlocked", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO lockout_events`).
+			WithArgs("test_user", entities.LockoutActionUnlocked, "admin", 0, nil).
+			WillReturnError(InternalTestError)
+
+		err := repo.AddLockoutEvent(context.Background(), &entities.LockoutEvent{
+			Username: "test_user",
+			Action:   entities.LockoutActionUnlocked,
+			Actor:    "admin",
+		})
+		assert.True(t, errors.Is(err, InternalTestError))
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&pq.Error{Code: "40001"}))
+	assert.True(t, IsRetryable(fmt.Errorf("send coin error: %w", &pq.Error{Code: "40P01"})))
+	assert.False(t, IsRetryable(&pq.Error{Code: "23505"}))
+	assert.True(t, IsRetryable(&pq.Error{Code: "23505", Constraint: "audit_log_prev_hash_key"}))
+	assert.False(t, IsRetryable(InternalTestError))
+}
+
+func TestGetRefundableOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	createdAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT orders.id, items.name, (.+) FROM orders (.+) HAVING (.+) ORDER BY orders.id DESC;`).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "quantity", "unit_price", "refunded", "created_at"}).
+				AddRow(7, "cup", 1, 20, 0, createdAt))
+
+		orders, err := repo.GetRefundableOrders(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.Order{
+			{ID: 7, ItemType: "cup", Quantity: 1, UnitPrice: 20, Total: 20, CreatedAt: createdAt},
+		}, orders)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT orders.id, items.name, (.+) FROM orders`).
+			WithArgs(1, 2).
+			WillReturnError(InternalTestError)
+
+		_, err := repo.GetRefundableOrders(context.Background(), 1, 2)
+		assert.True(t, errors.Is(err, InternalTestError))
+	})
+}
+
+func TestAddRefund(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	refund := &entities.Refund{OrderID: 7, UserID: 1, ItemID: 2, Quantity: 1, Amount: 20, Actor: "admin"}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT COALESCE\(SUM\(quantity\), 0\) FROM inventory WHERE user_id = (.+) AND item_id = (.+);`).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(1))
+		mock.ExpectExec(`UPDATE users SET balance = balance \+ (.+) WHERE id = (.+);`).
+			WithArgs(20, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO refunds \(order_id, user_id, quantity, amount, actor\) VALUES (.+);`).
+			WithArgs(7, 1, 1, 20, "admin").
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.AddRefund(context.Background(), refund))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not enough items", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT COALESCE\(SUM\(quantity\), 0\) FROM inventory`).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(0))
+		mock.ExpectRollback()
+
+		err := repo.AddRefund(context.Background(), refund)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	createdAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT orders.id, (.+) FROM orders (.+) WHERE orders.user_id = (.+) ORDER BY orders.id DESC LIMIT (.+) OFFSET (.+);`).
+			WithArgs(1, "hoody", 10, 20).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "quantity", "unit_price", "refunded", "gift_to", "message", "created_at"}).
+				AddRow(9, "hoody", 2, 300, 1, "", "", createdAt))
+
+		orders, err := repo.GetOrders(context.Background(), 1, "hoody", 10, 20)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.Order{
+			{ID: 9, ItemType: "hoody", Quantity: 2, UnitPrice: 300, Total: 600, Refunded: 1, CreatedAt: createdAt},
+		}, orders)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT orders.id, (.+) FROM orders`).
+			WithArgs(1, "", 10, 0).
+			WillReturnError(InternalTestError)
+
+		_, err := repo.GetOrders(context.Background(), 1, "", 10, 0)
+		assert.True(t, errors.Is(err, InternalTestError))
+	})
+}
+
+func TestCountOrders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
 
+	repo := NewUserPostgresRepo(db)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM orders (.+) WHERE orders.user_id = (.+);`).
+		WithArgs(1, "").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := repo.CountOrders(context.Background(), 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestGiftItem(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT balance FROM users WHERE id = (.+);`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+	mock.ExpectQuery(`SELECT price FROM items WHERE id = (.+);`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(20))
+	mock.ExpectExec(`UPDATE users SET balance = balance - (.+) WHERE id = (.+);`).WithArgs(20, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO orders (.+) VALUES (.+);`).WithArgs(1, 3, 20, int64(2), "happy birthday").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.GiftItem(context.Background(), 1, 2, 3, "happy birthday")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetGifts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	t.Run("sent", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT users.username, items.name, orders.message FROM orders (.+) WHERE orders.user_id = (.+)`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"username", "name", "message"}).AddRow("bob", "cup", "thanks"))
+
+		gifts, err := repo.GetSentGifts(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.SentGift{{ToUser: "bob", Item: "cup", Message: "thanks"}}, gifts)
+	})
+
+	t.Run("received", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT users.username, items.name, orders.message FROM orders (.+) WHERE orders.recipient_id = (.+)`).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"username", "name", "message"}).AddRow("alice", "cup", ""))
+
+		gifts, err := repo.GetReceivedGifts(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.ReceivedGift{{FromUser: "alice", Item: "cup"}}, gifts)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT users.username, items.name, orders.message FROM orders`).
+			WithArgs(1).
+			WillReturnError(InternalTestError)
+
+		_, err := repo.GetSentGifts(context.Background(), 1)
+		assert.True(t, errors.Is(err, InternalTestError))
+	})
+}
+
+func TestTransferItem(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, balance FROM users WHERE id IN (.+) ORDER BY id FOR UPDATE;`).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(1, 100).AddRow(2, 100))
+		mock.ExpectQuery(`SELECT COALESCE\(SUM\(quantity\), 0\) FROM inventory`).
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(2))
+		mock.ExpectExec(`INSERT INTO item_transfers \(from_id, to_id, item_id, quantity\) VALUES (.+);`).
+			WithArgs(1, 2, 3, 2).
+			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
-		err = repo.SendCoin(fromUserID, toUserID, amount)
+		err := repo.TransferItem(context.Background(), 1, 2, 3, 2)
 		assert.NoError(t, err)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+
+	t.Run("unknown recipient", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, balance FROM users WHERE id IN (.+) ORDER BY id FOR UPDATE;`).
+			WithArgs(1, 9).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(1, 100))
+		mock.ExpectRollback()
+
+		err := repo.TransferItem(context.Background(), 1, 9, 3, 1)
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not enough items", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id, balance FROM users WHERE id IN (.+) ORDER BY id FOR UPDATE;`).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(1, 100).AddRow(2, 100))
+		mock.ExpectQuery(`SELECT COALESCE\(SUM\(quantity\), 0\) FROM inventory`).
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(1))
+		mock.ExpectRollback()
+
+		err := repo.TransferItem(context.Background(), 1, 2, 3, 2)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetItemTransfers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	t.Run("sent", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT users.username, items.name, item_transfers.quantity FROM item_transfers (.+) WHERE item_transfers.from_id = (.+)`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"username", "name", "quantity"}).AddRow("bob", "cup", 2))
+
+		operations, err := repo.GetSentItems(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.SentItemOperation{{ToUser: "bob", Item: "cup", Quantity: 2}}, operations)
+	})
+
+	t.Run("received", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT users.username, items.name, item_transfers.quantity FROM item_transfers (.+) WHERE item_transfers.to_id = (.+)`).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"username", "name", "quantity"}).AddRow("alice", "cup", 2))
+
+		operations, err := repo.GetReceivedItems(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.ReceivedItemOperation{{FromUser: "alice", Item: "cup", Quantity: 2}}, operations)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT users.username, items.name, item_transfers.quantity FROM item_transfers`).
+			WithArgs(2).
+			WillReturnError(InternalTestError)
+
+		_, err := repo.GetReceivedItems(context.Background(), 2)
+		assert.True(t, errors.Is(err, InternalTestError))
+	})
+}
+
+func TestSellListing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE listings SET status = 'sold', (.+) WHERE id = (.+) AND status = 'open' RETURNING seller_id, price;`).
+			WithArgs(7, 2, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"seller_id", "price"}).AddRow(1, 100))
+		mock.ExpectQuery(`SELECT balance FROM users WHERE id = (.+) FOR UPDATE;`).WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+		mock.ExpectExec(`UPDATE users SET balance = balance - (.+) WHERE id = (.+);`).WithArgs(100, 2).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`UPDATE users SET balance = balance \+ (.+) WHERE id = (.+);`).WithArgs(95, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.SellListing(context.Background(), 7, 2, 5)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("listing closed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE listings SET status = 'sold'`).
+			WithArgs(7, 2, 5).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		err := repo.SellListing(context.Background(), 7, 2, 5)
+		assert.True(t, errors.Is(err, utils.ErrListingClosed))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not enough balance", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE listings SET status = 'sold'`).
+			WithArgs(7, 2, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"seller_id", "price"}).AddRow(1, 100))
+		mock.ExpectQuery(`SELECT balance FROM users WHERE id = (.+) FOR UPDATE;`).WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(99))
+		mock.ExpectRollback()
+
+		err := repo.SellListing(context.Background(), 7, 2, 5)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCloseListing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE listings SET status = (.+), closed_at = NOW\(\) WHERE id = (.+) AND status = 'open';`).
+			WithArgs(7, entities.ListingCancelled).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.CloseListing(context.Background(), 7, entities.ListingCancelled)
+		assert.NoError(t, err)
+	})
+
+	t.Run("already closed", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE listings SET status = (.+), closed_at = NOW\(\) WHERE id = (.+) AND status = 'open';`).
+			WithArgs(7, entities.ListingCancelled).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.CloseListing(context.Background(), 7, entities.ListingCancelled)
+		assert.True(t, errors.Is(err, utils.ErrListingClosed))
+	})
+}
+
+func TestGetListing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	createdAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(time.Hour)
+	columns := []string{"id", "seller_id", "seller", "name", "quantity", "price", "status", "buyer", "fee", "created_at", "expires_at"}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT listings.id, (.+) FROM listings (.+) WHERE listings.id = (.+);`).
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "alice", "cup", 2, 100, "sold", "bob", 5, createdAt, expiresAt))
+
+		listing, err := repo.GetListing(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.Listing{
+			ID:        7,
+			SellerID:  1,
+			Seller:    "alice",
+			ItemType:  "cup",
+			Quantity:  2,
+			Price:     100,
+			Status:    entities.ListingSold,
+			Buyer:     "bob",
+			Fee:       5,
+			CreatedAt: createdAt,
+			ExpiresAt: expiresAt,
+		}, listing)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT listings.id, (.+) FROM listings`).
+			WithArgs(8).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.GetListing(context.Background(), 8)
+		assert.True(t, errors.Is(err, utils.ErrNoListing))
+	})
+}
+
+func TestSearchTransfers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	createdAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "from", "to", "amount", "memo", "category", "created_at"}
+
+	t.Run("success", func(t *testing.T) {
+		filter := entities.TransferFilter{Direction: entities.TransferDirectionSent, Query: "50%_off"}
+
+		mock.ExpectQuery(`SELECT exchanges.id, (.+) FROM exchanges (.+) LIMIT (.+) OFFSET (.+);`).
+			WithArgs(1, entities.TransferDirectionSent, "", `%50\%\_off%`, 10, 0).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(4, "alice", "bob", 30, "50%_off", entities.TransferCategoryGift, createdAt).
+				AddRow(2, "alice", "carol", 10, "", entities.TransferCategoryOther, nil))
+
+		transfers, err := repo.SearchTransfers(context.Background(), 1, filter, 10, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.CoinTransfer{
+			{ID: 4, FromUser: "alice", ToUser: "bob", Amount: 30, Memo: "50%_off", Category: entities.TransferCategoryGift, CreatedAt: &createdAt},
+			{ID: 2, FromUser: "alice", ToUser: "carol", Amount: 10, Category: entities.TransferCategoryOther},
+		}, transfers)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("count", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM exchanges (.+);`).
+			WithArgs(1, "", entities.TransferCategoryBet, "").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		count, err := repo.CountTransfers(context.Background(), 1, entities.TransferFilter{Category: entities.TransferCategoryBet})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestClaimDueSchedule(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	now := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "from_id", "from", "to_id", "to", "amount", "memo", "category", "recurrence", "next_run_at", "anchor_day", "status", "created_at"}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT scheduled_transfers.id, (.+) WHERE scheduled_transfers.status = 'active' AND scheduled_transfers.next_run_at <= (.+) FOR UPDATE OF scheduled_transfers SKIP LOCKED;`).
+			WithArgs(now).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(4, 1, "alice", 2, "bob", 25, "allowance", "gift", "weekly", now.Add(-time.Hour), 31, "active", now.Add(-24*time.Hour)))
+
+		schedule, err := repo.ClaimDueSchedule(context.Background(), now)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.ScheduledTransfer{
+			ID:         4,
+			FromUserID: 1,
+			FromUser:   "alice",
+			ToUserID:   2,
+			ToUser:     "bob",
+			Amount:     25,
+			Memo:       "allowance",
+			Category:   entities.TransferCategoryGift,
+			Recurrence: entities.RecurrenceWeekly,
+			NextRunAt:  now.Add(-time.Hour),
+			Status:     entities.ScheduleActive,
+			CreatedAt:  now.Add(-24 * time.Hour),
+			AnchorDay:  31,
+		}, schedule)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nothing due", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT scheduled_transfers.id, (.+) SKIP LOCKED;`).
+			WithArgs(now).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.ClaimDueSchedule(context.Background(), now)
+		assert.True(t, errors.Is(err, utils.ErrNoSchedule))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCloseSchedule(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE scheduled_transfers SET status = (.+) WHERE id = (.+) AND status = 'active';`).
+			WithArgs(4, entities.ScheduleCompleted).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.CloseSchedule(context.Background(), 4, entities.ScheduleCompleted))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already closed", func(t *testing.T) {
+		mock.ExpectExec(`UPDATE scheduled_transfers SET status = (.+) WHERE id = (.+) AND status = 'active';`).
+			WithArgs(4, entities.ScheduleCancelled).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.CloseSchedule(context.Background(), 4, entities.ScheduleCancelled)
+		assert.True(t, errors.Is(err, utils.ErrScheduleClosed))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetPaymentRequest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	createdAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	closedAt := createdAt.Add(time.Minute)
+	columns := []string{"id", "requester_id", "requester", "payer_id", "payer", "amount", "memo", "category", "status", "created_at", "expires_at", "closed_at"}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT payment_requests.id, (.+) WHERE payment_requests.id = (.+);`).
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(3, 1, "alice", 2, "bob", 15, "lunch", "reimbursement", "accepted", createdAt, createdAt.Add(time.Hour), closedAt))
+
+		request, err := repo.GetPaymentRequest(context.Background(), 3)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.PaymentRequest{
+			ID:          3,
+			RequesterID: 1,
+			Requester:   "alice",
+			PayerID:     2,
+			Payer:       "bob",
+			Amount:      15,
+			Memo:        "lunch",
+			Category:    entities.TransferCategoryReimbursement,
+			Status:      entities.PaymentRequestAccepted,
+			CreatedAt:   createdAt,
+			ExpiresAt:   createdAt.Add(time.Hour),
+			ClosedAt:    &closedAt,
+		}, request)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT payment_requests.id, (.+) WHERE payment_requests.id = (.+);`).
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		_, err := repo.GetPaymentRequest(context.Background(), 4)
+		assert.True(t, errors.Is(err, utils.ErrNoPaymentRequest))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestClosePaymentRequest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	mock.ExpectExec(`UPDATE payment_requests SET status = (.+), closed_at = NOW\(\) WHERE id = (.+) AND status = 'pending';`).
+		WithArgs(3, entities.PaymentRequestDeclined).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.ClosePaymentRequest(context.Background(), 3, entities.PaymentRequestDeclined)
+	assert.True(t, errors.Is(err, utils.ErrPaymentRequestClosed))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTransferUsage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	since := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\), COUNT\(DISTINCT (.+)\) FROM exchanges WHERE from_id = \$1 AND created_at >= \$3;`).
+		WithArgs(1, 2, since).
+		WillReturnRows(sqlmock.NewRows([]string{"sum", "count"}).AddRow(120, 3))
+
+	usage, err := repo.GetTransferUsage(context.Background(), 1, 2, since)
+	assert.NoError(t, err)
+	assert.Equal(t, &entities.TransferUsage{Amount: 120, Recipients: 3}, usage)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdjustBalance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE users SET balance = balance \+ \$2 WHERE id = \$1 AND balance \+ \$2 >= 0 RETURNING balance;`).
+			WithArgs(1, -50).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(950))
+
+		balance, err := repo.AdjustBalance(context.Background(), 1, -50)
+		assert.NoError(t, err)
+		assert.Equal(t, 950, balance)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not enough balance", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE users SET balance = (.+) RETURNING balance;`).
+			WithArgs(1, -5000).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}))
+		mock.ExpectQuery(`SELECT balance FROM users WHERE id = (.+);`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(950))
+
+		_, err := repo.AdjustBalance(context.Background(), 1, -5000)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE users SET balance = (.+) RETURNING balance;`).
+			WithArgs(9, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}))
+		mock.ExpectQuery(`SELECT balance FROM users WHERE id = (.+);`).
+			WithArgs(9).
+			WillReturnRows(sqlmock.NewRows([]string{"balance"}))
+
+		_, err := repo.AdjustBalance(context.Background(), 9, 10)
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	since := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "action", "actor", "target", "ip", "user_agent", "request_id", "details", "created_at", "prev_hash", "hash"}
+
+	mock.ExpectQuery(`SELECT (.+) FROM audit_log WHERE (.+) AND id > \$6 ORDER BY id LIMIT \$7;`).
+		WithArgs(entities.AuditCoinsSent, "alice", "", since, nil, 10, 50).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(11, entities.AuditCoinsSent, "alice", "bob", "10.0.0.1", "curl/8.0", "req-1", "amount=5 category=other", since, "aa", "bb"))
+
+	events, err := repo.GetAuditEvents(context.Background(), entities.AuditFilter{Action: entities.AuditCoinsSent, Actor: "alice", Since: since}, 10, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, []*entities.AuditEvent{{
+		ID:        11,
+		Action:    entities.AuditCoinsSent,
+		Actor:     "alice",
+		Target:    "bob",
+		IP:        "10.0.0.1",
+		UserAgent: "curl/8.0",
+		RequestID: "req-1",
+		Details:   "amount=5 category=other",
+		CreatedAt: since,
+		PrevHash:  "aa",
+		Hash:      "bb",
+	}}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLastAuditEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	mock.ExpectQuery(`SELECT (.+) FROM audit_log WHERE (.+) ORDER BY id DESC LIMIT 1;`).
+		WithArgs("").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.LastAuditEvent(context.Background(), "")
+	assert.True(t, errors.Is(err, utils.ErrNoAuditEvent))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockAuditLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(hashtext\('audit_log'\)\);`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WillReturnError(InternalTestError)
+
+	assert.NoError(t, repo.LockAuditLog(context.Background()))
+	assert.True(t, errors.Is(repo.LockAuditLog(context.Background()), InternalTestError))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcquireOutboxLease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`INSERT INTO outbox_relay_lease (.+) ON CONFLICT (.+) RETURNING owner;`).
+		WithArgs("relay-1", now, now.Add(30*time.Second)).
+		WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("relay-1"))
+	mock.ExpectQuery(`INSERT INTO outbox_relay_lease (.+) ON CONFLICT (.+) RETURNING owner;`).
+		WithArgs("relay-2", now, now.Add(30*time.Second)).
+		WillReturnError(sql.ErrNoRows)
+
+	acquired, err := repo.AcquireOutboxLease(context.Background(), "relay-1", now, now.Add(30*time.Second))
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = repo.AcquireOutboxLease(context.Background(), "relay-2", now, now.Add(30*time.Second))
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDueOutboxEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT (.+) FROM outbox WHERE delivered_at IS NULL (.+) ORDER BY id LIMIT \$2;`).
+		WithArgs(now, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "event_key", "payload", "created_at", "attempts", "last_error", "next_attempt_at"}).
+			AddRow(3, "CoinsSent", "alice", `{"amount":15}`, now, 1, "sink down", now))
+
+	events, err := repo.GetDueOutboxEvents(context.Background(), now, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, []*entities.OutboxEvent{{
+		ID:            3,
+		Type:          entities.EventCoinsSent,
+		Key:           "alice",
+		Payload:       json.RawMessage(`{"amount":15}`),
+		CreatedAt:     now,
+		Attempts:      1,
+		LastError:     "sink down",
+		NextAttemptAt: now,
+	}}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -1,21 +1,193 @@
 package repository
 
 var (
-	GetBalance = "SELECT balance FROM users WHERE id = $1;"
+	GetBalance = "SELECT balance FROM users WHERE id = $1 FOR UPDATE;"
+	LockUsers = "SELECT id, balance FROM users WHERE id IN ($1, $2) ORDER BY id FOR UPDATE;"
 	GetPrice = "SELECT price FROM items WHERE id = $1;"
-	AddRecord = "INSERT INTO purchases (user_id, item_id) VALUES ($1, $2);" //
 	GetItemID = "SELECT id FROM items WHERE name = $1;"
+	GetCatalog = "SELECT name, price FROM items ORDER BY name;"
+	SetItemPrice = "INSERT INTO items (name, price) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET price = EXCLUDED.price;"
 	GetUserID = "SELECT id FROM users WHERE username = $1;"
-	AddToInventory = "INSERT INTO purchases (user_id, item_id, quantity) VALUES ($1, $2, 1) ON CONFLICT (user_id, item_id) DO UPDATE SET quantity = purchases.quantity + 1;"
-	GetInventory = "SELECT items.name, purchases.quantity FROM purchases JOIN items ON purchases.item_id = items.id WHERE purchases.user_id = $1;"
+	GetInventory = "SELECT items.name, inventory.quantity FROM inventory JOIN items ON inventory.item_id = items.id WHERE inventory.user_id = $1 ORDER BY items.name;"
+	GetItemQuantity = "SELECT COALESCE(SUM(quantity), 0) FROM inventory WHERE user_id = $1 AND item_id = $2;"
 	CheckExists = "SELECT EXISTS(SELECT 1 FROM users WHERE username = $1);"
-	GetUser     = "SELECT id, username, password FROM users WHERE username = $1;"
+	GetUser     = "SELECT id, username, password, role FROM users WHERE username = $1;"
 	GetUserByID     = "SELECT id, username, balance FROM users WHERE id = $1;"
 	CreateUser = "INSERT INTO users (username, password, balance) VAlUES ($1, $2, $3);"
 	ReduceCoins = "UPDATE users SET balance = balance - $1 WHERE id = $2;"
 	AddCoins = "UPDATE users SET balance = balance + $1 WHERE id = $2;"
-	AddExchangeRecord = "INSERT INTO exchanges (from_id, to_id, amount) VALUES ($1, $2, $3);"
+	AddExchangeRecord = "INSERT INTO exchanges (from_id, to_id, amount, memo, category) VALUES ($1, $2, $3, $4, $5);"
 	GetCoins = "SELECT balance FROM users WHERE id = $1;"
-	GetReceiveInfo = "SELECT from_id, amount FROM exchanges WHERE to_id = $1;"
-	GetSentInfo = "SELECT to_id, amount FROM exchanges WHERE from_id = $1"
-)
\ No newline at end of file
+	GetReceiveInfo = "SELECT users.username, exchanges.amount, exchanges.memo, exchanges.category FROM exchanges JOIN users ON users.id = exchanges.from_id WHERE exchanges.to_id = $1 ORDER BY exchanges.id;"
+	GetSentInfo = "SELECT users.username, exchanges.amount, exchanges.memo, exchanges.category FROM exchanges JOIN users ON users.id = exchanges.to_id WHERE exchanges.from_id = $1 ORDER BY exchanges.id;"
+	LockLoginAttempts = "INSERT INTO login_attempts (username) VALUES ($1) ON CONFLICT (username) DO UPDATE SET username = EXCLUDED.username RETURNING failed_count, last_failed_at, blocked_until;"
+	RegisterFailedLogin = "INSERT INTO login_attempts (username, failed_count, last_failed_at) VALUES ($1, 1, $2) ON CONFLICT (username) DO UPDATE SET failed_count = CASE WHEN login_attempts.last_failed_at >= $3 THEN login_attempts.failed_count + 1 ELSE 1 END, last_failed_at = $2 RETURNING failed_count;"
+	BlockLogin = "UPDATE login_attempts SET blocked_until = $2 WHERE username = $1;"
+	ResetLoginAttempts = "DELETE FROM login_attempts WHERE username = $1;"
+	SetUserRole = "UPDATE users SET role = $2 WHERE username = $1;"
+	AddLockoutEvent = "INSERT INTO lockout_events (username, action, actor, failed_count, locked_until) VALUES ($1, $2, $3, $4, $5);"
+	AddOrder = "INSERT INTO orders (user_id, item_id, quantity, unit_price, recipient_id, message) VALUES ($1, $2, 1, $3, $4, $5);"
+	GetRefundableOrders = "SELECT orders.id, items.name, orders.quantity, orders.unit_price, COALESCE(SUM(refunds.quantity), 0), orders.created_at FROM orders JOIN items ON items.id = orders.item_id LEFT JOIN refunds ON refunds.order_id = orders.id WHERE orders.user_id = $1 AND orders.item_id = $2 AND orders.recipient_id IS NULL GROUP BY orders.id, items.name HAVING orders.quantity > COALESCE(SUM(refunds.quantity), 0) ORDER BY orders.id DESC;"
+	AddRefund = "INSERT INTO refunds (order_id, user_id, quantity, amount, actor) VALUES ($1, $2, $3, $4, $5);"
+	GetOrders = "SELECT orders.id, items.name, orders.quantity, orders.unit_price, COALESCE(refunded.quantity, 0), COALESCE(recipients.username, ''), orders.message, orders.created_at FROM orders JOIN items ON items.id = orders.item_id LEFT JOIN users AS recipients ON recipients.id = orders.recipient_id LEFT JOIN (SELECT order_id, SUM(quantity) AS quantity FROM refunds GROUP BY order_id) AS refunded ON refunded.order_id = orders.id WHERE orders.user_id = $1 AND ($2::text = '' OR items.name = $2) ORDER BY orders.id DESC LIMIT $3 OFFSET $4;"
+	CountOrders = "SELECT COUNT(*) FROM orders JOIN items ON items.id = orders.item_id WHERE orders.user_id = $1 AND ($2::text = '' OR items.name = $2);"
+	GetRefundInfo = "SELECT items.name, refunds.quantity, refunds.amount, refunds.actor FROM refunds JOIN orders ON orders.id = refunds.order_id JOIN items ON items.id = orders.item_id WHERE refunds.user_id = $1 ORDER BY refunds.id;"
+	GetSentGifts = "SELECT users.username, items.name, orders.message FROM orders JOIN users ON users.id = orders.recipient_id JOIN items ON items.id = orders.item_id WHERE orders.user_id = $1 ORDER BY orders.id;"
+	GetReceivedGifts = "SELECT users.username, items.name, orders.message FROM orders JOIN users ON users.id = orders.user_id JOIN items ON items.id = orders.item_id WHERE orders.recipient_id = $1 ORDER BY orders.id;"
+	AddItemTransfer = "INSERT INTO item_transfers (from_id, to_id, item_id, quantity) VALUES ($1, $2, $3, $4);"
+	GetSentItems = "SELECT users.username, items.name, item_transfers.quantity FROM item_transfers JOIN users ON users.id = item_transfers.to_id JOIN items ON items.id = item_transfers.item_id WHERE item_transfers.from_id = $1 ORDER BY item_transfers.id;"
+	GetReceivedItems = "SELECT users.username, items.name, item_transfers.quantity FROM item_transfers JOIN users ON users.id = item_transfers.from_id JOIN items ON items.id = item_transfers.item_id WHERE item_transfers.to_id = $1 ORDER BY item_transfers.id;"
+	AddListing = "INSERT INTO listings (seller_id, item_id, quantity, price, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
+	GetListing = "SELECT listings.id, listings.seller_id, sellers.username, items.name, listings.quantity, listings.price, listings.status, COALESCE(buyers.username, ''), listings.fee, listings.created_at, listings.expires_at FROM listings JOIN users AS sellers ON sellers.id = listings.seller_id JOIN items ON items.id = listings.item_id LEFT JOIN users AS buyers ON buyers.id = listings.buyer_id WHERE listings.id = $1;"
+	GetListings = "SELECT listings.id, listings.seller_id, sellers.username, items.name, listings.quantity, listings.price, listings.status, COALESCE(buyers.username, ''), listings.fee, listings.created_at, listings.expires_at FROM listings JOIN users AS sellers ON sellers.id = listings.seller_id JOIN items ON items.id = listings.item_id LEFT JOIN users AS buyers ON buyers.id = listings.buyer_id WHERE listings.status = 'open' AND listings.expires_at > $2 AND ($1::text = '' OR items.name = $1) ORDER BY listings.id DESC LIMIT $3 OFFSET $4;"
+	CountListings = "SELECT COUNT(*) FROM listings JOIN items ON items.id = listings.item_id WHERE listings.status = 'open' AND listings.expires_at > $2 AND ($1::text = '' OR items.name = $1);"
+	SellListing = "UPDATE listings SET status = 'sold', buyer_id = $2, fee = $3, closed_at = NOW() WHERE id = $1 AND status = 'open' RETURNING seller_id, price;"
+	CloseListing = "UPDATE listings SET status = $2, closed_at = NOW() WHERE id = $1 AND status = 'open';"
+	ExpireListings = "UPDATE listings SET status = 'expired', closed_at = $1 WHERE status = 'open' AND expires_at <= $1;"
+	SearchTransfers = "SELECT exchanges.id, senders.username, recipients.username, exchanges.amount, exchanges.memo, exchanges.category, exchanges.created_at " + transfersFilter + " ORDER BY exchanges.id DESC LIMIT $5 OFFSET $6;"
+	CountTransfers = "SELECT COUNT(*) " + transfersFilter + ";"
+	AddSchedule = "INSERT INTO scheduled_transfers (from_id, to_id, amount, memo, category, recurrence, next_run_at, anchor_day) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;"
+	GetSchedule = scheduleSelect + " WHERE scheduled_transfers.id = $1;"
+	GetSchedules = scheduleSelect + " WHERE scheduled_transfers.from_id = $1 ORDER BY scheduled_transfers.id DESC;"
+	UpdateSchedule = "UPDATE scheduled_transfers SET to_id = $2, amount = $3, memo = $4, category = $5, recurrence = $6, next_run_at = $7, anchor_day = $8 WHERE id = $1 AND status = 'active';"
+	CloseSchedule = "UPDATE scheduled_transfers SET status = $2 WHERE id = $1 AND status = 'active';"
+	// ClaimDueSchedule locks the earliest due schedule and skips ones another
+	// replica has already locked, so each run is executed exactly once.
+	ClaimDueSchedule = scheduleSelect + " WHERE scheduled_transfers.status = 'active' AND scheduled_transfers.next_run_at <= $1 ORDER BY scheduled_transfers.next_run_at, scheduled_transfers.id LIMIT 1 FOR UPDATE OF scheduled_transfers SKIP LOCKED;"
+	AddScheduleRun = "INSERT INTO scheduled_transfer_runs (schedule_id, run_at, status, error) VALUES ($1, $2, $3, $4) RETURNING id;"
+	GetScheduleRuns = "SELECT id, run_at, status, error FROM scheduled_transfer_runs WHERE schedule_id = $1 ORDER BY id DESC LIMIT $2;"
+	AddPaymentRequest = "INSERT INTO payment_requests (requester_id, payer_id, amount, memo, category, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;"
+	GetPaymentRequest = paymentRequestSelect + " WHERE payment_requests.id = $1;"
+	GetPaymentRequests = paymentRequestSelect + paymentRequestsFilter + " ORDER BY payment_requests.id DESC LIMIT $4 OFFSET $5;"
+	CountPaymentRequests = "SELECT COUNT(*) FROM payment_requests" + paymentRequestsFilter + ";"
+	ClosePaymentRequest = "UPDATE payment_requests SET status = $2, closed_at = NOW() WHERE id = $1 AND status = 'pending';"
+	ExpirePaymentRequests = "UPDATE payment_requests SET status = 'expired', closed_at = $1 WHERE status = 'pending' AND expires_at <= $1 RETURNING id, requester_id, (SELECT username FROM users WHERE users.id = payment_requests.requester_id), payer_id, (SELECT username FROM users WHERE users.id = payment_requests.payer_id), amount, memo, category, status, created_at, expires_at, closed_at;"
+	GetTransferLimits = "SELECT users.username, transfer_limits.max_transfer, transfer_limits.daily_amount, transfer_limits.weekly_amount, transfer_limits.daily_recipients, transfer_limits.updated_by, transfer_limits.updated_at FROM transfer_limits JOIN users ON users.id = transfer_limits.user_id WHERE transfer_limits.user_id = $1;"
+	SetTransferLimits = "INSERT INTO transfer_limits (user_id, max_transfer, daily_amount, weekly_amount, daily_recipients, updated_by) VALUES ($1, $2, $3, $4, $5, $6)" +
+		" ON CONFLICT (user_id) DO UPDATE SET max_transfer = EXCLUDED.max_transfer, daily_amount = EXCLUDED.daily_amount, weekly_amount = EXCLUDED.weekly_amount, daily_recipients = EXCLUDED.daily_recipients, updated_by = EXCLUDED.updated_by, updated_at = NOW();"
+	DeleteTransferLimits = "DELETE FROM transfer_limits WHERE user_id = $1;"
+	// GetTransferUsage sums the coins $1 sent since $3 and counts the
+	// distinct recipients other than $2.
+	GetTransferUsage = "SELECT COALESCE(SUM(amount), 0), COUNT(DISTINCT CASE WHEN to_id <> $2 THEN to_id END) FROM exchanges WHERE from_id = $1 AND created_at >= $3;"
+	// AdjustBalance changes a balance by $2 unless that would take it
+	// below zero.
+	AdjustBalance = "UPDATE users SET balance = balance + $2 WHERE id = $1 AND balance + $2 >= 0 RETURNING balance;"
+	AddBalanceAdjustment = "INSERT INTO balance_adjustments (user_id, actor, kind, amount, balance, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;"
+	GetBalanceAdjustments = balanceAdjustmentSelect + " WHERE ($1::int = 0 OR balance_adjustments.user_id = $1) ORDER BY balance_adjustments.id DESC LIMIT $2 OFFSET $3;"
+	CountBalanceAdjustments = "SELECT COUNT(*) FROM balance_adjustments WHERE ($1::int = 0 OR user_id = $1);"
+	// LockAuditLog serializes appends to the audit log until the
+	// transaction ends.
+	LockAuditLog = "SELECT pg_advisory_xact_lock(hashtext('audit_log'));"
+	AddAuditEvent = "INSERT INTO audit_log (action, actor, target, ip, user_agent, request_id, details, created_at, prev_hash, hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id;"
+	LastAuditEvent = auditEventSelect + " WHERE ($1::text = '' OR action = $1) ORDER BY id DESC LIMIT 1;"
+	GetAuditEvents = auditEventSelect + auditFilter + " AND id > $6 ORDER BY id LIMIT $7;"
+	CountAuditEvents = "SELECT COUNT(*) FROM audit_log" + auditFilter + ";"
+	AddOutboxEvent = "INSERT INTO outbox (event_type, event_key, payload, created_at, next_attempt_at) VALUES ($1, $2, $3, $4, $4) RETURNING id;"
+	// AcquireOutboxLease takes the relay lease for $1 until $3 if it is
+	// free, expired at $2 or already held by $1; no row means another relay
+	// holds it.
+	AcquireOutboxLease = "INSERT INTO outbox_relay_lease (id, owner, expires_at) VALUES (1, $1, $3)" +
+		" ON CONFLICT (id) DO UPDATE SET owner = EXCLUDED.owner, expires_at = EXCLUDED.expires_at WHERE outbox_relay_lease.owner = $1 OR outbox_relay_lease.expires_at <= $2 RETURNING owner;"
+	// GetDueOutboxEvents skips events queued behind an earlier event with
+	// the same key that is waiting for a retry.
+	GetDueOutboxEvents = "SELECT id, event_type, event_key, payload, created_at, attempts, last_error, next_attempt_at FROM outbox" +
+		" WHERE delivered_at IS NULL AND next_attempt_at <= $1" +
+		" AND NOT EXISTS (SELECT 1 FROM outbox AS earlier WHERE earlier.event_key = outbox.event_key AND earlier.delivered_at IS NULL AND earlier.id < outbox.id AND earlier.next_attempt_at > $1)" +
+		" ORDER BY id LIMIT $2;"
+	MarkOutboxDelivered = "UPDATE outbox SET delivered_at = $2 WHERE id = $1;"
+	DeferOutboxEvent = "UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1 AND delivered_at IS NULL;"
+	AddWebhookEndpoint = "INSERT INTO webhook_endpoints (user_id, url, event_types, secret, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
+	GetWebhookEndpoint = webhookEndpointSelect + " WHERE id = $1;"
+	GetWebhookEndpoints = webhookEndpointSelect + " WHERE user_id = $1 ORDER BY id;"
+	DeleteWebhookEndpoint = "DELETE FROM webhook_endpoints WHERE id = $1;"
+	AddWebhookDelivery = "INSERT INTO webhook_deliveries (endpoint_id, event_type, payload, status, next_attempt_at, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;"
+	GetWebhookDelivery = webhookDeliverySelect + " WHERE id = $1;"
+	GetWebhookDeliveries = webhookDeliverySelect + webhookDeliveriesFilter + " ORDER BY id DESC LIMIT $3 OFFSET $4;"
+	CountWebhookDeliveries = "SELECT COUNT(*) FROM webhook_deliveries" + webhookDeliveriesFilter + ";"
+	// ClaimDueWebhookDelivery pushes the earliest due delivery's next attempt
+	// to $2 and returns it, skipping rows another replica is claiming.
+	ClaimDueWebhookDelivery = "UPDATE webhook_deliveries SET next_attempt_at = $2 WHERE id = (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $1 ORDER BY next_attempt_at, id LIMIT 1 FOR UPDATE SKIP LOCKED)" +
+		" RETURNING " + webhookDeliveryColumns + ";"
+	UpdateWebhookDelivery = "UPDATE webhook_deliveries SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5, delivered_at = $6 WHERE id = $1;"
+	AddWebhookAttempt = "INSERT INTO webhook_attempts (delivery_id, attempted_at, status_code, error, duration_ms) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
+	GetWebhookAttempts = "SELECT id, attempted_at, status_code, error, duration_ms FROM webhook_attempts WHERE delivery_id = $1 ORDER BY id;"
+	AddUserEvent = "INSERT INTO user_events (user_id, type, data, created_at) VALUES ($1, $2, $3, $4) RETURNING id;"
+	GetUserEvents = "SELECT id, user_id, type, data, created_at FROM user_events WHERE user_id = $1 AND id > $2 ORDER BY id LIMIT $3;"
+	LastUserEventID = "SELECT COALESCE(MAX(id), 0) FROM user_events WHERE user_id = $1;"
+	DeleteUserEvents = "DELETE FROM user_events WHERE created_at < $1;"
+	AddNotification = "INSERT INTO notifications (user_id, kind, text, created_at) VALUES ($1, $2, $3, $4) RETURNING id;"
+	GetNotifications = "SELECT id, kind, text, created_at, read_at FROM notifications" + notificationsFilter + " ORDER BY id DESC LIMIT $3 OFFSET $4;"
+	CountNotifications = "SELECT COUNT(*) FROM notifications" + notificationsFilter + ";"
+	// MarkNotificationRead keeps the time a notification was first read.
+	MarkNotificationRead = "UPDATE notifications SET read_at = COALESCE(read_at, $3) WHERE id = $2 AND user_id = $1;"
+	MarkAllNotificationsRead = "UPDATE notifications SET read_at = $2 WHERE user_id = $1 AND read_at IS NULL;"
+	GetNotificationPreferences = "SELECT kind, enabled FROM notification_preferences WHERE user_id = $1;"
+	SetNotificationPreference = "INSERT INTO notification_preferences (user_id, kind, enabled) VALUES ($1, $2, $3)" +
+		" ON CONFLICT (user_id, kind) DO UPDATE SET enabled = EXCLUDED.enabled;"
+	GetNotificationsSince = "SELECT id, kind, text, created_at, read_at FROM notifications WHERE user_id = $1 AND created_at > $2 ORDER BY id LIMIT $3;"
+	GetEmailSettings = "SELECT address, mode, digest_sent_at FROM email_settings WHERE user_id = $1;"
+	SetEmailSettings = "INSERT INTO email_settings (user_id, address, mode, digest_sent_at) VALUES ($1, $2, $3, $4)" +
+		" ON CONFLICT (user_id) DO UPDATE SET address = EXCLUDED.address, mode = EXCLUDED.mode, digest_sent_at = EXCLUDED.digest_sent_at;"
+	// GetDueEmailDigests returns the digest mode users whose last digest
+	// went out before $1, longest waiting first.
+	GetDueEmailDigests = "SELECT s.user_id, u.username, s.address, s.digest_sent_at FROM email_settings s JOIN users u ON u.id = s.user_id" +
+		" WHERE s.mode = 'digest' AND s.digest_sent_at < $1 ORDER BY s.digest_sent_at, s.user_id LIMIT $2;"
+	MarkEmailDigestSent = "UPDATE email_settings SET digest_sent_at = $2 WHERE user_id = $1;"
+	AddEmail = "INSERT INTO emails (user_id, address, subject, text_body, html_body, status, next_attempt_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;"
+	// ClaimDueEmail pushes the earliest due email's next attempt to $2
+	// and returns it, skipping rows another replica is claiming.
+	ClaimDueEmail = "UPDATE emails SET next_attempt_at = $2 WHERE id = (SELECT id FROM emails WHERE status = 'pending' AND next_attempt_at <= $1 ORDER BY next_attempt_at, id LIMIT 1 FOR UPDATE SKIP LOCKED)" +
+		" RETURNING " + emailColumns + ";"
+	UpdateEmail = "UPDATE emails SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5, sent_at = $6 WHERE id = $1;"
+)
+
+// transfersFilter selects a user's coin transfers for SearchTransfers and
+// CountTransfers: $1 is the user id, $2 the direction, $3 the category and
+// $4 a LIKE pattern for the memo or the other party's username.
+const transfersFilter = "FROM exchanges JOIN users AS senders ON senders.id = exchanges.from_id JOIN users AS recipients ON recipients.id = exchanges.to_id" +
+	" WHERE ((exchanges.from_id = $1 AND $2::text <> 'received') OR (exchanges.to_id = $1 AND $2::text <> 'sent'))" +
+	" AND ($3::text = '' OR exchanges.category = $3)" +
+	" AND ($4::text = '' OR exchanges.memo ILIKE $4 ESCAPE '\\' OR (exchanges.from_id = $1 AND recipients.username ILIKE $4 ESCAPE '\\') OR (exchanges.to_id = $1 AND senders.username ILIKE $4 ESCAPE '\\'))"
+// scheduleSelect reads scheduled transfers with both parties' usernames.
+const scheduleSelect = "SELECT scheduled_transfers.id, scheduled_transfers.from_id, senders.username, scheduled_transfers.to_id, recipients.username, scheduled_transfers.amount, scheduled_transfers.memo, scheduled_transfers.category, scheduled_transfers.recurrence, scheduled_transfers.next_run_at, scheduled_transfers.anchor_day, scheduled_transfers.status, scheduled_transfers.created_at" +
+	" FROM scheduled_transfers JOIN users AS senders ON senders.id = scheduled_transfers.from_id JOIN users AS recipients ON recipients.id = scheduled_transfers.to_id"
+
+// paymentRequestSelect reads payment requests with both parties' usernames.
+const paymentRequestSelect = "SELECT payment_requests.id, payment_requests.requester_id, requesters.username, payment_requests.payer_id, payers.username, payment_requests.amount, payment_requests.memo, payment_requests.category, payment_requests.status, payment_requests.created_at, payment_requests.expires_at, payment_requests.closed_at" +
+	" FROM payment_requests JOIN users AS requesters ON requesters.id = payment_requests.requester_id JOIN users AS payers ON payers.id = payment_requests.payer_id"
+
+// balanceAdjustmentSelect reads balance adjustments with the username.
+const balanceAdjustmentSelect = "SELECT balance_adjustments.id, balance_adjustments.user_id, users.username, balance_adjustments.actor, balance_adjustments.kind, balance_adjustments.amount, balance_adjustments.balance, balance_adjustments.reason, balance_adjustments.created_at" +
+	" FROM balance_adjustments JOIN users ON users.id = balance_adjustments.user_id"
+
+// auditEventSelect reads audit log entries.
+const auditEventSelect = "SELECT id, action, actor, target, ip, user_agent, request_id, details, created_at, prev_hash, hash FROM audit_log"
+
+// auditFilter selects audit log entries for GetAuditEvents and
+// CountAuditEvents: $1 is the action, $2 the actor, $3 the target and $4
+// and $5 bound created_at. Empty strings and NULLs match everything.
+const auditFilter = " WHERE ($1::text = '' OR action = $1) AND ($2::text = '' OR actor = $2) AND ($3::text = '' OR target = $3)" +
+	" AND ($4::timestamptz IS NULL OR created_at >= $4) AND ($5::timestamptz IS NULL OR created_at < $5)"
+
+// paymentRequestsFilter selects a user's payment requests: $1 is the user
+// id, $2 the direction and $3 the status.
+const paymentRequestsFilter = " WHERE ((payment_requests.requester_id = $1 AND $2::text <> 'incoming') OR (payment_requests.payer_id = $1 AND $2::text <> 'outgoing'))" +
+	" AND ($3::text = '' OR payment_requests.status = $3)"
+
+// webhookEndpointSelect reads webhook endpoints.
+const webhookEndpointSelect = "SELECT id, user_id, url, event_types, secret, created_at FROM webhook_endpoints"
+
+const webhookDeliveryColumns = "id, endpoint_id, event_type, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at"
+
+// webhookDeliverySelect reads webhook deliveries.
+const webhookDeliverySelect = "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries"
+
+// webhookDeliveriesFilter selects an endpoint's deliveries: $1 is the
+// endpoint id and $2 the status, empty for all.
+const webhookDeliveriesFilter = " WHERE endpoint_id = $1 AND ($2::text = '' OR status = $2)"
+
+// notificationsFilter selects a user's notifications: $1 is the user id and
+// $2 whether to select only unread ones.
+const notificationsFilter = " WHERE user_id = $1 AND ($2::boolean = false OR read_at IS NULL)"
+
+const emailColumns = "id, user_id, address, subject, text_body, html_body, status, attempts, last_error, next_attempt_at, created_at, sent_at"
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/KonstantinGalanin/itemStore/internal/repository/sqltx"
+	"github.com/lib/pq"
+)
+
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+	uniqueViolation      = "23505"
+
+	// auditChainConstraint is the unique index on audit_log.prev_hash.
+	auditChainConstraint = "audit_log_prev_hash_key"
+)
+
+// IsRetryable reports serialization failures and deadlocks, after which
+// PostgreSQL expects the whole transaction to be run again. So is an audit
+// event that continued an entry another transaction continued first: its
+// snapshot was older than that append, and a new one will see it.
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	switch pqErr.Code {
+	case serializationFailure, deadlockDetected:
+		return true
+	case uniqueViolation:
+		return pqErr.Constraint == auditChainConstraint
+	}
+
+	return false
+}
+
+func NewTxManager(db *sql.DB) *sqltx.Manager {
+	return sqltx.NewManager(db, IsRetryable)
+}
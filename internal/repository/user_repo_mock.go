@@ -5,7 +5,10 @@
 package repository
 
 import (
+	context "context"
+	sql "database/sql"
 	reflect "reflect"
+	time "time"
 
 	entities "github.com/KonstantinGalanin/itemStore/internal/entities"
 	gomock "github.com/golang/mock/gomock"
@@ -34,135 +37,1539 @@ func (m *MockUserRepo) EXPECT() *MockUserRepoMockRecorder {
 	return m.recorder
 }
 
+// AcquireOutboxLease mocks base method.
+func (m *MockUserRepo) AcquireOutboxLease(ctx context.Context, owner string, now, until time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcquireOutboxLease", ctx, owner, now, until)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcquireOutboxLease indicates an expected call of AcquireOutboxLease.
+func (mr *MockUserRepoMockRecorder) AcquireOutboxLease(ctx, owner, now, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcquireOutboxLease", reflect.TypeOf((*MockUserRepo)(nil).AcquireOutboxLease), ctx, owner, now, until)
+}
+
+// AddAuditEvent mocks base method.
+func (m *MockUserRepo) AddAuditEvent(ctx context.Context, event *entities.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAuditEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAuditEvent indicates an expected call of AddAuditEvent.
+func (mr *MockUserRepoMockRecorder) AddAuditEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAuditEvent", reflect.TypeOf((*MockUserRepo)(nil).AddAuditEvent), ctx, event)
+}
+
+// AddBalanceAdjustment mocks base method.
+func (m *MockUserRepo) AddBalanceAdjustment(ctx context.Context, adjustment *entities.BalanceAdjustment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddBalanceAdjustment", ctx, adjustment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddBalanceAdjustment indicates an expected call of AddBalanceAdjustment.
+func (mr *MockUserRepoMockRecorder) AddBalanceAdjustment(ctx, adjustment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBalanceAdjustment", reflect.TypeOf((*MockUserRepo)(nil).AddBalanceAdjustment), ctx, adjustment)
+}
+
+// AddEmail mocks base method.
+func (m *MockUserRepo) AddEmail(ctx context.Context, email *entities.Email) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddEmail", ctx, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddEmail indicates an expected call of AddEmail.
+func (mr *MockUserRepoMockRecorder) AddEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEmail", reflect.TypeOf((*MockUserRepo)(nil).AddEmail), ctx, email)
+}
+
+// AddLockoutEvent mocks base method.
+func (m *MockUserRepo) AddLockoutEvent(ctx context.Context, event *entities.LockoutEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLockoutEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddLockoutEvent indicates an expected call of AddLockoutEvent.
+func (mr *MockUserRepoMockRecorder) AddLockoutEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLockoutEvent", reflect.TypeOf((*MockUserRepo)(nil).AddLockoutEvent), ctx, event)
+}
+
+// AddNotification mocks base method.
+func (m *MockUserRepo) AddNotification(ctx context.Context, userID int, notification *entities.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddNotification", ctx, userID, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddNotification indicates an expected call of AddNotification.
+func (mr *MockUserRepoMockRecorder) AddNotification(ctx, userID, notification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddNotification", reflect.TypeOf((*MockUserRepo)(nil).AddNotification), ctx, userID, notification)
+}
+
+// AddOutboxEvent mocks base method.
+func (m *MockUserRepo) AddOutboxEvent(ctx context.Context, event *entities.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOutboxEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddOutboxEvent indicates an expected call of AddOutboxEvent.
+func (mr *MockUserRepoMockRecorder) AddOutboxEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOutboxEvent", reflect.TypeOf((*MockUserRepo)(nil).AddOutboxEvent), ctx, event)
+}
+
+// AddRefund mocks base method.
+func (m *MockUserRepo) AddRefund(ctx context.Context, refund *entities.Refund) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRefund", ctx, refund)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRefund indicates an expected call of AddRefund.
+func (mr *MockUserRepoMockRecorder) AddRefund(ctx, refund interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRefund", reflect.TypeOf((*MockUserRepo)(nil).AddRefund), ctx, refund)
+}
+
+// AddScheduleRun mocks base method.
+func (m *MockUserRepo) AddScheduleRun(ctx context.Context, scheduleID int, run *entities.ScheduledTransferRun) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddScheduleRun", ctx, scheduleID, run)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddScheduleRun indicates an expected call of AddScheduleRun.
+func (mr *MockUserRepoMockRecorder) AddScheduleRun(ctx, scheduleID, run interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddScheduleRun", reflect.TypeOf((*MockUserRepo)(nil).AddScheduleRun), ctx, scheduleID, run)
+}
+
+// AddUserEvent mocks base method.
+func (m *MockUserRepo) AddUserEvent(ctx context.Context, event *entities.UserEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUserEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddUserEvent indicates an expected call of AddUserEvent.
+func (mr *MockUserRepoMockRecorder) AddUserEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserEvent", reflect.TypeOf((*MockUserRepo)(nil).AddUserEvent), ctx, event)
+}
+
+// AddWebhookAttempt mocks base method.
+func (m *MockUserRepo) AddWebhookAttempt(ctx context.Context, deliveryID int, attempt *entities.WebhookAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookAttempt", ctx, deliveryID, attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookAttempt indicates an expected call of AddWebhookAttempt.
+func (mr *MockUserRepoMockRecorder) AddWebhookAttempt(ctx, deliveryID, attempt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookAttempt", reflect.TypeOf((*MockUserRepo)(nil).AddWebhookAttempt), ctx, deliveryID, attempt)
+}
+
+// AddWebhookDelivery mocks base method.
+func (m *MockUserRepo) AddWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookDelivery", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookDelivery indicates an expected call of AddWebhookDelivery.
+func (mr *MockUserRepoMockRecorder) AddWebhookDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookDelivery", reflect.TypeOf((*MockUserRepo)(nil).AddWebhookDelivery), ctx, delivery)
+}
+
+// AddWebhookEndpoint mocks base method.
+func (m *MockUserRepo) AddWebhookEndpoint(ctx context.Context, endpoint *entities.WebhookEndpoint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookEndpoint", ctx, endpoint)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookEndpoint indicates an expected call of AddWebhookEndpoint.
+func (mr *MockUserRepoMockRecorder) AddWebhookEndpoint(ctx, endpoint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookEndpoint", reflect.TypeOf((*MockUserRepo)(nil).AddWebhookEndpoint), ctx, endpoint)
+}
+
+// AdjustBalance mocks base method.
+func (m *MockUserRepo) AdjustBalance(ctx context.Context, userID, delta int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", ctx, userID, delta)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockUserRepoMockRecorder) AdjustBalance(ctx, userID, delta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockUserRepo)(nil).AdjustBalance), ctx, userID, delta)
+}
+
 // Auth mocks base method.
-func (m *MockUserRepo) Auth(userName, password string) (*entities.User, error) {
+func (m *MockUserRepo) Auth(ctx context.Context, userName, password string) (*entities.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Auth", userName, password)
+	ret := m.ctrl.Call(m, "Auth", ctx, userName, password)
 	ret0, _ := ret[0].(*entities.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Auth indicates an expected call of Auth.
-func (mr *MockUserRepoMockRecorder) Auth(userName, password interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) Auth(ctx, userName, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth", reflect.TypeOf((*MockUserRepo)(nil).Auth), ctx, userName, password)
+}
+
+// BlockLogin mocks base method.
+func (m *MockUserRepo) BlockLogin(ctx context.Context, userName string, until time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockLogin", ctx, userName, until)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockLogin indicates an expected call of BlockLogin.
+func (mr *MockUserRepoMockRecorder) BlockLogin(ctx, userName, until interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth", reflect.TypeOf((*MockUserRepo)(nil).Auth), userName, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockLogin", reflect.TypeOf((*MockUserRepo)(nil).BlockLogin), ctx, userName, until)
 }
 
 // BuyItem mocks base method.
-func (m *MockUserRepo) BuyItem(userID, itemID int) error {
+func (m *MockUserRepo) BuyItem(ctx context.Context, userID, itemID int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BuyItem", userID, itemID)
+	ret := m.ctrl.Call(m, "BuyItem", ctx, userID, itemID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // BuyItem indicates an expected call of BuyItem.
-func (mr *MockUserRepoMockRecorder) BuyItem(userID, itemID interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) BuyItem(ctx, userID, itemID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockUserRepo)(nil).BuyItem), userID, itemID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockUserRepo)(nil).BuyItem), ctx, userID, itemID)
 }
 
-// GetCoinsInfo mocks base method.
-func (m *MockUserRepo) GetCoinsInfo(userID int) (int, error) {
+// ClaimDueEmail mocks base method.
+func (m *MockUserRepo) ClaimDueEmail(ctx context.Context, now, until time.Time) (*entities.Email, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueEmail", ctx, now, until)
+	ret0, _ := ret[0].(*entities.Email)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueEmail indicates an expected call of ClaimDueEmail.
+func (mr *MockUserRepoMockRecorder) ClaimDueEmail(ctx, now, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueEmail", reflect.TypeOf((*MockUserRepo)(nil).ClaimDueEmail), ctx, now, until)
+}
+
+// ClaimDueSchedule mocks base method.
+func (m *MockUserRepo) ClaimDueSchedule(ctx context.Context, now time.Time) (*entities.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueSchedule", ctx, now)
+	ret0, _ := ret[0].(*entities.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueSchedule indicates an expected call of ClaimDueSchedule.
+func (mr *MockUserRepoMockRecorder) ClaimDueSchedule(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueSchedule", reflect.TypeOf((*MockUserRepo)(nil).ClaimDueSchedule), ctx, now)
+}
+
+// ClaimDueWebhookDelivery mocks base method.
+func (m *MockUserRepo) ClaimDueWebhookDelivery(ctx context.Context, now, until time.Time) (*entities.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueWebhookDelivery", ctx, now, until)
+	ret0, _ := ret[0].(*entities.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueWebhookDelivery indicates an expected call of ClaimDueWebhookDelivery.
+func (mr *MockUserRepoMockRecorder) ClaimDueWebhookDelivery(ctx, now, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueWebhookDelivery", reflect.TypeOf((*MockUserRepo)(nil).ClaimDueWebhookDelivery), ctx, now, until)
+}
+
+// CloseListing mocks base method.
+func (m *MockUserRepo) CloseListing(ctx context.Context, listingID int, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseListing", ctx, listingID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseListing indicates an expected call of CloseListing.
+func (mr *MockUserRepoMockRecorder) CloseListing(ctx, listingID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseListing", reflect.TypeOf((*MockUserRepo)(nil).CloseListing), ctx, listingID, status)
+}
+
+// ClosePaymentRequest mocks base method.
+func (m *MockUserRepo) ClosePaymentRequest(ctx context.Context, requestID int, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClosePaymentRequest", ctx, requestID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClosePaymentRequest indicates an expected call of ClosePaymentRequest.
+func (mr *MockUserRepoMockRecorder) ClosePaymentRequest(ctx, requestID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClosePaymentRequest", reflect.TypeOf((*MockUserRepo)(nil).ClosePaymentRequest), ctx, requestID, status)
+}
+
+// CloseSchedule mocks base method.
+func (m *MockUserRepo) CloseSchedule(ctx context.Context, scheduleID int, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseSchedule", ctx, scheduleID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseSchedule indicates an expected call of CloseSchedule.
+func (mr *MockUserRepoMockRecorder) CloseSchedule(ctx, scheduleID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseSchedule", reflect.TypeOf((*MockUserRepo)(nil).CloseSchedule), ctx, scheduleID, status)
+}
+
+// CountAuditEvents mocks base method.
+func (m *MockUserRepo) CountAuditEvents(ctx context.Context, filter entities.AuditFilter) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCoinsInfo", userID)
+	ret := m.ctrl.Call(m, "CountAuditEvents", ctx, filter)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetCoinsInfo indicates an expected call of GetCoinsInfo.
-func (mr *MockUserRepoMockRecorder) GetCoinsInfo(userID interface{}) *gomock.Call {
+// CountAuditEvents indicates an expected call of CountAuditEvents.
+func (mr *MockUserRepoMockRecorder) CountAuditEvents(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoinsInfo", reflect.TypeOf((*MockUserRepo)(nil).GetCoinsInfo), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountAuditEvents", reflect.TypeOf((*MockUserRepo)(nil).CountAuditEvents), ctx, filter)
 }
 
-// GetInventoryInfo mocks base method.
-func (m *MockUserRepo) GetInventoryInfo(userID int) ([]*entities.Item, error) {
+// CountBalanceAdjustments mocks base method.
+func (m *MockUserRepo) CountBalanceAdjustments(ctx context.Context, userID int) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetInventoryInfo", userID)
-	ret0, _ := ret[0].([]*entities.Item)
+	ret := m.ctrl.Call(m, "CountBalanceAdjustments", ctx, userID)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetInventoryInfo indicates an expected call of GetInventoryInfo.
-func (mr *MockUserRepoMockRecorder) GetInventoryInfo(userID interface{}) *gomock.Call {
+// CountBalanceAdjustments indicates an expected call of CountBalanceAdjustments.
+func (mr *MockUserRepoMockRecorder) CountBalanceAdjustments(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInventoryInfo", reflect.TypeOf((*MockUserRepo)(nil).GetInventoryInfo), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountBalanceAdjustments", reflect.TypeOf((*MockUserRepo)(nil).CountBalanceAdjustments), ctx, userID)
 }
 
-// GetItemID mocks base method.
-func (m *MockUserRepo) GetItemID(itemName string) (int, error) {
+// CountListings mocks base method.
+func (m *MockUserRepo) CountListings(ctx context.Context, itemName string, now time.Time) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetItemID", itemName)
+	ret := m.ctrl.Call(m, "CountListings", ctx, itemName, now)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetItemID indicates an expected call of GetItemID.
-func (mr *MockUserRepoMockRecorder) GetItemID(itemName interface{}) *gomock.Call {
+// CountListings indicates an expected call of CountListings.
+func (mr *MockUserRepoMockRecorder) CountListings(ctx, itemName, now interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemID", reflect.TypeOf((*MockUserRepo)(nil).GetItemID), itemName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountListings", reflect.TypeOf((*MockUserRepo)(nil).CountListings), ctx, itemName, now)
 }
 
-// GetReceiveInfo mocks base method.
-func (m *MockUserRepo) GetReceiveInfo(userID int) ([]*entities.ReceiveOperation, error) {
+// CountNotifications mocks base method.
+func (m *MockUserRepo) CountNotifications(ctx context.Context, userID int, unreadOnly bool) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetReceiveInfo", userID)
-	ret0, _ := ret[0].([]*entities.ReceiveOperation)
+	ret := m.ctrl.Call(m, "CountNotifications", ctx, userID, unreadOnly)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetReceiveInfo indicates an expected call of GetReceiveInfo.
-func (mr *MockUserRepoMockRecorder) GetReceiveInfo(userID interface{}) *gomock.Call {
+// CountNotifications indicates an expected call of CountNotifications.
+func (mr *MockUserRepoMockRecorder) CountNotifications(ctx, userID, unreadOnly interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReceiveInfo", reflect.TypeOf((*MockUserRepo)(nil).GetReceiveInfo), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountNotifications", reflect.TypeOf((*MockUserRepo)(nil).CountNotifications), ctx, userID, unreadOnly)
 }
 
-// GetSentInfo mocks base method.
-func (m *MockUserRepo) GetSentInfo(userID int) ([]*entities.SentOperation, error) {
+// CountOrders mocks base method.
+func (m *MockUserRepo) CountOrders(ctx context.Context, userID int, itemName string) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSentInfo", userID)
-	ret0, _ := ret[0].([]*entities.SentOperation)
+	ret := m.ctrl.Call(m, "CountOrders", ctx, userID, itemName)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetSentInfo indicates an expected call of GetSentInfo.
-func (mr *MockUserRepoMockRecorder) GetSentInfo(userID interface{}) *gomock.Call {
+// CountOrders indicates an expected call of CountOrders.
+func (mr *MockUserRepoMockRecorder) CountOrders(ctx, userID, itemName interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSentInfo", reflect.TypeOf((*MockUserRepo)(nil).GetSentInfo), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOrders", reflect.TypeOf((*MockUserRepo)(nil).CountOrders), ctx, userID, itemName)
 }
 
-// GetUserID mocks base method.
-func (m *MockUserRepo) GetUserID(userName string) (int, error) {
+// CountPaymentRequests mocks base method.
+func (m *MockUserRepo) CountPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserID", userName)
+	ret := m.ctrl.Call(m, "CountPaymentRequests", ctx, userID, filter)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserID indicates an expected call of GetUserID.
-func (mr *MockUserRepoMockRecorder) GetUserID(userName interface{}) *gomock.Call {
+// CountPaymentRequests indicates an expected call of CountPaymentRequests.
+func (mr *MockUserRepoMockRecorder) CountPaymentRequests(ctx, userID, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserID", reflect.TypeOf((*MockUserRepo)(nil).GetUserID), userName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPaymentRequests", reflect.TypeOf((*MockUserRepo)(nil).CountPaymentRequests), ctx, userID, filter)
 }
 
-// SendCoin mocks base method.
-func (m *MockUserRepo) SendCoin(fromUserID, toUserID, amount int) error {
+// CountTransfers mocks base method.
+func (m *MockUserRepo) CountTransfers(ctx context.Context, userID int, filter entities.TransferFilter) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTransfers", ctx, userID, filter)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTransfers indicates an expected call of CountTransfers.
+func (mr *MockUserRepoMockRecorder) CountTransfers(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTransfers", reflect.TypeOf((*MockUserRepo)(nil).CountTransfers), ctx, userID, filter)
+}
+
+// CountWebhookDeliveries mocks base method.
+func (m *MockUserRepo) CountWebhookDeliveries(ctx context.Context, endpointID int, status string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountWebhookDeliveries", ctx, endpointID, status)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountWebhookDeliveries indicates an expected call of CountWebhookDeliveries.
+func (mr *MockUserRepoMockRecorder) CountWebhookDeliveries(ctx, endpointID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountWebhookDeliveries", reflect.TypeOf((*MockUserRepo)(nil).CountWebhookDeliveries), ctx, endpointID, status)
+}
+
+// CreateListing mocks base method.
+func (m *MockUserRepo) CreateListing(ctx context.Context, sellerID, itemID, quantity, price int, expiresAt time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateListing", ctx, sellerID, itemID, quantity, price, expiresAt)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateListing indicates an expected call of CreateListing.
+func (mr *MockUserRepoMockRecorder) CreateListing(ctx, sellerID, itemID, quantity, price, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateListing", reflect.TypeOf((*MockUserRepo)(nil).CreateListing), ctx, sellerID, itemID, quantity, price, expiresAt)
+}
+
+// CreatePaymentRequest mocks base method.
+func (m *MockUserRepo) CreatePaymentRequest(ctx context.Context, request *entities.PaymentRequest) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePaymentRequest", ctx, request)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePaymentRequest indicates an expected call of CreatePaymentRequest.
+func (mr *MockUserRepoMockRecorder) CreatePaymentRequest(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentRequest", reflect.TypeOf((*MockUserRepo)(nil).CreatePaymentRequest), ctx, request)
+}
+
+// CreateSchedule mocks base method.
+func (m *MockUserRepo) CreateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendCoin", fromUserID, toUserID, amount)
+	ret := m.ctrl.Call(m, "CreateSchedule", ctx, schedule)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSchedule indicates an expected call of CreateSchedule.
+func (mr *MockUserRepoMockRecorder) CreateSchedule(ctx, schedule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSchedule", reflect.TypeOf((*MockUserRepo)(nil).CreateSchedule), ctx, schedule)
+}
+
+// DeferOutboxEvent mocks base method.
+func (m *MockUserRepo) DeferOutboxEvent(ctx context.Context, eventID int, lastError string, until time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeferOutboxEvent", ctx, eventID, lastError, until)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SendCoin indicates an expected call of SendCoin.
-func (mr *MockUserRepoMockRecorder) SendCoin(fromUserID, toUserID, amount interface{}) *gomock.Call {
+// DeferOutboxEvent indicates an expected call of DeferOutboxEvent.
+func (mr *MockUserRepoMockRecorder) DeferOutboxEvent(ctx, eventID, lastError, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeferOutboxEvent", reflect.TypeOf((*MockUserRepo)(nil).DeferOutboxEvent), ctx, eventID, lastError, until)
+}
+
+// DeleteTransferLimits mocks base method.
+func (m *MockUserRepo) DeleteTransferLimits(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTransferLimits", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTransferLimits indicates an expected call of DeleteTransferLimits.
+func (mr *MockUserRepoMockRecorder) DeleteTransferLimits(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTransferLimits", reflect.TypeOf((*MockUserRepo)(nil).DeleteTransferLimits), ctx, userID)
+}
+
+// DeleteUserEvents mocks base method.
+func (m *MockUserRepo) DeleteUserEvents(ctx context.Context, before time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserEvents", ctx, before)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserEvents indicates an expected call of DeleteUserEvents.
+func (mr *MockUserRepoMockRecorder) DeleteUserEvents(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserEvents", reflect.TypeOf((*MockUserRepo)(nil).DeleteUserEvents), ctx, before)
+}
+
+// DeleteWebhookEndpoint mocks base method.
+func (m *MockUserRepo) DeleteWebhookEndpoint(ctx context.Context, endpointID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookEndpoint", ctx, endpointID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookEndpoint indicates an expected call of DeleteWebhookEndpoint.
+func (mr *MockUserRepoMockRecorder) DeleteWebhookEndpoint(ctx, endpointID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookEndpoint", reflect.TypeOf((*MockUserRepo)(nil).DeleteWebhookEndpoint), ctx, endpointID)
+}
+
+// ExpireListings mocks base method.
+func (m *MockUserRepo) ExpireListings(ctx context.Context, now time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireListings", ctx, now)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireListings indicates an expected call of ExpireListings.
+func (mr *MockUserRepoMockRecorder) ExpireListings(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireListings", reflect.TypeOf((*MockUserRepo)(nil).ExpireListings), ctx, now)
+}
+
+// ExpirePaymentRequests mocks base method.
+func (m *MockUserRepo) ExpirePaymentRequests(ctx context.Context, now time.Time) ([]*entities.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePaymentRequests", ctx, now)
+	ret0, _ := ret[0].([]*entities.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePaymentRequests indicates an expected call of ExpirePaymentRequests.
+func (mr *MockUserRepoMockRecorder) ExpirePaymentRequests(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePaymentRequests", reflect.TypeOf((*MockUserRepo)(nil).ExpirePaymentRequests), ctx, now)
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockUserRepo) GetAuditEvents(ctx context.Context, filter entities.AuditFilter, afterID, limit int) ([]*entities.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, filter, afterID, limit)
+	ret0, _ := ret[0].([]*entities.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockUserRepoMockRecorder) GetAuditEvents(ctx, filter, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockUserRepo)(nil).GetAuditEvents), ctx, filter, afterID, limit)
+}
+
+// GetBalanceAdjustments mocks base method.
+func (m *MockUserRepo) GetBalanceAdjustments(ctx context.Context, userID, limit, offset int) ([]*entities.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAdjustments", ctx, userID, limit, offset)
+	ret0, _ := ret[0].([]*entities.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAdjustments indicates an expected call of GetBalanceAdjustments.
+func (mr *MockUserRepoMockRecorder) GetBalanceAdjustments(ctx, userID, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAdjustments", reflect.TypeOf((*MockUserRepo)(nil).GetBalanceAdjustments), ctx, userID, limit, offset)
+}
+
+// GetCatalog mocks base method.
+func (m *MockUserRepo) GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCatalog", ctx)
+	ret0, _ := ret[0].([]*entities.CatalogItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCatalog indicates an expected call of GetCatalog.
+func (mr *MockUserRepoMockRecorder) GetCatalog(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCatalog", reflect.TypeOf((*MockUserRepo)(nil).GetCatalog), ctx)
+}
+
+// GetCoinsInfo mocks base method.
+func (m *MockUserRepo) GetCoinsInfo(ctx context.Context, userID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCoinsInfo", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCoinsInfo indicates an expected call of GetCoinsInfo.
+func (mr *MockUserRepoMockRecorder) GetCoinsInfo(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoinsInfo", reflect.TypeOf((*MockUserRepo)(nil).GetCoinsInfo), ctx, userID)
+}
+
+// GetDueEmailDigests mocks base method.
+func (m *MockUserRepo) GetDueEmailDigests(ctx context.Context, before time.Time, limit int) ([]*entities.EmailDigest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueEmailDigests", ctx, before, limit)
+	ret0, _ := ret[0].([]*entities.EmailDigest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueEmailDigests indicates an expected call of GetDueEmailDigests.
+func (mr *MockUserRepoMockRecorder) GetDueEmailDigests(ctx, before, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueEmailDigests", reflect.TypeOf((*MockUserRepo)(nil).GetDueEmailDigests), ctx, before, limit)
+}
+
+// GetDueOutboxEvents mocks base method.
+func (m *MockUserRepo) GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueOutboxEvents", ctx, now, limit)
+	ret0, _ := ret[0].([]*entities.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueOutboxEvents indicates an expected call of GetDueOutboxEvents.
+func (mr *MockUserRepoMockRecorder) GetDueOutboxEvents(ctx, now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueOutboxEvents", reflect.TypeOf((*MockUserRepo)(nil).GetDueOutboxEvents), ctx, now, limit)
+}
+
+// GetEmailSettings mocks base method.
+func (m *MockUserRepo) GetEmailSettings(ctx context.Context, userID int) (*entities.EmailSettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmailSettings", ctx, userID)
+	ret0, _ := ret[0].(*entities.EmailSettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmailSettings indicates an expected call of GetEmailSettings.
+func (mr *MockUserRepoMockRecorder) GetEmailSettings(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailSettings", reflect.TypeOf((*MockUserRepo)(nil).GetEmailSettings), ctx, userID)
+}
+
+// GetInventoryInfo mocks base method.
+func (m *MockUserRepo) GetInventoryInfo(ctx context.Context, userID int) ([]*entities.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInventoryInfo", ctx, userID)
+	ret0, _ := ret[0].([]*entities.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInventoryInfo indicates an expected call of GetInventoryInfo.
+func (mr *MockUserRepoMockRecorder) GetInventoryInfo(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInventoryInfo", reflect.TypeOf((*MockUserRepo)(nil).GetInventoryInfo), ctx, userID)
+}
+
+// GetItemID mocks base method.
+func (m *MockUserRepo) GetItemID(ctx context.Context, itemName string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItemID", ctx, itemName)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItemID indicates an expected call of GetItemID.
+func (mr *MockUserRepoMockRecorder) GetItemID(ctx, itemName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItemID", reflect.TypeOf((*MockUserRepo)(nil).GetItemID), ctx, itemName)
+}
+
+// GetListing mocks base method.
+func (m *MockUserRepo) GetListing(ctx context.Context, listingID int) (*entities.Listing, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetListing", ctx, listingID)
+	ret0, _ := ret[0].(*entities.Listing)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetListing indicates an expected call of GetListing.
+func (mr *MockUserRepoMockRecorder) GetListing(ctx, listingID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetListing", reflect.TypeOf((*MockUserRepo)(nil).GetListing), ctx, listingID)
+}
+
+// GetListings mocks base method.
+func (m *MockUserRepo) GetListings(ctx context.Context, itemName string, now time.Time, limit, offset int) ([]*entities.Listing, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetListings", ctx, itemName, now, limit, offset)
+	ret0, _ := ret[0].([]*entities.Listing)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetListings indicates an expected call of GetListings.
+func (mr *MockUserRepoMockRecorder) GetListings(ctx, itemName, now, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetListings", reflect.TypeOf((*MockUserRepo)(nil).GetListings), ctx, itemName, now, limit, offset)
+}
+
+// GetNotificationPreferences mocks base method.
+func (m *MockUserRepo) GetNotificationPreferences(ctx context.Context, userID int) (entities.NotificationPreferences, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotificationPreferences", ctx, userID)
+	ret0, _ := ret[0].(entities.NotificationPreferences)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotificationPreferences indicates an expected call of GetNotificationPreferences.
+func (mr *MockUserRepoMockRecorder) GetNotificationPreferences(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotificationPreferences", reflect.TypeOf((*MockUserRepo)(nil).GetNotificationPreferences), ctx, userID)
+}
+
+// GetNotifications mocks base method.
+func (m *MockUserRepo) GetNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*entities.Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotifications", ctx, userID, unreadOnly, limit, offset)
+	ret0, _ := ret[0].([]*entities.Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotifications indicates an expected call of GetNotifications.
+func (mr *MockUserRepoMockRecorder) GetNotifications(ctx, userID, unreadOnly, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotifications", reflect.TypeOf((*MockUserRepo)(nil).GetNotifications), ctx, userID, unreadOnly, limit, offset)
+}
+
+// GetNotificationsSince mocks base method.
+func (m *MockUserRepo) GetNotificationsSince(ctx context.Context, userID int, since time.Time, limit int) ([]*entities.Notification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotificationsSince", ctx, userID, since, limit)
+	ret0, _ := ret[0].([]*entities.Notification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotificationsSince indicates an expected call of GetNotificationsSince.
+func (mr *MockUserRepoMockRecorder) GetNotificationsSince(ctx, userID, since, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotificationsSince", reflect.TypeOf((*MockUserRepo)(nil).GetNotificationsSince), ctx, userID, since, limit)
+}
+
+// GetOrders mocks base method.
+func (m *MockUserRepo) GetOrders(ctx context.Context, userID int, itemName string, limit, offset int) ([]*entities.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, userID, itemName, limit, offset)
+	ret0, _ := ret[0].([]*entities.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockUserRepoMockRecorder) GetOrders(ctx, userID, itemName, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockUserRepo)(nil).GetOrders), ctx, userID, itemName, limit, offset)
+}
+
+// GetPaymentRequest mocks base method.
+func (m *MockUserRepo) GetPaymentRequest(ctx context.Context, requestID int) (*entities.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentRequest", ctx, requestID)
+	ret0, _ := ret[0].(*entities.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentRequest indicates an expected call of GetPaymentRequest.
+func (mr *MockUserRepoMockRecorder) GetPaymentRequest(ctx, requestID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentRequest", reflect.TypeOf((*MockUserRepo)(nil).GetPaymentRequest), ctx, requestID)
+}
+
+// GetPaymentRequests mocks base method.
+func (m *MockUserRepo) GetPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter, limit, offset int) ([]*entities.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentRequests", ctx, userID, filter, limit, offset)
+	ret0, _ := ret[0].([]*entities.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentRequests indicates an expected call of GetPaymentRequests.
+func (mr *MockUserRepoMockRecorder) GetPaymentRequests(ctx, userID, filter, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentRequests", reflect.TypeOf((*MockUserRepo)(nil).GetPaymentRequests), ctx, userID, filter, limit, offset)
+}
+
+// GetReceiveInfo mocks base method.
+func (m *MockUserRepo) GetReceiveInfo(ctx context.Context, userID int) ([]*entities.ReceiveOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReceiveInfo", ctx, userID)
+	ret0, _ := ret[0].([]*entities.ReceiveOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReceiveInfo indicates an expected call of GetReceiveInfo.
+func (mr *MockUserRepoMockRecorder) GetReceiveInfo(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReceiveInfo", reflect.TypeOf((*MockUserRepo)(nil).GetReceiveInfo), ctx, userID)
+}
+
+// GetReceivedGifts mocks base method.
+func (m *MockUserRepo) GetReceivedGifts(ctx context.Context, userID int) ([]*entities.ReceivedGift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReceivedGifts", ctx, userID)
+	ret0, _ := ret[0].([]*entities.ReceivedGift)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReceivedGifts indicates an expected call of GetReceivedGifts.
+func (mr *MockUserRepoMockRecorder) GetReceivedGifts(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReceivedGifts", reflect.TypeOf((*MockUserRepo)(nil).GetReceivedGifts), ctx, userID)
+}
+
+// GetReceivedItems mocks base method.
+func (m *MockUserRepo) GetReceivedItems(ctx context.Context, userID int) ([]*entities.ReceivedItemOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReceivedItems", ctx, userID)
+	ret0, _ := ret[0].([]*entities.ReceivedItemOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReceivedItems indicates an expected call of GetReceivedItems.
+func (mr *MockUserRepoMockRecorder) GetReceivedItems(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReceivedItems", reflect.TypeOf((*MockUserRepo)(nil).GetReceivedItems), ctx, userID)
+}
+
+// GetRefundInfo mocks base method.
+func (m *MockUserRepo) GetRefundInfo(ctx context.Context, userID int) ([]*entities.RefundOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefundInfo", ctx, userID)
+	ret0, _ := ret[0].([]*entities.RefundOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefundInfo indicates an expected call of GetRefundInfo.
+func (mr *MockUserRepoMockRecorder) GetRefundInfo(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefundInfo", reflect.TypeOf((*MockUserRepo)(nil).GetRefundInfo), ctx, userID)
+}
+
+// GetRefundableOrders mocks base method.
+func (m *MockUserRepo) GetRefundableOrders(ctx context.Context, userID, itemID int) ([]*entities.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefundableOrders", ctx, userID, itemID)
+	ret0, _ := ret[0].([]*entities.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefundableOrders indicates an expected call of GetRefundableOrders.
+func (mr *MockUserRepoMockRecorder) GetRefundableOrders(ctx, userID, itemID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefundableOrders", reflect.TypeOf((*MockUserRepo)(nil).GetRefundableOrders), ctx, userID, itemID)
+}
+
+// GetSchedule mocks base method.
+func (m *MockUserRepo) GetSchedule(ctx context.Context, scheduleID int) (*entities.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchedule", ctx, scheduleID)
+	ret0, _ := ret[0].(*entities.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchedule indicates an expected call of GetSchedule.
+func (mr *MockUserRepoMockRecorder) GetSchedule(ctx, scheduleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchedule", reflect.TypeOf((*MockUserRepo)(nil).GetSchedule), ctx, scheduleID)
+}
+
+// GetScheduleRuns mocks base method.
+func (m *MockUserRepo) GetScheduleRuns(ctx context.Context, scheduleID, limit int) ([]*entities.ScheduledTransferRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScheduleRuns", ctx, scheduleID, limit)
+	ret0, _ := ret[0].([]*entities.ScheduledTransferRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScheduleRuns indicates an expected call of GetScheduleRuns.
+func (mr *MockUserRepoMockRecorder) GetScheduleRuns(ctx, scheduleID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScheduleRuns", reflect.TypeOf((*MockUserRepo)(nil).GetScheduleRuns), ctx, scheduleID, limit)
+}
+
+// GetSchedules mocks base method.
+func (m *MockUserRepo) GetSchedules(ctx context.Context, userID int) ([]*entities.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchedules", ctx, userID)
+	ret0, _ := ret[0].([]*entities.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchedules indicates an expected call of GetSchedules.
+func (mr *MockUserRepoMockRecorder) GetSchedules(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchedules", reflect.TypeOf((*MockUserRepo)(nil).GetSchedules), ctx, userID)
+}
+
+// GetSentGifts mocks base method.
+func (m *MockUserRepo) GetSentGifts(ctx context.Context, userID int) ([]*entities.SentGift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSentGifts", ctx, userID)
+	ret0, _ := ret[0].([]*entities.SentGift)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSentGifts indicates an expected call of GetSentGifts.
+func (mr *MockUserRepoMockRecorder) GetSentGifts(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSentGifts", reflect.TypeOf((*MockUserRepo)(nil).GetSentGifts), ctx, userID)
+}
+
+// GetSentInfo mocks base method.
+func (m *MockUserRepo) GetSentInfo(ctx context.Context, userID int) ([]*entities.SentOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSentInfo", ctx, userID)
+	ret0, _ := ret[0].([]*entities.SentOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSentInfo indicates an expected call of GetSentInfo.
+func (mr *MockUserRepoMockRecorder) GetSentInfo(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSentInfo", reflect.TypeOf((*MockUserRepo)(nil).GetSentInfo), ctx, userID)
+}
+
+// GetSentItems mocks base method.
+func (m *MockUserRepo) GetSentItems(ctx context.Context, userID int) ([]*entities.SentItemOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSentItems", ctx, userID)
+	ret0, _ := ret[0].([]*entities.SentItemOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSentItems indicates an expected call of GetSentItems.
+func (mr *MockUserRepoMockRecorder) GetSentItems(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSentItems", reflect.TypeOf((*MockUserRepo)(nil).GetSentItems), ctx, userID)
+}
+
+// GetTransferLimits mocks base method.
+func (m *MockUserRepo) GetTransferLimits(ctx context.Context, userID int) (*entities.UserTransferLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferLimits", ctx, userID)
+	ret0, _ := ret[0].(*entities.UserTransferLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferLimits indicates an expected call of GetTransferLimits.
+func (mr *MockUserRepoMockRecorder) GetTransferLimits(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferLimits", reflect.TypeOf((*MockUserRepo)(nil).GetTransferLimits), ctx, userID)
+}
+
+// GetTransferUsage mocks base method.
+func (m *MockUserRepo) GetTransferUsage(ctx context.Context, fromUserID, toUserID int, since time.Time) (*entities.TransferUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferUsage", ctx, fromUserID, toUserID, since)
+	ret0, _ := ret[0].(*entities.TransferUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferUsage indicates an expected call of GetTransferUsage.
+func (mr *MockUserRepoMockRecorder) GetTransferUsage(ctx, fromUserID, toUserID, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferUsage", reflect.TypeOf((*MockUserRepo)(nil).GetTransferUsage), ctx, fromUserID, toUserID, since)
+}
+
+// GetUserEvents mocks base method.
+func (m *MockUserRepo) GetUserEvents(ctx context.Context, userID, afterID, limit int) ([]*entities.UserEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserEvents", ctx, userID, afterID, limit)
+	ret0, _ := ret[0].([]*entities.UserEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserEvents indicates an expected call of GetUserEvents.
+func (mr *MockUserRepoMockRecorder) GetUserEvents(ctx, userID, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserEvents", reflect.TypeOf((*MockUserRepo)(nil).GetUserEvents), ctx, userID, afterID, limit)
+}
+
+// GetUserID mocks base method.
+func (m *MockUserRepo) GetUserID(ctx context.Context, userName string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserID", ctx, userName)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserID indicates an expected call of GetUserID.
+func (mr *MockUserRepoMockRecorder) GetUserID(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserID", reflect.TypeOf((*MockUserRepo)(nil).GetUserID), ctx, userName)
+}
+
+// GetWebhookAttempts mocks base method.
+func (m *MockUserRepo) GetWebhookAttempts(ctx context.Context, deliveryID int) ([]*entities.WebhookAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookAttempts", ctx, deliveryID)
+	ret0, _ := ret[0].([]*entities.WebhookAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookAttempts indicates an expected call of GetWebhookAttempts.
+func (mr *MockUserRepoMockRecorder) GetWebhookAttempts(ctx, deliveryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookAttempts", reflect.TypeOf((*MockUserRepo)(nil).GetWebhookAttempts), ctx, deliveryID)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockUserRepo) GetWebhookDeliveries(ctx context.Context, endpointID int, status string, limit, offset int) ([]*entities.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", ctx, endpointID, status, limit, offset)
+	ret0, _ := ret[0].([]*entities.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockUserRepoMockRecorder) GetWebhookDeliveries(ctx, endpointID, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockUserRepo)(nil).GetWebhookDeliveries), ctx, endpointID, status, limit, offset)
+}
+
+// GetWebhookDelivery mocks base method.
+func (m *MockUserRepo) GetWebhookDelivery(ctx context.Context, deliveryID int) (*entities.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDelivery", ctx, deliveryID)
+	ret0, _ := ret[0].(*entities.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDelivery indicates an expected call of GetWebhookDelivery.
+func (mr *MockUserRepoMockRecorder) GetWebhookDelivery(ctx, deliveryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDelivery", reflect.TypeOf((*MockUserRepo)(nil).GetWebhookDelivery), ctx, deliveryID)
+}
+
+// GetWebhookEndpoint mocks base method.
+func (m *MockUserRepo) GetWebhookEndpoint(ctx context.Context, endpointID int) (*entities.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookEndpoint", ctx, endpointID)
+	ret0, _ := ret[0].(*entities.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookEndpoint indicates an expected call of GetWebhookEndpoint.
+func (mr *MockUserRepoMockRecorder) GetWebhookEndpoint(ctx, endpointID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookEndpoint", reflect.TypeOf((*MockUserRepo)(nil).GetWebhookEndpoint), ctx, endpointID)
+}
+
+// GetWebhookEndpoints mocks base method.
+func (m *MockUserRepo) GetWebhookEndpoints(ctx context.Context, userID int) ([]*entities.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookEndpoints", ctx, userID)
+	ret0, _ := ret[0].([]*entities.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookEndpoints indicates an expected call of GetWebhookEndpoints.
+func (mr *MockUserRepoMockRecorder) GetWebhookEndpoints(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookEndpoints", reflect.TypeOf((*MockUserRepo)(nil).GetWebhookEndpoints), ctx, userID)
+}
+
+// GiftItem mocks base method.
+func (m *MockUserRepo) GiftItem(ctx context.Context, userID, recipientID, itemID int, message string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GiftItem", ctx, userID, recipientID, itemID, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GiftItem indicates an expected call of GiftItem.
+func (mr *MockUserRepoMockRecorder) GiftItem(ctx, userID, recipientID, itemID, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GiftItem", reflect.TypeOf((*MockUserRepo)(nil).GiftItem), ctx, userID, recipientID, itemID, message)
+}
+
+// LastAuditEvent mocks base method.
+func (m *MockUserRepo) LastAuditEvent(ctx context.Context, action string) (*entities.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastAuditEvent", ctx, action)
+	ret0, _ := ret[0].(*entities.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastAuditEvent indicates an expected call of LastAuditEvent.
+func (mr *MockUserRepoMockRecorder) LastAuditEvent(ctx, action interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastAuditEvent", reflect.TypeOf((*MockUserRepo)(nil).LastAuditEvent), ctx, action)
+}
+
+// LastUserEventID mocks base method.
+func (m *MockUserRepo) LastUserEventID(ctx context.Context, userID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastUserEventID", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastUserEventID indicates an expected call of LastUserEventID.
+func (mr *MockUserRepoMockRecorder) LastUserEventID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastUserEventID", reflect.TypeOf((*MockUserRepo)(nil).LastUserEventID), ctx, userID)
+}
+
+// LockAuditLog mocks base method.
+func (m *MockUserRepo) LockAuditLog(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockAuditLog", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockAuditLog indicates an expected call of LockAuditLog.
+func (mr *MockUserRepoMockRecorder) LockAuditLog(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockAuditLog", reflect.TypeOf((*MockUserRepo)(nil).LockAuditLog), ctx)
+}
+
+// LockLoginAttempts mocks base method.
+func (m *MockUserRepo) LockLoginAttempts(ctx context.Context, userName string) (*entities.LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLoginAttempts", ctx, userName)
+	ret0, _ := ret[0].(*entities.LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockLoginAttempts indicates an expected call of LockLoginAttempts.
+func (mr *MockUserRepoMockRecorder) LockLoginAttempts(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLoginAttempts", reflect.TypeOf((*MockUserRepo)(nil).LockLoginAttempts), ctx, userName)
+}
+
+// MarkAllNotificationsRead mocks base method.
+func (m *MockUserRepo) MarkAllNotificationsRead(ctx context.Context, userID int, at time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkAllNotificationsRead", ctx, userID, at)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkAllNotificationsRead indicates an expected call of MarkAllNotificationsRead.
+func (mr *MockUserRepoMockRecorder) MarkAllNotificationsRead(ctx, userID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkAllNotificationsRead", reflect.TypeOf((*MockUserRepo)(nil).MarkAllNotificationsRead), ctx, userID, at)
+}
+
+// MarkEmailDigestSent mocks base method.
+func (m *MockUserRepo) MarkEmailDigestSent(ctx context.Context, userID int, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEmailDigestSent", ctx, userID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkEmailDigestSent indicates an expected call of MarkEmailDigestSent.
+func (mr *MockUserRepoMockRecorder) MarkEmailDigestSent(ctx, userID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEmailDigestSent", reflect.TypeOf((*MockUserRepo)(nil).MarkEmailDigestSent), ctx, userID, at)
+}
+
+// MarkNotificationRead mocks base method.
+func (m *MockUserRepo) MarkNotificationRead(ctx context.Context, userID, notificationID int, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkNotificationRead", ctx, userID, notificationID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkNotificationRead indicates an expected call of MarkNotificationRead.
+func (mr *MockUserRepoMockRecorder) MarkNotificationRead(ctx, userID, notificationID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkNotificationRead", reflect.TypeOf((*MockUserRepo)(nil).MarkNotificationRead), ctx, userID, notificationID, at)
+}
+
+// MarkOutboxDelivered mocks base method.
+func (m *MockUserRepo) MarkOutboxDelivered(ctx context.Context, eventID int, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxDelivered", ctx, eventID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxDelivered indicates an expected call of MarkOutboxDelivered.
+func (mr *MockUserRepoMockRecorder) MarkOutboxDelivered(ctx, eventID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxDelivered", reflect.TypeOf((*MockUserRepo)(nil).MarkOutboxDelivered), ctx, eventID, at)
+}
+
+// RegisterFailedLogin mocks base method.
+func (m *MockUserRepo) RegisterFailedLogin(ctx context.Context, userName string, at, since time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterFailedLogin", ctx, userName, at, since)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegisterFailedLogin indicates an expected call of RegisterFailedLogin.
+func (mr *MockUserRepoMockRecorder) RegisterFailedLogin(ctx, userName, at, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterFailedLogin", reflect.TypeOf((*MockUserRepo)(nil).RegisterFailedLogin), ctx, userName, at, since)
+}
+
+// ResetLoginAttempts mocks base method.
+func (m *MockUserRepo) ResetLoginAttempts(ctx context.Context, userName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetLoginAttempts", ctx, userName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetLoginAttempts indicates an expected call of ResetLoginAttempts.
+func (mr *MockUserRepoMockRecorder) ResetLoginAttempts(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetLoginAttempts", reflect.TypeOf((*MockUserRepo)(nil).ResetLoginAttempts), ctx, userName)
+}
+
+// SearchTransfers mocks base method.
+func (m *MockUserRepo) SearchTransfers(ctx context.Context, userID int, filter entities.TransferFilter, limit, offset int) ([]*entities.CoinTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchTransfers", ctx, userID, filter, limit, offset)
+	ret0, _ := ret[0].([]*entities.CoinTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchTransfers indicates an expected call of SearchTransfers.
+func (mr *MockUserRepoMockRecorder) SearchTransfers(ctx, userID, filter, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchTransfers", reflect.TypeOf((*MockUserRepo)(nil).SearchTransfers), ctx, userID, filter, limit, offset)
+}
+
+// SellListing mocks base method.
+func (m *MockUserRepo) SellListing(ctx context.Context, listingID, buyerID, fee int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SellListing", ctx, listingID, buyerID, fee)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SellListing indicates an expected call of SellListing.
+func (mr *MockUserRepoMockRecorder) SellListing(ctx, listingID, buyerID, fee interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SellListing", reflect.TypeOf((*MockUserRepo)(nil).SellListing), ctx, listingID, buyerID, fee)
+}
+
+// SendCoin mocks base method.
+func (m *MockUserRepo) SendCoin(ctx context.Context, fromUserID, toUserID, amount int, memo, category string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendCoin", ctx, fromUserID, toUserID, amount, memo, category)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendCoin indicates an expected call of SendCoin.
+func (mr *MockUserRepoMockRecorder) SendCoin(ctx, fromUserID, toUserID, amount, memo, category interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockUserRepo)(nil).SendCoin), ctx, fromUserID, toUserID, amount, memo, category)
+}
+
+// SetEmailSettings mocks base method.
+func (m *MockUserRepo) SetEmailSettings(ctx context.Context, userID int, settings *entities.EmailSettings) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetEmailSettings", ctx, userID, settings)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetEmailSettings indicates an expected call of SetEmailSettings.
+func (mr *MockUserRepoMockRecorder) SetEmailSettings(ctx, userID, settings interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmailSettings", reflect.TypeOf((*MockUserRepo)(nil).SetEmailSettings), ctx, userID, settings)
+}
+
+// SetItemPrice mocks base method.
+func (m *MockUserRepo) SetItemPrice(ctx context.Context, itemName string, price int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetItemPrice", ctx, itemName, price)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetItemPrice indicates an expected call of SetItemPrice.
+func (mr *MockUserRepoMockRecorder) SetItemPrice(ctx, itemName, price interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetItemPrice", reflect.TypeOf((*MockUserRepo)(nil).SetItemPrice), ctx, itemName, price)
+}
+
+// SetNotificationPreference mocks base method.
+func (m *MockUserRepo) SetNotificationPreference(ctx context.Context, userID int, kind string, enabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetNotificationPreference", ctx, userID, kind, enabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetNotificationPreference indicates an expected call of SetNotificationPreference.
+func (mr *MockUserRepoMockRecorder) SetNotificationPreference(ctx, userID, kind, enabled interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNotificationPreference", reflect.TypeOf((*MockUserRepo)(nil).SetNotificationPreference), ctx, userID, kind, enabled)
+}
+
+// SetTransferLimits mocks base method.
+func (m *MockUserRepo) SetTransferLimits(ctx context.Context, userID int, limits entities.TransferLimits, actor string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTransferLimits", ctx, userID, limits, actor)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTransferLimits indicates an expected call of SetTransferLimits.
+func (mr *MockUserRepoMockRecorder) SetTransferLimits(ctx, userID, limits, actor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTransferLimits", reflect.TypeOf((*MockUserRepo)(nil).SetTransferLimits), ctx, userID, limits, actor)
+}
+
+// SetUserRole mocks base method.
+func (m *MockUserRepo) SetUserRole(ctx context.Context, userName, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRole", ctx, userName, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserRole indicates an expected call of SetUserRole.
+func (mr *MockUserRepoMockRecorder) SetUserRole(ctx, userName, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRole", reflect.TypeOf((*MockUserRepo)(nil).SetUserRole), ctx, userName, role)
+}
+
+// TransferItem mocks base method.
+func (m *MockUserRepo) TransferItem(ctx context.Context, fromUserID, toUserID, itemID, quantity int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferItem", ctx, fromUserID, toUserID, itemID, quantity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransferItem indicates an expected call of TransferItem.
+func (mr *MockUserRepoMockRecorder) TransferItem(ctx, fromUserID, toUserID, itemID, quantity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferItem", reflect.TypeOf((*MockUserRepo)(nil).TransferItem), ctx, fromUserID, toUserID, itemID, quantity)
+}
+
+// UpdateEmail mocks base method.
+func (m *MockUserRepo) UpdateEmail(ctx context.Context, email *entities.Email) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEmail", ctx, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEmail indicates an expected call of UpdateEmail.
+func (mr *MockUserRepoMockRecorder) UpdateEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEmail", reflect.TypeOf((*MockUserRepo)(nil).UpdateEmail), ctx, email)
+}
+
+// UpdateSchedule mocks base method.
+func (m *MockUserRepo) UpdateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSchedule", ctx, schedule)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSchedule indicates an expected call of UpdateSchedule.
+func (mr *MockUserRepoMockRecorder) UpdateSchedule(ctx, schedule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSchedule", reflect.TypeOf((*MockUserRepo)(nil).UpdateSchedule), ctx, schedule)
+}
+
+// UpdateWebhookDelivery mocks base method.
+func (m *MockUserRepo) UpdateWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhookDelivery", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhookDelivery indicates an expected call of UpdateWebhookDelivery.
+func (mr *MockUserRepoMockRecorder) UpdateWebhookDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhookDelivery", reflect.TypeOf((*MockUserRepo)(nil).UpdateWebhookDelivery), ctx, delivery)
+}
+
+// MockNotifier is a mock of Notifier interface.
+type MockNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierMockRecorder
+}
+
+// MockNotifierMockRecorder is the mock recorder for MockNotifier.
+type MockNotifierMockRecorder struct {
+	mock *MockNotifier
+}
+
+// NewMockNotifier creates a new mock instance.
+func NewMockNotifier(ctrl *gomock.Controller) *MockNotifier {
+	mock := &MockNotifier{ctrl: ctrl}
+	mock.recorder = &MockNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
+	return m.recorder
+}
+
+// Notify mocks base method.
+func (m *MockNotifier) Notify(ctx context.Context, notification *entities.Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Notify", ctx, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Notify indicates an expected call of Notify.
+func (mr *MockNotifierMockRecorder) Notify(ctx, notification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockNotifier)(nil).Notify), ctx, notification)
+}
+
+// MockTxManager is a mock of TxManager interface.
+type MockTxManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxManagerMockRecorder
+}
+
+// MockTxManagerMockRecorder is the mock recorder for MockTxManager.
+type MockTxManagerMockRecorder struct {
+	mock *MockTxManager
+}
+
+// NewMockTxManager creates a new mock instance.
+func NewMockTxManager(ctrl *gomock.Controller) *MockTxManager {
+	mock := &MockTxManager{ctrl: ctrl}
+	mock.recorder = &MockTxManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTxManager) EXPECT() *MockTxManagerMockRecorder {
+	return m.recorder
+}
+
+// WithinTx mocks base method.
+func (m *MockTxManager) WithinTx(ctx context.Context, opts *sql.TxOptions, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithinTx", ctx, opts, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithinTx indicates an expected call of WithinTx.
+func (mr *MockTxManagerMockRecorder) WithinTx(ctx, opts, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockUserRepo)(nil).SendCoin), fromUserID, toUserID, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithinTx", reflect.TypeOf((*MockTxManager)(nil).WithinTx), ctx, opts, fn)
 }
@@ -3,23 +3,89 @@ package router
 import (
 	"net/http"
 
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
 	"github.com/KonstantinGalanin/itemStore/internal/handlers"
 	"github.com/KonstantinGalanin/itemStore/internal/middleware"
+	"github.com/KonstantinGalanin/itemStore/internal/openapi"
 
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(userHandler *handlers.UserHandler) http.Handler {
+func NewRouter(userHandler *handlers.UserHandler, adminHandler *handlers.AdminHandler, auditHandler *handlers.AuditHandler) http.Handler {
 	r := mux.NewRouter()
+	r.Use(middleware.RequestInfo)
 
+	// Requests are checked against the OpenAPI document before they
+	// reach the handlers.
 	api := r.PathPrefix("/api").Subrouter()
+	api.Use(openapi.NewValidator(openapi.MustLoad()).Middleware)
+	api.HandleFunc("/openapi.json", openapi.ServeSpec).Methods(http.MethodGet)
+	api.HandleFunc("/docs", openapi.ServeDocs).Methods(http.MethodGet)
 	api.HandleFunc("/auth", userHandler.Auth).Methods(http.MethodPost)
 
+	// Browsers cannot set headers on EventSource and WebSocket requests,
+	// so the event stream also takes a stream token from the query string.
+	api.Handle("/events", middleware.StreamAuth(http.HandlerFunc(userHandler.GetEvents))).Methods(http.MethodGet)
+
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(middleware.AuthMiddleware)
 	protected.HandleFunc("/info", userHandler.GetInfo).Methods(http.MethodGet)
+	protected.HandleFunc("/events/token", userHandler.CreateStreamToken).Methods(http.MethodPost)
+	protected.HandleFunc("/items", userHandler.GetCatalog).Methods(http.MethodGet)
 	protected.HandleFunc("/sendCoin", userHandler.SendCoin).Methods(http.MethodPost)
+	protected.HandleFunc("/sendCoin/batch", userHandler.BatchSendCoin).Methods(http.MethodPost)
 	protected.HandleFunc("/buy/{item}", userHandler.BuyItem).Methods(http.MethodPost)
+	protected.HandleFunc("/refund/{item}", userHandler.Refund).Methods(http.MethodPost)
+	protected.HandleFunc("/transfer/{item}", userHandler.TransferItem).Methods(http.MethodPost)
+	protected.HandleFunc("/orders", userHandler.GetOrders).Methods(http.MethodGet)
+	protected.HandleFunc("/history", userHandler.GetHistory).Methods(http.MethodGet)
+	protected.HandleFunc("/market/listings", userHandler.GetListings).Methods(http.MethodGet)
+	protected.HandleFunc("/market/listings", userHandler.CreateListing).Methods(http.MethodPost)
+	protected.HandleFunc("/market/listings/{id}/buy", userHandler.BuyListing).Methods(http.MethodPost)
+	protected.HandleFunc("/market/listings/{id}", userHandler.CancelListing).Methods(http.MethodDelete)
+	protected.HandleFunc("/schedules", userHandler.GetSchedules).Methods(http.MethodGet)
+	protected.HandleFunc("/schedules", userHandler.CreateSchedule).Methods(http.MethodPost)
+	protected.HandleFunc("/schedules/{id}", userHandler.GetSchedule).Methods(http.MethodGet)
+	protected.HandleFunc("/schedules/{id}", userHandler.UpdateSchedule).Methods(http.MethodPut)
+	protected.HandleFunc("/schedules/{id}", userHandler.CancelSchedule).Methods(http.MethodDelete)
+	protected.HandleFunc("/paymentRequests", userHandler.GetPaymentRequests).Methods(http.MethodGet)
+	protected.HandleFunc("/paymentRequests", userHandler.RequestPayment).Methods(http.MethodPost)
+	protected.HandleFunc("/paymentRequests/{id}/accept", userHandler.AcceptPaymentRequest).Methods(http.MethodPost)
+	protected.HandleFunc("/paymentRequests/{id}/decline", userHandler.DeclinePaymentRequest).Methods(http.MethodPost)
+	protected.HandleFunc("/paymentRequests/{id}", userHandler.CancelPaymentRequest).Methods(http.MethodDelete)
+	protected.HandleFunc("/webhooks", userHandler.GetWebhooks).Methods(http.MethodGet)
+	protected.HandleFunc("/webhooks", userHandler.CreateWebhook).Methods(http.MethodPost)
+	protected.HandleFunc("/webhooks/{id}", userHandler.DeleteWebhook).Methods(http.MethodDelete)
+	protected.HandleFunc("/webhooks/{id}/test", userHandler.SendTestWebhook).Methods(http.MethodPost)
+	protected.HandleFunc("/webhooks/{id}/deliveries", userHandler.GetWebhookDeliveries).Methods(http.MethodGet)
+	protected.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}", userHandler.GetWebhookDelivery).Methods(http.MethodGet)
+	protected.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/redeliver", userHandler.RedeliverWebhook).Methods(http.MethodPost)
+	protected.HandleFunc("/notifications", userHandler.GetNotifications).Methods(http.MethodGet)
+	protected.HandleFunc("/notifications/read", userHandler.MarkAllNotificationsRead).Methods(http.MethodPost)
+	protected.HandleFunc("/notifications/preferences", userHandler.GetNotificationPreferences).Methods(http.MethodGet)
+	protected.HandleFunc("/notifications/preferences", userHandler.SetNotificationPreferences).Methods(http.MethodPut)
+	protected.HandleFunc("/notifications/email", userHandler.GetEmailSettings).Methods(http.MethodGet)
+	protected.HandleFunc("/notifications/email", userHandler.SetEmailSettings).Methods(http.MethodPut)
+	protected.HandleFunc("/notifications/{id}/read", userHandler.MarkNotificationRead).Methods(http.MethodPost)
+
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireRole(entities.RoleAdmin))
+	admin.HandleFunc("/users/{username}/unlock", adminHandler.UnlockUser).Methods(http.MethodPost)
+	admin.HandleFunc("/users/{username}/refund/{item}", adminHandler.ForceRefund).Methods(http.MethodPost)
+	admin.HandleFunc("/users/{username}/limits", adminHandler.GetTransferLimits).Methods(http.MethodGet)
+	admin.HandleFunc("/users/{username}/limits", adminHandler.SetTransferLimits).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{username}/limits", adminHandler.ClearTransferLimits).Methods(http.MethodDelete)
+	admin.HandleFunc("/users/{username}/clawback", adminHandler.ClawbackCoins).Methods(http.MethodPost)
+	admin.HandleFunc("/users/{username}/balance", adminHandler.SetBalance).Methods(http.MethodPut)
+	admin.HandleFunc("/coins/grant", adminHandler.GrantCoins).Methods(http.MethodPost)
+	admin.HandleFunc("/adjustments", adminHandler.GetBalanceAdjustments).Methods(http.MethodGet)
+	admin.HandleFunc("/items/{item}", adminHandler.SetItemPrice).Methods(http.MethodPut)
+
+	auditor := protected.PathPrefix("/audit").Subrouter()
+	auditor.Use(middleware.RequireRole(entities.RoleAuditor))
+	auditor.HandleFunc("/events", auditHandler.GetAuditEvents).Methods(http.MethodGet)
+	auditor.HandleFunc("/export", auditHandler.ExportAuditEvents).Methods(http.MethodGet)
+	auditor.HandleFunc("/verify", auditHandler.VerifyAuditLog).Methods(http.MethodGet)
 	
 	return r
 }
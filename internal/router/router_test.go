@@ -0,0 +1,165 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/handlers"
+	"github.com/KonstantinGalanin/itemStore/internal/middleware"
+	"github.com/KonstantinGalanin/itemStore/internal/openapi"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/pkg/jwt"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoutesMatchSpec fails when a route is added without documenting it,
+// or the document describes a route that does not exist.
+func TestRoutesMatchSpec(t *testing.T) {
+	r := NewRouter(handlers.NewUserHandler(nil, nil), handlers.NewAdminHandler(nil), handlers.NewAuditHandler(nil)).(*mux.Router)
+
+	var routed []string
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Subrouter prefixes have no methods of their own.
+			return nil
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routed = append(routed, method+" "+template)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var documented []string
+	for path, operations := range openapi.MustLoad().Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	slices.Sort(routed)
+	slices.Sort(documented)
+	assert.Equal(t, routed, documented)
+}
+
+func TestRouterValidatesRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userService := service.NewMockUserService(ctrl)
+	r := NewRouter(handlers.NewUserHandler(userService, jwt.NewJwtService()), handlers.NewAdminHandler(nil), handlers.NewAuditHandler(nil))
+
+	resp, err := jwt.NewJwtService().CreateToken(&entities.User{Username: "alice", Role: entities.RoleUser})
+	require.NoError(t, err)
+	var token struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(resp, &token))
+
+	sendCoin := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		w := sendCoin(`{"toUser":"bob","amount":"ten"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"errors":"body.amount must be an integer"}`, w.Body.String())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		userService.EXPECT().SendCoin(gomock.Any(), "alice", "bob", 10, "", "").Return(nil)
+
+		w := sendCoin(`{"toUser":"bob","amount":10}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("spec served", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, json.Valid(w.Body.Bytes()))
+	})
+}
+
+func TestStreamToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userService := service.NewMockUserService(ctrl)
+	r := NewRouter(handlers.NewUserHandler(userService, jwt.NewJwtService()), handlers.NewAdminHandler(nil), handlers.NewAuditHandler(nil))
+
+	resp, err := jwt.NewJwtService().CreateToken(&entities.User{Username: "alice", Role: entities.RoleUser})
+	require.NoError(t, err)
+	var login struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(resp, &login))
+
+	serve := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/api/events/token", login.Token)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stream struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stream))
+
+	t.Run("opens the event stream", func(t *testing.T) {
+		userService.EXPECT().SubscribeEvents(gomock.Any(), "alice").Return(nil, nil, errors.New("hub closed"))
+
+		w := serve(http.MethodGet, "/api/events?access_token="+stream.Token, "")
+		assert.NotEqual(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("login token not accepted in the query", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/events?access_token="+login.Token, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("not accepted elsewhere", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/info", stream.Token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = serve(http.MethodPost, "/api/events/token", stream.Token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("removed from the request", func(t *testing.T) {
+		var seen *http.Request
+		handler := middleware.StreamAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = r
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/events?lastEventId=3&access_token="+stream.Token, nil))
+		require.NotNil(t, seen)
+		assert.Equal(t, "lastEventId=3", seen.URL.RawQuery)
+		assert.Equal(t, "/api/events?lastEventId=3", seen.RequestURI)
+		assert.Equal(t, "alice", seen.Context().Value("user"))
+	})
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	// MaxReasonLength is the longest reason an admin may give for a
+	// balance adjustment, in characters.
+	MaxReasonLength = 200
+	// MaxGrantUsers is the most users a single grant may go to.
+	MaxGrantUsers = 1000
+
+	DefaultAdjustmentsLimit = 20
+	MaxAdjustmentsLimit     = 100
+)
+
+// GrantCoins gives amount coins to every user in userNames, e.g. a
+// quarterly bonus for a team. The grant succeeds or fails as a whole and
+// every user's adjustment is recorded with adminName and reason.
+func (u *UserService) GrantCoins(ctx context.Context, adminName string, userNames []string, amount int, reason string) ([]*entities.BalanceAdjustment, error) {
+	if len(userNames) == 0 {
+		return nil, utils.ErrNoUsers
+	}
+	if len(userNames) > MaxGrantUsers {
+		return nil, utils.ErrTooManyUsers
+	}
+	if amount <= 0 {
+		return nil, utils.ErrBadAmount
+	}
+	reason, err := adjustmentReason(reason)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(userNames))
+	for i, userName := range userNames {
+		if seen[userName] {
+			return nil, fmt.Errorf("users[%d]: %w", i, utils.ErrDuplicateRecipient)
+		}
+		seen[userName] = true
+	}
+
+	adjustments := make([]*entities.BalanceAdjustment, 0, len(userNames))
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		adjustments = adjustments[:0]
+		for _, userName := range userNames {
+			userID, err := u.UserRepo.GetUserID(ctx, userName)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", userName, err)
+			}
+
+			adjustment, err := u.adjustBalance(ctx, adminName, userID, userName, entities.AdjustmentGrant, amount, reason)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", userName, err)
+			}
+			adjustments = append(adjustments, adjustment)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, adjustment := range adjustments {
+		u.notifyAdjustment(ctx, adjustment, fmt.Sprintf("%s granted you %d coins", adminName, amount))
+	}
+
+	return adjustments, nil
+}
+
+// ClawbackCoins takes amount coins back from userName. It fails with
+// utils.ErrNotEnoughBalance rather than leave a negative balance.
+func (u *UserService) ClawbackCoins(ctx context.Context, adminName, userName string, amount int, reason string) (*entities.BalanceAdjustment, error) {
+	if amount <= 0 {
+		return nil, utils.ErrBadAmount
+	}
+	reason, err := adjustmentReason(reason)
+	if err != nil {
+		return nil, err
+	}
+
+	var adjustment *entities.BalanceAdjustment
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		adjustment, err = u.adjustBalance(ctx, adminName, userID, userName, entities.AdjustmentClawback, -amount, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.notifyAdjustment(ctx, adjustment, fmt.Sprintf("%s took back %d coins", adminName, amount))
+
+	return adjustment, nil
+}
+
+// SetBalance sets userName's balance to balance, recording the difference.
+func (u *UserService) SetBalance(ctx context.Context, adminName, userName string, balance int, reason string) (*entities.BalanceAdjustment, error) {
+	if balance < 0 {
+		return nil, utils.ErrBadBalance
+	}
+	reason, err := adjustmentReason(reason)
+	if err != nil {
+		return nil, err
+	}
+
+	var adjustment *entities.BalanceAdjustment
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+		current, err := u.UserRepo.GetCoinsInfo(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		adjustment, err = u.adjustBalance(ctx, adminName, userID, userName, entities.AdjustmentSet, balance-current, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.notifyAdjustment(ctx, adjustment, fmt.Sprintf("%s set your balance to %d coins", adminName, balance))
+
+	return adjustment, nil
+}
+
+// GetBalanceAdjustments returns a page of the balance adjustments made to
+// userName, or to everyone if userName is empty, newest first.
+func (u *UserService) GetBalanceAdjustments(ctx context.Context, adminName, userName string, limit, offset int) (*entities.BalanceAdjustmentsPage, error) {
+	if limit < 0 || offset < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if limit == 0 {
+		limit = DefaultAdjustmentsLimit
+	}
+	limit = min(limit, MaxAdjustmentsLimit)
+
+	page := &entities.BalanceAdjustmentsPage{
+		Limit:  limit,
+		Offset: offset,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID := 0
+		if userName != "" {
+			var err error
+			if userID, err = u.UserRepo.GetUserID(ctx, userName); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if page.Total, err = u.UserRepo.CountBalanceAdjustments(ctx, userID); err != nil {
+			return err
+		}
+
+		page.Adjustments, err = u.UserRepo.GetBalanceAdjustments(ctx, userID, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// adjustmentAudits is the audit action recorded for each kind of balance
+// adjustment.
+var adjustmentAudits = map[string]string{
+	entities.AdjustmentGrant:    entities.AuditCoinsGranted,
+	entities.AdjustmentClawback: entities.AuditCoinsClawedBack,
+	entities.AdjustmentSet:      entities.AuditBalanceSet,
+}
+
+// adjustBalance changes a user's balance by delta and records and audits
+// the change. It must run inside the caller's transaction.
+func (u *UserService) adjustBalance(ctx context.Context, adminName string, userID int, userName, kind string, delta int, reason string) (*entities.BalanceAdjustment, error) {
+	balance, err := u.UserRepo.AdjustBalance(ctx, userID, delta)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustment := &entities.BalanceAdjustment{
+		UserID:    userID,
+		Username:  userName,
+		Actor:     adminName,
+		Kind:      kind,
+		Amount:    delta,
+		Balance:   balance,
+		Reason:    reason,
+		CreatedAt: u.Now(),
+	}
+	if err := u.UserRepo.AddBalanceAdjustment(ctx, adjustment); err != nil {
+		return nil, err
+	}
+	err = u.auditTx(ctx, adjustmentAudits[kind], adminName, userName,
+		fmt.Sprintf("amount=%d balance=%d reason=%s", delta, balance, reason))
+	if err != nil {
+		return nil, err
+	}
+	if err := u.push(ctx, userID, entities.StreamBalanceChanged, entities.BalanceChangedEvent{Balance: balance}); err != nil {
+		return nil, err
+	}
+
+	return adjustment, nil
+}
+
+// adjustmentReason validates the reason given for a balance adjustment and
+// returns it in the form it is stored.
+func adjustmentReason(reason string) (string, error) {
+	reason = sanitizeMemo(reason)
+	if reason == "" {
+		return "", utils.ErrReasonRequired
+	}
+	if utf8.RuneCountInString(reason) > MaxReasonLength {
+		return "", utils.ErrReasonTooLong
+	}
+
+	return reason, nil
+}
+
+func (u *UserService) notifyAdjustment(ctx context.Context, adjustment *entities.BalanceAdjustment, text string) {
+	u.notify(ctx, &entities.Notification{
+		Recipient: adjustment.Username,
+		Kind:      entities.NotificationBalanceAdjusted,
+		Text:      text + ": " + adjustment.Reason,
+	})
+}
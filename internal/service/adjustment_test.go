@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantCoins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	notifier := &recordingNotifier{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Notifier = notifier
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().AdjustBalance(gomock.Any(), 1, 100).Return(1100, nil)
+		mockRepo.EXPECT().AdjustBalance(gomock.Any(), 2, 100).Return(300, nil)
+		mockRepo.EXPECT().AddBalanceAdjustment(gomock.Any(), &entities.BalanceAdjustment{
+			UserID: 1, Username: "alice", Actor: "admin", Kind: entities.AdjustmentGrant, Amount: 100, Balance: 1100, Reason: "Q1 bonus", CreatedAt: now,
+		}).Return(nil)
+		mockRepo.EXPECT().AddBalanceAdjustment(gomock.Any(), &entities.BalanceAdjustment{
+			UserID: 2, Username: "bob", Actor: "admin", Kind: entities.AdjustmentGrant, Amount: 100, Balance: 300, Reason: "Q1 bonus", CreatedAt: now,
+		}).Return(nil)
+
+		adjustments, err := userService.GrantCoins(context.Background(), "admin", []string{"alice", "bob"}, 100, "  Q1\nbonus ")
+		assert.NoError(t, err)
+		assert.Len(t, adjustments, 2)
+		assert.Equal(t, []*entities.Notification{
+			{Recipient: "alice", Kind: entities.NotificationBalanceAdjusted, Text: "admin granted you 100 coins: Q1 bonus"},
+			{Recipient: "bob", Kind: entities.NotificationBalanceAdjusted, Text: "admin granted you 100 coins: Q1 bonus"},
+		}, notifier.notifications)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "ghost").Return(0, utils.ErrNoUser)
+
+		_, err := userService.GrantCoins(context.Background(), "admin", []string{"ghost"}, 100, "prize")
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+		assert.Contains(t, err.Error(), "ghost")
+	})
+
+	t.Run("invalid grant", func(t *testing.T) {
+		_, err := userService.GrantCoins(context.Background(), "admin", nil, 100, "prize")
+		assert.True(t, errors.Is(err, utils.ErrNoUsers))
+
+		_, err = userService.GrantCoins(context.Background(), "admin", []string{"alice"}, 0, "prize")
+		assert.True(t, errors.Is(err, utils.ErrBadAmount))
+
+		_, err = userService.GrantCoins(context.Background(), "admin", []string{"alice", "alice"}, 10, "prize")
+		assert.True(t, errors.Is(err, utils.ErrDuplicateRecipient))
+
+		_, err = userService.GrantCoins(context.Background(), "admin", []string{"alice"}, 10, " \t")
+		assert.True(t, errors.Is(err, utils.ErrReasonRequired))
+
+		_, err = userService.GrantCoins(context.Background(), "admin", []string{"alice"}, 10, strings.Repeat("a", MaxReasonLength+1))
+		assert.True(t, errors.Is(err, utils.ErrReasonTooLong))
+	})
+}
+
+func TestClawbackCoins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().AdjustBalance(gomock.Any(), 2, -40).Return(60, nil)
+		mockRepo.EXPECT().AddBalanceAdjustment(gomock.Any(), gomock.Any()).Return(nil)
+
+		adjustment, err := userService.ClawbackCoins(context.Background(), "admin", "bob", 40, "duplicate prize")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.AdjustmentClawback, adjustment.Kind)
+		assert.Equal(t, -40, adjustment.Amount)
+		assert.Equal(t, 60, adjustment.Balance)
+	})
+
+	t.Run("more than the balance", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().AdjustBalance(gomock.Any(), 2, -500).Return(0, utils.ErrNotEnoughBalance)
+
+		_, err := userService.ClawbackCoins(context.Background(), "admin", "bob", 500, "duplicate prize")
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+	})
+}
+
+func TestSetBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 2).Return(700, nil)
+		mockRepo.EXPECT().AdjustBalance(gomock.Any(), 2, -200).Return(500, nil)
+		mockRepo.EXPECT().AddBalanceAdjustment(gomock.Any(), gomock.Any()).Return(nil)
+
+		adjustment, err := userService.SetBalance(context.Background(), "admin", "bob", 500, "support ticket 42")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.AdjustmentSet, adjustment.Kind)
+		assert.Equal(t, -200, adjustment.Amount)
+		assert.Equal(t, 500, adjustment.Balance)
+	})
+
+	t.Run("negative balance", func(t *testing.T) {
+		_, err := userService.SetBalance(context.Background(), "admin", "bob", -1, "support ticket 42")
+		assert.True(t, errors.Is(err, utils.ErrBadBalance))
+	})
+}
+
+func TestGetBalanceAdjustments(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("everyone", func(t *testing.T) {
+		adjustments := []*entities.BalanceAdjustment{{ID: 3, Username: "bob"}}
+
+		mockRepo.EXPECT().CountBalanceAdjustments(gomock.Any(), 0).Return(1, nil)
+		mockRepo.EXPECT().GetBalanceAdjustments(gomock.Any(), 0, DefaultAdjustmentsLimit, 0).Return(adjustments, nil)
+
+		page, err := userService.GetBalanceAdjustments(context.Background(), "admin", "", 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.BalanceAdjustmentsPage{Adjustments: adjustments, Total: 1, Limit: DefaultAdjustmentsLimit}, page)
+	})
+
+	t.Run("one user", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().CountBalanceAdjustments(gomock.Any(), 2).Return(0, nil)
+		mockRepo.EXPECT().GetBalanceAdjustments(gomock.Any(), 2, MaxAdjustmentsLimit, 5).Return([]*entities.BalanceAdjustment{}, nil)
+
+		_, err := userService.GetBalanceAdjustments(context.Background(), "admin", "bob", 1000, 5)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,184 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: admin.go
+
+// Package service is a generated GoMock package.
+package service
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entities "github.com/KonstantinGalanin/itemStore/internal/entities"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAdminService is a mock of AdminService interface.
+type MockAdminService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminServiceMockRecorder
+}
+
+// MockAdminServiceMockRecorder is the mock recorder for MockAdminService.
+type MockAdminServiceMockRecorder struct {
+	mock *MockAdminService
+}
+
+// NewMockAdminService creates a new mock instance.
+func NewMockAdminService(ctrl *gomock.Controller) *MockAdminService {
+	mock := &MockAdminService{ctrl: ctrl}
+	mock.recorder = &MockAdminServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminService) EXPECT() *MockAdminServiceMockRecorder {
+	return m.recorder
+}
+
+// ClawbackCoins mocks base method.
+func (m *MockAdminService) ClawbackCoins(ctx context.Context, adminName, userName string, amount int, reason string) (*entities.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClawbackCoins", ctx, adminName, userName, amount, reason)
+	ret0, _ := ret[0].(*entities.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClawbackCoins indicates an expected call of ClawbackCoins.
+func (mr *MockAdminServiceMockRecorder) ClawbackCoins(ctx, adminName, userName, amount, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClawbackCoins", reflect.TypeOf((*MockAdminService)(nil).ClawbackCoins), ctx, adminName, userName, amount, reason)
+}
+
+// ClearTransferLimits mocks base method.
+func (m *MockAdminService) ClearTransferLimits(ctx context.Context, adminName, userName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearTransferLimits", ctx, adminName, userName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearTransferLimits indicates an expected call of ClearTransferLimits.
+func (mr *MockAdminServiceMockRecorder) ClearTransferLimits(ctx, adminName, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearTransferLimits", reflect.TypeOf((*MockAdminService)(nil).ClearTransferLimits), ctx, adminName, userName)
+}
+
+// ForceRefund mocks base method.
+func (m *MockAdminService) ForceRefund(ctx context.Context, adminName, userName, itemName string, quantity int) (*entities.RefundOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceRefund", ctx, adminName, userName, itemName, quantity)
+	ret0, _ := ret[0].(*entities.RefundOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ForceRefund indicates an expected call of ForceRefund.
+func (mr *MockAdminServiceMockRecorder) ForceRefund(ctx, adminName, userName, itemName, quantity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceRefund", reflect.TypeOf((*MockAdminService)(nil).ForceRefund), ctx, adminName, userName, itemName, quantity)
+}
+
+// GetBalanceAdjustments mocks base method.
+func (m *MockAdminService) GetBalanceAdjustments(ctx context.Context, adminName, userName string, limit, offset int) (*entities.BalanceAdjustmentsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAdjustments", ctx, adminName, userName, limit, offset)
+	ret0, _ := ret[0].(*entities.BalanceAdjustmentsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAdjustments indicates an expected call of GetBalanceAdjustments.
+func (mr *MockAdminServiceMockRecorder) GetBalanceAdjustments(ctx, adminName, userName, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAdjustments", reflect.TypeOf((*MockAdminService)(nil).GetBalanceAdjustments), ctx, adminName, userName, limit, offset)
+}
+
+// GetTransferLimits mocks base method.
+func (m *MockAdminService) GetTransferLimits(ctx context.Context, userName string) (*entities.UserTransferLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferLimits", ctx, userName)
+	ret0, _ := ret[0].(*entities.UserTransferLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferLimits indicates an expected call of GetTransferLimits.
+func (mr *MockAdminServiceMockRecorder) GetTransferLimits(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferLimits", reflect.TypeOf((*MockAdminService)(nil).GetTransferLimits), ctx, userName)
+}
+
+// GrantCoins mocks base method.
+func (m *MockAdminService) GrantCoins(ctx context.Context, adminName string, userNames []string, amount int, reason string) ([]*entities.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantCoins", ctx, adminName, userNames, amount, reason)
+	ret0, _ := ret[0].([]*entities.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrantCoins indicates an expected call of GrantCoins.
+func (mr *MockAdminServiceMockRecorder) GrantCoins(ctx, adminName, userNames, amount, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantCoins", reflect.TypeOf((*MockAdminService)(nil).GrantCoins), ctx, adminName, userNames, amount, reason)
+}
+
+// SetBalance mocks base method.
+func (m *MockAdminService) SetBalance(ctx context.Context, adminName, userName string, balance int, reason string) (*entities.BalanceAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBalance", ctx, adminName, userName, balance, reason)
+	ret0, _ := ret[0].(*entities.BalanceAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetBalance indicates an expected call of SetBalance.
+func (mr *MockAdminServiceMockRecorder) SetBalance(ctx, adminName, userName, balance, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBalance", reflect.TypeOf((*MockAdminService)(nil).SetBalance), ctx, adminName, userName, balance, reason)
+}
+
+// SetItemPrice mocks base method.
+func (m *MockAdminService) SetItemPrice(ctx context.Context, adminName, itemName string, price int) (*entities.CatalogItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetItemPrice", ctx, adminName, itemName, price)
+	ret0, _ := ret[0].(*entities.CatalogItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetItemPrice indicates an expected call of SetItemPrice.
+func (mr *MockAdminServiceMockRecorder) SetItemPrice(ctx, adminName, itemName, price interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetItemPrice", reflect.TypeOf((*MockAdminService)(nil).SetItemPrice), ctx, adminName, itemName, price)
+}
+
+// SetTransferLimits mocks base method.
+func (m *MockAdminService) SetTransferLimits(ctx context.Context, adminName, userName string, limits entities.TransferLimits) (*entities.UserTransferLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTransferLimits", ctx, adminName, userName, limits)
+	ret0, _ := ret[0].(*entities.UserTransferLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTransferLimits indicates an expected call of SetTransferLimits.
+func (mr *MockAdminServiceMockRecorder) SetTransferLimits(ctx, adminName, userName, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTransferLimits", reflect.TypeOf((*MockAdminService)(nil).SetTransferLimits), ctx, adminName, userName, limits)
+}
+
+// UnlockUser mocks base method.
+func (m *MockAdminService) UnlockUser(ctx context.Context, adminName, userName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockUser", ctx, adminName, userName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockUser indicates an expected call of UnlockUser.
+func (mr *MockAdminServiceMockRecorder) UnlockUser(ctx, adminName, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockUser", reflect.TypeOf((*MockAdminService)(nil).UnlockUser), ctx, adminName, userName)
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/audit"
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	DefaultAuditEventsLimit = 50
+	MaxAuditEventsLimit     = 500
+
+	// auditBatchSize is how many events ExportAuditEvents and
+	// VerifyAuditLog read per query.
+	auditBatchSize = 500
+
+	// SystemActor is the actor of events nobody in particular caused.
+	SystemActor = "system"
+)
+
+// Auditor appends events to the audit log. Events about a change are
+// recorded in the transaction that makes it, so a failure undoes the change
+// and a rolled back change leaves no event. Events with no change to go
+// with, such as a failed login, are recorded on their own and a failure is
+// only logged.
+type Auditor interface {
+	Record(ctx context.Context, event *entities.AuditEvent) error
+}
+
+type nopAuditor struct{}
+
+func (nopAuditor) Record(ctx context.Context, event *entities.AuditEvent) error {
+	return nil
+}
+
+// AuditLog is the Auditor that stores events with the repository, each one
+// chained to the event before it.
+type AuditLog struct {
+	Repo UserRepo
+	Tx   TxManager
+}
+
+func NewAuditLog(repo UserRepo, txManager TxManager) *AuditLog {
+	return &AuditLog{
+		Repo: repo,
+		Tx:   txManager,
+	}
+}
+
+// auditTx is how Record runs when the caller has no transaction. Reading
+// the last event must happen after LockAuditLog returns, so it cannot use a
+// snapshot taken before the lock was granted.
+var auditTx = &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+
+// Record locks the audit log, reads the last event and appends event after
+// it, so concurrent appends queue up instead of forking the chain. Inside
+// the caller's transaction the last event comes from that transaction's
+// snapshot; if another append got in first, the unique prev_hash makes the
+// store fail the transaction as retryable.
+func (l *AuditLog) Record(ctx context.Context, event *entities.AuditEvent) error {
+	return l.Tx.WithinTx(ctx, auditTx, func(ctx context.Context) error {
+		if err := l.Repo.LockAuditLog(ctx); err != nil {
+			return err
+		}
+
+		event.PrevHash = ""
+		last, err := l.Repo.LastAuditEvent(ctx, "")
+		switch {
+		case err == nil:
+			event.PrevHash = last.Hash
+		case !errors.Is(err, utils.ErrNoAuditEvent):
+			return err
+		}
+		event.Hash = audit.Hash(event)
+
+		return l.Repo.AddAuditEvent(ctx, event)
+	})
+}
+
+// audit records that actor did action to target when there is no change
+// to record it with, logging a failure. The request metadata is taken from
+// ctx.
+func (u *UserService) audit(ctx context.Context, action, actor, target, details string) {
+	if err := u.auditTx(ctx, action, actor, target, details); err != nil {
+		log.Printf("audit %s by %s: %v", action, actor, err)
+	}
+}
+
+// auditTx records that actor did action to target as part of the
+// transaction in ctx, so the event is stored if and only if the change is.
+func (u *UserService) auditTx(ctx context.Context, action, actor, target, details string) error {
+	meta := audit.MetaFrom(ctx)
+
+	return u.Auditor.Record(ctx, &entities.AuditEvent{
+		Action:    action,
+		Actor:     actor,
+		Target:    target,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+		RequestID: meta.RequestID,
+		Details:   details,
+		CreatedAt: u.Now().UTC().Truncate(time.Microsecond),
+	})
+}
+
+// RecordConfig adds a config.changed event when settings differ from the
+// settings last recorded, so that configuration changed between restarts
+// shows up in the audit log.
+func (u *UserService) RecordConfig(ctx context.Context, settings string) error {
+	last, err := u.UserRepo.LastAuditEvent(ctx, entities.AuditConfigChanged)
+	if err != nil && !errors.Is(err, utils.ErrNoAuditEvent) {
+		return err
+	}
+	if err == nil && last.Details == settings {
+		return nil
+	}
+
+	u.audit(ctx, entities.AuditConfigChanged, SystemActor, "", settings)
+
+	return nil
+}
+
+// GetAuditEvents returns a page of the audit events matching filter in log
+// order, starting after the event with id after.
+func (u *UserService) GetAuditEvents(ctx context.Context, auditorName string, filter entities.AuditFilter, after, limit int) (*entities.AuditEventsPage, error) {
+	if limit < 0 || after < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if err := checkAuditFilter(filter); err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = DefaultAuditEventsLimit
+	}
+	limit = min(limit, MaxAuditEventsLimit)
+
+	page := &entities.AuditEventsPage{
+		Limit: limit,
+		After: after,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		var err error
+		if page.Total, err = u.UserRepo.CountAuditEvents(ctx, filter); err != nil {
+			return err
+		}
+
+		page.Events, err = u.UserRepo.GetAuditEvents(ctx, filter, after, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// ExportAuditEvents calls fn with every audit event matching filter, in log
+// order, and records the export itself. Events are read in batches, each in
+// its own transaction, so a long export does not hold one open.
+func (u *UserService) ExportAuditEvents(ctx context.Context, auditorName string, filter entities.AuditFilter, fn func(event *entities.AuditEvent) error) error {
+	if err := checkAuditFilter(filter); err != nil {
+		return err
+	}
+
+	u.audit(ctx, entities.AuditLogExported, auditorName, "", "")
+
+	return u.eachAuditEvent(ctx, filter, fn)
+}
+
+// VerifyAuditLog walks the whole audit log and checks its hash chain.
+func (u *UserService) VerifyAuditLog(ctx context.Context, auditorName string) (*entities.AuditVerification, error) {
+	result := &entities.AuditVerification{Valid: true}
+	prevHash := ""
+	errBroken := errors.New("audit chain broken")
+	err := u.eachAuditEvent(ctx, entities.AuditFilter{}, func(event *entities.AuditEvent) error {
+		if audit.Verify(prevHash, []*entities.AuditEvent{event}) >= 0 {
+			result.Valid = false
+			result.BrokenAt = event.ID
+			return errBroken
+		}
+		prevHash = event.Hash
+		result.Events++
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (u *UserService) eachAuditEvent(ctx context.Context, filter entities.AuditFilter, fn func(event *entities.AuditEvent) error) error {
+	after := 0
+	for {
+		var events []*entities.AuditEvent
+		err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+			var err error
+			events, err = u.UserRepo.GetAuditEvents(ctx, filter, after, auditBatchSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+			after = event.ID
+		}
+		if len(events) < auditBatchSize {
+			return nil
+		}
+	}
+}
+
+func checkAuditFilter(filter entities.AuditFilter) error {
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return utils.ErrBadTimeRange
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: audit.go
+
+// Package service is a generated GoMock package.
+package service
+
+import (
+	context "context"
+	reflect "reflect"
+
+	entities "github.com/KonstantinGalanin/itemStore/internal/entities"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAuditService is a mock of AuditService interface.
+type MockAuditService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditServiceMockRecorder
+}
+
+// MockAuditServiceMockRecorder is the mock recorder for MockAuditService.
+type MockAuditServiceMockRecorder struct {
+	mock *MockAuditService
+}
+
+// NewMockAuditService creates a new mock instance.
+func NewMockAuditService(ctrl *gomock.Controller) *MockAuditService {
+	mock := &MockAuditService{ctrl: ctrl}
+	mock.recorder = &MockAuditServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditService) EXPECT() *MockAuditServiceMockRecorder {
+	return m.recorder
+}
+
+// ExportAuditEvents mocks base method.
+func (m *MockAuditService) ExportAuditEvents(ctx context.Context, auditorName string, filter entities.AuditFilter, fn func(*entities.AuditEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportAuditEvents", ctx, auditorName, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportAuditEvents indicates an expected call of ExportAuditEvents.
+func (mr *MockAuditServiceMockRecorder) ExportAuditEvents(ctx, auditorName, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportAuditEvents", reflect.TypeOf((*MockAuditService)(nil).ExportAuditEvents), ctx, auditorName, filter, fn)
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockAuditService) GetAuditEvents(ctx context.Context, auditorName string, filter entities.AuditFilter, after, limit int) (*entities.AuditEventsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, auditorName, filter, after, limit)
+	ret0, _ := ret[0].(*entities.AuditEventsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockAuditServiceMockRecorder) GetAuditEvents(ctx, auditorName, filter, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockAuditService)(nil).GetAuditEvents), ctx, auditorName, filter, after, limit)
+}
+
+// VerifyAuditLog mocks base method.
+func (m *MockAuditService) VerifyAuditLog(ctx context.Context, auditorName string) (*entities.AuditVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAuditLog", ctx, auditorName)
+	ret0, _ := ret[0].(*entities.AuditVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAuditLog indicates an expected call of VerifyAuditLog.
+func (mr *MockAuditServiceMockRecorder) VerifyAuditLog(ctx, auditorName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAuditLog", reflect.TypeOf((*MockAuditService)(nil).VerifyAuditLog), ctx, auditorName)
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/audit"
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingAuditor struct {
+	events []*entities.AuditEvent
+	err    error
+}
+
+func (r *recordingAuditor) Record(ctx context.Context, event *entities.AuditEvent) error {
+	r.events = append(r.events, event)
+	return r.err
+}
+
+func TestAuditLogRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	auditLog := NewAuditLog(mockRepo, passthroughTx{})
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("first event", func(t *testing.T) {
+		event := &entities.AuditEvent{Action: entities.AuditLoginSucceeded, Actor: "alice", CreatedAt: now}
+
+		gomock.InOrder(
+			mockRepo.EXPECT().LockAuditLog(gomock.Any()).Return(nil),
+			mockRepo.EXPECT().LastAuditEvent(gomock.Any(), "").Return(nil, utils.ErrNoAuditEvent),
+			mockRepo.EXPECT().AddAuditEvent(gomock.Any(), event).Return(nil),
+		)
+
+		assert.NoError(t, auditLog.Record(context.Background(), event))
+		assert.Empty(t, event.PrevHash)
+		assert.Equal(t, audit.Hash(event), event.Hash)
+	})
+
+	t.Run("chained to the last event", func(t *testing.T) {
+		event := &entities.AuditEvent{Action: entities.AuditCoinsSent, Actor: "alice", Target: "bob", CreatedAt: now}
+
+		gomock.InOrder(
+			mockRepo.EXPECT().LockAuditLog(gomock.Any()).Return(nil),
+			mockRepo.EXPECT().LastAuditEvent(gomock.Any(), "").Return(&entities.AuditEvent{ID: 7, Hash: "abc"}, nil),
+			mockRepo.EXPECT().AddAuditEvent(gomock.Any(), event).Return(nil),
+		)
+
+		assert.NoError(t, auditLog.Record(context.Background(), event))
+		assert.Equal(t, "abc", event.PrevHash)
+		assert.Equal(t, audit.Hash(event), event.Hash)
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		someError := errors.New("db error")
+		mockRepo.EXPECT().LockAuditLog(gomock.Any()).Return(someError)
+
+		assert.Equal(t, someError, auditLog.Record(context.Background(), &entities.AuditEvent{Action: entities.AuditLoginSucceeded}))
+	})
+}
+
+func TestAuditedActions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	auditor := &recordingAuditor{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Auditor = auditor
+
+	meta := audit.Meta{IP: "10.0.0.1", UserAgent: "curl/8.0", RequestID: "req-1"}
+	ctx := audit.WithMeta(context.Background(), meta)
+
+	t.Run("send coin", func(t *testing.T) {
+		auditor.events = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 15, "", entities.TransferCategoryOther).Return(nil)
+
+		assert.NoError(t, userService.SendCoin(ctx, "alice", "bob", 15, "", ""))
+		assert.Equal(t, []*entities.AuditEvent{{
+			Action:    entities.AuditCoinsSent,
+			Actor:     "alice",
+			Target:    "bob",
+			IP:        meta.IP,
+			UserAgent: meta.UserAgent,
+			RequestID: meta.RequestID,
+			Details:   "amount=15 category=other",
+			CreatedAt: now,
+		}}, auditor.events)
+	})
+
+	t.Run("failed transfer is not audited", func(t *testing.T) {
+		auditor.events = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 15, "", entities.TransferCategoryOther).Return(utils.ErrNotEnoughBalance)
+
+		assert.Error(t, userService.SendCoin(ctx, "alice", "bob", 15, "", ""))
+		assert.Empty(t, auditor.events)
+	})
+
+	t.Run("failed audit fails the change", func(t *testing.T) {
+		auditor.events = nil
+		auditor.err = errors.New("audit log unavailable")
+		defer func() { auditor.err = nil }()
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 15, "", entities.TransferCategoryOther).Return(nil)
+
+		assert.Equal(t, auditor.err, userService.SendCoin(ctx, "alice", "bob", 15, "", ""))
+	})
+
+	t.Run("failed audit of a failed login is only logged", func(t *testing.T) {
+		auditor.events = nil
+		auditor.err = errors.New("audit log unavailable")
+		defer func() { auditor.err = nil }()
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "carol").
+			Return(&entities.LoginAttempts{Username: "carol", BlockedUntil: now.Add(time.Minute)}, nil)
+
+		_, err := userService.Auth(ctx, "carol", "password")
+		assert.True(t, errors.Is(err, utils.ErrTooManyAttempts))
+	})
+
+	t.Run("registration", func(t *testing.T) {
+		auditor.events = nil
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "carol").Return(&entities.LoginAttempts{Username: "carol"}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), "carol", "password").Return(&entities.User{Username: "carol", Registered: true}, nil)
+
+		_, err := userService.Auth(ctx, "carol", "password")
+		assert.NoError(t, err)
+		if assert.Len(t, auditor.events, 2) {
+			assert.Equal(t, entities.AuditRegistered, auditor.events[0].Action)
+			assert.Equal(t, entities.AuditLoginSucceeded, auditor.events[1].Action)
+		}
+	})
+
+	t.Run("blocked login", func(t *testing.T) {
+		auditor.events = nil
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "carol").
+			Return(&entities.LoginAttempts{Username: "carol", BlockedUntil: now.Add(time.Minute)}, nil)
+
+		_, err := userService.Auth(ctx, "carol", "password")
+		assert.True(t, errors.Is(err, utils.ErrTooManyAttempts))
+		if assert.Len(t, auditor.events, 1) {
+			assert.Equal(t, entities.AuditLoginFailed, auditor.events[0].Action)
+			assert.Equal(t, "blocked", auditor.events[0].Details)
+		}
+	})
+}
+
+func TestRecordConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	auditor := &recordingAuditor{}
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Auditor = auditor
+
+	t.Run("unchanged", func(t *testing.T) {
+		mockRepo.EXPECT().LastAuditEvent(gomock.Any(), entities.AuditConfigChanged).
+			Return(&entities.AuditEvent{Details: "refundWindow=24h0m0s"}, nil)
+
+		assert.NoError(t, userService.RecordConfig(context.Background(), "refundWindow=24h0m0s"))
+		assert.Empty(t, auditor.events)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		mockRepo.EXPECT().LastAuditEvent(gomock.Any(), entities.AuditConfigChanged).
+			Return(&entities.AuditEvent{Details: "refundWindow=24h0m0s"}, nil)
+
+		assert.NoError(t, userService.RecordConfig(context.Background(), "refundWindow=48h0m0s"))
+		if assert.Len(t, auditor.events, 1) {
+			assert.Equal(t, entities.AuditConfigChanged, auditor.events[0].Action)
+			assert.Equal(t, SystemActor, auditor.events[0].Actor)
+			assert.Equal(t, "refundWindow=48h0m0s", auditor.events[0].Details)
+		}
+	})
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		filter := entities.AuditFilter{Actor: "alice", Since: since}
+		events := []*entities.AuditEvent{{ID: 11, Action: entities.AuditCoinsSent, Actor: "alice"}}
+
+		mockRepo.EXPECT().CountAuditEvents(gomock.Any(), filter).Return(12, nil)
+		mockRepo.EXPECT().GetAuditEvents(gomock.Any(), filter, 10, DefaultAuditEventsLimit).Return(events, nil)
+
+		page, err := userService.GetAuditEvents(context.Background(), "auditor", filter, 10, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.AuditEventsPage{Events: events, Total: 12, Limit: DefaultAuditEventsLimit, After: 10}, page)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		_, err := userService.GetAuditEvents(context.Background(), "auditor", entities.AuditFilter{}, -1, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadPage))
+
+		_, err = userService.GetAuditEvents(context.Background(), "auditor", entities.AuditFilter{Since: since, Until: since}, 0, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadTimeRange))
+	})
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	chain := func() []*entities.AuditEvent {
+		first := &entities.AuditEvent{ID: 1, Action: entities.AuditRegistered, Actor: "alice", CreatedAt: now}
+		first.Hash = audit.Hash(first)
+		second := &entities.AuditEvent{ID: 2, Action: entities.AuditCoinsSent, Actor: "alice", Target: "bob", Details: "amount=15 category=other", CreatedAt: now, PrevHash: first.Hash}
+		second.Hash = audit.Hash(second)
+		return []*entities.AuditEvent{first, second}
+	}
+
+	t.Run("intact", func(t *testing.T) {
+		mockRepo.EXPECT().GetAuditEvents(gomock.Any(), entities.AuditFilter{}, 0, auditBatchSize).Return(chain(), nil)
+
+		result, err := userService.VerifyAuditLog(context.Background(), "auditor")
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.AuditVerification{Valid: true, Events: 2}, result)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		events := chain()
+		events[1].Details = "amount=1 category=other"
+
+		mockRepo.EXPECT().GetAuditEvents(gomock.Any(), entities.AuditFilter{}, 0, auditBatchSize).Return(events, nil)
+
+		result, err := userService.VerifyAuditLog(context.Background(), "auditor")
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.AuditVerification{Events: 1, BrokenAt: 2}, result)
+	})
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+// MaxBatchRecipients is the most recipients a single batch transfer may have.
+const MaxBatchRecipients = 100
+
+// BatchSendCoin sends coins from fromUser to every recipient in transfers
+// within one transaction, so either every transfer happens or none does.
+// The whole batch is validated before any coins move; errors about a single
+// entry name its index or recipient. The memo and category apply to every
+// transfer.
+func (u *UserService) BatchSendCoin(ctx context.Context, fromUser string, transfers []entities.BatchTransfer, memo, category string) (*entities.BatchSendResult, error) {
+	if len(transfers) == 0 {
+		return nil, utils.ErrEmptyBatch
+	}
+	if len(transfers) > MaxBatchRecipients {
+		return nil, utils.ErrBatchTooLarge
+	}
+	memo, category, err := transferDetails(memo, category)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	seen := make(map[string]bool, len(transfers))
+	for i, transfer := range transfers {
+		switch {
+		case transfer.Amount <= 0:
+			return nil, fmt.Errorf("transfers[%d]: %w", i, utils.ErrBadAmount)
+		case transfer.ToUser == fromUser:
+			return nil, fmt.Errorf("transfers[%d]: %w", i, utils.ErrSelfSend)
+		case seen[transfer.ToUser]:
+			return nil, fmt.Errorf("transfers[%d]: %w", i, utils.ErrDuplicateRecipient)
+		case transfer.Amount > math.MaxInt-total:
+			return nil, utils.ErrNotEnoughBalance
+		}
+		seen[transfer.ToUser] = true
+		total += transfer.Amount
+	}
+
+	result := &entities.BatchSendResult{
+		Transfers: transfers,
+		Total:     total,
+	}
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		fromUserID, err := u.UserRepo.GetUserID(ctx, fromUser)
+		if err != nil {
+			return err
+		}
+
+		toUserIDs := make([]int, len(transfers))
+		for i, transfer := range transfers {
+			if toUserIDs[i], err = u.UserRepo.GetUserID(ctx, transfer.ToUser); err != nil {
+				return fmt.Errorf("recipient %s: %w", transfer.ToUser, err)
+			}
+		}
+
+		balance, err := u.UserRepo.GetCoinsInfo(ctx, fromUserID)
+		if err != nil {
+			return err
+		}
+		if balance < total {
+			return utils.ErrNotEnoughBalance
+		}
+
+		limits, err := u.transferLimits(ctx, fromUserID)
+		if err != nil {
+			return err
+		}
+		balanceChecked, err := u.watchBalance(ctx, fromUserID, fromUser)
+		if err != nil {
+			return err
+		}
+		// Each transfer is checked after the previous ones were made, so
+		// the batch as a whole is held to the limits.
+		for i, transfer := range transfers {
+			if err := u.checkTransferLimits(ctx, limits, fromUserID, toUserIDs[i], transfer.Amount); err != nil {
+				return fmt.Errorf("recipient %s: %w", transfer.ToUser, err)
+			}
+			if err := u.UserRepo.SendCoin(ctx, fromUserID, toUserIDs[i], transfer.Amount, memo, category); err != nil {
+				return fmt.Errorf("recipient %s: %w", transfer.ToUser, err)
+			}
+
+			sent := entities.CoinsSentEvent{
+				FromUser: fromUser,
+				ToUser:   transfer.ToUser,
+				Amount:   transfer.Amount,
+				Memo:     memo,
+				Category: category,
+			}
+			if err := u.webhook(ctx, toUserIDs[i], entities.WebhookCoinReceived, sent); err != nil {
+				return err
+			}
+			if err := u.push(ctx, toUserIDs[i], entities.StreamCoinReceived, sent); err != nil {
+				return err
+			}
+			if err := u.publish(ctx, entities.EventCoinsSent, fromUser, sent); err != nil {
+				return err
+			}
+			if err := u.auditTx(ctx, entities.AuditCoinsBatchSent, fromUser, transfer.ToUser, fmt.Sprintf("amount=%d category=%s", transfer.Amount, category)); err != nil {
+				return err
+			}
+		}
+		result.Balance = balance - total
+		if err := u.pushBalance(ctx, append([]int{fromUserID}, toUserIDs...)...); err != nil {
+			return err
+		}
+
+		return balanceChecked()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transfer := range transfers {
+		u.notifyCoinsReceived(ctx, fromUser, transfer.ToUser, transfer.Amount, memo)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSendCoin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	transfers := []entities.BatchTransfer{
+		{ToUser: "bob", Amount: 30},
+		{ToUser: "carol", Amount: 20},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(3, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(100, nil)
+		gomock.InOrder(
+			mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 30, "sprint bonus", entities.TransferCategoryThanks).Return(nil),
+			mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 3, 20, "sprint bonus", entities.TransferCategoryThanks).Return(nil),
+		)
+
+		result, err := userService.BatchSendCoin(context.Background(), "alice", transfers, "sprint bonus", entities.TransferCategoryThanks)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.BatchSendResult{Transfers: transfers, Total: 50, Balance: 50}, result)
+	})
+
+	t.Run("unknown recipient", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(0, utils.ErrNoUser)
+
+		_, err := userService.BatchSendCoin(context.Background(), "alice", transfers, "", "")
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+		assert.Contains(t, err.Error(), "carol")
+	})
+
+	t.Run("not enough balance for the whole batch", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(3, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(40, nil)
+
+		_, err := userService.BatchSendCoin(context.Background(), "alice", transfers, "", "")
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+	})
+
+	t.Run("transfer failure aborts the batch", func(t *testing.T) {
+		someError := errors.New("db down")
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(3, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(100, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 30, "", entities.TransferCategoryOther).Return(someError)
+
+		_, err := userService.BatchSendCoin(context.Background(), "alice", transfers, "", "")
+		assert.True(t, errors.Is(err, someError))
+	})
+
+	t.Run("invalid batch", func(t *testing.T) {
+		_, err := userService.BatchSendCoin(context.Background(), "alice", nil, "", "")
+		assert.True(t, errors.Is(err, utils.ErrEmptyBatch))
+
+		_, err = userService.BatchSendCoin(context.Background(), "alice", make([]entities.BatchTransfer, MaxBatchRecipients+1), "", "")
+		assert.True(t, errors.Is(err, utils.ErrBatchTooLarge))
+
+		_, err = userService.BatchSendCoin(context.Background(), "alice", []entities.BatchTransfer{{ToUser: "bob", Amount: 10}, {ToUser: "carol", Amount: 0}}, "", "")
+		assert.True(t, errors.Is(err, utils.ErrBadAmount))
+		assert.Contains(t, err.Error(), "transfers[1]")
+
+		_, err = userService.BatchSendCoin(context.Background(), "alice", []entities.BatchTransfer{{ToUser: "alice", Amount: 10}}, "", "")
+		assert.True(t, errors.Is(err, utils.ErrSelfSend))
+
+		_, err = userService.BatchSendCoin(context.Background(), "alice", []entities.BatchTransfer{{ToUser: "bob", Amount: 10}, {ToUser: "bob", Amount: 5}}, "", "")
+		assert.True(t, errors.Is(err, utils.ErrDuplicateRecipient))
+
+		_, err = userService.BatchSendCoin(context.Background(), "alice", transfers, "", "bribe")
+		assert.True(t, errors.Is(err, utils.ErrBadCategory))
+	})
+}
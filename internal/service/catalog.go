@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+var itemNameValid = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// GetCatalog lists the items on sale and their prices.
+func (u *UserService) GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error) {
+	return u.UserRepo.GetCatalog(ctx)
+}
+
+// SetItemPrice sets the price of itemName, putting it on sale if the shop
+// does not sell it yet. Past orders keep the price they were made at, so
+// refunds are not affected.
+func (u *UserService) SetItemPrice(ctx context.Context, adminName, itemName string, price int) (*entities.CatalogItem, error) {
+	if !itemNameValid.MatchString(itemName) {
+		return nil, utils.ErrBadItemName
+	}
+	if price <= 0 {
+		return nil, utils.ErrBadPrice
+	}
+
+	err := u.Tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+		if err := u.UserRepo.SetItemPrice(ctx, itemName, price); err != nil {
+			return err
+		}
+
+		return u.auditTx(ctx, entities.AuditItemPriced, adminName, itemName, fmt.Sprintf("price=%d", price))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.CatalogItem{Name: itemName, Price: price}, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetItemPrice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	auditor := &recordingAuditor{}
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Auditor = auditor
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().SetItemPrice(gomock.Any(), "sticker", 15).Return(nil)
+
+		item, err := userService.SetItemPrice(context.Background(), "admin", "sticker", 15)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.CatalogItem{Name: "sticker", Price: 15}, item)
+		if assert.Len(t, auditor.events, 1) {
+			assert.Equal(t, entities.AuditItemPriced, auditor.events[0].Action)
+			assert.Equal(t, "admin", auditor.events[0].Actor)
+			assert.Equal(t, "sticker", auditor.events[0].Target)
+			assert.Equal(t, "price=15", auditor.events[0].Details)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tc := range []struct {
+			itemName string
+			price    int
+			err      error
+		}{
+			{"", 10, utils.ErrBadItemName},
+			{"two words", 10, utils.ErrBadItemName},
+			{"sticker/2", 10, utils.ErrBadItemName},
+			{"sticker", 0, utils.ErrBadPrice},
+			{"sticker", -5, utils.ErrBadPrice},
+		} {
+			_, err := userService.SetItemPrice(context.Background(), "admin", tc.itemName, tc.price)
+			assert.True(t, errors.Is(err, tc.err), "%q %d: %v", tc.itemName, tc.price, err)
+		}
+	})
+
+	t.Run("repo error", func(t *testing.T) {
+		mockRepo.EXPECT().SetItemPrice(gomock.Any(), "sticker", 15).Return(errors.New("db down"))
+
+		_, err := userService.SetItemPrice(context.Background(), "admin", "sticker", 15)
+		assert.Error(t, err)
+	})
+}
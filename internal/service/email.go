@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	// DefaultEmailDigestInterval is how often users in digest mode get a
+	// digest.
+	DefaultEmailDigestInterval = 24 * time.Hour
+	// MaxDigestNotifications is the most notifications a digest lists; the
+	// rest stay in the inbox.
+	MaxDigestNotifications = 50
+	// EmailTemplateDigest names the digest template. Other emails use the
+	// template named after their notification kind.
+	EmailTemplateDigest = "digest"
+
+	// MaxEmailAddressLength is the longest address a path in SMTP allows.
+	MaxEmailAddressLength = 254
+
+	// A failed email is retried after emailBaseRetry, doubled per failed
+	// attempt up to emailMaxRetry, and marked dead after EmailMaxAttempts
+	// attempts.
+	EmailMaxAttempts = 6
+	emailBaseRetry   = time.Minute
+	emailMaxRetry    = 2 * time.Hour
+
+	// emailClaimTTL is how long a claimed email is held back from other
+	// workers while it is being sent.
+	emailClaimTTL = time.Minute
+	// maxEmailsPerSweep and maxDigestsPerSweep bound the work a single
+	// SendEmails or SendEmailDigests call does.
+	maxEmailsPerSweep  = 100
+	maxDigestsPerSweep = 100
+)
+
+// EmailTemplates renders the emails sent to users.
+type EmailTemplates interface {
+	Render(name string, data *entities.EmailData) (subject, text, html string, err error)
+}
+
+// EmailSender sends one email. It returns an error unless the mail server
+// accepted it.
+type EmailSender interface {
+	Send(ctx context.Context, email *entities.Email) error
+}
+
+var (
+	// errNoEmailTemplates and errNoEmailSender fail emails while email is
+	// not configured.
+	errNoEmailTemplates = errors.New("no email templates configured")
+	errNoEmailSender    = errors.New("no email sender configured")
+)
+
+type nopEmailTemplates struct{}
+
+func (nopEmailTemplates) Render(name string, data *entities.EmailData) (string, string, string, error) {
+	return "", "", "", errNoEmailTemplates
+}
+
+type nopEmailSender struct{}
+
+func (nopEmailSender) Send(ctx context.Context, email *entities.Email) error {
+	return errNoEmailSender
+}
+
+// Mailer is the Notifier that emails receipts and coin-received alerts to
+// the users who want them as they happen, unless they turned notifications
+// of that kind off. Emails are queued for SendEmails.
+type Mailer struct {
+	Repo      UserRepo
+	Templates EmailTemplates
+	Now       func() time.Time
+}
+
+func NewMailer(repo UserRepo, templates EmailTemplates) *Mailer {
+	return &Mailer{
+		Repo:      repo,
+		Templates: templates,
+		Now:       time.Now,
+	}
+}
+
+func (m *Mailer) Notify(ctx context.Context, notification *entities.Notification) error {
+	if !slices.Contains(entities.EmailKinds, notification.Kind) {
+		return nil
+	}
+
+	userID, err := m.Repo.GetUserID(ctx, notification.Recipient)
+	if err != nil {
+		return err
+	}
+
+	preferences, err := m.Repo.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enabled, ok := preferences[notification.Kind]; ok && !enabled {
+		return nil
+	}
+
+	settings, err := m.Repo.GetEmailSettings(ctx, userID)
+	if errors.Is(err, utils.ErrNoEmailSettings) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if settings.Mode != entities.EmailModeInstant {
+		return nil
+	}
+
+	email, err := renderEmail(m.Templates, notification.Kind, &entities.EmailData{
+		Username:     notification.Recipient,
+		Notification: notification,
+	}, m.Now())
+	if err != nil {
+		return err
+	}
+	email.UserID = userID
+	email.To = settings.Address
+
+	return m.Repo.AddEmail(ctx, email)
+}
+
+// renderEmail renders the name template into a pending email, due now.
+func renderEmail(templates EmailTemplates, name string, data *entities.EmailData, now time.Time) (*entities.Email, error) {
+	subject, text, html, err := templates.Render(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("render %s email: %w", name, err)
+	}
+
+	now = now.UTC().Truncate(time.Microsecond)
+	return &entities.Email{
+		Subject:       subject,
+		Text:          text,
+		HTML:          html,
+		Status:        entities.EmailPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// GetEmailSettings returns the user's email settings. Users who never set
+// them get no email.
+func (u *UserService) GetEmailSettings(ctx context.Context, userName string) (*entities.EmailSettings, error) {
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := u.UserRepo.GetEmailSettings(ctx, userID)
+	if errors.Is(err, utils.ErrNoEmailSettings) {
+		return &entities.EmailSettings{Mode: entities.EmailModeOff}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// SetEmailSettings sets where and how the user is emailed. Mode off opts
+// the user out of email; switching to digest mode starts the first digest's
+// period.
+func (u *UserService) SetEmailSettings(ctx context.Context, userName string, settings entities.EmailSettings) (*entities.EmailSettings, error) {
+	if !slices.Contains(entities.EmailModes, settings.Mode) {
+		return nil, utils.ErrBadEmailMode
+	}
+	settings.Address = strings.TrimSpace(settings.Address)
+	if settings.Address != "" || settings.Mode != entities.EmailModeOff {
+		address, err := mail.ParseAddress(settings.Address)
+		if err != nil || address.Name != "" || address.Address != settings.Address || len(settings.Address) > MaxEmailAddressLength {
+			return nil, utils.ErrBadEmail
+		}
+	}
+
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		current, err := u.UserRepo.GetEmailSettings(ctx, userID)
+		switch {
+		case errors.Is(err, utils.ErrNoEmailSettings):
+			settings.DigestSentAt = u.Now()
+		case err != nil:
+			return err
+		case current.Mode != entities.EmailModeDigest:
+			settings.DigestSentAt = u.Now()
+		default:
+			settings.DigestSentAt = current.DigestSentAt
+		}
+
+		return u.UserRepo.SetEmailSettings(ctx, userID, &settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// SendEmails sends the queued emails that are due and reports how many
+// were attempted. Like DeliverWebhooks it claims each email in a
+// transaction of its own and sends it outside of it.
+func (u *UserService) SendEmails(ctx context.Context) (int, error) {
+	sent := 0
+	for sent < maxEmailsPerSweep {
+		var email *entities.Email
+		err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+			now := u.Now()
+			var err error
+			email, err = u.UserRepo.ClaimDueEmail(ctx, now, now.Add(emailClaimTTL))
+			return err
+		})
+		if errors.Is(err, utils.ErrNoEmail) {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+
+		if err := u.attemptEmail(ctx, email); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// attemptEmail sends email and schedules a retry, or gives up, if that
+// failed.
+func (u *UserService) attemptEmail(ctx context.Context, email *entities.Email) error {
+	sendErr := u.EmailSender.Send(ctx, email)
+	now := u.Now()
+
+	email.Attempts++
+	switch {
+	case sendErr == nil:
+		email.Status = entities.EmailSent
+		email.LastError = ""
+		email.SentAt = &now
+	case email.Attempts >= EmailMaxAttempts:
+		email.Status = entities.EmailDead
+		email.LastError = sendErr.Error()
+	default:
+		email.LastError = sendErr.Error()
+		email.NextAttemptAt = now.Add(emailRetryDelay(email.Attempts))
+	}
+	if sendErr != nil {
+		log.Printf("email %d to user %d: %v", email.ID, email.UserID, sendErr)
+	}
+
+	return u.UserRepo.UpdateEmail(ctx, email)
+}
+
+func emailRetryDelay(attempts int) time.Duration {
+	delay := emailBaseRetry
+	for i := 1; i < attempts && delay < emailMaxRetry; i++ {
+		delay *= 2
+	}
+
+	return min(delay, emailMaxRetry)
+}
+
+// SendEmailDigests queues a digest for each digest mode user whose last one
+// went out EmailDigestInterval ago, listing the notifications they got
+// since, and reports how many were queued. Users with nothing new get no
+// email but start a new period all the same.
+func (u *UserService) SendEmailDigests(ctx context.Context) (int, error) {
+	now := u.Now()
+	digests, err := u.UserRepo.GetDueEmailDigests(ctx, now.Add(-u.EmailDigestInterval), maxDigestsPerSweep)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, digest := range digests {
+		sent := false
+		err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+			sent = false
+
+			// Another replica may have sent this digest in the meantime.
+			settings, err := u.UserRepo.GetEmailSettings(ctx, digest.UserID)
+			if err != nil {
+				return err
+			}
+			if settings.Mode != entities.EmailModeDigest || !settings.DigestSentAt.Equal(digest.Since) {
+				return nil
+			}
+
+			notifications, err := u.UserRepo.GetNotificationsSince(ctx, digest.UserID, digest.Since, MaxDigestNotifications)
+			if err != nil {
+				return err
+			}
+			if len(notifications) > 0 {
+				email, err := renderEmail(u.EmailTemplates, EmailTemplateDigest, &entities.EmailData{
+					Username:      digest.Username,
+					Notifications: notifications,
+				}, now)
+				if err != nil {
+					return err
+				}
+				email.UserID = digest.UserID
+				email.To = settings.Address
+				if err := u.UserRepo.AddEmail(ctx, email); err != nil {
+					return err
+				}
+				sent = true
+			}
+
+			return u.UserRepo.MarkEmailDigestSent(ctx, digest.UserID, now)
+		})
+		if err != nil {
+			return queued, fmt.Errorf("digest for %s: %w", digest.Username, err)
+		}
+		if sent {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
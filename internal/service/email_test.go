@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEmailTemplates renders the template name as the subject and the
+// notification texts as the body.
+type fakeEmailTemplates struct{}
+
+func (fakeEmailTemplates) Render(name string, data *entities.EmailData) (string, string, string, error) {
+	text := ""
+	if data.Notification != nil {
+		text = data.Notification.Text
+	}
+	for _, notification := range data.Notifications {
+		text += notification.Text + "\n"
+	}
+
+	return name, text, "<p>" + text + "</p>", nil
+}
+
+type fakeEmailSender struct {
+	sent []*entities.Email
+	err  error
+}
+
+func (s *fakeEmailSender) Send(ctx context.Context, email *entities.Email) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, email)
+	return nil
+}
+
+func TestMailer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mailer := NewMailer(mockRepo, fakeEmailTemplates{})
+	mailer.Now = func() time.Time { return now }
+
+	coins := &entities.Notification{Recipient: "bob", Kind: entities.NotificationCoinsReceived, Text: "alice sent you 50 coins"}
+
+	t.Run("instant", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 2).Return(entities.NotificationPreferences{}, nil)
+		mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 2).Return(&entities.EmailSettings{Address: "bob@example.com", Mode: entities.EmailModeInstant}, nil)
+		mockRepo.EXPECT().AddEmail(gomock.Any(), &entities.Email{
+			UserID:        2,
+			To:            "bob@example.com",
+			Subject:       entities.NotificationCoinsReceived,
+			Text:          "alice sent you 50 coins",
+			HTML:          "<p>alice sent you 50 coins</p>",
+			Status:        entities.EmailPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}).Return(nil)
+
+		assert.NoError(t, mailer.Notify(context.Background(), coins))
+	})
+
+	t.Run("digest mode waits for the digest", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 2).Return(entities.NotificationPreferences{}, nil)
+		mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 2).Return(&entities.EmailSettings{Address: "bob@example.com", Mode: entities.EmailModeDigest}, nil)
+
+		assert.NoError(t, mailer.Notify(context.Background(), coins))
+	})
+
+	t.Run("no settings", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 2).Return(entities.NotificationPreferences{}, nil)
+		mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 2).Return(nil, utils.ErrNoEmailSettings)
+
+		assert.NoError(t, mailer.Notify(context.Background(), coins))
+	})
+
+	t.Run("kind turned off", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 2).Return(entities.NotificationPreferences{
+			entities.NotificationCoinsReceived: false,
+		}, nil)
+
+		assert.NoError(t, mailer.Notify(context.Background(), coins))
+	})
+
+	t.Run("kind not emailed", func(t *testing.T) {
+		assert.NoError(t, mailer.Notify(context.Background(), &entities.Notification{Recipient: "bob", Kind: entities.NotificationGiftReceived}))
+	})
+}
+
+func TestEmailSettings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+
+	t.Run("default is off", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 1).Return(nil, utils.ErrNoEmailSettings)
+
+		settings, err := userService.GetEmailSettings(context.Background(), "alice")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.EmailModeOff, settings.Mode)
+	})
+
+	t.Run("switching to digest starts a period", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 1).Return(&entities.EmailSettings{Address: "a@example.com", Mode: entities.EmailModeInstant}, nil)
+		mockRepo.EXPECT().SetEmailSettings(gomock.Any(), 1, &entities.EmailSettings{Address: "alice@example.com", Mode: entities.EmailModeDigest, DigestSentAt: now}).Return(nil)
+
+		settings, err := userService.SetEmailSettings(context.Background(), "alice", entities.EmailSettings{Address: " alice@example.com ", Mode: entities.EmailModeDigest})
+		assert.NoError(t, err)
+		assert.Equal(t, "alice@example.com", settings.Address)
+	})
+
+	t.Run("staying in digest keeps the period", func(t *testing.T) {
+		sentAt := now.Add(-time.Hour)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 1).Return(&entities.EmailSettings{Address: "a@example.com", Mode: entities.EmailModeDigest, DigestSentAt: sentAt}, nil)
+		mockRepo.EXPECT().SetEmailSettings(gomock.Any(), 1, &entities.EmailSettings{Address: "alice@example.com", Mode: entities.EmailModeDigest, DigestSentAt: sentAt}).Return(nil)
+
+		_, err := userService.SetEmailSettings(context.Background(), "alice", entities.EmailSettings{Address: "alice@example.com", Mode: entities.EmailModeDigest})
+		assert.NoError(t, err)
+	})
+
+	t.Run("off needs no address", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 1).Return(nil, utils.ErrNoEmailSettings)
+		mockRepo.EXPECT().SetEmailSettings(gomock.Any(), 1, gomock.Any()).Return(nil)
+
+		_, err := userService.SetEmailSettings(context.Background(), "alice", entities.EmailSettings{Mode: entities.EmailModeOff})
+		assert.NoError(t, err)
+	})
+
+	for _, tc := range []struct {
+		name     string
+		settings entities.EmailSettings
+		err      error
+	}{
+		{"unknown mode", entities.EmailSettings{Address: "alice@example.com", Mode: "weekly"}, utils.ErrBadEmailMode},
+		{"no address", entities.EmailSettings{Mode: entities.EmailModeInstant}, utils.ErrBadEmail},
+		{"bad address", entities.EmailSettings{Address: "alice", Mode: entities.EmailModeInstant}, utils.ErrBadEmail},
+		{"display name", entities.EmailSettings{Address: "Alice <alice@example.com>", Mode: entities.EmailModeInstant}, utils.ErrBadEmail},
+		{"header injection", entities.EmailSettings{Address: "alice@example.com\r\nBcc: eve@example.com", Mode: entities.EmailModeInstant}, utils.ErrBadEmail},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := userService.SetEmailSettings(context.Background(), "alice", tc.settings)
+			assert.True(t, errors.Is(err, tc.err), "got %v", err)
+		})
+	}
+}
+
+func TestSendEmails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	sender := &fakeEmailSender{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.EmailSender = sender
+
+	send := func(email *entities.Email) *entities.Email {
+		var updated *entities.Email
+		gomock.InOrder(
+			mockRepo.EXPECT().ClaimDueEmail(gomock.Any(), now, now.Add(emailClaimTTL)).Return(email, nil),
+			mockRepo.EXPECT().UpdateEmail(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, e *entities.Email) error {
+				updated = e
+				return nil
+			}),
+			mockRepo.EXPECT().ClaimDueEmail(gomock.Any(), now, now.Add(emailClaimTTL)).Return(nil, utils.ErrNoEmail),
+		)
+
+		sent, err := userService.SendEmails(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		return updated
+	}
+
+	t.Run("sent", func(t *testing.T) {
+		sender.err = nil
+
+		updated := send(&entities.Email{ID: 5, To: "bob@example.com", Status: entities.EmailPending})
+		assert.Equal(t, entities.EmailSent, updated.Status)
+		assert.Equal(t, 1, updated.Attempts)
+		assert.Equal(t, &now, updated.SentAt)
+		assert.Len(t, sender.sent, 1)
+	})
+
+	t.Run("retried with backoff", func(t *testing.T) {
+		sender.err = errors.New("421 try again later")
+
+		updated := send(&entities.Email{ID: 6, Status: entities.EmailPending, Attempts: 2})
+		assert.Equal(t, entities.EmailPending, updated.Status)
+		assert.Equal(t, 3, updated.Attempts)
+		assert.Equal(t, now.Add(4*emailBaseRetry), updated.NextAttemptAt)
+		assert.Equal(t, "421 try again later", updated.LastError)
+	})
+
+	t.Run("dead after the last attempt", func(t *testing.T) {
+		sender.err = errors.New("550 no such user")
+
+		updated := send(&entities.Email{ID: 7, Status: entities.EmailPending, Attempts: EmailMaxAttempts - 1})
+		assert.Equal(t, entities.EmailDead, updated.Status)
+		assert.Equal(t, EmailMaxAttempts, updated.Attempts)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		userService.EmailSender = nopEmailSender{}
+		defer func() { userService.EmailSender = sender }()
+
+		updated := send(&entities.Email{ID: 8, Status: entities.EmailPending})
+		assert.Equal(t, entities.EmailPending, updated.Status)
+		assert.Equal(t, errNoEmailSender.Error(), updated.LastError)
+	})
+}
+
+func TestSendEmailDigests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-DefaultEmailDigestInterval)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.EmailTemplates = fakeEmailTemplates{}
+
+	settings := &entities.EmailSettings{Address: "alice@example.com", Mode: entities.EmailModeDigest, DigestSentAt: since}
+	digests := []*entities.EmailDigest{
+		{UserID: 1, Username: "alice", Address: "alice@example.com", Since: since},
+		{UserID: 2, Username: "bob", Address: "bob@example.com", Since: since},
+		{UserID: 3, Username: "carol", Address: "carol@example.com", Since: since},
+	}
+
+	mockRepo.EXPECT().GetDueEmailDigests(gomock.Any(), since, maxDigestsPerSweep).Return(digests, nil)
+
+	// alice has news and gets a digest.
+	mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 1).Return(settings, nil)
+	mockRepo.EXPECT().GetNotificationsSince(gomock.Any(), 1, since, MaxDigestNotifications).Return([]*entities.Notification{
+		{Kind: entities.NotificationCoinsReceived, Text: "bob sent you 5 coins"},
+		{Kind: entities.NotificationGiftReceived, Text: "bob sent you a cup"},
+	}, nil)
+	mockRepo.EXPECT().AddEmail(gomock.Any(), &entities.Email{
+		UserID:        1,
+		To:            "alice@example.com",
+		Subject:       EmailTemplateDigest,
+		Text:          "bob sent you 5 coins\nbob sent you a cup\n",
+		HTML:          "<p>bob sent you 5 coins\nbob sent you a cup\n</p>",
+		Status:        entities.EmailPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Return(nil)
+	mockRepo.EXPECT().MarkEmailDigestSent(gomock.Any(), 1, now).Return(nil)
+
+	// bob has nothing new: no email, but a new period.
+	mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 2).Return(settings, nil)
+	mockRepo.EXPECT().GetNotificationsSince(gomock.Any(), 2, since, MaxDigestNotifications).Return(nil, nil)
+	mockRepo.EXPECT().MarkEmailDigestSent(gomock.Any(), 2, now).Return(nil)
+
+	// carol's digest was sent by another replica in the meantime.
+	mockRepo.EXPECT().GetEmailSettings(gomock.Any(), 3).Return(&entities.EmailSettings{Mode: entities.EmailModeDigest, DigestSentAt: now}, nil)
+
+	queued, err := userService.SendEmailDigests(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, queued)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	// DefaultEventRetention is how long events stay available for resuming
+	// a stream.
+	DefaultEventRetention = 24 * time.Hour
+
+	// MaxUserEventsBatch is how many events GetUserEvents returns at once.
+	MaxUserEventsBatch = 100
+)
+
+// EventHub wakes the event streams of connected users.
+type EventHub interface {
+	// Notify is called inside the transaction that adds an event for
+	// userID. The user's subscribers must not miss the event: they are
+	// woken once it can be read.
+	Notify(ctx context.Context, userID int) error
+	// Subscribe returns a channel that receives a value whenever events
+	// may have been added for userID, and the function that unsubscribes.
+	Subscribe(userID int) (<-chan struct{}, func())
+}
+
+// EventStream stores the events pushed to connected users. Like Publisher
+// it is called inside the transaction that makes the change, so an event is
+// pushed if and only if the change is committed.
+type EventStream interface {
+	Push(ctx context.Context, event *entities.UserEvent) error
+	// PushBalance pushes a balance.changed event with userID's balance as
+	// the transaction in ctx sees it.
+	PushBalance(ctx context.Context, userID int, at time.Time) error
+	Subscribe(userID int) (<-chan struct{}, func())
+}
+
+type nopEventStream struct{}
+
+func (nopEventStream) Push(ctx context.Context, event *entities.UserEvent) error {
+	return nil
+}
+
+func (nopEventStream) PushBalance(ctx context.Context, userID int, at time.Time) error {
+	return nil
+}
+
+// Subscribe returns a nil channel: nothing is ever pushed.
+func (nopEventStream) Subscribe(userID int) (<-chan struct{}, func()) {
+	return nil, func() {}
+}
+
+// UserEvents is the EventStream that stores events with the repository and
+// wakes their users' streams with Hub.
+type UserEvents struct {
+	Repo UserRepo
+	Hub  EventHub
+}
+
+func NewUserEvents(repo UserRepo, hub EventHub) *UserEvents {
+	return &UserEvents{
+		Repo: repo,
+		Hub:  hub,
+	}
+}
+
+func (e *UserEvents) Push(ctx context.Context, event *entities.UserEvent) error {
+	if err := e.Repo.AddUserEvent(ctx, event); err != nil {
+		return err
+	}
+
+	return e.Hub.Notify(ctx, event.UserID)
+}
+
+func (e *UserEvents) PushBalance(ctx context.Context, userID int, at time.Time) error {
+	balance, err := e.Repo.GetCoinsInfo(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entities.BalanceChangedEvent{Balance: balance})
+	if err != nil {
+		return fmt.Errorf("push %s: %w", entities.StreamBalanceChanged, err)
+	}
+
+	return e.Push(ctx, &entities.UserEvent{
+		UserID:    userID,
+		Type:      entities.StreamBalanceChanged,
+		Data:      data,
+		CreatedAt: at,
+	})
+}
+
+func (e *UserEvents) Subscribe(userID int) (<-chan struct{}, func()) {
+	return e.Hub.Subscribe(userID)
+}
+
+// push adds an eventType event to userID's stream.
+func (u *UserService) push(ctx context.Context, userID int, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("push %s: %w", eventType, err)
+	}
+
+	return u.Events.Push(ctx, &entities.UserEvent{
+		UserID:    userID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: u.Now().UTC().Truncate(time.Microsecond),
+	})
+}
+
+// pushBalance adds a balance.changed event to the stream of each of
+// userIDs, once per user.
+func (u *UserService) pushBalance(ctx context.Context, userIDs ...int) error {
+	at := u.Now().UTC().Truncate(time.Microsecond)
+	pushed := make(map[int]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if pushed[userID] {
+			continue
+		}
+		pushed[userID] = true
+
+		if err := u.Events.PushBalance(ctx, userID, at); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SubscribeEvents subscribes to the user's event stream. The returned
+// channel receives a value whenever new events may be available from
+// GetUserEvents; the returned function unsubscribes.
+func (u *UserService) SubscribeEvents(ctx context.Context, userName string) (<-chan struct{}, func(), error) {
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wake, unsubscribe := u.Events.Subscribe(userID)
+	return wake, unsubscribe, nil
+}
+
+// GetUserEvents returns up to MaxUserEventsBatch of the user's events after
+// afterID, oldest first.
+func (u *UserService) GetUserEvents(ctx context.Context, userName string, afterID int) ([]*entities.UserEvent, error) {
+	if afterID < 0 {
+		return nil, utils.ErrBadEventID
+	}
+
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.UserRepo.GetUserEvents(ctx, userID, afterID, MaxUserEventsBatch)
+}
+
+// LastUserEventID returns the id of the user's latest event, where a new
+// stream starts from, or zero if there is none.
+func (u *UserService) LastUserEventID(ctx context.Context, userName string) (int, error) {
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return 0, err
+	}
+
+	return u.UserRepo.LastUserEventID(ctx, userID)
+}
+
+// PruneUserEvents deletes the events older than EventRetention and reports
+// how many there were. A stream resumed from an older id skips them.
+func (u *UserService) PruneUserEvents(ctx context.Context) (int, error) {
+	return u.UserRepo.DeleteUserEvents(ctx, u.Now().Add(-u.EventRetention))
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHub records the users it was notified about.
+type recordingHub struct {
+	notified []int
+}
+
+func (h *recordingHub) Notify(ctx context.Context, userID int) error {
+	h.notified = append(h.notified, userID)
+	return nil
+}
+
+func (h *recordingHub) Subscribe(userID int) (<-chan struct{}, func()) {
+	return make(chan struct{}), func() {}
+}
+
+func TestEventsPushed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	hub := &recordingHub{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Events = NewUserEvents(mockRepo, hub)
+
+	var pushed []*entities.UserEvent
+	mockRepo.EXPECT().AddUserEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event *entities.UserEvent) error {
+		pushed = append(pushed, event)
+		event.ID = len(pushed)
+		return nil
+	}).AnyTimes()
+
+	t.Run("send coin", func(t *testing.T) {
+		pushed, hub.notified = nil, nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 15, "", entities.TransferCategoryOther).Return(nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(985, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 2).Return(1015, nil)
+
+		assert.NoError(t, userService.SendCoin(context.Background(), "alice", "bob", 15, "", ""))
+		if assert.Len(t, pushed, 3) {
+			assert.Equal(t, 2, pushed[0].UserID)
+			assert.Equal(t, entities.StreamCoinReceived, pushed[0].Type)
+			assert.JSONEq(t, `{"fromUser":"alice","toUser":"bob","amount":15,"category":"other"}`, string(pushed[0].Data))
+			assert.Equal(t, now, pushed[0].CreatedAt)
+
+			assert.Equal(t, 1, pushed[1].UserID)
+			assert.Equal(t, entities.StreamBalanceChanged, pushed[1].Type)
+			assert.JSONEq(t, `{"balance":985}`, string(pushed[1].Data))
+
+			assert.Equal(t, 2, pushed[2].UserID)
+			assert.JSONEq(t, `{"balance":1015}`, string(pushed[2].Data))
+		}
+		assert.Equal(t, []int{2, 1, 2}, hub.notified)
+	})
+
+	t.Run("buy item", func(t *testing.T) {
+		pushed, hub.notified = nil, nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().BuyItem(gomock.Any(), 1, 3).Return(nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(965, nil)
+
+		assert.NoError(t, userService.BuyItem(context.Background(), "alice", "cup"))
+		if assert.Len(t, pushed, 2) {
+			assert.Equal(t, entities.StreamPurchaseCompleted, pushed[0].Type)
+			assert.JSONEq(t, `{"user":"alice","item":"cup","quantity":1}`, string(pushed[0].Data))
+			assert.Equal(t, entities.StreamBalanceChanged, pushed[1].Type)
+			assert.JSONEq(t, `{"balance":965}`, string(pushed[1].Data))
+		}
+		assert.Equal(t, []int{1, 1}, hub.notified)
+	})
+
+	t.Run("transfer item", func(t *testing.T) {
+		pushed, hub.notified = nil, nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().TransferItem(gomock.Any(), 1, 2, 3, 2).Return(nil)
+
+		assert.NoError(t, userService.TransferItem(context.Background(), "alice", "bob", "cup", 2))
+		if assert.Len(t, pushed, 1) {
+			assert.Equal(t, 2, pushed[0].UserID)
+			assert.Equal(t, entities.StreamItemReceived, pushed[0].Type)
+			assert.JSONEq(t, `{"fromUser":"alice","toUser":"bob","item":"cup","quantity":2}`, string(pushed[0].Data))
+		}
+		assert.Equal(t, []int{2}, hub.notified)
+	})
+
+	t.Run("failed push rolls back", func(t *testing.T) {
+		errPush := errors.New("push failed")
+		failingRepo := repository.NewMockUserRepo(ctrl)
+		failingRepo.EXPECT().AddUserEvent(gomock.Any(), gomock.Any()).Return(errPush)
+		failing := NewUserService(mockRepo, passthroughTx{})
+		failing.Events = NewUserEvents(failingRepo, hub)
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().BuyItem(gomock.Any(), 1, 3).Return(nil)
+
+		assert.True(t, errors.Is(failing.BuyItem(context.Background(), "alice", "cup"), errPush))
+	})
+}
+
+func TestGetUserEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	events := []*entities.UserEvent{{ID: 5, UserID: 1, Type: entities.StreamBalanceChanged}}
+	mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+	mockRepo.EXPECT().GetUserEvents(gomock.Any(), 1, 4, MaxUserEventsBatch).Return(events, nil)
+
+	got, err := userService.GetUserEvents(context.Background(), "alice", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, events, got)
+
+	_, err = userService.GetUserEvents(context.Background(), "alice", -1)
+	assert.True(t, errors.Is(err, utils.ErrBadEventID))
+}
+
+func TestPruneUserEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+
+	mockRepo.EXPECT().DeleteUserEvents(gomock.Any(), now.Add(-DefaultEventRetention)).Return(3, nil)
+
+	pruned, err := userService.PruneUserEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pruned)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	DefaultTransfersLimit = 20
+	MaxTransfersLimit     = 100
+)
+
+// SearchHistory returns a page of the user's coin transfers, newest first,
+// narrowed by filter.
+func (u *UserService) SearchHistory(ctx context.Context, userName string, filter entities.TransferFilter, limit, offset int) (*entities.TransfersPage, error) {
+	switch filter.Direction {
+	case "", entities.TransferDirectionSent, entities.TransferDirectionReceived:
+	default:
+		return nil, utils.ErrBadDirection
+	}
+	if filter.Category != "" && !slices.Contains(entities.TransferCategories, filter.Category) {
+		return nil, utils.ErrBadCategory
+	}
+	if limit < 0 || offset < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if limit == 0 {
+		limit = DefaultTransfersLimit
+	}
+	limit = min(limit, MaxTransfersLimit)
+
+	page := &entities.TransfersPage{
+		Limit:  limit,
+		Offset: offset,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		if page.Total, err = u.UserRepo.CountTransfers(ctx, userID, filter); err != nil {
+			return err
+		}
+
+		page.Transfers, err = u.UserRepo.SearchTransfers(ctx, userID, filter, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
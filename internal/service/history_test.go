@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		filter := entities.TransferFilter{Direction: entities.TransferDirectionSent, Category: entities.TransferCategoryBet, Query: "bob"}
+		transfers := []*entities.CoinTransfer{{ID: 3, FromUser: "alice", ToUser: "bob", Amount: 10, Category: entities.TransferCategoryBet}}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountTransfers(gomock.Any(), 1, filter).Return(1, nil)
+		mockRepo.EXPECT().SearchTransfers(gomock.Any(), 1, filter, DefaultTransfersLimit, 0).Return(transfers, nil)
+
+		page, err := userService.SearchHistory(context.Background(), "alice", filter, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.TransfersPage{Transfers: transfers, Total: 1, Limit: DefaultTransfersLimit}, page)
+	})
+
+	t.Run("limit is capped", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountTransfers(gomock.Any(), 1, entities.TransferFilter{}).Return(0, nil)
+		mockRepo.EXPECT().SearchTransfers(gomock.Any(), 1, entities.TransferFilter{}, MaxTransfersLimit, 5).Return(nil, nil)
+
+		page, err := userService.SearchHistory(context.Background(), "alice", entities.TransferFilter{}, 1000, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, MaxTransfersLimit, page.Limit)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		_, err := userService.SearchHistory(context.Background(), "alice", entities.TransferFilter{Direction: "both"}, 0, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadDirection))
+
+		_, err = userService.SearchHistory(context.Background(), "alice", entities.TransferFilter{Category: "bribe"}, 0, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadCategory))
+
+		_, err = userService.SearchHistory(context.Background(), "alice", entities.TransferFilter{}, -1, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadPage))
+	})
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+// DefaultTransferLimits leave coin transfers unlimited.
+var DefaultTransferLimits = entities.TransferLimits{}
+
+// GetTransferLimits returns the limits applied to userName's transfers.
+func (u *UserService) GetTransferLimits(ctx context.Context, userName string) (*entities.UserTransferLimits, error) {
+	var limits *entities.UserTransferLimits
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		limits, err = u.UserRepo.GetTransferLimits(ctx, userID)
+		if errors.Is(err, utils.ErrNoTransferLimits) {
+			limits, err = &entities.UserTransferLimits{Username: userName, TransferLimits: u.TransferLimits}, nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// SetTransferLimits overrides the configured limits for userName. Every
+// limit is replaced; zero lifts it for this user.
+func (u *UserService) SetTransferLimits(ctx context.Context, adminName, userName string, limits entities.TransferLimits) (*entities.UserTransferLimits, error) {
+	if limits.MaxTransfer < 0 || limits.DailyAmount < 0 || limits.WeeklyAmount < 0 || limits.DailyRecipients < 0 {
+		return nil, utils.ErrBadLimit
+	}
+
+	var result *entities.UserTransferLimits
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		if err := u.UserRepo.SetTransferLimits(ctx, userID, limits, adminName); err != nil {
+			return err
+		}
+		err = u.auditTx(ctx, entities.AuditLimitsSet, adminName, userName, fmt.Sprintf("maxTransfer=%d dailyAmount=%d weeklyAmount=%d dailyRecipients=%d",
+			limits.MaxTransfer, limits.DailyAmount, limits.WeeklyAmount, limits.DailyRecipients))
+		if err != nil {
+			return err
+		}
+
+		result, err = u.UserRepo.GetTransferLimits(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ClearTransferLimits removes userName's override so the configured limits
+// apply again.
+func (u *UserService) ClearTransferLimits(ctx context.Context, adminName, userName string) error {
+	return u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+		if err := u.UserRepo.DeleteTransferLimits(ctx, userID); err != nil {
+			return err
+		}
+
+		return u.auditTx(ctx, entities.AuditLimitsCleared, adminName, userName, "")
+	})
+}
+
+// transferLimits returns the limits applied to userID: an admin override if
+// one is set, u.TransferLimits otherwise.
+func (u *UserService) transferLimits(ctx context.Context, userID int) (entities.TransferLimits, error) {
+	override, err := u.UserRepo.GetTransferLimits(ctx, userID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNoTransferLimits) {
+			return u.TransferLimits, nil
+		}
+		return entities.TransferLimits{}, err
+	}
+
+	return override.TransferLimits, nil
+}
+
+// checkTransferLimits fails with a *utils.TransferLimitError if sending
+// amount coins from fromUserID to toUserID would break one of limits. It
+// must run in the transaction making the transfer, so that concurrent
+// transfers cannot both fit under a limit only one of them should.
+func (u *UserService) checkTransferLimits(ctx context.Context, limits entities.TransferLimits, fromUserID, toUserID, amount int) error {
+	if limits.MaxTransfer > 0 && amount > limits.MaxTransfer {
+		return &utils.TransferLimitError{Limit: entities.LimitMaxTransfer, Max: limits.MaxTransfer}
+	}
+
+	now := u.Now()
+	if limits.DailyAmount > 0 || limits.DailyRecipients > 0 {
+		usage, err := u.UserRepo.GetTransferUsage(ctx, fromUserID, toUserID, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if limits.DailyAmount > 0 && usage.Amount+amount > limits.DailyAmount {
+			return &utils.TransferLimitError{Limit: entities.LimitDailyAmount, Max: limits.DailyAmount}
+		}
+		if limits.DailyRecipients > 0 && usage.Recipients+1 > limits.DailyRecipients {
+			return &utils.TransferLimitError{Limit: entities.LimitDailyRecipients, Max: limits.DailyRecipients}
+		}
+	}
+	if limits.WeeklyAmount > 0 {
+		usage, err := u.UserRepo.GetTransferUsage(ctx, fromUserID, toUserID, now.Add(-7*24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if usage.Amount+amount > limits.WeeklyAmount {
+			return &utils.TransferLimitError{Limit: entities.LimitWeeklyAmount, Max: limits.WeeklyAmount}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendCoinLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.TransferLimits = entities.TransferLimits{MaxTransfer: 100, DailyAmount: 200, WeeklyAmount: 500, DailyRecipients: 3}
+
+	mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil).AnyTimes()
+	mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil).AnyTimes()
+
+	limitHit := func(t *testing.T, err error, limit string, max int) {
+		t.Helper()
+		var limitErr *utils.TransferLimitError
+		assert.True(t, errors.As(err, &limitErr))
+		assert.True(t, errors.Is(err, utils.ErrTransferLimit))
+		assert.Equal(t, &utils.TransferLimitError{Limit: limit, Max: max}, limitErr)
+	}
+
+	t.Run("within limits", func(t *testing.T) {
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 1).Return(nil, utils.ErrNoTransferLimits)
+		mockRepo.EXPECT().GetTransferUsage(gomock.Any(), 1, 2, now.Add(-24*time.Hour)).Return(&entities.TransferUsage{Amount: 100, Recipients: 2}, nil)
+		mockRepo.EXPECT().GetTransferUsage(gomock.Any(), 1, 2, now.Add(-7*24*time.Hour)).Return(&entities.TransferUsage{Amount: 400}, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 100, "", entities.TransferCategoryOther).Return(nil)
+
+		assert.NoError(t, userService.SendCoin(context.Background(), "alice", "bob", 100, "", ""))
+	})
+
+	t.Run("max transfer", func(t *testing.T) {
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 1).Return(nil, utils.ErrNoTransferLimits)
+
+		err := userService.SendCoin(context.Background(), "alice", "bob", 101, "", "")
+		limitHit(t, err, entities.LimitMaxTransfer, 100)
+		assert.Equal(t, "transfers are limited to 100 coins each", err.Error())
+	})
+
+	t.Run("daily amount", func(t *testing.T) {
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 1).Return(nil, utils.ErrNoTransferLimits)
+		mockRepo.EXPECT().GetTransferUsage(gomock.Any(), 1, 2, now.Add(-24*time.Hour)).Return(&entities.TransferUsage{Amount: 150}, nil)
+
+		err := userService.SendCoin(context.Background(), "alice", "bob", 60, "", "")
+		limitHit(t, err, entities.LimitDailyAmount, 200)
+	})
+
+	t.Run("daily recipients", func(t *testing.T) {
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 1).Return(nil, utils.ErrNoTransferLimits)
+		mockRepo.EXPECT().GetTransferUsage(gomock.Any(), 1, 2, now.Add(-24*time.Hour)).Return(&entities.TransferUsage{Amount: 30, Recipients: 3}, nil)
+
+		err := userService.SendCoin(context.Background(), "alice", "bob", 10, "", "")
+		limitHit(t, err, entities.LimitDailyRecipients, 3)
+	})
+
+	t.Run("weekly amount", func(t *testing.T) {
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 1).Return(nil, utils.ErrNoTransferLimits)
+		mockRepo.EXPECT().GetTransferUsage(gomock.Any(), 1, 2, now.Add(-24*time.Hour)).Return(&entities.TransferUsage{}, nil)
+		mockRepo.EXPECT().GetTransferUsage(gomock.Any(), 1, 2, now.Add(-7*24*time.Hour)).Return(&entities.TransferUsage{Amount: 450}, nil)
+
+		err := userService.SendCoin(context.Background(), "alice", "bob", 60, "", "")
+		limitHit(t, err, entities.LimitWeeklyAmount, 500)
+	})
+
+	t.Run("admin override", func(t *testing.T) {
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 1).Return(&entities.UserTransferLimits{
+			TransferLimits: entities.TransferLimits{MaxTransfer: 1000},
+			Override:       true,
+		}, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 900, "", entities.TransferCategoryOther).Return(nil)
+
+		assert.NoError(t, userService.SendCoin(context.Background(), "alice", "bob", 900, "", ""))
+	})
+}
+
+func TestTransferLimitsAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.TransferLimits = entities.TransferLimits{DailyAmount: 200}
+
+	t.Run("defaults", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 2).Return(nil, utils.ErrNoTransferLimits)
+
+		limits, err := userService.GetTransferLimits(context.Background(), "bob")
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.UserTransferLimits{Username: "bob", TransferLimits: entities.TransferLimits{DailyAmount: 200}}, limits)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		limits := entities.TransferLimits{MaxTransfer: 50, DailyAmount: 1000}
+		override := &entities.UserTransferLimits{Username: "bob", TransferLimits: limits, Override: true, UpdatedBy: "admin"}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SetTransferLimits(gomock.Any(), 2, limits, "admin").Return(nil)
+		mockRepo.EXPECT().GetTransferLimits(gomock.Any(), 2).Return(override, nil)
+
+		result, err := userService.SetTransferLimits(context.Background(), "admin", "bob", limits)
+		assert.NoError(t, err)
+		assert.Equal(t, override, result)
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		_, err := userService.SetTransferLimits(context.Background(), "admin", "bob", entities.TransferLimits{WeeklyAmount: -1})
+		assert.True(t, errors.Is(err, utils.ErrBadLimit))
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().DeleteTransferLimits(gomock.Any(), 2).Return(utils.ErrNoTransferLimits)
+
+		err := userService.ClearTransferLimits(context.Background(), "admin", "bob")
+		assert.True(t, errors.Is(err, utils.ErrNoTransferLimits))
+	})
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+// MarketPolicy configures the marketplace. FeePercent of every sale is
+// burned rather than paid to the seller; listings are taken off the market
+// after ListingTTL.
+type MarketPolicy struct {
+	FeePercent int
+	ListingTTL time.Duration
+}
+
+var DefaultMarketPolicy = MarketPolicy{
+	FeePercent: 0,
+	ListingTTL: 7 * 24 * time.Hour,
+}
+
+const (
+	DefaultListingsLimit = 20
+	MaxListingsLimit     = 100
+)
+
+// CreateListing puts quantity units of an item the user holds up for sale
+// at price coins in total. The units are held in escrow until the listing
+// is sold, cancelled or expires.
+func (u *UserService) CreateListing(ctx context.Context, userName, itemName string, quantity, price int) (*entities.Listing, error) {
+	if quantity <= 0 {
+		return nil, utils.ErrBadQuantity
+	}
+	if price <= 0 {
+		return nil, utils.ErrBadPrice
+	}
+
+	var listing *entities.Listing
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		sellerID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+		itemID, err := u.UserRepo.GetItemID(ctx, itemName)
+		if err != nil {
+			return err
+		}
+
+		listingID, err := u.UserRepo.CreateListing(ctx, sellerID, itemID, quantity, price, u.Now().Add(u.Market.ListingTTL))
+		if err != nil {
+			return err
+		}
+
+		listing, err = u.UserRepo.GetListing(ctx, listingID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+// GetListings returns a page of listings that are still for sale, newest
+// first, optionally only those for itemName.
+func (u *UserService) GetListings(ctx context.Context, itemName string, limit, offset int) (*entities.ListingsPage, error) {
+	if limit < 0 || offset < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if limit == 0 {
+		limit = DefaultListingsLimit
+	}
+	limit = min(limit, MaxListingsLimit)
+
+	now := u.Now()
+	page := &entities.ListingsPage{
+		Limit:  limit,
+		Offset: offset,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		var err error
+		if page.Total, err = u.UserRepo.CountListings(ctx, itemName, now); err != nil {
+			return err
+		}
+
+		page.Listings, err = u.UserRepo.GetListings(ctx, itemName, now, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// BuyListing swaps the listing's units for its price: the units go to the
+// buyer, the price less the market fee to the seller.
+func (u *UserService) BuyListing(ctx context.Context, userName string, listingID int) (*entities.Listing, error) {
+	var listing *entities.Listing
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		buyerID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		listing, err = u.UserRepo.GetListing(ctx, listingID)
+		if err != nil {
+			return err
+		}
+		if listing.Status != entities.ListingOpen || !listing.ExpiresAt.After(u.Now()) {
+			return utils.ErrListingClosed
+		}
+		if listing.SellerID == buyerID {
+			return utils.ErrOwnListing
+		}
+
+		balanceChecked, err := u.watchBalance(ctx, buyerID, userName)
+		if err != nil {
+			return err
+		}
+		fee := listing.Price * u.Market.FeePercent / 100
+		if err := u.UserRepo.SellListing(ctx, listingID, buyerID, fee); err != nil {
+			return err
+		}
+		if err := balanceChecked(); err != nil {
+			return err
+		}
+
+		listing, err = u.UserRepo.GetListing(ctx, listingID)
+		if err != nil {
+			return err
+		}
+
+		purchase := entities.ItemPurchasedEvent{
+			User:      userName,
+			Item:      listing.ItemType,
+			Quantity:  listing.Quantity,
+			ListingID: listing.ID,
+		}
+		if err := u.webhook(ctx, buyerID, entities.WebhookItemPurchased, purchase); err != nil {
+			return err
+		}
+		if err := u.push(ctx, buyerID, entities.StreamPurchaseCompleted, purchase); err != nil {
+			return err
+		}
+		if err := u.pushBalance(ctx, buyerID, listing.SellerID); err != nil {
+			return err
+		}
+		err = u.auditTx(ctx, entities.AuditListingBought, userName, listing.Seller,
+			fmt.Sprintf("listing=%d item=%s quantity=%d price=%d", listing.ID, listing.ItemType, listing.Quantity, listing.Price))
+		if err != nil {
+			return err
+		}
+
+		return u.publish(ctx, entities.EventItemPurchased, userName, purchase)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.notify(ctx, &entities.Notification{
+		Recipient: listing.Seller,
+		Kind:      entities.NotificationListingSold,
+		Text:      fmt.Sprintf("%s bought your listing of %d %s for %d coins", userName, listing.Quantity, listing.ItemType, listing.Price-listing.Fee),
+	})
+	u.notify(ctx, &entities.Notification{
+		Recipient: userName,
+		Kind:      entities.NotificationPurchaseCompleted,
+		Text:      fmt.Sprintf("you bought %d %s from %s for %d coins", listing.Quantity, listing.ItemType, listing.Seller, listing.Price),
+	})
+
+	return listing, nil
+}
+
+// CancelListing takes an open listing off the market and returns its units
+// to the seller. Only the seller may cancel it.
+func (u *UserService) CancelListing(ctx context.Context, userName string, listingID int) error {
+	return u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		listing, err := u.UserRepo.GetListing(ctx, listingID)
+		if err != nil {
+			return err
+		}
+		if listing.SellerID != userID {
+			return utils.ErrForbidden
+		}
+
+		return u.UserRepo.CloseListing(ctx, listingID, entities.ListingCancelled)
+	})
+}
+
+// ExpireListings closes open listings past their expiry, returning their
+// units to the sellers, and reports how many were closed.
+func (u *UserService) ExpireListings(ctx context.Context) (int, error) {
+	var expired int
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		var err error
+		expired, err = u.UserRepo.ExpireListings(ctx, u.Now())
+		return err
+	})
+
+	return expired, err
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Market.ListingTTL = time.Hour
+
+	t.Run("success", func(t *testing.T) {
+		listing := &entities.Listing{ID: 7, Seller: "alice", ItemType: "cup", Quantity: 2, Price: 50, Status: entities.ListingOpen}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(2, nil)
+		mockRepo.EXPECT().CreateListing(gomock.Any(), 1, 2, 2, 50, now.Add(time.Hour)).Return(7, nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(listing, nil)
+
+		result, err := userService.CreateListing(context.Background(), "alice", "cup", 2, 50)
+		assert.NoError(t, err)
+		assert.Equal(t, listing, result)
+	})
+
+	t.Run("not enough items", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(2, nil)
+		mockRepo.EXPECT().CreateListing(gomock.Any(), 1, 2, 3, 50, now.Add(time.Hour)).Return(0, utils.ErrNotEnoughItems)
+
+		_, err := userService.CreateListing(context.Background(), "alice", "cup", 3, 50)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+	})
+
+	t.Run("bad quantity", func(t *testing.T) {
+		_, err := userService.CreateListing(context.Background(), "alice", "cup", 0, 50)
+		assert.True(t, errors.Is(err, utils.ErrBadQuantity))
+	})
+
+	t.Run("bad price", func(t *testing.T) {
+		_, err := userService.CreateListing(context.Background(), "alice", "cup", 1, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadPrice))
+	})
+}
+
+func TestGetListings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+
+	t.Run("default limit", func(t *testing.T) {
+		listings := []*entities.Listing{{ID: 1}}
+
+		mockRepo.EXPECT().CountListings(gomock.Any(), "cup", now).Return(1, nil)
+		mockRepo.EXPECT().GetListings(gomock.Any(), "cup", now, DefaultListingsLimit, 0).Return(listings, nil)
+
+		page, err := userService.GetListings(context.Background(), "cup", 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.ListingsPage{Listings: listings, Total: 1, Limit: DefaultListingsLimit}, page)
+	})
+
+	t.Run("limit is capped", func(t *testing.T) {
+		mockRepo.EXPECT().CountListings(gomock.Any(), "", now).Return(0, nil)
+		mockRepo.EXPECT().GetListings(gomock.Any(), "", now, MaxListingsLimit, 5).Return([]*entities.Listing{}, nil)
+
+		page, err := userService.GetListings(context.Background(), "", 1000, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, MaxListingsLimit, page.Limit)
+	})
+
+	t.Run("bad page", func(t *testing.T) {
+		_, err := userService.GetListings(context.Background(), "", -1, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadPage))
+	})
+}
+
+func TestBuyListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	notifier := &recordingNotifier{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Market.FeePercent = 5
+	userService.Notifier = notifier
+
+	open := &entities.Listing{
+		ID:        7,
+		SellerID:  1,
+		Seller:    "alice",
+		ItemType:  "cup",
+		Quantity:  2,
+		Price:     100,
+		Status:    entities.ListingOpen,
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	t.Run("success burns fee and notifies seller", func(t *testing.T) {
+		sold := *open
+		sold.Status = entities.ListingSold
+		sold.Buyer = "bob"
+		sold.Fee = 5
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(open, nil)
+		mockRepo.EXPECT().SellListing(gomock.Any(), 7, 2, 5).Return(nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(&sold, nil)
+
+		listing, err := userService.BuyListing(context.Background(), "bob", 7)
+		assert.NoError(t, err)
+		assert.Equal(t, &sold, listing)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "alice",
+			Kind:      entities.NotificationListingSold,
+			Text:      "bob bought your listing of 2 cup for 95 coins",
+		}, {
+			Recipient: "bob",
+			Kind:      entities.NotificationPurchaseCompleted,
+			Text:      "you bought 2 cup from alice for 100 coins",
+		}}, notifier.notifications)
+	})
+
+	t.Run("expired listing", func(t *testing.T) {
+		expired := *open
+		expired.ExpiresAt = now
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(&expired, nil)
+
+		_, err := userService.BuyListing(context.Background(), "bob", 7)
+		assert.True(t, errors.Is(err, utils.ErrListingClosed))
+	})
+
+	t.Run("own listing", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(open, nil)
+
+		_, err := userService.BuyListing(context.Background(), "alice", 7)
+		assert.True(t, errors.Is(err, utils.ErrOwnListing))
+	})
+
+	t.Run("not enough balance", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(open, nil)
+		mockRepo.EXPECT().SellListing(gomock.Any(), 7, 2, 5).Return(utils.ErrNotEnoughBalance)
+
+		_, err := userService.BuyListing(context.Background(), "bob", 7)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+	})
+}
+
+func TestCancelListing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	listing := &entities.Listing{ID: 7, SellerID: 1, Status: entities.ListingOpen}
+
+	t.Run("seller cancels", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(listing, nil)
+		mockRepo.EXPECT().CloseListing(gomock.Any(), 7, entities.ListingCancelled).Return(nil)
+
+		err := userService.CancelListing(context.Background(), "alice", 7)
+		assert.NoError(t, err)
+	})
+
+	t.Run("someone else's listing", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetListing(gomock.Any(), 7).Return(listing, nil)
+
+		err := userService.CancelListing(context.Background(), "bob", 7)
+		assert.True(t, errors.Is(err, utils.ErrForbidden))
+	})
+}
+
+func TestExpireListings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+
+	mockRepo.EXPECT().ExpireListings(gomock.Any(), now).Return(3, nil)
+
+	expired, err := userService.ExpireListings(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, expired)
+}
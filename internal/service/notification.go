@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	DefaultNotificationsLimit = 20
+	MaxNotificationsLimit     = 100
+)
+
+// Inbox is the Notifier that keeps notifications in the recipient's inbox,
+// unless they turned notifications of that kind off.
+type Inbox struct {
+	Repo UserRepo
+	Now  func() time.Time
+}
+
+func NewInbox(repo UserRepo) *Inbox {
+	return &Inbox{
+		Repo: repo,
+		Now:  time.Now,
+	}
+}
+
+func (i *Inbox) Notify(ctx context.Context, notification *entities.Notification) error {
+	userID, err := i.Repo.GetUserID(ctx, notification.Recipient)
+	if err != nil {
+		return err
+	}
+
+	preferences, err := i.Repo.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enabled, ok := preferences[notification.Kind]; ok && !enabled {
+		return nil
+	}
+
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = i.Now().UTC().Truncate(time.Microsecond)
+	}
+
+	return i.Repo.AddNotification(ctx, userID, notification)
+}
+
+// GetNotifications returns a page of the user's notifications, newest
+// first, optionally only the unread ones, with the number of unread
+// notifications. A zero limit means DefaultNotificationsLimit; larger limits
+// are capped at MaxNotificationsLimit.
+func (u *UserService) GetNotifications(ctx context.Context, userName string, unreadOnly bool, limit, offset int) (*entities.NotificationsPage, error) {
+	if limit < 0 || offset < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if limit == 0 {
+		limit = DefaultNotificationsLimit
+	}
+	limit = min(limit, MaxNotificationsLimit)
+
+	page := &entities.NotificationsPage{
+		Limit:  limit,
+		Offset: offset,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		if page.Unread, err = u.UserRepo.CountNotifications(ctx, userID, true); err != nil {
+			return err
+		}
+		page.Total = page.Unread
+		if !unreadOnly {
+			if page.Total, err = u.UserRepo.CountNotifications(ctx, userID, false); err != nil {
+				return err
+			}
+		}
+
+		page.Notifications, err = u.UserRepo.GetNotifications(ctx, userID, unreadOnly, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// MarkNotificationRead marks one of the user's notifications read. Marking
+// a read notification again is not an error.
+func (u *UserService) MarkNotificationRead(ctx context.Context, userName string, notificationID int) error {
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return err
+	}
+
+	return u.UserRepo.MarkNotificationRead(ctx, userID, notificationID, u.Now())
+}
+
+// MarkAllNotificationsRead marks all of the user's notifications read and
+// reports how many were unread.
+func (u *UserService) MarkAllNotificationsRead(ctx context.Context, userName string) (int, error) {
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return 0, err
+	}
+
+	return u.UserRepo.MarkAllNotificationsRead(ctx, userID, u.Now())
+}
+
+// GetNotificationPreferences returns, for every notification kind, whether
+// the user gets notifications of that kind. Kinds are on until turned off.
+func (u *UserService) GetNotificationPreferences(ctx context.Context, userName string) (entities.NotificationPreferences, error) {
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := u.UserRepo.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := make(entities.NotificationPreferences, len(entities.NotificationKinds))
+	for _, kind := range entities.NotificationKinds {
+		enabled, ok := stored[kind]
+		preferences[kind] = enabled || !ok
+	}
+
+	return preferences, nil
+}
+
+// SetNotificationPreferences turns the given notification kinds on or off
+// and returns the user's preferences. Kinds left out keep their setting.
+func (u *UserService) SetNotificationPreferences(ctx context.Context, userName string, preferences entities.NotificationPreferences) (entities.NotificationPreferences, error) {
+	for kind := range preferences {
+		if !slices.Contains(entities.NotificationKinds, kind) {
+			return nil, fmt.Errorf("%s: %w", kind, utils.ErrBadNotificationKind)
+		}
+	}
+
+	var result entities.NotificationPreferences
+	err := u.Tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		for kind, enabled := range preferences {
+			if err := u.UserRepo.SetNotificationPreference(ctx, userID, kind, enabled); err != nil {
+				return err
+			}
+		}
+
+		result, err = u.GetNotificationPreferences(ctx, userName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	inbox := NewInbox(mockRepo)
+	inbox.Now = func() time.Time { return now }
+
+	t.Run("stored in the recipient's inbox", func(t *testing.T) {
+		notification := &entities.Notification{Recipient: "bob", Kind: entities.NotificationCoinsReceived, Text: "alice sent you 50 coins"}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 2).Return(entities.NotificationPreferences{
+			entities.NotificationGiftReceived: false,
+		}, nil)
+		mockRepo.EXPECT().AddNotification(gomock.Any(), 2, notification).Return(nil)
+
+		assert.NoError(t, inbox.Notify(context.Background(), notification))
+		assert.Equal(t, now, notification.CreatedAt)
+	})
+
+	t.Run("kind turned off", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 2).Return(entities.NotificationPreferences{
+			entities.NotificationCoinsReceived: false,
+		}, nil)
+
+		assert.NoError(t, inbox.Notify(context.Background(), &entities.Notification{Recipient: "bob", Kind: entities.NotificationCoinsReceived}))
+	})
+
+	t.Run("unknown recipient", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "nobody").Return(0, utils.ErrNoUser)
+
+		err := inbox.Notify(context.Background(), &entities.Notification{Recipient: "nobody", Kind: entities.NotificationCoinsReceived})
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+	})
+}
+
+func TestNotificationsGenerated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	notifier := &recordingNotifier{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Notifier = notifier
+
+	t.Run("coins received", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 50, "lunch", entities.TransferCategoryOther).Return(nil)
+
+		assert.NoError(t, userService.SendCoin(context.Background(), "alice", "bob", 50, "lunch", ""))
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "bob",
+			Kind:      entities.NotificationCoinsReceived,
+			Text:      "alice sent you 50 coins: lunch",
+		}}, notifier.notifications)
+	})
+
+	t.Run("failed transfer notifies nobody", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 50, "", entities.TransferCategoryOther).Return(utils.ErrNotEnoughBalance)
+
+		assert.Error(t, userService.SendCoin(context.Background(), "alice", "bob", 50, "", ""))
+		assert.Empty(t, notifier.notifications)
+	})
+
+	t.Run("batch recipients", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(3, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(100, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 10, "", entities.TransferCategoryOther).Return(nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 3, 20, "", entities.TransferCategoryOther).Return(nil)
+
+		_, err := userService.BatchSendCoin(context.Background(), "alice", []entities.BatchTransfer{
+			{ToUser: "bob", Amount: 10},
+			{ToUser: "carol", Amount: 20},
+		}, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "bob",
+			Kind:      entities.NotificationCoinsReceived,
+			Text:      "alice sent you 10 coins",
+		}, {
+			Recipient: "carol",
+			Kind:      entities.NotificationCoinsReceived,
+			Text:      "alice sent you 20 coins",
+		}}, notifier.notifications)
+	})
+
+	t.Run("purchase completed", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "hoodie").Return(4, nil)
+		mockRepo.EXPECT().BuyItem(gomock.Any(), 1, 4).Return(nil)
+
+		assert.NoError(t, userService.BuyItem(context.Background(), "alice", "hoodie"))
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "alice",
+			Kind:      entities.NotificationPurchaseCompleted,
+			Text:      "you bought a hoodie",
+		}}, notifier.notifications)
+	})
+
+	t.Run("item received", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().TransferItem(gomock.Any(), 1, 2, 3, 2).Return(nil)
+
+		assert.NoError(t, userService.TransferItem(context.Background(), "alice", "bob", "cup", 2))
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "bob",
+			Kind:      entities.NotificationItemReceived,
+			Text:      "alice sent you 2 cup",
+		}}, notifier.notifications)
+	})
+
+	t.Run("item refunded", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "hoodie").Return(4, nil)
+		mockRepo.EXPECT().GetRefundableOrders(gomock.Any(), 1, 4).Return([]*entities.Order{
+			{ID: 9, ItemType: "hoodie", Quantity: 1, UnitPrice: 300, CreatedAt: now},
+		}, nil)
+		mockRepo.EXPECT().AddRefund(gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := userService.RefundItem(context.Background(), "alice", "hoodie", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "alice",
+			Kind:      entities.NotificationItemRefunded,
+			Text:      "your order of 1 hoodie was refunded: 300 coins returned",
+		}}, notifier.notifications)
+	})
+}
+
+func TestGetNotifications(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	notifications := []*entities.Notification{{ID: 3, Kind: entities.NotificationCoinsReceived, Text: "bob sent you 5 coins"}}
+
+	t.Run("default page", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountNotifications(gomock.Any(), 1, true).Return(1, nil)
+		mockRepo.EXPECT().CountNotifications(gomock.Any(), 1, false).Return(4, nil)
+		mockRepo.EXPECT().GetNotifications(gomock.Any(), 1, false, DefaultNotificationsLimit, 0).Return(notifications, nil)
+
+		page, err := userService.GetNotifications(context.Background(), "alice", false, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.NotificationsPage{
+			Notifications: notifications,
+			Unread:        1,
+			Total:         4,
+			Limit:         DefaultNotificationsLimit,
+		}, page)
+	})
+
+	t.Run("unread only with capped limit", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountNotifications(gomock.Any(), 1, true).Return(1, nil)
+		mockRepo.EXPECT().GetNotifications(gomock.Any(), 1, true, MaxNotificationsLimit, 10).Return(nil, nil)
+
+		page, err := userService.GetNotifications(context.Background(), "alice", true, 1000, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, MaxNotificationsLimit, page.Limit)
+	})
+
+	t.Run("bad page", func(t *testing.T) {
+		_, err := userService.GetNotifications(context.Background(), "alice", false, -1, 0)
+		assert.Equal(t, utils.ErrBadPage, err)
+	})
+
+	t.Run("mark read", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().MarkNotificationRead(gomock.Any(), 1, 3, gomock.Any()).Return(fmt.Errorf("memory mark notification read: %w", utils.ErrNoNotification))
+
+		err := userService.MarkNotificationRead(context.Background(), "alice", 3)
+		assert.True(t, errors.Is(err, utils.ErrNoNotification))
+	})
+
+	t.Run("mark all read", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().MarkAllNotificationsRead(gomock.Any(), 1, gomock.Any()).Return(2, nil)
+
+		marked, err := userService.MarkAllNotificationsRead(context.Background(), "alice")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, marked)
+	})
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	allOn := func() entities.NotificationPreferences {
+		preferences := make(entities.NotificationPreferences)
+		for _, kind := range entities.NotificationKinds {
+			preferences[kind] = true
+		}
+		return preferences
+	}
+
+	t.Run("kinds are on by default", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 1).Return(entities.NotificationPreferences{
+			entities.NotificationCoinsReceived: false,
+		}, nil)
+
+		want := allOn()
+		want[entities.NotificationCoinsReceived] = false
+
+		preferences, err := userService.GetNotificationPreferences(context.Background(), "alice")
+		assert.NoError(t, err)
+		assert.Equal(t, want, preferences)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil).Times(2)
+		mockRepo.EXPECT().SetNotificationPreference(gomock.Any(), 1, entities.NotificationItemRefunded, false).Return(nil)
+		mockRepo.EXPECT().GetNotificationPreferences(gomock.Any(), 1).Return(entities.NotificationPreferences{
+			entities.NotificationItemRefunded: false,
+		}, nil)
+
+		want := allOn()
+		want[entities.NotificationItemRefunded] = false
+
+		preferences, err := userService.SetNotificationPreferences(context.Background(), "alice", entities.NotificationPreferences{
+			entities.NotificationItemRefunded: false,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, want, preferences)
+	})
+
+	t.Run("unknown kind", func(t *testing.T) {
+		_, err := userService.SetNotificationPreferences(context.Background(), "alice", entities.NotificationPreferences{"spam": false})
+		assert.True(t, errors.Is(err, utils.ErrBadNotificationKind))
+	})
+}
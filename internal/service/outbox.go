@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+const (
+	// OutboxLeaseTTL is how long a relay may stay silent before another
+	// relay takes the outbox over.
+	OutboxLeaseTTL = 30 * time.Second
+
+	// outboxBatchSize is how many events RelayOutbox reads per call.
+	outboxBatchSize = 100
+
+	// A failed delivery is retried after outboxBaseRetry, doubled per
+	// failed attempt up to outboxMaxRetry.
+	outboxBaseRetry = time.Second
+	outboxMaxRetry  = 10 * time.Minute
+)
+
+// Publisher writes domain events to the outbox. Unlike Notifier and Auditor
+// it is called inside the transaction that makes the change, so an event is
+// stored if and only if the change is committed; an error rolls both back.
+type Publisher interface {
+	Publish(ctx context.Context, event *entities.OutboxEvent) error
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(ctx context.Context, event *entities.OutboxEvent) error {
+	return nil
+}
+
+// Outbox is the Publisher that stores events with the repository, for
+// RelayOutbox to deliver.
+type Outbox struct {
+	Repo UserRepo
+}
+
+func NewOutbox(repo UserRepo) *Outbox {
+	return &Outbox{
+		Repo: repo,
+	}
+}
+
+func (o *Outbox) Publish(ctx context.Context, event *entities.OutboxEvent) error {
+	return o.Repo.AddOutboxEvent(ctx, event)
+}
+
+// EventSink receives the events relayed from the outbox. Delivery is at
+// least once: an event is sent again after any failure, including a failure
+// of another sink, so sinks should treat the event id as an idempotency key.
+type EventSink interface {
+	Deliver(ctx context.Context, event *entities.OutboxEvent) error
+}
+
+// publish adds an event of type eventType to the outbox. Events with the
+// same key, the user they are about, are delivered in the order published.
+func (u *UserService) publish(ctx context.Context, eventType, key string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", eventType, err)
+	}
+
+	return u.Publisher.Publish(ctx, &entities.OutboxEvent{
+		Type:      eventType,
+		Key:       key,
+		Payload:   data,
+		CreatedAt: u.Now().UTC().Truncate(time.Microsecond),
+	})
+}
+
+// RelayOutbox delivers due outbox events to every sink and reports how many
+// were delivered. Only the relay holding the outbox lease delivers, so
+// replicas running this at the same time do not reorder events. A failed
+// event is retried with backoff, and the events after it with the same key
+// wait for it.
+func (u *UserService) RelayOutbox(ctx context.Context, relayID string) (int, error) {
+	if len(u.EventSinks) == 0 {
+		return 0, nil
+	}
+
+	var events []*entities.OutboxEvent
+	err := u.Tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+		now := u.Now()
+		acquired, err := u.UserRepo.AcquireOutboxLease(ctx, relayID, now, now.Add(OutboxLeaseTTL))
+		if err != nil || !acquired {
+			return err
+		}
+
+		events, err = u.UserRepo.GetDueOutboxEvents(ctx, now, outboxBatchSize)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	failed := make(map[string]bool)
+	for _, event := range events {
+		if failed[event.Key] {
+			continue
+		}
+
+		// Renewing the lease per event keeps a slow sink from letting
+		// another relay start on the same events.
+		now := u.Now()
+		acquired, err := u.UserRepo.AcquireOutboxLease(ctx, relayID, now, now.Add(OutboxLeaseTTL))
+		if err != nil {
+			return delivered, err
+		}
+		if !acquired {
+			return delivered, nil
+		}
+
+		if err := u.deliver(ctx, event); err != nil {
+			log.Printf("relay outbox event %d (%s): %v", event.ID, event.Type, err)
+			failed[event.Key] = true
+			retryAt := u.Now().Add(outboxRetryDelay(event.Attempts + 1))
+			if err := u.UserRepo.DeferOutboxEvent(ctx, event.ID, err.Error(), retryAt); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		if err := u.UserRepo.MarkOutboxDelivered(ctx, event.ID, u.Now()); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+func (u *UserService) deliver(ctx context.Context, event *entities.OutboxEvent) error {
+	for _, sink := range u.EventSinks {
+		if err := sink.Deliver(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := outboxBaseRetry
+	for i := 1; i < attempts && delay < outboxMaxRetry; i++ {
+		delay *= 2
+	}
+
+	return min(delay, outboxMaxRetry)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingPublisher struct {
+	events []*entities.OutboxEvent
+	err    error
+}
+
+func (r *recordingPublisher) Publish(ctx context.Context, event *entities.OutboxEvent) error {
+	r.events = append(r.events, event)
+	return r.err
+}
+
+type recordingSink struct {
+	delivered []int
+	fail      map[int]bool
+}
+
+func (r *recordingSink) Deliver(ctx context.Context, event *entities.OutboxEvent) error {
+	if r.fail[event.ID] {
+		return errors.New("sink down")
+	}
+	r.delivered = append(r.delivered, event.ID)
+	return nil
+}
+
+func TestPublishedEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	publisher := &recordingPublisher{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Publisher = publisher
+
+	t.Run("coins sent", func(t *testing.T) {
+		publisher.events = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 15, "rent", entities.TransferCategoryOther).Return(nil)
+
+		assert.NoError(t, userService.SendCoin(context.Background(), "alice", "bob", 15, "rent", ""))
+		if assert.Len(t, publisher.events, 1) {
+			event := publisher.events[0]
+			assert.Equal(t, entities.EventCoinsSent, event.Type)
+			assert.Equal(t, "alice", event.Key)
+			assert.Equal(t, now, event.CreatedAt)
+			assert.JSONEq(t, `{"fromUser":"alice","toUser":"bob","amount":15,"memo":"rent","category":"other"}`, string(event.Payload))
+		}
+	})
+
+	t.Run("item purchased", func(t *testing.T) {
+		publisher.events = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().BuyItem(gomock.Any(), 1, 3).Return(nil)
+
+		assert.NoError(t, userService.BuyItem(context.Background(), "alice", "cup"))
+		if assert.Len(t, publisher.events, 1) {
+			assert.Equal(t, entities.EventItemPurchased, publisher.events[0].Type)
+			assert.JSONEq(t, `{"user":"alice","item":"cup","quantity":1}`, string(publisher.events[0].Payload))
+		}
+	})
+
+	t.Run("item transferred", func(t *testing.T) {
+		publisher.events = nil
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().TransferItem(gomock.Any(), 1, 2, 3, 2).Return(nil)
+
+		assert.NoError(t, userService.TransferItem(context.Background(), "alice", "bob", "cup", 2))
+		if assert.Len(t, publisher.events, 1) {
+			assert.Equal(t, entities.EventItemTransferred, publisher.events[0].Type)
+			assert.Equal(t, "alice", publisher.events[0].Key)
+			assert.JSONEq(t, `{"fromUser":"alice","toUser":"bob","item":"cup","quantity":2}`, string(publisher.events[0].Payload))
+		}
+	})
+
+	t.Run("user registered", func(t *testing.T) {
+		publisher.events = nil
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "carol").Return(&entities.LoginAttempts{Username: "carol"}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), "carol", "password").Return(&entities.User{Username: "carol", Registered: true}, nil)
+
+		_, err := userService.Auth(context.Background(), "carol", "password")
+		assert.NoError(t, err)
+		if assert.Len(t, publisher.events, 1) {
+			assert.Equal(t, entities.EventUserRegistered, publisher.events[0].Type)
+			assert.Equal(t, "carol", publisher.events[0].Key)
+		}
+	})
+
+	t.Run("failed publish fails the change", func(t *testing.T) {
+		publisher.events = nil
+		publisher.err = errors.New("outbox unavailable")
+		defer func() { publisher.err = nil }()
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 15, "", entities.TransferCategoryOther).Return(nil)
+
+		assert.Error(t, userService.SendCoin(context.Background(), "alice", "bob", 15, "", ""))
+	})
+}
+
+func TestRelayOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	sink := &recordingSink{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.EventSinks = []EventSink{sink}
+
+	events := []*entities.OutboxEvent{
+		{ID: 1, Type: entities.EventCoinsSent, Key: "alice", Payload: json.RawMessage(`{}`), Attempts: 2},
+		{ID: 2, Type: entities.EventCoinsSent, Key: "bob", Payload: json.RawMessage(`{}`)},
+		{ID: 3, Type: entities.EventCoinsSent, Key: "alice", Payload: json.RawMessage(`{}`)},
+	}
+
+	t.Run("failure holds back the key", func(t *testing.T) {
+		sink.delivered = nil
+		sink.fail = map[int]bool{1: true}
+
+		mockRepo.EXPECT().AcquireOutboxLease(gomock.Any(), "relay-1", now, now.Add(OutboxLeaseTTL)).Return(true, nil).Times(3)
+		mockRepo.EXPECT().GetDueOutboxEvents(gomock.Any(), now, outboxBatchSize).Return(events, nil)
+		mockRepo.EXPECT().DeferOutboxEvent(gomock.Any(), 1, "sink down", now.Add(4*time.Second)).Return(nil)
+		mockRepo.EXPECT().MarkOutboxDelivered(gomock.Any(), 2, now).Return(nil)
+
+		delivered, err := userService.RelayOutbox(context.Background(), "relay-1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, []int{2}, sink.delivered)
+	})
+
+	t.Run("lease held by another relay", func(t *testing.T) {
+		sink.delivered = nil
+
+		mockRepo.EXPECT().AcquireOutboxLease(gomock.Any(), "relay-2", now, now.Add(OutboxLeaseTTL)).Return(false, nil)
+
+		delivered, err := userService.RelayOutbox(context.Background(), "relay-2")
+		assert.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.Empty(t, sink.delivered)
+	})
+}
+
+func TestOutboxRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, outboxRetryDelay(1))
+	assert.Equal(t, 8*time.Second, outboxRetryDelay(4))
+	assert.Equal(t, outboxMaxRetry, outboxRetryDelay(100))
+}
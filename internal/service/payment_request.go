@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	DefaultPaymentRequestTTL = 72 * time.Hour
+
+	DefaultPaymentRequestsLimit = 20
+	MaxPaymentRequestsLimit     = 100
+)
+
+// RequestPayment asks payer to send amount coins to requester. The request
+// stays pending until the payer answers it, the requester cancels it or it
+// expires after PaymentRequestTTL; the payer is notified.
+func (u *UserService) RequestPayment(ctx context.Context, requester, payer string, amount int, memo, category string) (*entities.PaymentRequest, error) {
+	if amount <= 0 {
+		return nil, utils.ErrBadAmount
+	}
+	if payer == requester {
+		return nil, utils.ErrSelfRequest
+	}
+	memo, category, err := transferDetails(memo, category)
+	if err != nil {
+		return nil, err
+	}
+
+	var request *entities.PaymentRequest
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		requesterID, err := u.UserRepo.GetUserID(ctx, requester)
+		if err != nil {
+			return err
+		}
+		payerID, err := u.UserRepo.GetUserID(ctx, payer)
+		if err != nil {
+			return err
+		}
+
+		requestID, err := u.UserRepo.CreatePaymentRequest(ctx, &entities.PaymentRequest{
+			RequesterID: requesterID,
+			PayerID:     payerID,
+			Amount:      amount,
+			Memo:        memo,
+			Category:    category,
+			ExpiresAt:   u.Now().Add(u.PaymentRequestTTL),
+		})
+		if err != nil {
+			return err
+		}
+
+		request, err = u.UserRepo.GetPaymentRequest(ctx, requestID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.notifyPaymentRequest(ctx, request.Payer, fmt.Sprintf("%s requested %d coins from you", requester, amount), request.Memo)
+
+	return request, nil
+}
+
+// GetPaymentRequests returns a page of the user's payment requests, newest
+// first, narrowed by filter.
+func (u *UserService) GetPaymentRequests(ctx context.Context, userName string, filter entities.PaymentRequestFilter, limit, offset int) (*entities.PaymentRequestsPage, error) {
+	switch filter.Direction {
+	case "", entities.PaymentRequestIncoming, entities.PaymentRequestOutgoing:
+	default:
+		return nil, utils.ErrBadRequestDirection
+	}
+	if filter.Status != "" && !slices.Contains(entities.PaymentRequestStatuses, filter.Status) {
+		return nil, utils.ErrBadStatus
+	}
+	if limit < 0 || offset < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if limit == 0 {
+		limit = DefaultPaymentRequestsLimit
+	}
+	limit = min(limit, MaxPaymentRequestsLimit)
+
+	page := &entities.PaymentRequestsPage{
+		Limit:  limit,
+		Offset: offset,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		if page.Total, err = u.UserRepo.CountPaymentRequests(ctx, userID, filter); err != nil {
+			return err
+		}
+
+		page.Requests, err = u.UserRepo.GetPaymentRequests(ctx, userID, filter, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// AcceptPaymentRequest pays a pending request addressed to userName the way
+// SendCoin would. If the payer cannot afford it the request stays pending.
+func (u *UserService) AcceptPaymentRequest(ctx context.Context, userName string, requestID int) (*entities.PaymentRequest, error) {
+	request, err := u.answerPaymentRequest(ctx, userName, requestID, entities.PaymentRequestAccepted, func(ctx context.Context, request *entities.PaymentRequest) error {
+		return u.sendCoin(ctx, request.Payer, request.Requester, request.Amount, request.Memo, request.Category)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.notifyPaymentRequest(ctx, request.Requester, fmt.Sprintf("%s paid your request for %d coins", userName, request.Amount), request.Memo)
+
+	return request, nil
+}
+
+// DeclinePaymentRequest turns down a pending request addressed to userName.
+func (u *UserService) DeclinePaymentRequest(ctx context.Context, userName string, requestID int) (*entities.PaymentRequest, error) {
+	request, err := u.answerPaymentRequest(ctx, userName, requestID, entities.PaymentRequestDeclined, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	u.notifyPaymentRequest(ctx, request.Requester, fmt.Sprintf("%s declined your request for %d coins", userName, request.Amount), request.Memo)
+
+	return request, nil
+}
+
+// CancelPaymentRequest withdraws a pending request userName made.
+func (u *UserService) CancelPaymentRequest(ctx context.Context, userName string, requestID int) error {
+	var request *entities.PaymentRequest
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		var err error
+		request, err = u.UserRepo.GetPaymentRequest(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		if request.Requester != userName {
+			return utils.ErrForbidden
+		}
+
+		return u.UserRepo.ClosePaymentRequest(ctx, requestID, entities.PaymentRequestCancelled)
+	})
+	if err != nil {
+		return err
+	}
+
+	u.notifyPaymentRequest(ctx, request.Payer, fmt.Sprintf("%s cancelled their request for %d coins", userName, request.Amount), request.Memo)
+
+	return nil
+}
+
+// ExpirePaymentRequests closes pending requests past their expiry, tells
+// both parties and reports how many were closed.
+func (u *UserService) ExpirePaymentRequests(ctx context.Context) (int, error) {
+	var expired []*entities.PaymentRequest
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		var err error
+		expired, err = u.UserRepo.ExpirePaymentRequests(ctx, u.Now())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, request := range expired {
+		u.notifyPaymentRequest(ctx, request.Requester, fmt.Sprintf("your request to %s for %d coins expired", request.Payer, request.Amount), request.Memo)
+		u.notifyPaymentRequest(ctx, request.Payer, fmt.Sprintf("%s's request for %d coins expired", request.Requester, request.Amount), request.Memo)
+	}
+
+	return len(expired), nil
+}
+
+// answerPaymentRequest closes a pending request addressed to payer with
+// status, running action first if it is not nil, and returns the updated
+// request.
+func (u *UserService) answerPaymentRequest(ctx context.Context, payer string, requestID int, status string, action func(ctx context.Context, request *entities.PaymentRequest) error) (*entities.PaymentRequest, error) {
+	var request *entities.PaymentRequest
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		var err error
+		request, err = u.UserRepo.GetPaymentRequest(ctx, requestID)
+		if err != nil {
+			return err
+		}
+		if request.Payer != payer {
+			return utils.ErrForbidden
+		}
+		if request.Status != entities.PaymentRequestPending || !request.ExpiresAt.After(u.Now()) {
+			return utils.ErrPaymentRequestClosed
+		}
+
+		// Closing the request first makes a concurrent answer or cancel
+		// find it no longer pending.
+		if err := u.UserRepo.ClosePaymentRequest(ctx, requestID, status); err != nil {
+			return err
+		}
+		if action != nil {
+			if err := action(ctx, request); err != nil {
+				return err
+			}
+		}
+
+		request, err = u.UserRepo.GetPaymentRequest(ctx, requestID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func (u *UserService) notifyPaymentRequest(ctx context.Context, recipient, text, memo string) {
+	if memo != "" {
+		text += ": " + memo
+	}
+
+	u.notify(ctx, &entities.Notification{
+		Recipient: recipient,
+		Kind:      entities.NotificationPaymentRequest,
+		Text:      text,
+	})
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestPayment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	notifier := &recordingNotifier{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.PaymentRequestTTL = time.Hour
+	userService.Notifier = notifier
+
+	t.Run("success", func(t *testing.T) {
+		request := &entities.PaymentRequest{ID: 3, Requester: "alice", Payer: "bob", Amount: 15, Memo: "lunch", Status: entities.PaymentRequestPending}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().CreatePaymentRequest(gomock.Any(), &entities.PaymentRequest{
+			RequesterID: 1,
+			PayerID:     2,
+			Amount:      15,
+			Memo:        "lunch",
+			Category:    entities.TransferCategoryReimbursement,
+			ExpiresAt:   now.Add(time.Hour),
+		}).Return(3, nil)
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(request, nil)
+
+		result, err := userService.RequestPayment(context.Background(), "alice", "bob", 15, "lunch", entities.TransferCategoryReimbursement)
+		assert.NoError(t, err)
+		assert.Equal(t, request, result)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "bob",
+			Kind:      entities.NotificationPaymentRequest,
+			Text:      "alice requested 15 coins from you: lunch",
+		}}, notifier.notifications)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		_, err := userService.RequestPayment(context.Background(), "alice", "bob", 0, "", "")
+		assert.True(t, errors.Is(err, utils.ErrBadAmount))
+
+		_, err = userService.RequestPayment(context.Background(), "alice", "alice", 10, "", "")
+		assert.True(t, errors.Is(err, utils.ErrSelfRequest))
+
+		_, err = userService.RequestPayment(context.Background(), "alice", "bob", 10, "", "bribe")
+		assert.True(t, errors.Is(err, utils.ErrBadCategory))
+	})
+}
+
+func TestGetPaymentRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		filter := entities.PaymentRequestFilter{Direction: entities.PaymentRequestIncoming, Status: entities.PaymentRequestPending}
+		requests := []*entities.PaymentRequest{{ID: 3, Requester: "bob", Payer: "alice", Amount: 15}}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountPaymentRequests(gomock.Any(), 1, filter).Return(1, nil)
+		mockRepo.EXPECT().GetPaymentRequests(gomock.Any(), 1, filter, DefaultPaymentRequestsLimit, 0).Return(requests, nil)
+
+		page, err := userService.GetPaymentRequests(context.Background(), "alice", filter, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.PaymentRequestsPage{Requests: requests, Total: 1, Limit: DefaultPaymentRequestsLimit}, page)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		_, err := userService.GetPaymentRequests(context.Background(), "alice", entities.PaymentRequestFilter{Direction: "sent"}, 0, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadRequestDirection))
+
+		_, err = userService.GetPaymentRequests(context.Background(), "alice", entities.PaymentRequestFilter{Status: "paid"}, 0, 0)
+		assert.True(t, errors.Is(err, utils.ErrBadStatus))
+
+		_, err = userService.GetPaymentRequests(context.Background(), "alice", entities.PaymentRequestFilter{}, 0, -1)
+		assert.True(t, errors.Is(err, utils.ErrBadPage))
+	})
+}
+
+func TestAnswerPaymentRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	notifier := &recordingNotifier{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Notifier = notifier
+
+	pending := func() *entities.PaymentRequest {
+		return &entities.PaymentRequest{
+			ID:        3,
+			Requester: "alice",
+			Payer:     "bob",
+			Amount:    15,
+			Category:  entities.TransferCategoryOther,
+			Status:    entities.PaymentRequestPending,
+			ExpiresAt: now.Add(time.Hour),
+		}
+	}
+
+	t.Run("accept", func(t *testing.T) {
+		notifier.notifications = nil
+		accepted := pending()
+		accepted.Status = entities.PaymentRequestAccepted
+
+		gomock.InOrder(
+			mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(pending(), nil),
+			mockRepo.EXPECT().ClosePaymentRequest(gomock.Any(), 3, entities.PaymentRequestAccepted).Return(nil),
+			mockRepo.EXPECT().SendCoin(gomock.Any(), 2, 1, 15, "", entities.TransferCategoryOther).Return(nil),
+			mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(accepted, nil),
+		)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+
+		request, err := userService.AcceptPaymentRequest(context.Background(), "bob", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, accepted, request)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "alice",
+			Kind:      entities.NotificationPaymentRequest,
+			Text:      "bob paid your request for 15 coins",
+		}}, notifier.notifications)
+	})
+
+	t.Run("accept without enough balance", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(pending(), nil)
+		mockRepo.EXPECT().ClosePaymentRequest(gomock.Any(), 3, entities.PaymentRequestAccepted).Return(nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 2, 1, 15, "", entities.TransferCategoryOther).Return(utils.ErrNotEnoughBalance)
+
+		_, err := userService.AcceptPaymentRequest(context.Background(), "bob", 3)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughBalance))
+		assert.Empty(t, notifier.notifications)
+	})
+
+	t.Run("decline", func(t *testing.T) {
+		notifier.notifications = nil
+		declined := pending()
+		declined.Status = entities.PaymentRequestDeclined
+
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(pending(), nil)
+		mockRepo.EXPECT().ClosePaymentRequest(gomock.Any(), 3, entities.PaymentRequestDeclined).Return(nil)
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(declined, nil)
+
+		request, err := userService.DeclinePaymentRequest(context.Background(), "bob", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.PaymentRequestDeclined, request.Status)
+		assert.Equal(t, "bob declined your request for 15 coins", notifier.notifications[0].Text)
+	})
+
+	t.Run("not the payer", func(t *testing.T) {
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(pending(), nil)
+
+		_, err := userService.AcceptPaymentRequest(context.Background(), "alice", 3)
+		assert.True(t, errors.Is(err, utils.ErrForbidden))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		request := pending()
+		request.ExpiresAt = now
+
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(request, nil)
+
+		_, err := userService.AcceptPaymentRequest(context.Background(), "bob", 3)
+		assert.True(t, errors.Is(err, utils.ErrPaymentRequestClosed))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(pending(), nil)
+		mockRepo.EXPECT().ClosePaymentRequest(gomock.Any(), 3, entities.PaymentRequestCancelled).Return(nil)
+
+		assert.NoError(t, userService.CancelPaymentRequest(context.Background(), "alice", 3))
+		assert.Equal(t, "bob", notifier.notifications[0].Recipient)
+	})
+
+	t.Run("cancel someone else's request", func(t *testing.T) {
+		mockRepo.EXPECT().GetPaymentRequest(gomock.Any(), 3).Return(pending(), nil)
+
+		err := userService.CancelPaymentRequest(context.Background(), "bob", 3)
+		assert.True(t, errors.Is(err, utils.ErrForbidden))
+	})
+}
+
+func TestExpirePaymentRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	notifier := &recordingNotifier{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Notifier = notifier
+
+	t.Run("both parties told", func(t *testing.T) {
+		notifier.notifications = nil
+
+		mockRepo.EXPECT().ExpirePaymentRequests(gomock.Any(), now).Return([]*entities.PaymentRequest{
+			{ID: 3, Requester: "alice", Payer: "bob", Amount: 15, Memo: "lunch", Status: entities.PaymentRequestExpired},
+		}, nil)
+
+		expired, err := userService.ExpirePaymentRequests(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "alice",
+			Kind:      entities.NotificationPaymentRequest,
+			Text:      "your request to bob for 15 coins expired: lunch",
+		}, {
+			Recipient: "bob",
+			Kind:      entities.NotificationPaymentRequest,
+			Text:      "alice's request for 15 coins expired: lunch",
+		}}, notifier.notifications)
+	})
+
+	t.Run("failed sweep notifies nobody", func(t *testing.T) {
+		notifier.notifications = nil
+		someError := errors.New("db is down")
+
+		mockRepo.EXPECT().ExpirePaymentRequests(gomock.Any(), now).Return(nil, someError)
+
+		_, err := userService.ExpirePaymentRequests(context.Background())
+		assert.True(t, errors.Is(err, someError))
+		assert.Empty(t, notifier.notifications)
+	})
+}
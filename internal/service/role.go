@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+var roles = []string{entities.RoleUser, entities.RoleAdmin, entities.RoleAuditor}
+
+// SetUserRole changes a registered user's role. The new role is in the
+// tokens the user gets from the next login on.
+func (u *UserService) SetUserRole(ctx context.Context, actor, userName, role string) error {
+	if !slices.Contains(roles, role) {
+		return utils.ErrBadRole
+	}
+
+	return u.Tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+		if err := u.UserRepo.SetUserRole(ctx, userName, role); err != nil {
+			return err
+		}
+
+		return u.auditTx(ctx, entities.AuditRoleChanged, actor, userName, "role="+role)
+	})
+}
+
+// applyConfiguredRole gives user the role u.Roles names for them, if any,
+// and reports whether it changed. Accounts the login just created are left
+// alone: anyone may register an unused name, so a listed name nobody has
+// claimed yet must not hand its role to whoever logs in first.
+func (u *UserService) applyConfiguredRole(ctx context.Context, user *entities.User) (bool, error) {
+	role, ok := u.Roles[user.Username]
+	if !ok || role == user.Role || user.Registered {
+		return false, nil
+	}
+
+	if err := u.UserRepo.SetUserRole(ctx, user.Username, role); err != nil {
+		return false, err
+	}
+	user.Role = role
+
+	return true, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetUserRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	auditor := &recordingAuditor{}
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Auditor = auditor
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().SetUserRole(gomock.Any(), "alice", entities.RoleAuditor).Return(nil)
+
+		assert.NoError(t, userService.SetUserRole(context.Background(), "cli", "alice", entities.RoleAuditor))
+		if assert.Len(t, auditor.events, 1) {
+			assert.Equal(t, entities.AuditRoleChanged, auditor.events[0].Action)
+			assert.Equal(t, "cli", auditor.events[0].Actor)
+			assert.Equal(t, "alice", auditor.events[0].Target)
+			assert.Equal(t, "role=auditor", auditor.events[0].Details)
+		}
+	})
+
+	t.Run("bad role", func(t *testing.T) {
+		err := userService.SetUserRole(context.Background(), "cli", "alice", "root")
+		assert.True(t, errors.Is(err, utils.ErrBadRole))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mockRepo.EXPECT().SetUserRole(gomock.Any(), "nobody", entities.RoleAdmin).Return(utils.ErrNoUser)
+
+		err := userService.SetUserRole(context.Background(), "cli", "nobody", entities.RoleAdmin)
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+	})
+}
+
+func TestConfiguredRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	auditor := &recordingAuditor{}
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Auditor = auditor
+	userService.Roles = map[string]string{"root": entities.RoleAdmin}
+
+	t.Run("granted at login", func(t *testing.T) {
+		auditor.events = nil
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "root").Return(&entities.LoginAttempts{Username: "root"}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), "root", "password").Return(&entities.User{Username: "root", Role: entities.RoleUser}, nil)
+		mockRepo.EXPECT().SetUserRole(gomock.Any(), "root", entities.RoleAdmin).Return(nil)
+
+		user, err := userService.Auth(context.Background(), "root", "password")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.RoleAdmin, user.Role)
+		if assert.Len(t, auditor.events, 2) {
+			assert.Equal(t, entities.AuditRoleChanged, auditor.events[0].Action)
+			assert.Equal(t, SystemActor, auditor.events[0].Actor)
+		}
+	})
+
+	t.Run("already granted", func(t *testing.T) {
+		auditor.events = nil
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "root").Return(&entities.LoginAttempts{Username: "root"}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), "root", "password").Return(&entities.User{Username: "root", Role: entities.RoleAdmin}, nil)
+
+		user, err := userService.Auth(context.Background(), "root", "password")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.RoleAdmin, user.Role)
+		assert.Len(t, auditor.events, 1)
+	})
+
+	t.Run("not on the login that registers", func(t *testing.T) {
+		auditor.events = nil
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "root").Return(&entities.LoginAttempts{Username: "root"}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), "root", "password").Return(&entities.User{Username: "root", Role: entities.RoleUser, Registered: true}, nil)
+
+		user, err := userService.Auth(context.Background(), "root", "password")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.RoleUser, user.Role)
+		for _, event := range auditor.events {
+			assert.NotEqual(t, entities.AuditRoleChanged, event.Action)
+		}
+	})
+
+	t.Run("unlisted user", func(t *testing.T) {
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), "alice").Return(&entities.LoginAttempts{Username: "alice"}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), "alice", "password").Return(&entities.User{Username: "alice", Role: entities.RoleUser}, nil)
+
+		user, err := userService.Auth(context.Background(), "alice", "password")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.RoleUser, user.Role)
+	})
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+const (
+	// MaxScheduleRuns is how many of the latest runs GetSchedule returns.
+	MaxScheduleRuns = 20
+	// maxRunsPerSweep bounds the work a single RunDueSchedules call does.
+	maxRunsPerSweep = 100
+)
+
+// scheduleRunFailures are the errors that fail a single run of a scheduled
+// transfer instead of aborting the sweep, e.g. a sender short of coins.
+var scheduleRunFailures = []error{
+	utils.ErrNotEnoughBalance,
+	utils.ErrNoUser,
+	utils.ErrBadAmount,
+	utils.ErrMemoTooLong,
+	utils.ErrBadCategory,
+}
+
+// CreateSchedule schedules a transfer from userName at req.RunAt, repeated
+// weekly or monthly after that if req.Recurrence asks for it.
+func (u *UserService) CreateSchedule(ctx context.Context, userName string, req entities.ScheduleRequest) (*entities.ScheduledTransfer, error) {
+	schedule, err := u.validateSchedule(userName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		if schedule.FromUserID, err = u.UserRepo.GetUserID(ctx, userName); err != nil {
+			return err
+		}
+		if schedule.ToUserID, err = u.UserRepo.GetUserID(ctx, req.ToUser); err != nil {
+			return err
+		}
+
+		scheduleID, err := u.UserRepo.CreateSchedule(ctx, schedule)
+		if err != nil {
+			return err
+		}
+
+		schedule, err = u.UserRepo.GetSchedule(ctx, scheduleID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// GetSchedules returns the user's scheduled transfers, newest first.
+func (u *UserService) GetSchedules(ctx context.Context, userName string) ([]*entities.ScheduledTransfer, error) {
+	var schedules []*entities.ScheduledTransfer
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		schedules, err = u.UserRepo.GetSchedules(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// GetSchedule returns one of the user's scheduled transfers with its latest
+// runs.
+func (u *UserService) GetSchedule(ctx context.Context, userName string, scheduleID int) (*entities.ScheduledTransfer, error) {
+	var schedule *entities.ScheduledTransfer
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		var err error
+		if schedule, err = u.ownSchedule(ctx, userName, scheduleID); err != nil {
+			return err
+		}
+
+		schedule.Runs, err = u.UserRepo.GetScheduleRuns(ctx, scheduleID, MaxScheduleRuns)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// UpdateSchedule replaces the settings of one of the user's active
+// scheduled transfers.
+func (u *UserService) UpdateSchedule(ctx context.Context, userName string, scheduleID int, req entities.ScheduleRequest) (*entities.ScheduledTransfer, error) {
+	updated, err := u.validateSchedule(userName, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule *entities.ScheduledTransfer
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		if _, err := u.ownSchedule(ctx, userName, scheduleID); err != nil {
+			return err
+		}
+
+		updated.ID = scheduleID
+		if updated.ToUserID, err = u.UserRepo.GetUserID(ctx, req.ToUser); err != nil {
+			return err
+		}
+		if err := u.UserRepo.UpdateSchedule(ctx, updated); err != nil {
+			return err
+		}
+
+		schedule, err = u.UserRepo.GetSchedule(ctx, scheduleID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// CancelSchedule stops one of the user's scheduled transfers. Runs already
+// made are kept.
+func (u *UserService) CancelSchedule(ctx context.Context, userName string, scheduleID int) error {
+	return u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		if _, err := u.ownSchedule(ctx, userName, scheduleID); err != nil {
+			return err
+		}
+
+		return u.UserRepo.CloseSchedule(ctx, scheduleID, entities.ScheduleCancelled)
+	})
+}
+
+// RunDueSchedules executes the scheduled transfers that are due through
+// SendCoin and reports how many ran. Each run is claimed and executed in a
+// transaction of its own, so replicas running this at the same time share
+// the work rather than repeating it. A run the sender cannot afford is
+// recorded as failed and the sender is notified; recurring schedules then
+// move on to their next date either way.
+func (u *UserService) RunDueSchedules(ctx context.Context) (int, error) {
+	ran := 0
+	for ran < maxRunsPerSweep {
+		var notification *entities.Notification
+		err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+			notification = nil
+			now := u.Now()
+			schedule, err := u.UserRepo.ClaimDueSchedule(ctx, now)
+			if err != nil {
+				return err
+			}
+
+			run := &entities.ScheduledTransferRun{
+				RunAt:  now,
+				Status: entities.ScheduleRunSucceeded,
+			}
+			err = u.sendCoin(ctx, schedule.FromUser, schedule.ToUser, schedule.Amount, schedule.Memo, schedule.Category)
+			if err == nil {
+				notification = coinsReceived(schedule.FromUser, schedule.ToUser, schedule.Amount, schedule.Memo)
+			} else {
+				failure := runFailure(err)
+				if failure == nil {
+					return err
+				}
+				run.Status = entities.ScheduleRunFailed
+				run.Error = failure.Error()
+				notification = &entities.Notification{
+					Recipient: schedule.FromUser,
+					Kind:      entities.NotificationScheduleFailed,
+					Text:      fmt.Sprintf("scheduled transfer of %d coins to %s failed: %s", schedule.Amount, schedule.ToUser, run.Error),
+				}
+			}
+			if err := u.UserRepo.AddScheduleRun(ctx, schedule.ID, run); err != nil {
+				return err
+			}
+
+			if schedule.Recurrence == entities.RecurrenceOnce {
+				return u.UserRepo.CloseSchedule(ctx, schedule.ID, entities.ScheduleCompleted)
+			}
+			schedule.NextRunAt = nextRunAt(schedule.NextRunAt, schedule.AnchorDay, schedule.Recurrence, now)
+			return u.UserRepo.UpdateSchedule(ctx, schedule)
+		})
+		if errors.Is(err, utils.ErrNoSchedule) {
+			return ran, nil
+		}
+		if err != nil {
+			return ran, err
+		}
+
+		ran++
+		if notification != nil {
+			u.notify(ctx, notification)
+		}
+	}
+
+	return ran, nil
+}
+
+// validateSchedule checks a schedule request from userName and returns the
+// schedule it describes, without user ids.
+func (u *UserService) validateSchedule(userName string, req entities.ScheduleRequest) (*entities.ScheduledTransfer, error) {
+	if req.Amount <= 0 {
+		return nil, utils.ErrBadAmount
+	}
+	if req.ToUser == userName {
+		return nil, utils.ErrSelfSend
+	}
+	memo, category, err := transferDetails(req.Memo, req.Category)
+	if err != nil {
+		return nil, err
+	}
+	if req.Recurrence == "" {
+		req.Recurrence = entities.RecurrenceOnce
+	}
+	if !slices.Contains(entities.Recurrences, req.Recurrence) {
+		return nil, utils.ErrBadRecurrence
+	}
+	if !req.RunAt.After(u.Now()) {
+		return nil, utils.ErrBadRunTime
+	}
+
+	return &entities.ScheduledTransfer{
+		Amount:     req.Amount,
+		Memo:       memo,
+		Category:   category,
+		Recurrence: req.Recurrence,
+		NextRunAt:  req.RunAt,
+		AnchorDay:  req.RunAt.UTC().Day(),
+	}, nil
+}
+
+// ownSchedule returns the schedule if userName set it up.
+func (u *UserService) ownSchedule(ctx context.Context, userName string, scheduleID int) (*entities.ScheduledTransfer, error) {
+	schedule, err := u.UserRepo.GetSchedule(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.FromUser != userName {
+		return nil, utils.ErrForbidden
+	}
+
+	return schedule, nil
+}
+
+// runFailure returns the sentinel error that failed a scheduled run, or nil
+// if err is not a run failure. Transfer limit errors are returned as they
+// are, since they name the limit that was hit.
+func runFailure(err error) error {
+	var limitErr *utils.TransferLimitError
+	if errors.As(err, &limitErr) {
+		return limitErr
+	}
+	for _, failure := range scheduleRunFailures {
+		if errors.Is(err, failure) {
+			return failure
+		}
+	}
+
+	return nil
+}
+
+// nextRunAt returns the first date of a recurring schedule after now,
+// skipping periods missed while no worker was running. Monthly runs go to
+// anchorDay, or to the last day of months that lack it, so a schedule on
+// the 31st runs on Feb 28 and then on Mar 31 again. Months are counted in
+// UTC; an anchorDay of zero uses last's day.
+func nextRunAt(last time.Time, anchorDay int, recurrence string, now time.Time) time.Time {
+	next := last
+	for !next.After(now) {
+		if recurrence == entities.RecurrenceWeekly {
+			next = next.AddDate(0, 0, 7)
+			continue
+		}
+
+		utc := next.UTC()
+		year, month, day := utc.Date()
+		if anchorDay > 0 {
+			day = anchorDay
+		}
+		lastDay := time.Date(year, month+2, 0, 0, 0, 0, 0, time.UTC).Day()
+		next = time.Date(year, month+1, min(day, lastDay), utc.Hour(), utc.Minute(), utc.Second(), utc.Nanosecond(), time.UTC).In(next.Location())
+	}
+
+	return next
+}
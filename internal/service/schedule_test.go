@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+
+	t.Run("success", func(t *testing.T) {
+		req := entities.ScheduleRequest{ToUser: "bob", Amount: 25, Memo: " weekly\nallowance ", Recurrence: entities.RecurrenceWeekly, RunAt: now.Add(time.Hour)}
+		schedule := &entities.ScheduledTransfer{ID: 4, FromUser: "alice", ToUser: "bob", Amount: 25}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().CreateSchedule(gomock.Any(), &entities.ScheduledTransfer{
+			FromUserID: 1,
+			ToUserID:   2,
+			Amount:     25,
+			Memo:       "weekly allowance",
+			Category:   entities.TransferCategoryOther,
+			Recurrence: entities.RecurrenceWeekly,
+			NextRunAt:  now.Add(time.Hour),
+			AnchorDay:  1,
+		}).Return(4, nil)
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(schedule, nil)
+
+		result, err := userService.CreateSchedule(context.Background(), "alice", req)
+		assert.NoError(t, err)
+		assert.Equal(t, schedule, result)
+	})
+
+	t.Run("unknown recipient", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "ghost").Return(0, utils.ErrNoUser)
+
+		_, err := userService.CreateSchedule(context.Background(), "alice", entities.ScheduleRequest{ToUser: "ghost", Amount: 5, RunAt: now.Add(time.Hour)})
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		valid := entities.ScheduleRequest{ToUser: "bob", Amount: 5, RunAt: now.Add(time.Hour)}
+		cases := []struct {
+			name string
+			edit func(req *entities.ScheduleRequest)
+			err  error
+		}{
+			{"amount", func(req *entities.ScheduleRequest) { req.Amount = 0 }, utils.ErrBadAmount},
+			{"self", func(req *entities.ScheduleRequest) { req.ToUser = "alice" }, utils.ErrSelfSend},
+			{"category", func(req *entities.ScheduleRequest) { req.Category = "bribe" }, utils.ErrBadCategory},
+			{"recurrence", func(req *entities.ScheduleRequest) { req.Recurrence = "daily" }, utils.ErrBadRecurrence},
+			{"past", func(req *entities.ScheduleRequest) { req.RunAt = now }, utils.ErrBadRunTime},
+			{"missing run time", func(req *entities.ScheduleRequest) { req.RunAt = time.Time{} }, utils.ErrBadRunTime},
+		}
+		for _, tc := range cases {
+			req := valid
+			tc.edit(&req)
+
+			_, err := userService.CreateSchedule(context.Background(), "alice", req)
+			assert.True(t, errors.Is(err, tc.err), "%s: got %v", tc.name, err)
+		}
+	})
+}
+
+func TestGetSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		runs := []*entities.ScheduledTransferRun{{ID: 1, Status: entities.ScheduleRunSucceeded}}
+
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(&entities.ScheduledTransfer{ID: 4, FromUser: "alice"}, nil)
+		mockRepo.EXPECT().GetScheduleRuns(gomock.Any(), 4, MaxScheduleRuns).Return(runs, nil)
+
+		schedule, err := userService.GetSchedule(context.Background(), "alice", 4)
+		assert.NoError(t, err)
+		assert.Equal(t, runs, schedule.Runs)
+	})
+
+	t.Run("someone else's schedule", func(t *testing.T) {
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(&entities.ScheduledTransfer{ID: 4, FromUser: "alice"}, nil)
+
+		_, err := userService.GetSchedule(context.Background(), "mallory", 4)
+		assert.True(t, errors.Is(err, utils.ErrForbidden))
+	})
+}
+
+func TestUpdateSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+
+	req := entities.ScheduleRequest{ToUser: "carol", Amount: 40, Recurrence: entities.RecurrenceMonthly, RunAt: now.Add(time.Hour)}
+
+	t.Run("success", func(t *testing.T) {
+		updated := &entities.ScheduledTransfer{ID: 4, FromUser: "alice", ToUser: "carol", Amount: 40}
+
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(&entities.ScheduledTransfer{ID: 4, FromUser: "alice"}, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(3, nil)
+		mockRepo.EXPECT().UpdateSchedule(gomock.Any(), &entities.ScheduledTransfer{
+			ID:         4,
+			ToUserID:   3,
+			Amount:     40,
+			Category:   entities.TransferCategoryOther,
+			Recurrence: entities.RecurrenceMonthly,
+			NextRunAt:  now.Add(time.Hour),
+			AnchorDay:  1,
+		}).Return(nil)
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(updated, nil)
+
+		schedule, err := userService.UpdateSchedule(context.Background(), "alice", 4, req)
+		assert.NoError(t, err)
+		assert.Equal(t, updated, schedule)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(&entities.ScheduledTransfer{ID: 4, FromUser: "alice"}, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(3, nil)
+		mockRepo.EXPECT().UpdateSchedule(gomock.Any(), gomock.Any()).Return(utils.ErrScheduleClosed)
+
+		_, err := userService.UpdateSchedule(context.Background(), "alice", 4, req)
+		assert.True(t, errors.Is(err, utils.ErrScheduleClosed))
+	})
+}
+
+func TestCancelSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(&entities.ScheduledTransfer{ID: 4, FromUser: "alice"}, nil)
+		mockRepo.EXPECT().CloseSchedule(gomock.Any(), 4, entities.ScheduleCancelled).Return(nil)
+
+		assert.NoError(t, userService.CancelSchedule(context.Background(), "alice", 4))
+	})
+
+	t.Run("someone else's schedule", func(t *testing.T) {
+		mockRepo.EXPECT().GetSchedule(gomock.Any(), 4).Return(&entities.ScheduledTransfer{ID: 4, FromUser: "alice"}, nil)
+
+		err := userService.CancelSchedule(context.Background(), "mallory", 4)
+		assert.True(t, errors.Is(err, utils.ErrForbidden))
+	})
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	notifier := &recordingNotifier{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Notifier = notifier
+
+	t.Run("one-off and failed recurring run", func(t *testing.T) {
+		once := &entities.ScheduledTransfer{ID: 1, FromUser: "alice", ToUser: "bob", Amount: 30, Category: entities.TransferCategoryOther, Recurrence: entities.RecurrenceOnce, NextRunAt: now}
+		weekly := &entities.ScheduledTransfer{ID: 2, FromUser: "carol", ToUser: "bob", Amount: 500, Category: entities.TransferCategoryGift, Recurrence: entities.RecurrenceWeekly, NextRunAt: now.Add(-time.Hour)}
+
+		gomock.InOrder(
+			mockRepo.EXPECT().ClaimDueSchedule(gomock.Any(), now).Return(once, nil),
+			mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 30, "", entities.TransferCategoryOther).Return(nil),
+			mockRepo.EXPECT().AddScheduleRun(gomock.Any(), 1, &entities.ScheduledTransferRun{RunAt: now, Status: entities.ScheduleRunSucceeded}).Return(nil),
+			mockRepo.EXPECT().CloseSchedule(gomock.Any(), 1, entities.ScheduleCompleted).Return(nil),
+
+			mockRepo.EXPECT().ClaimDueSchedule(gomock.Any(), now).Return(weekly, nil),
+			mockRepo.EXPECT().SendCoin(gomock.Any(), 3, 2, 500, "", entities.TransferCategoryGift).
+				Return(fmt.Errorf("postgres send coin: %w", utils.ErrNotEnoughBalance)),
+			mockRepo.EXPECT().AddScheduleRun(gomock.Any(), 2, &entities.ScheduledTransferRun{RunAt: now, Status: entities.ScheduleRunFailed, Error: utils.ErrNotEnoughBalance.Error()}).Return(nil),
+			mockRepo.EXPECT().UpdateSchedule(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, schedule *entities.ScheduledTransfer) error {
+				assert.Equal(t, now.Add(-time.Hour).AddDate(0, 0, 7), schedule.NextRunAt)
+				return nil
+			}),
+
+			mockRepo.EXPECT().ClaimDueSchedule(gomock.Any(), now).Return(nil, utils.ErrNoSchedule),
+		)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil).Times(2)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "carol").Return(3, nil)
+
+		ran, err := userService.RunDueSchedules(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, ran)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "bob",
+			Kind:      entities.NotificationCoinsReceived,
+			Text:      "alice sent you 30 coins",
+		}, {
+			Recipient: "carol",
+			Kind:      entities.NotificationScheduleFailed,
+			Text:      "scheduled transfer of 500 coins to bob failed: Not enough balance",
+		}}, notifier.notifications)
+	})
+
+	t.Run("storage error aborts the sweep", func(t *testing.T) {
+		someError := errors.New("db down")
+		schedule := &entities.ScheduledTransfer{ID: 1, FromUser: "alice", ToUser: "bob", Amount: 30, Recurrence: entities.RecurrenceOnce, NextRunAt: now}
+
+		mockRepo.EXPECT().ClaimDueSchedule(gomock.Any(), now).Return(schedule, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 30, "", entities.TransferCategoryOther).Return(someError)
+
+		ran, err := userService.RunDueSchedules(context.Background())
+		assert.True(t, errors.Is(err, someError))
+		assert.Zero(t, ran)
+	})
+}
+
+func TestNextRunAt(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		last       time.Time
+		anchorDay  int
+		recurrence string
+		want       time.Time
+	}{
+		{"weekly", time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), 10, entities.RecurrenceWeekly, time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"weekly skips missed weeks", time.Date(2025, 2, 17, 9, 0, 0, 0, time.UTC), 17, entities.RecurrenceWeekly, time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"monthly", time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC), 10, entities.RecurrenceMonthly, time.Date(2025, 4, 10, 9, 0, 0, 0, time.UTC)},
+		{"monthly returns to the anchor day", time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC), 31, entities.RecurrenceMonthly, time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC)},
+		{"monthly without an anchor day", time.Date(2025, 2, 28, 9, 0, 0, 0, time.UTC), 0, entities.RecurrenceMonthly, time.Date(2025, 3, 28, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextRunAt(tt.last, tt.anchorDay, tt.recurrence, now))
+		})
+	}
+}
+
+func TestNextRunAtMonthEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		first time.Time
+		want  []time.Time
+	}{
+		{"common year", time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC), []time.Time{
+			time.Date(2025, 2, 28, 9, 0, 0, 0, time.UTC),
+			time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC),
+			time.Date(2025, 4, 30, 9, 0, 0, 0, time.UTC),
+			time.Date(2025, 5, 31, 9, 0, 0, 0, time.UTC),
+		}},
+		{"leap year", time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC), []time.Time{
+			time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 31, 9, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each run is computed just after the previous one fired, as
+			// RunDueSchedules does.
+			run := tt.first
+			for _, want := range tt.want {
+				run = nextRunAt(run, tt.first.Day(), entities.RecurrenceMonthly, run.Add(time.Minute))
+				assert.Equal(t, want, run)
+			}
+		})
+	}
+}
@@ -1,106 +1,925 @@
 package service
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
 )
 
 //go:generate mockgen -source=user.go -destination=../repository/user_repo_mock.go -package=repository
 type UserRepo interface {
-	BuyItem(userID, itemID int) error
-	SendCoin(fromUserID, toUserID int, amount int) error
-	Auth(userName, password string) (*entities.User, error)
-	GetUserID(userName string) (int, error)
-	GetItemID(itemName string) (int, error)
-	GetCoinsInfo(userID int) (int, error)
-	GetInventoryInfo(userID int) ([]*entities.Item, error)
-	GetReceiveInfo(userID int) ([]*entities.ReceiveOperation, error)
-	GetSentInfo(userID int) ([]*entities.SentOperation, error)
+	BuyItem(ctx context.Context, userID, itemID int) error
+	SendCoin(ctx context.Context, fromUserID, toUserID int, amount int, memo, category string) error
+	Auth(ctx context.Context, userName, password string) (*entities.User, error)
+	GetUserID(ctx context.Context, userName string) (int, error)
+	GetItemID(ctx context.Context, itemName string) (int, error)
+	GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error)
+	SetItemPrice(ctx context.Context, itemName string, price int) error
+	GetCoinsInfo(ctx context.Context, userID int) (int, error)
+	GetInventoryInfo(ctx context.Context, userID int) ([]*entities.Item, error)
+	GetReceiveInfo(ctx context.Context, userID int) ([]*entities.ReceiveOperation, error)
+	GetSentInfo(ctx context.Context, userID int) ([]*entities.SentOperation, error)
+	// LockLoginAttempts returns the throttling state of userName and keeps
+	// other transactions from reading or changing it until this one ends.
+	LockLoginAttempts(ctx context.Context, userName string) (*entities.LoginAttempts, error)
+	// RegisterFailedLogin records a failed login at and returns the number of
+	// failures that count towards a lockout. Failures before since are
+	// forgotten first.
+	RegisterFailedLogin(ctx context.Context, userName string, at, since time.Time) (int, error)
+	BlockLogin(ctx context.Context, userName string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, userName string) error
+	SetUserRole(ctx context.Context, userName, role string) error
+	AddLockoutEvent(ctx context.Context, event *entities.LockoutEvent) error
+	GetRefundableOrders(ctx context.Context, userID, itemID int) ([]*entities.Order, error)
+	AddRefund(ctx context.Context, refund *entities.Refund) error
+	GetRefundInfo(ctx context.Context, userID int) ([]*entities.RefundOperation, error)
+	GetOrders(ctx context.Context, userID int, itemName string, limit, offset int) ([]*entities.Order, error)
+	CountOrders(ctx context.Context, userID int, itemName string) (int, error)
+	GiftItem(ctx context.Context, userID, recipientID, itemID int, message string) error
+	GetSentGifts(ctx context.Context, userID int) ([]*entities.SentGift, error)
+	GetReceivedGifts(ctx context.Context, userID int) ([]*entities.ReceivedGift, error)
+	TransferItem(ctx context.Context, fromUserID, toUserID, itemID, quantity int) error
+	GetSentItems(ctx context.Context, userID int) ([]*entities.SentItemOperation, error)
+	GetReceivedItems(ctx context.Context, userID int) ([]*entities.ReceivedItemOperation, error)
+	CreateListing(ctx context.Context, sellerID, itemID, quantity, price int, expiresAt time.Time) (int, error)
+	GetListing(ctx context.Context, listingID int) (*entities.Listing, error)
+	GetListings(ctx context.Context, itemName string, now time.Time, limit, offset int) ([]*entities.Listing, error)
+	CountListings(ctx context.Context, itemName string, now time.Time) (int, error)
+	SellListing(ctx context.Context, listingID, buyerID, fee int) error
+	CloseListing(ctx context.Context, listingID int, status string) error
+	ExpireListings(ctx context.Context, now time.Time) (int, error)
+	SearchTransfers(ctx context.Context, userID int, filter entities.TransferFilter, limit, offset int) ([]*entities.CoinTransfer, error)
+	CountTransfers(ctx context.Context, userID int, filter entities.TransferFilter) (int, error)
+	CreateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) (int, error)
+	GetSchedule(ctx context.Context, scheduleID int) (*entities.ScheduledTransfer, error)
+	GetSchedules(ctx context.Context, userID int) ([]*entities.ScheduledTransfer, error)
+	UpdateSchedule(ctx context.Context, schedule *entities.ScheduledTransfer) error
+	CloseSchedule(ctx context.Context, scheduleID int, status string) error
+	ClaimDueSchedule(ctx context.Context, now time.Time) (*entities.ScheduledTransfer, error)
+	AddScheduleRun(ctx context.Context, scheduleID int, run *entities.ScheduledTransferRun) error
+	GetScheduleRuns(ctx context.Context, scheduleID, limit int) ([]*entities.ScheduledTransferRun, error)
+	CreatePaymentRequest(ctx context.Context, request *entities.PaymentRequest) (int, error)
+	GetPaymentRequest(ctx context.Context, requestID int) (*entities.PaymentRequest, error)
+	GetPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter, limit, offset int) ([]*entities.PaymentRequest, error)
+	CountPaymentRequests(ctx context.Context, userID int, filter entities.PaymentRequestFilter) (int, error)
+	ClosePaymentRequest(ctx context.Context, requestID int, status string) error
+	ExpirePaymentRequests(ctx context.Context, now time.Time) ([]*entities.PaymentRequest, error)
+	GetTransferLimits(ctx context.Context, userID int) (*entities.UserTransferLimits, error)
+	SetTransferLimits(ctx context.Context, userID int, limits entities.TransferLimits, actor string) error
+	DeleteTransferLimits(ctx context.Context, userID int) error
+	GetTransferUsage(ctx context.Context, fromUserID, toUserID int, since time.Time) (*entities.TransferUsage, error)
+	AdjustBalance(ctx context.Context, userID, delta int) (int, error)
+	AddBalanceAdjustment(ctx context.Context, adjustment *entities.BalanceAdjustment) error
+	GetBalanceAdjustments(ctx context.Context, userID, limit, offset int) ([]*entities.BalanceAdjustment, error)
+	CountBalanceAdjustments(ctx context.Context, userID int) (int, error)
+	// LockAuditLog keeps other transactions from appending to the audit log
+	// until this one ends.
+	LockAuditLog(ctx context.Context) error
+	AddAuditEvent(ctx context.Context, event *entities.AuditEvent) error
+	LastAuditEvent(ctx context.Context, action string) (*entities.AuditEvent, error)
+	GetAuditEvents(ctx context.Context, filter entities.AuditFilter, afterID, limit int) ([]*entities.AuditEvent, error)
+	CountAuditEvents(ctx context.Context, filter entities.AuditFilter) (int, error)
+	AddOutboxEvent(ctx context.Context, event *entities.OutboxEvent) error
+	AcquireOutboxLease(ctx context.Context, owner string, now, until time.Time) (bool, error)
+	GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEvent, error)
+	MarkOutboxDelivered(ctx context.Context, eventID int, at time.Time) error
+	DeferOutboxEvent(ctx context.Context, eventID int, lastError string, until time.Time) error
+	AddWebhookEndpoint(ctx context.Context, endpoint *entities.WebhookEndpoint) error
+	GetWebhookEndpoint(ctx context.Context, endpointID int) (*entities.WebhookEndpoint, error)
+	GetWebhookEndpoints(ctx context.Context, userID int) ([]*entities.WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, endpointID int) error
+	AddWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, deliveryID int) (*entities.WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, endpointID int, status string, limit, offset int) ([]*entities.WebhookDelivery, error)
+	CountWebhookDeliveries(ctx context.Context, endpointID int, status string) (int, error)
+	ClaimDueWebhookDelivery(ctx context.Context, now, until time.Time) (*entities.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error
+	AddWebhookAttempt(ctx context.Context, deliveryID int, attempt *entities.WebhookAttempt) error
+	GetWebhookAttempts(ctx context.Context, deliveryID int) ([]*entities.WebhookAttempt, error)
+	AddUserEvent(ctx context.Context, event *entities.UserEvent) error
+	GetUserEvents(ctx context.Context, userID, afterID, limit int) ([]*entities.UserEvent, error)
+	LastUserEventID(ctx context.Context, userID int) (int, error)
+	DeleteUserEvents(ctx context.Context, before time.Time) (int, error)
+	AddNotification(ctx context.Context, userID int, notification *entities.Notification) error
+	GetNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*entities.Notification, error)
+	CountNotifications(ctx context.Context, userID int, unreadOnly bool) (int, error)
+	MarkNotificationRead(ctx context.Context, userID, notificationID int, at time.Time) error
+	MarkAllNotificationsRead(ctx context.Context, userID int, at time.Time) (int, error)
+	GetNotificationPreferences(ctx context.Context, userID int) (entities.NotificationPreferences, error)
+	SetNotificationPreference(ctx context.Context, userID int, kind string, enabled bool) error
+	GetNotificationsSince(ctx context.Context, userID int, since time.Time, limit int) ([]*entities.Notification, error)
+	GetEmailSettings(ctx context.Context, userID int) (*entities.EmailSettings, error)
+	SetEmailSettings(ctx context.Context, userID int, settings *entities.EmailSettings) error
+	GetDueEmailDigests(ctx context.Context, before time.Time, limit int) ([]*entities.EmailDigest, error)
+	MarkEmailDigestSent(ctx context.Context, userID int, at time.Time) error
+	AddEmail(ctx context.Context, email *entities.Email) error
+	ClaimDueEmail(ctx context.Context, now, until time.Time) (*entities.Email, error)
+	UpdateEmail(ctx context.Context, email *entities.Email) error
 }
 
+// Notifier delivers notifications to users. It is called after the change
+// it reports has been committed; a failed delivery does not undo it.
+type Notifier interface {
+	Notify(ctx context.Context, notification *entities.Notification) error
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(ctx context.Context, notification *entities.Notification) error {
+	return nil
+}
+
+// TxManager runs fn as one unit of work. Repository calls made with the ctx
+// passed to fn join the transaction; a nested WithinTx joins the outer one.
+// Implementations retry fn on serialization failures and deadlocks, so fn
+// must be safe to run more than once.
+type TxManager interface {
+	WithinTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error
+}
+
+// LockoutPolicy controls how failed logins are throttled. Every failure
+// before MaxAttempts delays the next attempt by BaseDelay doubled per
+// failure (capped at MaxDelay); reaching MaxAttempts locks the username
+// for LockoutDuration. A failure more than FailureWindow after the previous
+// one starts the count again; zero keeps failures until a login succeeds.
+type LockoutPolicy struct {
+	MaxAttempts     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	FailureWindow   time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxAttempts:     5,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	LockoutDuration: 15 * time.Minute,
+	FailureWindow:   15 * time.Minute,
+}
+
+// DefaultRefundWindow is how long after a purchase users may return it
+// themselves.
+const DefaultRefundWindow = 14 * 24 * time.Hour
+
 type UserService struct {
-	UserRepo UserRepo
+	UserRepo     UserRepo
+	Tx           TxManager
+	Lockout      LockoutPolicy
+	RefundWindow time.Duration
+	Market       MarketPolicy
+	// PaymentRequestTTL is how long a payment request waits for an answer.
+	PaymentRequestTTL time.Duration
+	// TransferLimits apply to users without an admin override.
+	TransferLimits entities.TransferLimits
+	// Roles maps usernames to the role they get when they log in to an
+	// existing account, so the first admins can be named in the
+	// configuration.
+	Roles map[string]string
+	Notifier     Notifier
+	Auditor      Auditor
+	Publisher    Publisher
+	// EventSinks receive the events RelayOutbox takes from the outbox.
+	EventSinks   []EventSink
+	Webhooks      WebhookQueue
+	WebhookSender WebhookSender
+	// LowBalanceThreshold is the balance below which a spend sends a
+	// balance.low webhook.
+	LowBalanceThreshold int
+	// Events feeds the streams of users connected to GET /api/events.
+	Events EventStream
+	// EventRetention is how long events stay available for resuming a
+	// stream.
+	EventRetention time.Duration
+	EmailTemplates EmailTemplates
+	EmailSender    EmailSender
+	// EmailDigestInterval is how often users in digest mode get a digest.
+	EmailDigestInterval time.Duration
+	Now          func() time.Time
 }
 
-func NewUserService(userRepo UserRepo) *UserService{
+func NewUserService(userRepo UserRepo, txManager TxManager) *UserService{
 	return &UserService{
 		UserRepo: userRepo,
+		Tx:       txManager,
+		Lockout:  DefaultLockoutPolicy,
+		RefundWindow: DefaultRefundWindow,
+		Market:   DefaultMarketPolicy,
+		PaymentRequestTTL: DefaultPaymentRequestTTL,
+		TransferLimits: DefaultTransferLimits,
+		Notifier: nopNotifier{},
+		Auditor:  nopAuditor{},
+		Publisher: nopPublisher{},
+		Webhooks: nopWebhookQueue{},
+		WebhookSender: nopWebhookSender{},
+		LowBalanceThreshold: DefaultLowBalanceThreshold,
+		Events: nopEventStream{},
+		EventRetention: DefaultEventRetention,
+		EmailTemplates: nopEmailTemplates{},
+		EmailSender: nopEmailSender{},
+		EmailDigestInterval: DefaultEmailDigestInterval,
+		Now:      time.Now,
 	}
 }
 
-func (u *UserService) BuyItem(userName, itemName string) error {
-	userID, err := u.UserRepo.GetUserID(userName)
+// writeTx is used for operations that move coins or items: they read
+// balances and write them back, so anything weaker than serializable lets
+// two concurrent requests spend the same coins.
+var writeTx = &sql.TxOptions{Isolation: sql.LevelSerializable}
+
+// readTx gives GetInfo a single consistent snapshot of balance and history.
+var readTx = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+func (u *UserService) BuyItem(ctx context.Context, userName, itemName string) error {
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+		itemID, err := u.UserRepo.GetItemID(ctx, itemName)
+		if err != nil {
+			return err
+		}
+		balanceChecked, err := u.watchBalance(ctx, userID, userName)
+		if err != nil {
+			return err
+		}
+		if err := u.UserRepo.BuyItem(ctx, userID, itemID); err != nil {
+			return err
+		}
+		if err := balanceChecked(); err != nil {
+			return err
+		}
+
+		purchase := entities.ItemPurchasedEvent{
+			User:     userName,
+			Item:     itemName,
+			Quantity: 1,
+		}
+		if err := u.webhook(ctx, userID, entities.WebhookItemPurchased, purchase); err != nil {
+			return err
+		}
+		if err := u.push(ctx, userID, entities.StreamPurchaseCompleted, purchase); err != nil {
+			return err
+		}
+		if err := u.pushBalance(ctx, userID); err != nil {
+			return err
+		}
+		if err := u.auditTx(ctx, entities.AuditItemBought, userName, userName, "item="+itemName); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, entities.EventItemPurchased, userName, purchase)
+	})
 	if err != nil {
 		return err
 	}
-	itemID, err := u.UserRepo.GetItemID(itemName)
+
+	u.notify(ctx, &entities.Notification{
+		Recipient: userName,
+		Kind:      entities.NotificationPurchaseCompleted,
+		Text:      fmt.Sprintf("you bought a %s", itemName),
+	})
+
+	return nil
+}
+
+// MaxGiftMessageLength is the longest gift message accepted, in characters.
+const MaxGiftMessageLength = 200
+
+// GiftItem buys an item for fromUser and puts it into toUser's inventory.
+func (u *UserService) GiftItem(ctx context.Context, fromUser, toUser, itemName, message string) error {
+	if fromUser == toUser {
+		return utils.ErrSelfGift
+	}
+	message = strings.TrimSpace(message)
+	if utf8.RuneCountInString(message) > MaxGiftMessageLength {
+		return utils.ErrMessageTooLong
+	}
+
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, fromUser)
+		if err != nil {
+			return err
+		}
+		recipientID, err := u.UserRepo.GetUserID(ctx, toUser)
+		if err != nil {
+			return err
+		}
+		itemID, err := u.UserRepo.GetItemID(ctx, itemName)
+		if err != nil {
+			return err
+		}
+		balanceChecked, err := u.watchBalance(ctx, userID, fromUser)
+		if err != nil {
+			return err
+		}
+		if err := u.UserRepo.GiftItem(ctx, userID, recipientID, itemID, message); err != nil {
+			return err
+		}
+		if err := balanceChecked(); err != nil {
+			return err
+		}
+
+		purchase := entities.ItemPurchasedEvent{
+			User:      fromUser,
+			Item:      itemName,
+			Quantity:  1,
+			Recipient: toUser,
+		}
+		if err := u.webhook(ctx, userID, entities.WebhookItemPurchased, purchase); err != nil {
+			return err
+		}
+		if err := u.push(ctx, userID, entities.StreamPurchaseCompleted, purchase); err != nil {
+			return err
+		}
+		if err := u.pushBalance(ctx, userID); err != nil {
+			return err
+		}
+		if err := u.auditTx(ctx, entities.AuditItemGifted, fromUser, toUser, "item="+itemName); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, entities.EventItemPurchased, fromUser, purchase)
+	})
 	if err != nil {
 		return err
 	}
-	if err := u.UserRepo.BuyItem(userID, itemID); err != nil {
+
+	text := fmt.Sprintf("%s sent you a %s", fromUser, itemName)
+	if message != "" {
+		text += ": " + message
+	}
+	u.notify(ctx, &entities.Notification{
+		Recipient: toUser,
+		Kind:      entities.NotificationGiftReceived,
+		Text:      text,
+	})
+
+	return nil
+}
+
+func (u *UserService) notify(ctx context.Context, notification *entities.Notification) {
+	if err := u.Notifier.Notify(ctx, notification); err != nil {
+		log.Printf("notify %s about %s: %v", notification.Recipient, notification.Kind, err)
+	}
+}
+
+// MaxMemoLength is the longest memo accepted on a coin transfer, in
+// characters.
+const MaxMemoLength = 200
+
+// SendCoin moves amount coins to toUser. The memo is cleaned up before it
+// is stored and an empty category means "other".
+func (u *UserService) SendCoin(ctx context.Context, fromUser, toUser string, amount int, memo, category string) error {
+	if err := u.sendCoin(ctx, fromUser, toUser, amount, memo, category); err != nil {
 		return err
 	}
+
+	u.notifyCoinsReceived(ctx, fromUser, toUser, amount, memo)
+
 	return nil
 }
 
-func (u *UserService) SendCoin(fromUser, toUser string, amount int) error {
-	fromUserID, err := u.UserRepo.GetUserID(fromUser)
+// sendCoin is SendCoin without the coins_received notification, for
+// callers that tell the recipient themselves.
+func (u *UserService) sendCoin(ctx context.Context, fromUser, toUser string, amount int, memo, category string) error {
+	if amount <= 0 {
+		return utils.ErrBadAmount
+	}
+	memo, category, err := transferDetails(memo, category)
 	if err != nil {
 		return err
 	}
-	toUserID, err := u.UserRepo.GetUserID(toUser)
-	if err := u.UserRepo.SendCoin(fromUserID, toUserID, amount); err != nil {
+
+	return u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		fromUserID, err := u.UserRepo.GetUserID(ctx, fromUser)
+		if err != nil {
+			return err
+		}
+		toUserID, err := u.UserRepo.GetUserID(ctx, toUser)
+		if err != nil {
+			return err
+		}
+
+		limits, err := u.transferLimits(ctx, fromUserID)
+		if err != nil {
+			return err
+		}
+		if err := u.checkTransferLimits(ctx, limits, fromUserID, toUserID, amount); err != nil {
+			return err
+		}
+
+		balanceChecked, err := u.watchBalance(ctx, fromUserID, fromUser)
+		if err != nil {
+			return err
+		}
+		if err := u.UserRepo.SendCoin(ctx, fromUserID, toUserID, amount, memo, category); err != nil {
+			return err
+		}
+		if err := balanceChecked(); err != nil {
+			return err
+		}
+
+		transfer := entities.CoinsSentEvent{
+			FromUser: fromUser,
+			ToUser:   toUser,
+			Amount:   amount,
+			Memo:     memo,
+			Category: category,
+		}
+		if err := u.webhook(ctx, toUserID, entities.WebhookCoinReceived, transfer); err != nil {
+			return err
+		}
+		if err := u.push(ctx, toUserID, entities.StreamCoinReceived, transfer); err != nil {
+			return err
+		}
+		if err := u.pushBalance(ctx, fromUserID, toUserID); err != nil {
+			return err
+		}
+		if err := u.auditTx(ctx, entities.AuditCoinsSent, fromUser, toUser, fmt.Sprintf("amount=%d category=%s", amount, category)); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, entities.EventCoinsSent, fromUser, transfer)
+	})
+}
+
+func (u *UserService) notifyCoinsReceived(ctx context.Context, fromUser, toUser string, amount int, memo string) {
+	u.notify(ctx, coinsReceived(fromUser, toUser, amount, memo))
+}
+
+func coinsReceived(fromUser, toUser string, amount int, memo string) *entities.Notification {
+	text := fmt.Sprintf("%s sent you %d coins", fromUser, amount)
+	if memo != "" {
+		text += ": " + memo
+	}
+
+	return &entities.Notification{
+		Recipient: toUser,
+		Kind:      entities.NotificationCoinsReceived,
+		Text:      text,
+	}
+}
+
+// transferDetails validates the memo and category of a coin transfer and
+// returns them in the form they are stored.
+func transferDetails(memo, category string) (string, string, error) {
+	memo = sanitizeMemo(memo)
+	if utf8.RuneCountInString(memo) > MaxMemoLength {
+		return "", "", utils.ErrMemoTooLong
+	}
+	if category == "" {
+		category = entities.TransferCategoryOther
+	}
+	if !slices.Contains(entities.TransferCategories, category) {
+		return "", "", utils.ErrBadCategory
+	}
+
+	return memo, category, nil
+}
+
+// sanitizeMemo drops invalid UTF-8, turns control characters such as
+// newlines into spaces and collapses runs of whitespace.
+func sanitizeMemo(memo string) string {
+	memo = strings.ToValidUTF8(memo, "")
+	memo = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, memo)
+
+	return strings.Join(strings.Fields(memo), " ")
+}
+
+// TransferItem moves quantity units of an item the sender holds into the
+// recipient's inventory and tells the recipient.
+func (u *UserService) TransferItem(ctx context.Context, fromUser, toUser, itemName string, quantity int) error {
+	if quantity <= 0 {
+		return utils.ErrBadQuantity
+	}
+	if fromUser == toUser {
+		return utils.ErrSelfTransfer
+	}
+
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		fromUserID, err := u.UserRepo.GetUserID(ctx, fromUser)
+		if err != nil {
+			return err
+		}
+		toUserID, err := u.UserRepo.GetUserID(ctx, toUser)
+		if err != nil {
+			return err
+		}
+		itemID, err := u.UserRepo.GetItemID(ctx, itemName)
+		if err != nil {
+			return err
+		}
+		if err := u.UserRepo.TransferItem(ctx, fromUserID, toUserID, itemID, quantity); err != nil {
+			return err
+		}
+
+		transfer := entities.ItemTransferredEvent{
+			FromUser: fromUser,
+			ToUser:   toUser,
+			Item:     itemName,
+			Quantity: quantity,
+		}
+		if err := u.webhook(ctx, toUserID, entities.WebhookItemReceived, transfer); err != nil {
+			return err
+		}
+		if err := u.push(ctx, toUserID, entities.StreamItemReceived, transfer); err != nil {
+			return err
+		}
+		if err := u.auditTx(ctx, entities.AuditItemTransferred, fromUser, toUser, fmt.Sprintf("item=%s quantity=%d", itemName, quantity)); err != nil {
+			return err
+		}
+
+		return u.publish(ctx, entities.EventItemTransferred, fromUser, transfer)
+	})
+	if err != nil {
 		return err
 	}
 
+	u.notify(ctx, &entities.Notification{
+		Recipient: toUser,
+		Kind:      entities.NotificationItemReceived,
+		Text:      fmt.Sprintf("%s sent you %d %s", fromUser, quantity, itemName),
+	})
+
 	return nil
 }
 
-func (u *UserService) GetInfo(userName string) (*entities.InfoResponse, error) {
-	userID, err := u.UserRepo.GetUserID(userName)
-	if err != nil {
-		return nil, err
+// RefundItem returns quantity units of an item the user bought within the
+// refund window and credits back what was paid for them.
+func (u *UserService) RefundItem(ctx context.Context, userName, itemName string, quantity int) (*entities.RefundOperation, error) {
+	return u.refund(ctx, userName, itemName, quantity, "")
+}
+
+// ForceRefund is RefundItem for admins: it ignores the refund window and
+// records adminName as the one who refunded.
+func (u *UserService) ForceRefund(ctx context.Context, adminName, userName, itemName string, quantity int) (*entities.RefundOperation, error) {
+	return u.refund(ctx, userName, itemName, quantity, adminName)
+}
+
+// refund takes units from the newest orders first, each credited at the
+// price paid for that order. Without an actor only orders inside the refund
+// window are eligible.
+func (u *UserService) refund(ctx context.Context, userName, itemName string, quantity int, actor string) (*entities.RefundOperation, error) {
+	if quantity <= 0 {
+		return nil, utils.ErrBadQuantity
 	}
 
-	coins, err := u.UserRepo.GetCoinsInfo(userID)
-	if err != nil {
-		return nil, err
+	result := &entities.RefundOperation{
+		Item:       itemName,
+		Quantity:   quantity,
+		RefundedBy: actor,
 	}
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		result.Amount = 0
+
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+		itemID, err := u.UserRepo.GetItemID(ctx, itemName)
+		if err != nil {
+			return err
+		}
+
+		orders, err := u.UserRepo.GetRefundableOrders(ctx, userID, itemID)
+		if err != nil {
+			return err
+		}
+
+		oldest := u.Now().Add(-u.RefundWindow)
+		var refunds []*entities.Refund
+		left := quantity
+		for _, order := range orders {
+			if left == 0 {
+				break
+			}
+			if actor == "" && order.CreatedAt.Before(oldest) {
+				continue
+			}
+
+			units := min(left, order.Quantity-order.Refunded)
+			refunds = append(refunds, &entities.Refund{
+				OrderID:  order.ID,
+				UserID:   userID,
+				ItemID:   itemID,
+				Quantity: units,
+				Amount:   units * order.UnitPrice,
+				Actor:    actor,
+			})
+			left -= units
+		}
+		if left > 0 {
+			return utils.ErrNothingToRefund
+		}
+
+		for _, refund := range refunds {
+			if err := u.UserRepo.AddRefund(ctx, refund); err != nil {
+				return err
+			}
+			result.Amount += refund.Amount
+		}
 
-	inventory, err := u.UserRepo.GetInventoryInfo(userID)
+		auditActor := actor
+		if auditActor == "" {
+			auditActor = userName
+		}
+		details := fmt.Sprintf("item=%s quantity=%d amount=%d", itemName, quantity, result.Amount)
+		if err := u.auditTx(ctx, entities.AuditItemRefunded, auditActor, userName, details); err != nil {
+			return err
+		}
+
+		return u.pushBalance(ctx, userID)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	receives, err := u.UserRepo.GetReceiveInfo(userID)
-	if err != nil {
-		return nil, err
+	u.notify(ctx, &entities.Notification{
+		Recipient: userName,
+		Kind:      entities.NotificationItemRefunded,
+		Text:      fmt.Sprintf("your order of %d %s was refunded: %d coins returned", quantity, itemName, result.Amount),
+	})
+
+	return result, nil
+}
+
+const (
+	DefaultOrdersLimit = 20
+	MaxOrdersLimit     = 100
+)
+
+// GetOrders returns a page of the user's orders, newest first, optionally
+// only those for itemName. A zero limit means DefaultOrdersLimit; larger
+// limits are capped at MaxOrdersLimit.
+func (u *UserService) GetOrders(ctx context.Context, userName, itemName string, limit, offset int) (*entities.OrdersPage, error) {
+	if limit < 0 || offset < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if limit == 0 {
+		limit = DefaultOrdersLimit
 	}
+	limit = min(limit, MaxOrdersLimit)
 
-	sents, err := u.UserRepo.GetSentInfo(userID)
+	page := &entities.OrdersPage{
+		Limit:  limit,
+		Offset: offset,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		if page.Total, err = u.UserRepo.CountOrders(ctx, userID, itemName); err != nil {
+			return err
+		}
+
+		page.Orders, err = u.UserRepo.GetOrders(ctx, userID, itemName, limit, offset)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	info := &entities.InfoResponse{
-		Coins: coins,
-		Inventory: inventory,
-		CoinHistory: entities.CoinHistory{
-			Received: receives,
-			Sent: sents,
-		},
+	return page, nil
+}
+
+func (u *UserService) GetInfo(ctx context.Context, userName string) (*entities.InfoResponse, error) {
+	var info *entities.InfoResponse
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		coins, err := u.UserRepo.GetCoinsInfo(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		inventory, err := u.UserRepo.GetInventoryInfo(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		receives, err := u.UserRepo.GetReceiveInfo(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		sents, err := u.UserRepo.GetSentInfo(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		refunds, err := u.UserRepo.GetRefundInfo(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		sentGifts, err := u.UserRepo.GetSentGifts(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		receivedGifts, err := u.UserRepo.GetReceivedGifts(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		sentItems, err := u.UserRepo.GetSentItems(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		receivedItems, err := u.UserRepo.GetReceivedItems(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		info = &entities.InfoResponse{
+			Coins: coins,
+			Inventory: inventory,
+			CoinHistory: entities.CoinHistory{
+				Received: receives,
+				Sent: sents,
+				Refunds: refunds,
+			},
+			GiftHistory: entities.GiftHistory{
+				Received: receivedGifts,
+				Sent:     sentGifts,
+			},
+			ItemHistory: entities.ItemHistory{
+				Received: receivedItems,
+				Sent:     sentItems,
+			},
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return info, nil
 }
 
-func (u *UserService) Auth(userName, password string) (*entities.User, error) {
-	user, err := u.UserRepo.Auth(userName, password)
+func (u *UserService) Auth(ctx context.Context, userName, password string) (*entities.User, error) {
+	now := u.Now()
+
+	// The throttling state is locked before the password is checked, so
+	// concurrent guesses are checked one at a time and each sees the block
+	// the previous one set. It is keyed by the raw username and read before
+	// the users table is touched, so the response for a blocked login is the
+	// same whether or not the account exists. A successful login is stored
+	// together with its audit events and, for a new account, its
+	// UserRegistered event.
+	var user *entities.User
+	var wrongPass bool
+	var blocked *utils.LoginBlockedError
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		user, wrongPass, blocked = nil, false, nil
+
+		attempts, err := u.UserRepo.LockLoginAttempts(ctx, userName)
+		if err != nil {
+			return err
+		}
+		if now.Before(attempts.BlockedUntil) {
+			blocked = &utils.LoginBlockedError{RetryAfter: attempts.BlockedUntil.Sub(now)}
+			return nil
+		}
+
+		user, err = u.UserRepo.Auth(ctx, userName, password)
+		if errors.Is(err, utils.ErrWrongPass) {
+			wrongPass = true
+			return u.registerFailedLogin(ctx, userName, now)
+		}
+		if err != nil {
+			return err
+		}
+
+		if attempts.FailedCount > 0 {
+			if err := u.UserRepo.ResetLoginAttempts(ctx, userName); err != nil {
+				return err
+			}
+		}
+		roleChanged, err := u.applyConfiguredRole(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		if user.Registered {
+			if err := u.auditTx(ctx, entities.AuditRegistered, userName, userName, ""); err != nil {
+				return err
+			}
+			err := u.publish(ctx, entities.EventUserRegistered, userName, entities.UserRegisteredEvent{
+				Username: userName,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if roleChanged {
+			if err := u.auditTx(ctx, entities.AuditRoleChanged, SystemActor, userName, "role="+user.Role); err != nil {
+				return err
+			}
+		}
+
+		return u.auditTx(ctx, entities.AuditLoginSucceeded, userName, userName, "")
+	})
 	if err != nil {
-		fmt.Println("auth service error", err)
 		return nil, err
 	}
+	// A failed login is audited after the failure is counted, so an audit
+	// log that is down cannot undo the count and let guessing go on.
+	if blocked != nil {
+		u.audit(ctx, entities.AuditLoginFailed, userName, userName, "blocked")
+		return nil, blocked
+	}
+	if wrongPass {
+		u.audit(ctx, entities.AuditLoginFailed, userName, userName, "wrong password")
+		return nil, utils.ErrWrongPass
+	}
 
 	return user, nil
 }
+
+// registerFailedLogin bumps the failure counter and sets the matching block
+// in one transaction, so concurrent failures cannot leave a counter at the
+// threshold without a lockout.
+func (u *UserService) registerFailedLogin(ctx context.Context, userName string, now time.Time) error {
+	return u.Tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+		var since time.Time
+		if u.Lockout.FailureWindow > 0 {
+			since = now.Add(-u.Lockout.FailureWindow)
+		}
+		failed, err := u.UserRepo.RegisterFailedLogin(ctx, userName, now, since)
+		if err != nil {
+			return err
+		}
+
+		if failed < u.Lockout.MaxAttempts {
+			return u.UserRepo.BlockLogin(ctx, userName, now.Add(u.Lockout.backoff(failed)))
+		}
+
+		lockedUntil := now.Add(u.Lockout.LockoutDuration)
+		if err := u.UserRepo.BlockLogin(ctx, userName, lockedUntil); err != nil {
+			return err
+		}
+
+		return u.UserRepo.AddLockoutEvent(ctx, &entities.LockoutEvent{
+			Username:    userName,
+			Action:      entities.LockoutActionLocked,
+			FailedCount: failed,
+			LockedUntil: lockedUntil,
+		})
+	})
+}
+
+func (p LockoutPolicy) backoff(failed int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < failed && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+func (u *UserService) UnlockUser(ctx context.Context, adminName, userName string) error {
+	return u.Tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+		if err := u.UserRepo.ResetLoginAttempts(ctx, userName); err != nil {
+			return err
+		}
+
+		err := u.UserRepo.AddLockoutEvent(ctx, &entities.LockoutEvent{
+			Username: userName,
+			Action:   entities.LockoutActionUnlocked,
+			Actor:    adminName,
+		})
+		if err != nil {
+			return err
+		}
+
+		return u.auditTx(ctx, entities.AuditUserUnlocked, adminName, userName, "")
+	})
+}
@@ -5,6 +5,7 @@
 package service
 
 import (
+	context "context"
 	reflect "reflect"
 
 	entities "github.com/KonstantinGalanin/itemStore/internal/entities"
@@ -34,6 +35,21 @@ func (m *MockJwtService) EXPECT() *MockJwtServiceMockRecorder {
 	return m.recorder
 }
 
+// CreateStreamToken mocks base method.
+func (m *MockJwtService) CreateStreamToken(userItem *entities.User) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateStreamToken", userItem)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateStreamToken indicates an expected call of CreateStreamToken.
+func (mr *MockJwtServiceMockRecorder) CreateStreamToken(userItem interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStreamToken", reflect.TypeOf((*MockJwtService)(nil).CreateStreamToken), userItem)
+}
+
 // CreateToken mocks base method.
 func (m *MockJwtService) CreateToken(userItem *entities.User) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -72,60 +88,624 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 	return m.recorder
 }
 
+// AcceptPaymentRequest mocks base method.
+func (m *MockUserService) AcceptPaymentRequest(ctx context.Context, userName string, requestID int) (*entities.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptPaymentRequest", ctx, userName, requestID)
+	ret0, _ := ret[0].(*entities.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptPaymentRequest indicates an expected call of AcceptPaymentRequest.
+func (mr *MockUserServiceMockRecorder) AcceptPaymentRequest(ctx, userName, requestID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptPaymentRequest", reflect.TypeOf((*MockUserService)(nil).AcceptPaymentRequest), ctx, userName, requestID)
+}
+
 // Auth mocks base method.
-func (m *MockUserService) Auth(userName, password string) (*entities.User, error) {
+func (m *MockUserService) Auth(ctx context.Context, userName, password string) (*entities.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Auth", userName, password)
+	ret := m.ctrl.Call(m, "Auth", ctx, userName, password)
 	ret0, _ := ret[0].(*entities.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Auth indicates an expected call of Auth.
-func (mr *MockUserServiceMockRecorder) Auth(userName, password interface{}) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) Auth(ctx, userName, password interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth", reflect.TypeOf((*MockUserService)(nil).Auth), userName, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth", reflect.TypeOf((*MockUserService)(nil).Auth), ctx, userName, password)
+}
+
+// BatchSendCoin mocks base method.
+func (m *MockUserService) BatchSendCoin(ctx context.Context, fromUser string, transfers []entities.BatchTransfer, memo, category string) (*entities.BatchSendResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchSendCoin", ctx, fromUser, transfers, memo, category)
+	ret0, _ := ret[0].(*entities.BatchSendResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchSendCoin indicates an expected call of BatchSendCoin.
+func (mr *MockUserServiceMockRecorder) BatchSendCoin(ctx, fromUser, transfers, memo, category interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchSendCoin", reflect.TypeOf((*MockUserService)(nil).BatchSendCoin), ctx, fromUser, transfers, memo, category)
 }
 
 // BuyItem mocks base method.
-func (m *MockUserService) BuyItem(userName, itemName string) error {
+func (m *MockUserService) BuyItem(ctx context.Context, userName, itemName string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BuyItem", userName, itemName)
+	ret := m.ctrl.Call(m, "BuyItem", ctx, userName, itemName)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // BuyItem indicates an expected call of BuyItem.
-func (mr *MockUserServiceMockRecorder) BuyItem(userName, itemName interface{}) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) BuyItem(ctx, userName, itemName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockUserService)(nil).BuyItem), ctx, userName, itemName)
+}
+
+// BuyListing mocks base method.
+func (m *MockUserService) BuyListing(ctx context.Context, userName string, listingID int) (*entities.Listing, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BuyListing", ctx, userName, listingID)
+	ret0, _ := ret[0].(*entities.Listing)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BuyListing indicates an expected call of BuyListing.
+func (mr *MockUserServiceMockRecorder) BuyListing(ctx, userName, listingID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyListing", reflect.TypeOf((*MockUserService)(nil).BuyListing), ctx, userName, listingID)
+}
+
+// CancelListing mocks base method.
+func (m *MockUserService) CancelListing(ctx context.Context, userName string, listingID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelListing", ctx, userName, listingID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelListing indicates an expected call of CancelListing.
+func (mr *MockUserServiceMockRecorder) CancelListing(ctx, userName, listingID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelListing", reflect.TypeOf((*MockUserService)(nil).CancelListing), ctx, userName, listingID)
+}
+
+// CancelPaymentRequest mocks base method.
+func (m *MockUserService) CancelPaymentRequest(ctx context.Context, userName string, requestID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelPaymentRequest", ctx, userName, requestID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelPaymentRequest indicates an expected call of CancelPaymentRequest.
+func (mr *MockUserServiceMockRecorder) CancelPaymentRequest(ctx, userName, requestID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelPaymentRequest", reflect.TypeOf((*MockUserService)(nil).CancelPaymentRequest), ctx, userName, requestID)
+}
+
+// CancelSchedule mocks base method.
+func (m *MockUserService) CancelSchedule(ctx context.Context, userName string, scheduleID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelSchedule", ctx, userName, scheduleID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelSchedule indicates an expected call of CancelSchedule.
+func (mr *MockUserServiceMockRecorder) CancelSchedule(ctx, userName, scheduleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSchedule", reflect.TypeOf((*MockUserService)(nil).CancelSchedule), ctx, userName, scheduleID)
+}
+
+// CreateListing mocks base method.
+func (m *MockUserService) CreateListing(ctx context.Context, userName, itemName string, quantity, price int) (*entities.Listing, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateListing", ctx, userName, itemName, quantity, price)
+	ret0, _ := ret[0].(*entities.Listing)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateListing indicates an expected call of CreateListing.
+func (mr *MockUserServiceMockRecorder) CreateListing(ctx, userName, itemName, quantity, price interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateListing", reflect.TypeOf((*MockUserService)(nil).CreateListing), ctx, userName, itemName, quantity, price)
+}
+
+// CreateSchedule mocks base method.
+func (m *MockUserService) CreateSchedule(ctx context.Context, userName string, req entities.ScheduleRequest) (*entities.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSchedule", ctx, userName, req)
+	ret0, _ := ret[0].(*entities.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSchedule indicates an expected call of CreateSchedule.
+func (mr *MockUserServiceMockRecorder) CreateSchedule(ctx, userName, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSchedule", reflect.TypeOf((*MockUserService)(nil).CreateSchedule), ctx, userName, req)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockUserService) CreateWebhook(ctx context.Context, userName string, req entities.WebhookEndpointRequest) (*entities.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, userName, req)
+	ret0, _ := ret[0].(*entities.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockUserServiceMockRecorder) CreateWebhook(ctx, userName, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockUserService)(nil).CreateWebhook), ctx, userName, req)
+}
+
+// DeclinePaymentRequest mocks base method.
+func (m *MockUserService) DeclinePaymentRequest(ctx context.Context, userName string, requestID int) (*entities.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeclinePaymentRequest", ctx, userName, requestID)
+	ret0, _ := ret[0].(*entities.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeclinePaymentRequest indicates an expected call of DeclinePaymentRequest.
+func (mr *MockUserServiceMockRecorder) DeclinePaymentRequest(ctx, userName, requestID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeclinePaymentRequest", reflect.TypeOf((*MockUserService)(nil).DeclinePaymentRequest), ctx, userName, requestID)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockUserService) DeleteWebhook(ctx context.Context, userName string, endpointID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, userName, endpointID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockUserServiceMockRecorder) DeleteWebhook(ctx, userName, endpointID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockUserService)(nil).BuyItem), userName, itemName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockUserService)(nil).DeleteWebhook), ctx, userName, endpointID)
+}
+
+// GetCatalog mocks base method.
+func (m *MockUserService) GetCatalog(ctx context.Context) ([]*entities.CatalogItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCatalog", ctx)
+	ret0, _ := ret[0].([]*entities.CatalogItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCatalog indicates an expected call of GetCatalog.
+func (mr *MockUserServiceMockRecorder) GetCatalog(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCatalog", reflect.TypeOf((*MockUserService)(nil).GetCatalog), ctx)
+}
+
+// GetEmailSettings mocks base method.
+func (m *MockUserService) GetEmailSettings(ctx context.Context, userName string) (*entities.EmailSettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmailSettings", ctx, userName)
+	ret0, _ := ret[0].(*entities.EmailSettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmailSettings indicates an expected call of GetEmailSettings.
+func (mr *MockUserServiceMockRecorder) GetEmailSettings(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmailSettings", reflect.TypeOf((*MockUserService)(nil).GetEmailSettings), ctx, userName)
 }
 
 // GetInfo mocks base method.
-func (m *MockUserService) GetInfo(userName string) (*entities.InfoResponse, error) {
+func (m *MockUserService) GetInfo(ctx context.Context, userName string) (*entities.InfoResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetInfo", userName)
+	ret := m.ctrl.Call(m, "GetInfo", ctx, userName)
 	ret0, _ := ret[0].(*entities.InfoResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetInfo indicates an expected call of GetInfo.
-func (mr *MockUserServiceMockRecorder) GetInfo(userName interface{}) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) GetInfo(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInfo", reflect.TypeOf((*MockUserService)(nil).GetInfo), ctx, userName)
+}
+
+// GetListings mocks base method.
+func (m *MockUserService) GetListings(ctx context.Context, itemName string, limit, offset int) (*entities.ListingsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetListings", ctx, itemName, limit, offset)
+	ret0, _ := ret[0].(*entities.ListingsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetListings indicates an expected call of GetListings.
+func (mr *MockUserServiceMockRecorder) GetListings(ctx, itemName, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetListings", reflect.TypeOf((*MockUserService)(nil).GetListings), ctx, itemName, limit, offset)
+}
+
+// GetNotificationPreferences mocks base method.
+func (m *MockUserService) GetNotificationPreferences(ctx context.Context, userName string) (entities.NotificationPreferences, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotificationPreferences", ctx, userName)
+	ret0, _ := ret[0].(entities.NotificationPreferences)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotificationPreferences indicates an expected call of GetNotificationPreferences.
+func (mr *MockUserServiceMockRecorder) GetNotificationPreferences(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotificationPreferences", reflect.TypeOf((*MockUserService)(nil).GetNotificationPreferences), ctx, userName)
+}
+
+// GetNotifications mocks base method.
+func (m *MockUserService) GetNotifications(ctx context.Context, userName string, unreadOnly bool, limit, offset int) (*entities.NotificationsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotifications", ctx, userName, unreadOnly, limit, offset)
+	ret0, _ := ret[0].(*entities.NotificationsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotifications indicates an expected call of GetNotifications.
+func (mr *MockUserServiceMockRecorder) GetNotifications(ctx, userName, unreadOnly, limit, offset interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInfo", reflect.TypeOf((*MockUserService)(nil).GetInfo), userName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotifications", reflect.TypeOf((*MockUserService)(nil).GetNotifications), ctx, userName, unreadOnly, limit, offset)
+}
+
+// GetOrders mocks base method.
+func (m *MockUserService) GetOrders(ctx context.Context, userName, itemName string, limit, offset int) (*entities.OrdersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, userName, itemName, limit, offset)
+	ret0, _ := ret[0].(*entities.OrdersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockUserServiceMockRecorder) GetOrders(ctx, userName, itemName, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockUserService)(nil).GetOrders), ctx, userName, itemName, limit, offset)
+}
+
+// GetPaymentRequests mocks base method.
+func (m *MockUserService) GetPaymentRequests(ctx context.Context, userName string, filter entities.PaymentRequestFilter, limit, offset int) (*entities.PaymentRequestsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentRequests", ctx, userName, filter, limit, offset)
+	ret0, _ := ret[0].(*entities.PaymentRequestsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentRequests indicates an expected call of GetPaymentRequests.
+func (mr *MockUserServiceMockRecorder) GetPaymentRequests(ctx, userName, filter, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentRequests", reflect.TypeOf((*MockUserService)(nil).GetPaymentRequests), ctx, userName, filter, limit, offset)
+}
+
+// GetSchedule mocks base method.
+func (m *MockUserService) GetSchedule(ctx context.Context, userName string, scheduleID int) (*entities.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchedule", ctx, userName, scheduleID)
+	ret0, _ := ret[0].(*entities.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchedule indicates an expected call of GetSchedule.
+func (mr *MockUserServiceMockRecorder) GetSchedule(ctx, userName, scheduleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchedule", reflect.TypeOf((*MockUserService)(nil).GetSchedule), ctx, userName, scheduleID)
+}
+
+// GetSchedules mocks base method.
+func (m *MockUserService) GetSchedules(ctx context.Context, userName string) ([]*entities.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSchedules", ctx, userName)
+	ret0, _ := ret[0].([]*entities.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchedules indicates an expected call of GetSchedules.
+func (mr *MockUserServiceMockRecorder) GetSchedules(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchedules", reflect.TypeOf((*MockUserService)(nil).GetSchedules), ctx, userName)
+}
+
+// GetUserEvents mocks base method.
+func (m *MockUserService) GetUserEvents(ctx context.Context, userName string, afterID int) ([]*entities.UserEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserEvents", ctx, userName, afterID)
+	ret0, _ := ret[0].([]*entities.UserEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserEvents indicates an expected call of GetUserEvents.
+func (mr *MockUserServiceMockRecorder) GetUserEvents(ctx, userName, afterID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserEvents", reflect.TypeOf((*MockUserService)(nil).GetUserEvents), ctx, userName, afterID)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockUserService) GetWebhookDeliveries(ctx context.Context, userName string, endpointID int, status string, limit, offset int) (*entities.WebhookDeliveriesPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", ctx, userName, endpointID, status, limit, offset)
+	ret0, _ := ret[0].(*entities.WebhookDeliveriesPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockUserServiceMockRecorder) GetWebhookDeliveries(ctx, userName, endpointID, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockUserService)(nil).GetWebhookDeliveries), ctx, userName, endpointID, status, limit, offset)
+}
+
+// GetWebhookDelivery mocks base method.
+func (m *MockUserService) GetWebhookDelivery(ctx context.Context, userName string, endpointID, deliveryID int) (*entities.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDelivery", ctx, userName, endpointID, deliveryID)
+	ret0, _ := ret[0].(*entities.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDelivery indicates an expected call of GetWebhookDelivery.
+func (mr *MockUserServiceMockRecorder) GetWebhookDelivery(ctx, userName, endpointID, deliveryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDelivery", reflect.TypeOf((*MockUserService)(nil).GetWebhookDelivery), ctx, userName, endpointID, deliveryID)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockUserService) GetWebhooks(ctx context.Context, userName string) ([]*entities.WebhookEndpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", ctx, userName)
+	ret0, _ := ret[0].([]*entities.WebhookEndpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockUserServiceMockRecorder) GetWebhooks(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockUserService)(nil).GetWebhooks), ctx, userName)
+}
+
+// GiftItem mocks base method.
+func (m *MockUserService) GiftItem(ctx context.Context, fromUser, toUser, itemName, message string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GiftItem", ctx, fromUser, toUser, itemName, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GiftItem indicates an expected call of GiftItem.
+func (mr *MockUserServiceMockRecorder) GiftItem(ctx, fromUser, toUser, itemName, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GiftItem", reflect.TypeOf((*MockUserService)(nil).GiftItem), ctx, fromUser, toUser, itemName, message)
+}
+
+// LastUserEventID mocks base method.
+func (m *MockUserService) LastUserEventID(ctx context.Context, userName string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastUserEventID", ctx, userName)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LastUserEventID indicates an expected call of LastUserEventID.
+func (mr *MockUserServiceMockRecorder) LastUserEventID(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastUserEventID", reflect.TypeOf((*MockUserService)(nil).LastUserEventID), ctx, userName)
+}
+
+// MarkAllNotificationsRead mocks base method.
+func (m *MockUserService) MarkAllNotificationsRead(ctx context.Context, userName string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkAllNotificationsRead", ctx, userName)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkAllNotificationsRead indicates an expected call of MarkAllNotificationsRead.
+func (mr *MockUserServiceMockRecorder) MarkAllNotificationsRead(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkAllNotificationsRead", reflect.TypeOf((*MockUserService)(nil).MarkAllNotificationsRead), ctx, userName)
+}
+
+// MarkNotificationRead mocks base method.
+func (m *MockUserService) MarkNotificationRead(ctx context.Context, userName string, notificationID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkNotificationRead", ctx, userName, notificationID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkNotificationRead indicates an expected call of MarkNotificationRead.
+func (mr *MockUserServiceMockRecorder) MarkNotificationRead(ctx, userName, notificationID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkNotificationRead", reflect.TypeOf((*MockUserService)(nil).MarkNotificationRead), ctx, userName, notificationID)
+}
+
+// RedeliverWebhook mocks base method.
+func (m *MockUserService) RedeliverWebhook(ctx context.Context, userName string, endpointID, deliveryID int) (*entities.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeliverWebhook", ctx, userName, endpointID, deliveryID)
+	ret0, _ := ret[0].(*entities.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RedeliverWebhook indicates an expected call of RedeliverWebhook.
+func (mr *MockUserServiceMockRecorder) RedeliverWebhook(ctx, userName, endpointID, deliveryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeliverWebhook", reflect.TypeOf((*MockUserService)(nil).RedeliverWebhook), ctx, userName, endpointID, deliveryID)
+}
+
+// RefundItem mocks base method.
+func (m *MockUserService) RefundItem(ctx context.Context, userName, itemName string, quantity int) (*entities.RefundOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundItem", ctx, userName, itemName, quantity)
+	ret0, _ := ret[0].(*entities.RefundOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundItem indicates an expected call of RefundItem.
+func (mr *MockUserServiceMockRecorder) RefundItem(ctx, userName, itemName, quantity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundItem", reflect.TypeOf((*MockUserService)(nil).RefundItem), ctx, userName, itemName, quantity)
+}
+
+// RequestPayment mocks base method.
+func (m *MockUserService) RequestPayment(ctx context.Context, requester, payer string, amount int, memo, category string) (*entities.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPayment", ctx, requester, payer, amount, memo, category)
+	ret0, _ := ret[0].(*entities.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestPayment indicates an expected call of RequestPayment.
+func (mr *MockUserServiceMockRecorder) RequestPayment(ctx, requester, payer, amount, memo, category interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPayment", reflect.TypeOf((*MockUserService)(nil).RequestPayment), ctx, requester, payer, amount, memo, category)
+}
+
+// SearchHistory mocks base method.
+func (m *MockUserService) SearchHistory(ctx context.Context, userName string, filter entities.TransferFilter, limit, offset int) (*entities.TransfersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchHistory", ctx, userName, filter, limit, offset)
+	ret0, _ := ret[0].(*entities.TransfersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchHistory indicates an expected call of SearchHistory.
+func (mr *MockUserServiceMockRecorder) SearchHistory(ctx, userName, filter, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchHistory", reflect.TypeOf((*MockUserService)(nil).SearchHistory), ctx, userName, filter, limit, offset)
 }
 
 // SendCoin mocks base method.
-func (m *MockUserService) SendCoin(fromUser, toUser string, amount int) error {
+func (m *MockUserService) SendCoin(ctx context.Context, fromUser, toUser string, amount int, memo, category string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendCoin", fromUser, toUser, amount)
+	ret := m.ctrl.Call(m, "SendCoin", ctx, fromUser, toUser, amount, memo, category)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendCoin indicates an expected call of SendCoin.
-func (mr *MockUserServiceMockRecorder) SendCoin(fromUser, toUser, amount interface{}) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) SendCoin(ctx, fromUser, toUser, amount, memo, category interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockUserService)(nil).SendCoin), ctx, fromUser, toUser, amount, memo, category)
+}
+
+// SendTestWebhook mocks base method.
+func (m *MockUserService) SendTestWebhook(ctx context.Context, userName string, endpointID int) (*entities.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendTestWebhook", ctx, userName, endpointID)
+	ret0, _ := ret[0].(*entities.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendTestWebhook indicates an expected call of SendTestWebhook.
+func (mr *MockUserServiceMockRecorder) SendTestWebhook(ctx, userName, endpointID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendTestWebhook", reflect.TypeOf((*MockUserService)(nil).SendTestWebhook), ctx, userName, endpointID)
+}
+
+// SetEmailSettings mocks base method.
+func (m *MockUserService) SetEmailSettings(ctx context.Context, userName string, settings entities.EmailSettings) (*entities.EmailSettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetEmailSettings", ctx, userName, settings)
+	ret0, _ := ret[0].(*entities.EmailSettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetEmailSettings indicates an expected call of SetEmailSettings.
+func (mr *MockUserServiceMockRecorder) SetEmailSettings(ctx, userName, settings interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmailSettings", reflect.TypeOf((*MockUserService)(nil).SetEmailSettings), ctx, userName, settings)
+}
+
+// SetNotificationPreferences mocks base method.
+func (m *MockUserService) SetNotificationPreferences(ctx context.Context, userName string, preferences entities.NotificationPreferences) (entities.NotificationPreferences, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetNotificationPreferences", ctx, userName, preferences)
+	ret0, _ := ret[0].(entities.NotificationPreferences)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetNotificationPreferences indicates an expected call of SetNotificationPreferences.
+func (mr *MockUserServiceMockRecorder) SetNotificationPreferences(ctx, userName, preferences interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNotificationPreferences", reflect.TypeOf((*MockUserService)(nil).SetNotificationPreferences), ctx, userName, preferences)
+}
+
+// SubscribeEvents mocks base method.
+func (m *MockUserService) SubscribeEvents(ctx context.Context, userName string) (<-chan struct{}, func(), error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeEvents", ctx, userName)
+	ret0, _ := ret[0].(<-chan struct{})
+	ret1, _ := ret[1].(func())
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// SubscribeEvents indicates an expected call of SubscribeEvents.
+func (mr *MockUserServiceMockRecorder) SubscribeEvents(ctx, userName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeEvents", reflect.TypeOf((*MockUserService)(nil).SubscribeEvents), ctx, userName)
+}
+
+// TransferItem mocks base method.
+func (m *MockUserService) TransferItem(ctx context.Context, fromUser, toUser, itemName string, quantity int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferItem", ctx, fromUser, toUser, itemName, quantity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransferItem indicates an expected call of TransferItem.
+func (mr *MockUserServiceMockRecorder) TransferItem(ctx, fromUser, toUser, itemName, quantity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferItem", reflect.TypeOf((*MockUserService)(nil).TransferItem), ctx, fromUser, toUser, itemName, quantity)
+}
+
+// UpdateSchedule mocks base method.
+func (m *MockUserService) UpdateSchedule(ctx context.Context, userName string, scheduleID int, req entities.ScheduleRequest) (*entities.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSchedule", ctx, userName, scheduleID, req)
+	ret0, _ := ret[0].(*entities.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSchedule indicates an expected call of UpdateSchedule.
+func (mr *MockUserServiceMockRecorder) UpdateSchedule(ctx, userName, scheduleID, req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockUserService)(nil).SendCoin), fromUser, toUser, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSchedule", reflect.TypeOf((*MockUserService)(nil).UpdateSchedule), ctx, userName, scheduleID, req)
 }
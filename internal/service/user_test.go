@@ -1,21 +1,37 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/repository/memory"
 	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// passthroughTx runs fn directly; the repository mock has no transaction to
+// join.
+type passthroughTx struct{}
+
+func (passthroughTx) WithinTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
 func TestBuyItem(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := repository.NewMockUserRepo(ctrl)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, passthroughTx{})
 
 	t.Run("success", func(t *testing.T) {
 		userName := "test_user"
@@ -23,11 +39,11 @@ func TestBuyItem(t *testing.T) {
 		userID := 1
 		itemID := 100
 
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetItemID(itemName).Return(itemID, nil)
-		mockRepo.EXPECT().BuyItem(userID, itemID).Return(nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), itemName).Return(itemID, nil)
+		mockRepo.EXPECT().BuyItem(gomock.Any(), userID, itemID).Return(nil)
 
-		err := userService.BuyItem(userName, itemName)
+		err := userService.BuyItem(context.Background(), userName, itemName)
 		assert.NoError(t, err)
 	})
 
@@ -35,9 +51,9 @@ func TestBuyItem(t *testing.T) {
 		userName := "test_user"
 		someError := errors.New("user not found")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(0, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(0, someError)
 
-		err := userService.BuyItem(userName, "test_item")
+		err := userService.BuyItem(context.Background(), userName, "test_item")
 		assert.Error(t, err)
 		assert.Equal(t, someError, err)
 	})
@@ -48,10 +64,10 @@ func TestBuyItem(t *testing.T) {
 		userID := 1
 		someError := errors.New("item not found")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetItemID(itemName).Return(0, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), itemName).Return(0, someError)
 
-		err := userService.BuyItem(userName, itemName)
+		err := userService.BuyItem(context.Background(), userName, itemName)
 		assert.Error(t, err)
 		assert.Equal(t, someError, err)
 	})
@@ -63,22 +79,64 @@ func TestBuyItem(t *testing.T) {
 		itemID := 100
 		someError := errors.New("failed to buy item")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetItemID(itemName).Return(itemID, nil)
-		mockRepo.EXPECT().BuyItem(userID, itemID).Return(someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), itemName).Return(itemID, nil)
+		mockRepo.EXPECT().BuyItem(gomock.Any(), userID, itemID).Return(someError)
 
-		err := userService.BuyItem(userName, itemName)
+		err := userService.BuyItem(context.Background(), userName, itemName)
 		assert.Error(t, err)
 		assert.Equal(t, someError, err)
 	})
 }
 
+// recordingTx remembers the options WithinTx was called with and fails with
+// err without running fn when err is set.
+type recordingTx struct {
+	opts *sql.TxOptions
+	err  error
+}
+
+func (r *recordingTx) WithinTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	r.opts = opts
+	if r.err != nil {
+		return r.err
+	}
+	return fn(ctx)
+}
+
+func TestBuyItemRunsInTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	tx := &recordingTx{}
+	userService := NewUserService(mockRepo, tx)
+
+	t.Run("serializable", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "test_user").Return(1, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(2, nil)
+		mockRepo.EXPECT().BuyItem(gomock.Any(), 1, 2).Return(nil)
+
+		err := userService.BuyItem(context.Background(), "test_user", "cup")
+		assert.NoError(t, err)
+		assert.Equal(t, sql.LevelSerializable, tx.opts.Isolation)
+	})
+
+	t.Run("transaction error", func(t *testing.T) {
+		tx.err = errors.New("could not serialize access")
+
+		err := userService.BuyItem(context.Background(), "test_user", "cup")
+		assert.Equal(t, tx.err, err)
+	})
+}
+
 func TestSendCoin(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := repository.NewMockUserRepo(ctrl)
-	userService := NewUserService(mockRepo)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	userService := NewUserService(mockRepo, passthroughTx{})
 
 	t.Run("success", func(t *testing.T) {
 		fromUser := "alice"
@@ -87,11 +145,11 @@ func TestSendCoin(t *testing.T) {
 		fromUserID := 1
 		toUserID := 2
 
-		mockRepo.EXPECT().GetUserID(fromUser).Return(fromUserID, nil)
-		mockRepo.EXPECT().GetUserID(toUser).Return(toUserID, nil)
-		mockRepo.EXPECT().SendCoin(fromUserID, toUserID, amount).Return(nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), fromUser).Return(fromUserID, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), toUser).Return(toUserID, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), fromUserID, toUserID, amount, "", entities.TransferCategoryOther).Return(nil)
 
-		err := userService.SendCoin(fromUser, toUser, amount)
+		err := userService.SendCoin(context.Background(), fromUser, toUser, amount, "", "")
 		assert.NoError(t, err)
 	})
 
@@ -101,13 +159,24 @@ func TestSendCoin(t *testing.T) {
 		amount := 50
 		someError := errors.New("user not found")
 
-		mockRepo.EXPECT().GetUserID(fromUser).Return(0, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), fromUser).Return(0, someError)
 
-		err := userService.SendCoin(fromUser, toUser, amount)
+		err := userService.SendCoin(context.Background(), fromUser, toUser, amount, "", "")
 		assert.Error(t, err)
 		assert.Equal(t, someError, err)
 	})
 
+	t.Run("unknown receiver", func(t *testing.T) {
+		fromUser := "alice"
+		toUser := "ghost"
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), fromUser).Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), toUser).Return(0, utils.ErrNoUser)
+
+		err := userService.SendCoin(context.Background(), fromUser, toUser, 50, "", "")
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+	})
+
 	t.Run("send coin id error", func(t *testing.T) {
 		fromUser := "alice"
 		toUser := "bob"
@@ -116,15 +185,34 @@ func TestSendCoin(t *testing.T) {
 		toUserID := 2
 		someError := errors.New("failed to send coins")
 
-		mockRepo.EXPECT().GetUserID(fromUser).Return(fromUserID, nil)
-		mockRepo.EXPECT().GetUserID(toUser).Return(toUserID, nil)
-		mockRepo.EXPECT().SendCoin(fromUserID, toUserID, amount).Return(someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), fromUser).Return(fromUserID, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), toUser).Return(toUserID, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), fromUserID, toUserID, amount, "", entities.TransferCategoryOther).Return(someError)
 
-		err := userService.SendCoin(fromUser, toUser, amount)
+		err := userService.SendCoin(context.Background(), fromUser, toUser, amount, "", "")
 		assert.Error(t, err)
 		assert.Equal(t, someError, err)
 	})
 
+	t.Run("memo and category", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 30, "pizza night", entities.TransferCategoryReimbursement).Return(nil)
+
+		err := userService.SendCoin(context.Background(), "alice", "bob", 30, "  pizza\n\tnight ", entities.TransferCategoryReimbursement)
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		err := userService.SendCoin(context.Background(), "alice", "bob", 0, "", "")
+		assert.True(t, errors.Is(err, utils.ErrBadAmount))
+
+		err = userService.SendCoin(context.Background(), "alice", "bob", 10, strings.Repeat("я", MaxMemoLength+1), "")
+		assert.True(t, errors.Is(err, utils.ErrMemoTooLong))
+
+		err = userService.SendCoin(context.Background(), "alice", "bob", 10, "", "bribe")
+		assert.True(t, errors.Is(err, utils.ErrBadCategory))
+	})
 }
 
 func TestGetInfo(t *testing.T) {
@@ -132,7 +220,7 @@ func TestGetInfo(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := repository.NewMockUserRepo(ctrl)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, passthroughTx{})
 	t.Run("success", func(t *testing.T) {
 		userName := "test_user"
 		userID := 1
@@ -140,28 +228,43 @@ func TestGetInfo(t *testing.T) {
 		inventory := []*entities.Item{{ItemType: "cup", Quantity: 2}}
 		receives := []*entities.ReceiveOperation{{FromUser: "Konstantin", Amount: 50}}
 		sents := []*entities.SentOperation{{ToUser: "Masha", Amount: 20}}
-
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetCoinsInfo(userID).Return(coins, nil)
-		mockRepo.EXPECT().GetInventoryInfo(userID).Return(inventory, nil)
-		mockRepo.EXPECT().GetReceiveInfo(userID).Return(receives, nil)
-		mockRepo.EXPECT().GetSentInfo(userID).Return(sents, nil)
-
-		info, err := userService.GetInfo(userName)
+		refunds := []*entities.RefundOperation{{Item: "pen", Quantity: 1, Amount: 10}}
+		sentGifts := []*entities.SentGift{{ToUser: "Masha", Item: "cup", Message: "thanks"}}
+		receivedGifts := []*entities.ReceivedGift{{FromUser: "Konstantin", Item: "pen"}}
+		sentItems := []*entities.SentItemOperation{{ToUser: "Masha", Item: "book", Quantity: 2}}
+		receivedItems := []*entities.ReceivedItemOperation{{FromUser: "Konstantin", Item: "socks", Quantity: 1}}
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), userID).Return(coins, nil)
+		mockRepo.EXPECT().GetInventoryInfo(gomock.Any(), userID).Return(inventory, nil)
+		mockRepo.EXPECT().GetReceiveInfo(gomock.Any(), userID).Return(receives, nil)
+		mockRepo.EXPECT().GetSentInfo(gomock.Any(), userID).Return(sents, nil)
+		mockRepo.EXPECT().GetRefundInfo(gomock.Any(), userID).Return(refunds, nil)
+		mockRepo.EXPECT().GetSentGifts(gomock.Any(), userID).Return(sentGifts, nil)
+		mockRepo.EXPECT().GetReceivedGifts(gomock.Any(), userID).Return(receivedGifts, nil)
+		mockRepo.EXPECT().GetSentItems(gomock.Any(), userID).Return(sentItems, nil)
+		mockRepo.EXPECT().GetReceivedItems(gomock.Any(), userID).Return(receivedItems, nil)
+
+		info, err := userService.GetInfo(context.Background(), userName)
 		assert.NoError(t, err)
 		assert.Equal(t, coins, info.Coins)
 		assert.Equal(t, inventory, info.Inventory)
 		assert.Equal(t, receives, info.CoinHistory.Received)
 		assert.Equal(t, sents, info.CoinHistory.Sent)
+		assert.Equal(t, refunds, info.CoinHistory.Refunds)
+		assert.Equal(t, sentGifts, info.GiftHistory.Sent)
+		assert.Equal(t, receivedGifts, info.GiftHistory.Received)
+		assert.Equal(t, sentItems, info.ItemHistory.Sent)
+		assert.Equal(t, receivedItems, info.ItemHistory.Received)
 	})
 
 	t.Run("get user id error", func(t *testing.T) {
 		userName := "test_user"
 		someError := errors.New("user not found")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(0, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(0, someError)
 
-		info, err := userService.GetInfo(userName)
+		info, err := userService.GetInfo(context.Background(), userName)
 		assert.Error(t, err)
 		assert.Nil(t, info)
 		assert.Equal(t, someError, err)
@@ -172,10 +275,10 @@ func TestGetInfo(t *testing.T) {
 		userID := 1
 		someError := errors.New("user not found")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetCoinsInfo(userID).Return(0, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), userID).Return(0, someError)
 
-		info, err := userService.GetInfo(userName)
+		info, err := userService.GetInfo(context.Background(), userName)
 		assert.Error(t, err)
 		assert.Nil(t, info)
 		assert.Equal(t, someError, err)
@@ -187,11 +290,11 @@ func TestGetInfo(t *testing.T) {
 		coins := 100
 		someError := errors.New("user not found")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetCoinsInfo(userID).Return(coins, nil)
-		mockRepo.EXPECT().GetInventoryInfo(userID).Return(nil, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), userID).Return(coins, nil)
+		mockRepo.EXPECT().GetInventoryInfo(gomock.Any(), userID).Return(nil, someError)
 
-		info, err := userService.GetInfo(userName)
+		info, err := userService.GetInfo(context.Background(), userName)
 		assert.Error(t, err)
 		assert.Nil(t, info)
 		assert.Equal(t, someError, err)
@@ -204,12 +307,12 @@ func TestGetInfo(t *testing.T) {
 		inventory := []*entities.Item{{ItemType: "cup", Quantity: 2}}
 		someError := errors.New("user not found")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetCoinsInfo(userID).Return(coins, nil)
-		mockRepo.EXPECT().GetInventoryInfo(userID).Return(inventory, nil)
-		mockRepo.EXPECT().GetReceiveInfo(userID).Return(nil, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), userID).Return(coins, nil)
+		mockRepo.EXPECT().GetInventoryInfo(gomock.Any(), userID).Return(inventory, nil)
+		mockRepo.EXPECT().GetReceiveInfo(gomock.Any(), userID).Return(nil, someError)
 
-		info, err := userService.GetInfo(userName)
+		info, err := userService.GetInfo(context.Background(), userName)
 		assert.Error(t, err)
 		assert.Nil(t, info)
 		assert.Equal(t, someError, err)
@@ -223,13 +326,13 @@ func TestGetInfo(t *testing.T) {
 		receives := []*entities.ReceiveOperation{{FromUser: "Konstantin", Amount: 50}}
 		someError := errors.New("user not found")
 
-		mockRepo.EXPECT().GetUserID(userName).Return(userID, nil)
-		mockRepo.EXPECT().GetCoinsInfo(userID).Return(coins, nil)
-		mockRepo.EXPECT().GetInventoryInfo(userID).Return(inventory, nil)
-		mockRepo.EXPECT().GetReceiveInfo(userID).Return(receives, nil)
-		mockRepo.EXPECT().GetSentInfo(userID).Return(nil, someError)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), userID).Return(coins, nil)
+		mockRepo.EXPECT().GetInventoryInfo(gomock.Any(), userID).Return(inventory, nil)
+		mockRepo.EXPECT().GetReceiveInfo(gomock.Any(), userID).Return(receives, nil)
+		mockRepo.EXPECT().GetSentInfo(gomock.Any(), userID).Return(nil, someError)
 
-		info, err := userService.GetInfo(userName)
+		info, err := userService.GetInfo(context.Background(), userName)
 		assert.Error(t, err)
 		assert.Nil(t, info)
 		assert.Equal(t, someError, err)
@@ -237,21 +340,271 @@ func TestGetInfo(t *testing.T) {
 
 }
 
+func TestTransferItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().TransferItem(gomock.Any(), 1, 2, 3, 2).Return(nil)
+
+		err := userService.TransferItem(context.Background(), "alice", "bob", "cup", 2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("not enough items", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().TransferItem(gomock.Any(), 1, 2, 3, 5).Return(utils.ErrNotEnoughItems)
+
+		err := userService.TransferItem(context.Background(), "alice", "bob", "cup", 5)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+	})
+
+	t.Run("unknown recipient", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "nobody").Return(0, utils.ErrNoUser)
+
+		err := userService.TransferItem(context.Background(), "alice", "nobody", "cup", 1)
+		assert.True(t, errors.Is(err, utils.ErrNoUser))
+	})
+
+	t.Run("bad quantity", func(t *testing.T) {
+		err := userService.TransferItem(context.Background(), "alice", "bob", "cup", 0)
+		assert.True(t, errors.Is(err, utils.ErrBadQuantity))
+	})
+
+	t.Run("self transfer", func(t *testing.T) {
+		err := userService.TransferItem(context.Background(), "alice", "alice", "cup", 1)
+		assert.True(t, errors.Is(err, utils.ErrSelfTransfer))
+	})
+}
+
+type recordingNotifier struct {
+	notifications []*entities.Notification
+	err           error
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, notification *entities.Notification) error {
+	r.notifications = append(r.notifications, notification)
+	return r.err
+}
+
+func TestGiftItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	notifier := &recordingNotifier{}
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Notifier = notifier
+
+	t.Run("success notifies recipient", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().GiftItem(gomock.Any(), 1, 2, 3, "happy birthday").Return(nil)
+
+		err := userService.GiftItem(context.Background(), "alice", "bob", "cup", "  happy birthday ")
+		assert.NoError(t, err)
+		assert.Equal(t, []*entities.Notification{{
+			Recipient: "bob",
+			Kind:      entities.NotificationGiftReceived,
+			Text:      "alice sent you a cup: happy birthday",
+		}}, notifier.notifications)
+	})
+
+	t.Run("notify error does not fail the gift", func(t *testing.T) {
+		notifier.err = errors.New("mail is down")
+		defer func() { notifier.err = nil }()
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().GiftItem(gomock.Any(), 1, 2, 3, "").Return(nil)
+
+		err := userService.GiftItem(context.Background(), "alice", "bob", "cup", "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("repo error skips notification", func(t *testing.T) {
+		notifier.notifications = nil
+		someError := errors.New("not enough coins")
+
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+		mockRepo.EXPECT().GiftItem(gomock.Any(), 1, 2, 3, "").Return(someError)
+
+		err := userService.GiftItem(context.Background(), "alice", "bob", "cup", "")
+		assert.Equal(t, someError, err)
+		assert.Empty(t, notifier.notifications)
+	})
+
+	t.Run("self gift", func(t *testing.T) {
+		err := userService.GiftItem(context.Background(), "alice", "alice", "cup", "")
+		assert.True(t, errors.Is(err, utils.ErrSelfGift))
+	})
+
+	t.Run("message too long", func(t *testing.T) {
+		message := strings.Repeat("я", MaxGiftMessageLength+1)
+		err := userService.GiftItem(context.Background(), "alice", "bob", "cup", message)
+		assert.True(t, errors.Is(err, utils.ErrMessageTooLong))
+	})
+}
+
+func TestRefundItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.RefundWindow = 24 * time.Hour
+
+	userName := "alice"
+	itemName := "cup"
+	userID := 1
+	itemID := 2
+	orders := []*entities.Order{
+		{ID: 3, ItemType: itemName, Quantity: 1, UnitPrice: 25, CreatedAt: now.Add(-time.Hour)},
+		{ID: 2, ItemType: itemName, Quantity: 1, UnitPrice: 20, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: 1, ItemType: itemName, Quantity: 1, UnitPrice: 20, CreatedAt: now.Add(-48 * time.Hour)},
+	}
+
+	t.Run("refunds newest orders at price paid", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), itemName).Return(itemID, nil)
+		mockRepo.EXPECT().GetRefundableOrders(gomock.Any(), userID, itemID).Return(orders, nil)
+		mockRepo.EXPECT().AddRefund(gomock.Any(), &entities.Refund{OrderID: 3, UserID: userID, ItemID: itemID, Quantity: 1, Amount: 25}).Return(nil)
+		mockRepo.EXPECT().AddRefund(gomock.Any(), &entities.Refund{OrderID: 2, UserID: userID, ItemID: itemID, Quantity: 1, Amount: 20}).Return(nil)
+
+		refund, err := userService.RefundItem(context.Background(), userName, itemName, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.RefundOperation{Item: itemName, Quantity: 2, Amount: 45}, refund)
+	})
+
+	t.Run("orders outside the window are not refundable", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), itemName).Return(itemID, nil)
+		mockRepo.EXPECT().GetRefundableOrders(gomock.Any(), userID, itemID).Return(orders, nil)
+
+		_, err := userService.RefundItem(context.Background(), userName, itemName, 3)
+		assert.True(t, errors.Is(err, utils.ErrNothingToRefund))
+	})
+
+	t.Run("admin ignores the window", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), itemName).Return(itemID, nil)
+		mockRepo.EXPECT().GetRefundableOrders(gomock.Any(), userID, itemID).Return(orders[2:], nil)
+		mockRepo.EXPECT().AddRefund(gomock.Any(), &entities.Refund{OrderID: 1, UserID: userID, ItemID: itemID, Quantity: 1, Amount: 20, Actor: "admin"}).Return(nil)
+
+		refund, err := userService.ForceRefund(context.Background(), "admin", userName, itemName, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.RefundOperation{Item: itemName, Quantity: 1, Amount: 20, RefundedBy: "admin"}, refund)
+	})
+
+	t.Run("bad quantity", func(t *testing.T) {
+		_, err := userService.RefundItem(context.Background(), userName, itemName, 0)
+		assert.Equal(t, utils.ErrBadQuantity, err)
+	})
+
+	t.Run("add refund error", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), userName).Return(userID, nil)
+		mockRepo.EXPECT().GetItemID(gomock.Any(), itemName).Return(itemID, nil)
+		mockRepo.EXPECT().GetRefundableOrders(gomock.Any(), userID, itemID).Return(orders, nil)
+		mockRepo.EXPECT().AddRefund(gomock.Any(), gomock.Any()).Return(utils.ErrNotEnoughItems)
+
+		_, err := userService.RefundItem(context.Background(), userName, itemName, 1)
+		assert.True(t, errors.Is(err, utils.ErrNotEnoughItems))
+	})
+}
+
+func TestGetOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	orders := []*entities.Order{{ID: 2, ItemType: "cup", Quantity: 1, UnitPrice: 20, Total: 20}}
+
+	t.Run("default limit", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountOrders(gomock.Any(), 1, "").Return(1, nil)
+		mockRepo.EXPECT().GetOrders(gomock.Any(), 1, "", DefaultOrdersLimit, 0).Return(orders, nil)
+
+		page, err := userService.GetOrders(context.Background(), "alice", "", 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.OrdersPage{Orders: orders, Total: 1, Limit: DefaultOrdersLimit}, page)
+	})
+
+	t.Run("limit is capped", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountOrders(gomock.Any(), 1, "cup").Return(30, nil)
+		mockRepo.EXPECT().GetOrders(gomock.Any(), 1, "cup", MaxOrdersLimit, 10).Return(orders, nil)
+
+		page, err := userService.GetOrders(context.Background(), "alice", "cup", 1000, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, MaxOrdersLimit, page.Limit)
+		assert.Equal(t, 10, page.Offset)
+		assert.Equal(t, 30, page.Total)
+	})
+
+	t.Run("negative offset", func(t *testing.T) {
+		_, err := userService.GetOrders(context.Background(), "alice", "", 10, -1)
+		assert.Equal(t, utils.ErrBadPage, err)
+	})
+
+	t.Run("count error", func(t *testing.T) {
+		someError := errors.New("db error")
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().CountOrders(gomock.Any(), 1, "").Return(0, someError)
+
+		_, err := userService.GetOrders(context.Background(), "alice", "", 0, 0)
+		assert.Equal(t, someError, err)
+	})
+}
+
 func TestAuth(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := repository.NewMockUserRepo(ctrl)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	userService.Now = func() time.Time { return now }
 
 	t.Run("success", func(t *testing.T) {
 		userName := "test_user"
 		password := "secure_password"
 		expectedUser := &entities.User{ID: 1, Username: userName}
 
-		mockRepo.EXPECT().Auth(userName, password).Return(expectedUser, nil)
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).Return(&entities.LoginAttempts{Username: userName}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), userName, password).Return(expectedUser, nil)
+
+		user, err := userService.Auth(context.Background(), userName, password)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedUser, user)
+	})
+
+	t.Run("success resets failed attempts", func(t *testing.T) {
+		userName := "test_user"
+		password := "secure_password"
+		expectedUser := &entities.User{ID: 1, Username: userName}
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).
+			Return(&entities.LoginAttempts{Username: userName, FailedCount: 2, BlockedUntil: now.Add(-time.Second)}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), userName, password).Return(expectedUser, nil)
+		mockRepo.EXPECT().ResetLoginAttempts(gomock.Any(), userName).Return(nil)
 
-		user, err := userService.Auth(userName, password)
+		user, err := userService.Auth(context.Background(), userName, password)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedUser, user)
 	})
@@ -261,11 +614,178 @@ func TestAuth(t *testing.T) {
 		password := "wrong_password"
 		someError := errors.New("authentication failed")
 
-		mockRepo.EXPECT().Auth(userName, password).Return(nil, someError)
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).Return(&entities.LoginAttempts{Username: userName}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), userName, password).Return(nil, someError)
 
-		user, err := userService.Auth(userName, password)
+		user, err := userService.Auth(context.Background(), userName, password)
 		assert.Error(t, err)
 		assert.Nil(t, user)
 		assert.Equal(t, someError, err)
 	})
+
+	t.Run("lock login attempts error", func(t *testing.T) {
+		userName := "test_user"
+		someError := errors.New("db error")
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).Return(nil, someError)
+
+		user, err := userService.Auth(context.Background(), userName, "password")
+		assert.Nil(t, user)
+		assert.Equal(t, someError, err)
+	})
+
+	t.Run("wrong password starts backoff", func(t *testing.T) {
+		userName := "test_user"
+		password := "wrong_password"
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).Return(&entities.LoginAttempts{Username: userName, FailedCount: 2}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), userName, password).Return(nil, utils.ErrWrongPass)
+		mockRepo.EXPECT().RegisterFailedLogin(gomock.Any(), userName, now, now.Add(-DefaultLockoutPolicy.FailureWindow)).Return(3, nil)
+		mockRepo.EXPECT().BlockLogin(gomock.Any(), userName, now.Add(4*time.Second)).Return(nil)
+
+		user, err := userService.Auth(context.Background(), userName, password)
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, utils.ErrWrongPass))
+	})
+
+	t.Run("wrong password locks account", func(t *testing.T) {
+		userName := "test_user"
+		password := "wrong_password"
+		lockedUntil := now.Add(DefaultLockoutPolicy.LockoutDuration)
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).Return(&entities.LoginAttempts{Username: userName, FailedCount: 4}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), userName, password).Return(nil, utils.ErrWrongPass)
+		mockRepo.EXPECT().RegisterFailedLogin(gomock.Any(), userName, now, now.Add(-DefaultLockoutPolicy.FailureWindow)).Return(5, nil)
+		mockRepo.EXPECT().BlockLogin(gomock.Any(), userName, lockedUntil).Return(nil)
+		mockRepo.EXPECT().AddLockoutEvent(gomock.Any(), &entities.LockoutEvent{
+			Username:    userName,
+			Action:      entities.LockoutActionLocked,
+			FailedCount: 5,
+			LockedUntil: lockedUntil,
+		}).Return(nil)
+
+		user, err := userService.Auth(context.Background(), userName, password)
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, utils.ErrWrongPass))
+	})
+
+	t.Run("failures kept without a window", func(t *testing.T) {
+		userName := "test_user"
+		password := "wrong_password"
+		userService.Lockout.FailureWindow = 0
+		defer func() { userService.Lockout.FailureWindow = DefaultLockoutPolicy.FailureWindow }()
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).Return(&entities.LoginAttempts{Username: userName}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), userName, password).Return(nil, utils.ErrWrongPass)
+		mockRepo.EXPECT().RegisterFailedLogin(gomock.Any(), userName, now, time.Time{}).Return(1, nil)
+		mockRepo.EXPECT().BlockLogin(gomock.Any(), userName, now.Add(time.Second)).Return(nil)
+
+		_, err := userService.Auth(context.Background(), userName, password)
+		assert.True(t, errors.Is(err, utils.ErrWrongPass))
+	})
+
+	t.Run("register failed login error", func(t *testing.T) {
+		userName := "test_user"
+		password := "wrong_password"
+		someError := errors.New("db error")
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).Return(&entities.LoginAttempts{Username: userName}, nil)
+		mockRepo.EXPECT().Auth(gomock.Any(), userName, password).Return(nil, utils.ErrWrongPass)
+		mockRepo.EXPECT().RegisterFailedLogin(gomock.Any(), userName, now, now.Add(-DefaultLockoutPolicy.FailureWindow)).Return(0, someError)
+
+		user, err := userService.Auth(context.Background(), userName, password)
+		assert.Nil(t, user)
+		assert.Equal(t, someError, err)
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		userName := "test_user"
+
+		mockRepo.EXPECT().LockLoginAttempts(gomock.Any(), userName).
+			Return(&entities.LoginAttempts{Username: userName, FailedCount: 5, BlockedUntil: now.Add(time.Minute)}, nil)
+
+		user, err := userService.Auth(context.Background(), userName, "secure_password")
+		assert.Nil(t, user)
+		assert.True(t, errors.Is(err, utils.ErrTooManyAttempts))
+
+		var blocked *utils.LoginBlockedError
+		assert.True(t, errors.As(err, &blocked))
+		assert.Equal(t, time.Minute, blocked.RetryAfter)
+	})
+}
+
+func TestLockoutPolicyBackoff(t *testing.T) {
+	policy := LockoutPolicy{
+		MaxAttempts:     10,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutDuration: time.Hour,
+	}
+
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 8*time.Second, policy.backoff(4))
+	assert.Equal(t, 10*time.Second, policy.backoff(5))
+	assert.Equal(t, 10*time.Second, policy.backoff(9))
+}
+
+func TestConcurrentWrongPasswords(t *testing.T) {
+	repo := memory.NewUserMemoryRepo()
+	userService := NewUserService(repo, repo)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	userService.Now = func() time.Time { return now }
+
+	_, err := userService.Auth(context.Background(), "victim", "secure_password")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wrong, blocked := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := userService.Auth(context.Background(), "victim", "guess")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, utils.ErrWrongPass) {
+				wrong++
+			} else if errors.Is(err, utils.ErrTooManyAttempts) {
+				blocked++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, wrong, "only the first guess reaches the password check")
+	assert.Equal(t, 19, blocked)
+}
+
+func TestUnlockUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().ResetLoginAttempts(gomock.Any(), "test_user").Return(nil)
+		mockRepo.EXPECT().AddLockoutEvent(gomock.Any(), &entities.LockoutEvent{
+			Username: "test_user",
+			Action:   entities.LockoutActionUnlocked,
+			Actor:    "admin",
+		}).Return(nil)
+
+		err := userService.UnlockUser(context.Background(), "admin", "test_user")
+		assert.NoError(t, err)
+	})
+
+	t.Run("reset error", func(t *testing.T) {
+		someError := errors.New("db error")
+		mockRepo.EXPECT().ResetLoginAttempts(gomock.Any(), "test_user").Return(someError)
+
+		err := userService.UnlockUser(context.Background(), "admin", "test_user")
+		assert.Equal(t, someError, err)
+	})
 }
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/KonstantinGalanin/itemStore/internal/webhook"
+)
+
+const (
+	// MaxWebhookEndpoints is how many endpoints a user may register.
+	MaxWebhookEndpoints = 10
+
+	DefaultWebhookDeliveriesLimit = 20
+	MaxWebhookDeliveriesLimit     = 100
+
+	// DefaultLowBalanceThreshold is the balance below which a spend sends
+	// a balance.low webhook.
+	DefaultLowBalanceThreshold = 100
+
+	// A failed delivery is retried after webhookBaseRetry, doubled per
+	// failed attempt up to webhookMaxRetry, and marked dead after
+	// WebhookMaxAttempts attempts.
+	WebhookMaxAttempts = 8
+	webhookBaseRetry   = 30 * time.Second
+	webhookMaxRetry    = 6 * time.Hour
+
+	// webhookClaimTTL is how long a claimed delivery is held back from
+	// other workers while it is being sent.
+	webhookClaimTTL = time.Minute
+	// maxWebhooksPerSweep bounds the work a single DeliverWebhooks call
+	// does.
+	maxWebhooksPerSweep = 100
+)
+
+// WebhookQueue queues webhook deliveries for a user's endpoints. Like
+// Publisher it is called inside the transaction that makes the change, so
+// deliveries are queued if and only if the change is committed.
+type WebhookQueue interface {
+	// Subscribed reports whether the user has an endpoint for eventType.
+	Subscribed(ctx context.Context, userID int, eventType string) (bool, error)
+	// Enqueue queues a copy of delivery for each of the user's endpoints
+	// subscribed to its event type.
+	Enqueue(ctx context.Context, userID int, delivery *entities.WebhookDelivery) error
+}
+
+type nopWebhookQueue struct{}
+
+func (nopWebhookQueue) Subscribed(ctx context.Context, userID int, eventType string) (bool, error) {
+	return false, nil
+}
+
+func (nopWebhookQueue) Enqueue(ctx context.Context, userID int, delivery *entities.WebhookDelivery) error {
+	return nil
+}
+
+// Webhooks is the WebhookQueue that stores deliveries with the repository,
+// for DeliverWebhooks to send.
+type Webhooks struct {
+	Repo UserRepo
+}
+
+func NewWebhooks(repo UserRepo) *Webhooks {
+	return &Webhooks{
+		Repo: repo,
+	}
+}
+
+func (w *Webhooks) Subscribed(ctx context.Context, userID int, eventType string) (bool, error) {
+	endpoints, err := w.Repo.GetWebhookEndpoints(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(endpoints, func(endpoint *entities.WebhookEndpoint) bool {
+		return slices.Contains(endpoint.EventTypes, eventType)
+	}), nil
+}
+
+func (w *Webhooks) Enqueue(ctx context.Context, userID int, delivery *entities.WebhookDelivery) error {
+	endpoints, err := w.Repo.GetWebhookEndpoints(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if !slices.Contains(endpoint.EventTypes, delivery.EventType) {
+			continue
+		}
+
+		queued := *delivery
+		queued.EndpointID = endpoint.ID
+		if err := w.Repo.AddWebhookDelivery(ctx, &queued); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WebhookSender makes the request for one delivery. It returns the response
+// status, or zero if there was none, and an error unless the endpoint
+// accepted the delivery.
+type WebhookSender interface {
+	Send(ctx context.Context, endpoint *entities.WebhookEndpoint, delivery *entities.WebhookDelivery) (int, error)
+}
+
+// errNoWebhookSender fails deliveries while no WebhookSender is set.
+var errNoWebhookSender = errors.New("no webhook sender configured")
+
+type nopWebhookSender struct{}
+
+func (nopWebhookSender) Send(ctx context.Context, endpoint *entities.WebhookEndpoint, delivery *entities.WebhookDelivery) (int, error) {
+	return 0, errNoWebhookSender
+}
+
+// webhook queues an eventType webhook for userID's endpoints.
+func (u *UserService) webhook(ctx context.Context, userID int, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", eventType, err)
+	}
+
+	now := u.Now().UTC().Truncate(time.Microsecond)
+	return u.Webhooks.Enqueue(ctx, userID, &entities.WebhookDelivery{
+		EventType:     eventType,
+		Payload:       data,
+		Status:        entities.WebhookDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+// watchBalance is called before userID spends coins in a transaction and
+// returns the function to call after the spend, in the same transaction.
+// That function queues a balance.low webhook if the spend took the balance
+// below LowBalanceThreshold. Balances are only read for users subscribed
+// to balance.low.
+func (u *UserService) watchBalance(ctx context.Context, userID int, userName string) (func() error, error) {
+	subscribed, err := u.Webhooks.Subscribed(ctx, userID, entities.WebhookLowBalance)
+	if err != nil {
+		return nil, err
+	}
+	if !subscribed {
+		return func() error { return nil }, nil
+	}
+
+	before, err := u.UserRepo.GetCoinsInfo(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		after, err := u.UserRepo.GetCoinsInfo(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if before < u.LowBalanceThreshold || after >= u.LowBalanceThreshold {
+			return nil
+		}
+
+		return u.webhook(ctx, userID, entities.WebhookLowBalance, entities.LowBalanceEvent{
+			User:      userName,
+			Balance:   after,
+			Threshold: u.LowBalanceThreshold,
+		})
+	}, nil
+}
+
+// CreateWebhook registers an endpoint for the user. The returned endpoint
+// is the only one that carries the signing secret.
+func (u *UserService) CreateWebhook(ctx context.Context, userName string, req entities.WebhookEndpointRequest) (*entities.WebhookEndpoint, error) {
+	endpoint, err := validateWebhook(req)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("webhook secret: %w", err)
+	}
+	endpoint.Secret = "whsec_" + hex.EncodeToString(secret)
+	endpoint.CreatedAt = u.Now().UTC().Truncate(time.Microsecond)
+
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		var err error
+		if endpoint.UserID, err = u.UserRepo.GetUserID(ctx, userName); err != nil {
+			return err
+		}
+
+		endpoints, err := u.UserRepo.GetWebhookEndpoints(ctx, endpoint.UserID)
+		if err != nil {
+			return err
+		}
+		if len(endpoints) >= MaxWebhookEndpoints {
+			return utils.ErrTooManyWebhooks
+		}
+
+		return u.UserRepo.AddWebhookEndpoint(ctx, endpoint)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+// validateWebhook checks an endpoint request and returns the endpoint it
+// describes, with each event type listed once.
+func validateWebhook(req entities.WebhookEndpointRequest) (*entities.WebhookEndpoint, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, utils.ErrBadWebhookURL
+	}
+	// The sender checks every address it connects to; this only turns the
+	// obvious cases away before they are stored.
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return nil, utils.ErrPrivateWebhookURL
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !webhook.PublicIP(ip) {
+		return nil, utils.ErrPrivateWebhookURL
+	}
+	if len(req.EventTypes) == 0 {
+		return nil, utils.ErrNoEventTypes
+	}
+
+	var eventTypes []string
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(entities.WebhookEventTypes, eventType) {
+			return nil, fmt.Errorf("%w %q", utils.ErrBadEventType, eventType)
+		}
+		if !slices.Contains(eventTypes, eventType) {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	return &entities.WebhookEndpoint{
+		URL:        target.String(),
+		EventTypes: eventTypes,
+	}, nil
+}
+
+// GetWebhooks returns the user's endpoints, without their secrets.
+func (u *UserService) GetWebhooks(ctx context.Context, userName string) ([]*entities.WebhookEndpoint, error) {
+	var endpoints []*entities.WebhookEndpoint
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		userID, err := u.UserRepo.GetUserID(ctx, userName)
+		if err != nil {
+			return err
+		}
+
+		endpoints, err = u.UserRepo.GetWebhookEndpoints(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, endpoint := range endpoints {
+		endpoint.Secret = ""
+	}
+
+	return endpoints, nil
+}
+
+// DeleteWebhook removes one of the user's endpoints with its deliveries.
+func (u *UserService) DeleteWebhook(ctx context.Context, userName string, endpointID int) error {
+	return u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		if _, err := u.ownWebhook(ctx, userName, endpointID); err != nil {
+			return err
+		}
+
+		return u.UserRepo.DeleteWebhookEndpoint(ctx, endpointID)
+	})
+}
+
+// GetWebhookDeliveries returns a page of an endpoint's deliveries, newest
+// first. A status of "dead" lists the endpoint's dead letters.
+func (u *UserService) GetWebhookDeliveries(ctx context.Context, userName string, endpointID int, status string, limit, offset int) (*entities.WebhookDeliveriesPage, error) {
+	if status != "" && !slices.Contains(entities.WebhookDeliveryStatuses, status) {
+		return nil, utils.ErrBadStatus
+	}
+	if limit < 0 || offset < 0 {
+		return nil, utils.ErrBadPage
+	}
+	if limit == 0 {
+		limit = DefaultWebhookDeliveriesLimit
+	}
+	limit = min(limit, MaxWebhookDeliveriesLimit)
+
+	page := &entities.WebhookDeliveriesPage{
+		Limit:  limit,
+		Offset: offset,
+	}
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		if _, err := u.ownWebhook(ctx, userName, endpointID); err != nil {
+			return err
+		}
+
+		var err error
+		if page.Total, err = u.UserRepo.CountWebhookDeliveries(ctx, endpointID, status); err != nil {
+			return err
+		}
+
+		page.Deliveries, err = u.UserRepo.GetWebhookDeliveries(ctx, endpointID, status, limit, offset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetWebhookDelivery returns one of an endpoint's deliveries with its
+// delivery log.
+func (u *UserService) GetWebhookDelivery(ctx context.Context, userName string, endpointID, deliveryID int) (*entities.WebhookDelivery, error) {
+	var delivery *entities.WebhookDelivery
+	err := u.Tx.WithinTx(ctx, readTx, func(ctx context.Context) error {
+		var err error
+		if delivery, err = u.ownDelivery(ctx, userName, endpointID, deliveryID); err != nil {
+			return err
+		}
+
+		delivery.Log, err = u.UserRepo.GetWebhookAttempts(ctx, deliveryID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// RedeliverWebhook puts a dead delivery back in the queue with a fresh set
+// of retries.
+func (u *UserService) RedeliverWebhook(ctx context.Context, userName string, endpointID, deliveryID int) (*entities.WebhookDelivery, error) {
+	var delivery *entities.WebhookDelivery
+	err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		var err error
+		if delivery, err = u.ownDelivery(ctx, userName, endpointID, deliveryID); err != nil {
+			return err
+		}
+		if delivery.Status != entities.WebhookDeliveryDead {
+			return utils.ErrNotDeadLetter
+		}
+
+		delivery.Status = entities.WebhookDeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = u.Now()
+		return u.UserRepo.UpdateWebhookDelivery(ctx, delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// SendTestWebhook sends a webhook.test event to one of the user's endpoints
+// right away and returns the delivery with the attempt's outcome. A failed
+// test is retried like any other delivery.
+func (u *UserService) SendTestWebhook(ctx context.Context, userName string, endpointID int) (*entities.WebhookDelivery, error) {
+	payload, err := json.Marshal(entities.WebhookTestEvent{Message: "test event from itemStore"})
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoint *entities.WebhookEndpoint
+	now := u.Now().UTC().Truncate(time.Microsecond)
+	delivery := &entities.WebhookDelivery{
+		EndpointID: endpointID,
+		EventType:  entities.WebhookTest,
+		Payload:    payload,
+		Status:     entities.WebhookDeliveryPending,
+		// Claimed from the start, so workers leave it to this call.
+		NextAttemptAt: now.Add(webhookClaimTTL),
+		CreatedAt:     now,
+	}
+	err = u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+		var err error
+		if endpoint, err = u.ownWebhook(ctx, userName, endpointID); err != nil {
+			return err
+		}
+
+		return u.UserRepo.AddWebhookDelivery(ctx, delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.attemptWebhook(ctx, endpoint, delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// DeliverWebhooks sends the webhook deliveries that are due and reports how
+// many were attempted. Each delivery is claimed in a transaction of its own
+// and sent outside of it, so replicas running this at the same time share
+// the work and no transaction waits on a slow endpoint.
+func (u *UserService) DeliverWebhooks(ctx context.Context) (int, error) {
+	sent := 0
+	for sent < maxWebhooksPerSweep {
+		var delivery *entities.WebhookDelivery
+		var endpoint *entities.WebhookEndpoint
+		err := u.Tx.WithinTx(ctx, writeTx, func(ctx context.Context) error {
+			now := u.Now()
+			var err error
+			if delivery, err = u.UserRepo.ClaimDueWebhookDelivery(ctx, now, now.Add(webhookClaimTTL)); err != nil {
+				return err
+			}
+
+			endpoint, err = u.UserRepo.GetWebhookEndpoint(ctx, delivery.EndpointID)
+			return err
+		})
+		if errors.Is(err, utils.ErrNoWebhookDelivery) {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+
+		if err := u.attemptWebhook(ctx, endpoint, delivery); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// attemptWebhook sends delivery to endpoint, adds the attempt to the
+// delivery log and schedules a retry, or gives up, if it failed.
+func (u *UserService) attemptWebhook(ctx context.Context, endpoint *entities.WebhookEndpoint, delivery *entities.WebhookDelivery) error {
+	start := u.Now()
+	statusCode, sendErr := u.WebhookSender.Send(ctx, endpoint, delivery)
+	now := u.Now()
+
+	attempt := &entities.WebhookAttempt{
+		AttemptedAt: start,
+		StatusCode:  statusCode,
+		DurationMS:  int(now.Sub(start).Milliseconds()),
+	}
+	delivery.Attempts++
+	switch {
+	case sendErr == nil:
+		delivery.Status = entities.WebhookDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= WebhookMaxAttempts:
+		attempt.Error = sendErr.Error()
+		delivery.Status = entities.WebhookDeliveryDead
+		delivery.LastError = attempt.Error
+	default:
+		attempt.Error = sendErr.Error()
+		delivery.LastError = attempt.Error
+		delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+	}
+	if sendErr != nil {
+		log.Printf("webhook delivery %d to endpoint %d: %v", delivery.ID, endpoint.ID, sendErr)
+	}
+
+	return u.Tx.WithinTx(ctx, nil, func(ctx context.Context) error {
+		if err := u.UserRepo.AddWebhookAttempt(ctx, delivery.ID, attempt); err != nil {
+			return err
+		}
+
+		return u.UserRepo.UpdateWebhookDelivery(ctx, delivery)
+	})
+}
+
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookBaseRetry
+	for i := 1; i < attempts && delay < webhookMaxRetry; i++ {
+		delay *= 2
+	}
+
+	return min(delay, webhookMaxRetry)
+}
+
+// ownWebhook returns the endpoint if userName registered it.
+func (u *UserService) ownWebhook(ctx context.Context, userName string, endpointID int) (*entities.WebhookEndpoint, error) {
+	userID, err := u.UserRepo.GetUserID(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := u.UserRepo.GetWebhookEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.UserID != userID {
+		return nil, utils.ErrForbidden
+	}
+
+	return endpoint, nil
+}
+
+// ownDelivery returns the delivery if it belongs to one of userName's
+// endpoints, endpointID.
+func (u *UserService) ownDelivery(ctx context.Context, userName string, endpointID, deliveryID int) (*entities.WebhookDelivery, error) {
+	if _, err := u.ownWebhook(ctx, userName, endpointID); err != nil {
+		return nil, err
+	}
+	delivery, err := u.UserRepo.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.EndpointID != endpointID {
+		return nil, utils.ErrNoWebhookDelivery
+	}
+
+	return delivery, nil
+}
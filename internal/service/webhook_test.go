@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeWebhookSender struct {
+	status int
+	err    error
+	sent   []int
+}
+
+func (f *fakeWebhookSender) Send(ctx context.Context, endpoint *entities.WebhookEndpoint, delivery *entities.WebhookDelivery) (int, error) {
+	f.sent = append(f.sent, delivery.ID)
+	return f.status, f.err
+}
+
+func TestCreateWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetWebhookEndpoints(gomock.Any(), 1).Return(nil, nil)
+		mockRepo.EXPECT().AddWebhookEndpoint(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, endpoint *entities.WebhookEndpoint) error {
+			endpoint.ID = 4
+			return nil
+		})
+
+		endpoint, err := userService.CreateWebhook(context.Background(), "alice", entities.WebhookEndpointRequest{
+			URL:        "https://example.com/hooks",
+			EventTypes: []string{entities.WebhookCoinReceived, entities.WebhookCoinReceived, entities.WebhookLowBalance},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, endpoint.ID)
+		assert.Equal(t, []string{entities.WebhookCoinReceived, entities.WebhookLowBalance}, endpoint.EventTypes)
+		assert.True(t, strings.HasPrefix(endpoint.Secret, "whsec_"))
+	})
+
+	t.Run("too many endpoints", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetWebhookEndpoints(gomock.Any(), 1).Return(make([]*entities.WebhookEndpoint, MaxWebhookEndpoints), nil)
+
+		_, err := userService.CreateWebhook(context.Background(), "alice", entities.WebhookEndpointRequest{
+			URL:        "https://example.com/hooks",
+			EventTypes: []string{entities.WebhookCoinReceived},
+		})
+		assert.True(t, errors.Is(err, utils.ErrTooManyWebhooks))
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		requests := []struct {
+			req  entities.WebhookEndpointRequest
+			want error
+		}{
+			{entities.WebhookEndpointRequest{URL: "ftp://example.com", EventTypes: []string{entities.WebhookCoinReceived}}, utils.ErrBadWebhookURL},
+			{entities.WebhookEndpointRequest{URL: "/hooks", EventTypes: []string{entities.WebhookCoinReceived}}, utils.ErrBadWebhookURL},
+			{entities.WebhookEndpointRequest{URL: "http://127.0.0.1:8080/hooks", EventTypes: []string{entities.WebhookCoinReceived}}, utils.ErrPrivateWebhookURL},
+			{entities.WebhookEndpointRequest{URL: "http://169.254.169.254/latest/meta-data", EventTypes: []string{entities.WebhookCoinReceived}}, utils.ErrPrivateWebhookURL},
+			{entities.WebhookEndpointRequest{URL: "http://[::1]/hooks", EventTypes: []string{entities.WebhookCoinReceived}}, utils.ErrPrivateWebhookURL},
+			{entities.WebhookEndpointRequest{URL: "http://10.0.0.5/hooks", EventTypes: []string{entities.WebhookCoinReceived}}, utils.ErrPrivateWebhookURL},
+			{entities.WebhookEndpointRequest{URL: "http://LocalHost./hooks", EventTypes: []string{entities.WebhookCoinReceived}}, utils.ErrPrivateWebhookURL},
+			{entities.WebhookEndpointRequest{URL: "https://example.com"}, utils.ErrNoEventTypes},
+			{entities.WebhookEndpointRequest{URL: "https://example.com", EventTypes: []string{"coins.lost"}}, utils.ErrBadEventType},
+		}
+		for _, r := range requests {
+			_, err := userService.CreateWebhook(context.Background(), "alice", r.req)
+			assert.True(t, errors.Is(err, r.want), "%+v: %v", r.req, err)
+		}
+	})
+}
+
+func TestWebhooksQueued(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	mockRepo.EXPECT().GetTransferLimits(gomock.Any(), gomock.Any()).Return(nil, utils.ErrNoTransferLimits).AnyTimes()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.Webhooks = NewWebhooks(mockRepo)
+
+	aliceHooks := []*entities.WebhookEndpoint{{ID: 1, UserID: 1, EventTypes: []string{entities.WebhookLowBalance}}}
+	bobHooks := []*entities.WebhookEndpoint{
+		{ID: 2, UserID: 2, EventTypes: []string{entities.WebhookCoinReceived}},
+		{ID: 3, UserID: 2, EventTypes: []string{entities.WebhookItemPurchased}},
+	}
+
+	var queued []*entities.WebhookDelivery
+	mockRepo.EXPECT().AddWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, delivery *entities.WebhookDelivery) error {
+		queued = append(queued, delivery)
+		return nil
+	}).AnyTimes()
+
+	mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+	mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+	mockRepo.EXPECT().GetWebhookEndpoints(gomock.Any(), 1).Return(aliceHooks, nil).Times(2)
+	mockRepo.EXPECT().GetWebhookEndpoints(gomock.Any(), 2).Return(bobHooks, nil)
+	gomock.InOrder(
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(110, nil),
+		mockRepo.EXPECT().SendCoin(gomock.Any(), 1, 2, 15, "", entities.TransferCategoryOther).Return(nil),
+		mockRepo.EXPECT().GetCoinsInfo(gomock.Any(), 1).Return(95, nil),
+	)
+
+	assert.NoError(t, userService.SendCoin(context.Background(), "alice", "bob", 15, "", ""))
+	if assert.Len(t, queued, 2) {
+		assert.Equal(t, 1, queued[0].EndpointID)
+		assert.Equal(t, entities.WebhookLowBalance, queued[0].EventType)
+		assert.JSONEq(t, `{"user":"alice","balance":95,"threshold":100}`, string(queued[0].Payload))
+
+		assert.Equal(t, 2, queued[1].EndpointID)
+		assert.Equal(t, entities.WebhookCoinReceived, queued[1].EventType)
+		assert.Equal(t, entities.WebhookDeliveryPending, queued[1].Status)
+		assert.Equal(t, now, queued[1].NextAttemptAt)
+	}
+}
+
+func TestItemReceivedWebhookQueued(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Webhooks = NewWebhooks(mockRepo)
+
+	var queued []*entities.WebhookDelivery
+	mockRepo.EXPECT().AddWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, delivery *entities.WebhookDelivery) error {
+		queued = append(queued, delivery)
+		return nil
+	}).AnyTimes()
+
+	mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+	mockRepo.EXPECT().GetUserID(gomock.Any(), "bob").Return(2, nil)
+	mockRepo.EXPECT().GetItemID(gomock.Any(), "cup").Return(3, nil)
+	mockRepo.EXPECT().TransferItem(gomock.Any(), 1, 2, 3, 2).Return(nil)
+	mockRepo.EXPECT().GetWebhookEndpoints(gomock.Any(), 2).Return([]*entities.WebhookEndpoint{
+		{ID: 2, UserID: 2, EventTypes: []string{entities.WebhookCoinReceived}},
+		{ID: 3, UserID: 2, EventTypes: []string{entities.WebhookItemReceived}},
+	}, nil)
+
+	assert.NoError(t, userService.TransferItem(context.Background(), "alice", "bob", "cup", 2))
+	if assert.Len(t, queued, 1) {
+		assert.Equal(t, 3, queued[0].EndpointID)
+		assert.Equal(t, entities.WebhookItemReceived, queued[0].EventType)
+		assert.JSONEq(t, `{"fromUser":"alice","toUser":"bob","item":"cup","quantity":2}`, string(queued[0].Payload))
+	}
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	sender := &fakeWebhookSender{}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	userService.WebhookSender = sender
+	endpoint := &entities.WebhookEndpoint{ID: 2, UserID: 2}
+
+	deliver := func(delivery *entities.WebhookDelivery) *entities.WebhookDelivery {
+		var updated *entities.WebhookDelivery
+		gomock.InOrder(
+			mockRepo.EXPECT().ClaimDueWebhookDelivery(gomock.Any(), now, now.Add(webhookClaimTTL)).Return(delivery, nil),
+			mockRepo.EXPECT().GetWebhookEndpoint(gomock.Any(), 2).Return(endpoint, nil),
+			mockRepo.EXPECT().AddWebhookAttempt(gomock.Any(), delivery.ID, gomock.Any()).Return(nil),
+			mockRepo.EXPECT().UpdateWebhookDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, d *entities.WebhookDelivery) error {
+				updated = d
+				return nil
+			}),
+			mockRepo.EXPECT().ClaimDueWebhookDelivery(gomock.Any(), now, now.Add(webhookClaimTTL)).Return(nil, utils.ErrNoWebhookDelivery),
+		)
+
+		sent, err := userService.DeliverWebhooks(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		return updated
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		sender.status, sender.err = 200, nil
+
+		updated := deliver(&entities.WebhookDelivery{ID: 5, EndpointID: 2, Status: entities.WebhookDeliveryPending})
+		assert.Equal(t, entities.WebhookDeliveryDelivered, updated.Status)
+		assert.Equal(t, 1, updated.Attempts)
+		assert.Equal(t, &now, updated.DeliveredAt)
+	})
+
+	t.Run("retried with backoff", func(t *testing.T) {
+		sender.status, sender.err = 503, errors.New("unexpected status 503")
+
+		updated := deliver(&entities.WebhookDelivery{ID: 6, EndpointID: 2, Status: entities.WebhookDeliveryPending, Attempts: 2})
+		assert.Equal(t, entities.WebhookDeliveryPending, updated.Status)
+		assert.Equal(t, 3, updated.Attempts)
+		assert.Equal(t, now.Add(4*webhookBaseRetry), updated.NextAttemptAt)
+		assert.Equal(t, "unexpected status 503", updated.LastError)
+	})
+
+	t.Run("dead after the last attempt", func(t *testing.T) {
+		sender.status, sender.err = 0, errors.New("connection refused")
+
+		updated := deliver(&entities.WebhookDelivery{ID: 7, EndpointID: 2, Status: entities.WebhookDeliveryPending, Attempts: WebhookMaxAttempts - 1})
+		assert.Equal(t, entities.WebhookDeliveryDead, updated.Status)
+		assert.Equal(t, WebhookMaxAttempts, updated.Attempts)
+	})
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepo(ctrl)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	userService := NewUserService(mockRepo, passthroughTx{})
+	userService.Now = func() time.Time { return now }
+	endpoint := &entities.WebhookEndpoint{ID: 2, UserID: 1}
+
+	t.Run("dead letter", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetWebhookEndpoint(gomock.Any(), 2).Return(endpoint, nil)
+		mockRepo.EXPECT().GetWebhookDelivery(gomock.Any(), 5).Return(&entities.WebhookDelivery{ID: 5, EndpointID: 2, Status: entities.WebhookDeliveryDead, Attempts: WebhookMaxAttempts}, nil)
+		mockRepo.EXPECT().UpdateWebhookDelivery(gomock.Any(), &entities.WebhookDelivery{ID: 5, EndpointID: 2, Status: entities.WebhookDeliveryPending, NextAttemptAt: now}).Return(nil)
+
+		delivery, err := userService.RedeliverWebhook(context.Background(), "alice", 2, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.WebhookDeliveryPending, delivery.Status)
+	})
+
+	t.Run("not dead", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "alice").Return(1, nil)
+		mockRepo.EXPECT().GetWebhookEndpoint(gomock.Any(), 2).Return(endpoint, nil)
+		mockRepo.EXPECT().GetWebhookDelivery(gomock.Any(), 5).Return(&entities.WebhookDelivery{ID: 5, EndpointID: 2, Status: entities.WebhookDeliveryDelivered}, nil)
+
+		_, err := userService.RedeliverWebhook(context.Background(), "alice", 2, 5)
+		assert.True(t, errors.Is(err, utils.ErrNotDeadLetter))
+	})
+
+	t.Run("someone else's endpoint", func(t *testing.T) {
+		mockRepo.EXPECT().GetUserID(gomock.Any(), "mallory").Return(3, nil)
+		mockRepo.EXPECT().GetWebhookEndpoint(gomock.Any(), 2).Return(endpoint, nil)
+
+		_, err := userService.RedeliverWebhook(context.Background(), "mallory", 2, 5)
+		assert.True(t, errors.Is(err, utils.ErrForbidden))
+	})
+}
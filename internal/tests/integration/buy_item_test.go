@@ -2,6 +2,7 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/KonstantinGalanin/itemStore/internal/handlers"
 	"github.com/KonstantinGalanin/itemStore/internal/middleware"
+	"github.com/KonstantinGalanin/itemStore/internal/migrations"
 	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
 	"github.com/KonstantinGalanin/itemStore/internal/service"
 	"github.com/KonstantinGalanin/itemStore/pkg/jwt"
@@ -36,6 +38,14 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatal(err)
 	}
 
+	list, err := migrations.Postgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db, migrations.PostgresDialect, list).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
 	db.Exec("INSERT INTO users (username, password) VALUES ($1, $2)", TestUser, "pass1234")
 	db.Exec("INSERT INTO users (username, password) VALUES ($1, $2)", Receiver, "pass1234")
 	db.Exec("INSERT INTO users (username, password) VALUES ($1, $2)", Sender, "pass1234")
@@ -86,7 +96,7 @@ func TestBuyItem(t *testing.T) {
 	defer db.Close()
 
 	repo := repository.NewUserPostgresRepo(db)
-	userService := service.NewUserService(repo)
+	userService := service.NewUserService(repo, repository.NewTxManager(db))
 	userHandler := handlers.NewUserHandler(userService, jwt.NewJwtService())
 
 	router := mux.NewRouter()
@@ -123,9 +133,9 @@ func TestBuyItem(t *testing.T) {
 	assert.Equal(t, 920, balance)
 
 	var itemID int
-	err = db.QueryRow("SELECT item_id FROM purchases WHERE user_id = (SELECT id FROM users WHERE username = $1)", TestUser).Scan(&itemID)
+	err = db.QueryRow("SELECT item_id FROM inventory WHERE user_id = (SELECT id FROM users WHERE username = $1)", TestUser).Scan(&itemID)
 	if err != nil {
-		t.Fatalf("Failed to get item from purchases: %v", err)
+		t.Fatalf("Failed to get item from inventory: %v", err)
 	}
 	assert.NotEqual(t, 0, itemID)
 }
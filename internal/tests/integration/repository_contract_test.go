@@ -0,0 +1,29 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/KonstantinGalanin/itemStore/internal/repository/repotest"
+	repository "github.com/KonstantinGalanin/itemStore/internal/repository/user"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+
+	_ "github.com/lib/pq"
+)
+
+func TestUserPostgresRepoContract(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) service.UserRepo {
+		db := setupTestDB(t)
+		t.Cleanup(func() { db.Close() })
+
+		return repository.NewUserPostgresRepo(db)
+	})
+}
+
+func TestPostgresTxManager(t *testing.T) {
+	repotest.RunTxManager(t, func(t *testing.T) (service.UserRepo, service.TxManager) {
+		db := setupTestDB(t)
+		t.Cleanup(func() { db.Close() })
+
+		return repository.NewUserPostgresRepo(db), repository.NewTxManager(db)
+	})
+}
@@ -23,7 +23,7 @@ func TestSendCoin(t *testing.T) {
 	defer db.Close()
 
 	repo := repository.NewUserPostgresRepo(db)
-	userService := service.NewUserService(repo)
+	userService := service.NewUserService(repo, repository.NewTxManager(db))
 	userHandler := handlers.NewUserHandler(userService, jwt.NewJwtService())
 
 	router := mux.NewRouter()
@@ -3,7 +3,9 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/KonstantinGalanin/itemStore/internal/entities"
 )
@@ -12,10 +14,111 @@ var (
 	ErrInvalidChars  = errors.New("contains invalid characters")
 	ErrNeedMoreChars = errors.New("must be more than 8 characters")
 	ErrNoUser            = errors.New("user not found")
+	ErrNoItem            = errors.New("item not found")
 	ErrWrongPass = errors.New("wrong email or password")
 	ErrNotEnoughBalance = errors.New("Not enough balance")
+	ErrTooManyAttempts = errors.New("too many login attempts, try again later")
+	ErrForbidden = errors.New("access denied")
+	ErrBadQuantity = errors.New("quantity must be positive")
+	ErrNotEnoughItems = errors.New("not enough items")
+	ErrNothingToRefund = errors.New("not enough refundable items")
+	ErrBadPage = errors.New("limit and offset must not be negative")
+	ErrSelfGift = errors.New("cannot send a gift to yourself")
+	ErrMessageTooLong = errors.New("message is too long")
+	ErrSelfTransfer = errors.New("cannot transfer items to yourself")
+	ErrBadPrice = errors.New("price must be positive")
+	ErrNoListing = errors.New("listing not found")
+	ErrListingClosed = errors.New("listing is no longer open")
+	ErrOwnListing = errors.New("cannot buy your own listing")
+	ErrBadAmount = errors.New("amount must be positive")
+	ErrMemoTooLong = errors.New("memo is too long")
+	ErrBadCategory = errors.New("unknown transfer category")
+	ErrBadDirection = errors.New("direction must be sent or received")
+	ErrEmptyBatch = errors.New("batch has no transfers")
+	ErrBatchTooLarge = errors.New("batch has too many transfers")
+	ErrSelfSend = errors.New("cannot send coins to yourself")
+	ErrDuplicateRecipient = errors.New("recipient appears more than once")
+	ErrNoSchedule = errors.New("scheduled transfer not found")
+	ErrScheduleClosed = errors.New("scheduled transfer is no longer active")
+	ErrBadRecurrence = errors.New("recurrence must be once, weekly or monthly")
+	ErrBadRunTime = errors.New("run time must be in the future")
+	ErrSelfRequest = errors.New("cannot request coins from yourself")
+	ErrNoPaymentRequest = errors.New("payment request not found")
+	ErrPaymentRequestClosed = errors.New("payment request is no longer pending")
+	ErrBadRequestDirection = errors.New("direction must be incoming or outgoing")
+	ErrBadStatus = errors.New("unknown status")
+	ErrTransferLimit = errors.New("transfer limit exceeded")
+	ErrBadLimit = errors.New("limits must not be negative")
+	ErrNoTransferLimits = errors.New("no transfer limits set for user")
+	ErrReasonRequired = errors.New("reason is required")
+	ErrReasonTooLong = errors.New("reason is too long")
+	ErrBadBalance = errors.New("balance must not be negative")
+	ErrNoUsers = errors.New("no users given")
+	ErrTooManyUsers = errors.New("too many users")
+	ErrNoAuditEvent = errors.New("audit event not found")
+	ErrBadExportFormat = errors.New("format must be csv or jsonl")
+	ErrBadTimeRange = errors.New("since must be before until")
+	ErrNoWebhook = errors.New("webhook endpoint not found")
+	ErrNoWebhookDelivery = errors.New("webhook delivery not found")
+	ErrBadWebhookURL = errors.New("webhook url must be an absolute http or https url")
+	ErrPrivateWebhookURL = errors.New("webhook url must point at a public address")
+	ErrBadEventType = errors.New("unknown event type")
+	ErrNoEventTypes = errors.New("at least one event type is required")
+	ErrTooManyWebhooks = errors.New("too many webhook endpoints")
+	ErrNotDeadLetter = errors.New("only dead deliveries can be redelivered")
+	ErrBadEventID = errors.New("last event id must be a non-negative integer")
+	ErrNoNotification = errors.New("notification not found")
+	ErrBadNotificationKind = errors.New("unknown notification kind")
+	ErrBadEmail = errors.New("invalid email address")
+	ErrBadEmailMode = errors.New("email mode must be off, instant or digest")
+	ErrNoEmailSettings = errors.New("email settings not found")
+	ErrNoEmail = errors.New("email not found")
+	ErrBadRole = errors.New("role must be user, admin or auditor")
+	ErrBadItemName = errors.New("item name must be 1 to 64 letters, digits, dashes or underscores")
 )
 
+// LoginBlockedError is returned while logins for a username are throttled.
+// It unwraps to ErrTooManyAttempts and carries the time left until the next
+// attempt is allowed.
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// TransferLimitError is returned when a coin transfer would break one of the
+// sender's limits. It unwraps to ErrTransferLimit and names the limit, one
+// of the entities.Limit* constants, and its value.
+type TransferLimitError struct {
+	Limit string
+	Max   int
+}
+
+func (e *TransferLimitError) Error() string {
+	switch e.Limit {
+	case entities.LimitMaxTransfer:
+		return fmt.Sprintf("transfers are limited to %d coins each", e.Max)
+	case entities.LimitDailyAmount:
+		return fmt.Sprintf("daily transfer limit of %d coins exceeded", e.Max)
+	case entities.LimitWeeklyAmount:
+		return fmt.Sprintf("weekly transfer limit of %d coins exceeded", e.Max)
+	case entities.LimitDailyRecipients:
+		return fmt.Sprintf("daily limit of %d recipients exceeded", e.Max)
+	}
+
+	return ErrTransferLimit.Error()
+}
+
+func (e *TransferLimitError) Unwrap() error {
+	return ErrTransferLimit
+}
+
 func WriteErrorResponse(w http.ResponseWriter, err error, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,191 @@
+// Package webhook signs and sends the webhooks users register for.
+//
+// Every request carries the event in a JSON envelope and a signature header
+// of the form "t=<unix time>,v1=<hex HMAC-SHA256>", where the HMAC is taken
+// with the endpoint's secret over "<unix time>.<body>". Receivers should
+// check the signature with Verify and reject old timestamps to stop replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+)
+
+const (
+	SignatureHeader = "X-Itemstore-Signature"
+	EventHeader     = "X-Itemstore-Event"
+	DeliveryHeader  = "X-Itemstore-Delivery"
+)
+
+var (
+	ErrBadSignature   = errors.New("webhook signature does not match")
+	ErrStaleRequest   = errors.New("webhook timestamp is too old")
+	ErrPrivateAddress = errors.New("webhook address is not public")
+)
+
+// Envelope is the body of every webhook request. ID is the delivery id and
+// stays the same across retries, so receivers can drop duplicates.
+type Envelope struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + mac(secret, timestamp, body)
+}
+
+// Verify checks a signature header made by Sign. Requests signed more than
+// tolerance before now are rejected; a zero tolerance accepts any age.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(mac(secret, timestamp, body))) {
+		return ErrBadSignature
+	}
+	if tolerance > 0 && now.Sub(time.Unix(unix, 0)) > tolerance {
+		return ErrStaleRequest
+	}
+
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sender POSTs signed deliveries over HTTP.
+type Sender struct {
+	Client *http.Client
+	Now    func() time.Time
+}
+
+// NewSender returns a sender that only connects to public addresses, so
+// endpoints cannot be used to reach the server's own network.
+func NewSender() *Sender {
+	return &Sender{
+		Client: newClient(func(addr netip.AddrPort) bool { return PublicIP(addr.Addr()) }),
+		Now:    time.Now,
+	}
+}
+
+// PublicIP reports whether ip may receive webhooks. Loopback, private,
+// link-local, multicast and unspecified addresses may not.
+func PublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// newClient returns a client that only dials addresses allow accepts. The
+// check runs on the resolved address at connect time, so a hostname that
+// resolves differently later cannot get around it. Redirects are not
+// followed: a public endpoint could otherwise send the request inward.
+func newClient(allow func(netip.AddrPort) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allow(addr) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed in the endpoint's place and defeat the check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Send delivers delivery to endpoint and returns the response status, or
+// zero if there was no response. Any status other than 2xx is an error.
+func (s *Sender) Send(ctx context.Context, endpoint *entities.WebhookEndpoint, delivery *entities.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(Envelope{
+		ID:        delivery.ID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("encode webhook: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "itemStore-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, s.Now(), body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
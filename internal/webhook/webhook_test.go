@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":1}`)
+	header := Sign("whsec_test", now, body)
+
+	assert.NoError(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)))
+	assert.ErrorIs(t, Verify("whsec_other", header, body, 5*time.Minute, now), ErrBadSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, []byte(`{"id":2}`), 5*time.Minute, now), ErrBadSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Hour)), ErrStaleRequest)
+	assert.ErrorIs(t, Verify("whsec_test", "garbage", body, 0, now), ErrBadSignature)
+}
+
+func TestSender(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	endpoint := &entities.WebhookEndpoint{ID: 1, Secret: "whsec_test"}
+	delivery := &entities.WebhookDelivery{
+		ID:        9,
+		EventType: entities.WebhookCoinReceived,
+		Payload:   json.RawMessage(`{"fromUser":"alice","toUser":"bob","amount":15,"category":"other"}`),
+		CreatedAt: now,
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		var got *http.Request
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer server.Close()
+
+		sender := loopbackSender()
+		sender.Now = func() time.Time { return now }
+		endpoint.URL = server.URL
+
+		status, err := sender.Send(context.Background(), endpoint, delivery)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, entities.WebhookCoinReceived, got.Header.Get(EventHeader))
+		assert.Equal(t, "9", got.Header.Get(DeliveryHeader))
+		assert.NoError(t, Verify(endpoint.Secret, got.Header.Get(SignatureHeader), body, time.Minute, now))
+
+		var envelope Envelope
+		assert.NoError(t, json.Unmarshal(body, &envelope))
+		assert.Equal(t, 9, envelope.ID)
+		assert.JSONEq(t, string(delivery.Payload), string(envelope.Data))
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGone)
+		}))
+		defer server.Close()
+
+		endpoint.URL = server.URL
+		status, err := loopbackSender().Send(context.Background(), endpoint, delivery)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusGone, status)
+	})
+
+	t.Run("loopback refused", func(t *testing.T) {
+		called := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer server.Close()
+
+		endpoint.URL = server.URL
+		status, err := NewSender().Send(context.Background(), endpoint, delivery)
+		assert.ErrorIs(t, err, ErrPrivateAddress)
+		assert.Zero(t, status)
+		assert.False(t, called)
+	})
+
+	t.Run("redirect to loopback not followed", func(t *testing.T) {
+		called := false
+		internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer internal.Close()
+		public := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusTemporaryRedirect))
+		defer public.Close()
+
+		// Only the redirecting server counts as public here.
+		publicAddr := netip.MustParseAddrPort(public.Listener.Addr().String())
+		sender := &Sender{
+			Client: newClient(func(addr netip.AddrPort) bool { return addr == publicAddr }),
+			Now:    time.Now,
+		}
+
+		endpoint.URL = public.URL
+		status, err := sender.Send(context.Background(), endpoint, delivery)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusTemporaryRedirect, status)
+		assert.False(t, called)
+	})
+}
+
+// loopbackSender is NewSender's client with the address check lifted, for
+// the test servers on 127.0.0.1.
+func loopbackSender() *Sender {
+	return &Sender{
+		Client: newClient(func(netip.AddrPort) bool { return true }),
+		Now:    time.Now,
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"224.0.0.1":            false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	} {
+		assert.Equal(t, public, PublicIP(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,256 @@
+// Package websocket is the server side of the WebSocket protocol (RFC 6455),
+// as much of it as a server-to-client stream needs: the opening handshake,
+// sending text and ping frames, and answering the client's control frames.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xa
+
+	CloseNormal        = 1000
+	CloseProtocolError = 1002
+	CloseTooLarge      = 1009
+
+	// acceptGUID is mixed into Sec-WebSocket-Accept (RFC 6455, 1.3).
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// maxFrameSize bounds the frames read from the client, which only
+	// needs to send control frames.
+	maxFrameSize = 4096
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	ErrBadHandshake  = errors.New("websocket: bad handshake")
+	ErrProtocol      = errors.New("websocket: protocol error")
+	ErrFrameTooLarge = errors.New("websocket: frame too large")
+)
+
+// IsUpgrade reports whether r asks to switch to the WebSocket protocol.
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Conn is a server-side WebSocket connection. Its write methods may be
+// called while ReadLoop runs in another goroutine.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	mu   sync.Mutex
+}
+
+// Upgrade completes the handshake for r and takes over the connection. On
+// failure it has already written the error response. Headers set on w
+// before the call are sent with the handshake response.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, ErrBadHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrBadHandshake
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		http.Error(w, "bad Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, ErrBadHandshake
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("%w: response cannot be hijacked", ErrBadHandshake)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket hijack: %w", err)
+	}
+
+	header := w.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", acceptKey(key))
+	header.Del("Content-Type")
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := io.WriteString(rw, "HTTP/1.1 101 Switching Protocols\r\n"); err == nil {
+		if err = header.Write(rw); err == nil {
+			_, err = io.WriteString(rw, "\r\n")
+		}
+	}
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake: %w", err)
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	return &Conn{
+		conn: conn,
+		br:   rw.Reader,
+	}, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// WriteText sends data as one text message.
+func (c *Conn) WriteText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Ping sends a ping, which the client answers with a pong. It keeps
+// proxies from timing an idle connection out.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close sends a close frame with code and reason and closes the connection.
+func (c *Conn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	c.writeFrame(opClose, append(payload, reason...))
+
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = binary.BigEndian.AppendUint16(append(header, 126), uint16(n))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 127), uint64(n))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
+
+// ReadLoop reads from the client until the connection ends, answering
+// pings and the closing handshake and discarding data messages. It returns
+// nil when the client closed the connection cleanly.
+func (c *Conn) ReadLoop() error {
+	for {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrFrameTooLarge):
+				c.Close(CloseTooLarge, "")
+			case errors.Is(err, ErrProtocol):
+				c.Close(CloseProtocolError, "")
+			default:
+				c.conn.Close()
+			}
+			return err
+		}
+
+		switch op {
+		case opClose:
+			if len(payload) >= 2 {
+				payload = payload[:2]
+			}
+			c.writeFrame(opClose, payload)
+			c.conn.Close()
+			return nil
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				c.conn.Close()
+				return err
+			}
+		}
+	}
+}
+
+func (c *Conn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	op := header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return 0, nil, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+	if header[1]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("%w: unmasked client frame", ErrProtocol)
+	}
+
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= opClose && (n > 125 || !fin) {
+		return 0, nil, fmt.Errorf("%w: bad control frame", ErrProtocol)
+	}
+	if n > maxFrameSize {
+		return 0, nil, ErrFrameTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return op, payload, nil
+}
@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dial opens a WebSocket connection to server the way a browser would.
+func dial(t *testing.T, server *httptest.Server) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	require.NoError(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+
+	return conn, br, resp
+}
+
+// writeClientFrame sends a masked frame, as clients must.
+func writeClientFrame(t *testing.T, conn net.Conn, op byte, payload []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := conn.Write(frame)
+	require.NoError(t, err)
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	var header [2]byte
+	_, err := io.ReadFull(br, header[:])
+	require.NoError(t, err)
+	assert.Zero(t, header[1]&0x80, "server frames are not masked")
+
+	n := int(header[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		_, err := io.ReadFull(br, ext[:])
+		require.NoError(t, err)
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	_, err = io.ReadFull(br, payload)
+	require.NoError(t, err)
+
+	return header[0] & 0x0f, payload
+}
+
+func TestConn(t *testing.T) {
+	done := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "abc")
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			done <- err
+			return
+		}
+		conn.WriteText([]byte("hello"))
+		conn.WriteText([]byte(strings.Repeat("x", 300)))
+		done <- conn.ReadLoop()
+	}))
+	defer server.Close()
+
+	conn, br, resp := dial(t, server)
+	defer conn.Close()
+
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+	assert.Equal(t, "abc", resp.Header.Get("X-Request-ID"))
+
+	op, payload := readServerFrame(t, br)
+	assert.Equal(t, byte(opText), op)
+	assert.Equal(t, "hello", string(payload))
+
+	op, payload = readServerFrame(t, br)
+	assert.Equal(t, byte(opText), op)
+	assert.Len(t, payload, 300)
+
+	writeClientFrame(t, conn, opPing, []byte("ping"))
+	op, payload = readServerFrame(t, br)
+	assert.Equal(t, byte(opPong), op)
+	assert.Equal(t, "ping", string(payload))
+
+	writeClientFrame(t, conn, opText, []byte("ignored"))
+
+	writeClientFrame(t, conn, opClose, binary.BigEndian.AppendUint16(nil, CloseNormal))
+	op, payload = readServerFrame(t, br)
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, uint16(CloseNormal), binary.BigEndian.Uint16(payload))
+
+	assert.NoError(t, <-done)
+}
+
+func TestConnUnmaskedFrame(t *testing.T) {
+	done := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- conn.ReadLoop()
+	}))
+	defer server.Close()
+
+	conn, br, _ := dial(t, server)
+	defer conn.Close()
+
+	_, err := conn.Write([]byte{0x80 | opText, 2, 'h', 'i'})
+	require.NoError(t, err)
+
+	op, payload := readServerFrame(t, br)
+	assert.Equal(t, byte(opClose), op)
+	assert.Equal(t, uint16(CloseProtocolError), binary.BigEndian.Uint16(payload))
+	assert.ErrorIs(t, <-done, ErrProtocol)
+}
+
+func TestUpgradeRejected(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, err := Upgrade(w, r)
+		assert.ErrorIs(t, err, ErrBadHandshake)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	w = httptest.NewRecorder()
+	handler(w, req)
+	assert.Equal(t, http.StatusUpgradeRequired, w.Code)
+	assert.Equal(t, "13", w.Header().Get("Sec-WebSocket-Version"))
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// GrantCoins gives amount coins to each of userNames; the grant succeeds
+// or fails as a whole. It needs the admin role.
+func (c *Client) GrantCoins(ctx context.Context, userNames []string, amount int, reason string) ([]*BalanceAdjustment, error) {
+	body := struct {
+		Users  []string `json:"users"`
+		Amount int      `json:"amount"`
+		Reason string   `json:"reason"`
+	}{userNames, amount, reason}
+
+	var adjustments []*BalanceAdjustment
+	if err := c.call(ctx, http.MethodPost, "/api/admin/coins/grant", nil, body, &adjustments); err != nil {
+		return nil, err
+	}
+
+	return adjustments, nil
+}
+
+// ClawbackCoins takes amount coins back from a user. It needs the admin
+// role.
+func (c *Client) ClawbackCoins(ctx context.Context, userName string, amount int, reason string) (*BalanceAdjustment, error) {
+	body := struct {
+		Amount int    `json:"amount"`
+		Reason string `json:"reason"`
+	}{amount, reason}
+
+	var adjustment BalanceAdjustment
+	if err := c.call(ctx, http.MethodPost, pathf("/api/admin/users/%s/clawback", userName), nil, body, &adjustment); err != nil {
+		return nil, err
+	}
+
+	return &adjustment, nil
+}
+
+// SetBalance sets a user's balance. It needs the admin role.
+func (c *Client) SetBalance(ctx context.Context, userName string, balance int, reason string) (*BalanceAdjustment, error) {
+	body := struct {
+		Balance int    `json:"balance"`
+		Reason  string `json:"reason"`
+	}{balance, reason}
+
+	var adjustment BalanceAdjustment
+	if err := c.call(ctx, http.MethodPut, pathf("/api/admin/users/%s/balance", userName), nil, body, &adjustment); err != nil {
+		return nil, err
+	}
+
+	return &adjustment, nil
+}
+
+// GetBalanceAdjustments lists balance adjustments, newest first, only
+// those of userName if it is not empty. It needs the admin role.
+func (c *Client) GetBalanceAdjustments(ctx context.Context, userName string, limit, offset int) (*BalanceAdjustmentsPage, error) {
+	query := url.Values{}
+	if userName != "" {
+		query.Set("user", userName)
+	}
+
+	var page BalanceAdjustmentsPage
+	if err := c.call(ctx, http.MethodGet, "/api/admin/adjustments", pageQuery(query, limit, offset), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// The calls in this file need the admin role.
+
+// UnlockUser lifts a login lockout.
+func (c *Client) UnlockUser(ctx context.Context, userName string) error {
+	return c.call(ctx, http.MethodPost, pathf("/api/admin/users/%s/unlock", userName), nil, nil, nil)
+}
+
+// ForceRefund refunds quantity units of a user's item regardless of the
+// refund window.
+func (c *Client) ForceRefund(ctx context.Context, userName, itemName string, quantity int) (*RefundOperation, error) {
+	body := struct {
+		Quantity int `json:"quantity"`
+	}{quantity}
+
+	var refund RefundOperation
+	if err := c.call(ctx, http.MethodPost, pathf("/api/admin/users/%s/refund/%s", userName, itemName), nil, body, &refund); err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// GetTransferLimits returns the transfer limits applied to a user.
+func (c *Client) GetTransferLimits(ctx context.Context, userName string) (*UserTransferLimits, error) {
+	var limits UserTransferLimits
+	if err := c.call(ctx, http.MethodGet, pathf("/api/admin/users/%s/limits", userName), nil, nil, &limits); err != nil {
+		return nil, err
+	}
+
+	return &limits, nil
+}
+
+// SetTransferLimits replaces a user's transfer limits.
+func (c *Client) SetTransferLimits(ctx context.Context, userName string, limits TransferLimits) (*UserTransferLimits, error) {
+	var result UserTransferLimits
+	if err := c.call(ctx, http.MethodPut, pathf("/api/admin/users/%s/limits", userName), nil, limits, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ClearTransferLimits removes a user's override so the configured transfer
+// limits apply again.
+func (c *Client) ClearTransferLimits(ctx context.Context, userName string) error {
+	return c.call(ctx, http.MethodDelete, pathf("/api/admin/users/%s/limits", userName), nil, nil, nil)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// GetAuditEvents lists audit events matching filter in log order, starting
+// after the event with id after. It needs the auditor role.
+func (c *Client) GetAuditEvents(ctx context.Context, filter AuditFilter, after, limit int) (*AuditEventsPage, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"action": filter.Action,
+		"actor":  filter.Actor,
+		"target": filter.Target,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	setQuery(query, "after", after)
+	setQuery(query, "limit", limit)
+
+	var page AuditEventsPage
+	if err := c.call(ctx, http.MethodGet, "/api/audit/events", query, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// VerifyAuditLog checks the audit log's hash chain. It needs the auditor
+// role.
+func (c *Client) VerifyAuditLog(ctx context.Context) (*AuditVerification, error) {
+	var verification AuditVerification
+	if err := c.call(ctx, http.MethodGet, "/api/audit/verify", nil, nil, &verification); err != nil {
+		return nil, err
+	}
+
+	return &verification, nil
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// GetCatalog lists the items on sale and their prices.
+func (c *Client) GetCatalog(ctx context.Context) ([]*CatalogItem, error) {
+	var catalog []*CatalogItem
+	if err := c.call(ctx, http.MethodGet, "/api/items", nil, nil, &catalog); err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+// SetItemPrice sets an item's price, putting it on sale if the shop does not
+// sell it yet. It needs the admin role.
+func (c *Client) SetItemPrice(ctx context.Context, itemName string, price int) (*CatalogItem, error) {
+	body := struct {
+		Price int `json:"price"`
+	}{price}
+
+	var item CatalogItem
+	if err := c.call(ctx, http.MethodPut, pathf("/api/admin/items/%s", itemName), nil, body, &item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
@@ -0,0 +1,334 @@
+// Package client is a typed Go client for the itemStore HTTP API.
+//
+// Methods mirror the server's services without the username, which the
+// server takes from the token:
+//
+//	c := client.New("http://localhost:8080")
+//	if _, err := c.Auth(ctx, "alice", "password1"); err != nil {
+//		return err
+//	}
+//	err := c.SendCoin(ctx, "bob", 10, "lunch", "")
+//	if errors.Is(err, client.ErrNotEnoughBalance) {
+//		...
+//	}
+//
+// After Auth the client logs in again by itself when its token is about
+// to expire or the server rejects it. Idempotent calls (GET, PUT and
+// DELETE) are retried with exponential backoff when the network or the
+// server fails; other calls are never retried, so a coin transfer cannot
+// run twice.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	jwtToken "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultBackoff    = 200 * time.Millisecond
+
+	// refreshBefore is how long before it expires a token is replaced.
+	refreshBefore = time.Minute
+)
+
+// Client calls one itemStore server. It is safe for concurrent use.
+type Client struct {
+	// BaseURL is the server's address, such as "http://localhost:8080".
+	BaseURL    string
+	HTTPClient *http.Client
+	// MaxRetries is how many times a failed idempotent call is retried,
+	// waiting Backoff before the first retry and twice as long before
+	// each one after it.
+	MaxRetries int
+	Backoff    time.Duration
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	username  string
+	password  string
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+// Auth logs in and uses the token for later calls. The credentials are
+// kept so the client can log in again when the token runs out.
+func (c *Client) Auth(ctx context.Context, username, password string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.login(ctx, username, password); err != nil {
+		return "", err
+	}
+	c.username, c.password = username, password
+
+	return c.token, nil
+}
+
+// SetToken makes the client use a token obtained earlier, such as one a
+// command-line tool cached. Without credentials from Auth, an expired
+// token is not replaced and calls fail with ErrUnauthorized.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setToken(token)
+}
+
+// Token returns the token the client currently uses.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.token
+}
+
+// login gets a new token. c.mu must be held.
+func (c *Client) login(ctx context.Context, username, password string) error {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	body := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{username, password}
+	if err := c.do(ctx, http.MethodPost, "/api/auth", nil, body, &resp, ""); err != nil {
+		return err
+	}
+
+	c.setToken(resp.Token)
+	return nil
+}
+
+// setToken stores token and when it expires. c.mu must be held.
+func (c *Client) setToken(token string) {
+	c.token = token
+	c.expiresAt = time.Time{}
+
+	// The signature is the server's business; only the expiry is read.
+	var claims jwtToken.RegisteredClaims
+	if _, _, err := jwtToken.NewParser().ParseUnverified(token, &claims); err == nil && claims.ExpiresAt != nil {
+		c.expiresAt = claims.ExpiresAt.Time
+	}
+}
+
+// currentToken returns a token to send, logging in again first if the
+// current one is about to expire.
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.username != "" && !c.expiresAt.IsZero() && time.Until(c.expiresAt) < refreshBefore {
+		if err := c.login(ctx, c.username, c.password); err != nil {
+			return "", err
+		}
+	}
+
+	return c.token, nil
+}
+
+// refreshToken logs in again after the server rejected stale. It reports
+// false if the client has no credentials to do so.
+func (c *Client) refreshToken(ctx context.Context, stale string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.username == "" {
+		return "", false, nil
+	}
+	// Another call may have logged in already.
+	if c.token != stale {
+		return c.token, true, nil
+	}
+	if err := c.login(ctx, c.username, c.password); err != nil {
+		return "", true, err
+	}
+
+	return c.token, true, nil
+}
+
+// call sends an authenticated request with body, if any, encoded as JSON
+// and decodes the response into out, if given.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.do(ctx, method, path, query, body, out, token)
+	if !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+
+	token, refreshed, refreshErr := c.refreshToken(ctx, token)
+	if refreshErr != nil {
+		return refreshErr
+	}
+	if !refreshed {
+		return err
+	}
+
+	return c.do(ctx, method, path, query, body, out, token)
+}
+
+// do sends a request, retrying it if the method is idempotent.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any, token string) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	retries := 0
+	if method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete {
+		retries = c.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, target, payload, out, token)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+
+		wait := c.Backoff << attempt
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte, out any, token string) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// responseError turns an error response into an *Error.
+func responseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var errResp entities.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Errors != "" {
+		apiErr.Message = errResp.Errors
+	} else if text := strings.TrimSpace(string(body)); text != "" {
+		apiErr.Message = text
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return apiErr
+}
+
+// retryable reports whether a failed call may succeed if sent again.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		// The request never got an answer.
+		return true
+	}
+
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+}
+
+// pageQuery adds limit and offset to query, leaving out zeros so the
+// server's defaults apply.
+func pageQuery(query url.Values, limit, offset int) url.Values {
+	if query == nil {
+		query = url.Values{}
+	}
+	setQuery(query, "limit", limit)
+	setQuery(query, "offset", offset)
+
+	return query
+}
+
+func setQuery(query url.Values, key string, value int) {
+	if value != 0 {
+		query.Set(key, strconv.Itoa(value))
+	}
+}
+
+// pathf formats a path, escaping each argument as a path segment.
+func pathf(format string, args ...any) string {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = url.PathEscape(s)
+		}
+	}
+
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/entities"
+	"github.com/KonstantinGalanin/itemStore/internal/handlers"
+	"github.com/KonstantinGalanin/itemStore/internal/router"
+	"github.com/KonstantinGalanin/itemStore/internal/service"
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+	"github.com/KonstantinGalanin/itemStore/pkg/jwt"
+	jwtToken "github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient starts the real router over mock services and returns a
+// client for it.
+func newTestClient(t *testing.T) (*Client, *service.MockUserService, *service.MockAdminService) {
+	ctrl := gomock.NewController(t)
+	userService := service.NewMockUserService(ctrl)
+	adminService := service.NewMockAdminService(ctrl)
+
+	server := httptest.NewServer(router.NewRouter(
+		handlers.NewUserHandler(userService, jwt.NewJwtService()),
+		handlers.NewAdminHandler(adminService),
+		handlers.NewAuditHandler(service.NewMockAuditService(ctrl)),
+	))
+	t.Cleanup(server.Close)
+
+	c := New(server.URL)
+	c.Backoff = time.Millisecond
+
+	return c, userService, adminService
+}
+
+// login authenticates c as username with the given role.
+func login(t *testing.T, c *Client, userService *service.MockUserService, username, role string) {
+	userService.EXPECT().Auth(gomock.Any(), username, "password1").
+		Return(&entities.User{Username: username, Role: role}, nil)
+
+	token, err := c.Auth(context.Background(), username, "password1")
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+}
+
+// tokenExpiring signs a token for username that expires after d.
+func tokenExpiring(t *testing.T, username string, d time.Duration) string {
+	token, err := jwtToken.NewWithClaims(jwtToken.SigningMethodHS256, jwt.JWTInfo{
+		Username: username,
+		RegisteredClaims: jwtToken.RegisteredClaims{
+			ExpiresAt: jwtToken.NewNumericDate(time.Now().Add(d)),
+		},
+	}).SignedString(jwt.TokenSecret)
+	require.NoError(t, err)
+
+	return token
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("calls", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		login(t, c, userService, "alice", entities.RoleUser)
+
+		userService.EXPECT().GetInfo(gomock.Any(), "alice").
+			Return(&entities.InfoResponse{Coins: 990, Inventory: []*entities.Item{{ItemType: "cup", Quantity: 1}}}, nil)
+		info, err := c.GetInfo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 990, info.Coins)
+		assert.Equal(t, "cup", info.Inventory[0].ItemType)
+
+		userService.EXPECT().SendCoin(gomock.Any(), "alice", "bob", 10, "lunch", "").Return(nil)
+		require.NoError(t, c.SendCoin(ctx, "bob", 10, "lunch", ""))
+
+		userService.EXPECT().BuyItem(gomock.Any(), "alice", "pink-hoody").Return(nil)
+		require.NoError(t, c.BuyItem(ctx, "pink-hoody"))
+
+		userService.EXPECT().GiftItem(gomock.Any(), "alice", "bob", "cup", "enjoy").Return(nil)
+		require.NoError(t, c.GiftItem(ctx, "bob", "cup", "enjoy"))
+
+		userService.EXPECT().SearchHistory(gomock.Any(), "alice", entities.TransferFilter{Direction: "sent", Query: "lunch"}, 5, 10).
+			Return(&entities.TransfersPage{Total: 1, Limit: 5, Offset: 10}, nil)
+		page, err := c.SearchHistory(ctx, TransferFilter{Direction: "sent", Query: "lunch"}, 5, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+
+		userService.EXPECT().CancelListing(gomock.Any(), "alice", 7).Return(nil)
+		require.NoError(t, c.CancelListing(ctx, 7))
+
+		userService.EXPECT().GetCatalog(gomock.Any()).Return([]*entities.CatalogItem{{Name: "cup", Price: 20}}, nil)
+		catalog, err := c.GetCatalog(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*CatalogItem{{Name: "cup", Price: 20}}, catalog)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		login(t, c, userService, "alice", entities.RoleUser)
+
+		userService.EXPECT().SendCoin(gomock.Any(), "alice", "bob", 5000, "", "").
+			Return(&utils.TransferLimitError{Limit: entities.LimitMaxTransfer, Max: 1000})
+		err := c.SendCoin(ctx, "bob", 5000, "", "")
+		assert.ErrorIs(t, err, ErrTransferLimit)
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 422, apiErr.StatusCode)
+		assert.Equal(t, "transfers are limited to 1000 coins each", apiErr.Message)
+
+		userService.EXPECT().BuyItem(gomock.Any(), "alice", "cup").
+			Return(fmt.Errorf("buy cup: %w", utils.ErrNotEnoughBalance))
+		err = c.BuyItem(ctx, "cup")
+		assert.ErrorIs(t, err, ErrNotEnoughBalance)
+		assert.NotErrorIs(t, err, ErrNoItem)
+
+		userService.EXPECT().CancelListing(gomock.Any(), "alice", 7).
+			Return(fmt.Errorf("get listing error: %w: %w", utils.ErrNoListing, sql.ErrNoRows))
+		err = c.CancelListing(ctx, 7)
+		assert.ErrorIs(t, err, ErrNoListing)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// The server checks requests against its OpenAPI document.
+		_, err = c.GetOrders(ctx, "", -1, 0)
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 400, apiErr.StatusCode)
+		assert.Equal(t, "query parameter limit must be at least 0", apiErr.Message)
+
+		_, err = c.GrantCoins(ctx, []string{"bob"}, 100, "bonus")
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("login throttled", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		userService.EXPECT().Auth(gomock.Any(), "alice", "password1").
+			Return(nil, &utils.LoginBlockedError{RetryAfter: 30 * time.Second})
+
+		_, err := c.Auth(ctx, "alice", "password1")
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 30*time.Second, apiErr.RetryAfter)
+	})
+
+	t.Run("reads are retried", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		login(t, c, userService, "alice", entities.RoleUser)
+
+		gomock.InOrder(
+			userService.EXPECT().GetInfo(gomock.Any(), "alice").Return(nil, errors.New("connection reset")).Times(2),
+			userService.EXPECT().GetInfo(gomock.Any(), "alice").Return(&entities.InfoResponse{Coins: 1000}, nil),
+		)
+		info, err := c.GetInfo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1000, info.Coins)
+	})
+
+	t.Run("retries run out", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		login(t, c, userService, "alice", entities.RoleUser)
+		c.MaxRetries = 2
+
+		userService.EXPECT().GetInfo(gomock.Any(), "alice").Return(nil, errors.New("connection reset")).Times(3)
+		_, err := c.GetInfo(ctx)
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 500, apiErr.StatusCode)
+	})
+
+	t.Run("transfers are not retried", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		login(t, c, userService, "alice", entities.RoleUser)
+
+		userService.EXPECT().SendCoin(gomock.Any(), "alice", "bob", 10, "", "").Return(errors.New("connection reset")).Times(1)
+		assert.Error(t, c.SendCoin(ctx, "bob", 10, "", ""))
+	})
+
+	t.Run("rejected token is replaced", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		login(t, c, userService, "alice", entities.RoleUser)
+		c.SetToken("not-a-token")
+
+		userService.EXPECT().Auth(gomock.Any(), "alice", "password1").
+			Return(&entities.User{Username: "alice", Role: entities.RoleUser}, nil)
+		userService.EXPECT().SendCoin(gomock.Any(), "alice", "bob", 10, "", "").Return(nil)
+
+		require.NoError(t, c.SendCoin(ctx, "bob", 10, "", ""))
+		assert.NotEqual(t, "not-a-token", c.Token())
+	})
+
+	t.Run("expiring token is replaced", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		login(t, c, userService, "alice", entities.RoleUser)
+		expiring := tokenExpiring(t, "alice", 10*time.Second)
+		c.SetToken(expiring)
+
+		userService.EXPECT().Auth(gomock.Any(), "alice", "password1").
+			Return(&entities.User{Username: "alice", Role: entities.RoleUser}, nil)
+		userService.EXPECT().GetInfo(gomock.Any(), "alice").Return(&entities.InfoResponse{}, nil)
+
+		_, err := c.GetInfo(ctx)
+		require.NoError(t, err)
+		assert.NotEqual(t, expiring, c.Token())
+	})
+
+	t.Run("token without credentials", func(t *testing.T) {
+		c, userService, _ := newTestClient(t)
+		c.SetToken(tokenExpiring(t, "alice", time.Hour))
+
+		userService.EXPECT().GetInfo(gomock.Any(), "alice").Return(&entities.InfoResponse{Coins: 7}, nil)
+		info, err := c.GetInfo(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 7, info.Coins)
+
+		c.SetToken(tokenExpiring(t, "alice", -time.Minute))
+		_, err = c.GetInfo(ctx)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		c, userService, adminService := newTestClient(t)
+		login(t, c, userService, "root", entities.RoleAdmin)
+
+		adminService.EXPECT().GrantCoins(gomock.Any(), "root", []string{"alice", "bob"}, 100, "bonus").
+			Return([]*entities.BalanceAdjustment{{ID: 1, Username: "alice", Amount: 100}, {ID: 2, Username: "bob", Amount: 100}}, nil)
+		adjustments, err := c.GrantCoins(ctx, []string{"alice", "bob"}, 100, "bonus")
+		require.NoError(t, err)
+		assert.Len(t, adjustments, 2)
+
+		adminService.EXPECT().GetTransferLimits(gomock.Any(), "alice smith").Return(&entities.UserTransferLimits{Username: "alice smith"}, nil)
+		limits, err := c.GetTransferLimits(ctx, "alice smith")
+		require.NoError(t, err)
+		assert.Equal(t, "alice smith", limits.Username)
+
+		adminService.EXPECT().SetItemPrice(gomock.Any(), "root", "sticker", 15).Return(&entities.CatalogItem{Name: "sticker", Price: 15}, nil)
+		item, err := c.SetItemPrice(ctx, "sticker", 15)
+		require.NoError(t, err)
+		assert.Equal(t, 15, item.Price)
+
+		adminService.EXPECT().SetItemPrice(gomock.Any(), "root", "sticker", 15).Return(nil, utils.ErrBadItemName)
+		_, err = c.SetItemPrice(ctx, "sticker", 15)
+		assert.ErrorIs(t, err, ErrBadItemName)
+	})
+}
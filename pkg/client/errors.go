@@ -0,0 +1,117 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KonstantinGalanin/itemStore/internal/utils"
+)
+
+// Error is an error response from the server. Compare it with the Err
+// values below using errors.Is.
+type Error struct {
+	StatusCode int
+	// Message is what the server said went wrong.
+	Message string
+	// RetryAfter is how long the server asked the client to wait, if it
+	// did.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("itemstore: %s (%d)", e.Message, e.StatusCode)
+}
+
+// Is reports whether the server answered with target. ErrUnauthorized and
+// ErrNotFound match on the status code alone; the server's own errors
+// match on their message, which may be wrapped in some context, as in
+// "get item id error: item not found: sql: no rows in result set".
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrTooManyAttempts:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrTransferLimit:
+		// The message names the limit that was hit.
+		return e.StatusCode == http.StatusUnprocessableEntity
+	}
+
+	return strings.Contains(e.Message, target.Error())
+}
+
+var (
+	// ErrUnauthorized means the token is missing, invalid or expired, or
+	// the password was wrong.
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+)
+
+// The errors the server reports.
+var (
+	ErrInvalidChars         = utils.ErrInvalidChars
+	ErrNeedMoreChars        = utils.ErrNeedMoreChars
+	ErrNoUser               = utils.ErrNoUser
+	ErrNoItem               = utils.ErrNoItem
+	ErrWrongPass            = utils.ErrWrongPass
+	ErrNotEnoughBalance     = utils.ErrNotEnoughBalance
+	ErrTooManyAttempts      = utils.ErrTooManyAttempts
+	ErrForbidden            = utils.ErrForbidden
+	ErrBadQuantity          = utils.ErrBadQuantity
+	ErrNotEnoughItems       = utils.ErrNotEnoughItems
+	ErrNothingToRefund      = utils.ErrNothingToRefund
+	ErrBadPage              = utils.ErrBadPage
+	ErrSelfGift             = utils.ErrSelfGift
+	ErrMessageTooLong       = utils.ErrMessageTooLong
+	ErrSelfTransfer         = utils.ErrSelfTransfer
+	ErrBadPrice             = utils.ErrBadPrice
+	ErrBadItemName          = utils.ErrBadItemName
+	ErrNoListing            = utils.ErrNoListing
+	ErrListingClosed        = utils.ErrListingClosed
+	ErrOwnListing           = utils.ErrOwnListing
+	ErrBadAmount            = utils.ErrBadAmount
+	ErrMemoTooLong          = utils.ErrMemoTooLong
+	ErrBadCategory          = utils.ErrBadCategory
+	ErrBadDirection         = utils.ErrBadDirection
+	ErrEmptyBatch           = utils.ErrEmptyBatch
+	ErrBatchTooLarge        = utils.ErrBatchTooLarge
+	ErrSelfSend             = utils.ErrSelfSend
+	ErrDuplicateRecipient   = utils.ErrDuplicateRecipient
+	ErrNoSchedule           = utils.ErrNoSchedule
+	ErrScheduleClosed       = utils.ErrScheduleClosed
+	ErrBadRecurrence        = utils.ErrBadRecurrence
+	ErrBadRunTime           = utils.ErrBadRunTime
+	ErrSelfRequest          = utils.ErrSelfRequest
+	ErrNoPaymentRequest     = utils.ErrNoPaymentRequest
+	ErrPaymentRequestClosed = utils.ErrPaymentRequestClosed
+	ErrBadRequestDirection  = utils.ErrBadRequestDirection
+	ErrBadStatus            = utils.ErrBadStatus
+	ErrTransferLimit        = utils.ErrTransferLimit
+	ErrBadLimit             = utils.ErrBadLimit
+	ErrNoTransferLimits     = utils.ErrNoTransferLimits
+	ErrReasonRequired       = utils.ErrReasonRequired
+	ErrReasonTooLong        = utils.ErrReasonTooLong
+	ErrBadBalance           = utils.ErrBadBalance
+	ErrNoUsers              = utils.ErrNoUsers
+	ErrTooManyUsers         = utils.ErrTooManyUsers
+	ErrNoAuditEvent         = utils.ErrNoAuditEvent
+	ErrBadExportFormat      = utils.ErrBadExportFormat
+	ErrBadTimeRange         = utils.ErrBadTimeRange
+	ErrNoWebhook            = utils.ErrNoWebhook
+	ErrNoWebhookDelivery    = utils.ErrNoWebhookDelivery
+	ErrBadWebhookURL        = utils.ErrBadWebhookURL
+	ErrPrivateWebhookURL    = utils.ErrPrivateWebhookURL
+	ErrBadEventType         = utils.ErrBadEventType
+	ErrNoEventTypes         = utils.ErrNoEventTypes
+	ErrTooManyWebhooks      = utils.ErrTooManyWebhooks
+	ErrNotDeadLetter        = utils.ErrNotDeadLetter
+	ErrNoNotification       = utils.ErrNoNotification
+	ErrBadNotificationKind  = utils.ErrBadNotificationKind
+	ErrBadEmail             = utils.ErrBadEmail
+	ErrBadEmailMode         = utils.ErrBadEmailMode
+)
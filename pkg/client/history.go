@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// SearchHistory lists the caller's coin transfers matching filter, newest
+// first.
+func (c *Client) SearchHistory(ctx context.Context, filter TransferFilter, limit, offset int) (*TransfersPage, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"direction": filter.Direction,
+		"category":  filter.Category,
+		"q":         filter.Query,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	var page TransfersPage
+	if err := c.call(ctx, http.MethodGet, "/api/history", pageQuery(query, limit, offset), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
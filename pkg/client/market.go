@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// CreateListing puts quantity units of an owned item up for sale at price
+// coins each.
+func (c *Client) CreateListing(ctx context.Context, itemName string, quantity, price int) (*Listing, error) {
+	body := struct {
+		Item     string `json:"item"`
+		Quantity int    `json:"quantity"`
+		Price    int    `json:"price"`
+	}{itemName, quantity, price}
+
+	var listing Listing
+	if err := c.call(ctx, http.MethodPost, "/api/market/listings", nil, body, &listing); err != nil {
+		return nil, err
+	}
+
+	return &listing, nil
+}
+
+// GetListings browses open listings, only of itemName if it is not empty.
+func (c *Client) GetListings(ctx context.Context, itemName string, limit, offset int) (*ListingsPage, error) {
+	query := url.Values{}
+	if itemName != "" {
+		query.Set("item", itemName)
+	}
+
+	var page ListingsPage
+	if err := c.call(ctx, http.MethodGet, "/api/market/listings", pageQuery(query, limit, offset), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// BuyListing buys every unit of a listing.
+func (c *Client) BuyListing(ctx context.Context, listingID int) (*Listing, error) {
+	var listing Listing
+	if err := c.call(ctx, http.MethodPost, pathf("/api/market/listings/%d/buy", listingID), nil, nil, &listing); err != nil {
+		return nil, err
+	}
+
+	return &listing, nil
+}
+
+// CancelListing takes one of the caller's listings off the market.
+func (c *Client) CancelListing(ctx context.Context, listingID int) error {
+	return c.call(ctx, http.MethodDelete, pathf("/api/market/listings/%d", listingID), nil, nil, nil)
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// GetNotifications lists the caller's notifications, newest first, only
+// unread ones if unreadOnly is set.
+func (c *Client) GetNotifications(ctx context.Context, unreadOnly bool, limit, offset int) (*NotificationsPage, error) {
+	query := url.Values{}
+	if unreadOnly {
+		query.Set("unread", "true")
+	}
+
+	var page NotificationsPage
+	if err := c.call(ctx, http.MethodGet, "/api/notifications", pageQuery(query, limit, offset), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// MarkNotificationRead marks one notification read.
+func (c *Client) MarkNotificationRead(ctx context.Context, notificationID int) error {
+	return c.call(ctx, http.MethodPost, pathf("/api/notifications/%d/read", notificationID), nil, nil, nil)
+}
+
+// MarkAllNotificationsRead marks every notification read and returns how
+// many were unread.
+func (c *Client) MarkAllNotificationsRead(ctx context.Context) (int, error) {
+	var resp struct {
+		Marked int `json:"marked"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/api/notifications/read", nil, nil, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Marked, nil
+}
+
+// GetNotificationPreferences returns which notification kinds are on.
+func (c *Client) GetNotificationPreferences(ctx context.Context) (NotificationPreferences, error) {
+	var preferences NotificationPreferences
+	if err := c.call(ctx, http.MethodGet, "/api/notifications/preferences", nil, nil, &preferences); err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// SetNotificationPreferences turns notification kinds on or off; kinds
+// left out keep their setting.
+func (c *Client) SetNotificationPreferences(ctx context.Context, preferences NotificationPreferences) (NotificationPreferences, error) {
+	var result NotificationPreferences
+	if err := c.call(ctx, http.MethodPut, "/api/notifications/preferences", nil, preferences, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetEmailSettings returns where and how the caller is emailed.
+func (c *Client) GetEmailSettings(ctx context.Context) (*EmailSettings, error) {
+	var settings EmailSettings
+	if err := c.call(ctx, http.MethodGet, "/api/notifications/email", nil, nil, &settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// SetEmailSettings sets where and how the caller is emailed.
+func (c *Client) SetEmailSettings(ctx context.Context, settings EmailSettings) (*EmailSettings, error) {
+	var result EmailSettings
+	if err := c.call(ctx, http.MethodPut, "/api/notifications/email", nil, settings, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
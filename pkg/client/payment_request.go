@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// RequestPayment asks payer for amount coins.
+func (c *Client) RequestPayment(ctx context.Context, payer string, amount int, memo, category string) (*PaymentRequest, error) {
+	body := struct {
+		FromUser string `json:"fromUser"`
+		Amount   int    `json:"amount"`
+		Memo     string `json:"memo,omitempty"`
+		Category string `json:"category,omitempty"`
+	}{payer, amount, memo, category}
+
+	var request PaymentRequest
+	if err := c.call(ctx, http.MethodPost, "/api/paymentRequests", nil, body, &request); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// GetPaymentRequests lists the caller's payment requests matching filter.
+func (c *Client) GetPaymentRequests(ctx context.Context, filter PaymentRequestFilter, limit, offset int) (*PaymentRequestsPage, error) {
+	query := url.Values{}
+	if filter.Direction != "" {
+		query.Set("direction", filter.Direction)
+	}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+
+	var page PaymentRequestsPage
+	if err := c.call(ctx, http.MethodGet, "/api/paymentRequests", pageQuery(query, limit, offset), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// AcceptPaymentRequest pays a request made to the caller.
+func (c *Client) AcceptPaymentRequest(ctx context.Context, requestID int) (*PaymentRequest, error) {
+	return c.answerPaymentRequest(ctx, pathf("/api/paymentRequests/%d/accept", requestID))
+}
+
+// DeclinePaymentRequest turns down a request made to the caller.
+func (c *Client) DeclinePaymentRequest(ctx context.Context, requestID int) (*PaymentRequest, error) {
+	return c.answerPaymentRequest(ctx, pathf("/api/paymentRequests/%d/decline", requestID))
+}
+
+func (c *Client) answerPaymentRequest(ctx context.Context, path string) (*PaymentRequest, error) {
+	var request PaymentRequest
+	if err := c.call(ctx, http.MethodPost, path, nil, nil, &request); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// CancelPaymentRequest withdraws a pending request the caller made.
+func (c *Client) CancelPaymentRequest(ctx context.Context, requestID int) error {
+	return c.call(ctx, http.MethodDelete, pathf("/api/paymentRequests/%d", requestID), nil, nil, nil)
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// CreateSchedule schedules a coin transfer.
+func (c *Client) CreateSchedule(ctx context.Context, req ScheduleRequest) (*ScheduledTransfer, error) {
+	var schedule ScheduledTransfer
+	if err := c.call(ctx, http.MethodPost, "/api/schedules", nil, req, &schedule); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// GetSchedules lists the caller's scheduled transfers.
+func (c *Client) GetSchedules(ctx context.Context) ([]*ScheduledTransfer, error) {
+	var schedules []*ScheduledTransfer
+	if err := c.call(ctx, http.MethodGet, "/api/schedules", nil, nil, &schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// GetSchedule returns a scheduled transfer with its latest runs.
+func (c *Client) GetSchedule(ctx context.Context, scheduleID int) (*ScheduledTransfer, error) {
+	var schedule ScheduledTransfer
+	if err := c.call(ctx, http.MethodGet, pathf("/api/schedules/%d", scheduleID), nil, nil, &schedule); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// UpdateSchedule replaces the settings of an active scheduled transfer.
+func (c *Client) UpdateSchedule(ctx context.Context, scheduleID int, req ScheduleRequest) (*ScheduledTransfer, error) {
+	var schedule ScheduledTransfer
+	if err := c.call(ctx, http.MethodPut, pathf("/api/schedules/%d", scheduleID), nil, req, &schedule); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// CancelSchedule stops a scheduled transfer from running again.
+func (c *Client) CancelSchedule(ctx context.Context, scheduleID int) error {
+	return c.call(ctx, http.MethodDelete, pathf("/api/schedules/%d", scheduleID), nil, nil, nil)
+}
@@ -0,0 +1,61 @@
+package client
+
+import "github.com/KonstantinGalanin/itemStore/internal/entities"
+
+// The client speaks in the server's own types. They live in an internal
+// package, so they are aliased here for programs outside this module.
+type (
+	Item                  = entities.Item
+	CatalogItem           = entities.CatalogItem
+	InfoResponse          = entities.InfoResponse
+	CoinHistory           = entities.CoinHistory
+	GiftHistory           = entities.GiftHistory
+	ItemHistory           = entities.ItemHistory
+	SentOperation         = entities.SentOperation
+	ReceiveOperation      = entities.ReceiveOperation
+	RefundOperation       = entities.RefundOperation
+	SentGift              = entities.SentGift
+	ReceivedGift          = entities.ReceivedGift
+	SentItemOperation     = entities.SentItemOperation
+	ReceivedItemOperation = entities.ReceivedItemOperation
+
+	BatchTransfer   = entities.BatchTransfer
+	BatchSendResult = entities.BatchSendResult
+	CoinTransfer    = entities.CoinTransfer
+	TransferFilter  = entities.TransferFilter
+	TransfersPage   = entities.TransfersPage
+	Order           = entities.Order
+	OrdersPage      = entities.OrdersPage
+
+	Listing      = entities.Listing
+	ListingsPage = entities.ListingsPage
+
+	ScheduleRequest      = entities.ScheduleRequest
+	ScheduledTransfer    = entities.ScheduledTransfer
+	ScheduledTransferRun = entities.ScheduledTransferRun
+
+	PaymentRequest       = entities.PaymentRequest
+	PaymentRequestFilter = entities.PaymentRequestFilter
+	PaymentRequestsPage  = entities.PaymentRequestsPage
+
+	WebhookEndpointRequest = entities.WebhookEndpointRequest
+	WebhookEndpoint        = entities.WebhookEndpoint
+	WebhookDelivery        = entities.WebhookDelivery
+	WebhookAttempt         = entities.WebhookAttempt
+	WebhookDeliveriesPage  = entities.WebhookDeliveriesPage
+
+	Notification            = entities.Notification
+	NotificationsPage       = entities.NotificationsPage
+	NotificationPreferences = entities.NotificationPreferences
+	EmailSettings           = entities.EmailSettings
+
+	TransferLimits         = entities.TransferLimits
+	UserTransferLimits     = entities.UserTransferLimits
+	BalanceAdjustment      = entities.BalanceAdjustment
+	BalanceAdjustmentsPage = entities.BalanceAdjustmentsPage
+
+	AuditFilter       = entities.AuditFilter
+	AuditEvent        = entities.AuditEvent
+	AuditEventsPage   = entities.AuditEventsPage
+	AuditVerification = entities.AuditVerification
+)
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// GetInfo returns the caller's balance, inventory and history.
+func (c *Client) GetInfo(ctx context.Context) (*InfoResponse, error) {
+	var info InfoResponse
+	if err := c.call(ctx, http.MethodGet, "/api/info", nil, nil, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// SendCoin sends amount coins to toUser. memo and category may be empty.
+func (c *Client) SendCoin(ctx context.Context, toUser string, amount int, memo, category string) error {
+	body := struct {
+		ToUser   string `json:"toUser"`
+		Amount   int    `json:"amount"`
+		Memo     string `json:"memo,omitempty"`
+		Category string `json:"category,omitempty"`
+	}{toUser, amount, memo, category}
+
+	return c.call(ctx, http.MethodPost, "/api/sendCoin", nil, body, nil)
+}
+
+// BatchSendCoin sends coins to several users at once; either every
+// transfer is made or none is.
+func (c *Client) BatchSendCoin(ctx context.Context, transfers []BatchTransfer, memo, category string) (*BatchSendResult, error) {
+	body := struct {
+		Transfers []BatchTransfer `json:"transfers"`
+		Memo      string          `json:"memo,omitempty"`
+		Category  string          `json:"category,omitempty"`
+	}{transfers, memo, category}
+
+	var result BatchSendResult
+	if err := c.call(ctx, http.MethodPost, "/api/sendCoin/batch", nil, body, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// BuyItem buys one unit of itemName for the caller.
+func (c *Client) BuyItem(ctx context.Context, itemName string) error {
+	return c.call(ctx, http.MethodPost, pathf("/api/buy/%s", itemName), nil, nil, nil)
+}
+
+// GiftItem buys one unit of itemName for toUser, with an optional message.
+func (c *Client) GiftItem(ctx context.Context, toUser, itemName, message string) error {
+	body := struct {
+		ToUser  string `json:"toUser"`
+		Message string `json:"message,omitempty"`
+	}{toUser, message}
+
+	return c.call(ctx, http.MethodPost, pathf("/api/buy/%s", itemName), nil, body, nil)
+}
+
+// RefundItem returns quantity units of itemName bought within the refund
+// window.
+func (c *Client) RefundItem(ctx context.Context, itemName string, quantity int) (*RefundOperation, error) {
+	body := struct {
+		Quantity int `json:"quantity"`
+	}{quantity}
+
+	var refund RefundOperation
+	if err := c.call(ctx, http.MethodPost, pathf("/api/refund/%s", itemName), nil, body, &refund); err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// TransferItem hands quantity units of an owned item to toUser.
+func (c *Client) TransferItem(ctx context.Context, toUser, itemName string, quantity int) error {
+	body := struct {
+		ToUser   string `json:"toUser"`
+		Quantity int    `json:"quantity"`
+	}{toUser, quantity}
+
+	return c.call(ctx, http.MethodPost, pathf("/api/transfer/%s", itemName), nil, body, nil)
+}
+
+// GetOrders lists the caller's purchases, only of itemName if it is not
+// empty. A zero limit or offset leaves the server's default.
+func (c *Client) GetOrders(ctx context.Context, itemName string, limit, offset int) (*OrdersPage, error) {
+	query := url.Values{}
+	if itemName != "" {
+		query.Set("item", itemName)
+	}
+
+	var page OrdersPage
+	if err := c.call(ctx, http.MethodGet, "/api/orders", pageQuery(query, limit, offset), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
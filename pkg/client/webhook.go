@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// CreateWebhook registers a webhook endpoint. The result is the only one
+// to include the endpoint's signing secret.
+func (c *Client) CreateWebhook(ctx context.Context, req WebhookEndpointRequest) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	if err := c.call(ctx, http.MethodPost, "/api/webhooks", nil, req, &endpoint); err != nil {
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+// GetWebhooks lists the caller's webhook endpoints.
+func (c *Client) GetWebhooks(ctx context.Context) ([]*WebhookEndpoint, error) {
+	var endpoints []*WebhookEndpoint
+	if err := c.call(ctx, http.MethodGet, "/api/webhooks", nil, nil, &endpoints); err != nil {
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+// DeleteWebhook removes a webhook endpoint.
+func (c *Client) DeleteWebhook(ctx context.Context, endpointID int) error {
+	return c.call(ctx, http.MethodDelete, pathf("/api/webhooks/%d", endpointID), nil, nil, nil)
+}
+
+// GetWebhookDeliveries lists an endpoint's deliveries, newest first, only
+// those with status if it is not empty.
+func (c *Client) GetWebhookDeliveries(ctx context.Context, endpointID int, status string, limit, offset int) (*WebhookDeliveriesPage, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+
+	var page WebhookDeliveriesPage
+	if err := c.call(ctx, http.MethodGet, pathf("/api/webhooks/%d/deliveries", endpointID), pageQuery(query, limit, offset), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// GetWebhookDelivery returns a delivery with its delivery log.
+func (c *Client) GetWebhookDelivery(ctx context.Context, endpointID, deliveryID int) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := c.call(ctx, http.MethodGet, pathf("/api/webhooks/%d/deliveries/%d", endpointID, deliveryID), nil, nil, &delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// RedeliverWebhook moves a dead delivery back into the queue.
+func (c *Client) RedeliverWebhook(ctx context.Context, endpointID, deliveryID int) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := c.call(ctx, http.MethodPost, pathf("/api/webhooks/%d/deliveries/%d/redeliver", endpointID, deliveryID), nil, nil, &delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// SendTestWebhook sends a webhook.test event to the endpoint right away.
+func (c *Client) SendTestWebhook(ctx context.Context, endpointID int) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := c.call(ctx, http.MethodPost, pathf("/api/webhooks/%d/test", endpointID), nil, nil, &delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
@@ -12,6 +12,15 @@ import (
 
 const (
 	ExpTime = 7 * 24 * time.Hour
+
+	// StreamExpTime is how long a stream token can be used to open the
+	// event stream. An open stream is not closed when it expires.
+	StreamExpTime = time.Minute
+
+	// streamAudience marks stream tokens. They travel in URLs, which end
+	// up in proxy logs and browser history, so they are only accepted by
+	// ParseStreamToken.
+	streamAudience = "events"
 )
 
 var (
@@ -20,6 +29,7 @@ var (
 
 type JWTInfo struct {
 	Username string `json:"username"`
+	Role     string `json:"role,omitempty"`
 	jwtToken.RegisteredClaims
 }
 
@@ -30,14 +40,31 @@ func NewJwtService() *JwtService {
 }
 
 func (j *JwtService) CreateToken(userItem *entities.User) ([]byte, error) {
-	claims := JWTInfo{
+	return createToken(JWTInfo{
 		Username: userItem.Username,
+		Role:     userItem.Role,
 		RegisteredClaims: jwtToken.RegisteredClaims{
 			IssuedAt:  jwtToken.NewNumericDate(time.Now()),
 			ExpiresAt: jwtToken.NewNumericDate(time.Now().Add(ExpTime)),
 		},
-	}
+	})
+}
+
+// CreateStreamToken returns a token that only opens the event stream and
+// expires after StreamExpTime, for clients that have to put it in the URL.
+func (j *JwtService) CreateStreamToken(userItem *entities.User) ([]byte, error) {
+	return createToken(JWTInfo{
+		Username: userItem.Username,
+		Role:     userItem.Role,
+		RegisteredClaims: jwtToken.RegisteredClaims{
+			Audience:  jwtToken.ClaimStrings{streamAudience},
+			IssuedAt:  jwtToken.NewNumericDate(time.Now()),
+			ExpiresAt: jwtToken.NewNumericDate(time.Now().Add(StreamExpTime)),
+		},
+	})
+}
 
+func createToken(claims JWTInfo) ([]byte, error) {
 	token := jwtToken.NewWithClaims(jwtToken.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(TokenSecret)
 	if err != nil {
@@ -55,18 +82,42 @@ func (j *JwtService) CreateToken(userItem *entities.User) ([]byte, error) {
 }
 
 func GetToken(tokenString string) (string, error) {
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
 
+	return claims.Username, nil
+}
+
+func ParseToken(tokenString string) (*JWTInfo, error) {
+	claims, err := parseToken(strings.TrimPrefix(tokenString, "Bearer "))
+	if err != nil {
+		return nil, err
+	}
+	if len(claims.Audience) > 0 {
+		return nil, fmt.Errorf("token is not valid")
+	}
+
+	return claims, nil
+}
+
+// ParseStreamToken accepts only tokens made by CreateStreamToken.
+func ParseStreamToken(tokenString string) (*JWTInfo, error) {
+	return parseToken(tokenString, jwtToken.WithAudience(streamAudience), jwtToken.WithExpirationRequired())
+}
+
+func parseToken(tokenString string, options ...jwtToken.ParserOption) (*JWTInfo, error) {
 	claims := &JWTInfo{}
 	token, err := jwtToken.ParseWithClaims(tokenString, claims, func(t *jwtToken.Token) (interface{}, error) {
 		return TokenSecret, nil
-	})
+	}, options...)
 	if err != nil {
-		return "", fmt.Errorf("invalid token: %v", err)
+		return nil, fmt.Errorf("invalid token: %v", err)
 	}
 	if !token.Valid {
-		return "", fmt.Errorf("token is not valid")
+		return nil, fmt.Errorf("token is not valid")
 	}
 
-	return claims.Username, nil
+	return claims, nil
 }
\ No newline at end of file